@@ -0,0 +1,114 @@
+package audit
+
+import (
+	"errors"
+	"time"
+
+	"github.com/Aleksao998/LightningUserVault/core/common"
+	"go.uber.org/zap"
+)
+
+// Operation is the kind of write recorded in the audit log
+type Operation string
+
+const (
+	// Create is recorded when a user is stored
+	Create Operation = "create"
+
+	// Update is recorded when the fields of a user are replaced
+	Update Operation = "update"
+
+	// Delete is recorded when a user is permanently removed
+	Delete Operation = "delete"
+
+	// SoftDelete is recorded when a user is marked as deleted
+	SoftDelete Operation = "soft_delete"
+
+	// Restore is recorded when a soft deleted user is brought back
+	Restore Operation = "restore"
+)
+
+// Entry is a single write to a user
+type Entry struct {
+	// ID is the unique identifier of the entry, increasing in recording order
+	ID int64 `json:"id"`
+	// Time is the time the write was recorded, strictly increasing in recording order
+	Time time.Time `json:"time"`
+	// Actor identifies who made the write
+	Actor string `json:"actor"`
+	// Operation is the kind of the write
+	Operation Operation `json:"operation"`
+	// RequestID is the ID of the request which made the write
+	RequestID string `json:"request_id,omitempty"`
+	// UserID is the ID of the written user
+	UserID int64 `json:"user_id"`
+	// Before is the user before the write, it is empty for created users
+	Before *common.User `json:"before,omitempty"`
+	// After is the user after the write, it is empty for deleted users
+	After *common.User `json:"after,omitempty"`
+}
+
+// EntryList represents audit entries
+type EntryList struct {
+	Entries []*Entry `json:"entries"`
+}
+
+var (
+	// ErrUnsupportedStorage is returned when the audit log is enabled on a storage which can not keep its entries
+	ErrUnsupportedStorage = errors.New("audit log is not supported by the storage type")
+)
+
+type Log interface {
+	// Record assigns an ID and time to the entry and stores it
+	Record(entry *Entry) error
+
+	// History returns up to limit entries of the user with the given ID, newest first
+	History(userID int64, limit int) ([]*Entry, error)
+
+	// Since returns up to limit entries recorded after the given time, oldest first.
+	// Passing the time of the last returned entry continues with the next page
+	Since(since time.Time, limit int) ([]*Entry, error)
+}
+
+type Config struct {
+	Enabled bool
+}
+
+// storeLog is the audit log kept in a user storage
+type storeLog struct {
+	store Store
+}
+
+// Record assigns an ID and time to the entry and stores it
+func (l *storeLog) Record(entry *Entry) error {
+	return l.store.RecordAudit(entry)
+}
+
+// History returns up to limit entries of the user with the given ID, newest first
+func (l *storeLog) History(userID int64, limit int) ([]*Entry, error) {
+	return l.store.AuditHistory(userID, limit)
+}
+
+// Since returns up to limit entries recorded after the given time, oldest first
+func (l *storeLog) Since(since time.Time, limit int) ([]*Entry, error) {
+	return l.store.AuditSince(since, limit)
+}
+
+// GetLog initializes and returns an audit log based on the provided configuration.
+// Entries are kept in the given storage
+func GetLog(logger *zap.Logger, config Config, vault interface{}) (Log, error) {
+	if !config.Enabled {
+		logger.Debug("Audit log disabled")
+
+		return nil, nil
+	}
+
+	logger.Debug("Audit log enabled")
+
+	store, ok := vault.(Store)
+	if !ok {
+		return nil, ErrUnsupportedStorage
+	}
+
+	return &storeLog{store: store}, nil
+}
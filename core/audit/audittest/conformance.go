@@ -0,0 +1,97 @@
+package audittest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Aleksao998/LightningUserVault/core/audit"
+	"github.com/Aleksao998/LightningUserVault/core/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// NewStoreFn creates an empty store under test, it is closed by the caller when the test ends
+type NewStoreFn func(t *testing.T) audit.Store
+
+// userIDs returns the user IDs of the entries in order
+func userIDs(entries []*audit.Entry) []int64 {
+	ids := make([]int64, 0, len(entries))
+	for _, entry := range entries {
+		ids = append(ids, entry.UserID)
+	}
+
+	return ids
+}
+
+// RunConformance runs the suite checking that a storage keeps audit entries
+// the same way as every other storage. Every case uses a fresh store
+func RunConformance(t *testing.T, newStore NewStoreFn) {
+	t.Helper()
+
+	t.Run("RecordAndHistory", func(t *testing.T) {
+		store := newStore(t)
+
+		before := &common.User{ID: 1, Name: "User-1", Version: 1}
+		after := &common.User{ID: 1, Name: "User-2", Version: 2}
+
+		first := &audit.Entry{Actor: "alice", Operation: audit.Create, UserID: 1, After: before}
+		other := &audit.Entry{Actor: "bob", Operation: audit.Create, UserID: 2}
+		last := &audit.Entry{Actor: "alice", Operation: audit.Update, RequestID: "req-1", UserID: 1, Before: before, After: after}
+
+		require.NoError(t, store.RecordAudit(first))
+		require.NoError(t, store.RecordAudit(other))
+		require.NoError(t, store.RecordAudit(last))
+
+		// IDs and times are strictly increasing in recording order
+		assert.Greater(t, other.ID, first.ID)
+		assert.Greater(t, last.ID, other.ID)
+		assert.True(t, other.Time.After(first.Time))
+		assert.True(t, last.Time.After(other.Time))
+
+		history, err := store.AuditHistory(1, 10)
+		require.NoError(t, err)
+		require.Len(t, history, 2)
+
+		assert.Equal(t, last.ID, history[0].ID)
+		assert.True(t, last.Time.Equal(history[0].Time))
+		assert.Equal(t, "alice", history[0].Actor)
+		assert.Equal(t, audit.Update, history[0].Operation)
+		assert.Equal(t, "req-1", history[0].RequestID)
+		assert.Equal(t, before, history[0].Before)
+		assert.Equal(t, after, history[0].After)
+		assert.Equal(t, first.ID, history[1].ID)
+		assert.Nil(t, history[1].Before)
+
+		// The limit keeps the newest entries
+		history, err = store.AuditHistory(1, 1)
+		require.NoError(t, err)
+		require.Len(t, history, 1)
+		assert.Equal(t, last.ID, history[0].ID)
+
+		history, err = store.AuditHistory(3, 10)
+		require.NoError(t, err)
+		assert.Empty(t, history)
+	})
+
+	t.Run("Since", func(t *testing.T) {
+		store := newStore(t)
+
+		for i := int64(1); i <= 5; i++ {
+			require.NoError(t, store.RecordAudit(&audit.Entry{Operation: audit.Create, UserID: i}))
+		}
+
+		page, err := store.AuditSince(time.Time{}, 3)
+		require.NoError(t, err)
+		require.Len(t, page, 3)
+		assert.Equal(t, []int64{1, 2, 3}, userIDs(page))
+
+		page, err = store.AuditSince(page[2].Time, 3)
+		require.NoError(t, err)
+		require.Len(t, page, 2)
+		assert.Equal(t, []int64{4, 5}, userIDs(page))
+
+		page, err = store.AuditSince(page[1].Time, 3)
+		require.NoError(t, err)
+		assert.Empty(t, page)
+	})
+}
@@ -0,0 +1,43 @@
+package mocks
+
+import (
+	"time"
+
+	"github.com/Aleksao998/LightningUserVault/core/audit"
+)
+
+type (
+	RecordDelegate  func(entry *audit.Entry) error
+	HistoryDelegate func(userID int64, limit int) ([]*audit.Entry, error)
+	SinceDelegate   func(since time.Time, limit int) ([]*audit.Entry, error)
+)
+
+type MockLog struct {
+	RecordFn  RecordDelegate
+	HistoryFn HistoryDelegate
+	SinceFn   SinceDelegate
+}
+
+func (m *MockLog) Record(entry *audit.Entry) error {
+	if m.RecordFn != nil {
+		return m.RecordFn(entry)
+	}
+
+	return nil
+}
+
+func (m *MockLog) History(userID int64, limit int) ([]*audit.Entry, error) {
+	if m.HistoryFn != nil {
+		return m.HistoryFn(userID, limit)
+	}
+
+	return nil, nil
+}
+
+func (m *MockLog) Since(since time.Time, limit int) ([]*audit.Entry, error) {
+	if m.SinceFn != nil {
+		return m.SinceFn(since, limit)
+	}
+
+	return nil, nil
+}
@@ -0,0 +1,106 @@
+package audit
+
+import (
+	"sync"
+	"time"
+)
+
+// Store persists audit entries. It is implemented by the user storages,
+// so entries are kept in the same database as the users they describe
+type Store interface {
+	// RecordAudit assigns the next ID and a time after the last entry to the entry and stores it
+	RecordAudit(entry *Entry) error
+
+	// AuditHistory returns up to limit entries of the user with the given ID, newest first
+	AuditHistory(userID int64, limit int) ([]*Entry, error)
+
+	// AuditSince returns up to limit entries recorded after the given time, oldest first
+	AuditSince(since time.Time, limit int) ([]*Entry, error)
+}
+
+// NextTime returns the current time in the given precision. Entries are paged by their time,
+// so it is moved after the time of the last entry if the clock did not advance past it or went back
+func NextTime(last time.Time, precision time.Duration) time.Time {
+	at := time.Now().UTC().Truncate(precision)
+	if !at.After(last) {
+		at = last.Add(precision)
+	}
+
+	return at
+}
+
+// MemoryStore keeps audit entries in memory, it is used by the in-memory user storage.
+// Entries are copied on every read and write, so callers never share state with the store
+type MemoryStore struct {
+	// lock guards entries, which are kept in recording order
+	lock    sync.RWMutex
+	entries []*Entry
+}
+
+// NewMemoryStore creates a new empty MemoryStore
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		entries: make([]*Entry, 0),
+	}
+}
+
+// copyEntry returns a copy of the entry, the users are shared since they are never changed
+func copyEntry(entry *Entry) *Entry {
+	copied := *entry
+
+	return &copied
+}
+
+// RecordAudit assigns the next ID and a time after the last entry to the entry and stores it
+func (m *MemoryStore) RecordAudit(entry *Entry) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	var last Entry
+	if len(m.entries) > 0 {
+		last = *m.entries[len(m.entries)-1]
+	}
+
+	entry.ID = last.ID + 1
+	entry.Time = NextTime(last.Time, time.Nanosecond)
+
+	m.entries = append(m.entries, copyEntry(entry))
+
+	return nil
+}
+
+// AuditHistory returns up to limit entries of the user with the given ID, newest first
+func (m *MemoryStore) AuditHistory(userID int64, limit int) ([]*Entry, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	entries := make([]*Entry, 0)
+
+	for i := len(m.entries) - 1; i >= 0 && len(entries) < limit; i-- {
+		if m.entries[i].UserID == userID {
+			entries = append(entries, copyEntry(m.entries[i]))
+		}
+	}
+
+	return entries, nil
+}
+
+// AuditSince returns up to limit entries recorded after the given time, oldest first
+func (m *MemoryStore) AuditSince(since time.Time, limit int) ([]*Entry, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	entries := make([]*Entry, 0)
+
+	for _, entry := range m.entries {
+		if len(entries) == limit {
+			break
+		}
+
+		if entry.Time.After(since) {
+			entries = append(entries, copyEntry(entry))
+		}
+	}
+
+	return entries, nil
+}
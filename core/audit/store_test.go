@@ -0,0 +1,79 @@
+package audit_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Aleksao998/LightningUserVault/core/audit"
+	"github.com/Aleksao998/LightningUserVault/core/audit/audittest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestMemoryStore_Conformance(t *testing.T) {
+	t.Parallel()
+
+	audittest.RunConformance(t, func(t *testing.T) audit.Store {
+		t.Helper()
+
+		return audit.NewMemoryStore()
+	})
+}
+
+// TestNextTime tests that times never repeat or go back, even if the clock does
+func TestNextTime(t *testing.T) {
+	t.Parallel()
+
+	last := time.Now().UTC().Add(time.Hour)
+
+	assert.Equal(t, last.Add(time.Nanosecond), audit.NextTime(last, time.Nanosecond))
+
+	// The time is moved by the precision, so it is still kept after the last one
+	assert.Equal(t, last.Truncate(time.Microsecond).Add(time.Microsecond), audit.NextTime(last.Truncate(time.Microsecond), time.Microsecond))
+
+	at := audit.NextTime(time.Time{}, time.Microsecond)
+	assert.Equal(t, at.Truncate(time.Microsecond), at)
+	assert.WithinDuration(t, time.Now(), at, time.Second)
+}
+
+func TestGetLog_Disabled(t *testing.T) {
+	t.Parallel()
+
+	log, err := audit.GetLog(zap.NewNop(), audit.Config{Enabled: false}, nil)
+	require.NoError(t, err)
+	assert.Nil(t, log)
+}
+
+func TestGetLog_UnsupportedStorage(t *testing.T) {
+	t.Parallel()
+
+	log, err := audit.GetLog(zap.NewNop(), audit.Config{Enabled: true}, struct{}{})
+	assert.ErrorIs(t, err, audit.ErrUnsupportedStorage)
+	assert.Nil(t, log)
+}
+
+func TestGetLog_Enabled(t *testing.T) {
+	t.Parallel()
+
+	store := audit.NewMemoryStore()
+
+	log, err := audit.GetLog(zap.NewNop(), audit.Config{Enabled: true}, store)
+	require.NoError(t, err)
+	require.NotNil(t, log)
+
+	// Entries are recorded in the given storage
+	require.NoError(t, log.Record(&audit.Entry{Operation: audit.Create, UserID: 1}))
+
+	history, err := store.AuditHistory(1, 10)
+	require.NoError(t, err)
+	assert.Len(t, history, 1)
+
+	history, err = log.History(1, 10)
+	require.NoError(t, err)
+	assert.Len(t, history, 1)
+
+	page, err := log.Since(time.Time{}, 10)
+	require.NoError(t, err)
+	assert.Len(t, page, 1)
+}
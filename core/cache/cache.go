@@ -4,7 +4,11 @@ import (
 	"errors"
 	"net"
 
+	"github.com/Aleksao998/LightningUserVault/core/cache/expiry"
+	"github.com/Aleksao998/LightningUserVault/core/cache/lru"
 	"github.com/Aleksao998/LightningUserVault/core/cache/memcache"
+	"github.com/Aleksao998/LightningUserVault/core/cache/redis"
+	"github.com/Aleksao998/LightningUserVault/core/cache/tiered"
 	"github.com/Aleksao998/LightningUserVault/core/command/server/types"
 	"github.com/Aleksao998/LightningUserVault/core/common"
 	"go.uber.org/zap"
@@ -16,18 +20,47 @@ type Cache interface {
 	// Set stores a value in the cache with a given key.
 	Set(key int64, value *common.User) error
 
+	// SetMissing records that no user exists for a given key, until the negative time to live passes.
+	// It does nothing when negative caching is disabled.
+	SetMissing(key int64) error
+
 	// Get retrieves a value from the cache using a given key.
+	// It returns common.ErrUserNotFound if the key is recorded as missing.
 	Get(key int64) (*common.User, error)
+
+	// GetMulti retrieves all values found in the cache for the given keys, keys which are not cached or are recorded as missing are omitted.
+	GetMulti(keys []int64) (map[int64]*common.User, error)
+
+	// Delete removes a value from the cache using a given key.
+	Delete(key int64) error
 }
 
 type Config struct {
 	CacheType       types.CacheType
 	MemcacheAddress *net.TCPAddr
-	Enabled         bool
+
+	// Expiry decides how long users and records of missing users are kept, it applies to all cache types
+	Expiry expiry.Policy
+
+	// Redis configures the connection and key prefix of the REDIS cache
+	Redis redis.Options
+
+	// InMemory configures the size bounds of the INMEMORY cache
+	InMemory lru.Options
+
+	// NearCache keeps recently read users in a local cache in front of a MEMCACHE or REDIS cache
+	NearCache bool
+
+	// Near configures the size bounds and short time to live of the local cache,
+	// records of missing users are kept locally for at most that time as well
+	Near lru.Options
+
+	Enabled bool
 }
 
 // GetCache initializes and returns a cache instance based on the provided configuration
-// The method supports multiple cache types, currently including only MEMCACHE
+// The method supports multiple cache types including MEMCACHE, REDIS and INMEMORY,
+// MEMCACHE and REDIS can be fronted by a local near cache
 func GetCache(logger *zap.Logger, config Config) (Cache, error) {
 	if !config.Enabled {
 		logger.Debug("Cache disabled")
@@ -37,10 +70,44 @@ func GetCache(logger *zap.Logger, config Config) (Cache, error) {
 
 	logger.Debug("Cache enabled")
 
+	var (
+		remote Cache
+		err    error
+	)
+
 	switch config.CacheType {
 	case types.MEMCACHE:
-		return memcache.NewMemcacheCache(logger, config.MemcacheAddress.String())
+		remote, err = memcache.NewMemcacheCache(logger, config.MemcacheAddress.String(), config.Expiry)
+	case types.REDIS:
+		options := config.Redis
+		options.Expiry = config.Expiry
+
+		remote, err = redis.NewRedisCache(logger, options)
+	case types.INMEMORY:
+		options := config.InMemory
+		options.Expiry = config.Expiry
+
+		return lru.NewLRUCache(logger, options), nil
 	default:
 		return nil, errInvalidCache
 	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if config.NearCache {
+		near := config.Near
+		near.Expiry.NegativeTTL = config.Expiry.NegativeTTL
+
+		if near.Expiry.TTL > 0 && near.Expiry.TTL < near.Expiry.NegativeTTL {
+			near.Expiry.NegativeTTL = near.Expiry.TTL
+		}
+
+		logger.Debug("Near cache enabled", zap.Duration("ttl", near.Expiry.TTL))
+
+		return tiered.NewTieredCache(logger, remote, near), nil
+	}
+
+	return remote, nil
 }
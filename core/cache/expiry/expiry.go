@@ -0,0 +1,37 @@
+package expiry
+
+import (
+	"math/rand"
+	"time"
+)
+
+// Policy decides how long users and records of missing users are kept in a cache
+type Policy struct {
+	// TTL is the time to live of cached users, zero keeps them until they are removed or evicted
+	TTL time.Duration
+
+	// Jitter is the upper bound of a random duration added to TTL, so users cached together do not expire together
+	Jitter time.Duration
+
+	// NegativeTTL is the time to live of records of missing users, zero disables negative caching
+	NegativeTTL time.Duration
+}
+
+// UserTTL returns the time to live of a newly cached user, including a random jitter
+func (p Policy) UserTTL() time.Duration {
+	if p.TTL <= 0 {
+		return 0
+	}
+
+	if p.Jitter <= 0 {
+		return p.TTL
+	}
+
+	//nolint:gosec // Jitter only spreads expirations, it does not need a secure source
+	return p.TTL + time.Duration(rand.Int63n(int64(p.Jitter)))
+}
+
+// NegativeEnabled reports if missing users are cached
+func (p Policy) NegativeEnabled() bool {
+	return p.NegativeTTL > 0
+}
@@ -0,0 +1,32 @@
+package expiry
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPolicy_UserTTL(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, time.Duration(0), Policy{}.UserTTL())
+	assert.Equal(t, time.Duration(0), Policy{Jitter: time.Minute}.UserTTL())
+	assert.Equal(t, time.Minute, Policy{TTL: time.Minute}.UserTTL())
+
+	policy := Policy{TTL: time.Minute, Jitter: 10 * time.Second}
+
+	for i := 0; i < 100; i++ {
+		ttl := policy.UserTTL()
+
+		assert.GreaterOrEqual(t, ttl, time.Minute)
+		assert.Less(t, ttl, time.Minute+10*time.Second)
+	}
+}
+
+func TestPolicy_NegativeEnabled(t *testing.T) {
+	t.Parallel()
+
+	assert.False(t, Policy{TTL: time.Minute}.NegativeEnabled())
+	assert.True(t, Policy{NegativeTTL: time.Second}.NegativeEnabled())
+}
@@ -0,0 +1,339 @@
+package lru
+
+import (
+	"container/list"
+	"encoding/json"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Aleksao998/LightningUserVault/core/cache/expiry"
+	"github.com/Aleksao998/LightningUserVault/core/common"
+	"go.uber.org/zap"
+)
+
+const (
+	// defaultShards is the number of shards used when none are configured
+	defaultShards = 16
+
+	// entryOverhead approximates the memory used by an entry besides its encoded user
+	entryOverhead = 64
+
+	// minShardBytes is the smallest size bound of a shard, smaller shards would turn away most users as oversized
+	minShardBytes = 4096
+)
+
+// ErrCacheMiss is returned when a user is not cached or its entry has expired
+var ErrCacheMiss = errors.New("cache miss")
+
+// Options configures the size bounds and expiry of the cache
+type Options struct {
+	// Shards is the number of independently locked shards, it is rounded up to a power of two.
+	// It is lowered while the size bounds would give a shard no entry or less than minShardBytes
+	Shards int
+
+	// MaxEntries is the maximum number of cached users, zero means unbounded
+	MaxEntries int
+
+	// MaxBytes is the maximum approximate size of all cached users in bytes, zero means unbounded
+	MaxBytes int64
+
+	// Expiry decides how long users and records of missing users are kept
+	Expiry expiry.Policy
+}
+
+// Stats holds counters of the cache since it was created
+type Stats struct {
+	Hits        uint64
+	Misses      uint64
+	Evictions   uint64
+	Expirations uint64
+}
+
+// entry is a cached user kept in the recency list of its shard, a missing user has no data
+type entry struct {
+	key       int64
+	data      []byte
+	expiresAt time.Time
+}
+
+// size returns the approximate memory used by the entry
+func (e *entry) size() int64 {
+	return int64(len(e.data)) + entryOverhead
+}
+
+// shard is an LRU list of a subset of keys guarded by its own lock
+type shard struct {
+	mu         sync.Mutex
+	items      map[int64]*list.Element
+	recency    *list.List
+	bytes      int64
+	maxEntries int
+	maxBytes   int64
+}
+
+type LRUCache struct {
+	shards []*shard
+	mask   uint64
+	expiry expiry.Policy
+	now    func() time.Time
+	logger *zap.Logger
+
+	hits        uint64
+	misses      uint64
+	evictions   uint64
+	expirations uint64
+}
+
+// NewLRUCache initializes a new in-process cache, the size bounds are split between the shards so they add up to the configured ones
+func NewLRUCache(logger *zap.Logger, options Options) *LRUCache {
+	shards := options.Shards
+	if shards <= 0 {
+		shards = defaultShards
+	}
+
+	count := 1
+	for count < shards {
+		count <<= 1
+	}
+
+	for count > 1 &&
+		((options.MaxEntries > 0 && count > options.MaxEntries) ||
+			(options.MaxBytes > 0 && int64(count)*minShardBytes > options.MaxBytes)) {
+		count >>= 1
+	}
+
+	c := &LRUCache{
+		shards: make([]*shard, count),
+		mask:   uint64(count - 1),
+		expiry: options.Expiry,
+		now:    time.Now,
+		logger: logger,
+	}
+
+	for i := range c.shards {
+		c.shards[i] = &shard{
+			items:      make(map[int64]*list.Element),
+			recency:    list.New(),
+			maxEntries: int(shareOf(int64(options.MaxEntries), count, i)),
+			maxBytes:   shareOf(options.MaxBytes, count, i),
+		}
+	}
+
+	registerMetrics(logger)
+
+	logger.Debug(
+		"Successfully created in-memory cache",
+		zap.Int("shards", count),
+		zap.Int("maxEntries", options.MaxEntries),
+		zap.Int64("maxBytes", options.MaxBytes),
+		zap.Duration("ttl", options.Expiry.TTL),
+	)
+
+	return c
+}
+
+// shareOf returns the part of the limit given to the shard with the given index.
+// The remainder of an even split goes to the first shards, so the parts add up to the limit
+func shareOf(limit int64, count int, index int) int64 {
+	share := limit / int64(count)
+	if int64(index) < limit%int64(count) {
+		share++
+	}
+
+	return share
+}
+
+// shardFor returns the shard of the given key
+func (c *LRUCache) shardFor(key int64) *shard {
+	// Mix the bits, so sequential IDs are spread over all shards
+	h := uint64(key) * 0x9e3779b97f4a7c15
+	h ^= h >> 32
+
+	return c.shards[h&c.mask]
+}
+
+// Set stores a user in the cache, evicting the least recently used users of its shard when it is full
+func (c *LRUCache) Set(key int64, value *common.User) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		c.logger.Error("Failed to marshal user data", zap.Int64("key", key), zap.Error(err))
+
+		return err
+	}
+
+	c.store(&entry{key: key, data: data}, c.expiry.UserTTL())
+
+	return nil
+}
+
+// SetMissing records that the user does not exist, it does nothing when negative caching is disabled
+func (c *LRUCache) SetMissing(key int64) error {
+	if !c.expiry.NegativeEnabled() {
+		return nil
+	}
+
+	c.store(&entry{key: key}, c.expiry.NegativeTTL)
+
+	return nil
+}
+
+// store adds the entry with the given time to live, evicting the least recently used entries of its shard when it is full
+func (c *LRUCache) store(e *entry, ttl time.Duration) {
+	if ttl > 0 {
+		e.expiresAt = c.now().Add(ttl)
+	}
+
+	key := e.key
+	s := c.shardFor(key)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if element, ok := s.items[key]; ok {
+		s.remove(element)
+	}
+
+	if s.maxBytes > 0 && e.size() > s.maxBytes {
+		// The user can never fit, so it is not cached at all
+		c.logger.Debug("User data exceeds in-memory cache size", zap.Int64("key", key), zap.Int64("size", e.size()))
+
+		return
+	}
+
+	s.items[key] = s.recency.PushFront(e)
+	s.bytes += e.size()
+
+	for (s.maxEntries > 0 && len(s.items) > s.maxEntries) || (s.maxBytes > 0 && s.bytes > s.maxBytes) {
+		s.remove(s.recency.Back())
+		c.recordEviction(evictionReasonSize)
+	}
+
+	c.logger.Debug("Successfully stored user data in in-memory cache", zap.Int64("key", key))
+}
+
+// Get retrieves a user from the cache, a missing or expired entry is returned as ErrCacheMiss
+// and common.ErrUserNotFound is returned if the user is recorded as missing
+func (c *LRUCache) Get(key int64) (*common.User, error) {
+	data, ok := c.lookup(key)
+	if !ok {
+		atomic.AddUint64(&c.misses, 1)
+
+		return nil, ErrCacheMiss
+	}
+
+	atomic.AddUint64(&c.hits, 1)
+
+	if data == nil {
+		return nil, common.ErrUserNotFound
+	}
+
+	var user common.User
+	if err := json.Unmarshal(data, &user); err != nil {
+		c.logger.Error("Failed to unmarshal user data", zap.Int64("key", key), zap.Error(err))
+
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// GetMulti retrieves all users found in the cache for the given keys, users recorded as missing are omitted
+func (c *LRUCache) GetMulti(keys []int64) (map[int64]*common.User, error) {
+	users := make(map[int64]*common.User, len(keys))
+
+	for _, key := range keys {
+		user, err := c.Get(key)
+		if err != nil {
+			continue
+		}
+
+		users[key] = user
+	}
+
+	c.logger.Debug("Successfully retrieved multiple user data from in-memory cache", zap.Int("keys", len(keys)), zap.Int("hits", len(users)))
+
+	return users, nil
+}
+
+// Delete removes a user from the cache, a missing entry is not considered an error
+func (c *LRUCache) Delete(key int64) error {
+	s := c.shardFor(key)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if element, ok := s.items[key]; ok {
+		s.remove(element)
+	}
+
+	return nil
+}
+
+// Len returns the number of cached users, including expired users which were not accessed since they expired
+func (c *LRUCache) Len() int {
+	total := 0
+
+	for _, s := range c.shards {
+		s.mu.Lock()
+		total += len(s.items)
+		s.mu.Unlock()
+	}
+
+	return total
+}
+
+// Stats returns the counters of the cache
+func (c *LRUCache) Stats() Stats {
+	return Stats{
+		Hits:        atomic.LoadUint64(&c.hits),
+		Misses:      atomic.LoadUint64(&c.misses),
+		Evictions:   atomic.LoadUint64(&c.evictions),
+		Expirations: atomic.LoadUint64(&c.expirations),
+	}
+}
+
+// lookup returns the encoded user of the given key and marks it as recently used, expired entries are removed
+func (c *LRUCache) lookup(key int64) ([]byte, bool) {
+	s := c.shardFor(key)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	element, ok := s.items[key]
+	if !ok {
+		return nil, false
+	}
+
+	e, _ := element.Value.(*entry)
+	if !e.expiresAt.IsZero() && !c.now().Before(e.expiresAt) {
+		s.remove(element)
+		c.recordEviction(evictionReasonExpired)
+
+		return nil, false
+	}
+
+	s.recency.MoveToFront(element)
+
+	return e.data, true
+}
+
+// recordEviction counts an entry removed by the cache itself
+func (c *LRUCache) recordEviction(reason string) {
+	if reason == evictionReasonExpired {
+		atomic.AddUint64(&c.expirations, 1)
+	} else {
+		atomic.AddUint64(&c.evictions, 1)
+	}
+
+	incEvictions(c.logger, reason)
+}
+
+// remove drops the given element from the shard, the shard lock must be held
+func (s *shard) remove(element *list.Element) {
+	e, _ := s.recency.Remove(element).(*entry)
+
+	delete(s.items, e.key)
+	s.bytes -= e.size()
+}
@@ -0,0 +1,259 @@
+package lru
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Aleksao998/LightningUserVault/core/cache/expiry"
+	"github.com/Aleksao998/LightningUserVault/core/common"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+// TestLRU_SetGet tests that cached users are copies which are read back unchanged
+func TestLRU_SetGet(t *testing.T) {
+	t.Parallel()
+
+	cache := NewLRUCache(zap.NewNop(), Options{})
+
+	user := &common.User{ID: 1, Name: "User-1", Version: 2}
+	assert.NoError(t, cache.Set(1, user))
+
+	// Changes after caching do not leak into the cache
+	user.Name = "Changed"
+
+	cached, err := cache.Get(1)
+	assert.NoError(t, err)
+	assert.Equal(t, "User-1", cached.Name)
+
+	_, err = cache.Get(2)
+	assert.ErrorIs(t, err, ErrCacheMiss)
+
+	assert.Equal(t, Stats{Hits: 1, Misses: 1}, cache.Stats())
+}
+
+// TestLRU_MaxEntries tests that the least recently used users are evicted once the cache is full
+func TestLRU_MaxEntries(t *testing.T) {
+	t.Parallel()
+
+	cache := NewLRUCache(zap.NewNop(), Options{Shards: 1, MaxEntries: 2})
+
+	assert.NoError(t, cache.Set(1, &common.User{ID: 1}))
+	assert.NoError(t, cache.Set(2, &common.User{ID: 2}))
+
+	// Reading user 1 makes user 2 the least recently used
+	_, err := cache.Get(1)
+	assert.NoError(t, err)
+
+	assert.NoError(t, cache.Set(3, &common.User{ID: 3}))
+
+	_, err = cache.Get(2)
+	assert.ErrorIs(t, err, ErrCacheMiss)
+
+	users, err := cache.GetMulti([]int64{1, 2, 3})
+	assert.NoError(t, err)
+	assert.Len(t, users, 2)
+	assert.Equal(t, 2, cache.Len())
+	assert.Equal(t, uint64(1), cache.Stats().Evictions)
+}
+
+// TestLRU_ShardLimits tests that the bounds of all shards together never exceed the configured ones
+func TestLRU_ShardLimits(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name           string
+		options        Options
+		expectedShards int
+	}{
+		{
+			name:           "Uneven entries",
+			options:        Options{Shards: 8, MaxEntries: 100},
+			expectedShards: 8,
+		},
+		{
+			name:           "Fewer entries than shards",
+			options:        Options{Shards: 16, MaxEntries: 10},
+			expectedShards: 8,
+		},
+		{
+			name:           "Uneven bytes",
+			options:        Options{Shards: 4, MaxBytes: 3*minShardBytes + 3},
+			expectedShards: 2,
+		},
+		{
+			name:           "Fewer bytes than a shard",
+			options:        Options{MaxEntries: 1000, MaxBytes: 100},
+			expectedShards: 1,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			cache := NewLRUCache(zap.NewNop(), tc.options)
+			assert.Len(t, cache.shards, tc.expectedShards)
+
+			var (
+				entries int
+				bytes   int64
+			)
+
+			for _, s := range cache.shards {
+				if tc.options.MaxEntries > 0 {
+					assert.Greater(t, s.maxEntries, 0)
+				}
+
+				entries += s.maxEntries
+				bytes += s.maxBytes
+			}
+
+			assert.Equal(t, tc.options.MaxEntries, entries)
+			assert.Equal(t, tc.options.MaxBytes, bytes)
+
+			for i := int64(0); i < 1000; i++ {
+				assert.NoError(t, cache.Set(i, &common.User{ID: i}))
+			}
+
+			if tc.options.MaxEntries > 0 {
+				assert.LessOrEqual(t, cache.Len(), tc.options.MaxEntries)
+			}
+		})
+	}
+}
+
+// TestLRU_MaxBytes tests that users are evicted to stay within the size bound and oversized users are not cached
+func TestLRU_MaxBytes(t *testing.T) {
+	t.Parallel()
+
+	cache := NewLRUCache(zap.NewNop(), Options{Shards: 1, MaxBytes: 400})
+
+	for i := int64(1); i <= 5; i++ {
+		assert.NoError(t, cache.Set(i, &common.User{ID: i, Name: "User"}))
+	}
+
+	assert.Less(t, cache.Len(), 5)
+	assert.Greater(t, cache.Stats().Evictions, uint64(0))
+
+	_, err := cache.Get(5)
+	assert.NoError(t, err)
+
+	assert.NoError(t, cache.Set(6, &common.User{ID: 6, Name: strings.Repeat("a", 500)}))
+
+	_, err = cache.Get(6)
+	assert.ErrorIs(t, err, ErrCacheMiss)
+
+	// Replacing a user with an oversized one drops the stale entry
+	assert.NoError(t, cache.Set(5, &common.User{ID: 5, Name: strings.Repeat("a", 500)}))
+
+	_, err = cache.Get(5)
+	assert.ErrorIs(t, err, ErrCacheMiss)
+}
+
+// TestLRU_TTL tests that users expire after the configured time to live
+func TestLRU_TTL(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+
+	cache := NewLRUCache(zap.NewNop(), Options{Expiry: expiry.Policy{TTL: time.Minute}})
+	cache.now = func() time.Time { return now }
+
+	assert.NoError(t, cache.Set(1, &common.User{ID: 1}))
+
+	now = now.Add(59 * time.Second)
+
+	_, err := cache.Get(1)
+	assert.NoError(t, err)
+
+	now = now.Add(time.Second)
+
+	_, err = cache.Get(1)
+	assert.ErrorIs(t, err, ErrCacheMiss)
+	assert.Equal(t, 0, cache.Len())
+	assert.Equal(t, uint64(1), cache.Stats().Expirations)
+}
+
+// TestLRU_SetMissing tests that missing users are recorded until the negative time to live passes
+func TestLRU_SetMissing(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+
+	cache := NewLRUCache(zap.NewNop(), Options{})
+	cache.now = func() time.Time { return now }
+
+	// Negative caching is disabled without a negative time to live
+	assert.NoError(t, cache.SetMissing(1))
+	assert.Equal(t, 0, cache.Len())
+
+	cache.expiry = expiry.Policy{TTL: time.Minute, NegativeTTL: 10 * time.Second}
+
+	assert.NoError(t, cache.SetMissing(1))
+
+	_, err := cache.Get(1)
+	assert.ErrorIs(t, err, common.ErrUserNotFound)
+
+	users, err := cache.GetMulti([]int64{1})
+	assert.NoError(t, err)
+	assert.Empty(t, users)
+
+	now = now.Add(10 * time.Second)
+
+	_, err = cache.Get(1)
+	assert.ErrorIs(t, err, ErrCacheMiss)
+
+	// Storing the user replaces the record of it missing
+	assert.NoError(t, cache.SetMissing(1))
+	assert.NoError(t, cache.Set(1, &common.User{ID: 1}))
+
+	_, err = cache.Get(1)
+	assert.NoError(t, err)
+}
+
+// TestLRU_Delete tests that deleting cached and missing users succeeds
+func TestLRU_Delete(t *testing.T) {
+	t.Parallel()
+
+	cache := NewLRUCache(zap.NewNop(), Options{})
+
+	assert.NoError(t, cache.Set(1, &common.User{ID: 1}))
+	assert.NoError(t, cache.Delete(1))
+	assert.NoError(t, cache.Delete(1))
+
+	_, err := cache.Get(1)
+	assert.ErrorIs(t, err, ErrCacheMiss)
+}
+
+// TestLRU_Concurrent tests that the bounds hold while users are cached from many goroutines
+func TestLRU_Concurrent(t *testing.T) {
+	t.Parallel()
+
+	cache := NewLRUCache(zap.NewNop(), Options{Shards: 4, MaxEntries: 100})
+
+	var wg sync.WaitGroup
+
+	for worker := int64(0); worker < 8; worker++ {
+		wg.Add(1)
+
+		go func(worker int64) {
+			defer wg.Done()
+
+			for i := int64(0); i < 500; i++ {
+				key := worker*500 + i
+
+				assert.NoError(t, cache.Set(key, &common.User{ID: key}))
+				_, _ = cache.Get(key - 1)
+			}
+		}(worker)
+	}
+
+	wg.Wait()
+
+	assert.LessOrEqual(t, cache.Len(), 100)
+}
@@ -0,0 +1,40 @@
+package lru
+
+import (
+	"sync"
+
+	"github.com/penglongli/gin-metrics/ginmetrics"
+	"go.uber.org/zap"
+)
+
+const (
+	// evictionsMetric counts entries removed by the in-memory cache itself
+	evictionsMetric = "cache_evictions_total"
+
+	evictionReasonSize    = "size"
+	evictionReasonExpired = "expired"
+)
+
+var registerOnce sync.Once
+
+// registerMetrics adds the cache metrics to the global monitor exposed by the router, once per process
+func registerMetrics(logger *zap.Logger) {
+	registerOnce.Do(func() {
+		err := ginmetrics.GetMonitor().AddMetric(&ginmetrics.Metric{
+			Type:        ginmetrics.Counter,
+			Name:        evictionsMetric,
+			Description: "Number of entries evicted from the in-memory cache by reason",
+			Labels:      []string{"reason"},
+		})
+		if err != nil {
+			logger.Warn("Failed to register in-memory cache metrics", zap.Error(err))
+		}
+	})
+}
+
+// incEvictions increments the evictions metric of the given reason
+func incEvictions(logger *zap.Logger, reason string) {
+	if err := ginmetrics.GetMonitor().GetMetric(evictionsMetric).Inc([]string{reason}); err != nil {
+		logger.Debug("Failed to increment in-memory cache evictions", zap.Error(err))
+	}
+}
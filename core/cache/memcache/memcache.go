@@ -2,20 +2,27 @@ package memcache
 
 import (
 	"encoding/json"
+	"errors"
 	"strconv"
+	"time"
 
+	"github.com/Aleksao998/LightningUserVault/core/cache/expiry"
 	"github.com/Aleksao998/LightningUserVault/core/common"
 	"github.com/bradfitz/gomemcache/memcache"
 	"go.uber.org/zap"
 )
 
+// maxRelativeExpiration is the longest expiration Memcache accepts in seconds, longer ones are read as a unix time
+const maxRelativeExpiration = 30 * 24 * time.Hour
+
 type MemcacheCache struct {
 	client MemcacheClient
 	logger *zap.Logger
+	expiry expiry.Policy
 }
 
-// NewMemcacheCache initializes a new Memcache cache instance
-func NewMemcacheCache(logger *zap.Logger, server string) (*MemcacheCache, error) {
+// NewMemcacheCache initializes a new Memcache cache instance, entries expire according to the given policy
+func NewMemcacheCache(logger *zap.Logger, server string, policy expiry.Policy) (*MemcacheCache, error) {
 	mc := memcache.New(server)
 
 	err := mc.Ping()
@@ -30,6 +37,7 @@ func NewMemcacheCache(logger *zap.Logger, server string) (*MemcacheCache, error)
 	return &MemcacheCache{
 		client: mc,
 		logger: logger,
+		expiry: policy,
 	}, nil
 }
 
@@ -43,8 +51,9 @@ func (m *MemcacheCache) Set(key int64, value *common.User) error {
 	}
 
 	item := &memcache.Item{
-		Key:   strconv.FormatInt(key, 10),
-		Value: data,
+		Key:        strconv.FormatInt(key, 10),
+		Value:      data,
+		Expiration: expiration(m.expiry.UserTTL()),
 	}
 
 	err = m.client.Set(item)
@@ -59,7 +68,31 @@ func (m *MemcacheCache) Set(key int64, value *common.User) error {
 	return nil
 }
 
-// Get retrieves a user from the Memcache cache
+// SetMissing records in the Memcache cache that the user does not exist, it does nothing when negative caching is disabled
+func (m *MemcacheCache) SetMissing(key int64) error {
+	if !m.expiry.NegativeEnabled() {
+		return nil
+	}
+
+	// Missing users are stored as empty values, which no encoded user can be
+	item := &memcache.Item{
+		Key:        strconv.FormatInt(key, 10),
+		Value:      []byte{},
+		Expiration: expiration(m.expiry.NegativeTTL),
+	}
+
+	if err := m.client.Set(item); err != nil {
+		m.logger.Error("Failed to set missing user in Memcache", zap.Int64("key", key), zap.Error(err))
+
+		return err
+	}
+
+	m.logger.Debug("Successfully stored missing user in Memcache", zap.Int64("key", key))
+
+	return nil
+}
+
+// Get retrieves a user from the Memcache cache, common.ErrUserNotFound is returned if the user is recorded as missing
 func (m *MemcacheCache) Get(key int64) (*common.User, error) {
 	item, err := m.client.Get(strconv.FormatInt(key, 10))
 	if err != nil {
@@ -68,6 +101,12 @@ func (m *MemcacheCache) Get(key int64) (*common.User, error) {
 		return nil, err
 	}
 
+	if len(item.Value) == 0 {
+		m.logger.Debug("Retrieved missing user from Memcache", zap.Int64("key", key))
+
+		return nil, common.ErrUserNotFound
+	}
+
 	var user common.User
 	if err := json.Unmarshal(item.Value, &user); err != nil {
 		m.logger.Error("Failed to unmarshal user data", zap.Int64("key", key), zap.Error(err))
@@ -79,3 +118,69 @@ func (m *MemcacheCache) Get(key int64) (*common.User, error) {
 
 	return &user, nil
 }
+
+// GetMulti retrieves multiple users from the Memcache cache in a single round trip
+func (m *MemcacheCache) GetMulti(keys []int64) (map[int64]*common.User, error) {
+	cacheKeys := make([]string, 0, len(keys))
+	for _, key := range keys {
+		cacheKeys = append(cacheKeys, strconv.FormatInt(key, 10))
+	}
+
+	items, err := m.client.GetMulti(cacheKeys)
+	if err != nil {
+		m.logger.Error("Failed to get multiple user data from Memcache", zap.Int("keys", len(keys)), zap.Error(err))
+
+		return nil, err
+	}
+
+	users := make(map[int64]*common.User, len(items))
+
+	for _, item := range items {
+		if len(item.Value) == 0 {
+			// Missing users are left for the caller to resolve
+			continue
+		}
+
+		var user common.User
+		if err := json.Unmarshal(item.Value, &user); err != nil {
+			// Treat corrupted entries as cache misses
+			m.logger.Warn("Failed to unmarshal user data", zap.String("key", item.Key), zap.Error(err))
+
+			continue
+		}
+
+		users[user.ID] = &user
+	}
+
+	m.logger.Debug("Successfully retrieved multiple user data from Memcache", zap.Int("keys", len(keys)), zap.Int("hits", len(users)))
+
+	return users, nil
+}
+
+// Delete removes a user from the Memcache cache, a missing entry is not considered an error
+func (m *MemcacheCache) Delete(key int64) error {
+	err := m.client.Delete(strconv.FormatInt(key, 10))
+	if err != nil && !errors.Is(err, memcache.ErrCacheMiss) {
+		m.logger.Error("Failed to delete user data from Memcache", zap.Int64("key", key), zap.Error(err))
+
+		return err
+	}
+
+	m.logger.Debug("Successfully deleted user data from Memcache", zap.Int64("key", key))
+
+	return nil
+}
+
+// expiration converts a time to live to a Memcache expiration, zero means the item does not expire
+func expiration(ttl time.Duration) int32 {
+	if ttl <= 0 {
+		return 0
+	}
+
+	if ttl > maxRelativeExpiration {
+		return int32(time.Now().Add(ttl).Unix())
+	}
+
+	// Round up, so short time to lives do not turn into no expiration at all
+	return int32((ttl + time.Second - 1) / time.Second)
+}
@@ -4,7 +4,9 @@ import (
 	"encoding/json"
 	"errors"
 	"testing"
+	"time"
 
+	"github.com/Aleksao998/LightningUserVault/core/cache/expiry"
 	"github.com/Aleksao998/LightningUserVault/core/cache/memcache/mock"
 	"github.com/Aleksao998/LightningUserVault/core/common"
 	"github.com/bradfitz/gomemcache/memcache"
@@ -50,6 +52,75 @@ func TestMemcache_SetInternalError(t *testing.T) {
 	assert.Equal(t, errInternal, err)
 }
 
+// TestMemcache_SetExpiration tests that users are stored with the time to live of the expiry policy
+func TestMemcache_SetExpiration(t *testing.T) {
+	var expirations []int32
+
+	cache := &MemcacheCache{
+		client: &mock.MockClient{
+			SetFn: func(item *memcache.Item) error {
+				expirations = append(expirations, item.Expiration)
+
+				return nil
+			},
+		},
+		logger: zap.NewNop(),
+		expiry: expiry.Policy{TTL: time.Minute, Jitter: 30 * time.Second},
+	}
+
+	for i := 0; i < 20; i++ {
+		assert.NoError(t, cache.Set(1, &common.User{Name: "User"}))
+	}
+
+	for _, expiration := range expirations {
+		assert.GreaterOrEqual(t, expiration, int32(60))
+		assert.LessOrEqual(t, expiration, int32(90))
+	}
+}
+
+// TestMemcache_Expiration tests the conversion of time to lives to Memcache expirations
+func TestMemcache_Expiration(t *testing.T) {
+	assert.Equal(t, int32(0), expiration(0))
+	assert.Equal(t, int32(1), expiration(100*time.Millisecond))
+	assert.Equal(t, int32(300), expiration(5*time.Minute))
+
+	// Expirations longer than 30 days are sent as unix times
+	unix := expiration(60 * 24 * time.Hour)
+	assert.InDelta(t, time.Now().Add(60*24*time.Hour).Unix(), int64(unix), 5)
+}
+
+// TestMemcache_SetMissing tests that missing users are stored as empty values with the negative time to live
+func TestMemcache_SetMissing(t *testing.T) {
+	var stored *memcache.Item
+
+	cache := &MemcacheCache{
+		client: &mock.MockClient{
+			SetFn: func(item *memcache.Item) error {
+				stored = item
+
+				return nil
+			},
+			GetFn: func(key string) (*memcache.Item, error) {
+				return stored, nil
+			},
+		},
+		logger: zap.NewNop(),
+	}
+
+	// Negative caching is disabled without a negative time to live
+	assert.NoError(t, cache.SetMissing(1))
+	assert.Nil(t, stored)
+
+	cache.expiry = expiry.Policy{NegativeTTL: 10 * time.Second}
+
+	assert.NoError(t, cache.SetMissing(1))
+	assert.Empty(t, stored.Value)
+	assert.Equal(t, int32(10), stored.Expiration)
+
+	_, err := cache.Get(1)
+	assert.ErrorIs(t, err, common.ErrUserNotFound)
+}
+
 // TestMemcache_GetClientError tests the scenario where the Memcache client returns an error when trying to get a user from the cache
 func TestMemcache_GetClientError(t *testing.T) {
 	cache := &MemcacheCache{
@@ -86,3 +157,97 @@ func TestMemcache_GetValid(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Equal(t, validUser.Name, user.Name)
 }
+
+// TestMemcache_DeleteValid tests the successful removal of a user from the Memcache cache
+func TestMemcache_DeleteValid(t *testing.T) {
+	var deletedKey string
+
+	cache := &MemcacheCache{
+		client: &mock.MockClient{
+			DeleteFn: func(key string) error {
+				deletedKey = key
+
+				return nil
+			},
+		},
+		logger: zap.NewNop(),
+	}
+
+	err := cache.Delete(1)
+	assert.Nil(t, err)
+	assert.Equal(t, "1", deletedKey)
+}
+
+// TestMemcache_DeleteCacheMiss tests that removing a user which is not cached is not an error
+func TestMemcache_DeleteCacheMiss(t *testing.T) {
+	cache := &MemcacheCache{
+		client: &mock.MockClient{
+			DeleteFn: func(key string) error {
+				return memcache.ErrCacheMiss
+			},
+		},
+		logger: zap.NewNop(),
+	}
+
+	err := cache.Delete(1)
+	assert.Nil(t, err)
+}
+
+// TestMemcache_DeleteClientError tests the scenario where the Memcache client returns an error when trying to delete a user
+func TestMemcache_DeleteClientError(t *testing.T) {
+	cache := &MemcacheCache{
+		client: &mock.MockClient{
+			DeleteFn: func(key string) error {
+				return errClient
+			},
+		},
+		logger: zap.NewNop(),
+	}
+
+	err := cache.Delete(1)
+	assert.Error(t, err)
+	assert.Equal(t, errClient, err)
+}
+
+// TestMemcache_GetMultiValid tests the successful retrieval of multiple users from the Memcache cache
+func TestMemcache_GetMultiValid(t *testing.T) {
+	cache := &MemcacheCache{
+		client: &mock.MockClient{
+			GetMultiFn: func(keys []string) (map[string]*memcache.Item, error) {
+				assert.Equal(t, []string{"1", "2", "3"}, keys)
+
+				data1, _ := json.Marshal(&common.User{ID: 1, Name: "User-1"})
+				data3, _ := json.Marshal(&common.User{ID: 3, Name: "User-3"})
+
+				return map[string]*memcache.Item{
+					"1": {Key: "1", Value: data1},
+					"3": {Key: "3", Value: data3},
+					"2": {Key: "2", Value: []byte{}},
+				}, nil
+			},
+		},
+		logger: zap.NewNop(),
+	}
+
+	users, err := cache.GetMulti([]int64{1, 2, 3})
+	assert.Nil(t, err)
+	assert.Len(t, users, 2)
+	assert.Equal(t, "User-1", users[1].Name)
+	assert.Equal(t, "User-3", users[3].Name)
+}
+
+// TestMemcache_GetMultiClientError tests the scenario where the Memcache client returns an error when trying to get multiple users
+func TestMemcache_GetMultiClientError(t *testing.T) {
+	cache := &MemcacheCache{
+		client: &mock.MockClient{
+			GetMultiFn: func(keys []string) (map[string]*memcache.Item, error) {
+				return nil, errClient
+			},
+		},
+		logger: zap.NewNop(),
+	}
+
+	users, err := cache.GetMulti([]int64{1, 2})
+	assert.Equal(t, errClient, err)
+	assert.Nil(t, users)
+}
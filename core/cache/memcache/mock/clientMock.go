@@ -3,13 +3,17 @@ package mock
 import "github.com/bradfitz/gomemcache/memcache"
 
 type (
-	SetDelegate func(item *memcache.Item) error
-	GetDelegate func(key string) (item *memcache.Item, err error)
+	SetDelegate      func(item *memcache.Item) error
+	GetDelegate      func(key string) (item *memcache.Item, err error)
+	GetMultiDelegate func(keys []string) (map[string]*memcache.Item, error)
+	DeleteDelegate   func(key string) error
 )
 
 type MockClient struct {
-	SetFn SetDelegate
-	GetFn GetDelegate
+	SetFn      SetDelegate
+	GetFn      GetDelegate
+	GetMultiFn GetMultiDelegate
+	DeleteFn   DeleteDelegate
 }
 
 func (m *MockClient) Set(item *memcache.Item) error {
@@ -27,3 +31,19 @@ func (m *MockClient) Get(key string) (item *memcache.Item, err error) {
 
 	return nil, nil
 }
+
+func (m *MockClient) GetMulti(keys []string) (map[string]*memcache.Item, error) {
+	if m.GetMultiFn != nil {
+		return m.GetMultiFn(keys)
+	}
+
+	return nil, nil
+}
+
+func (m *MockClient) Delete(key string) error {
+	if m.DeleteFn != nil {
+		return m.DeleteFn(key)
+	}
+
+	return nil
+}
@@ -5,13 +5,19 @@ import (
 )
 
 type (
-	SetDelegate func(key int64, value *common.User) error
-	GetDelegate func(key int64) (*common.User, error)
+	SetDelegate        func(key int64, value *common.User) error
+	SetMissingDelegate func(key int64) error
+	GetDelegate        func(key int64) (*common.User, error)
+	GetMultiDelegate   func(keys []int64) (map[int64]*common.User, error)
+	DeleteDelegate     func(key int64) error
 )
 
 type MockCache struct {
-	SetFn SetDelegate
-	GetFn GetDelegate
+	SetFn        SetDelegate
+	SetMissingFn SetMissingDelegate
+	GetFn        GetDelegate
+	GetMultiFn   GetMultiDelegate
+	DeleteFn     DeleteDelegate
 }
 
 func (m *MockCache) Set(key int64, value *common.User) error {
@@ -22,6 +28,14 @@ func (m *MockCache) Set(key int64, value *common.User) error {
 	return nil
 }
 
+func (m *MockCache) SetMissing(key int64) error {
+	if m.SetMissingFn != nil {
+		return m.SetMissingFn(key)
+	}
+
+	return nil
+}
+
 func (m *MockCache) Get(key int64) (*common.User, error) {
 	if m.GetFn != nil {
 		return m.GetFn(key)
@@ -29,3 +43,19 @@ func (m *MockCache) Get(key int64) (*common.User, error) {
 
 	return nil, nil
 }
+
+func (m *MockCache) GetMulti(keys []int64) (map[int64]*common.User, error) {
+	if m.GetMultiFn != nil {
+		return m.GetMultiFn(keys)
+	}
+
+	return nil, nil
+}
+
+func (m *MockCache) Delete(key int64) error {
+	if m.DeleteFn != nil {
+		return m.DeleteFn(key)
+	}
+
+	return nil
+}
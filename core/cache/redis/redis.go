@@ -0,0 +1,219 @@
+package redis
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/Aleksao998/LightningUserVault/core/cache/expiry"
+	"github.com/Aleksao998/LightningUserVault/core/common"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+// pingTimeout is how long the connection check on startup may take
+const pingTimeout = 5 * time.Second
+
+// Options configures the connection to Redis and how users are cached
+type Options struct {
+	// Address is the address of a standalone Redis server, it is not used when sentinel addresses are set
+	Address string
+
+	// SentinelAddresses are the addresses of Redis Sentinel nodes which are asked for the current master
+	SentinelAddresses []string
+
+	// MasterName is the name of the master monitored by the sentinels
+	MasterName string
+
+	// Password authenticates the connection to Redis
+	Password string
+
+	// TLS enables TLS for the connection to Redis
+	TLS bool
+
+	// KeyPrefix is prepended to every key, so several vaults can share one Redis database
+	KeyPrefix string
+
+	// Expiry decides how long users and records of missing users are kept
+	Expiry expiry.Policy
+}
+
+type RedisCache struct {
+	client    redis.UniversalClient
+	logger    *zap.Logger
+	keyPrefix string
+	expiry    expiry.Policy
+}
+
+// NewRedisCache initializes a new Redis cache instance, connecting through sentinels when they are configured
+func NewRedisCache(logger *zap.Logger, options Options) (*RedisCache, error) {
+	var tlsConfig *tls.Config
+	if options.TLS {
+		tlsConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+
+	var client *redis.Client
+
+	if len(options.SentinelAddresses) > 0 {
+		client = redis.NewFailoverClient(&redis.FailoverOptions{
+			MasterName:    options.MasterName,
+			SentinelAddrs: options.SentinelAddresses,
+			Password:      options.Password,
+			TLSConfig:     tlsConfig,
+		})
+	} else {
+		client = redis.NewClient(&redis.Options{
+			Addr:      options.Address,
+			Password:  options.Password,
+			TLSConfig: tlsConfig,
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), pingTimeout)
+	defer cancel()
+
+	if err := client.Ping(ctx).Err(); err != nil {
+		logger.Error("Failed to ping Redis server", zap.String("address", options.Address), zap.Error(err))
+		client.Close()
+
+		return nil, err
+	}
+
+	logger.Debug("Successfully connected to Redis server", zap.String("address", options.Address))
+
+	return &RedisCache{
+		client:    client,
+		logger:    logger,
+		keyPrefix: options.KeyPrefix,
+		expiry:    options.Expiry,
+	}, nil
+}
+
+// cacheKey returns the Redis key of the user with the given ID
+func (r *RedisCache) cacheKey(key int64) string {
+	return r.keyPrefix + strconv.FormatInt(key, 10)
+}
+
+// Set stores a user in the Redis cache with the time to live of the expiry policy
+func (r *RedisCache) Set(key int64, value *common.User) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		r.logger.Error("Failed to marshal user data", zap.Int64("key", key), zap.Error(err))
+
+		return err
+	}
+
+	err = r.client.Set(context.Background(), r.cacheKey(key), data, r.expiry.UserTTL()).Err()
+	if err != nil {
+		r.logger.Error("Failed to set user data in Redis", zap.Int64("key", key), zap.Error(err))
+
+		return err
+	}
+
+	r.logger.Debug("Successfully stored user data in Redis", zap.Int64("key", key))
+
+	return nil
+}
+
+// SetMissing records in the Redis cache that the user does not exist, it does nothing when negative caching is disabled
+func (r *RedisCache) SetMissing(key int64) error {
+	if !r.expiry.NegativeEnabled() {
+		return nil
+	}
+
+	// Missing users are stored as empty values, which no encoded user can be
+	err := r.client.Set(context.Background(), r.cacheKey(key), "", r.expiry.NegativeTTL).Err()
+	if err != nil {
+		r.logger.Error("Failed to set missing user in Redis", zap.Int64("key", key), zap.Error(err))
+
+		return err
+	}
+
+	r.logger.Debug("Successfully stored missing user in Redis", zap.Int64("key", key))
+
+	return nil
+}
+
+// Get retrieves a user from the Redis cache, a missing entry is returned as redis.Nil
+// and common.ErrUserNotFound is returned if the user is recorded as missing
+func (r *RedisCache) Get(key int64) (*common.User, error) {
+	data, err := r.client.Get(context.Background(), r.cacheKey(key)).Bytes()
+	if err != nil {
+		r.logger.Error("Failed to get user data from Redis", zap.Int64("key", key), zap.Error(err))
+
+		return nil, err
+	}
+
+	if len(data) == 0 {
+		r.logger.Debug("Retrieved missing user from Redis", zap.Int64("key", key))
+
+		return nil, common.ErrUserNotFound
+	}
+
+	var user common.User
+	if err := json.Unmarshal(data, &user); err != nil {
+		r.logger.Error("Failed to unmarshal user data", zap.Int64("key", key), zap.Error(err))
+
+		return nil, err
+	}
+
+	r.logger.Debug("Successfully retrieved user data from Redis", zap.Int64("key", key))
+
+	return &user, nil
+}
+
+// GetMulti retrieves multiple users from the Redis cache in a single round trip
+func (r *RedisCache) GetMulti(keys []int64) (map[int64]*common.User, error) {
+	cacheKeys := make([]string, 0, len(keys))
+	for _, key := range keys {
+		cacheKeys = append(cacheKeys, r.cacheKey(key))
+	}
+
+	values, err := r.client.MGet(context.Background(), cacheKeys...).Result()
+	if err != nil {
+		r.logger.Error("Failed to get multiple user data from Redis", zap.Int("keys", len(keys)), zap.Error(err))
+
+		return nil, err
+	}
+
+	users := make(map[int64]*common.User, len(values))
+
+	for i, value := range values {
+		// Missing keys are returned as nil
+		data, ok := value.(string)
+		if !ok || data == "" {
+			// Missing users are left for the caller to resolve
+			continue
+		}
+
+		var user common.User
+		if err := json.Unmarshal([]byte(data), &user); err != nil {
+			// Treat corrupted entries as cache misses
+			r.logger.Warn("Failed to unmarshal user data", zap.String("key", cacheKeys[i]), zap.Error(err))
+
+			continue
+		}
+
+		users[user.ID] = &user
+	}
+
+	r.logger.Debug("Successfully retrieved multiple user data from Redis", zap.Int("keys", len(keys)), zap.Int("hits", len(users)))
+
+	return users, nil
+}
+
+// Delete removes a user from the Redis cache, a missing entry is not considered an error
+func (r *RedisCache) Delete(key int64) error {
+	err := r.client.Del(context.Background(), r.cacheKey(key)).Err()
+	if err != nil {
+		r.logger.Error("Failed to delete user data from Redis", zap.Int64("key", key), zap.Error(err))
+
+		return err
+	}
+
+	r.logger.Debug("Successfully deleted user data from Redis", zap.Int64("key", key))
+
+	return nil
+}
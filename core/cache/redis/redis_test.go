@@ -0,0 +1,159 @@
+package redis
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Aleksao998/LightningUserVault/core/cache/expiry"
+	"github.com/Aleksao998/LightningUserVault/core/common"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+// createRedisCache starts an in-process Redis server and connects a cache to it
+func createRedisCache(t *testing.T, options Options) (*miniredis.Miniredis, *RedisCache) {
+	t.Helper()
+
+	server := miniredis.RunT(t)
+
+	options.Address = server.Addr()
+
+	cache, err := NewRedisCache(zap.NewNop(), options)
+	if err != nil {
+		t.Fatalf("error creating redis cache, %v", err)
+	}
+
+	return server, cache
+}
+
+// TestRedis_SetGet tests that users are stored under prefixed keys and read back
+func TestRedis_SetGet(t *testing.T) {
+	t.Parallel()
+
+	server, cache := createRedisCache(t, Options{KeyPrefix: "vault:"})
+
+	user := &common.User{ID: 1, Name: "User-1", Version: 2}
+
+	assert.NoError(t, cache.Set(1, user))
+	assert.True(t, server.Exists("vault:1"))
+
+	cached, err := cache.Get(1)
+	assert.NoError(t, err)
+	assert.Equal(t, user, cached)
+
+	_, err = cache.Get(2)
+	assert.ErrorIs(t, err, redis.Nil)
+}
+
+// TestRedis_TTL tests that cached users expire after the configured time to live
+func TestRedis_TTL(t *testing.T) {
+	t.Parallel()
+
+	server, cache := createRedisCache(t, Options{Expiry: expiry.Policy{TTL: time.Minute, Jitter: 10 * time.Second}})
+
+	assert.NoError(t, cache.Set(1, &common.User{ID: 1}))
+	assert.GreaterOrEqual(t, server.TTL("1"), time.Minute)
+	assert.Less(t, server.TTL("1"), time.Minute+10*time.Second)
+
+	server.FastForward(time.Minute + 10*time.Second)
+
+	_, err := cache.Get(1)
+	assert.ErrorIs(t, err, redis.Nil)
+}
+
+// TestRedis_NoTTL tests that cached users are kept when no time to live is configured
+func TestRedis_NoTTL(t *testing.T) {
+	t.Parallel()
+
+	server, cache := createRedisCache(t, Options{})
+
+	assert.NoError(t, cache.Set(1, &common.User{ID: 1}))
+	assert.Equal(t, time.Duration(0), server.TTL("1"))
+}
+
+// TestRedis_SetMissing tests that missing users are recorded with the negative time to live
+func TestRedis_SetMissing(t *testing.T) {
+	t.Parallel()
+
+	server, cache := createRedisCache(t, Options{})
+
+	// Negative caching is disabled without a negative time to live
+	assert.NoError(t, cache.SetMissing(1))
+	assert.False(t, server.Exists("1"))
+
+	cache.expiry = expiry.Policy{NegativeTTL: 10 * time.Second}
+
+	assert.NoError(t, cache.SetMissing(1))
+	assert.Equal(t, 10*time.Second, server.TTL("1"))
+
+	_, err := cache.Get(1)
+	assert.ErrorIs(t, err, common.ErrUserNotFound)
+
+	users, err := cache.GetMulti([]int64{1})
+	assert.NoError(t, err)
+	assert.Empty(t, users)
+
+	server.FastForward(10 * time.Second)
+
+	_, err = cache.Get(1)
+	assert.ErrorIs(t, err, redis.Nil)
+}
+
+// TestRedis_GetMulti tests that only cached and valid users are returned
+func TestRedis_GetMulti(t *testing.T) {
+	t.Parallel()
+
+	server, cache := createRedisCache(t, Options{KeyPrefix: "vault:"})
+
+	assert.NoError(t, cache.Set(1, &common.User{ID: 1, Name: "User-1"}))
+	assert.NoError(t, cache.Set(2, &common.User{ID: 2, Name: "User-2"}))
+	assert.NoError(t, server.Set("vault:3", "corrupted"))
+
+	users, err := cache.GetMulti([]int64{1, 2, 3, 4})
+	assert.NoError(t, err)
+	assert.Len(t, users, 2)
+	assert.Equal(t, "User-2", users[2].Name)
+}
+
+// TestRedis_Delete tests that deleting cached and missing users succeeds
+func TestRedis_Delete(t *testing.T) {
+	t.Parallel()
+
+	server, cache := createRedisCache(t, Options{})
+
+	assert.NoError(t, cache.Set(1, &common.User{ID: 1}))
+	assert.NoError(t, cache.Delete(1))
+	assert.False(t, server.Exists("1"))
+	assert.NoError(t, cache.Delete(1))
+}
+
+// TestRedis_Password tests that the cache authenticates with the configured password
+func TestRedis_Password(t *testing.T) {
+	t.Parallel()
+
+	server := miniredis.RunT(t)
+	server.RequireAuth("secret")
+
+	_, err := NewRedisCache(zap.NewNop(), Options{Address: server.Addr(), Password: "wrong"})
+	assert.Error(t, err)
+
+	cache, err := NewRedisCache(zap.NewNop(), Options{Address: server.Addr(), Password: "secret"})
+	assert.NoError(t, err)
+	assert.NoError(t, cache.Set(1, &common.User{ID: 1}))
+}
+
+// TestRedis_ServerError tests that errors are returned once the server is gone
+func TestRedis_ServerError(t *testing.T) {
+	t.Parallel()
+
+	server, cache := createRedisCache(t, Options{})
+
+	server.Close()
+
+	assert.Error(t, cache.Set(1, &common.User{ID: 1}))
+
+	_, err := cache.GetMulti([]int64{1})
+	assert.Error(t, err)
+}
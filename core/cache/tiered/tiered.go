@@ -0,0 +1,132 @@
+package tiered
+
+import (
+	"errors"
+
+	"github.com/Aleksao998/LightningUserVault/core/cache/lru"
+	"github.com/Aleksao998/LightningUserVault/core/common"
+	"go.uber.org/zap"
+)
+
+// Remote is the shared cache consulted when a user is not found in the local cache
+type Remote interface {
+	Set(key int64, value *common.User) error
+	SetMissing(key int64) error
+	Get(key int64) (*common.User, error)
+	GetMulti(keys []int64) (map[int64]*common.User, error)
+	Delete(key int64) error
+}
+
+// TieredCache keeps recently read users in a small local cache in front of a shared remote cache.
+// Local entries expire after a short time to live, which bounds how long a change made through another
+// instance may be missed
+type TieredCache struct {
+	local  *lru.LRUCache
+	remote Remote
+	logger *zap.Logger
+}
+
+// NewTieredCache initializes a new two-tier cache with a local cache of the given options in front of the remote cache.
+// The negative time to live of the local cache should not exceed the one of the remote cache
+func NewTieredCache(logger *zap.Logger, remote Remote, options lru.Options) *TieredCache {
+	return &TieredCache{
+		local:  lru.NewLRUCache(logger, options),
+		remote: remote,
+		logger: logger,
+	}
+}
+
+// Set stores a user in the remote cache and then in the local cache
+func (t *TieredCache) Set(key int64, value *common.User) error {
+	if err := t.remote.Set(key, value); err != nil {
+		// Do not keep serving a local copy which the remote cache does not agree with
+		_ = t.local.Delete(key)
+
+		return err
+	}
+
+	return t.local.Set(key, value)
+}
+
+// SetMissing records that the user does not exist in the remote cache and then in the local cache
+func (t *TieredCache) SetMissing(key int64) error {
+	if err := t.remote.SetMissing(key); err != nil {
+		_ = t.local.Delete(key)
+
+		return err
+	}
+
+	return t.local.SetMissing(key)
+}
+
+// Get retrieves a user from the local cache, falling back to the remote cache and keeping the result locally
+func (t *TieredCache) Get(key int64) (*common.User, error) {
+	user, err := t.local.Get(key)
+	if err == nil || errors.Is(err, common.ErrUserNotFound) {
+		return user, err
+	}
+
+	user, err = t.remote.Get(key)
+	if errors.Is(err, common.ErrUserNotFound) {
+		// Keep the record of the missing user locally as well
+		if err := t.local.SetMissing(key); err != nil {
+			t.logger.Warn("Failed to store missing user in local cache", zap.Int64("key", key), zap.Error(err))
+		}
+
+		return nil, err
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := t.local.Set(key, user); err != nil {
+		t.logger.Warn("Failed to store user data in local cache", zap.Int64("key", key), zap.Error(err))
+	}
+
+	return user, nil
+}
+
+// GetMulti retrieves users from the local cache and only the missing users from the remote cache
+func (t *TieredCache) GetMulti(keys []int64) (map[int64]*common.User, error) {
+	users, err := t.local.GetMulti(keys)
+	if err != nil {
+		return nil, err
+	}
+
+	missing := make([]int64, 0, len(keys)-len(users))
+
+	for _, key := range keys {
+		if _, ok := users[key]; !ok {
+			missing = append(missing, key)
+		}
+	}
+
+	if len(missing) == 0 {
+		return users, nil
+	}
+
+	remoteUsers, err := t.remote.GetMulti(missing)
+	if err != nil {
+		return nil, err
+	}
+
+	for key, user := range remoteUsers {
+		if err := t.local.Set(key, user); err != nil {
+			t.logger.Warn("Failed to store user data in local cache", zap.Int64("key", key), zap.Error(err))
+		}
+
+		users[key] = user
+	}
+
+	return users, nil
+}
+
+// Delete removes a user from both caches
+func (t *TieredCache) Delete(key int64) error {
+	if err := t.local.Delete(key); err != nil {
+		return err
+	}
+
+	return t.remote.Delete(key)
+}
@@ -0,0 +1,218 @@
+package tiered
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Aleksao998/LightningUserVault/core/cache/expiry"
+	"github.com/Aleksao998/LightningUserVault/core/cache/lru"
+	"github.com/Aleksao998/LightningUserVault/core/cache/mocks"
+	"github.com/Aleksao998/LightningUserVault/core/common"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+var errRemote = errors.New("remote error")
+
+// newRemote returns a mocked remote cache backed by a map, counting reads
+func newRemote(reads *int) (*mocks.MockCache, map[int64]*common.User) {
+	users := make(map[int64]*common.User)
+
+	return &mocks.MockCache{
+		SetFn: func(key int64, value *common.User) error {
+			users[key] = value
+
+			return nil
+		},
+		GetFn: func(key int64) (*common.User, error) {
+			*reads++
+
+			user, ok := users[key]
+			if !ok {
+				return nil, lru.ErrCacheMiss
+			}
+
+			return user, nil
+		},
+		GetMultiFn: func(keys []int64) (map[int64]*common.User, error) {
+			*reads++
+
+			found := make(map[int64]*common.User)
+
+			for _, key := range keys {
+				if user, ok := users[key]; ok {
+					found[key] = user
+				}
+			}
+
+			return found, nil
+		},
+		DeleteFn: func(key int64) error {
+			delete(users, key)
+
+			return nil
+		},
+	}, users
+}
+
+// TestTiered_Get tests that users read from the remote cache are served locally afterwards
+func TestTiered_Get(t *testing.T) {
+	t.Parallel()
+
+	reads := 0
+	remote, users := newRemote(&reads)
+	users[1] = &common.User{ID: 1, Name: "User-1"}
+
+	cache := NewTieredCache(zap.NewNop(), remote, lru.Options{Expiry: expiry.Policy{TTL: time.Minute}})
+
+	for i := 0; i < 3; i++ {
+		user, err := cache.Get(1)
+		assert.NoError(t, err)
+		assert.Equal(t, "User-1", user.Name)
+	}
+
+	assert.Equal(t, 1, reads)
+
+	_, err := cache.Get(2)
+	assert.ErrorIs(t, err, lru.ErrCacheMiss)
+}
+
+// TestTiered_LocalTTL tests that local entries expire, so remote changes are picked up
+func TestTiered_LocalTTL(t *testing.T) {
+	t.Parallel()
+
+	reads := 0
+	remote, users := newRemote(&reads)
+	users[1] = &common.User{ID: 1, Name: "User-1"}
+
+	cache := NewTieredCache(zap.NewNop(), remote, lru.Options{Expiry: expiry.Policy{TTL: 20 * time.Millisecond}})
+
+	_, err := cache.Get(1)
+	assert.NoError(t, err)
+
+	// Another instance updates the remote cache
+	users[1] = &common.User{ID: 1, Name: "Updated"}
+
+	assert.Eventually(t, func() bool {
+		user, err := cache.Get(1)
+
+		return err == nil && user.Name == "Updated"
+	}, time.Second, 5*time.Millisecond)
+}
+
+// TestTiered_GetMulti tests that only users missing locally are read from the remote cache
+func TestTiered_GetMulti(t *testing.T) {
+	t.Parallel()
+
+	reads := 0
+	remote, users := newRemote(&reads)
+	users[2] = &common.User{ID: 2}
+	users[3] = &common.User{ID: 3}
+
+	var requested []int64
+
+	getMulti := remote.GetMultiFn
+	remote.GetMultiFn = func(keys []int64) (map[int64]*common.User, error) {
+		requested = keys
+
+		return getMulti(keys)
+	}
+
+	cache := NewTieredCache(zap.NewNop(), remote, lru.Options{Expiry: expiry.Policy{TTL: time.Minute}})
+	assert.NoError(t, cache.Set(1, &common.User{ID: 1}))
+
+	found, err := cache.GetMulti([]int64{1, 2, 3, 4})
+	assert.NoError(t, err)
+	assert.Len(t, found, 3)
+	assert.Equal(t, []int64{2, 3, 4}, requested)
+
+	// All found users are now served locally
+	found, err = cache.GetMulti([]int64{1, 2, 3})
+	assert.NoError(t, err)
+	assert.Len(t, found, 3)
+	assert.Equal(t, 1, reads)
+}
+
+// TestTiered_SetError tests that the local copy is dropped when the remote cache can not be updated
+func TestTiered_SetError(t *testing.T) {
+	t.Parallel()
+
+	reads := 0
+	remote, _ := newRemote(&reads)
+
+	cache := NewTieredCache(zap.NewNop(), remote, lru.Options{Expiry: expiry.Policy{TTL: time.Minute}})
+	assert.NoError(t, cache.Set(1, &common.User{ID: 1}))
+
+	remote.SetFn = func(key int64, value *common.User) error {
+		return errRemote
+	}
+	remote.GetFn = func(key int64) (*common.User, error) {
+		return nil, errRemote
+	}
+
+	assert.ErrorIs(t, cache.Set(1, &common.User{ID: 1, Name: "Updated"}), errRemote)
+
+	_, err := cache.Get(1)
+	assert.ErrorIs(t, err, errRemote)
+}
+
+// TestTiered_SetMissing tests that missing users are recorded in both caches and served locally
+func TestTiered_SetMissing(t *testing.T) {
+	t.Parallel()
+
+	reads := 0
+	missing := make(map[int64]bool)
+	remote, _ := newRemote(&reads)
+	remote.SetMissingFn = func(key int64) error {
+		missing[key] = true
+
+		return nil
+	}
+	remote.GetFn = func(key int64) (*common.User, error) {
+		reads++
+
+		if missing[key] {
+			return nil, common.ErrUserNotFound
+		}
+
+		return nil, lru.ErrCacheMiss
+	}
+
+	policy := expiry.Policy{TTL: time.Minute, NegativeTTL: time.Minute}
+
+	cache := NewTieredCache(zap.NewNop(), remote, lru.Options{Expiry: policy})
+	assert.NoError(t, cache.SetMissing(1))
+	assert.True(t, missing[1])
+
+	_, err := cache.Get(1)
+	assert.ErrorIs(t, err, common.ErrUserNotFound)
+	assert.Equal(t, 0, reads)
+
+	// A user recorded as missing by another instance is kept locally after the first read
+	missing[2] = true
+
+	for i := 0; i < 2; i++ {
+		_, err = cache.Get(2)
+		assert.ErrorIs(t, err, common.ErrUserNotFound)
+	}
+
+	assert.Equal(t, 1, reads)
+}
+
+// TestTiered_Delete tests that users are removed from both caches
+func TestTiered_Delete(t *testing.T) {
+	t.Parallel()
+
+	reads := 0
+	remote, users := newRemote(&reads)
+
+	cache := NewTieredCache(zap.NewNop(), remote, lru.Options{Expiry: expiry.Policy{TTL: time.Minute}})
+	assert.NoError(t, cache.Set(1, &common.User{ID: 1}))
+	assert.NoError(t, cache.Delete(1))
+	assert.NotContains(t, users, int64(1))
+
+	_, err := cache.Get(1)
+	assert.ErrorIs(t, err, lru.ErrCacheMiss)
+	assert.Equal(t, 1, reads)
+}
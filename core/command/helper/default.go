@@ -3,9 +3,28 @@ package helper
 type IPBinding string
 
 const (
-	DefaultServerEndpoint           = "localhost"
-	DefaultServerPort               = "9090"
-	DefaultMemcachePort             = "11211"
-	DefaultDatabasePort             = "5432"
-	LocalHostBinding      IPBinding = "127.0.0.1"
+	DefaultServerEndpoint                = "localhost"
+	DefaultServerPort                    = "9090"
+	DefaultMemcachePort                  = "11211"
+	DefaultRedisPort                     = "6379"
+	DefaultCacheTTL                      = "0"
+	DefaultCacheTTLJitter                = "0"
+	DefaultCacheNegativeTTL              = "0"
+	DefaultCacheMaxEntries               = "100000"
+	DefaultCacheMaxBytes                 = "67108864"
+	DefaultNearCacheTTL                  = "5s"
+	DefaultNearCacheMaxEntries           = "10000"
+	DefaultCacheEarlyRefresh             = "0"
+	DefaultDatabasePort                  = "5432"
+	DefaultDatabasePath                  = "vault.db"
+	DefaultDatabaseCharset               = "utf8mb4"
+	DefaultDatabaseCollation             = "utf8mb4_bin"
+	DefaultSearchIndexPath               = "search-index"
+	DefaultSoftDeleteRetention           = "720h"
+	DefaultEventLogPath                  = "event-log"
+	DefaultWebhookMaxAttempts            = "8"
+	DefaultOutboxInterval                = "1s"
+	DefaultVersionRetention              = "720h"
+	DefaultVersionCompaction             = "1h"
+	LocalHostBinding           IPBinding = "127.0.0.1"
 )
@@ -34,6 +34,18 @@ func ResolveAddr(address string, defaultIP IPBinding) (*net.TCPAddr, error) {
 	return addr, nil
 }
 
+// GetEnvWithDefault returns the value of the environment variable with the given key,
+// or the default value if the variable is not set
+func GetEnvWithDefault(key, defaultValue string) string {
+	if value, exists := os.LookupEnv(key); exists {
+		fmt.Println(fmt.Sprintf("Exists %s", value))
+
+		return value
+	}
+
+	return defaultValue
+}
+
 // HandleSignals is a helper method for handling signals sent to the console
 // Like stop, error, etc.
 func HandleSignals(
@@ -0,0 +1,79 @@
+package rebuildindex
+
+import (
+	"net"
+
+	"github.com/Aleksao998/LightningUserVault/core/command/helper"
+	"github.com/Aleksao998/LightningUserVault/core/command/server/types"
+)
+
+var (
+	params = &rebuildIndexParams{}
+)
+
+const (
+	storageTypeFlag     = "storage-type"
+	dbHostRawFlag       = "database-host"
+	dbUserFlag          = "database-user"
+	dbPassFlag          = "database-pass"
+	dbNameFlag          = "database-name"
+	dbPathFlag          = "database-path"
+	dbCharsetFlag       = "database-charset"
+	dbCollationFlag     = "database-collation"
+	searchIndexPathFlag = "search-index-path"
+)
+
+type rebuildIndexParams struct {
+	// storageType is a storage type [PEBBLE, POSTGRESQL, MEMORY, SQLITE, MYSQL, BOLT]
+	storageType types.StorageType
+
+	// storageTypeRaw is a raw storage type
+	storageTypeRaw string
+
+	// dbHost is an address of database host
+	dbHost *net.TCPAddr
+
+	// dbHostRaw is a raw address of database host
+	dbHostRaw string
+
+	// dbUser is a user name for database
+	dbUser string
+
+	// dbUser is a password for database user
+	dbPass string
+
+	// dbName is a name of database
+	dbName string
+
+	// dbPath is a path of the SQLite or bolt database file
+	dbPath string
+
+	// dbCharset is a character set of the MySQL database
+	dbCharset string
+
+	// dbCollation is a collation of the MySQL database
+	dbCollation string
+
+	// searchIndexPath is a path of the search index snapshot
+	searchIndexPath string
+}
+
+func (p *rebuildIndexParams) initRawParams() error {
+	var err error
+
+	// Parse storage type
+	p.storageType, err = types.ConvertStringToStorageType(p.storageTypeRaw)
+	if err != nil {
+		return err
+	}
+
+	// Parse db host address
+	if p.dbHost, err = helper.ResolveAddr(
+		p.dbHostRaw,
+		helper.LocalHostBinding,
+	); err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,140 @@
+package rebuildindex
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/Aleksao998/LightningUserVault/core/command/helper"
+	"github.com/Aleksao998/LightningUserVault/core/search"
+	"github.com/Aleksao998/LightningUserVault/core/search/fulltext"
+	"github.com/Aleksao998/LightningUserVault/core/storage"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+func GetCommand() *cobra.Command {
+	rebuildIndexCmd := &cobra.Command{
+		Use:     "rebuild-index",
+		Short:   "Rebuilds the full-text search index from storage, the server must be stopped while it runs",
+		PreRunE: runPreRun,
+		Run:     runCommand,
+	}
+
+	setFlags(rebuildIndexCmd)
+
+	return rebuildIndexCmd
+}
+
+func setFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(
+		&params.storageTypeRaw,
+		storageTypeFlag,
+		helper.GetEnvWithDefault("STORAGE_TYPE", "PEBBLE"),
+		"the type of storage, supported [PEBBLE, POSTGRESQL, MEMORY, SQLITE, MYSQL, BOLT]",
+	)
+
+	cmd.Flags().StringVar(
+		&params.dbHostRaw,
+		dbHostRawFlag,
+		helper.GetEnvWithDefault("DB_HOST", fmt.Sprintf("%s:%s", helper.DefaultServerEndpoint, helper.DefaultDatabasePort)),
+		"database host endpoint",
+	)
+
+	cmd.Flags().StringVar(
+		&params.dbUser,
+		dbUserFlag,
+		helper.GetEnvWithDefault("DB_USER", "postgres"),
+		"database user",
+	)
+
+	cmd.Flags().StringVar(
+		&params.dbPass,
+		dbPassFlag,
+		helper.GetEnvWithDefault("DB_PASS", "postgres"),
+		"database password",
+	)
+
+	cmd.Flags().StringVar(
+		&params.dbName,
+		dbNameFlag,
+		helper.GetEnvWithDefault("DB_NAME", "postgres"),
+		"database name",
+	)
+
+	cmd.Flags().StringVar(
+		&params.dbPath,
+		dbPathFlag,
+		helper.GetEnvWithDefault("DB_PATH", helper.DefaultDatabasePath),
+		"path of the SQLite or bolt database file",
+	)
+
+	cmd.Flags().StringVar(
+		&params.dbCharset,
+		dbCharsetFlag,
+		helper.GetEnvWithDefault("DB_CHARSET", helper.DefaultDatabaseCharset),
+		"character set of the MySQL database",
+	)
+
+	cmd.Flags().StringVar(
+		&params.dbCollation,
+		dbCollationFlag,
+		helper.GetEnvWithDefault("DB_COLLATION", helper.DefaultDatabaseCollation),
+		"collation of the MySQL database, it decides how user names are compared",
+	)
+
+	cmd.Flags().StringVar(
+		&params.searchIndexPath,
+		searchIndexPathFlag,
+		helper.GetEnvWithDefault("SEARCH_INDEX_PATH", helper.DefaultSearchIndexPath),
+		"path of the full-text search index snapshot",
+	)
+}
+
+func runCommand(cmd *cobra.Command, _ []string) {
+	if err := rebuildIndex(); err != nil {
+		fmt.Println("ERROR: ", err)
+
+		return
+	}
+
+	fmt.Println("Search index rebuilt")
+}
+
+func runPreRun(cmd *cobra.Command, _ []string) error {
+	return params.initRawParams()
+}
+
+func rebuildIndex() error {
+	logger, err := zap.NewProduction()
+	if err != nil {
+		return err
+	}
+
+	defer logger.Sync()
+
+	vault, err := storage.GetStorage(logger, storage.Config{
+		StorageType: params.storageType,
+		DBHost:      params.dbHost.IP.String(),
+		DBPort:      strconv.Itoa(params.dbHost.Port),
+		DBPass:      params.dbPass,
+		DBName:      params.dbName,
+		DBUser:      params.dbUser,
+		DBPath:      params.dbPath,
+		DBCharset:   params.dbCharset,
+		DBCollation: params.dbCollation,
+	})
+	if err != nil {
+		return err
+	}
+
+	defer vault.Close()
+
+	// Start from an empty index, an existing snapshot is overwritten on close
+	index := fulltext.NewIndex(logger, params.searchIndexPath)
+
+	if err := search.Rebuild(index, vault); err != nil {
+		return err
+	}
+
+	return index.Close()
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/Aleksao998/LightningUserVault/core/command/rebuildindex"
 	"github.com/Aleksao998/LightningUserVault/core/command/server"
 	"github.com/spf13/cobra"
 )
@@ -27,6 +28,7 @@ func NewRootCommand() *RootCommand {
 func (rc *RootCommand) registerSubCommands() {
 	rc.baseCmd.AddCommand(
 		server.GetCommand(),
+		rebuildindex.GetCommand(),
 	)
 }
 
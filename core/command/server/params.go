@@ -1,9 +1,12 @@
 package server
 
 import (
+	"errors"
 	"log"
 	"net"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/Aleksao998/LightningUserVault/core/command/helper"
 	"github.com/Aleksao998/LightningUserVault/core/command/server/types"
@@ -13,19 +16,53 @@ import (
 
 var (
 	params = &serverParams{}
+
+	errInvalidEarlyRefresh = errors.New("cache early refresh probability must be between 0 and 1")
 )
 
 const (
-	logLevelFlag        = "log-level"
-	serverAddressFlag   = "server-address"
-	enabledCacheFlag    = "enable-cache"
-	cacheTypeFlag       = "cache-type"
-	memcacheAddressFlag = "memcache-address"
-	storageTypeFlag     = "storage-type"
-	dbHostRawFlag       = "database-host"
-	dbUserFlag          = "database-user"
-	dbPassFlag          = "database-pass"
-	dbNameFlag          = "database-name"
+	logLevelFlag            = "log-level"
+	serverAddressFlag       = "server-address"
+	enabledCacheFlag        = "enable-cache"
+	cacheTypeFlag           = "cache-type"
+	memcacheAddressFlag     = "memcache-address"
+	redisAddressFlag        = "redis-address"
+	redisSentinelsFlag      = "redis-sentinel-addresses"
+	redisMasterNameFlag     = "redis-master-name"
+	redisPasswordFlag       = "redis-password"
+	redisTLSFlag            = "redis-tls"
+	redisKeyPrefixFlag      = "redis-key-prefix"
+	cacheTTLFlag            = "cache-ttl"
+	cacheTTLJitterFlag      = "cache-ttl-jitter"
+	cacheNegativeTTLFlag    = "cache-negative-ttl"
+	cacheMaxEntriesFlag     = "cache-max-entries"
+	cacheMaxBytesFlag       = "cache-max-bytes"
+	enabledNearCacheFlag    = "enable-near-cache"
+	nearCacheTTLFlag        = "near-cache-ttl"
+	nearCacheMaxEntriesFlag = "near-cache-max-entries"
+	cacheEarlyRefreshFlag   = "cache-early-refresh"
+	storageTypeFlag         = "storage-type"
+	dbHostRawFlag           = "database-host"
+	dbUserFlag              = "database-user"
+	dbPassFlag              = "database-pass"
+	dbNameFlag              = "database-name"
+	dbPathFlag              = "database-path"
+	dbCharsetFlag           = "database-charset"
+	dbCollationFlag         = "database-collation"
+	enabledSearchFlag       = "enable-search"
+	searchIndexPathFlag     = "search-index-path"
+	enabledSoftDeleteFlag   = "enable-soft-delete"
+	softDeleteRetentionFlag = "soft-delete-retention"
+	enabledEventsFlag       = "enable-events"
+	eventLogPathFlag        = "event-log-path"
+	enabledWebhooksFlag     = "enable-webhooks"
+	webhookMaxAttemptsFlag  = "webhook-max-attempts"
+	enabledOutboxFlag       = "enable-outbox"
+	outboxIntervalFlag      = "outbox-interval"
+	enabledAuditFlag        = "enable-audit"
+	enabledVersionsFlag     = "enable-versions"
+	versionRetentionFlag    = "version-retention"
+	versionCompactionFlag   = "version-compaction-interval"
 )
 
 type serverParams struct {
@@ -44,7 +81,7 @@ type serverParams struct {
 	// enableCache is a flag which represents if cache mechanism is enabled
 	enableCache string
 
-	// cacheType is a cache type [MEMCACHE]
+	// cacheType is a cache type [MEMCACHE, REDIS, INMEMORY]
 	cacheType types.CacheType
 
 	// cacheTypeRaw is a raw cache type
@@ -56,7 +93,79 @@ type serverParams struct {
 	// memcacheAddressRaw is a raw address of memcache server
 	memcacheAddressRaw string
 
-	// storageType is a cache type [PEBBLE, POSTGRESQL]
+	// redisAddress is an address of redis server
+	redisAddress string
+
+	// redisSentinels are addresses of redis sentinels, the redis address is not used when they are set
+	redisSentinels []string
+
+	// redisSentinelsRaw is a raw comma separated list of redis sentinel addresses
+	redisSentinelsRaw string
+
+	// redisMasterName is a name of the redis master monitored by the sentinels
+	redisMasterName string
+
+	// redisPassword is a password for redis server
+	redisPassword string
+
+	// redisTLS is a flag which represents if redis connections use TLS
+	redisTLS string
+
+	// redisKeyPrefix is a prefix of all keys stored in redis
+	redisKeyPrefix string
+
+	// cacheTTL is how long cached users are kept, zero keeps them until evicted
+	cacheTTL time.Duration
+
+	// cacheTTLRaw is a raw cache time to live
+	cacheTTLRaw string
+
+	// cacheTTLJitter is the upper bound of a random duration added to the cache time to live
+	cacheTTLJitter time.Duration
+
+	// cacheTTLJitterRaw is a raw cache time to live jitter
+	cacheTTLJitterRaw string
+
+	// cacheNegativeTTL is how long users which do not exist are remembered by the cache, zero disables negative caching
+	cacheNegativeTTL time.Duration
+
+	// cacheNegativeTTLRaw is a raw cache negative time to live
+	cacheNegativeTTLRaw string
+
+	// cacheMaxEntries is the maximum number of users kept by the in-memory cache
+	cacheMaxEntries int
+
+	// cacheMaxEntriesRaw is a raw maximum number of cached users
+	cacheMaxEntriesRaw string
+
+	// cacheMaxBytes is the maximum size in bytes of users kept by the in-memory cache
+	cacheMaxBytes int64
+
+	// cacheMaxBytesRaw is a raw maximum size of cached users
+	cacheMaxBytesRaw string
+
+	// enableNearCache is a flag which represents if a local cache is kept in front of the MEMCACHE or REDIS cache
+	enableNearCache string
+
+	// nearCacheTTL is how long users are kept in the local near cache
+	nearCacheTTL time.Duration
+
+	// nearCacheTTLRaw is a raw near cache time to live
+	nearCacheTTLRaw string
+
+	// nearCacheMaxEntries is the maximum number of users kept in the local near cache
+	nearCacheMaxEntries int
+
+	// nearCacheMaxEntriesRaw is a raw maximum number of users in the near cache
+	nearCacheMaxEntriesRaw string
+
+	// cacheEarlyRefresh is the probability of a user served from cache being refreshed in the background
+	cacheEarlyRefresh float64
+
+	// cacheEarlyRefreshRaw is a raw cache early refresh probability
+	cacheEarlyRefreshRaw string
+
+	// storageType is a storage type [PEBBLE, POSTGRESQL, MEMORY, SQLITE, MYSQL, BOLT]
 	storageType types.StorageType
 
 	// storageTypeRaw is a raw storage type
@@ -76,6 +185,72 @@ type serverParams struct {
 
 	// dbName is a name of database
 	dbName string
+
+	// dbPath is a path of the SQLite or bolt database file
+	dbPath string
+
+	// dbCharset is a character set of the MySQL database
+	dbCharset string
+
+	// dbCollation is a collation of the MySQL database
+	dbCollation string
+
+	// enableSearch is a flag which represents if full-text search is enabled
+	enableSearch string
+
+	// searchIndexPath is a path of the search index snapshot
+	searchIndexPath string
+
+	// enableSoftDelete is a flag which represents if deleted users are kept until purged
+	enableSoftDelete string
+
+	// softDeleteRetention is how long soft deleted users are kept before they are purged
+	softDeleteRetention time.Duration
+
+	// softDeleteRetentionRaw is a raw soft delete retention
+	softDeleteRetentionRaw string
+
+	// enableEvents is a flag which represents if user changes are published as events
+	enableEvents string
+
+	// eventLogPath is a path of the persisted change log of events
+	eventLogPath string
+
+	// enableWebhooks is a flag which represents if events are delivered to registered webhooks
+	enableWebhooks string
+
+	// webhookMaxAttempts is the number of failed attempts after which a webhook delivery is dead
+	webhookMaxAttempts int
+
+	// webhookMaxAttemptsRaw is a raw number of webhook delivery attempts
+	webhookMaxAttemptsRaw string
+
+	// enableOutbox is a flag which represents if created users are recorded in an outbox relayed to the event stream
+	enableOutbox string
+
+	// outboxInterval is the time between two relays of an empty outbox
+	outboxInterval time.Duration
+
+	// outboxIntervalRaw is a raw outbox relay interval
+	outboxIntervalRaw string
+
+	// enableAudit is a flag which represents if writes are recorded in the audit log
+	enableAudit string
+
+	// enableVersions is a flag which represents if every version of a user is kept for point-in-time reads
+	enableVersions string
+
+	// versionRetention is how long replaced user versions are kept before they are compacted
+	versionRetention time.Duration
+
+	// versionRetentionRaw is a raw version retention
+	versionRetentionRaw string
+
+	// versionCompaction is the time between two compactions of user versions
+	versionCompaction time.Duration
+
+	// versionCompactionRaw is a raw version compaction interval
+	versionCompactionRaw string
 }
 
 func (p *serverParams) initRawParams() error {
@@ -109,6 +284,67 @@ func (p *serverParams) initRawParams() error {
 		return err
 	}
 
+	// Parse redis sentinel addresses
+	p.redisSentinels = nil
+
+	for _, address := range strings.Split(p.redisSentinelsRaw, ",") {
+		if address = strings.TrimSpace(address); address != "" {
+			p.redisSentinels = append(p.redisSentinels, address)
+		}
+	}
+
+	// Parse cache time to live
+	p.cacheTTL, err = time.ParseDuration(p.cacheTTLRaw)
+	if err != nil {
+		return err
+	}
+
+	// Parse cache time to live jitter
+	p.cacheTTLJitter, err = time.ParseDuration(p.cacheTTLJitterRaw)
+	if err != nil {
+		return err
+	}
+
+	// Parse cache negative time to live
+	p.cacheNegativeTTL, err = time.ParseDuration(p.cacheNegativeTTLRaw)
+	if err != nil {
+		return err
+	}
+
+	// Parse cache max entries
+	p.cacheMaxEntries, err = strconv.Atoi(p.cacheMaxEntriesRaw)
+	if err != nil {
+		return err
+	}
+
+	// Parse cache max bytes
+	p.cacheMaxBytes, err = strconv.ParseInt(p.cacheMaxBytesRaw, 10, 64)
+	if err != nil {
+		return err
+	}
+
+	// Parse near cache time to live
+	p.nearCacheTTL, err = time.ParseDuration(p.nearCacheTTLRaw)
+	if err != nil {
+		return err
+	}
+
+	// Parse near cache max entries
+	p.nearCacheMaxEntries, err = strconv.Atoi(p.nearCacheMaxEntriesRaw)
+	if err != nil {
+		return err
+	}
+
+	// Parse cache early refresh probability
+	p.cacheEarlyRefresh, err = strconv.ParseFloat(p.cacheEarlyRefreshRaw, 64)
+	if err != nil {
+		return err
+	}
+
+	if p.cacheEarlyRefresh < 0 || p.cacheEarlyRefresh > 1 {
+		return errInvalidEarlyRefresh
+	}
+
 	// Parse storage type
 	p.storageType, err = types.ConvertStringToStorageType(p.storageTypeRaw)
 	if err != nil {
@@ -123,6 +359,36 @@ func (p *serverParams) initRawParams() error {
 		return err
 	}
 
+	// Parse soft delete retention
+	p.softDeleteRetention, err = time.ParseDuration(p.softDeleteRetentionRaw)
+	if err != nil {
+		return err
+	}
+
+	// Parse webhook max attempts
+	p.webhookMaxAttempts, err = strconv.Atoi(p.webhookMaxAttemptsRaw)
+	if err != nil {
+		return err
+	}
+
+	// Parse outbox interval
+	p.outboxInterval, err = time.ParseDuration(p.outboxIntervalRaw)
+	if err != nil {
+		return err
+	}
+
+	// Parse version retention
+	p.versionRetention, err = time.ParseDuration(p.versionRetentionRaw)
+	if err != nil {
+		return err
+	}
+
+	// Parse version compaction interval
+	p.versionCompaction, err = time.ParseDuration(p.versionCompactionRaw)
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -132,16 +398,93 @@ func (p *serverParams) generateConfig() *server.Config {
 		log.Fatal(err)
 	}
 
+	enableNearCache, err := strconv.ParseBool(p.enableNearCache)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	redisTLS, err := strconv.ParseBool(p.redisTLS)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	enableSearch, err := strconv.ParseBool(p.enableSearch)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	enableSoftDelete, err := strconv.ParseBool(p.enableSoftDelete)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	enableEvents, err := strconv.ParseBool(p.enableEvents)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	enableWebhooks, err := strconv.ParseBool(p.enableWebhooks)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	enableOutbox, err := strconv.ParseBool(p.enableOutbox)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	enableAudit, err := strconv.ParseBool(p.enableAudit)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	enableVersions, err := strconv.ParseBool(p.enableVersions)
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	return &server.Config{
-		LogLevel:        p.logLevel,
-		ServerAddress:   p.serverAddress,
-		EnableCache:     enableCache,
-		CacheType:       p.cacheType,
-		MemcacheAddress: p.memcacheAddress,
-		StorageType:     p.storageType,
-		DBHost:          p.dbHost,
-		DBUser:          p.dbUser,
-		DBPass:          p.dbPass,
-		DBName:          p.dbName,
+		LogLevel:            p.logLevel,
+		ServerAddress:       p.serverAddress,
+		EnableCache:         enableCache,
+		CacheType:           p.cacheType,
+		MemcacheAddress:     p.memcacheAddress,
+		RedisAddress:        p.redisAddress,
+		RedisSentinels:      p.redisSentinels,
+		RedisMasterName:     p.redisMasterName,
+		RedisPassword:       p.redisPassword,
+		RedisTLS:            redisTLS,
+		RedisKeyPrefix:      p.redisKeyPrefix,
+		CacheTTL:            p.cacheTTL,
+		CacheTTLJitter:      p.cacheTTLJitter,
+		CacheNegativeTTL:    p.cacheNegativeTTL,
+		CacheMaxEntries:     p.cacheMaxEntries,
+		CacheMaxBytes:       p.cacheMaxBytes,
+		EnableNearCache:     enableNearCache,
+		NearCacheTTL:        p.nearCacheTTL,
+		NearCacheMaxEntries: p.nearCacheMaxEntries,
+		CacheEarlyRefresh:   p.cacheEarlyRefresh,
+		StorageType:         p.storageType,
+		DBHost:              p.dbHost,
+		DBUser:              p.dbUser,
+		DBPass:              p.dbPass,
+		DBName:              p.dbName,
+		DBPath:              p.dbPath,
+		DBCharset:           p.dbCharset,
+		DBCollation:         p.dbCollation,
+		EnableSearch:        enableSearch,
+		SearchIndexPath:     p.searchIndexPath,
+		EnableSoftDelete:    enableSoftDelete,
+		SoftDeleteRetention: p.softDeleteRetention,
+		EnableEvents:        enableEvents,
+		EventLogPath:        p.eventLogPath,
+		EnableWebhooks:      enableWebhooks,
+		WebhookMaxAttempts:  p.webhookMaxAttempts,
+		EnableOutbox:        enableOutbox,
+		OutboxInterval:      p.outboxInterval,
+		EnableAudit:         enableAudit,
+		EnableVersions:      enableVersions,
+		VersionRetention:    p.versionRetention,
+		VersionCompaction:   p.versionCompaction,
 	}
 }
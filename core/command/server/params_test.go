@@ -3,6 +3,7 @@ package server
 import (
 	"net"
 	"testing"
+	"time"
 
 	"github.com/Aleksao998/LightningUserVault/core/command/server/types"
 	"github.com/stretchr/testify/assert"
@@ -13,12 +14,26 @@ func TestInitRawParams(t *testing.T) {
 	t.Parallel()
 
 	sp := &serverParams{
-		logLevelRaw:        "DEBUG",
-		serverAddressRaw:   "localhost:8080",
-		cacheTypeRaw:       "MEMCACHE",
-		memcacheAddressRaw: "localhost:11211",
-		storageTypeRaw:     "PEBBLE",
-		dbHostRaw:          "localhost:5432",
+		logLevelRaw:            "DEBUG",
+		serverAddressRaw:       "localhost:8080",
+		cacheTypeRaw:           "MEMCACHE",
+		memcacheAddressRaw:     "localhost:11211",
+		redisSentinelsRaw:      "sentinel-1:26379, sentinel-2:26379,",
+		cacheTTLRaw:            "5m",
+		cacheTTLJitterRaw:      "30s",
+		cacheNegativeTTLRaw:    "10s",
+		cacheMaxEntriesRaw:     "1000",
+		cacheMaxBytesRaw:       "1048576",
+		nearCacheTTLRaw:        "2s",
+		nearCacheMaxEntriesRaw: "500",
+		cacheEarlyRefreshRaw:   "0.01",
+		storageTypeRaw:         "PEBBLE",
+		dbHostRaw:              "localhost:5432",
+		softDeleteRetentionRaw: "48h",
+		webhookMaxAttemptsRaw:  "5",
+		outboxIntervalRaw:      "500ms",
+		versionRetentionRaw:    "24h",
+		versionCompactionRaw:   "10m",
 	}
 
 	err := sp.initRawParams()
@@ -26,21 +41,90 @@ func TestInitRawParams(t *testing.T) {
 	assert.Equal(t, zapcore.DebugLevel, sp.logLevel)
 	assert.NotNil(t, sp.serverAddress)
 	assert.NotNil(t, sp.memcacheAddress)
+	assert.Equal(t, []string{"sentinel-1:26379", "sentinel-2:26379"}, sp.redisSentinels)
+	assert.Equal(t, 5*time.Minute, sp.cacheTTL)
+	assert.Equal(t, 30*time.Second, sp.cacheTTLJitter)
+	assert.Equal(t, 10*time.Second, sp.cacheNegativeTTL)
+	assert.Equal(t, 1000, sp.cacheMaxEntries)
+	assert.Equal(t, int64(1048576), sp.cacheMaxBytes)
+	assert.Equal(t, 2*time.Second, sp.nearCacheTTL)
+	assert.Equal(t, 500, sp.nearCacheMaxEntries)
+	assert.Equal(t, 0.01, sp.cacheEarlyRefresh)
 	assert.NotNil(t, sp.dbHost)
+	assert.Equal(t, 48*time.Hour, sp.softDeleteRetention)
+	assert.Equal(t, 5, sp.webhookMaxAttempts)
+	assert.Equal(t, 500*time.Millisecond, sp.outboxInterval)
+	assert.Equal(t, 24*time.Hour, sp.versionRetention)
+	assert.Equal(t, 10*time.Minute, sp.versionCompaction)
+}
+
+func TestInitRawParams_InvalidEarlyRefresh(t *testing.T) {
+	t.Parallel()
+
+	for _, probability := range []string{"-0.1", "1.5"} {
+		sp := &serverParams{
+			logLevelRaw:            "DEBUG",
+			serverAddressRaw:       "localhost:8080",
+			cacheTypeRaw:           "MEMCACHE",
+			memcacheAddressRaw:     "localhost:11211",
+			cacheTTLRaw:            "0",
+			cacheTTLJitterRaw:      "0",
+			cacheNegativeTTLRaw:    "0",
+			cacheMaxEntriesRaw:     "0",
+			cacheMaxBytesRaw:       "0",
+			nearCacheTTLRaw:        "1s",
+			nearCacheMaxEntriesRaw: "0",
+			cacheEarlyRefreshRaw:   probability,
+		}
+
+		assert.ErrorIs(t, sp.initRawParams(), errInvalidEarlyRefresh)
+	}
 }
 
 func TestGenerateConfig(t *testing.T) {
 	sp := &serverParams{
-		logLevel:        zapcore.DebugLevel,
-		serverAddress:   &net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 8080},
-		enableCache:     "true",
-		cacheType:       types.MEMCACHE,
-		memcacheAddress: &net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 11211},
-		storageType:     types.PEBBLE,
-		dbHost:          &net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 5432},
-		dbUser:          "user",
-		dbPass:          "pass",
-		dbName:          "testdb",
+		logLevel:            zapcore.DebugLevel,
+		serverAddress:       &net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 8080},
+		enableCache:         "true",
+		cacheType:           types.MEMCACHE,
+		memcacheAddress:     &net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 11211},
+		redisAddress:        "localhost:6379",
+		redisSentinels:      []string{"localhost:26379"},
+		redisMasterName:     "mymaster",
+		redisPassword:       "secret",
+		redisTLS:            "true",
+		redisKeyPrefix:      "vault:",
+		cacheTTL:            time.Minute,
+		cacheTTLJitter:      10 * time.Second,
+		cacheNegativeTTL:    5 * time.Second,
+		cacheMaxEntries:     1000,
+		cacheMaxBytes:       1 << 20,
+		enableNearCache:     "true",
+		nearCacheTTL:        time.Second,
+		nearCacheMaxEntries: 500,
+		cacheEarlyRefresh:   0.05,
+		storageType:         types.PEBBLE,
+		dbHost:              &net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 5432},
+		dbUser:              "user",
+		dbPass:              "pass",
+		dbName:              "testdb",
+		dbPath:              "vault.db",
+		dbCharset:           "utf8mb4",
+		dbCollation:         "utf8mb4_bin",
+		enableSearch:        "true",
+		searchIndexPath:     "search-index",
+		enableSoftDelete:    "true",
+		softDeleteRetention: time.Hour,
+		enableEvents:        "true",
+		eventLogPath:        "event-log",
+		enableWebhooks:      "true",
+		webhookMaxAttempts:  5,
+		enableOutbox:        "true",
+		outboxInterval:      time.Second,
+		enableAudit:         "true",
+		enableVersions:      "true",
+		versionRetention:    time.Hour,
+		versionCompaction:   time.Minute,
 	}
 
 	config := sp.generateConfig()
@@ -48,9 +132,41 @@ func TestGenerateConfig(t *testing.T) {
 	assert.Equal(t, sp.serverAddress, config.ServerAddress)
 	assert.Equal(t, sp.cacheType, config.CacheType)
 	assert.Equal(t, sp.memcacheAddress, config.MemcacheAddress)
+	assert.Equal(t, sp.redisAddress, config.RedisAddress)
+	assert.Equal(t, sp.redisSentinels, config.RedisSentinels)
+	assert.Equal(t, sp.redisMasterName, config.RedisMasterName)
+	assert.Equal(t, sp.redisPassword, config.RedisPassword)
+	assert.True(t, config.RedisTLS)
+	assert.Equal(t, sp.redisKeyPrefix, config.RedisKeyPrefix)
+	assert.Equal(t, time.Minute, config.CacheTTL)
+	assert.Equal(t, 10*time.Second, config.CacheTTLJitter)
+	assert.Equal(t, 5*time.Second, config.CacheNegativeTTL)
+	assert.Equal(t, 1000, config.CacheMaxEntries)
+	assert.Equal(t, int64(1<<20), config.CacheMaxBytes)
+	assert.True(t, config.EnableNearCache)
+	assert.Equal(t, time.Second, config.NearCacheTTL)
+	assert.Equal(t, 500, config.NearCacheMaxEntries)
+	assert.Equal(t, 0.05, config.CacheEarlyRefresh)
 	assert.Equal(t, sp.storageType, config.StorageType)
 	assert.Equal(t, sp.dbHost, config.DBHost)
 	assert.Equal(t, sp.dbUser, config.DBUser)
 	assert.Equal(t, sp.dbPass, config.DBPass)
 	assert.Equal(t, sp.dbName, config.DBName)
+	assert.Equal(t, sp.dbPath, config.DBPath)
+	assert.Equal(t, sp.dbCharset, config.DBCharset)
+	assert.Equal(t, sp.dbCollation, config.DBCollation)
+	assert.True(t, config.EnableSearch)
+	assert.Equal(t, sp.searchIndexPath, config.SearchIndexPath)
+	assert.True(t, config.EnableSoftDelete)
+	assert.Equal(t, time.Hour, config.SoftDeleteRetention)
+	assert.True(t, config.EnableEvents)
+	assert.Equal(t, sp.eventLogPath, config.EventLogPath)
+	assert.True(t, config.EnableWebhooks)
+	assert.Equal(t, 5, config.WebhookMaxAttempts)
+	assert.True(t, config.EnableOutbox)
+	assert.Equal(t, time.Second, config.OutboxInterval)
+	assert.True(t, config.EnableAudit)
+	assert.True(t, config.EnableVersions)
+	assert.Equal(t, time.Hour, config.VersionRetention)
+	assert.Equal(t, time.Minute, config.VersionCompaction)
 }
@@ -2,7 +2,6 @@ package server
 
 import (
 	"fmt"
-	"os"
 
 	"github.com/Aleksao998/LightningUserVault/core/command/helper"
 	"github.com/Aleksao998/LightningUserVault/core/server"
@@ -26,72 +25,296 @@ func setFlags(cmd *cobra.Command) {
 	cmd.Flags().StringVar(
 		&params.logLevelRaw,
 		logLevelFlag,
-		getEnvWithDefault("LOG_LEVEL", "WARN"),
+		helper.GetEnvWithDefault("LOG_LEVEL", "WARN"),
 		"the log level for console output",
 	)
 
 	cmd.Flags().StringVar(
 		&params.serverAddressRaw,
 		serverAddressFlag,
-		getEnvWithDefault("SERVER_ADDRESS", fmt.Sprintf("%s:%s", helper.DefaultServerEndpoint, helper.DefaultServerPort)),
+		helper.GetEnvWithDefault("SERVER_ADDRESS", fmt.Sprintf("%s:%s", helper.DefaultServerEndpoint, helper.DefaultServerPort)),
 		"server endpoint",
 	)
 
 	cmd.Flags().StringVar(
 		&params.enableCache,
 		enabledCacheFlag,
-		getEnvWithDefault("ENABLE_CACHE", "true"),
+		helper.GetEnvWithDefault("ENABLE_CACHE", "true"),
 		"flag which represents if cache mechanism is enabled",
 	)
 
 	cmd.Flags().StringVar(
 		&params.cacheTypeRaw,
 		cacheTypeFlag,
-		getEnvWithDefault("CACHE_TYPE", "MEMCACHE"),
-		"the type of cache, supported [MEMCACHE]",
+		helper.GetEnvWithDefault("CACHE_TYPE", "MEMCACHE"),
+		"the type of cache, supported [MEMCACHE, REDIS, INMEMORY]",
 	)
 
 	cmd.Flags().StringVar(
 		&params.memcacheAddressRaw,
 		memcacheAddressFlag,
-		getEnvWithDefault("MEMCACHE_ADDRESS", fmt.Sprintf("%s:%s", helper.DefaultServerEndpoint, helper.DefaultMemcachePort)),
+		helper.GetEnvWithDefault("MEMCACHE_ADDRESS", fmt.Sprintf("%s:%s", helper.DefaultServerEndpoint, helper.DefaultMemcachePort)),
 		"memcache endpoint",
 	)
 
+	cmd.Flags().StringVar(
+		&params.redisAddress,
+		redisAddressFlag,
+		helper.GetEnvWithDefault("REDIS_ADDRESS", fmt.Sprintf("%s:%s", helper.DefaultServerEndpoint, helper.DefaultRedisPort)),
+		"redis endpoint",
+	)
+
+	cmd.Flags().StringVar(
+		&params.redisSentinelsRaw,
+		redisSentinelsFlag,
+		helper.GetEnvWithDefault("REDIS_SENTINEL_ADDRESSES", ""),
+		"comma separated redis sentinel endpoints, the redis endpoint is not used when they are set",
+	)
+
+	cmd.Flags().StringVar(
+		&params.redisMasterName,
+		redisMasterNameFlag,
+		helper.GetEnvWithDefault("REDIS_MASTER_NAME", "mymaster"),
+		"name of the redis master monitored by the sentinels",
+	)
+
+	cmd.Flags().StringVar(
+		&params.redisPassword,
+		redisPasswordFlag,
+		helper.GetEnvWithDefault("REDIS_PASSWORD", ""),
+		"redis password",
+	)
+
+	cmd.Flags().StringVar(
+		&params.redisTLS,
+		redisTLSFlag,
+		helper.GetEnvWithDefault("REDIS_TLS", "false"),
+		"flag which represents if redis connections use TLS",
+	)
+
+	cmd.Flags().StringVar(
+		&params.redisKeyPrefix,
+		redisKeyPrefixFlag,
+		helper.GetEnvWithDefault("REDIS_KEY_PREFIX", ""),
+		"prefix of all keys stored in redis",
+	)
+
+	cmd.Flags().StringVar(
+		&params.cacheTTLRaw,
+		cacheTTLFlag,
+		helper.GetEnvWithDefault("CACHE_TTL", helper.DefaultCacheTTL),
+		"how long cached users are kept, 0 keeps them until evicted",
+	)
+
+	cmd.Flags().StringVar(
+		&params.cacheTTLJitterRaw,
+		cacheTTLJitterFlag,
+		helper.GetEnvWithDefault("CACHE_TTL_JITTER", helper.DefaultCacheTTLJitter),
+		"upper bound of a random duration added to the cache ttl, so users cached together do not expire together",
+	)
+
+	cmd.Flags().StringVar(
+		&params.cacheNegativeTTLRaw,
+		cacheNegativeTTLFlag,
+		helper.GetEnvWithDefault("CACHE_NEGATIVE_TTL", helper.DefaultCacheNegativeTTL),
+		"how long users which do not exist are remembered by the cache, 0 disables negative caching",
+	)
+
+	cmd.Flags().StringVar(
+		&params.cacheMaxEntriesRaw,
+		cacheMaxEntriesFlag,
+		helper.GetEnvWithDefault("CACHE_MAX_ENTRIES", helper.DefaultCacheMaxEntries),
+		"maximum number of users kept by the INMEMORY cache, 0 is unbounded",
+	)
+
+	cmd.Flags().StringVar(
+		&params.cacheMaxBytesRaw,
+		cacheMaxBytesFlag,
+		helper.GetEnvWithDefault("CACHE_MAX_BYTES", helper.DefaultCacheMaxBytes),
+		"maximum size in bytes of users kept by the INMEMORY cache, 0 is unbounded",
+	)
+
+	cmd.Flags().StringVar(
+		&params.enableNearCache,
+		enabledNearCacheFlag,
+		helper.GetEnvWithDefault("ENABLE_NEAR_CACHE", "false"),
+		"flag which represents if a local cache is kept in front of the MEMCACHE or REDIS cache",
+	)
+
+	cmd.Flags().StringVar(
+		&params.nearCacheTTLRaw,
+		nearCacheTTLFlag,
+		helper.GetEnvWithDefault("NEAR_CACHE_TTL", helper.DefaultNearCacheTTL),
+		"how long users are kept in the near cache, which bounds how stale they may be",
+	)
+
+	cmd.Flags().StringVar(
+		&params.nearCacheMaxEntriesRaw,
+		nearCacheMaxEntriesFlag,
+		helper.GetEnvWithDefault("NEAR_CACHE_MAX_ENTRIES", helper.DefaultNearCacheMaxEntries),
+		"maximum number of users kept in the near cache, 0 is unbounded",
+	)
+
+	cmd.Flags().StringVar(
+		&params.cacheEarlyRefreshRaw,
+		cacheEarlyRefreshFlag,
+		helper.GetEnvWithDefault("CACHE_EARLY_REFRESH", helper.DefaultCacheEarlyRefresh),
+		"probability (0-1) of a user served from cache being refreshed in the background ahead of its expiry, 0 disables it",
+	)
+
 	cmd.Flags().StringVar(
 		&params.storageTypeRaw,
 		storageTypeFlag,
-		getEnvWithDefault("STORAGE_TYPE", "PEBBLE"),
-		"the type of storage, supported [PEBBLE, POSTRESQL]",
+		helper.GetEnvWithDefault("STORAGE_TYPE", "PEBBLE"),
+		"the type of storage, supported [PEBBLE, POSTGRESQL, MEMORY, SQLITE, MYSQL, BOLT]",
 	)
 
 	cmd.Flags().StringVar(
 		&params.dbHostRaw,
 		dbHostRawFlag,
-		getEnvWithDefault("DB_HOST", fmt.Sprintf("%s:%s", helper.DefaultServerEndpoint, helper.DefaultDatabasePort)),
+		helper.GetEnvWithDefault("DB_HOST", fmt.Sprintf("%s:%s", helper.DefaultServerEndpoint, helper.DefaultDatabasePort)),
 		"database host endpoint",
 	)
 
 	cmd.Flags().StringVar(
 		&params.dbUser,
 		dbUserFlag,
-		getEnvWithDefault("DB_USER", "postgres"),
+		helper.GetEnvWithDefault("DB_USER", "postgres"),
 		"database user",
 	)
 
 	cmd.Flags().StringVar(
 		&params.dbPass,
 		dbPassFlag,
-		getEnvWithDefault("DB_PASS", "postgres"),
+		helper.GetEnvWithDefault("DB_PASS", "postgres"),
 		"database password",
 	)
 
 	cmd.Flags().StringVar(
 		&params.dbName,
 		dbNameFlag,
-		getEnvWithDefault("DB_NAME", "postgres"),
+		helper.GetEnvWithDefault("DB_NAME", "postgres"),
 		"database name",
 	)
+
+	cmd.Flags().StringVar(
+		&params.dbPath,
+		dbPathFlag,
+		helper.GetEnvWithDefault("DB_PATH", helper.DefaultDatabasePath),
+		"path of the SQLite or bolt database file",
+	)
+
+	cmd.Flags().StringVar(
+		&params.dbCharset,
+		dbCharsetFlag,
+		helper.GetEnvWithDefault("DB_CHARSET", helper.DefaultDatabaseCharset),
+		"character set of the MySQL database",
+	)
+
+	cmd.Flags().StringVar(
+		&params.dbCollation,
+		dbCollationFlag,
+		helper.GetEnvWithDefault("DB_COLLATION", helper.DefaultDatabaseCollation),
+		"collation of the MySQL database, it decides how user names are compared",
+	)
+
+	cmd.Flags().StringVar(
+		&params.enableSearch,
+		enabledSearchFlag,
+		helper.GetEnvWithDefault("ENABLE_SEARCH", "false"),
+		"flag which represents if full-text search is enabled",
+	)
+
+	cmd.Flags().StringVar(
+		&params.searchIndexPath,
+		searchIndexPathFlag,
+		helper.GetEnvWithDefault("SEARCH_INDEX_PATH", helper.DefaultSearchIndexPath),
+		"path of the full-text search index snapshot",
+	)
+
+	cmd.Flags().StringVar(
+		&params.enableSoftDelete,
+		enabledSoftDeleteFlag,
+		helper.GetEnvWithDefault("ENABLE_SOFT_DELETE", "false"),
+		"flag which represents if deleted users are kept and can be restored until purged",
+	)
+
+	cmd.Flags().StringVar(
+		&params.softDeleteRetentionRaw,
+		softDeleteRetentionFlag,
+		helper.GetEnvWithDefault("SOFT_DELETE_RETENTION", helper.DefaultSoftDeleteRetention),
+		"how long soft deleted users are kept before they are purged",
+	)
+
+	cmd.Flags().StringVar(
+		&params.enableEvents,
+		enabledEventsFlag,
+		helper.GetEnvWithDefault("ENABLE_EVENTS", "false"),
+		"flag which represents if user changes are streamed as events",
+	)
+
+	cmd.Flags().StringVar(
+		&params.eventLogPath,
+		eventLogPathFlag,
+		helper.GetEnvWithDefault("EVENT_LOG_PATH", helper.DefaultEventLogPath),
+		"path of the persisted change log of events",
+	)
+
+	cmd.Flags().StringVar(
+		&params.enableWebhooks,
+		enabledWebhooksFlag,
+		helper.GetEnvWithDefault("ENABLE_WEBHOOKS", "false"),
+		"flag which represents if events are delivered to registered webhooks, it requires events",
+	)
+
+	cmd.Flags().StringVar(
+		&params.webhookMaxAttemptsRaw,
+		webhookMaxAttemptsFlag,
+		helper.GetEnvWithDefault("WEBHOOK_MAX_ATTEMPTS", helper.DefaultWebhookMaxAttempts),
+		"number of failed attempts after which a webhook delivery is moved to the dead-letter list",
+	)
+
+	cmd.Flags().StringVar(
+		&params.enableOutbox,
+		enabledOutboxFlag,
+		helper.GetEnvWithDefault("ENABLE_OUTBOX", "false"),
+		"flag which represents if created users are recorded in a transactional outbox relayed to the event stream, it requires POSTGRESQL or MYSQL storage",
+	)
+
+	cmd.Flags().StringVar(
+		&params.outboxIntervalRaw,
+		outboxIntervalFlag,
+		helper.GetEnvWithDefault("OUTBOX_INTERVAL", helper.DefaultOutboxInterval),
+		"time between two relays of an empty outbox",
+	)
+
+	cmd.Flags().StringVar(
+		&params.enableAudit,
+		enabledAuditFlag,
+		helper.GetEnvWithDefault("ENABLE_AUDIT", "false"),
+		"flag which represents if every write is recorded in the audit log",
+	)
+
+	cmd.Flags().StringVar(
+		&params.enableVersions,
+		enabledVersionsFlag,
+		helper.GetEnvWithDefault("ENABLE_VERSIONS", "false"),
+		"flag which represents if every version of a user is kept for point-in-time reads",
+	)
+
+	cmd.Flags().StringVar(
+		&params.versionRetentionRaw,
+		versionRetentionFlag,
+		helper.GetEnvWithDefault("VERSION_RETENTION", helper.DefaultVersionRetention),
+		"how long replaced user versions are kept before they are compacted",
+	)
+
+	cmd.Flags().StringVar(
+		&params.versionCompactionRaw,
+		versionCompactionFlag,
+		helper.GetEnvWithDefault("VERSION_COMPACTION_INTERVAL", helper.DefaultVersionCompaction),
+		"time between two compactions of replaced user versions",
+	)
 }
 
 func runCommand(cmd *cobra.Command, _ []string) {
@@ -114,13 +337,3 @@ func runServerLoop() error {
 
 	return helper.HandleSignals(serverInstance.Close)
 }
-
-func getEnvWithDefault(key, defaultValue string) string {
-	if value, exists := os.LookupEnv(key); exists {
-		fmt.Println(fmt.Sprintf("Exists %s", value))
-
-		return value
-	}
-
-	return defaultValue
-}
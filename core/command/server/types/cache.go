@@ -10,6 +10,8 @@ type CacheType string
 
 const (
 	MEMCACHE CacheType = "MEMCACHE"
+	REDIS    CacheType = "REDIS"
+	INMEMORY CacheType = "INMEMORY"
 )
 
 // ConvertStringToCacheType converts a string to its corresponding CacheType
@@ -17,6 +19,10 @@ func ConvertStringToCacheType(s string) (CacheType, error) {
 	switch strings.ToUpper(s) {
 	case string(MEMCACHE):
 		return MEMCACHE, nil
+	case string(REDIS):
+		return REDIS, nil
+	case string(INMEMORY):
+		return INMEMORY, nil
 	default:
 		return "", fmt.Errorf("invalid cache type: %s", s)
 	}
@@ -17,6 +17,10 @@ func TestConvertStringToCacheType(t *testing.T) {
 		{"MEMCACHE", MEMCACHE, false},
 		{"memcache", MEMCACHE, false},
 		{"MeMcAcHe", MEMCACHE, false},
+		{"REDIS", REDIS, false},
+		{"redis", REDIS, false},
+		{"INMEMORY", INMEMORY, false},
+		{"inmemory", INMEMORY, false},
 		{"INVALID", "", true},
 		{"", "", true},
 	}
@@ -11,6 +11,10 @@ type StorageType string
 const (
 	PEBBLE     StorageType = "PEBBLE"
 	POSTGRESQL StorageType = "POSTGRESQL"
+	MEMORY     StorageType = "MEMORY"
+	SQLITE     StorageType = "SQLITE"
+	MYSQL      StorageType = "MYSQL"
+	BOLT       StorageType = "BOLT"
 )
 
 // StorageType converts a string to its corresponding StorageType
@@ -20,6 +24,14 @@ func ConvertStringToStorageType(s string) (StorageType, error) {
 		return PEBBLE, nil
 	case string(POSTGRESQL):
 		return POSTGRESQL, nil
+	case string(MEMORY):
+		return MEMORY, nil
+	case string(SQLITE):
+		return SQLITE, nil
+	case string(MYSQL):
+		return MYSQL, nil
+	case string(BOLT):
+		return BOLT, nil
 	default:
 		return "", fmt.Errorf("invalid storage type: %s", s)
 	}
@@ -20,6 +20,14 @@ func TestConvertStringToStorageType(t *testing.T) {
 		{"POSTGRESQL", POSTGRESQL, false},
 		{"postgresql", POSTGRESQL, false},
 		{"PoStGrEsQl", POSTGRESQL, false},
+		{"MEMORY", MEMORY, false},
+		{"memory", MEMORY, false},
+		{"SQLITE", SQLITE, false},
+		{"sqlite", SQLITE, false},
+		{"MYSQL", MYSQL, false},
+		{"mysql", MYSQL, false},
+		{"BOLT", BOLT, false},
+		{"bolt", BOLT, false},
 		{"INVALID", "", true},
 		{"", "", true},
 	}
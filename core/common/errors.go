@@ -0,0 +1,20 @@
+package common
+
+import "errors"
+
+var (
+	// ErrUserNotFound is returned by storages when the requested user does not exist
+	ErrUserNotFound = errors.New("user not found")
+
+	// ErrInvalidCursor is returned by storages when a list cursor can not be decoded
+	ErrInvalidCursor = errors.New("invalid cursor")
+
+	// ErrVersionMismatch is returned by storages when a conditional write expects a different user version
+	ErrVersionMismatch = errors.New("user version mismatch")
+
+	// ErrUserDeleted is returned by storages when the requested user is soft deleted
+	ErrUserDeleted = errors.New("user is deleted")
+
+	// ErrUserNotDeleted is returned by storages when restoring a user which is not soft deleted
+	ErrUserNotDeleted = errors.New("user is not deleted")
+)
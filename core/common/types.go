@@ -1,13 +1,65 @@
 package common
 
+import "time"
+
+// AnyVersion is passed to conditional storage writes which should apply to any version of the user
+const AnyVersion int64 = 0
+
 // User represents an individual user in the system.
 type User struct {
 	// ID is the unique identifier for the user
 	ID int64 `json:"id"`
 	// Name is the name of the user
 	Name string `json:"name"`
+	// Email is the optional email address of the user
+	Email string `json:"email,omitempty"`
+	// Attributes holds free-form custom data of the user
+	Attributes map[string]string `json:"attributes,omitempty" gorm:"serializer:json"`
+	// CreatedAt is the time the user was stored, it is set by the storage
+	CreatedAt time.Time `json:"created_at"`
+	// UpdatedAt is the time the user was last changed, it is set by the storage
+	UpdatedAt time.Time `json:"updated_at"`
+	// Version starts at 1 and is incremented by the storage on every update
+	Version int64 `json:"version"`
+	// DeletedAt is the time the user was soft deleted, it is only set on users awaiting purge
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+// UserList represents a single page of users
+type UserList struct {
+	// Users contains the users of the current page
+	Users []*User `json:"users"`
+	// NextCursor is an opaque token for fetching the next page, empty when there are no more users
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// VersionList represents the retained versions of a user, newest first
+type VersionList struct {
+	Versions []*User `json:"versions"`
 }
 
 type ErrorResponse struct {
 	Error string `json:"error"`
 }
+
+// UserMultiResponse represents the result of retrieving multiple users at once
+type UserMultiResponse struct {
+	// Users contains the found users in the requested order
+	Users []*User `json:"users"`
+	// Missing contains the requested IDs which do not exist
+	Missing []int64 `json:"missing"`
+}
+
+// BatchItemError describes why a single item of a batch request was rejected
+type BatchItemError struct {
+	// Index is the position of the rejected item in the request
+	Index int `json:"index"`
+	// Error is the reason the item was rejected
+	Error string `json:"error"`
+}
+
+// BatchErrorResponse is returned when one or more items of a batch request are invalid
+type BatchErrorResponse struct {
+	Error string           `json:"error"`
+	Items []BatchItemError `json:"items"`
+}
@@ -1,6 +1,9 @@
 package common
 
-import "encoding/binary"
+import (
+	"encoding/base64"
+	"encoding/binary"
+)
 
 // Int64ToBytes converts int64 to []byte using little endian format.
 func Int64ToBytes(i int64) []byte {
@@ -14,3 +17,18 @@ func Int64ToBytes(i int64) []byte {
 func BytesToInt64(data []byte) int64 {
 	return int64(binary.LittleEndian.Uint64(data))
 }
+
+// EncodeCursor converts storage specific position data into an opaque cursor token.
+func EncodeCursor(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeCursor converts an opaque cursor token back into storage specific position data.
+func DecodeCursor(cursor string) ([]byte, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	return data, nil
+}
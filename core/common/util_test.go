@@ -59,3 +59,26 @@ func TestConversionIntegrity(t *testing.T) {
 		assert.Equal(t, test, result, "For input %d", test)
 	}
 }
+
+// TestCursorIntegrity tests integrity between EncodeCursor and DecodeCursor
+func TestCursorIntegrity(t *testing.T) {
+	tests := [][]byte{
+		{},
+		Int64ToBytes(1),
+		Int64ToBytes(-1),
+		[]byte("cursor-data"),
+	}
+
+	for _, test := range tests {
+		cursor := EncodeCursor(test)
+		result, err := DecodeCursor(cursor)
+		assert.NoError(t, err)
+		assert.Equal(t, test, result, "For input %v", test)
+	}
+}
+
+// TestDecodeCursor_Invalid tests decoding cursor which was not created by EncodeCursor
+func TestDecodeCursor_Invalid(t *testing.T) {
+	_, err := DecodeCursor("not a cursor!")
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+}
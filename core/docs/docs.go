@@ -15,26 +15,1010 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
+        "/admin/audit": {
+            "get": {
+                "description": "Retrieve the recorded writes of all users after the given time, oldest first.\nPass the time of the last returned entry as since to fetch the next page",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "List audit entries",
+                "operationId": "list-audit-entries",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "RFC 3339 time after which entries were recorded, all entries are listed when empty",
+                        "name": "since",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 100,
+                        "description": "Maximum number of entries (1-1000)",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/audit.EntryList"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/common.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/common.ErrorResponse"
+                        }
+                    },
+                    "501": {
+                        "description": "Not Implemented",
+                        "schema": {
+                            "$ref": "#/definitions/common.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/deliveries": {
+            "get": {
+                "description": "Retrieve the newest deliveries of all webhooks with their status, status dead lists the dead-letter list",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "List deliveries",
+                "operationId": "list-deliveries",
+                "parameters": [
+                    {
+                        "enum": [
+                            "pending",
+                            "delivered",
+                            "dead"
+                        ],
+                        "type": "string",
+                        "description": "Delivery status",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 100,
+                        "description": "Maximum number of deliveries (1-1000)",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/webhook.DeliveryList"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/common.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/common.ErrorResponse"
+                        }
+                    },
+                    "501": {
+                        "description": "Not Implemented",
+                        "schema": {
+                            "$ref": "#/definitions/common.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/deliveries/{id}": {
+            "get": {
+                "description": "Retrieve the status of a single delivery",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Get delivery by ID",
+                "operationId": "get-delivery",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Delivery ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/webhook.Delivery"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/common.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/common.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/common.ErrorResponse"
+                        }
+                    },
+                    "501": {
+                        "description": "Not Implemented",
+                        "schema": {
+                            "$ref": "#/definitions/common.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/deliveries/{id}/retry": {
+            "post": {
+                "description": "Move a delivery from the dead-letter list back to the queue for immediate delivery",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Retry a dead delivery",
+                "operationId": "retry-delivery",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Delivery ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/webhook.Delivery"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/common.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/common.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/common.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/common.ErrorResponse"
+                        }
+                    },
+                    "501": {
+                        "description": "Not Implemented",
+                        "schema": {
+                            "$ref": "#/definitions/common.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/webhooks": {
+            "get": {
+                "description": "Retrieve all registered webhooks without their secrets",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "List webhooks",
+                "operationId": "list-webhooks",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/webhook.WebhookList"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/common.ErrorResponse"
+                        }
+                    },
+                    "501": {
+                        "description": "Not Implemented",
+                        "schema": {
+                            "$ref": "#/definitions/common.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Register an endpoint which receives user events committed from now on.\nEvery delivery is signed with HMAC-SHA256 over the X-Vault-Timestamp header, a dot and the body,\nsent as \"sha256=\u003chex\u003e\" in X-Vault-Signature. The secret is only returned in this response",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Register a webhook",
+                "operationId": "register-webhook",
+                "parameters": [
+                    {
+                        "description": "Webhook registration",
+                        "name": "webhook",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/webhook.Registration"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/webhook.Webhook"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/common.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/common.ErrorResponse"
+                        }
+                    },
+                    "501": {
+                        "description": "Not Implemented",
+                        "schema": {
+                            "$ref": "#/definitions/common.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/webhooks/{id}": {
+            "get": {
+                "description": "Retrieve a registered webhook without its secret",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Get webhook by ID",
+                "operationId": "get-webhook",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Webhook ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/webhook.Webhook"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/common.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/common.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/common.ErrorResponse"
+                        }
+                    },
+                    "501": {
+                        "description": "Not Implemented",
+                        "schema": {
+                            "$ref": "#/definitions/common.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Stop delivering events to a webhook, its pending deliveries are moved to the dead-letter list",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Remove a webhook",
+                "operationId": "remove-webhook",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Webhook ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/common.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/common.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/common.ErrorResponse"
+                        }
+                    },
+                    "501": {
+                        "description": "Not Implemented",
+                        "schema": {
+                            "$ref": "#/definitions/common.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/webhooks/{id}/deliveries": {
+            "get": {
+                "description": "Retrieve the newest deliveries of a webhook with their status",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "List deliveries of a webhook",
+                "operationId": "list-webhook-deliveries",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Webhook ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "enum": [
+                            "pending",
+                            "delivered",
+                            "dead"
+                        ],
+                        "type": "string",
+                        "description": "Delivery status",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 100,
+                        "description": "Maximum number of deliveries (1-1000)",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/webhook.DeliveryList"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/common.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/common.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/common.ErrorResponse"
+                        }
+                    },
+                    "501": {
+                        "description": "Not Implemented",
+                        "schema": {
+                            "$ref": "#/definitions/common.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
         "/user": {
+            "get": {
+                "description": "Retrieve a page of users, use the returned cursor to fetch the next page",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "List users",
+                "operationId": "list-users",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Opaque cursor returned by the previous page",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 100,
+                        "description": "Maximum number of users in the page (1-1000)",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/common.UserList"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/common.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/common.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Add a new user and return their ID",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Set a new user",
+                "operationId": "set-user",
+                "parameters": [
+                    {
+                        "description": "User object",
+                        "name": "user",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/common.User"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/common.User"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/common.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/batch": {
+            "post": {
+                "description": "Atomically add multiple users and return them with their IDs. If any user is invalid, none are stored",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Set a batch of new users",
+                "operationId": "set-user-batch",
+                "parameters": [
+                    {
+                        "description": "User objects",
+                        "name": "users",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/common.User"
+                            }
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/common.User"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/common.BatchErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/common.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/events": {
+            "get": {
+                "description": "Stream created, updated, deleted and restored user events as server-sent events.\nEvery event carries its ID, reconnecting clients send the last received one in Last-Event-ID\nto resume without missing events that are still retained in the change log",
+                "produces": [
+                    "text/event-stream"
+                ],
+                "summary": "Stream user changes",
+                "operationId": "user-events",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID of the last event received by the client",
+                        "name": "Last-Event-ID",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/events.Event"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/common.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/common.ErrorResponse"
+                        }
+                    },
+                    "501": {
+                        "description": "Not Implemented",
+                        "schema": {
+                            "$ref": "#/definitions/common.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/search": {
+            "get": {
+                "description": "Retrieve all users with the given name, or with names starting with it.\nWhen q is provided instead, run a full-text search tolerating partial and misspelled names, best matches first",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Search users",
+                "operationId": "search-users",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User name",
+                        "name": "name",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "exact",
+                            "prefix"
+                        ],
+                        "type": "string",
+                        "default": "exact",
+                        "description": "Match mode",
+                        "name": "match",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Full-text query",
+                        "name": "q",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 20,
+                        "description": "Maximum number of full-text results (1-100)",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/common.UserList"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/common.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/common.ErrorResponse"
+                        }
+                    },
+                    "501": {
+                        "description": "Not Implemented",
+                        "schema": {
+                            "$ref": "#/definitions/common.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/{id}": {
+            "get": {
+                "description": "Retrieve user details by user ID.\nWhen If-None-Match or If-Modified-Since show the client already has the current user, 304 is returned without a body.\nWhen as_of is set, the version of the user at that time is returned instead of the current one",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Get user by ID",
+                "operationId": "get-user-by-id",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "User ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "RFC 3339 time at which the user is read",
+                        "name": "as_of",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETags of the user versions held by the client",
+                        "name": "If-None-Match",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Last-Modified value of the user held by the client",
+                        "name": "If-Modified-Since",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/common.User"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Current version of the user"
+                            },
+                            "Last-Modified": {
+                                "type": "string",
+                                "description": "Time the user was last changed"
+                            }
+                        }
+                    },
+                    "304": {
+                        "description": "Not Modified",
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Current version of the user"
+                            },
+                            "Last-Modified": {
+                                "type": "string",
+                                "description": "Time the user was last changed"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/common.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/common.ErrorResponse"
+                        }
+                    },
+                    "410": {
+                        "description": "Gone",
+                        "schema": {
+                            "$ref": "#/definitions/common.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/common.ErrorResponse"
+                        }
+                    },
+                    "501": {
+                        "description": "Not Implemented",
+                        "schema": {
+                            "$ref": "#/definitions/common.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "description": "Replace the name, email and attributes of an existing user.\nWhen If-Match is provided, the update is only applied if the user is still at that version",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Update an existing user",
+                "operationId": "update-user",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "User ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag of the expected user version",
+                        "name": "If-Match",
+                        "in": "header"
+                    },
+                    {
+                        "description": "User object",
+                        "name": "user",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/common.User"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/common.User"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "New version of the user"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/common.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/common.ErrorResponse"
+                        }
+                    },
+                    "410": {
+                        "description": "Gone",
+                        "schema": {
+                            "$ref": "#/definitions/common.ErrorResponse"
+                        }
+                    },
+                    "412": {
+                        "description": "Precondition Failed",
+                        "schema": {
+                            "$ref": "#/definitions/common.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/common.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Remove an existing user by user ID. When soft delete is enabled, the user is only marked as deleted\nand can be restored until it is purged after the retention period.\nWhen If-Match is provided, the user is only removed if it is still at that version",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Delete a user",
+                "operationId": "delete-user",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "User ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag of the expected user version",
+                        "name": "If-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/common.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/common.ErrorResponse"
+                        }
+                    },
+                    "410": {
+                        "description": "Gone",
+                        "schema": {
+                            "$ref": "#/definitions/common.ErrorResponse"
+                        }
+                    },
+                    "412": {
+                        "description": "Precondition Failed",
+                        "schema": {
+                            "$ref": "#/definitions/common.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/common.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/{id}/history": {
+            "get": {
+                "description": "Retrieve the recorded writes of a user with the actor, request ID and values before and after each write, newest first",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Get user history",
+                "operationId": "get-user-history",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "User ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "default": 100,
+                        "description": "Maximum number of entries (1-1000)",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/audit.EntryList"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/common.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/common.ErrorResponse"
+                        }
+                    },
+                    "501": {
+                        "description": "Not Implemented",
+                        "schema": {
+                            "$ref": "#/definitions/common.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/{id}/restore": {
             "post": {
-                "description": "Add a new user and return their ID",
-                "consumes": [
-                    "application/json"
-                ],
+                "description": "Bring back a soft deleted user which has not been purged yet",
                 "produces": [
                     "application/json"
                 ],
-                "summary": "Set a new user",
-                "operationId": "set-user",
+                "summary": "Restore a deleted user",
+                "operationId": "restore-user",
                 "parameters": [
                     {
-                        "description": "User object",
-                        "name": "user",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/common.User"
-                        }
+                        "type": "integer",
+                        "description": "User ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
                     }
                 ],
                 "responses": {
@@ -42,6 +1026,12 @@ const docTemplate = `{
                         "description": "OK",
                         "schema": {
                             "$ref": "#/definitions/common.User"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "New version of the user"
+                            }
                         }
                     },
                     "400": {
@@ -49,18 +1039,42 @@ const docTemplate = `{
                         "schema": {
                             "$ref": "#/definitions/common.ErrorResponse"
                         }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/common.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/common.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/common.ErrorResponse"
+                        }
+                    },
+                    "501": {
+                        "description": "Not Implemented",
+                        "schema": {
+                            "$ref": "#/definitions/common.ErrorResponse"
+                        }
                     }
                 }
             }
         },
-        "/user/{id}": {
+        "/user/{id}/versions": {
             "get": {
-                "description": "Retrieve user details by user ID",
+                "description": "Retrieve every retained version of a user, newest first.\nVersions replaced longer than the version retention ago are compacted away",
                 "produces": [
                     "application/json"
                 ],
-                "summary": "Get user by ID",
-                "operationId": "get-user-by-id",
+                "summary": "List user versions",
+                "operationId": "list-user-versions",
                 "parameters": [
                     {
                         "type": "integer",
@@ -74,7 +1088,58 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/common.User"
+                            "$ref": "#/definitions/common.VersionList"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/common.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/common.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/common.ErrorResponse"
+                        }
+                    },
+                    "501": {
+                        "description": "Not Implemented",
+                        "schema": {
+                            "$ref": "#/definitions/common.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/users": {
+            "get": {
+                "description": "Retrieve details of multiple users at once, IDs which do not exist are reported as missing",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Get multiple users by ID",
+                "operationId": "get-users-by-ids",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Comma separated user IDs",
+                        "name": "ids",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/common.UserMultiResponse"
                         }
                     },
                     "400": {
@@ -82,12 +1147,122 @@ const docTemplate = `{
                         "schema": {
                             "$ref": "#/definitions/common.ErrorResponse"
                         }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/common.ErrorResponse"
+                        }
                     }
                 }
             }
         }
     },
     "definitions": {
+        "audit.Entry": {
+            "type": "object",
+            "properties": {
+                "actor": {
+                    "description": "Actor identifies who made the write",
+                    "type": "string"
+                },
+                "after": {
+                    "description": "After is the user after the write, it is empty for deleted users",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/common.User"
+                        }
+                    ]
+                },
+                "before": {
+                    "description": "Before is the user before the write, it is empty for created and restored users",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/common.User"
+                        }
+                    ]
+                },
+                "id": {
+                    "description": "ID is the unique identifier of the entry, increasing in recording order",
+                    "type": "integer"
+                },
+                "operation": {
+                    "description": "Operation is the kind of the write",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/audit.Operation"
+                        }
+                    ]
+                },
+                "request_id": {
+                    "description": "RequestID is the ID of the request which made the write",
+                    "type": "string"
+                },
+                "time": {
+                    "description": "Time is the time the write was recorded, strictly increasing in recording order",
+                    "type": "string"
+                },
+                "user_id": {
+                    "description": "UserID is the ID of the written user",
+                    "type": "integer"
+                }
+            }
+        },
+        "audit.EntryList": {
+            "type": "object",
+            "properties": {
+                "entries": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/audit.Entry"
+                    }
+                }
+            }
+        },
+        "audit.Operation": {
+            "type": "string",
+            "enum": [
+                "create",
+                "update",
+                "delete",
+                "soft_delete",
+                "restore"
+            ],
+            "x-enum-varnames": [
+                "Create",
+                "Update",
+                "Delete",
+                "SoftDelete",
+                "Restore"
+            ]
+        },
+        "common.BatchErrorResponse": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string"
+                },
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/common.BatchItemError"
+                    }
+                }
+            }
+        },
+        "common.BatchItemError": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "description": "Error is the reason the item was rejected",
+                    "type": "string"
+                },
+                "index": {
+                    "description": "Index is the position of the rejected item in the request",
+                    "type": "integer"
+                }
+            }
+        },
         "common.ErrorResponse": {
             "type": "object",
             "properties": {
@@ -99,6 +1274,25 @@ const docTemplate = `{
         "common.User": {
             "type": "object",
             "properties": {
+                "attributes": {
+                    "description": "Attributes holds free-form custom data of the user",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "created_at": {
+                    "description": "CreatedAt is the time the user was stored, it is set by the storage",
+                    "type": "string"
+                },
+                "deleted_at": {
+                    "description": "DeletedAt is the time the user was soft deleted, it is only set on users awaiting purge",
+                    "type": "string"
+                },
+                "email": {
+                    "description": "Email is the optional email address of the user",
+                    "type": "string"
+                },
                 "id": {
                     "description": "ID is the unique identifier for the user",
                     "type": "integer"
@@ -106,6 +1300,244 @@ const docTemplate = `{
                 "name": {
                     "description": "Name is the name of the user",
                     "type": "string"
+                },
+                "updated_at": {
+                    "description": "UpdatedAt is the time the user was last changed, it is set by the storage",
+                    "type": "string"
+                },
+                "version": {
+                    "description": "Version starts at 1 and is incremented by the storage on every update",
+                    "type": "integer"
+                }
+            }
+        },
+        "common.UserList": {
+            "type": "object",
+            "properties": {
+                "next_cursor": {
+                    "description": "NextCursor is an opaque token for fetching the next page, empty when there are no more users",
+                    "type": "string"
+                },
+                "users": {
+                    "description": "Users contains the users of the current page",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/common.User"
+                    }
+                }
+            }
+        },
+        "common.UserMultiResponse": {
+            "type": "object",
+            "properties": {
+                "missing": {
+                    "description": "Missing contains the requested IDs which do not exist",
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                },
+                "users": {
+                    "description": "Users contains the found users in the requested order",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/common.User"
+                    }
+                }
+            }
+        },
+        "common.VersionList": {
+            "type": "object",
+            "properties": {
+                "versions": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/common.User"
+                    }
+                }
+            }
+        },
+        "events.Event": {
+            "type": "object",
+            "properties": {
+                "id": {
+                    "description": "ID is the position of the event in the change log, IDs are increasing and start at 1",
+                    "type": "integer"
+                },
+                "time": {
+                    "description": "Time is the time the event was published",
+                    "type": "string"
+                },
+                "type": {
+                    "description": "Type is the kind of the change",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/events.Type"
+                        }
+                    ]
+                },
+                "user": {
+                    "description": "User is the user after the change, it is omitted for deleted users",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/common.User"
+                        }
+                    ]
+                },
+                "user_id": {
+                    "description": "UserID is the ID of the changed user",
+                    "type": "integer"
+                }
+            }
+        },
+        "events.Type": {
+            "type": "string",
+            "enum": [
+                "created",
+                "updated",
+                "deleted",
+                "restored"
+            ],
+            "x-enum-varnames": [
+                "Created",
+                "Updated",
+                "Deleted",
+                "Restored"
+            ]
+        },
+        "webhook.Delivery": {
+            "type": "object",
+            "properties": {
+                "attempts": {
+                    "description": "Attempts is the number of failed attempts since the delivery was queued or retried",
+                    "type": "integer"
+                },
+                "created_at": {
+                    "description": "CreatedAt is the time the delivery was queued",
+                    "type": "string"
+                },
+                "event": {
+                    "description": "Event is the delivered event",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/events.Event"
+                        }
+                    ]
+                },
+                "id": {
+                    "description": "ID is the unique identifier of the delivery",
+                    "type": "integer"
+                },
+                "last_error": {
+                    "description": "LastError describes why the last attempt failed",
+                    "type": "string"
+                },
+                "last_status_code": {
+                    "description": "LastStatusCode is the response status of the last attempt, 0 if no response was received",
+                    "type": "integer"
+                },
+                "next_attempt": {
+                    "description": "NextAttempt is the time of the next attempt of a pending delivery",
+                    "type": "string"
+                },
+                "status": {
+                    "description": "Status is the delivery state",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/webhook.Status"
+                        }
+                    ]
+                },
+                "updated_at": {
+                    "description": "UpdatedAt is the time of the last attempt or retry",
+                    "type": "string"
+                },
+                "webhook_id": {
+                    "description": "WebhookID is the ID of the webhook the event is delivered to",
+                    "type": "integer"
+                }
+            }
+        },
+        "webhook.DeliveryList": {
+            "type": "object",
+            "properties": {
+                "deliveries": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/webhook.Delivery"
+                    }
+                }
+            }
+        },
+        "webhook.Registration": {
+            "type": "object",
+            "properties": {
+                "events": {
+                    "description": "Events are the event types delivered to the webhook, all types are delivered when empty",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/events.Type"
+                    }
+                },
+                "secret": {
+                    "description": "Secret is the key of the HMAC signature of every delivery, a random one is generated when empty",
+                    "type": "string"
+                },
+                "url": {
+                    "description": "URL is the endpoint events are posted to",
+                    "type": "string"
+                }
+            }
+        },
+        "webhook.Status": {
+            "type": "string",
+            "enum": [
+                "pending",
+                "delivered",
+                "dead"
+            ],
+            "x-enum-varnames": [
+                "Pending",
+                "Delivered",
+                "Dead"
+            ]
+        },
+        "webhook.Webhook": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "description": "CreatedAt is the time the webhook was registered",
+                    "type": "string"
+                },
+                "events": {
+                    "description": "Events are the event types delivered to the webhook, all types are delivered when empty",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/events.Type"
+                    }
+                },
+                "id": {
+                    "description": "ID is the unique identifier of the webhook",
+                    "type": "integer"
+                },
+                "secret": {
+                    "description": "Secret is the key of the HMAC signature of every delivery, it is only returned on registration",
+                    "type": "string"
+                },
+                "url": {
+                    "description": "URL is the endpoint events are posted to",
+                    "type": "string"
+                }
+            }
+        },
+        "webhook.WebhookList": {
+            "type": "object",
+            "properties": {
+                "webhooks": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/webhook.Webhook"
+                    }
                 }
             }
         }
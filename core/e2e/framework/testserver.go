@@ -13,6 +13,7 @@ import (
 
 	"github.com/Aleksao998/LightningUserVault/core/command/helper"
 	serverCommand "github.com/Aleksao998/LightningUserVault/core/command/server"
+	"github.com/Aleksao998/LightningUserVault/core/command/server/types"
 	"github.com/Aleksao998/LightningUserVault/core/server"
 	"go.uber.org/zap/zapcore"
 )
@@ -20,6 +21,9 @@ import (
 const (
 	initialPort = 12000
 	localhost   = "localhost"
+
+	// storageTypeEnv selects the storage type of every test server, PEBBLE is used when it is not set
+	storageTypeEnv = "E2E_STORAGE_TYPE"
 )
 
 // TestServer represents a server used for testing purposes
@@ -30,8 +34,57 @@ type TestServer struct {
 	cmd    *exec.Cmd
 }
 
+// ConfigOption changes the configuration of a test server before it is started
+type ConfigOption func(config *server.Config)
+
+// WithSoftDelete enables soft delete with the given retention
+func WithSoftDelete(retention time.Duration) ConfigOption {
+	return func(config *server.Config) {
+		config.EnableSoftDelete = true
+		config.SoftDeleteRetention = retention
+	}
+}
+
+// WithSearch enables full-text search, the index snapshot is written to the given file
+func WithSearch(indexPath string) ConfigOption {
+	return func(config *server.Config) {
+		config.EnableSearch = true
+		config.SearchIndexPath = indexPath
+	}
+}
+
+// WithEvents enables streaming of user changes, the change log is kept in the given directory
+func WithEvents(logPath string) ConfigOption {
+	return func(config *server.Config) {
+		config.EnableEvents = true
+		config.EventLogPath = logPath
+	}
+}
+
+// WithWebhooks enables webhook delivery, it requires events, failed deliveries are dead after the given number of attempts
+func WithWebhooks(maxAttempts int) ConfigOption {
+	return func(config *server.Config) {
+		config.EnableWebhooks = true
+		config.WebhookMaxAttempts = maxAttempts
+	}
+}
+
+// WithAudit enables recording of every write in the audit log
+func WithAudit() ConfigOption {
+	return func(config *server.Config) {
+		config.EnableAudit = true
+	}
+}
+
+// WithVersions enables keeping every version of a user for point-in-time reads
+func WithVersions() ConfigOption {
+	return func(config *server.Config) {
+		config.EnableVersions = true
+	}
+}
+
 // NewTestServer initializes a new TestServer instance
-func NewTestServer(t *testing.T) *TestServer {
+func NewTestServer(t *testing.T, options ...ConfigOption) *TestServer {
 	t.Helper()
 
 	port := FindAvailablePort(initialPort, initialPort+10000)
@@ -48,10 +101,15 @@ func NewTestServer(t *testing.T) *TestServer {
 
 	config := server.Config{
 		ServerAddress: serverAddress,
+		StorageType:   StorageType(t),
 		EnableCache:   false,
 		LogLevel:      zapcore.DebugLevel,
 	}
 
+	for _, option := range options {
+		option(&config)
+	}
+
 	return &TestServer{
 		t:      t,
 		Config: &config,
@@ -59,13 +117,39 @@ func NewTestServer(t *testing.T) *TestServer {
 	}
 }
 
-// Stop terminates the test server process
+// StorageType returns the storage type the test servers are started with
+func StorageType(t *testing.T) types.StorageType {
+	t.Helper()
+
+	storageType, err := types.ConvertStringToStorageType(helper.GetEnvWithDefault(storageTypeEnv, string(types.PEBBLE)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return storageType
+}
+
+// SkipIfNotPersistent skips a test which expects users to survive a restart when the storage keeps them in memory
+func SkipIfNotPersistent(t *testing.T) {
+	t.Helper()
+
+	if StorageType(t) == types.MEMORY {
+		t.Skip("users are not persisted across restarts by MEMORY storage")
+	}
+}
+
+// Stop terminates the test server process and waits until it exits
 func (t *TestServer) Stop() {
 	if t.cmd != nil {
 		// Send a SIGINT signal to the process to trigger a graceful shutdown
 		if err := t.cmd.Process.Signal(os.Interrupt); err != nil {
 			t.t.Error(err)
 		}
+
+		// Files are written on shutdown, so wait for it before they are removed
+		_ = t.cmd.Wait()
+
+		t.cmd = nil
 	}
 }
 
@@ -85,8 +169,43 @@ func (t *TestServer) Start(ctx context.Context) error {
 	args := []string{
 		serverCmd.Use,
 		"--server-address", t.Config.ServerAddress.String(),
+		"--storage-type", string(t.Config.StorageType),
 		"--enable-cache", strconv.FormatBool(t.Config.EnableCache),
 		"--log-level", t.Config.LogLevel.String(),
+		"--enable-soft-delete", strconv.FormatBool(t.Config.EnableSoftDelete),
+	}
+
+	if t.Config.EnableSoftDelete {
+		args = append(args, "--soft-delete-retention", t.Config.SoftDeleteRetention.String())
+	}
+
+	if t.Config.EnableSearch {
+		args = append(args,
+			"--enable-search", "true",
+			"--search-index-path", t.Config.SearchIndexPath,
+		)
+	}
+
+	if t.Config.EnableEvents {
+		args = append(args,
+			"--enable-events", "true",
+			"--event-log-path", t.Config.EventLogPath,
+		)
+	}
+
+	if t.Config.EnableWebhooks {
+		args = append(args,
+			"--enable-webhooks", "true",
+			"--webhook-max-attempts", strconv.Itoa(t.Config.WebhookMaxAttempts),
+		)
+	}
+
+	if t.Config.EnableAudit {
+		args = append(args, "--enable-audit", "true")
+	}
+
+	if t.Config.EnableVersions {
+		args = append(args, "--enable-versions", "true")
 	}
 	fmt.Println(args)
 	t.ReleaseReservedPorts()
@@ -115,10 +234,10 @@ func (t *TestServer) Start(ctx context.Context) error {
 }
 
 // NewTestServerAndStart initializes and starts a new TestServer instance in a separate goroutine
-func NewTestServerAndStart(t *testing.T) *TestServer {
+func NewTestServerAndStart(t *testing.T, options ...ConfigOption) *TestServer {
 	t.Helper()
 
-	srv := NewTestServer(t)
+	srv := NewTestServer(t, options...)
 
 	t.Cleanup(func() {
 		srv.Stop()
@@ -146,4 +265,6 @@ func NewTestServerAndStart(t *testing.T) *TestServer {
 
 func CleanupStorage() {
 	os.RemoveAll("pebble-storage")
+	os.RemoveAll(helper.DefaultSearchIndexPath)
+	os.RemoveAll(helper.DefaultEventLogPath)
 }
@@ -1,13 +1,23 @@
 package e2e_test
 
 import (
+	"bufio"
 	"bytes"
 	"encoding/json"
+	"io"
 	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/Aleksao998/LightningUserVault/core/audit"
 	"github.com/Aleksao998/LightningUserVault/core/common"
 	"github.com/Aleksao998/LightningUserVault/core/e2e/framework"
+	"github.com/Aleksao998/LightningUserVault/core/events"
+	"github.com/Aleksao998/LightningUserVault/core/webhook"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -50,6 +60,8 @@ func TestE2E_SetAndGet(t *testing.T) {
 }
 
 func TestE2E_SetTwoItemsWithRestart(t *testing.T) {
+	framework.SkipIfNotPersistent(t)
+
 	// Initialize and start the test server using the framework
 	testServer := framework.NewTestServerAndStart(t)
 
@@ -99,3 +111,568 @@ func TestE2E_SetTwoItemsWithRestart(t *testing.T) {
 	// Teardown logic after all tests
 	framework.CleanupStorage()
 }
+
+func TestE2E_SetAndUpdate(t *testing.T) {
+	// Initialize and start the test server using the framework
+	testServer := framework.NewTestServerAndStart(t)
+
+	// Create a request to set a new user
+	user := common.User{Name: "John Doe"}
+	userJSON, err := json.Marshal(user)
+	assert.NoError(t, err)
+
+	resp, err := http.Post("http://"+testServer.Config.ServerAddress.String()+"/user", "application/json", bytes.NewBuffer(userJSON))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// Decode the response to validate
+	var newUser common.User
+	err = json.NewDecoder(resp.Body).Decode(&newUser)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), newUser.ID)
+
+	// Create a request to update the user
+	updatedJSON, err := json.Marshal(common.User{Name: "John Smith"})
+	assert.NoError(t, err)
+
+	req, err := http.NewRequest(http.MethodPut, "http://"+testServer.Config.ServerAddress.String()+"/user/1", bytes.NewBuffer(updatedJSON))
+	assert.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err = http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// Make a request to get the updated user
+	resp, err = http.Get("http://" + testServer.Config.ServerAddress.String() + "/user/1")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var retrievedUser common.User
+	err = json.NewDecoder(resp.Body).Decode(&retrievedUser)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), retrievedUser.ID)
+	assert.Equal(t, "John Smith", retrievedUser.Name)
+
+	// Make a request to update a user which does not exist
+	req, err = http.NewRequest(http.MethodPut, "http://"+testServer.Config.ServerAddress.String()+"/user/2", bytes.NewBuffer(updatedJSON))
+	assert.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err = http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	// Teardown logic after all tests
+	framework.CleanupStorage()
+}
+
+func TestE2E_SetAndDelete(t *testing.T) {
+	// Initialize and start the test server using the framework
+	testServer := framework.NewTestServerAndStart(t)
+
+	// Create a request to set a new user
+	user := common.User{Name: "John Doe"}
+	userJSON, err := json.Marshal(user)
+	assert.NoError(t, err)
+
+	resp, err := http.Post("http://"+testServer.Config.ServerAddress.String()+"/user", "application/json", bytes.NewBuffer(userJSON))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// Create a request to delete the user
+	req, err := http.NewRequest(http.MethodDelete, "http://"+testServer.Config.ServerAddress.String()+"/user/1", nil)
+	assert.NoError(t, err)
+
+	resp, err = http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+	// Make a request to get the deleted user
+	resp, err = http.Get("http://" + testServer.Config.ServerAddress.String() + "/user/1")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	// Deleting the user again should report it as missing
+	resp, err = http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	// Teardown logic after all tests
+	framework.CleanupStorage()
+}
+
+func TestE2E_SetAndFullTextSearch(t *testing.T) {
+	// Initialize and start the test server with full-text search enabled
+	testServer := framework.NewTestServerAndStart(t, framework.WithSearch(filepath.Join(t.TempDir(), "search-index")))
+
+	// Create a request to set a new user
+	user := common.User{Name: "Alexander Hamilton"}
+	userJSON, err := json.Marshal(user)
+	assert.NoError(t, err)
+
+	resp, err := http.Post("http://"+testServer.Config.ServerAddress.String()+"/user", "application/json", bytes.NewBuffer(userJSON))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// Search the user by a misspelled partial name
+	resp, err = http.Get("http://" + testServer.Config.ServerAddress.String() + "/user/search?q=hamilten+alex")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// Decode the response
+	var userList common.UserList
+	err = json.NewDecoder(resp.Body).Decode(&userList)
+	assert.NoError(t, err)
+	assert.Len(t, userList.Users, 1)
+	assert.Equal(t, "Alexander Hamilton", userList.Users[0].Name)
+
+	// Teardown logic after all tests
+	framework.CleanupStorage()
+}
+
+func TestE2E_SetAndGetUserFields(t *testing.T) {
+	// Initialize and start the test server using the framework
+	testServer := framework.NewTestServerAndStart(t)
+
+	// Create a request to set a new user with all optional fields
+	user := common.User{
+		Name:       "John Doe",
+		Email:      "john@example.com",
+		Attributes: map[string]string{"team": "core"},
+	}
+	userJSON, err := json.Marshal(user)
+	assert.NoError(t, err)
+
+	resp, err := http.Post("http://"+testServer.Config.ServerAddress.String()+"/user", "application/json", bytes.NewBuffer(userJSON))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// Decode the response to validate
+	var newUser common.User
+	err = json.NewDecoder(resp.Body).Decode(&newUser)
+	assert.NoError(t, err)
+	assert.False(t, newUser.CreatedAt.IsZero())
+
+	// Make a request to get the user
+	resp, err = http.Get("http://" + testServer.Config.ServerAddress.String() + "/user/1")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// Decode the response
+	var retrievedUser common.User
+	err = json.NewDecoder(resp.Body).Decode(&retrievedUser)
+	assert.NoError(t, err)
+	assert.Equal(t, "john@example.com", retrievedUser.Email)
+	assert.Equal(t, map[string]string{"team": "core"}, retrievedUser.Attributes)
+	assert.True(t, newUser.CreatedAt.Equal(retrievedUser.CreatedAt))
+
+	// Teardown logic after all tests
+	framework.CleanupStorage()
+}
+
+func TestE2E_UpdateWithIfMatch(t *testing.T) {
+	// Initialize and start the test server using the framework
+	testServer := framework.NewTestServerAndStart(t)
+
+	// Create a request to set a new user
+	user := common.User{Name: "John Doe"}
+	userJSON, err := json.Marshal(user)
+	assert.NoError(t, err)
+
+	resp, err := http.Post("http://"+testServer.Config.ServerAddress.String()+"/user", "application/json", bytes.NewBuffer(userJSON))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// Make a request to get the user and its version
+	resp, err = http.Get("http://" + testServer.Config.ServerAddress.String() + "/user/1")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	etag := resp.Header.Get("ETag")
+	assert.Equal(t, `"1"`, etag)
+
+	// Update the user at the retrieved version
+	updatedJSON, err := json.Marshal(common.User{Name: "John Smith"})
+	assert.NoError(t, err)
+
+	req, err := http.NewRequest(http.MethodPut, "http://"+testServer.Config.ServerAddress.String()+"/user/1", bytes.NewBuffer(updatedJSON))
+	assert.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("If-Match", etag)
+
+	resp, err = http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, `"2"`, resp.Header.Get("ETag"))
+
+	// Updating again with the stale version should fail
+	req, err = http.NewRequest(http.MethodPut, "http://"+testServer.Config.ServerAddress.String()+"/user/1", bytes.NewBuffer(updatedJSON))
+	assert.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("If-Match", etag)
+
+	resp, err = http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusPreconditionFailed, resp.StatusCode)
+
+	// Deleting with the stale version should fail as well
+	req, err = http.NewRequest(http.MethodDelete, "http://"+testServer.Config.ServerAddress.String()+"/user/1", nil)
+	assert.NoError(t, err)
+	req.Header.Set("If-Match", etag)
+
+	resp, err = http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusPreconditionFailed, resp.StatusCode)
+
+	// Teardown logic after all tests
+	framework.CleanupStorage()
+}
+
+func TestE2E_ConditionalGet(t *testing.T) {
+	// Initialize and start the test server using the framework
+	testServer := framework.NewTestServerAndStart(t)
+
+	// Create a request to set a new user
+	user := common.User{Name: "John Doe"}
+	userJSON, err := json.Marshal(user)
+	assert.NoError(t, err)
+
+	resp, err := http.Post("http://"+testServer.Config.ServerAddress.String()+"/user", "application/json", bytes.NewBuffer(userJSON))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// Make a request to get the user and its validators
+	resp, err = http.Get("http://" + testServer.Config.ServerAddress.String() + "/user/1")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	etag := resp.Header.Get("ETag")
+	lastModified := resp.Header.Get("Last-Modified")
+	assert.NotEmpty(t, lastModified)
+
+	// Revalidate with the ETag
+	req, err := http.NewRequest(http.MethodGet, "http://"+testServer.Config.ServerAddress.String()+"/user/1", nil)
+	assert.NoError(t, err)
+	req.Header.Set("If-None-Match", etag)
+
+	resp, err = http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotModified, resp.StatusCode)
+
+	// Revalidate with the modification time
+	req, err = http.NewRequest(http.MethodGet, "http://"+testServer.Config.ServerAddress.String()+"/user/1", nil)
+	assert.NoError(t, err)
+	req.Header.Set("If-Modified-Since", lastModified)
+
+	resp, err = http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotModified, resp.StatusCode)
+
+	// Teardown logic after all tests
+	framework.CleanupStorage()
+}
+
+func TestE2E_SoftDeleteRestoreAndPurge(t *testing.T) {
+	// Initialize and start the test server with a short retention, so the purge can be observed
+	testServer := framework.NewTestServerAndStart(t, framework.WithSoftDelete(2*time.Second))
+
+	// Create requests to set two users
+	for _, name := range []string{"John Doe", "Jane Doe"} {
+		userJSON, err := json.Marshal(common.User{Name: name})
+		assert.NoError(t, err)
+
+		resp, err := http.Post("http://"+testServer.Config.ServerAddress.String()+"/user", "application/json", bytes.NewBuffer(userJSON))
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+
+	// Delete both users
+	for _, id := range []string{"1", "2"} {
+		req, err := http.NewRequest(http.MethodDelete, "http://"+testServer.Config.ServerAddress.String()+"/user/"+id, nil)
+		assert.NoError(t, err)
+
+		resp, err := http.DefaultClient.Do(req)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	}
+
+	// The deleted user is gone
+	resp, err := http.Get("http://" + testServer.Config.ServerAddress.String() + "/user/1")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusGone, resp.StatusCode)
+
+	// Restore the first user
+	resp, err = http.Post("http://"+testServer.Config.ServerAddress.String()+"/user/1/restore", "application/json", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp, err = http.Get("http://" + testServer.Config.ServerAddress.String() + "/user/1")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var retrievedUser common.User
+	err = json.NewDecoder(resp.Body).Decode(&retrievedUser)
+	assert.NoError(t, err)
+	assert.Equal(t, "John Doe", retrievedUser.Name)
+
+	// After the retention the second user is purged and can not be restored anymore
+	time.Sleep(5 * time.Second)
+
+	resp, err = http.Get("http://" + testServer.Config.ServerAddress.String() + "/user/2")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	resp, err = http.Post("http://"+testServer.Config.ServerAddress.String()+"/user/2/restore", "application/json", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	// Teardown logic after all tests
+	framework.CleanupStorage()
+}
+
+func TestE2E_EventsResumeAfterRestart(t *testing.T) {
+	// The change log is kept in the same directory across the restart
+	eventLogPath := t.TempDir()
+
+	// Initialize and start the test server using the framework
+	testServer := framework.NewTestServerAndStart(t, framework.WithEvents(eventLogPath))
+
+	// Create two users, each of them is recorded in the change log
+	for _, name := range []string{"John Doe", "Jane Smith"} {
+		userJSON, err := json.Marshal(common.User{Name: name})
+		assert.NoError(t, err)
+
+		resp, err := http.Post("http://"+testServer.Config.ServerAddress.String()+"/user", "application/json", bytes.NewBuffer(userJSON))
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+
+	// Restart the server, the change log is persisted
+	testServer.Stop()
+
+	testServer = framework.NewTestServerAndStart(t, framework.WithEvents(eventLogPath))
+
+	// Resume after the first event
+	req, err := http.NewRequest(http.MethodGet, "http://"+testServer.Config.ServerAddress.String()+"/user/events", nil)
+	assert.NoError(t, err)
+	req.Header.Set("Last-Event-ID", "1")
+
+	resp, err := http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	// The second event is replayed first
+	reader := bufio.NewReader(resp.Body)
+	lines := make([]string, 0, 3)
+
+	for len(lines) < 3 {
+		line, err := reader.ReadString('\n')
+		assert.NoError(t, err)
+
+		lines = append(lines, strings.TrimSuffix(line, "\n"))
+	}
+
+	assert.Equal(t, "id: 2", lines[0])
+	assert.Equal(t, "event: created", lines[1])
+	assert.Contains(t, lines[2], `"name":"Jane Smith"`)
+
+	// Teardown logic after all tests
+	framework.CleanupStorage()
+}
+
+func TestE2E_WebhookDelivery(t *testing.T) {
+	// Start a webhook receiver collecting delivered events
+	received := make(chan *http.Request, 10)
+	bodies := make(chan []byte, 10)
+
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		received <- r
+		bodies <- body
+	}))
+	defer receiver.Close()
+
+	// Initialize and start the test server using the framework
+	testServer := framework.NewTestServerAndStart(t, framework.WithEvents(t.TempDir()), framework.WithWebhooks(3))
+	address := "http://" + testServer.Config.ServerAddress.String()
+
+	// Register the receiver for created users
+	registrationJSON, err := json.Marshal(webhook.Registration{
+		URL:    receiver.URL,
+		Secret: "secret",
+		Events: []events.Type{events.Created},
+	})
+	assert.NoError(t, err)
+
+	resp, err := http.Post(address+"/admin/webhooks", "application/json", bytes.NewBuffer(registrationJSON))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	// Create a user
+	userJSON, err := json.Marshal(common.User{Name: "John Doe"})
+	assert.NoError(t, err)
+
+	resp, err = http.Post(address+"/user", "application/json", bytes.NewBuffer(userJSON))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// The receiver gets the signed event
+	select {
+	case req := <-received:
+		body := <-bodies
+
+		timestamp, err := strconv.ParseInt(req.Header.Get(webhook.TimestampHeader), 10, 64)
+		assert.NoError(t, err)
+		assert.True(t, webhook.Verify("secret", timestamp, body, req.Header.Get(webhook.SignatureHeader)))
+		assert.Equal(t, "created", req.Header.Get(webhook.EventHeader))
+
+		var event events.Event
+		assert.NoError(t, json.Unmarshal(body, &event))
+		assert.Equal(t, "John Doe", event.User.Name)
+	case <-time.After(10 * time.Second):
+		t.Fatal("webhook was not called")
+	}
+
+	// The delivery status is reported
+	assert.Eventually(t, func() bool {
+		resp, err := http.Get(address + "/admin/deliveries?status=delivered")
+		if err != nil {
+			return false
+		}
+		defer resp.Body.Close()
+
+		var list webhook.DeliveryList
+		if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+			return false
+		}
+
+		return len(list.Deliveries) == 1
+	}, 5*time.Second, 100*time.Millisecond)
+
+	// Teardown logic after all tests
+	framework.CleanupStorage()
+}
+
+func TestE2E_AuditHistory(t *testing.T) {
+	// Initialize and start the test server with the audit log enabled
+	testServer := framework.NewTestServerAndStart(t, framework.WithAudit())
+
+	address := "http://" + testServer.Config.ServerAddress.String()
+
+	// Create a user as one actor
+	req, err := http.NewRequest(http.MethodPost, address+"/user", strings.NewReader(`{"name":"John Doe"}`))
+	assert.NoError(t, err)
+	req.Header.Set("X-Actor", "alice")
+	req.Header.Set("X-Request-ID", "req-1")
+
+	resp, err := http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// Rename it as another actor
+	req, err = http.NewRequest(http.MethodPut, address+"/user/1", strings.NewReader(`{"name":"Jane Doe"}`))
+	assert.NoError(t, err)
+	req.Header.Set("X-Actor", "bob")
+
+	resp, err = http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// The history holds both writes, newest first
+	resp, err = http.Get(address + "/user/1/history")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var history audit.EntryList
+	err = json.NewDecoder(resp.Body).Decode(&history)
+	assert.NoError(t, err)
+
+	if !assert.Len(t, history.Entries, 2) {
+		t.FailNow()
+	}
+
+	assert.Equal(t, audit.Update, history.Entries[0].Operation)
+	assert.Equal(t, "bob", history.Entries[0].Actor)
+	assert.NotEmpty(t, history.Entries[0].RequestID)
+	assert.Equal(t, "John Doe", history.Entries[0].Before.Name)
+	assert.Equal(t, "Jane Doe", history.Entries[0].After.Name)
+
+	assert.Equal(t, audit.Create, history.Entries[1].Operation)
+	assert.Equal(t, "alice", history.Entries[1].Actor)
+	assert.Equal(t, "req-1", history.Entries[1].RequestID)
+
+	// Only the update is listed after the time of the create
+	since := history.Entries[1].Time.Format(time.RFC3339Nano)
+
+	resp, err = http.Get(address + "/admin/audit?since=" + since)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var entries audit.EntryList
+	err = json.NewDecoder(resp.Body).Decode(&entries)
+	assert.NoError(t, err)
+
+	if assert.Len(t, entries.Entries, 1) {
+		assert.Equal(t, audit.Update, entries.Entries[0].Operation)
+	}
+
+	// Teardown logic after all tests
+	framework.CleanupStorage()
+}
+
+// TestE2E_PointInTimeRead tests reading a user as it was before an update and listing its versions
+func TestE2E_PointInTimeRead(t *testing.T) {
+	// Initialize and start the test server with user versions enabled
+	testServer := framework.NewTestServerAndStart(t, framework.WithVersions())
+
+	address := "http://" + testServer.Config.ServerAddress.String()
+
+	resp, err := http.Post(address+"/user", "application/json", strings.NewReader(`{"name":"John Doe"}`))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var created common.User
+	err = json.NewDecoder(resp.Body).Decode(&created)
+	assert.NoError(t, err)
+
+	req, err := http.NewRequest(http.MethodPut, address+"/user/1", strings.NewReader(`{"name":"Jane Doe"}`))
+	assert.NoError(t, err)
+
+	resp, err = http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// The user is read as it was when it was created
+	resp, err = http.Get(address + "/user/1?as_of=" + created.UpdatedAt.Format(time.RFC3339Nano))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var user common.User
+	err = json.NewDecoder(resp.Body).Decode(&user)
+	assert.NoError(t, err)
+	assert.Equal(t, "John Doe", user.Name)
+	assert.Equal(t, int64(1), user.Version)
+
+	// Both versions are listed, newest first
+	resp, err = http.Get(address + "/user/1/versions")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var versions common.VersionList
+	err = json.NewDecoder(resp.Body).Decode(&versions)
+	assert.NoError(t, err)
+
+	if assert.Len(t, versions.Versions, 2) {
+		assert.Equal(t, "Jane Doe", versions.Versions[0].Name)
+		assert.Equal(t, "John Doe", versions.Versions[1].Name)
+	}
+
+	// Teardown logic after all tests
+	framework.CleanupStorage()
+}
@@ -0,0 +1,276 @@
+package events
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/Aleksao998/LightningUserVault/core/common"
+	"go.uber.org/zap"
+)
+
+const (
+	// subscriberBuffer is the number of live events buffered for a subscriber before it is considered lagging
+	subscriberBuffer = 256
+
+	// replayPageSize is the number of events read from the change log at once while a subscriber catches up
+	replayPageSize = 256
+)
+
+// Broker persists published events to the change log and fans them out to subscribers.
+//
+// Publishing never waits for subscribers. Every subscriber has a bounded buffer of live events,
+// and a subscriber which lets it fill up is detached and catches up by reading the change log
+// until it reaches the newest event, after which it receives live events again
+type Broker struct {
+	logger    *zap.Logger
+	changeLog *ChangeLog
+
+	// lock serializes publishing with attaching subscribers,
+	// so every event is either replayed from the log or delivered live, never both or neither
+	lock        sync.Mutex
+	subscribers map[*subscription]struct{}
+	closed      chan struct{}
+	isClosed    bool
+}
+
+// NewBroker creates a new Broker publishing to the given change log
+func NewBroker(logger *zap.Logger, changeLog *ChangeLog) *Broker {
+	return &Broker{
+		logger:      logger,
+		changeLog:   changeLog,
+		subscribers: make(map[*subscription]struct{}),
+		closed:      make(chan struct{}),
+	}
+}
+
+// Publish appends an event for the given user to the change log and delivers it to all attached subscribers
+func (b *Broker) Publish(eventType Type, userID int64, user *common.User) (*Event, error) {
+	return b.publish(0, eventType, userID, user)
+}
+
+// PublishRelayed publishes the event of the outbox message with the given ID, recording the message ID with the event.
+// A message with an ID which is not greater than the last recorded one was published already, it is skipped
+// and a nil event is returned
+func (b *Broker) PublishRelayed(messageID int64, eventType Type, userID int64, user *common.User) (*Event, error) {
+	return b.publish(messageID, eventType, userID, user)
+}
+
+// publish appends the event to the change log and delivers it to all attached subscribers,
+// a message ID greater than 0 marks an event relayed from the outbox
+func (b *Broker) publish(messageID int64, eventType Type, userID int64, user *common.User) (*Event, error) {
+	event := &Event{
+		Type:   eventType,
+		UserID: userID,
+		User:   user,
+		Time:   time.Now().UTC(),
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.isClosed {
+		return nil, ErrStreamClosed
+	}
+
+	if messageID > 0 && messageID <= b.changeLog.LastRelayedID() {
+		b.logger.Debug("Skipping outbox message which was already published", zap.Int64("messageID", messageID))
+
+		return nil, nil
+	}
+
+	if err := b.changeLog.AppendRelayed(event, messageID); err != nil {
+		return nil, err
+	}
+
+	for sub := range b.subscribers {
+		select {
+		case sub.live <- event:
+		default:
+			// The subscriber is lagging, it continues from the change log
+			b.logger.Debug("Detaching lagging subscriber", zap.Int64("cursor", sub.cursor))
+			b.detach(sub)
+		}
+	}
+
+	return event, nil
+}
+
+// Subscribe returns a subscription receiving every event after the given event ID
+func (b *Broker) Subscribe(lastEventID int64) (Subscription, error) {
+	if lastEventID < 0 {
+		return nil, ErrInvalidEventID
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.isClosed {
+		return nil, ErrStreamClosed
+	}
+
+	// A client can not have seen events which were never published, it may be resuming against another change log
+	if lastEventID > b.changeLog.LastID() {
+		lastEventID = b.changeLog.LastID()
+	}
+
+	sub := &subscription{
+		broker: b,
+		cursor: lastEventID,
+	}
+
+	b.attach(sub)
+
+	return sub, nil
+}
+
+// attach registers the subscriber for live events, events up to the current last ID are replayed from the log.
+// It must be called while holding the lock
+func (b *Broker) attach(sub *subscription) {
+	sub.live = make(chan *Event, subscriberBuffer)
+	sub.replayUntil = b.changeLog.LastID()
+
+	b.subscribers[sub] = struct{}{}
+}
+
+// detach stops live delivery to the subscriber, it must be called while holding the lock
+func (b *Broker) detach(sub *subscription) {
+	if _, ok := b.subscribers[sub]; !ok {
+		return
+	}
+
+	delete(b.subscribers, sub)
+	close(sub.live)
+}
+
+// Close ends all subscriptions and closes the change log
+func (b *Broker) Close() error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.isClosed {
+		return nil
+	}
+
+	b.isClosed = true
+	close(b.closed)
+
+	for sub := range b.subscribers {
+		b.detach(sub)
+	}
+
+	return b.changeLog.Close()
+}
+
+// subscription reads events after its cursor, first from the change log and then live from the broker
+type subscription struct {
+	broker *Broker
+
+	// cursor is the ID of the last returned event
+	cursor int64
+
+	// replayUntil is the ID of the last event which has to be read from the change log
+	replayUntil int64
+
+	// pending holds events read from the change log which were not returned yet
+	pending []*Event
+
+	// live receives events published after replayUntil, it is closed when the subscriber is detached
+	live chan *Event
+}
+
+// Next returns the event following the cursor, it must not be called concurrently
+func (s *subscription) Next(ctx context.Context) (*Event, error) {
+	for {
+		if len(s.pending) > 0 {
+			event := s.pending[0]
+			s.pending = s.pending[1:]
+			s.cursor = event.ID
+
+			return event, nil
+		}
+
+		if s.cursor < s.replayUntil {
+			if err := s.replay(); err != nil {
+				return nil, err
+			}
+
+			continue
+		}
+
+		if s.live == nil {
+			if err := s.reattach(); err != nil {
+				return nil, err
+			}
+
+			continue
+		}
+
+		select {
+		case event, ok := <-s.live:
+			if !ok {
+				// Detached for lagging behind, continue from the change log
+				s.live = nil
+
+				continue
+			}
+
+			if event.ID <= s.cursor {
+				continue
+			}
+
+			s.cursor = event.ID
+
+			return event, nil
+		case <-s.broker.closed:
+			return nil, ErrStreamClosed
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// replay reads the next page of events up to replayUntil from the change log
+func (s *subscription) replay() error {
+	events, err := s.broker.changeLog.ReadAfter(s.cursor, replayPageSize)
+	if err != nil {
+		return err
+	}
+
+	for _, event := range events {
+		if event.ID > s.replayUntil {
+			break
+		}
+
+		s.pending = append(s.pending, event)
+	}
+
+	if len(s.pending) == 0 {
+		// Nothing is left to replay
+		s.cursor = s.replayUntil
+	}
+
+	return nil
+}
+
+// reattach registers a detached subscriber for live events again
+func (s *subscription) reattach() error {
+	s.broker.lock.Lock()
+	defer s.broker.lock.Unlock()
+
+	if s.broker.isClosed {
+		return ErrStreamClosed
+	}
+
+	s.broker.attach(s)
+
+	return nil
+}
+
+// Close stops live delivery to the subscription
+func (s *subscription) Close() {
+	s.broker.lock.Lock()
+	defer s.broker.lock.Unlock()
+
+	s.broker.detach(s)
+}
@@ -0,0 +1,215 @@
+package events
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Aleksao998/LightningUserVault/core/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// newTestBroker creates a broker publishing to a change log in a temporary directory
+func newTestBroker(t *testing.T) *Broker {
+	t.Helper()
+
+	changeLog, err := OpenChangeLog(zap.NewNop(), filepath.Join(t.TempDir(), "event-log"), 0)
+	require.NoError(t, err)
+
+	broker := NewBroker(zap.NewNop(), changeLog)
+	t.Cleanup(func() { broker.Close() })
+
+	return broker
+}
+
+// publishN publishes n created events for users with increasing IDs
+func publishN(t *testing.T, broker *Broker, n int) {
+	t.Helper()
+
+	for i := 0; i < n; i++ {
+		_, err := broker.Publish(Created, int64(i+1), &common.User{ID: int64(i + 1), Name: "John"})
+		require.NoError(t, err)
+	}
+}
+
+// nextIDs reads n events from the subscription and returns their IDs
+func nextIDs(t *testing.T, sub Subscription, n int) []int64 {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	ids := make([]int64, 0, n)
+
+	for i := 0; i < n; i++ {
+		event, err := sub.Next(ctx)
+		require.NoError(t, err)
+
+		ids = append(ids, event.ID)
+	}
+
+	return ids
+}
+
+// TestBroker_LiveEvents tests that events published after subscribing are delivered
+func TestBroker_LiveEvents(t *testing.T) {
+	t.Parallel()
+
+	broker := newTestBroker(t)
+
+	sub, err := broker.Subscribe(0)
+	require.NoError(t, err)
+	defer sub.Close()
+
+	event, err := broker.Publish(Updated, 7, &common.User{ID: 7, Name: "Jane"})
+	require.NoError(t, err)
+
+	received, err := sub.Next(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, event.ID, received.ID)
+	assert.Equal(t, Updated, received.Type)
+	assert.Equal(t, int64(7), received.UserID)
+	assert.Equal(t, "Jane", received.User.Name)
+}
+
+// TestBroker_Resume tests that a subscriber resuming from an event ID receives every later event exactly once
+func TestBroker_Resume(t *testing.T) {
+	t.Parallel()
+
+	broker := newTestBroker(t)
+	publishN(t, broker, 5)
+
+	sub, err := broker.Subscribe(2)
+	require.NoError(t, err)
+	defer sub.Close()
+
+	publishN(t, broker, 2)
+
+	assert.Equal(t, []int64{3, 4, 5, 6, 7}, nextIDs(t, sub, 5))
+}
+
+// TestBroker_PublishRelayed tests that an outbox message is published once, also when it is relayed again
+func TestBroker_PublishRelayed(t *testing.T) {
+	t.Parallel()
+
+	broker := newTestBroker(t)
+
+	for _, messageID := range []int64{1, 2, 1, 2, 3} {
+		_, err := broker.PublishRelayed(messageID, Created, messageID, &common.User{ID: messageID, Name: "John"})
+		require.NoError(t, err)
+	}
+
+	event, err := broker.PublishRelayed(3, Created, 3, &common.User{ID: 3, Name: "John"})
+	require.NoError(t, err)
+	assert.Nil(t, event)
+
+	sub, err := broker.Subscribe(0)
+	require.NoError(t, err)
+	defer sub.Close()
+
+	assert.Equal(t, []int64{1, 2, 3}, nextIDs(t, sub, 3))
+	assert.Equal(t, int64(3), broker.changeLog.LastID())
+}
+
+// TestBroker_ResumeFromUnknownID tests that resuming after the newest event only delivers new events
+func TestBroker_ResumeFromUnknownID(t *testing.T) {
+	t.Parallel()
+
+	broker := newTestBroker(t)
+	publishN(t, broker, 2)
+
+	sub, err := broker.Subscribe(100)
+	require.NoError(t, err)
+	defer sub.Close()
+
+	publishN(t, broker, 1)
+
+	assert.Equal(t, []int64{3}, nextIDs(t, sub, 1))
+}
+
+// TestBroker_LaggingSubscriber tests that a subscriber overflowing its buffer catches up from the change log
+// without slowing down publishing
+func TestBroker_LaggingSubscriber(t *testing.T) {
+	t.Parallel()
+
+	broker := newTestBroker(t)
+
+	sub, err := broker.Subscribe(0)
+	require.NoError(t, err)
+	defer sub.Close()
+
+	total := 2*subscriberBuffer + 10
+	publishN(t, broker, total)
+
+	ids := nextIDs(t, sub, total)
+
+	for i, id := range ids {
+		assert.Equal(t, int64(i+1), id)
+	}
+
+	// Live delivery is resumed once the subscriber caught up
+	publishN(t, broker, 1)
+	assert.Equal(t, []int64{int64(total + 1)}, nextIDs(t, sub, 1))
+}
+
+// TestBroker_InvalidEventID tests that negative event IDs are rejected
+func TestBroker_InvalidEventID(t *testing.T) {
+	t.Parallel()
+
+	broker := newTestBroker(t)
+
+	_, err := broker.Subscribe(-1)
+	assert.ErrorIs(t, err, ErrInvalidEventID)
+}
+
+// TestBroker_Close tests that closing the broker ends waiting subscriptions and rejects new events
+func TestBroker_Close(t *testing.T) {
+	t.Parallel()
+
+	broker := newTestBroker(t)
+
+	sub, err := broker.Subscribe(0)
+	require.NoError(t, err)
+
+	done := make(chan error)
+
+	go func() {
+		_, err := sub.Next(context.Background())
+		done <- err
+	}()
+
+	require.NoError(t, broker.Close())
+
+	select {
+	case err := <-done:
+		assert.ErrorIs(t, err, ErrStreamClosed)
+	case <-time.After(5 * time.Second):
+		t.Fatal("subscription was not ended")
+	}
+
+	_, err = broker.Publish(Created, 1, nil)
+	assert.ErrorIs(t, err, ErrStreamClosed)
+
+	_, err = broker.Subscribe(0)
+	assert.ErrorIs(t, err, ErrStreamClosed)
+}
+
+// TestBroker_ContextDone tests that waiting for an event stops when the context is done
+func TestBroker_ContextDone(t *testing.T) {
+	t.Parallel()
+
+	broker := newTestBroker(t)
+
+	sub, err := broker.Subscribe(0)
+	require.NoError(t, err)
+	defer sub.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	_, err = sub.Next(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
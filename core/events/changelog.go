@@ -0,0 +1,238 @@
+package events
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"sync"
+
+	"github.com/cockroachdb/pebble"
+	"go.uber.org/zap"
+)
+
+const (
+	// DefaultMaxEvents is the number of events retained when the limit is not configured
+	DefaultMaxEvents = 100000
+
+	// trimInterval is the number of appended events between two removals of events beyond the retention
+	trimInterval = 1000
+)
+
+// lastRelayedIDKey stores the ID of the last outbox message published to the log.
+// Event keys are big endian IDs which start with a zero byte, so the key sorts after every event
+var lastRelayedIDKey = []byte("__lastRelayedID__")
+
+// ChangeLog persists events in a pebble database keyed by their big endian ID, so they are read back in order.
+// Only the latest maxEvents events are retained
+type ChangeLog struct {
+	db            *pebble.DB
+	logger        *zap.Logger
+	maxEvents     int64
+	lastID        int64
+	lastRelayedID int64
+
+	// closeLock prevents reads from running into a closed database
+	closeLock sync.RWMutex
+	closed    bool
+}
+
+// OpenChangeLog opens the change log at the given path, continuing after the last stored event
+func OpenChangeLog(logger *zap.Logger, path string, maxEvents int64) (*ChangeLog, error) {
+	if maxEvents <= 0 {
+		maxEvents = DefaultMaxEvents
+	}
+
+	db, err := pebble.Open(path, &pebble.Options{})
+	if err != nil {
+		logger.Error("Failed to open change log", zap.String("path", path), zap.Error(err))
+
+		return nil, err
+	}
+
+	lastID, err := loadLastID(db)
+	if err != nil {
+		logger.Error("Failed to load last event ID", zap.String("path", path), zap.Error(err))
+		db.Close()
+
+		return nil, err
+	}
+
+	lastRelayedID, err := loadLastRelayedID(db)
+	if err != nil {
+		logger.Error("Failed to load last relayed message ID", zap.String("path", path), zap.Error(err))
+		db.Close()
+
+		return nil, err
+	}
+
+	logger.Debug("Opened change log", zap.String("path", path), zap.Int64("lastID", lastID), zap.Int64("lastRelayedID", lastRelayedID))
+
+	return &ChangeLog{
+		db:            db,
+		logger:        logger,
+		maxEvents:     maxEvents,
+		lastID:        lastID,
+		lastRelayedID: lastRelayedID,
+	}, nil
+}
+
+// eventKey returns the key of the event with the given ID
+func eventKey(id int64) []byte {
+	return binary.BigEndian.AppendUint64(nil, uint64(id))
+}
+
+// loadLastID returns the ID of the newest stored event, or 0 if the log is empty
+func loadLastID(db *pebble.DB) (int64, error) {
+	iter, err := db.NewIter(&pebble.IterOptions{UpperBound: lastRelayedIDKey})
+	if err != nil {
+		return 0, err
+	}
+	defer iter.Close()
+
+	if !iter.Last() {
+		return 0, iter.Error()
+	}
+
+	return int64(binary.BigEndian.Uint64(iter.Key())), nil
+}
+
+// loadLastRelayedID returns the ID of the last outbox message published to the log, or 0 if there is none
+func loadLastRelayedID(db *pebble.DB) (int64, error) {
+	value, closer, err := db.Get(lastRelayedIDKey)
+	if err != nil {
+		if errors.Is(err, pebble.ErrNotFound) {
+			return 0, nil
+		}
+
+		return 0, err
+	}
+	defer closer.Close()
+
+	return int64(binary.BigEndian.Uint64(value)), nil
+}
+
+// LastID returns the ID of the newest event. It is not safe for concurrent use with Append
+func (l *ChangeLog) LastID() int64 {
+	return l.lastID
+}
+
+// LastRelayedID returns the ID of the last outbox message appended with AppendRelayed.
+// It is not safe for concurrent use with appends
+func (l *ChangeLog) LastRelayedID() int64 {
+	return l.lastRelayedID
+}
+
+// Append assigns the next ID to the event and stores it. Appends must not run concurrently
+func (l *ChangeLog) Append(event *Event) error {
+	return l.append(event, 0)
+}
+
+// AppendRelayed assigns the next ID to the event of the outbox message with the given ID
+// and stores it together with the message ID, so a message sent again after a failure can be recognized.
+// Appends must not run concurrently
+func (l *ChangeLog) AppendRelayed(event *Event, messageID int64) error {
+	return l.append(event, messageID)
+}
+
+// append stores the event under the next ID, and the relayed message ID in the same batch when it is set
+func (l *ChangeLog) append(event *Event, messageID int64) error {
+	event.ID = l.lastID + 1
+
+	value, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	batch := l.db.NewBatch()
+	defer batch.Close()
+
+	err = batch.Set(eventKey(event.ID), value, nil)
+
+	if err == nil && messageID > 0 {
+		err = batch.Set(lastRelayedIDKey, eventKey(messageID), nil)
+	}
+
+	if err == nil {
+		err = batch.Commit(pebble.Sync)
+	}
+
+	if err != nil {
+		l.logger.Error("Failed to append event to change log", zap.Int64("ID", event.ID), zap.Error(err))
+
+		return err
+	}
+
+	l.lastID = event.ID
+
+	if messageID > 0 {
+		l.lastRelayedID = messageID
+	}
+
+	if l.lastID%trimInterval == 0 && l.lastID > l.maxEvents {
+		l.trim()
+	}
+
+	return nil
+}
+
+// trim removes events beyond the retention, failures are only logged since they are retried on the next trim
+func (l *ChangeLog) trim() {
+	oldest := l.lastID - l.maxEvents + 1
+
+	if err := l.db.DeleteRange(eventKey(0), eventKey(oldest), pebble.NoSync); err != nil {
+		l.logger.Warn("Failed to trim change log", zap.Int64("oldest", oldest), zap.Error(err))
+
+		return
+	}
+
+	l.logger.Debug("Trimmed change log", zap.Int64("oldest", oldest))
+}
+
+// ReadAfter returns up to limit events with an ID greater than the given one, in order.
+// Events removed by the retention are skipped, so the first returned event may not directly follow the given ID
+func (l *ChangeLog) ReadAfter(id int64, limit int) ([]*Event, error) {
+	l.closeLock.RLock()
+	defer l.closeLock.RUnlock()
+
+	if l.closed {
+		return nil, ErrStreamClosed
+	}
+
+	iter, err := l.db.NewIter(&pebble.IterOptions{
+		LowerBound: eventKey(id + 1),
+		UpperBound: lastRelayedIDKey,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Close()
+
+	events := make([]*Event, 0, limit)
+
+	for valid := iter.First(); valid && len(events) < limit; valid = iter.Next() {
+		var event Event
+		if err := json.Unmarshal(iter.Value(), &event); err != nil {
+			l.logger.Error("Failed to decode event from change log", zap.Error(err))
+
+			return nil, err
+		}
+
+		events = append(events, &event)
+	}
+
+	if err := iter.Error(); err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+// Close closes the change log database, waiting for running reads
+func (l *ChangeLog) Close() error {
+	l.closeLock.Lock()
+	defer l.closeLock.Unlock()
+
+	l.closed = true
+
+	return l.db.Close()
+}
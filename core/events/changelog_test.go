@@ -0,0 +1,127 @@
+package events
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// newTestChangeLog opens a change log in a temporary directory
+func newTestChangeLog(t *testing.T, path string, maxEvents int64) *ChangeLog {
+	t.Helper()
+
+	changeLog, err := OpenChangeLog(zap.NewNop(), path, maxEvents)
+	require.NoError(t, err)
+
+	return changeLog
+}
+
+// TestChangeLog_AppendAndReadAfter tests that appended events get increasing IDs and are read back in order
+func TestChangeLog_AppendAndReadAfter(t *testing.T) {
+	t.Parallel()
+
+	changeLog := newTestChangeLog(t, filepath.Join(t.TempDir(), "event-log"), 0)
+	defer changeLog.Close()
+
+	for i := int64(1); i <= 5; i++ {
+		event := &Event{Type: Created, UserID: i * 10}
+		require.NoError(t, changeLog.Append(event))
+		assert.Equal(t, i, event.ID)
+	}
+
+	assert.Equal(t, int64(5), changeLog.LastID())
+
+	events, err := changeLog.ReadAfter(1, 3)
+	require.NoError(t, err)
+	require.Len(t, events, 3)
+
+	for i, event := range events {
+		assert.Equal(t, int64(i+2), event.ID)
+		assert.Equal(t, int64(i+2)*10, event.UserID)
+		assert.Equal(t, Created, event.Type)
+	}
+
+	events, err = changeLog.ReadAfter(5, 3)
+	require.NoError(t, err)
+	assert.Empty(t, events)
+}
+
+// TestChangeLog_Reopen tests that IDs continue after the last event stored before reopening
+func TestChangeLog_Reopen(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "event-log")
+
+	changeLog := newTestChangeLog(t, path, 0)
+	require.NoError(t, changeLog.Append(&Event{Type: Created, UserID: 1}))
+	require.NoError(t, changeLog.Append(&Event{Type: Updated, UserID: 1}))
+	require.NoError(t, changeLog.Close())
+
+	changeLog = newTestChangeLog(t, path, 0)
+	defer changeLog.Close()
+
+	assert.Equal(t, int64(2), changeLog.LastID())
+
+	event := &Event{Type: Deleted, UserID: 1}
+	require.NoError(t, changeLog.Append(event))
+	assert.Equal(t, int64(3), event.ID)
+
+	events, err := changeLog.ReadAfter(0, 10)
+	require.NoError(t, err)
+	assert.Len(t, events, 3)
+}
+
+// TestChangeLog_AppendRelayed tests that the last relayed message ID is stored with the event and kept across reopening
+func TestChangeLog_AppendRelayed(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "event-log")
+
+	changeLog := newTestChangeLog(t, path, 0)
+	require.NoError(t, changeLog.AppendRelayed(&Event{Type: Created, UserID: 1}, 41))
+	require.NoError(t, changeLog.Append(&Event{Type: Updated, UserID: 1}))
+	assert.Equal(t, int64(41), changeLog.LastRelayedID())
+	require.NoError(t, changeLog.Close())
+
+	changeLog = newTestChangeLog(t, path, 0)
+	defer changeLog.Close()
+
+	assert.Equal(t, int64(2), changeLog.LastID())
+	assert.Equal(t, int64(41), changeLog.LastRelayedID())
+
+	// The stored message ID is not read back as an event
+	events, err := changeLog.ReadAfter(0, 10)
+	require.NoError(t, err)
+	assert.Len(t, events, 2)
+}
+
+// TestChangeLog_Trim tests that only the latest events are retained
+func TestChangeLog_Trim(t *testing.T) {
+	t.Parallel()
+
+	changeLog := newTestChangeLog(t, filepath.Join(t.TempDir(), "event-log"), 10)
+	defer changeLog.Close()
+
+	for i := 0; i < trimInterval; i++ {
+		require.NoError(t, changeLog.Append(&Event{Type: Created, UserID: int64(i)}))
+	}
+
+	events, err := changeLog.ReadAfter(0, trimInterval)
+	require.NoError(t, err)
+	require.Len(t, events, 10)
+	assert.Equal(t, int64(trimInterval-9), events[0].ID)
+}
+
+// TestChangeLog_ReadAfterClose tests that reading a closed change log fails instead of panicking
+func TestChangeLog_ReadAfterClose(t *testing.T) {
+	t.Parallel()
+
+	changeLog := newTestChangeLog(t, filepath.Join(t.TempDir(), "event-log"), 0)
+	require.NoError(t, changeLog.Close())
+
+	_, err := changeLog.ReadAfter(0, 10)
+	assert.ErrorIs(t, err, ErrStreamClosed)
+}
@@ -0,0 +1,96 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Aleksao998/LightningUserVault/core/common"
+	"go.uber.org/zap"
+)
+
+// Type is the kind of change an event describes
+type Type string
+
+const (
+	// Created is published when a new user is stored
+	Created Type = "created"
+
+	// Updated is published when an existing user is changed
+	Updated Type = "updated"
+
+	// Deleted is published when a user is deleted, either soft or permanently
+	Deleted Type = "deleted"
+
+	// Restored is published when a soft deleted user is brought back
+	Restored Type = "restored"
+)
+
+var (
+	// ErrStreamClosed is returned when publishing or reading after the stream was closed
+	ErrStreamClosed = errors.New("event stream is closed")
+
+	// ErrInvalidEventID is returned when an event ID to resume from can not be parsed
+	ErrInvalidEventID = errors.New("invalid event ID")
+)
+
+// Event describes a single committed change of a user
+type Event struct {
+	// ID is the position of the event in the change log, IDs are increasing and start at 1
+	ID int64 `json:"id"`
+	// Type is the kind of the change
+	Type Type `json:"type"`
+	// UserID is the ID of the changed user
+	UserID int64 `json:"user_id"`
+	// User is the user after the change, it is omitted for deleted users
+	User *common.User `json:"user,omitempty"`
+	// Time is the time the event was published
+	Time time.Time `json:"time"`
+}
+
+type Stream interface {
+	// Publish appends an event for the given user to the change log and delivers it to all subscribers
+	Publish(eventType Type, userID int64, user *common.User) (*Event, error)
+
+	// PublishRelayed publishes the event of the outbox message with the given ID and records the ID with the event.
+	// Messages which were published already are skipped and a nil event is returned
+	PublishRelayed(messageID int64, eventType Type, userID int64, user *common.User) (*Event, error)
+
+	// Subscribe returns a subscription receiving every event after the given event ID, 0 starts from the oldest retained event
+	Subscribe(lastEventID int64) (Subscription, error)
+
+	// Close ends all subscriptions and closes the change log
+	Close() error
+}
+
+type Subscription interface {
+	// Next blocks until the next event is available, the context is done or the stream is closed
+	Next(ctx context.Context) (*Event, error)
+
+	// Close releases the subscription
+	Close()
+}
+
+type Config struct {
+	LogPath   string
+	MaxEvents int64
+	Enabled   bool
+}
+
+// GetStream initializes and returns an event stream based on the provided configuration
+func GetStream(logger *zap.Logger, config Config) (Stream, error) {
+	if !config.Enabled {
+		logger.Debug("Events disabled")
+
+		return nil, nil
+	}
+
+	logger.Debug("Events enabled")
+
+	changeLog, err := OpenChangeLog(logger, config.LogPath, config.MaxEvents)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewBroker(logger, changeLog), nil
+}
@@ -0,0 +1,31 @@
+package events
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestGetStream_Disabled(t *testing.T) {
+	t.Parallel()
+
+	stream, err := GetStream(zap.NewNop(), Config{Enabled: false})
+	require.NoError(t, err)
+	assert.Nil(t, stream)
+}
+
+func TestGetStream_Enabled(t *testing.T) {
+	t.Parallel()
+
+	stream, err := GetStream(zap.NewNop(), Config{
+		LogPath: filepath.Join(t.TempDir(), "event-log"),
+		Enabled: true,
+	})
+	require.NoError(t, err)
+	require.NotNil(t, stream)
+
+	assert.NoError(t, stream.Close())
+}
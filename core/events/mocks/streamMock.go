@@ -0,0 +1,57 @@
+package mocks
+
+import (
+	"github.com/Aleksao998/LightningUserVault/core/common"
+	"github.com/Aleksao998/LightningUserVault/core/events"
+)
+
+type (
+	PublishDelegate        func(eventType events.Type, userID int64, user *common.User) (*events.Event, error)
+	PublishRelayedDelegate func(messageID int64, eventType events.Type, userID int64, user *common.User) (*events.Event, error)
+	SubscribeDelegate      func(lastEventID int64) (events.Subscription, error)
+	CloseDelegate          func() error
+)
+
+type MockStream struct {
+	PublishFn        PublishDelegate
+	PublishRelayedFn PublishRelayedDelegate
+	SubscribeFn      SubscribeDelegate
+	CloseFn          CloseDelegate
+}
+
+func (m *MockStream) Publish(eventType events.Type, userID int64, user *common.User) (*events.Event, error) {
+	if m.PublishFn != nil {
+		return m.PublishFn(eventType, userID, user)
+	}
+
+	return nil, nil
+}
+
+func (m *MockStream) PublishRelayed(
+	messageID int64,
+	eventType events.Type,
+	userID int64,
+	user *common.User,
+) (*events.Event, error) {
+	if m.PublishRelayedFn != nil {
+		return m.PublishRelayedFn(messageID, eventType, userID, user)
+	}
+
+	return nil, nil
+}
+
+func (m *MockStream) Subscribe(lastEventID int64) (events.Subscription, error) {
+	if m.SubscribeFn != nil {
+		return m.SubscribeFn(lastEventID)
+	}
+
+	return nil, nil
+}
+
+func (m *MockStream) Close() error {
+	if m.CloseFn != nil {
+		return m.CloseFn()
+	}
+
+	return nil
+}
@@ -0,0 +1,34 @@
+package mocks
+
+import (
+	"github.com/Aleksao998/LightningUserVault/core/outbox"
+)
+
+type (
+	DrainDelegate func(limit int, send func(messages []*outbox.Message) error) (int, error)
+	SendDelegate  func(messages []*outbox.Message) error
+)
+
+type MockSource struct {
+	DrainFn DrainDelegate
+}
+
+func (m *MockSource) Drain(limit int, send func(messages []*outbox.Message) error) (int, error) {
+	if m.DrainFn != nil {
+		return m.DrainFn(limit, send)
+	}
+
+	return 0, nil
+}
+
+type MockSink struct {
+	SendFn SendDelegate
+}
+
+func (m *MockSink) Send(messages []*outbox.Message) error {
+	if m.SendFn != nil {
+		return m.SendFn(messages)
+	}
+
+	return nil
+}
@@ -0,0 +1,72 @@
+package outbox
+
+import (
+	"errors"
+	"time"
+
+	"github.com/Aleksao998/LightningUserVault/core/events"
+	"go.uber.org/zap"
+)
+
+// ErrUnsupportedStorage is returned when the outbox is enabled on a storage which does not write one
+var ErrUnsupportedStorage = errors.New("outbox is not supported by the storage type")
+
+// Message is an event written by storage in the same transaction as the change it describes
+type Message struct {
+	// ID is the unique identifier of the message, increasing in commit order
+	ID int64 `json:"id"`
+	// Type is the kind of the change
+	Type events.Type `json:"type"`
+	// UserID is the ID of the changed user
+	UserID int64 `json:"user_id"`
+	// Payload is the JSON encoded user after the change
+	Payload []byte `json:"payload"`
+	// CreatedAt is the time the change was committed
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Source is a storage which records messages in an outbox
+type Source interface {
+	// Drain claims up to limit messages, oldest first, and passes them to send.
+	// The messages are removed only once send succeeds, otherwise they are claimed again by a later drain.
+	// It returns the number of drained messages
+	Drain(limit int, send func(messages []*Message) error) (int, error)
+}
+
+// Sink is the destination messages are relayed to
+type Sink interface {
+	// Send delivers the messages in order. A message is sent again if it is not removed from the outbox after a send,
+	// for example when a later message of the batch fails, so sinks which can not tolerate duplicates deduplicate by message ID
+	Send(messages []*Message) error
+}
+
+type Config struct {
+	// Interval is the time between two drains when the outbox is empty
+	Interval time.Duration
+
+	// BatchSize is the largest number of messages drained at once
+	BatchSize int
+
+	Enabled bool
+}
+
+// GetRelay initializes and starts a relay draining the outbox of the storage into the sink based on the provided configuration
+func GetRelay(logger *zap.Logger, config Config, vault interface{}, sink Sink) (*Relay, error) {
+	if !config.Enabled {
+		logger.Debug("Outbox disabled")
+
+		return nil, nil
+	}
+
+	logger.Debug("Outbox enabled")
+
+	source, ok := vault.(Source)
+	if !ok {
+		return nil, ErrUnsupportedStorage
+	}
+
+	relay := NewRelay(logger, source, sink, config)
+	relay.Start()
+
+	return relay, nil
+}
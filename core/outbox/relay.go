@@ -0,0 +1,118 @@
+package outbox
+
+import (
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// defaultInterval is the time between two drains of an empty outbox when the interval is not configured
+	defaultInterval = time.Second
+
+	// defaultBatchSize is the largest number of messages drained at once when the batch size is not configured
+	defaultBatchSize = 100
+)
+
+// Relay periodically drains the outbox of a storage into a sink
+type Relay struct {
+	source Source
+	sink   Sink
+	logger *zap.Logger
+	config Config
+
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewRelay creates a new Relay from the source to the sink, it does not run until started
+func NewRelay(logger *zap.Logger, source Source, sink Sink, config Config) *Relay {
+	if config.Interval <= 0 {
+		config.Interval = defaultInterval
+	}
+
+	if config.BatchSize <= 0 {
+		config.BatchSize = defaultBatchSize
+	}
+
+	return &Relay{
+		source: source,
+		sink:   sink,
+		logger: logger,
+		config: config,
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+}
+
+// Start runs drains in the background until the relay is closed
+func (r *Relay) Start() {
+	r.logger.Info("Starting outbox relay",
+		zap.Duration("interval", r.config.Interval),
+		zap.Int("batchSize", r.config.BatchSize),
+	)
+
+	go r.run()
+}
+
+// run drains the outbox until it is empty and then waits for the next tick
+func (r *Relay) run() {
+	defer close(r.done)
+
+	ticker := time.NewTicker(r.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		// Errors are logged and the drain is retried on the next tick
+		_, _ = r.Drain()
+
+		select {
+		case <-ticker.C:
+		case <-r.stop:
+			return
+		}
+	}
+}
+
+// Drain relays batches of messages until the outbox is empty or the relay is closed and returns how many were relayed
+func (r *Relay) Drain() (int, error) {
+	relayed := 0
+
+	for {
+		drained, err := r.source.Drain(r.config.BatchSize, r.sink.Send)
+		relayed += drained
+
+		if err != nil {
+			r.logger.Error("Failed to relay outbox messages", zap.Int("relayed", relayed), zap.Error(err))
+
+			return relayed, err
+		}
+
+		if drained < r.config.BatchSize {
+			break
+		}
+
+		select {
+		case <-r.stop:
+			return relayed, nil
+		default:
+		}
+	}
+
+	if relayed > 0 {
+		r.logger.Debug("Relayed outbox messages", zap.Int("count", relayed))
+	}
+
+	return relayed, nil
+}
+
+// Close stops the background drains and waits for a running drain to finish
+func (r *Relay) Close() {
+	r.stopOnce.Do(func() {
+		close(r.stop)
+	})
+
+	<-r.done
+}
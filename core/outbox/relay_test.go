@@ -0,0 +1,136 @@
+package outbox_test
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Aleksao998/LightningUserVault/core/outbox"
+	"github.com/Aleksao998/LightningUserVault/core/outbox/mocks"
+	storageMock "github.com/Aleksao998/LightningUserVault/core/storage/mocks"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+var errInternal = errors.New("internal error")
+
+// TestRelay_DrainUntilEmpty tests that full batches are drained until the outbox is empty
+func TestRelay_DrainUntilEmpty(t *testing.T) {
+	t.Parallel()
+
+	// The outbox holds 5 messages, drained in batches of 2
+	pending := []int64{1, 2, 3, 4, 5}
+	sent := make([]int64, 0)
+
+	source := &mocks.MockSource{
+		DrainFn: func(limit int, send func(messages []*outbox.Message) error) (int, error) {
+			batch := make([]*outbox.Message, 0, limit)
+			for _, id := range pending {
+				if len(batch) == limit {
+					break
+				}
+
+				batch = append(batch, &outbox.Message{ID: id})
+			}
+
+			if err := send(batch); err != nil {
+				return 0, err
+			}
+
+			pending = pending[len(batch):]
+
+			return len(batch), nil
+		},
+	}
+	sink := &mocks.MockSink{
+		SendFn: func(messages []*outbox.Message) error {
+			for _, message := range messages {
+				sent = append(sent, message.ID)
+			}
+
+			return nil
+		},
+	}
+
+	relay := outbox.NewRelay(zap.NewNop(), source, sink, outbox.Config{BatchSize: 2})
+
+	relayed, err := relay.Drain()
+	assert.NoError(t, err)
+	assert.Equal(t, 5, relayed)
+	assert.Equal(t, []int64{1, 2, 3, 4, 5}, sent)
+	assert.Empty(t, pending)
+}
+
+// TestRelay_DrainError tests that a failed drain stops relaying and returns the error
+func TestRelay_DrainError(t *testing.T) {
+	t.Parallel()
+
+	source := &mocks.MockSource{
+		DrainFn: func(limit int, send func(messages []*outbox.Message) error) (int, error) {
+			return 0, send([]*outbox.Message{{ID: 1}})
+		},
+	}
+	sink := &mocks.MockSink{
+		SendFn: func(messages []*outbox.Message) error {
+			return errInternal
+		},
+	}
+
+	relay := outbox.NewRelay(zap.NewNop(), source, sink, outbox.Config{})
+
+	relayed, err := relay.Drain()
+	assert.ErrorIs(t, err, errInternal)
+	assert.Equal(t, 0, relayed)
+}
+
+// TestRelay_StartAndClose tests that drains run periodically until the relay is closed
+func TestRelay_StartAndClose(t *testing.T) {
+	t.Parallel()
+
+	var calls int32
+
+	source := &mocks.MockSource{
+		DrainFn: func(limit int, send func(messages []*outbox.Message) error) (int, error) {
+			atomic.AddInt32(&calls, 1)
+
+			return 0, nil
+		},
+	}
+
+	relay := outbox.NewRelay(zap.NewNop(), source, &mocks.MockSink{}, outbox.Config{Interval: 10 * time.Millisecond})
+	relay.Start()
+
+	assert.Eventually(t, func() bool {
+		return atomic.LoadInt32(&calls) >= 3
+	}, time.Second, 5*time.Millisecond)
+
+	relay.Close()
+
+	// No drains run after close
+	stopped := atomic.LoadInt32(&calls)
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, stopped, atomic.LoadInt32(&calls))
+
+	// Closing again is a no-op
+	relay.Close()
+}
+
+// TestGetRelay tests that a relay is only created for storage writing an outbox
+func TestGetRelay(t *testing.T) {
+	t.Parallel()
+
+	relay, err := outbox.GetRelay(zap.NewNop(), outbox.Config{}, &mocks.MockSource{}, &mocks.MockSink{})
+	assert.NoError(t, err)
+	assert.Nil(t, relay)
+
+	relay, err = outbox.GetRelay(zap.NewNop(), outbox.Config{Enabled: true}, &storageMock.MockStorage{}, &mocks.MockSink{})
+	assert.ErrorIs(t, err, outbox.ErrUnsupportedStorage)
+	assert.Nil(t, relay)
+
+	relay, err = outbox.GetRelay(zap.NewNop(), outbox.Config{Enabled: true}, &mocks.MockSource{}, &mocks.MockSink{})
+	assert.NoError(t, err)
+	assert.NotNil(t, relay)
+
+	relay.Close()
+}
@@ -0,0 +1,66 @@
+package outbox
+
+import (
+	"encoding/json"
+
+	"github.com/Aleksao998/LightningUserVault/core/common"
+	"github.com/Aleksao998/LightningUserVault/core/events"
+	"go.uber.org/zap"
+)
+
+// StreamSink publishes messages to the event stream
+type StreamSink struct {
+	stream events.Stream
+}
+
+// NewStreamSink creates a sink publishing to the given stream
+func NewStreamSink(stream events.Stream) *StreamSink {
+	return &StreamSink{
+		stream: stream,
+	}
+}
+
+// Send publishes every message as an event of the changed user, stopping at the first failure.
+// The stream records the ID of every published message, so messages sent again after a failure are not published twice
+func (s *StreamSink) Send(messages []*Message) error {
+	for _, message := range messages {
+		var user *common.User
+		if len(message.Payload) > 0 {
+			user = &common.User{}
+			if err := json.Unmarshal(message.Payload, user); err != nil {
+				return err
+			}
+		}
+
+		if _, err := s.stream.PublishRelayed(message.ID, message.Type, message.UserID, user); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// LogSink logs messages, it is used when events are disabled
+type LogSink struct {
+	logger *zap.Logger
+}
+
+// NewLogSink creates a sink writing to the given logger
+func NewLogSink(logger *zap.Logger) *LogSink {
+	return &LogSink{
+		logger: logger,
+	}
+}
+
+// Send logs every message
+func (s *LogSink) Send(messages []*Message) error {
+	for _, message := range messages {
+		s.logger.Info("Outbox message",
+			zap.Int64("id", message.ID),
+			zap.String("type", string(message.Type)),
+			zap.Int64("userID", message.UserID),
+		)
+	}
+
+	return nil
+}
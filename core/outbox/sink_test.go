@@ -0,0 +1,73 @@
+package outbox_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/Aleksao998/LightningUserVault/core/common"
+	"github.com/Aleksao998/LightningUserVault/core/events"
+	eventsMock "github.com/Aleksao998/LightningUserVault/core/events/mocks"
+	"github.com/Aleksao998/LightningUserVault/core/outbox"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// TestStreamSink_Send tests that every message is published with its ID as an event of the decoded user
+func TestStreamSink_Send(t *testing.T) {
+	t.Parallel()
+
+	payload, err := json.Marshal(&common.User{ID: 1, Name: "User-1"})
+	require.NoError(t, err)
+
+	published := make([]*events.Event, 0)
+
+	stream := &eventsMock.MockStream{
+		PublishRelayedFn: func(messageID int64, eventType events.Type, userID int64, user *common.User) (*events.Event, error) {
+			event := &events.Event{ID: messageID, Type: eventType, UserID: userID, User: user}
+			published = append(published, event)
+
+			return event, nil
+		},
+	}
+
+	sink := outbox.NewStreamSink(stream)
+
+	err = sink.Send([]*outbox.Message{
+		{ID: 1, Type: events.Created, UserID: 1, Payload: payload},
+		{ID: 2, Type: events.Deleted, UserID: 2},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []*events.Event{
+		{ID: 1, Type: events.Created, UserID: 1, User: &common.User{ID: 1, Name: "User-1"}},
+		{ID: 2, Type: events.Deleted, UserID: 2},
+	}, published)
+}
+
+// TestStreamSink_SendError tests that sending stops at the first failed publish
+func TestStreamSink_SendError(t *testing.T) {
+	t.Parallel()
+
+	calls := 0
+
+	stream := &eventsMock.MockStream{
+		PublishRelayedFn: func(messageID int64, eventType events.Type, userID int64, user *common.User) (*events.Event, error) {
+			calls++
+
+			return nil, events.ErrStreamClosed
+		},
+	}
+
+	sink := outbox.NewStreamSink(stream)
+
+	err := sink.Send([]*outbox.Message{{ID: 1, Type: events.Created}, {ID: 2, Type: events.Created}})
+	assert.ErrorIs(t, err, events.ErrStreamClosed)
+	assert.Equal(t, 1, calls)
+}
+
+// TestLogSink_Send tests that the log sink accepts every message
+func TestLogSink_Send(t *testing.T) {
+	t.Parallel()
+
+	assert.NoError(t, outbox.NewLogSink(zap.NewNop()).Send([]*outbox.Message{{ID: 1, Type: events.Created}}))
+}
@@ -0,0 +1,274 @@
+package fulltext
+
+import (
+	"encoding/gob"
+	"errors"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/Aleksao998/LightningUserVault/core/common"
+	"go.uber.org/zap"
+)
+
+// Index is an in-memory inverted index over the searchable fields of users.
+// Terms are looked up through trigrams, which makes prefix, infix and typo-tolerant matching cheap
+type Index struct {
+	logger *zap.Logger
+	path   string
+
+	lock sync.RWMutex
+
+	// documents holds the indexed text of every user, it is the only state persisted in a snapshot
+	documents map[int64]string
+
+	// postings maps every term to the users containing it
+	postings map[string]map[int64]struct{}
+
+	// grams maps every gram to the terms containing it
+	grams map[string]map[string]struct{}
+}
+
+// NewIndex creates an empty index which is persisted to the given path on Close
+func NewIndex(logger *zap.Logger, path string) *Index {
+	return &Index{
+		logger:    logger,
+		path:      path,
+		documents: make(map[int64]string),
+		postings:  make(map[string]map[int64]struct{}),
+		grams:     make(map[string]map[string]struct{}),
+	}
+}
+
+// Load fills the index from the snapshot written by the last Close and reports whether a snapshot was found.
+// The snapshot is removed once loaded, so an index which is not closed cleanly gets rebuilt on the next start
+func (i *Index) Load() (bool, error) {
+	file, err := os.Open(i.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	documents := make(map[int64]string)
+
+	err = gob.NewDecoder(file).Decode(&documents)
+	file.Close()
+
+	if err != nil {
+		return false, err
+	}
+
+	i.lock.Lock()
+	for id, text := range documents {
+		i.add(id, text)
+	}
+	i.lock.Unlock()
+
+	i.logger.Debug("Search index loaded", zap.String("path", i.path), zap.Int("documents", len(documents)))
+
+	return true, os.Remove(i.path)
+}
+
+// Add indexes the given user, replacing previously indexed data of the same user
+func (i *Index) Add(user *common.User) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	i.remove(user.ID)
+	i.add(user.ID, documentText(user))
+}
+
+// Remove removes the user with the given ID from the index
+func (i *Index) Remove(id int64) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	i.remove(id)
+}
+
+// Search returns up to limit users matching every term of the query, best matches first
+func (i *Index) Search(query string, limit int) []int64 {
+	tokens := tokenize(query)
+	if len(tokens) == 0 || limit <= 0 {
+		return nil
+	}
+
+	i.lock.RLock()
+	defer i.lock.RUnlock()
+
+	var scores map[int64]float64
+
+	for _, token := range tokens {
+		tokenScores := i.matchToken(token)
+
+		if scores == nil {
+			scores = tokenScores
+
+			continue
+		}
+
+		// Users have to match all query terms
+		for id, score := range scores {
+			tokenScore, ok := tokenScores[id]
+			if !ok {
+				delete(scores, id)
+
+				continue
+			}
+
+			scores[id] = score + tokenScore
+		}
+	}
+
+	ids := make([]int64, 0, len(scores))
+	for id := range scores {
+		ids = append(ids, id)
+	}
+
+	sort.Slice(ids, func(a, b int) bool {
+		if scores[ids[a]] != scores[ids[b]] {
+			return scores[ids[a]] > scores[ids[b]]
+		}
+
+		return ids[a] < ids[b]
+	})
+
+	if len(ids) > limit {
+		ids = ids[:limit]
+	}
+
+	return ids
+}
+
+// Close persists the index snapshot
+func (i *Index) Close() error {
+	i.lock.RLock()
+	defer i.lock.RUnlock()
+
+	// Write to a temporary file first so a failed write never leaves a truncated snapshot behind
+	tmpPath := i.path + ".tmp"
+
+	file, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+
+	if err := gob.NewEncoder(file).Encode(i.documents); err != nil {
+		file.Close()
+
+		return err
+	}
+
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	i.logger.Debug("Search index saved", zap.String("path", i.path), zap.Int("documents", len(i.documents)))
+
+	return os.Rename(tmpPath, i.path)
+}
+
+// matchToken returns the best score of every user containing a term matching the token
+func (i *Index) matchToken(token string) map[int64]float64 {
+	scores := make(map[int64]float64)
+	checked := make(map[string]struct{})
+
+	for _, gram := range queryGrams(token) {
+		for term := range i.grams[gram] {
+			if _, ok := checked[term]; ok {
+				continue
+			}
+
+			checked[term] = struct{}{}
+
+			score := termScore(token, term)
+			if score == 0 {
+				continue
+			}
+
+			for id := range i.postings[term] {
+				if score > scores[id] {
+					scores[id] = score
+				}
+			}
+		}
+	}
+
+	return scores
+}
+
+func (i *Index) add(id int64, text string) {
+	i.documents[id] = text
+
+	for _, term := range tokenize(text) {
+		users, ok := i.postings[term]
+		if !ok {
+			users = make(map[int64]struct{})
+			i.postings[term] = users
+
+			for _, gram := range termGrams(term) {
+				terms, ok := i.grams[gram]
+				if !ok {
+					terms = make(map[string]struct{})
+					i.grams[gram] = terms
+				}
+
+				terms[term] = struct{}{}
+			}
+		}
+
+		users[id] = struct{}{}
+	}
+}
+
+func (i *Index) remove(id int64) {
+	text, ok := i.documents[id]
+	if !ok {
+		return
+	}
+
+	delete(i.documents, id)
+
+	for _, term := range tokenize(text) {
+		users := i.postings[term]
+		delete(users, id)
+
+		if len(users) > 0 {
+			continue
+		}
+
+		// Drop terms no longer used by any user
+		delete(i.postings, term)
+
+		for _, gram := range termGrams(term) {
+			delete(i.grams[gram], term)
+
+			if len(i.grams[gram]) == 0 {
+				delete(i.grams, gram)
+			}
+		}
+	}
+}
+
+// documentText returns the searchable text of a user, made of the name, email and attribute values
+func documentText(user *common.User) string {
+	fields := []string{user.Name, user.Email}
+
+	keys := make([]string, 0, len(user.Attributes))
+	for key := range user.Attributes {
+		keys = append(keys, key)
+	}
+
+	// Keep the text stable, so snapshots of the same data are identical
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		fields = append(fields, user.Attributes[key])
+	}
+
+	return strings.Join(fields, " ")
+}
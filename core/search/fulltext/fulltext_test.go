@@ -0,0 +1,163 @@
+package fulltext
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/Aleksao998/LightningUserVault/core/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// newTestIndex creates an index filled with the given names, user IDs start from 1
+func newTestIndex(t *testing.T, names ...string) *Index {
+	t.Helper()
+
+	index := NewIndex(zap.NewNop(), filepath.Join(t.TempDir(), "search-index"))
+
+	for i, name := range names {
+		index.Add(&common.User{ID: int64(i + 1), Name: name})
+	}
+
+	return index
+}
+
+func TestIndex_Search(t *testing.T) {
+	t.Parallel()
+
+	index := newTestIndex(t, "Alice Smith", "Alicia Keys", "Bob Smith", "Alexander", "Al")
+
+	tests := []struct {
+		name     string
+		query    string
+		expected []int64
+	}{
+		{"exact match ranks first", "alice", []int64{1, 2}},
+		{"prefix", "ali", []int64{1, 2}},
+		{"single rune prefix", "a", []int64{1, 2, 4, 5}},
+		{"infix", "xand", []int64{4}},
+		{"substitution typo", "alixe", []int64{1}},
+		{"transposition typo", "smtih", []int64{1, 3}},
+		{"misspelled prefix", "alxe", []int64{4}},
+		{"all terms must match", "smith bob", []int64{3}},
+		{"case and punctuation are ignored", "SMITH, Alice!", []int64{1}},
+		{"no match", "zed", []int64{}},
+		{"empty query", " ", nil},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, test.expected, index.Search(test.query, 10))
+		})
+	}
+}
+
+func TestIndex_SearchUserFields(t *testing.T) {
+	t.Parallel()
+
+	index := newTestIndex(t, "Alice")
+
+	index.Add(&common.User{
+		ID:         2,
+		Name:       "Bob",
+		Email:      "bob.builder@example.com",
+		Attributes: map[string]string{"city": "Belgrade", "team": "Platform"},
+	})
+
+	assert.Equal(t, []int64{2}, index.Search("builder", 10))
+	assert.Equal(t, []int64{2}, index.Search("belgrad platfrom", 10))
+}
+
+func TestIndex_SearchLimit(t *testing.T) {
+	t.Parallel()
+
+	index := newTestIndex(t, "Anna", "Anne", "Annie")
+
+	assert.Equal(t, []int64{1, 2}, index.Search("ann", 2))
+}
+
+func TestIndex_AddReplacesUser(t *testing.T) {
+	t.Parallel()
+
+	index := newTestIndex(t, "Alice")
+
+	index.Add(&common.User{ID: 1, Name: "Bob"})
+
+	assert.Empty(t, index.Search("alice", 10))
+	assert.Equal(t, []int64{1}, index.Search("bob", 10))
+	assert.NotContains(t, index.postings, "alice")
+}
+
+func TestIndex_Remove(t *testing.T) {
+	t.Parallel()
+
+	index := newTestIndex(t, "Alice", "Alice Cooper")
+
+	index.Remove(1)
+	assert.Equal(t, []int64{2}, index.Search("alice", 10))
+
+	index.Remove(2)
+	assert.Empty(t, index.Search("alice", 10))
+
+	// Unused terms and grams are dropped
+	assert.Empty(t, index.postings)
+	assert.Empty(t, index.grams)
+
+	// Removing an unknown user is a no-op
+	index.Remove(3)
+}
+
+func TestIndex_CloseAndLoad(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "search-index")
+
+	index := NewIndex(zap.NewNop(), path)
+
+	// Nothing to load before the first close
+	loaded, err := index.Load()
+	require.NoError(t, err)
+	assert.False(t, loaded)
+
+	index.Add(&common.User{ID: 1, Name: "Alice"})
+	index.Add(&common.User{ID: 2, Name: "Bob"})
+	require.NoError(t, index.Close())
+
+	reopened := NewIndex(zap.NewNop(), path)
+
+	loaded, err = reopened.Load()
+	require.NoError(t, err)
+	assert.True(t, loaded)
+	assert.Equal(t, []int64{1}, reopened.Search("alise", 10))
+
+	// The snapshot is consumed, so a crash before the next close forces a rebuild
+	loaded, err = NewIndex(zap.NewNop(), path).Load()
+	require.NoError(t, err)
+	assert.False(t, loaded)
+}
+
+func TestEditDistance(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		a, b     string
+		expected int
+	}{
+		{"", "", 0},
+		{"alice", "alice", 0},
+		{"alice", "alic", 1},
+		{"alice", "alixe", 1},
+		{"alice", "ailce", 1},
+		{"alice", "bob", 5},
+		{"", "bob", 3},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expected, editDistance([]rune(test.a), []rune(test.b)), "For %s and %s", test.a, test.b)
+	}
+}
@@ -0,0 +1,140 @@
+package fulltext
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// gramBoundary marks the start and the end of a term when building grams
+	gramBoundary = "$"
+
+	// gramSize is the number of runes in a gram
+	gramSize = 3
+)
+
+// tokenize splits text into lower case terms consisting of letters and digits
+func tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// termGrams returns all grams under which a term is indexed.
+// Besides trigrams of the bounded term, it includes short prefix grams so terms can be found by one or two runes
+func termGrams(term string) []string {
+	grams := trigrams(term)
+
+	runes := []rune(gramBoundary + term)
+	for i := 2; i < gramSize && i <= len(runes); i++ {
+		grams = append(grams, string(runes[:i]))
+	}
+
+	return grams
+}
+
+// queryGrams returns the grams used to look up candidate terms for a query token
+func queryGrams(token string) []string {
+	if utf8.RuneCountInString(token) < gramSize {
+		// Short tokens can only be matched as a prefix
+		return []string{gramBoundary + token}
+	}
+
+	return trigrams(token)
+}
+
+// trigrams returns the trigrams of the term surrounded by boundary markers
+func trigrams(term string) []string {
+	runes := []rune(gramBoundary + term + gramBoundary)
+	grams := make([]string, 0, len(runes))
+
+	for i := 0; i+gramSize <= len(runes); i++ {
+		grams = append(grams, string(runes[i:i+gramSize]))
+	}
+
+	return grams
+}
+
+// maxEdits returns the number of typos tolerated for a query token of the given length
+func maxEdits(length int) int {
+	switch {
+	case length < 4:
+		return 0
+	case length < 8:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// termScore rates how well an indexed term matches a query token, zero means no match
+func termScore(token, term string) float64 {
+	switch {
+	case term == token:
+		return 1
+	case strings.HasPrefix(term, token):
+		return 0.8
+	case strings.Contains(term, token):
+		return 0.6
+	}
+
+	tokenRunes, termRunes := []rune(token), []rune(term)
+	edits := maxEdits(len(tokenRunes))
+
+	if edits == 0 {
+		return 0
+	}
+
+	if distance := editDistance(tokenRunes, termRunes); distance <= edits {
+		return 0.7 - 0.2*float64(distance)
+	}
+
+	// The token can also be a misspelled prefix of the term
+	if len(termRunes) > len(tokenRunes) {
+		if distance := editDistance(tokenRunes, termRunes[:len(tokenRunes)]); distance <= edits {
+			return 0.5 - 0.2*float64(distance)
+		}
+	}
+
+	return 0
+}
+
+// editDistance returns the optimal string alignment distance between a and b,
+// counting insertions, deletions, substitutions and transpositions of adjacent runes
+func editDistance(a, b []rune) int {
+	rows := make([][]int, len(a)+1)
+	for i := range rows {
+		rows[i] = make([]int, len(b)+1)
+		rows[i][0] = i
+	}
+
+	for j := range rows[0] {
+		rows[0][j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+
+			rows[i][j] = minInt(minInt(rows[i-1][j]+1, rows[i][j-1]+1), rows[i-1][j-1]+cost)
+
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				rows[i][j] = minInt(rows[i][j], rows[i-2][j-2]+1)
+			}
+		}
+	}
+
+	return rows[len(a)][len(b)]
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+
+	return b
+}
@@ -0,0 +1,47 @@
+package mocks
+
+import (
+	"github.com/Aleksao998/LightningUserVault/core/common"
+)
+
+type (
+	AddDelegate    func(user *common.User)
+	RemoveDelegate func(id int64)
+	SearchDelegate func(query string, limit int) []int64
+	CloseDelegate  func() error
+)
+
+type MockIndex struct {
+	AddFn    AddDelegate
+	RemoveFn RemoveDelegate
+	SearchFn SearchDelegate
+	CloseFn  CloseDelegate
+}
+
+func (m *MockIndex) Add(user *common.User) {
+	if m.AddFn != nil {
+		m.AddFn(user)
+	}
+}
+
+func (m *MockIndex) Remove(id int64) {
+	if m.RemoveFn != nil {
+		m.RemoveFn(id)
+	}
+}
+
+func (m *MockIndex) Search(query string, limit int) []int64 {
+	if m.SearchFn != nil {
+		return m.SearchFn(query, limit)
+	}
+
+	return nil
+}
+
+func (m *MockIndex) Close() error {
+	if m.CloseFn != nil {
+		return m.CloseFn()
+	}
+
+	return nil
+}
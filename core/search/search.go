@@ -0,0 +1,83 @@
+package search
+
+import (
+	"github.com/Aleksao998/LightningUserVault/core/common"
+	"github.com/Aleksao998/LightningUserVault/core/search/fulltext"
+	"github.com/Aleksao998/LightningUserVault/core/storage"
+	"go.uber.org/zap"
+)
+
+// rebuildPageSize is the number of users read from storage at once while rebuilding the index
+const rebuildPageSize = 1000
+
+type Index interface {
+	// Add indexes the searchable fields of a user, replacing previously indexed data of the same user
+	Add(user *common.User)
+
+	// Remove removes the user with the given ID from the index
+	Remove(id int64)
+
+	// Search returns IDs of up to limit users matching the query, best matches first
+	Search(query string, limit int) []int64
+
+	// Close persists the index so it can be loaded on the next start
+	Close() error
+}
+
+type Config struct {
+	IndexPath string
+	Enabled   bool
+}
+
+// GetIndex initializes and returns a search index based on the provided configuration
+// The index is loaded from its snapshot if present, otherwise it is rebuilt from storage
+func GetIndex(logger *zap.Logger, config Config, vault storage.Storage) (Index, error) {
+	if !config.Enabled {
+		logger.Debug("Search disabled")
+
+		return nil, nil
+	}
+
+	logger.Debug("Search enabled")
+
+	index := fulltext.NewIndex(logger, config.IndexPath)
+
+	loaded, err := index.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	if loaded {
+		return index, nil
+	}
+
+	logger.Info("Search index snapshot not found, rebuilding from storage")
+
+	if err := Rebuild(index, vault); err != nil {
+		return nil, err
+	}
+
+	return index, nil
+}
+
+// Rebuild adds every user found in storage to the index
+func Rebuild(index Index, vault storage.Storage) error {
+	cursor := ""
+
+	for {
+		users, next, err := vault.List(cursor, rebuildPageSize)
+		if err != nil {
+			return err
+		}
+
+		for _, user := range users {
+			index.Add(user)
+		}
+
+		if next == "" {
+			return nil
+		}
+
+		cursor = next
+	}
+}
@@ -0,0 +1,110 @@
+package search
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/Aleksao998/LightningUserVault/core/common"
+	storageMock "github.com/Aleksao998/LightningUserVault/core/storage/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// newPagedStorage returns a storage mock listing the given users in pages of two
+func newPagedStorage(t *testing.T, users []*common.User) *storageMock.MockStorage {
+	t.Helper()
+
+	return &storageMock.MockStorage{
+		ListFn: func(cursor string, limit int) ([]*common.User, string, error) {
+			assert.Equal(t, rebuildPageSize, limit)
+
+			start := 0
+			if cursor != "" {
+				start = int(cursor[0] - '0')
+			}
+
+			end := start + 2
+			if end >= len(users) {
+				return users[start:], "", nil
+			}
+
+			return users[start:end], string(rune('0' + end)), nil
+		},
+	}
+}
+
+func TestGetIndex_Disabled(t *testing.T) {
+	t.Parallel()
+
+	index, err := GetIndex(zap.NewNop(), Config{Enabled: false}, &storageMock.MockStorage{})
+	require.NoError(t, err)
+	assert.Nil(t, index)
+}
+
+func TestGetIndex_RebuildsWithoutSnapshot(t *testing.T) {
+	t.Parallel()
+
+	users := []*common.User{
+		{ID: 1, Name: "Alice"},
+		{ID: 2, Name: "Bob"},
+		{ID: 3, Name: "Alicia"},
+		{ID: 4, Name: "Carol"},
+		{ID: 5, Name: "Alison"},
+	}
+
+	config := Config{
+		IndexPath: filepath.Join(t.TempDir(), "search-index"),
+		Enabled:   true,
+	}
+
+	index, err := GetIndex(zap.NewNop(), config, newPagedStorage(t, users))
+	require.NoError(t, err)
+
+	assert.Equal(t, []int64{1, 3, 5}, index.Search("ali", 10))
+	assert.Equal(t, []int64{4}, index.Search("carl", 10))
+}
+
+func TestGetIndex_LoadsSnapshot(t *testing.T) {
+	t.Parallel()
+
+	config := Config{
+		IndexPath: filepath.Join(t.TempDir(), "search-index"),
+		Enabled:   true,
+	}
+
+	index, err := GetIndex(zap.NewNop(), config, newPagedStorage(t, []*common.User{{ID: 1, Name: "Alice"}}))
+	require.NoError(t, err)
+	require.NoError(t, index.Close())
+
+	// The snapshot is used instead of storage
+	vault := &storageMock.MockStorage{
+		ListFn: func(cursor string, limit int) ([]*common.User, string, error) {
+			t.Fatal("storage should not be listed when a snapshot exists")
+
+			return nil, "", nil
+		},
+	}
+
+	index, err = GetIndex(zap.NewNop(), config, vault)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{1}, index.Search("alice", 10))
+}
+
+func TestRebuild_StorageError(t *testing.T) {
+	t.Parallel()
+
+	vault := &storageMock.MockStorage{
+		ListFn: func(cursor string, limit int) ([]*common.User, string, error) {
+			return nil, "", common.ErrInvalidCursor
+		},
+	}
+
+	config := Config{
+		IndexPath: filepath.Join(t.TempDir(), "search-index"),
+		Enabled:   true,
+	}
+
+	_, err := GetIndex(zap.NewNop(), config, vault)
+	assert.ErrorIs(t, err, common.ErrInvalidCursor)
+}
@@ -2,6 +2,7 @@ package server
 
 import (
 	"net"
+	"time"
 
 	"github.com/Aleksao998/LightningUserVault/core/command/server/types"
 	"go.uber.org/zap/zapcore"
@@ -18,13 +19,58 @@ type Config struct {
 	// EnableCache is a flag which represents if cache mechanism is enabled
 	EnableCache bool
 
-	// CacheType is a cache type [MEMCACHE]
+	// CacheType is a cache type [MEMCACHE, REDIS, INMEMORY]
 	CacheType types.CacheType
 
 	// MemcacheAddress is an address of memcache server
 	MemcacheAddress *net.TCPAddr
 
-	// StorageType is a cache type [PEBBLE, POSTRESQL]
+	// RedisAddress is an address of redis server
+	RedisAddress string
+
+	// RedisSentinels are addresses of redis sentinels, the redis address is not used when they are set
+	RedisSentinels []string
+
+	// RedisMasterName is a name of the redis master monitored by the sentinels
+	RedisMasterName string
+
+	// RedisPassword is a password for redis server
+	RedisPassword string
+
+	// RedisTLS is a flag which represents if redis connections use TLS
+	RedisTLS bool
+
+	// RedisKeyPrefix is a prefix of all keys stored in redis
+	RedisKeyPrefix string
+
+	// CacheTTL is how long cached users are kept, zero keeps them until evicted
+	CacheTTL time.Duration
+
+	// CacheTTLJitter is the upper bound of a random duration added to the cache time to live
+	CacheTTLJitter time.Duration
+
+	// CacheNegativeTTL is how long users which do not exist are remembered by the cache, zero disables negative caching
+	CacheNegativeTTL time.Duration
+
+	// CacheMaxEntries is the maximum number of users kept by the in-memory cache
+	CacheMaxEntries int
+
+	// CacheMaxBytes is the maximum size in bytes of users kept by the in-memory cache
+	CacheMaxBytes int64
+
+	// EnableNearCache is a flag which represents if a local cache is kept in front of the MEMCACHE or REDIS cache
+	EnableNearCache bool
+
+	// NearCacheTTL is how long users are kept in the local near cache
+	NearCacheTTL time.Duration
+
+	// NearCacheMaxEntries is the maximum number of users kept in the local near cache
+	NearCacheMaxEntries int
+
+	// CacheEarlyRefresh is the probability of a user served from cache being refreshed in the background
+	CacheEarlyRefresh float64
+
+	// StorageType is a storage type [PEBBLE, POSTGRESQL, MEMORY, SQLITE, MYSQL, BOLT]
 	StorageType types.StorageType
 
 	// DBHost is an address of database host
@@ -38,4 +84,55 @@ type Config struct {
 
 	// DBName is a name of database
 	DBName string
+
+	// DBPath is a path of the SQLite or bolt database file
+	DBPath string
+
+	// DBCharset is a character set of the MySQL database
+	DBCharset string
+
+	// DBCollation is a collation of the MySQL database
+	DBCollation string
+
+	// EnableSearch is a flag which represents if full-text search is enabled
+	EnableSearch bool
+
+	// SearchIndexPath is a path of the search index snapshot
+	SearchIndexPath string
+
+	// EnableSoftDelete is a flag which represents if deleted users are kept until purged
+	EnableSoftDelete bool
+
+	// SoftDeleteRetention is how long soft deleted users are kept before they are purged
+	SoftDeleteRetention time.Duration
+
+	// EnableEvents is a flag which represents if user changes are published as events
+	EnableEvents bool
+
+	// EventLogPath is a path of the persisted change log of events
+	EventLogPath string
+
+	// EnableWebhooks is a flag which represents if events are delivered to registered webhooks
+	EnableWebhooks bool
+
+	// WebhookMaxAttempts is the number of failed attempts after which a webhook delivery is dead
+	WebhookMaxAttempts int
+
+	// EnableOutbox is a flag which represents if created users are recorded in an outbox relayed to the event stream
+	EnableOutbox bool
+
+	// OutboxInterval is the time between two relays of an empty outbox
+	OutboxInterval time.Duration
+
+	// EnableAudit is a flag which represents if writes are recorded in the audit log
+	EnableAudit bool
+
+	// EnableVersions is a flag which represents if every version of a user is kept for point-in-time reads
+	EnableVersions bool
+
+	// VersionRetention is how long replaced user versions are kept before they are compacted
+	VersionRetention time.Duration
+
+	// VersionCompaction is the time between two compactions of user versions
+	VersionCompaction time.Duration
 }
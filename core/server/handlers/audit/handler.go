@@ -0,0 +1,165 @@
+package audithandler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Aleksao998/LightningUserVault/core/audit"
+	"github.com/Aleksao998/LightningUserVault/core/common"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const (
+	// defaultEntryLimit is the number of audit entries listed when the limit is not provided
+	defaultEntryLimit = 100
+
+	// maxEntryLimit is the largest number of audit entries listed at once
+	maxEntryLimit = 1000
+)
+
+var (
+	errInvalidUserID = errors.New("invalid user ID")
+	errInvalidLimit  = errors.New("invalid limit")
+	errInvalidSince  = errors.New("invalid since, expected an RFC 3339 time")
+	errAuditDisabled = errors.New("audit log is disabled")
+)
+
+type Config struct {
+	AuditEnabled bool
+}
+
+type AuditHandler struct {
+	audit  audit.Log
+	logger *zap.Logger
+	config Config
+}
+
+// NewAuditHandler creates a new AuditHandler with the given audit log
+func NewAuditHandler(logger *zap.Logger, auditLog audit.Log, config Config) *AuditHandler {
+	return &AuditHandler{
+		audit:  auditLog,
+		logger: logger,
+		config: config,
+	}
+}
+
+// @Summary Get user history
+// @Description Retrieve the recorded writes of a user with the actor, request ID and values before and after each write, newest first
+// @ID get-user-history
+// @Produce json
+// @Param id path int true "User ID"
+// @Param limit query int false "Maximum number of entries (1-1000)" default(100)
+// @Success 200 {object} audit.EntryList
+// @Failure 400 {object} common.ErrorResponse
+// @Failure 500 {object} common.ErrorResponse
+// @Failure 501 {object} common.ErrorResponse
+// @Router /user/{id}/history [get]
+func (h *AuditHandler) HistoryHandler(c *gin.Context) {
+	if !h.enabled(c) {
+		return
+	}
+
+	idStr := c.Param("id")
+
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		h.logger.Warn("Invalid user ID received", zap.String("id", idStr))
+		c.JSON(http.StatusBadRequest, common.ErrorResponse{Error: errInvalidUserID.Error()})
+
+		return
+	}
+
+	limit, ok := h.parseLimit(c)
+	if !ok {
+		return
+	}
+
+	entries, err := h.audit.History(id, limit)
+	if err != nil {
+		h.logger.Error("Failed to read user history", zap.Int64("id", id), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, common.ErrorResponse{Error: err.Error()})
+
+		return
+	}
+
+	h.logger.Info("Returning user history", zap.Int64("id", id), zap.Int("count", len(entries)))
+	c.JSON(http.StatusOK, audit.EntryList{Entries: entries})
+}
+
+// @Summary List audit entries
+// @Description Retrieve the recorded writes of all users after the given time, oldest first.
+// @Description Pass the time of the last returned entry as since to fetch the next page
+// @ID list-audit-entries
+// @Produce json
+// @Param since query string false "RFC 3339 time after which entries were recorded, all entries are listed when empty"
+// @Param limit query int false "Maximum number of entries (1-1000)" default(100)
+// @Success 200 {object} audit.EntryList
+// @Failure 400 {object} common.ErrorResponse
+// @Failure 500 {object} common.ErrorResponse
+// @Failure 501 {object} common.ErrorResponse
+// @Router /admin/audit [get]
+func (h *AuditHandler) ListHandler(c *gin.Context) {
+	if !h.enabled(c) {
+		return
+	}
+
+	var since time.Time
+
+	if sinceStr := c.Query("since"); sinceStr != "" {
+		var err error
+
+		since, err = time.Parse(time.RFC3339Nano, sinceStr)
+		if err != nil {
+			h.logger.Warn("Invalid audit since received", zap.String("since", sinceStr))
+			c.JSON(http.StatusBadRequest, common.ErrorResponse{Error: errInvalidSince.Error()})
+
+			return
+		}
+	}
+
+	limit, ok := h.parseLimit(c)
+	if !ok {
+		return
+	}
+
+	entries, err := h.audit.Since(since, limit)
+	if err != nil {
+		h.logger.Error("Failed to list audit entries", zap.Time("since", since), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, common.ErrorResponse{Error: err.Error()})
+
+		return
+	}
+
+	h.logger.Info("Returning audit entries", zap.Time("since", since), zap.Int("count", len(entries)))
+	c.JSON(http.StatusOK, audit.EntryList{Entries: entries})
+}
+
+// enabled responds with 501 and returns false when the audit log is disabled
+func (h *AuditHandler) enabled(c *gin.Context) bool {
+	if h.config.AuditEnabled {
+		return true
+	}
+
+	h.logger.Warn("Audit log requested while disabled")
+	c.JSON(http.StatusNotImplemented, common.ErrorResponse{Error: errAuditDisabled.Error()})
+
+	return false
+}
+
+// parseLimit parses the "limit" query parameter, responding with 400 if it is invalid
+func (h *AuditHandler) parseLimit(c *gin.Context) (int, bool) {
+	limitStr := c.DefaultQuery("limit", strconv.Itoa(defaultEntryLimit))
+
+	limit, err := strconv.Atoi(limitStr)
+	if err != nil || limit < 1 || limit > maxEntryLimit {
+		h.logger.Warn("Invalid audit limit received", zap.String("limit", limitStr))
+		c.JSON(http.StatusBadRequest, common.ErrorResponse{Error: errInvalidLimit.Error()})
+
+		return 0, false
+	}
+
+	return limit, true
+}
@@ -0,0 +1,216 @@
+package audithandler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Aleksao998/LightningUserVault/core/audit"
+	auditMock "github.com/Aleksao998/LightningUserVault/core/audit/mocks"
+	"github.com/Aleksao998/LightningUserVault/core/common"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+var errInternal = errors.New("internal error")
+
+// serve runs the handler for a request with the given "id" parameter and returns the response
+func serve(handle gin.HandlerFunc, target, id string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, target, nil)
+
+	if id != "" {
+		c.Params = append(c.Params, gin.Param{Key: "id", Value: id})
+	}
+
+	handle(c)
+
+	return w
+}
+
+// decodeError returns the error message of an error response
+func decodeError(t *testing.T, w *httptest.ResponseRecorder) string {
+	t.Helper()
+
+	var jsonError common.ErrorResponse
+
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &jsonError))
+
+	return jsonError.Error
+}
+
+// TestAuditHandler_Disabled tests that every endpoint responds with 501 when the audit log is disabled
+func TestAuditHandler_Disabled(t *testing.T) {
+	t.Parallel()
+
+	handler := NewAuditHandler(zap.NewNop(), nil, Config{})
+
+	for _, handle := range []gin.HandlerFunc{handler.HistoryHandler, handler.ListHandler} {
+		w := serve(handle, "/admin/audit", "1")
+
+		assert.Equal(t, http.StatusNotImplemented, w.Code)
+		assert.Equal(t, errAuditDisabled.Error(), decodeError(t, w))
+	}
+}
+
+// TestAuditHandler_History tests reading the history of a user
+func TestAuditHandler_History(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name           string
+		target         string
+		id             string
+		historyErr     error
+		expectedStatus int
+		expectedLimit  int
+		expectedError  string
+	}{
+		{
+			name:           "Default limit",
+			target:         "/user/1/history",
+			id:             "1",
+			expectedStatus: http.StatusOK,
+			expectedLimit:  defaultEntryLimit,
+		},
+		{
+			name:           "Given limit",
+			target:         "/user/1/history?limit=5",
+			id:             "1",
+			expectedStatus: http.StatusOK,
+			expectedLimit:  5,
+		},
+		{
+			name:           "Invalid ID",
+			target:         "/user/abc/history",
+			id:             "abc",
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  errInvalidUserID.Error(),
+		},
+		{
+			name:           "Invalid limit",
+			target:         "/user/1/history?limit=0",
+			id:             "1",
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  errInvalidLimit.Error(),
+		},
+		{
+			name:           "Audit log error",
+			target:         "/user/1/history",
+			id:             "1",
+			historyErr:     errInternal,
+			expectedStatus: http.StatusInternalServerError,
+			expectedError:  errInternal.Error(),
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			entry := &audit.Entry{ID: 1, Actor: "alice", Operation: audit.Create, UserID: 1}
+			limit := 0
+
+			mockLog := &auditMock.MockLog{
+				HistoryFn: func(userID int64, l int) ([]*audit.Entry, error) {
+					limit = l
+
+					return []*audit.Entry{entry}, tc.historyErr
+				},
+			}
+
+			handler := NewAuditHandler(zap.NewNop(), mockLog, Config{AuditEnabled: true})
+
+			w := serve(handler.HistoryHandler, tc.target, tc.id)
+			assert.Equal(t, tc.expectedStatus, w.Code)
+
+			if tc.expectedError != "" {
+				assert.Equal(t, tc.expectedError, decodeError(t, w))
+
+				return
+			}
+
+			var list audit.EntryList
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+			assert.Equal(t, []*audit.Entry{entry}, list.Entries)
+			assert.Equal(t, tc.expectedLimit, limit)
+		})
+	}
+}
+
+// TestAuditHandler_List tests listing audit entries after a time
+func TestAuditHandler_List(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name           string
+		target         string
+		expectedStatus int
+		expectedSince  time.Time
+		expectedError  string
+	}{
+		{
+			name:           "All entries",
+			target:         "/admin/audit",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Since time",
+			target:         "/admin/audit?since=2023-09-01T12:00:00.5Z&limit=10",
+			expectedStatus: http.StatusOK,
+			expectedSince:  time.Date(2023, 9, 1, 12, 0, 0, 500000000, time.UTC),
+		},
+		{
+			name:           "Invalid since",
+			target:         "/admin/audit?since=yesterday",
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  errInvalidSince.Error(),
+		},
+		{
+			name:           "Invalid limit",
+			target:         "/admin/audit?limit=1001",
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  errInvalidLimit.Error(),
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var since time.Time
+
+			mockLog := &auditMock.MockLog{
+				SinceFn: func(s time.Time, limit int) ([]*audit.Entry, error) {
+					since = s
+
+					return []*audit.Entry{}, nil
+				},
+			}
+
+			handler := NewAuditHandler(zap.NewNop(), mockLog, Config{AuditEnabled: true})
+
+			w := serve(handler.ListHandler, tc.target, "")
+			assert.Equal(t, tc.expectedStatus, w.Code)
+
+			if tc.expectedError != "" {
+				assert.Equal(t, tc.expectedError, decodeError(t, w))
+
+				return
+			}
+
+			assert.True(t, tc.expectedSince.Equal(since))
+			assert.JSONEq(t, `{"entries":[]}`, w.Body.String())
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package userhandler
+
+import (
+	"github.com/Aleksao998/LightningUserVault/core/audit"
+	"github.com/Aleksao998/LightningUserVault/core/common"
+	"github.com/Aleksao998/LightningUserVault/core/server/middleware"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// actorHeader is the header identifying who makes a write, the client IP is recorded when it is missing
+const actorHeader = "X-Actor"
+
+// record adds a committed write of the user to the audit log,
+// failures are only logged since the write itself already succeeded
+func (h *UserHandler) record(c *gin.Context, operation audit.Operation, id int64, before, after *common.User) {
+	if !h.config.AuditEnabled {
+		return
+	}
+
+	actor := c.GetHeader(actorHeader)
+	if actor == "" {
+		actor = c.ClientIP()
+	}
+
+	entry := &audit.Entry{
+		Actor:     actor,
+		Operation: operation,
+		RequestID: c.GetHeader(middleware.RequestIDHeader),
+		UserID:    id,
+		Before:    before,
+		After:     after,
+	}
+
+	if err := h.audit.Record(entry); err != nil {
+		h.logger.Error("Failed to record audit entry", zap.String("operation", string(operation)), zap.Int64("id", id), zap.Error(err))
+	}
+}
@@ -0,0 +1,225 @@
+package userhandler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Aleksao998/LightningUserVault/core/audit"
+	auditMock "github.com/Aleksao998/LightningUserVault/core/audit/mocks"
+	"github.com/Aleksao998/LightningUserVault/core/common"
+	"github.com/Aleksao998/LightningUserVault/core/server/middleware"
+	storageMock "github.com/Aleksao998/LightningUserVault/core/storage/mocks"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+// TestUserHandler_RecordAudit tests that every successful write records an audit entry with the values before and after it
+func TestUserHandler_RecordAudit(t *testing.T) {
+	t.Parallel()
+
+	stored := &common.User{ID: 1, Name: "User-1", Version: 1}
+	deletedAt := time.Date(2023, 9, 1, 12, 0, 0, 0, time.UTC)
+	softDeleted := &common.User{ID: 1, Name: "User-1", Version: 2, DeletedAt: &deletedAt}
+
+	testCases := []struct {
+		name              string
+		method            string
+		body              string
+		handle            func(h *UserHandler) gin.HandlerFunc
+		replaced          *common.User
+		softDeleteEnabled bool
+		auditEnabled      bool
+		expectedEntries   []*audit.Entry
+	}{
+		{
+			name:         "Set",
+			method:       http.MethodPost,
+			body:         `{"name":"User-1"}`,
+			handle:       func(h *UserHandler) gin.HandlerFunc { return h.SetHandler },
+			auditEnabled: true,
+			expectedEntries: []*audit.Entry{
+				{Operation: audit.Create, UserID: 1, After: &common.User{ID: 1, Name: "User-1"}},
+			},
+		},
+		{
+			name:         "Set batch",
+			method:       http.MethodPost,
+			body:         `[{"name":"User-1"},{"name":"User-2"}]`,
+			handle:       func(h *UserHandler) gin.HandlerFunc { return h.SetBatchHandler },
+			auditEnabled: true,
+			expectedEntries: []*audit.Entry{
+				{Operation: audit.Create, UserID: 1, After: &common.User{ID: 1, Name: "User-1"}},
+				{Operation: audit.Create, UserID: 2, After: &common.User{ID: 2, Name: "User-2"}},
+			},
+		},
+		{
+			name:         "Update",
+			method:       http.MethodPut,
+			body:         `{"name":"User-2"}`,
+			handle:       func(h *UserHandler) gin.HandlerFunc { return h.UpdateHandler },
+			replaced:     stored,
+			auditEnabled: true,
+			expectedEntries: []*audit.Entry{
+				{Operation: audit.Update, UserID: 1, Before: stored, After: &common.User{ID: 1, Name: "User-2"}},
+			},
+		},
+		{
+			name:         "Delete",
+			method:       http.MethodDelete,
+			handle:       func(h *UserHandler) gin.HandlerFunc { return h.DeleteHandler },
+			replaced:     stored,
+			auditEnabled: true,
+			expectedEntries: []*audit.Entry{
+				{Operation: audit.Delete, UserID: 1, Before: stored},
+			},
+		},
+		{
+			name:         "Delete soft deleted user",
+			method:       http.MethodDelete,
+			handle:       func(h *UserHandler) gin.HandlerFunc { return h.DeleteHandler },
+			replaced:     softDeleted,
+			auditEnabled: true,
+			expectedEntries: []*audit.Entry{
+				{Operation: audit.Delete, UserID: 1, Before: softDeleted},
+			},
+		},
+		{
+			name:              "Soft delete",
+			method:            http.MethodDelete,
+			handle:            func(h *UserHandler) gin.HandlerFunc { return h.DeleteHandler },
+			replaced:          stored,
+			softDeleteEnabled: true,
+			auditEnabled:      true,
+			expectedEntries: []*audit.Entry{
+				{Operation: audit.SoftDelete, UserID: 1, Before: stored},
+			},
+		},
+		{
+			name:              "Restore",
+			method:            http.MethodPost,
+			handle:            func(h *UserHandler) gin.HandlerFunc { return h.RestoreHandler },
+			replaced:          softDeleted,
+			softDeleteEnabled: true,
+			auditEnabled:      true,
+			expectedEntries: []*audit.Entry{
+				{Operation: audit.Restore, UserID: 1, Before: softDeleted, After: &common.User{ID: 1, Name: "User-1"}},
+			},
+		},
+		{
+			name:            "Audit disabled",
+			method:          http.MethodPost,
+			body:            `{"name":"User-1"}`,
+			handle:          func(h *UserHandler) gin.HandlerFunc { return h.SetHandler },
+			expectedEntries: []*audit.Entry{},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			recorded := make([]*audit.Entry, 0)
+
+			// The values before a write are returned by the write itself, they are never read separately
+			mockStorage := &storageMock.MockStorage{
+				GetFn: func(key int64) (*common.User, error) {
+					t.Fatalf("user should not be read")
+
+					return nil, nil
+				},
+				UpdateFn: func(user *common.User, version int64) (*common.User, error) {
+					return tc.replaced, nil
+				},
+				DeleteFn: func(key int64, version int64) (*common.User, error) {
+					return tc.replaced, nil
+				},
+				SoftDeleteFn: func(key int64, version int64) (*common.User, error) {
+					return tc.replaced, nil
+				},
+				SetFn: func(user *common.User) (int64, error) {
+					return 1, nil
+				},
+				SetBatchFn: func(users []*common.User) ([]int64, error) {
+					return []int64{1, 2}, nil
+				},
+				RestoreFn: func(key int64) (*common.User, *common.User, error) {
+					return &common.User{ID: key, Name: "User-1"}, tc.replaced, nil
+				},
+			}
+			mockLog := &auditMock.MockLog{
+				RecordFn: func(entry *audit.Entry) error {
+					recorded = append(recorded, entry)
+
+					return nil
+				},
+			}
+
+			handlerConfig := Config{
+				SoftDeleteEnabled: tc.softDeleteEnabled,
+				AuditEnabled:      tc.auditEnabled,
+			}
+
+			// Create test handler
+			handler := NewUserHandler(zap.NewNop(), mockStorage, nil, nil, nil, mockLog, handlerConfig)
+
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(tc.method, "/user/1", strings.NewReader(tc.body))
+			c.Request.Header.Set(actorHeader, "alice")
+			c.Request.Header.Set(middleware.RequestIDHeader, "req-1")
+			c.Params = append(c.Params, gin.Param{Key: "id", Value: "1"})
+
+			tc.handle(handler)(c)
+
+			assert.Less(t, w.Code, http.StatusBadRequest)
+
+			for _, entry := range tc.expectedEntries {
+				entry.Actor = "alice"
+				entry.RequestID = "req-1"
+			}
+
+			assert.Equal(t, tc.expectedEntries, recorded)
+		})
+	}
+}
+
+// TestUserHandler_RecordAuditActor tests that the client IP is recorded when the actor header is missing
+// and that a failed record does not fail the already stored write
+func TestUserHandler_RecordAuditActor(t *testing.T) {
+	t.Parallel()
+
+	var recorded *audit.Entry
+
+	mockStorage := &storageMock.MockStorage{
+		SetFn: func(user *common.User) (int64, error) {
+			return 1, nil
+		},
+	}
+	mockLog := &auditMock.MockLog{
+		RecordFn: func(entry *audit.Entry) error {
+			recorded = entry
+
+			return errInternal
+		},
+	}
+
+	// Create test handler
+	handler := NewUserHandler(zap.NewNop(), mockStorage, nil, nil, nil, mockLog, Config{AuditEnabled: true})
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/user", strings.NewReader(`{"name":"User-1"}`))
+	c.Request.RemoteAddr = "10.0.0.1:1234"
+
+	handler.SetHandler(c)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "10.0.0.1", recorded.Actor)
+	assert.Empty(t, recorded.RequestID)
+}
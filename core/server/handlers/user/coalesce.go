@@ -0,0 +1,124 @@
+package userhandler
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/Aleksao998/LightningUserVault/core/common"
+	"go.uber.org/zap"
+)
+
+// loadUser reads the user with the given ID from the vault and stores the result in cache.
+// Concurrent loads of the same user are coalesced into a single vault read whose result is shared by all callers
+func (h *UserHandler) loadUser(id int64) (*common.User, error) {
+	// Only the caller which performs the read runs the function, the others wait for its result
+	leader := false
+
+	value, err, _ := h.loads.Do(strconv.FormatInt(id, 10), func() (interface{}, error) {
+		leader = true
+
+		return h.readUser(id)
+	})
+
+	if !leader {
+		h.logger.Debug("User read coalesced with a concurrent read", zap.Int64("id", id))
+		incCollapsedReads(h.logger)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	user, _ := value.(*common.User)
+
+	return user, nil
+}
+
+// readUser reads the user with the given ID from the vault and stores it in cache,
+// or records it as missing in cache if it does not exist
+func (h *UserHandler) readUser(id int64) (*common.User, error) {
+	h.writesLock.RLock()
+	writes := h.writes
+	h.writesLock.RUnlock()
+
+	user, err := h.vault.Get(id)
+
+	h.writesLock.RLock()
+	defer h.writesLock.RUnlock()
+
+	if h.writes != writes {
+		// A write happened during the read, its result may be older than the write and must not be cached
+		h.logger.Debug("User read overlapped a write, not caching it", zap.Int64("id", id))
+
+		return user, err
+	}
+
+	if err != nil {
+		if errors.Is(err, common.ErrUserNotFound) && h.config.CacheEnabled {
+			// Remember the missing user, so repeated lookups do not reach the vault
+			if err := h.cache.SetMissing(id); err != nil {
+				h.logger.Error("Failed to set missing user in cache", zap.Int64("id", id), zap.Error(err))
+			}
+		}
+
+		return nil, err
+	}
+
+	if h.config.CacheEnabled {
+		// Store the fetched user in cache
+		err = h.cache.Set(id, user)
+		if err != nil {
+			h.logger.Error("Failed to set user in cache", zap.Int64("id", id), zap.Error(err))
+		} else {
+			h.logger.Debug("User stored in cache", zap.Int64("id", id))
+		}
+	}
+
+	return user, nil
+}
+
+// readUsers reads the users with the given IDs from the vault and back-fills the cache with them,
+// unless a write happened during the read and they may be older than it
+func (h *UserHandler) readUsers(ids []int64) (map[int64]*common.User, error) {
+	h.writesLock.RLock()
+	writes := h.writes
+	h.writesLock.RUnlock()
+
+	users, err := h.vault.GetMulti(ids)
+	if err != nil || !h.config.CacheEnabled {
+		return users, err
+	}
+
+	h.writesLock.RLock()
+	defer h.writesLock.RUnlock()
+
+	if h.writes != writes {
+		h.logger.Debug("Users read overlapped a write, not caching them", zap.Int("ids", len(ids)))
+
+		return users, nil
+	}
+
+	for id, user := range users {
+		if err := h.cache.Set(id, user); err != nil {
+			h.logger.Error("Failed to set user in cache", zap.Int64("id", id), zap.Error(err))
+		}
+	}
+
+	return users, nil
+}
+
+// maybeRefresh reloads a user served from cache in the background with the configured probability,
+// so popular users are refreshed ahead of their expiry instead of all their readers missing at once
+func (h *UserHandler) maybeRefresh(id int64) {
+	if h.config.EarlyRefreshProbability <= 0 || h.random() >= h.config.EarlyRefreshProbability {
+		return
+	}
+
+	incEarlyRefreshes(h.logger)
+
+	go func() {
+		if _, err := h.loadUser(id); err != nil {
+			h.logger.Warn("Failed to refresh cached user", zap.Int64("id", id), zap.Error(err))
+		}
+	}()
+}
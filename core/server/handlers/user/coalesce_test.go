@@ -0,0 +1,310 @@
+package userhandler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Aleksao998/LightningUserVault/core/cache/lru"
+	cacheMock "github.com/Aleksao998/LightningUserVault/core/cache/mocks"
+	"github.com/Aleksao998/LightningUserVault/core/common"
+	"github.com/Aleksao998/LightningUserVault/core/storage/memory"
+	storageMock "github.com/Aleksao998/LightningUserVault/core/storage/mocks"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+// getUser calls the GetHandler for the user with the given ID and returns the response status
+func getUser(handler *UserHandler, id string) int {
+	w := httptest.NewRecorder()
+
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/user/"+id, nil)
+	c.Params = append(c.Params, gin.Param{Key: "id", Value: id})
+
+	handler.GetHandler(c)
+
+	return w.Code
+}
+
+// TestUserHandler_GetCoalescesReads tests that concurrent reads of the same user reach the database once
+func TestUserHandler_GetCoalescesReads(t *testing.T) {
+	t.Parallel()
+
+	var (
+		reads   int32
+		started = make(chan struct{})
+		release = make(chan struct{})
+	)
+
+	mockStorage := &storageMock.MockStorage{
+		GetFn: func(key int64) (*common.User, error) {
+			if atomic.AddInt32(&reads, 1) == 1 {
+				close(started)
+			}
+
+			<-release
+
+			return &common.User{ID: key, Name: "User-1"}, nil
+		},
+	}
+
+	var stored int32
+
+	mockCache := &cacheMock.MockCache{
+		GetFn: func(key int64) (*common.User, error) {
+			return nil, errUserNotInCache
+		},
+		SetFn: func(key int64, value *common.User) error {
+			atomic.AddInt32(&stored, 1)
+
+			return nil
+		},
+	}
+
+	handler := NewUserHandler(zap.NewNop(), mockStorage, mockCache, nil, nil, nil, Config{CacheEnabled: true})
+
+	var (
+		wg    sync.WaitGroup
+		codes = make([]int, 10)
+	)
+
+	for i := range codes {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			codes[i] = getUser(handler, "1")
+		}(i)
+	}
+
+	// Give the other requests time to join the read in flight
+	<-started
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+
+	wg.Wait()
+
+	for _, code := range codes {
+		assert.Equal(t, http.StatusOK, code)
+	}
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&reads))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&stored))
+
+	// Once the read completed, the next read of the user reaches the database again
+	release = make(chan struct{})
+	close(release)
+
+	assert.Equal(t, http.StatusOK, getUser(handler, "1"))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&reads))
+}
+
+// TestUserHandler_GetAfterUpdateDuringRead tests that an update landing while a read is in flight is seen by later reads,
+// the read in flight neither serves nor caches the replaced user for them
+func TestUserHandler_GetAfterUpdateDuringRead(t *testing.T) {
+	t.Parallel()
+
+	store := memory.NewStorage(zap.NewNop(), memory.Options{})
+	defer store.Close()
+
+	id, err := store.Set(&common.User{Name: "User-1"})
+	if err != nil {
+		t.Fatalf("Failed to set user: %v", err)
+	}
+
+	var (
+		reads   int32
+		started = make(chan struct{})
+		release = make(chan struct{})
+	)
+
+	mockStorage := &storageMock.MockStorage{
+		GetFn: func(key int64) (*common.User, error) {
+			user, err := store.Get(key)
+
+			// Hold the first read until the update is done
+			if atomic.AddInt32(&reads, 1) == 1 {
+				close(started)
+				<-release
+			}
+
+			return user, err
+		},
+		UpdateFn: store.Update,
+	}
+
+	handler := NewUserHandler(zap.NewNop(), mockStorage, lru.NewLRUCache(zap.NewNop(), lru.Options{}), nil, nil, nil, Config{
+		CacheEnabled: true,
+	})
+
+	done := make(chan int)
+
+	go func() {
+		done <- getUser(handler, strconv.FormatInt(id, 10))
+	}()
+
+	<-started
+
+	w := httptest.NewRecorder()
+
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPut, "/user/1", strings.NewReader(`{"Name": "Updated"}`))
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Params = append(c.Params, gin.Param{Key: "id", Value: strconv.FormatInt(id, 10)})
+
+	handler.UpdateHandler(c)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// A read starting after the update does not join the read in flight
+	names := make(chan string, 1)
+
+	go func() {
+		names <- getUserName(t, handler, id)
+	}()
+
+	select {
+	case name := <-names:
+		assert.Equal(t, "Updated", name)
+	case <-time.After(time.Second):
+		close(release)
+		t.Fatal("Read after the update joined the read in flight")
+	}
+
+	close(release)
+	assert.Equal(t, http.StatusOK, <-done)
+
+	// The read in flight did not cache the replaced user
+	assert.Equal(t, "Updated", getUserName(t, handler, id))
+}
+
+// TestUserHandler_GetMultiAfterUpdateDuringRead tests that users read by a multi-get overlapping an update are not cached,
+// so later reads see the update
+func TestUserHandler_GetMultiAfterUpdateDuringRead(t *testing.T) {
+	t.Parallel()
+
+	store := memory.NewStorage(zap.NewNop(), memory.Options{})
+	defer store.Close()
+
+	id, err := store.Set(&common.User{Name: "User-1"})
+	if err != nil {
+		t.Fatalf("Failed to set user: %v", err)
+	}
+
+	var handler *UserHandler
+
+	mockStorage := &storageMock.MockStorage{
+		GetFn: store.Get,
+		GetMultiFn: func(keys []int64) (map[int64]*common.User, error) {
+			users, err := store.GetMulti(keys)
+
+			// Update the user after it was read, before the read completes
+			w := httptest.NewRecorder()
+
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodPut, "/user/1", strings.NewReader(`{"Name": "Updated"}`))
+			c.Request.Header.Set("Content-Type", "application/json")
+			c.Params = append(c.Params, gin.Param{Key: "id", Value: strconv.FormatInt(id, 10)})
+
+			handler.UpdateHandler(c)
+			assert.Equal(t, http.StatusOK, w.Code)
+
+			return users, err
+		},
+		UpdateFn: store.Update,
+	}
+
+	handler = NewUserHandler(zap.NewNop(), mockStorage, lru.NewLRUCache(zap.NewNop(), lru.Options{}), nil, nil, nil, Config{
+		CacheEnabled: true,
+	})
+
+	w := httptest.NewRecorder()
+
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/users?ids="+strconv.FormatInt(id, 10), nil)
+
+	handler.GetMultiHandler(c)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// The multi-get did not cache the replaced user
+	assert.Equal(t, "Updated", getUserName(t, handler, id))
+}
+
+// getUserName calls the GetHandler for the user with the given ID and returns the name in the response
+func getUserName(t *testing.T, handler *UserHandler, id int64) string {
+	t.Helper()
+
+	w := httptest.NewRecorder()
+
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/user/"+strconv.FormatInt(id, 10), nil)
+	c.Params = append(c.Params, gin.Param{Key: "id", Value: strconv.FormatInt(id, 10)})
+
+	handler.GetHandler(c)
+
+	var user common.User
+	if err := json.Unmarshal(w.Body.Bytes(), &user); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+
+	return user.Name
+}
+
+// TestUserHandler_GetEarlyRefresh tests that users served from cache are refreshed in the background with the configured probability
+func TestUserHandler_GetEarlyRefresh(t *testing.T) {
+	t.Parallel()
+
+	refreshed := make(chan *common.User, 1)
+
+	mockStorage := &storageMock.MockStorage{
+		GetFn: func(key int64) (*common.User, error) {
+			return &common.User{ID: key, Name: "Updated", Version: 2}, nil
+		},
+	}
+	mockCache := &cacheMock.MockCache{
+		GetFn: func(key int64) (*common.User, error) {
+			return &common.User{ID: key, Name: "User-1", Version: 1}, nil
+		},
+		SetFn: func(key int64, value *common.User) error {
+			refreshed <- value
+
+			return nil
+		},
+	}
+
+	handler := NewUserHandler(zap.NewNop(), mockStorage, mockCache, nil, nil, nil, Config{
+		CacheEnabled:            true,
+		EarlyRefreshProbability: 0.5,
+	})
+
+	// Above the probability the cached user is only served
+	handler.random = func() float64 { return 0.5 }
+
+	assert.Equal(t, http.StatusOK, getUser(handler, "1"))
+
+	select {
+	case <-refreshed:
+		t.Fatal("Unexpected refresh of the cached user")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// Below the probability the cached user is also reloaded from the database
+	handler.random = func() float64 { return 0.4 }
+
+	assert.Equal(t, http.StatusOK, getUser(handler, "1"))
+
+	select {
+	case user := <-refreshed:
+		assert.Equal(t, "Updated", user.Name)
+	case <-time.After(time.Second):
+		t.Fatal("Cached user was not refreshed")
+	}
+}
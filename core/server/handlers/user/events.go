@@ -0,0 +1,124 @@
+package userhandler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Aleksao998/LightningUserVault/core/common"
+	"github.com/Aleksao998/LightningUserVault/core/events"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// keepAliveInterval is the time without events after which a comment is sent, so idle connections are not dropped by proxies
+const keepAliveInterval = 15 * time.Second
+
+// @Summary Stream user changes
+// @Description Stream created, updated, deleted and restored user events as server-sent events.
+// @Description Every event carries its ID, reconnecting clients send the last received one in Last-Event-ID
+// @Description to resume without missing events that are still retained in the change log
+// @ID user-events
+// @Produce text/event-stream
+// @Param Last-Event-ID header int false "ID of the last event received by the client"
+// @Success 200 {object} events.Event
+// @Failure 400 {object} common.ErrorResponse
+// @Failure 500 {object} common.ErrorResponse
+// @Failure 501 {object} common.ErrorResponse
+// @Router /user/events [get]
+func (h *UserHandler) EventsHandler(c *gin.Context) {
+	if !h.config.EventsEnabled {
+		h.logger.Warn("Events requested while disabled")
+		c.JSON(http.StatusNotImplemented, common.ErrorResponse{Error: errEventsDisabled.Error()})
+
+		return
+	}
+
+	lastEventID := int64(0)
+
+	if header := strings.TrimSpace(c.GetHeader("Last-Event-ID")); header != "" {
+		id, err := strconv.ParseInt(header, 10, 64)
+		if err != nil || id < 0 {
+			h.logger.Warn("Invalid Last-Event-ID received", zap.String("lastEventID", header))
+			c.JSON(http.StatusBadRequest, common.ErrorResponse{Error: errInvalidLastEventID.Error()})
+
+			return
+		}
+
+		lastEventID = id
+	}
+
+	sub, err := h.stream.Subscribe(lastEventID)
+	if err != nil {
+		h.logger.Error("Failed to subscribe to events", zap.Int64("lastEventID", lastEventID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, common.ErrorResponse{Error: err.Error()})
+
+		return
+	}
+	defer sub.Close()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	h.logger.Info("Streaming events", zap.Int64("lastEventID", lastEventID))
+
+	for {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), keepAliveInterval)
+		event, err := sub.Next(ctx)
+		cancel()
+
+		switch {
+		case err == nil:
+			err = writeEvent(c, event)
+		case errors.Is(err, context.DeadlineExceeded) && c.Request.Context().Err() == nil:
+			_, err = fmt.Fprint(c.Writer, ": keep-alive\n\n")
+		}
+
+		if err != nil {
+			// The client went away or the stream was closed, the response can not carry an error anymore
+			h.logger.Debug("Stopped streaming events", zap.Error(err))
+
+			return
+		}
+
+		c.Writer.Flush()
+	}
+}
+
+// writeEvent writes the event in the server-sent events format, using the event type as the event name
+func writeEvent(c *gin.Context, event *events.Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(c.Writer, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+
+	return err
+}
+
+// publish announces a committed change of the user to the event stream,
+// failures are only logged since the change itself already succeeded
+func (h *UserHandler) publish(eventType events.Type, id int64, user *common.User) {
+	if !h.config.EventsEnabled {
+		return
+	}
+
+	// Created users are committed together with their event in the outbox, which is relayed to the stream
+	if eventType == events.Created && h.config.OutboxEnabled {
+		return
+	}
+
+	if _, err := h.stream.Publish(eventType, id, user); err != nil {
+		h.logger.Error("Failed to publish user event", zap.String("type", string(eventType)), zap.Int64("id", id), zap.Error(err))
+	}
+}
@@ -0,0 +1,384 @@
+package userhandler
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Aleksao998/LightningUserVault/core/common"
+	"github.com/Aleksao998/LightningUserVault/core/events"
+	eventsMock "github.com/Aleksao998/LightningUserVault/core/events/mocks"
+	storageMock "github.com/Aleksao998/LightningUserVault/core/storage/mocks"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// publishedEvent is an event received by the stream mock
+type publishedEvent struct {
+	eventType events.Type
+	userID    int64
+	user      *common.User
+}
+
+// TestUserHandler_PublishEvents tests that every successful write publishes an event of the matching type
+func TestUserHandler_PublishEvents(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name              string
+		method            string
+		body              string
+		handle            func(h *UserHandler) gin.HandlerFunc
+		softDeleteEnabled bool
+		eventsEnabled     bool
+		outboxEnabled     bool
+		expectedEvents    []publishedEvent
+	}{
+		{
+			name:          "Set",
+			method:        http.MethodPost,
+			body:          `{"name":"User-1"}`,
+			handle:        func(h *UserHandler) gin.HandlerFunc { return h.SetHandler },
+			eventsEnabled: true,
+			expectedEvents: []publishedEvent{
+				{eventType: events.Created, userID: 1, user: &common.User{ID: 1, Name: "User-1"}},
+			},
+		},
+		{
+			name:          "Set batch",
+			method:        http.MethodPost,
+			body:          `[{"name":"User-1"},{"name":"User-2"}]`,
+			handle:        func(h *UserHandler) gin.HandlerFunc { return h.SetBatchHandler },
+			eventsEnabled: true,
+			expectedEvents: []publishedEvent{
+				{eventType: events.Created, userID: 1, user: &common.User{ID: 1, Name: "User-1"}},
+				{eventType: events.Created, userID: 2, user: &common.User{ID: 2, Name: "User-2"}},
+			},
+		},
+		{
+			name:          "Update",
+			method:        http.MethodPut,
+			body:          `{"name":"User-2"}`,
+			handle:        func(h *UserHandler) gin.HandlerFunc { return h.UpdateHandler },
+			eventsEnabled: true,
+			expectedEvents: []publishedEvent{
+				{eventType: events.Updated, userID: 1, user: &common.User{ID: 1, Name: "User-2"}},
+			},
+		},
+		{
+			name:          "Delete",
+			method:        http.MethodDelete,
+			handle:        func(h *UserHandler) gin.HandlerFunc { return h.DeleteHandler },
+			eventsEnabled: true,
+			expectedEvents: []publishedEvent{
+				{eventType: events.Deleted, userID: 1},
+			},
+		},
+		{
+			name:              "Soft delete",
+			method:            http.MethodDelete,
+			handle:            func(h *UserHandler) gin.HandlerFunc { return h.DeleteHandler },
+			softDeleteEnabled: true,
+			eventsEnabled:     true,
+			expectedEvents: []publishedEvent{
+				{eventType: events.Deleted, userID: 1},
+			},
+		},
+		{
+			name:              "Restore",
+			method:            http.MethodPost,
+			handle:            func(h *UserHandler) gin.HandlerFunc { return h.RestoreHandler },
+			softDeleteEnabled: true,
+			eventsEnabled:     true,
+			expectedEvents: []publishedEvent{
+				{eventType: events.Restored, userID: 1, user: &common.User{ID: 1, Name: "User-1"}},
+			},
+		},
+		{
+			name:           "Set with outbox",
+			method:         http.MethodPost,
+			body:           `{"name":"User-1"}`,
+			handle:         func(h *UserHandler) gin.HandlerFunc { return h.SetHandler },
+			eventsEnabled:  true,
+			outboxEnabled:  true,
+			expectedEvents: []publishedEvent{},
+		},
+		{
+			name:          "Update with outbox",
+			method:        http.MethodPut,
+			body:          `{"name":"User-2"}`,
+			handle:        func(h *UserHandler) gin.HandlerFunc { return h.UpdateHandler },
+			eventsEnabled: true,
+			outboxEnabled: true,
+			expectedEvents: []publishedEvent{
+				{eventType: events.Updated, userID: 1, user: &common.User{ID: 1, Name: "User-2"}},
+			},
+		},
+		{
+			name:           "Events disabled",
+			method:         http.MethodPost,
+			body:           `{"name":"User-1"}`,
+			handle:         func(h *UserHandler) gin.HandlerFunc { return h.SetHandler },
+			expectedEvents: []publishedEvent{},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			published := make([]publishedEvent, 0)
+
+			mockStorage := &storageMock.MockStorage{
+				SetFn: func(user *common.User) (int64, error) {
+					return 1, nil
+				},
+				SetBatchFn: func(users []*common.User) ([]int64, error) {
+					return []int64{1, 2}, nil
+				},
+				RestoreFn: func(key int64) (*common.User, *common.User, error) {
+					return &common.User{ID: key, Name: "User-1"}, nil, nil
+				},
+			}
+			mockStream := &eventsMock.MockStream{
+				PublishFn: func(eventType events.Type, userID int64, user *common.User) (*events.Event, error) {
+					published = append(published, publishedEvent{eventType: eventType, userID: userID, user: user})
+
+					return &events.Event{}, nil
+				},
+			}
+
+			handlerConfig := Config{
+				SoftDeleteEnabled: tc.softDeleteEnabled,
+				EventsEnabled:     tc.eventsEnabled,
+				OutboxEnabled:     tc.outboxEnabled,
+			}
+
+			// Create test handler
+			handler := NewUserHandler(zap.NewNop(), mockStorage, nil, nil, mockStream, nil, handlerConfig)
+
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(tc.method, "/user/1", strings.NewReader(tc.body))
+			c.Params = append(c.Params, gin.Param{Key: "id", Value: "1"})
+
+			tc.handle(handler)(c)
+
+			assert.Less(t, w.Code, http.StatusBadRequest)
+			assert.Equal(t, tc.expectedEvents, published)
+		})
+	}
+}
+
+// TestUserHandler_PublishFailure tests that a failed publish does not fail the already stored write
+func TestUserHandler_PublishFailure(t *testing.T) {
+	t.Parallel()
+
+	mockStorage := &storageMock.MockStorage{
+		SetFn: func(user *common.User) (int64, error) {
+			return 1, nil
+		},
+	}
+	mockStream := &eventsMock.MockStream{
+		PublishFn: func(eventType events.Type, userID int64, user *common.User) (*events.Event, error) {
+			return nil, errInternal
+		},
+	}
+
+	// Create test handler
+	handler := NewUserHandler(zap.NewNop(), mockStorage, nil, nil, mockStream, nil, Config{EventsEnabled: true})
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/user", strings.NewReader(`{"name":"User-1"}`))
+
+	handler.SetHandler(c)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+// TestUserHandler_EventsWithInvalidParams tests that an event stream is only opened for valid requests
+func TestUserHandler_EventsWithInvalidParams(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name           string
+		lastEventID    string
+		eventsEnabled  bool
+		subscribeErr   error
+		expectedStatus int
+		expectedError  string
+	}{
+		{
+			name:           "Events disabled",
+			expectedStatus: http.StatusNotImplemented,
+			expectedError:  errEventsDisabled.Error(),
+		},
+		{
+			name:           "Invalid Last-Event-ID",
+			lastEventID:    "abc",
+			eventsEnabled:  true,
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  errInvalidLastEventID.Error(),
+		},
+		{
+			name:           "Negative Last-Event-ID",
+			lastEventID:    "-1",
+			eventsEnabled:  true,
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  errInvalidLastEventID.Error(),
+		},
+		{
+			name:           "Subscribe error",
+			eventsEnabled:  true,
+			subscribeErr:   events.ErrStreamClosed,
+			expectedStatus: http.StatusInternalServerError,
+			expectedError:  events.ErrStreamClosed.Error(),
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			mockStream := &eventsMock.MockStream{
+				SubscribeFn: func(lastEventID int64) (events.Subscription, error) {
+					return nil, tc.subscribeErr
+				},
+			}
+
+			// Create test handler
+			handler := NewUserHandler(zap.NewNop(), nil, nil, nil, mockStream, nil, Config{EventsEnabled: tc.eventsEnabled})
+
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodGet, "/user/events", nil)
+
+			if tc.lastEventID != "" {
+				c.Request.Header.Set("Last-Event-ID", tc.lastEventID)
+			}
+
+			handler.EventsHandler(c)
+
+			assert.Equal(t, tc.expectedStatus, w.Code)
+
+			var jsonError common.ErrorResponse
+
+			err := json.Unmarshal(w.Body.Bytes(), &jsonError)
+			if err != nil {
+				t.Fatalf("Failed to unmarshal response: %v", err)
+			}
+
+			assert.Equal(t, tc.expectedError, jsonError.Error)
+		})
+	}
+}
+
+// TestUserHandler_EventsStream tests that stored and live events are streamed after the Last-Event-ID
+// and that the stream ends when the event stream is closed
+func TestUserHandler_EventsStream(t *testing.T) {
+	t.Parallel()
+
+	stream, err := events.GetStream(zap.NewNop(), events.Config{
+		LogPath: filepath.Join(t.TempDir(), "event-log"),
+		Enabled: true,
+	})
+	require.NoError(t, err)
+
+	mockStorage := &storageMock.MockStorage{
+		SetFn: func(user *common.User) (int64, error) {
+			return 3, nil
+		},
+	}
+
+	// Create test handler
+	handler := NewUserHandler(zap.NewNop(), mockStorage, nil, nil, stream, nil, Config{EventsEnabled: true})
+
+	router := gin.New()
+	router.GET("/user/events", handler.EventsHandler)
+	router.POST("/user", handler.SetHandler)
+
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	// Events stored before the client connects
+	_, err = stream.Publish(events.Created, 1, &common.User{ID: 1, Name: "User-1"})
+	require.NoError(t, err)
+	_, err = stream.Publish(events.Deleted, 2, nil)
+	require.NoError(t, err)
+
+	req, err := http.NewRequest(http.MethodGet, server.URL+"/user/events", nil)
+	require.NoError(t, err)
+	req.Header.Set("Last-Event-ID", "1")
+
+	res, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+
+	defer res.Body.Close()
+
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "text/event-stream", res.Header.Get("Content-Type"))
+
+	reader := bufio.NewReader(res.Body)
+
+	// readEvent reads the next event from the response, skipping keep-alive comments
+	readEvent := func() (string, string, *events.Event) {
+		var id, eventType string
+
+		for {
+			line, err := reader.ReadString('\n')
+			require.NoError(t, err)
+
+			line = strings.TrimSuffix(line, "\n")
+
+			switch {
+			case strings.HasPrefix(line, "id: "):
+				id = strings.TrimPrefix(line, "id: ")
+			case strings.HasPrefix(line, "event: "):
+				eventType = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "data: "):
+				var event events.Event
+
+				require.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &event))
+
+				return id, eventType, &event
+			}
+		}
+	}
+
+	id, eventType, event := readEvent()
+	assert.Equal(t, "2", id)
+	assert.Equal(t, "deleted", eventType)
+	assert.Equal(t, int64(2), event.UserID)
+	assert.Nil(t, event.User)
+
+	// Events committed through the handler are streamed live
+	setRes, err := http.Post(server.URL+"/user", "application/json", strings.NewReader(`{"name":"User-3"}`))
+	require.NoError(t, err)
+	setRes.Body.Close()
+	require.Equal(t, http.StatusOK, setRes.StatusCode)
+
+	id, eventType, event = readEvent()
+	assert.Equal(t, "3", id)
+	assert.Equal(t, "created", eventType)
+	assert.Equal(t, int64(3), event.UserID)
+	assert.Equal(t, "User-3", event.User.Name)
+
+	require.NoError(t, stream.Close())
+
+	// The response ends once the event stream is closed
+	rest, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Equal(t, "\n", string(rest))
+}
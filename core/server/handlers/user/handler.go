@@ -2,50 +2,162 @@ package userhandler
 
 import (
 	"errors"
+	"fmt"
+	"math/rand"
 	"net/http"
+	"net/mail"
 	"strconv"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/Aleksao998/LightningUserVault/core/audit"
 	"github.com/Aleksao998/LightningUserVault/core/cache"
 	"github.com/Aleksao998/LightningUserVault/core/common"
+	"github.com/Aleksao998/LightningUserVault/core/events"
+	"github.com/Aleksao998/LightningUserVault/core/search"
 	"github.com/Aleksao998/LightningUserVault/core/storage"
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	// defaultListLimit is the page size used when the limit is not provided
+	defaultListLimit = 100
+
+	// maxListLimit is the largest allowed page size
+	maxListLimit = 1000
+
+	// exactMatch is the search match mode which only returns users with exactly the given name
+	exactMatch = "exact"
+
+	// prefixMatch is the search match mode which returns users with names starting with the given name
+	prefixMatch = "prefix"
+
+	// maxMultiGetSize is the largest number of users which can be retrieved at once
+	maxMultiGetSize = 1000
+
+	// maxBatchSize is the largest number of users which can be created in a single batch
+	maxBatchSize = 10000
+
+	// defaultSearchLimit is the number of full-text search results returned when the limit is not provided
+	defaultSearchLimit = 20
+
+	// maxSearchLimit is the largest number of full-text search results returned at once
+	maxSearchLimit = 100
+
+	// maxAttributes is the largest number of custom attributes a user can have
+	maxAttributes = 100
 )
 
 var (
 	errInvalidUserID       = errors.New("invalid user ID")
+	errInvalidListLimit    = errors.New("invalid limit")
 	errInvalidUserName     = errors.New("invalid user name")
+	errInvalidEmail        = errors.New("invalid email")
+	errInvalidAttributes   = errors.New("invalid attributes")
 	errInvalidReqJSONParam = errors.New("request is invalid json")
+	errInvalidUserIDs      = errors.New("invalid user IDs")
+	errTooManyUserIDs      = errors.New("too many user IDs")
+	errInvalidMatchMode    = errors.New("invalid match mode")
+	errEmptyBatch          = errors.New("batch is empty")
+	errBatchTooLarge       = errors.New("batch is too large")
+	errInvalidBatchItems   = errors.New("batch contains invalid users")
+	errInvalidSearchLimit  = errors.New("invalid search limit")
+	errSearchDisabled      = errors.New("full-text search is disabled")
+	errInvalidIfMatch      = errors.New("invalid If-Match header")
+	errSoftDeleteDisabled  = errors.New("soft delete is disabled")
+	errEventsDisabled      = errors.New("events are disabled")
+	errInvalidLastEventID  = errors.New("invalid Last-Event-ID header")
+	errInvalidAsOf         = errors.New("invalid as_of, expected an RFC 3339 time")
+	errVersionsDisabled    = errors.New("user versions are disabled")
 )
 
 type Config struct {
-	CacheEnabled bool
+	CacheEnabled      bool
+	SearchEnabled     bool
+	SoftDeleteEnabled bool
+	EventsEnabled     bool
+
+	// OutboxEnabled is set when created users are published by the outbox relay instead of the handler
+	OutboxEnabled bool
+
+	AuditEnabled bool
+
+	// VersionsEnabled is set when every version of a user is kept for point-in-time reads
+	VersionsEnabled bool
+
+	// EarlyRefreshProbability is the probability of a user served from cache being refreshed from the vault
+	// in the background, zero disables early refreshes
+	EarlyRefreshProbability float64
 }
 
 type UserHandler struct {
 	vault  storage.Storage
 	cache  cache.Cache
+	index  search.Index
+	stream events.Stream
+	audit  audit.Log
 	logger *zap.Logger
 	config Config
+
+	// loads coalesces concurrent vault reads of the same user
+	loads singleflight.Group
+
+	// writes counts cache invalidations, reads which overlap one do not store their result in cache.
+	// writesLock is held for writing while counting and for reading while a read stores its result
+	writes     uint64
+	writesLock sync.RWMutex
+
+	// random returns the number compared with the early refresh probability
+	random func() float64
 }
 
 // NewUserHandler creates a new UserHandler with the given storage
-func NewUserHandler(logger *zap.Logger, storage storage.Storage, cache cache.Cache, config Config) *UserHandler {
+func NewUserHandler(
+	logger *zap.Logger,
+	storage storage.Storage,
+	cache cache.Cache,
+	index search.Index,
+	stream events.Stream,
+	auditLog audit.Log,
+	config Config,
+) *UserHandler {
+	registerMetrics(logger)
+
 	return &UserHandler{
 		vault:  storage,
 		cache:  cache,
+		index:  index,
+		stream: stream,
+		audit:  auditLog,
 		logger: logger,
 		config: config,
+		//nolint:gosec // The early refresh decision does not need a secure source
+		random: rand.Float64,
 	}
 }
 
 // @Summary Get user by ID
-// @Description Retrieve user details by user ID
+// @Description Retrieve user details by user ID.
+// @Description When If-None-Match or If-Modified-Since show the client already has the current user, 304 is returned without a body.
+// @Description When as_of is set, the version of the user at that time is returned instead of the current one
 // @ID get-user-by-id
 // @Produce json
 // @Param id path int true "User ID"
+// @Param as_of query string false "RFC 3339 time at which the user is read"
+// @Param If-None-Match header string false "ETags of the user versions held by the client"
+// @Param If-Modified-Since header string false "Last-Modified value of the user held by the client"
 // @Success 200 {object} common.User
+// @Success 304
+// @Header 200,304 {string} ETag "Current version of the user"
+// @Header 200,304 {string} Last-Modified "Time the user was last changed"
 // @Failure 400 {object} common.ErrorResponse
+// @Failure 404 {object} common.ErrorResponse
+// @Failure 410 {object} common.ErrorResponse
+// @Failure 500 {object} common.ErrorResponse
+// @Failure 501 {object} common.ErrorResponse
 // @Router /user/{id} [get]
 func (h *UserHandler) GetHandler(c *gin.Context) {
 	idStr := c.Param("id")
@@ -58,38 +170,217 @@ func (h *UserHandler) GetHandler(c *gin.Context) {
 		return
 	}
 
+	if asOf, ok := c.GetQuery("as_of"); ok {
+		h.getAsOf(c, id, asOf)
+
+		return
+	}
+
 	if h.config.CacheEnabled {
 		// Try to get the user from cache first
 		user, err := h.cache.Get(id)
 		if err == nil {
 			h.logger.Debug("User fetched from cache", zap.Int64("id", id))
-			c.JSON(http.StatusOK, user)
+			h.maybeRefresh(id)
+			writeUser(c, user)
+
+			return
+		}
+
+		if errors.Is(err, common.ErrUserNotFound) {
+			h.logger.Debug("Missing user fetched from cache", zap.Int64("id", id))
+			c.JSON(http.StatusNotFound, common.ErrorResponse{Error: err.Error()})
 
 			return
 		}
 	}
 
-	// If not in cache, get from vault
-	user, err := h.vault.Get(id)
+	// If not in cache, get from vault, coalescing concurrent reads of the same user
+	user, err := h.loadUser(id)
 	if err != nil {
+		if errors.Is(err, common.ErrUserDeleted) {
+			h.logger.Info("Requested user is deleted", zap.Int64("id", id))
+			c.JSON(http.StatusGone, common.ErrorResponse{Error: err.Error()})
+
+			return
+		}
+
 		h.logger.Error("Failed to fetch user from vault", zap.Int64("id", id), zap.Error(err))
 		c.JSON(http.StatusNotFound, common.ErrorResponse{Error: err.Error()})
 
 		return
 	}
 
+	h.logger.Info("Returning user data", zap.Int64("id", id))
+	writeUser(c, user)
+}
+
+// @Summary Get multiple users by ID
+// @Description Retrieve details of multiple users at once, IDs which do not exist are reported as missing
+// @ID get-users-by-ids
+// @Produce json
+// @Param ids query string true "Comma separated user IDs"
+// @Success 200 {object} common.UserMultiResponse
+// @Failure 400 {object} common.ErrorResponse
+// @Failure 500 {object} common.ErrorResponse
+// @Router /users [get]
+func (h *UserHandler) GetMultiHandler(c *gin.Context) {
+	idsStr := c.Query("ids")
+
+	ids, err := parseUserIDs(idsStr)
+	if err != nil {
+		h.logger.Warn("Invalid user IDs received", zap.String("ids", idsStr), zap.Error(err))
+		c.JSON(http.StatusBadRequest, common.ErrorResponse{Error: err.Error()})
+
+		return
+	}
+
+	users := make(map[int64]*common.User, len(ids))
+
 	if h.config.CacheEnabled {
-		// Store the fetched user in cache
-		err = h.cache.Set(id, user)
+		// Try to get as many users as possible from cache first
+		cached, err := h.cache.GetMulti(ids)
+		if err != nil {
+			h.logger.Error("Failed to fetch users from cache", zap.Int("ids", len(ids)), zap.Error(err))
+		}
+
+		for id, user := range cached {
+			users[id] = user
+		}
+
+		h.logger.Debug("Users fetched from cache", zap.Int("hits", len(cached)))
+	}
+
+	misses := make([]int64, 0, len(ids)-len(users))
+
+	for _, id := range ids {
+		if _, ok := users[id]; !ok {
+			misses = append(misses, id)
+		}
+	}
+
+	if len(misses) > 0 {
+		// Fetch all users which were not in cache from vault at once and back-fill the cache with them
+		stored, err := h.readUsers(misses)
 		if err != nil {
-			h.logger.Error("Failed to set user in cache", zap.Int64("id", id), zap.Error(err))
+			h.logger.Error("Failed to fetch users from vault", zap.Int("ids", len(misses)), zap.Error(err))
+			c.JSON(http.StatusInternalServerError, common.ErrorResponse{Error: err.Error()})
+
+			return
+		}
+
+		for id, user := range stored {
+			users[id] = user
+		}
+	}
+
+	response := common.UserMultiResponse{
+		Users:   make([]*common.User, 0, len(users)),
+		Missing: make([]int64, 0),
+	}
+
+	for _, id := range ids {
+		if user, ok := users[id]; ok {
+			response.Users = append(response.Users, user)
 		} else {
-			h.logger.Debug("User stored in cache", zap.Int64("id", id))
+			response.Missing = append(response.Missing, id)
 		}
 	}
 
-	h.logger.Info("Returning user data", zap.Int64("id", id))
-	c.JSON(http.StatusOK, user)
+	h.logger.Info("Returning multiple users data", zap.Int("found", len(response.Users)), zap.Int("missing", len(response.Missing)))
+	c.JSON(http.StatusOK, response)
+}
+
+// @Summary Search users
+// @Description Retrieve all users with the given name, or with names starting with it.
+// @Description When q is provided instead, run a full-text search tolerating partial and misspelled names, best matches first
+// @ID search-users
+// @Produce json
+// @Param name query string false "User name"
+// @Param match query string false "Match mode" Enums(exact, prefix) default(exact)
+// @Param q query string false "Full-text query"
+// @Param limit query int false "Maximum number of full-text results (1-100)" default(20)
+// @Success 200 {object} common.UserList
+// @Failure 400 {object} common.ErrorResponse
+// @Failure 500 {object} common.ErrorResponse
+// @Failure 501 {object} common.ErrorResponse
+// @Router /user/search [get]
+func (h *UserHandler) SearchHandler(c *gin.Context) {
+	if query := c.Query("q"); query != "" {
+		h.fullTextSearch(c, query)
+
+		return
+	}
+
+	name := c.Query("name")
+	if name == "" {
+		h.logger.Warn("Invalid user name received", zap.String("name", name))
+		c.JSON(http.StatusBadRequest, common.ErrorResponse{Error: errInvalidUserName.Error()})
+
+		return
+	}
+
+	match := c.DefaultQuery("match", exactMatch)
+	if match != exactMatch && match != prefixMatch {
+		h.logger.Warn("Invalid match mode received", zap.String("match", match))
+		c.JSON(http.StatusBadRequest, common.ErrorResponse{Error: errInvalidMatchMode.Error()})
+
+		return
+	}
+
+	users, err := h.vault.FindByName(name, match == prefixMatch)
+	if err != nil {
+		h.logger.Error("Failed to search users in vault", zap.String("name", name), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, common.ErrorResponse{Error: err.Error()})
+
+		return
+	}
+
+	h.logger.Info("Returning found users", zap.String("name", name), zap.Int("count", len(users)))
+	c.JSON(http.StatusOK, common.UserList{Users: users})
+}
+
+// fullTextSearch responds with users matching the query in the search index, resolved from the vault
+func (h *UserHandler) fullTextSearch(c *gin.Context, query string) {
+	if !h.config.SearchEnabled {
+		h.logger.Warn("Full-text search requested while disabled")
+		c.JSON(http.StatusNotImplemented, common.ErrorResponse{Error: errSearchDisabled.Error()})
+
+		return
+	}
+
+	limitStr := c.DefaultQuery("limit", strconv.Itoa(defaultSearchLimit))
+
+	limit, err := strconv.Atoi(limitStr)
+	if err != nil || limit < 1 || limit > maxSearchLimit {
+		h.logger.Warn("Invalid search limit received", zap.String("limit", limitStr))
+		c.JSON(http.StatusBadRequest, common.ErrorResponse{Error: errInvalidSearchLimit.Error()})
+
+		return
+	}
+
+	ids := h.index.Search(query, limit)
+	response := common.UserList{Users: make([]*common.User, 0, len(ids))}
+
+	if len(ids) > 0 {
+		users, err := h.vault.GetMulti(ids)
+		if err != nil {
+			h.logger.Error("Failed to get found users from vault", zap.String("query", query), zap.Error(err))
+			c.JSON(http.StatusInternalServerError, common.ErrorResponse{Error: err.Error()})
+
+			return
+		}
+
+		// Keep the ranking of the index, skipping users removed since they were indexed
+		for _, id := range ids {
+			if user, ok := users[id]; ok {
+				response.Users = append(response.Users, user)
+			}
+		}
+	}
+
+	h.logger.Info("Returning full-text search results", zap.String("query", query), zap.Int("count", len(response.Users)))
+	c.JSON(http.StatusOK, response)
 }
 
 // @Summary Set a new user
@@ -110,14 +401,14 @@ func (h *UserHandler) SetHandler(c *gin.Context) {
 		return
 	}
 
-	if user.Name == "" {
-		h.logger.Warn("Invalid user name received", zap.String("name", user.Name))
-		c.JSON(http.StatusBadRequest, common.ErrorResponse{Error: errInvalidUserName.Error()})
+	if err := validateUser(&user); err != nil {
+		h.logger.Warn("Invalid user received", zap.String("name", user.Name), zap.Error(err))
+		c.JSON(http.StatusBadRequest, common.ErrorResponse{Error: err.Error()})
 
 		return
 	}
 
-	id, err := h.vault.Set(user.Name)
+	id, err := h.vault.Set(&user)
 	if err != nil {
 		h.logger.Error("Failed to set user in vault", zap.String("name", user.Name), zap.Error(err))
 		c.JSON(http.StatusInternalServerError, common.ErrorResponse{Error: err.Error()})
@@ -126,6 +417,566 @@ func (h *UserHandler) SetHandler(c *gin.Context) {
 	}
 
 	user.ID = id
+
+	if h.config.CacheEnabled {
+		// Drop a cached record of the ID missing from before the user was created
+		h.invalidateCache(id)
+	}
+
+	if h.config.SearchEnabled {
+		h.index.Add(&user)
+	}
+
+	h.publish(events.Created, id, &user)
+	h.record(c, audit.Create, id, nil, &user)
+
 	h.logger.Info("User successfully stored", zap.Int64("id", id), zap.String("name", user.Name))
+	setETag(c, user.Version)
+	c.JSON(http.StatusOK, user)
+}
+
+// @Summary Set a batch of new users
+// @Description Atomically add multiple users and return them with their IDs. If any user is invalid, none are stored
+// @ID set-user-batch
+// @Accept  json
+// @Produce json
+// @Param users body []common.User true "User objects"
+// @Success 200 {array} common.User
+// @Failure 400 {object} common.BatchErrorResponse
+// @Failure 500 {object} common.ErrorResponse
+// @Router /user/batch [post]
+func (h *UserHandler) SetBatchHandler(c *gin.Context) {
+	var users []common.User
+	if err := c.BindJSON(&users); err != nil {
+		h.logger.Warn("Invalid JSON received", zap.Error(err))
+		c.JSON(http.StatusBadRequest, common.ErrorResponse{Error: errInvalidReqJSONParam.Error()})
+
+		return
+	}
+
+	if len(users) == 0 {
+		h.logger.Warn("Empty batch received")
+		c.JSON(http.StatusBadRequest, common.ErrorResponse{Error: errEmptyBatch.Error()})
+
+		return
+	}
+
+	if len(users) > maxBatchSize {
+		h.logger.Warn("Too large batch received", zap.Int("size", len(users)))
+		c.JSON(http.StatusBadRequest, common.ErrorResponse{Error: errBatchTooLarge.Error()})
+
+		return
+	}
+
+	batch := make([]*common.User, 0, len(users))
+	itemErrors := make([]common.BatchItemError, 0)
+
+	for i := range users {
+		if err := validateUser(&users[i]); err != nil {
+			itemErrors = append(itemErrors, common.BatchItemError{Index: i, Error: err.Error()})
+
+			continue
+		}
+
+		batch = append(batch, &users[i])
+	}
+
+	if len(itemErrors) > 0 {
+		h.logger.Warn("Batch with invalid users received", zap.Int("invalid", len(itemErrors)))
+		c.JSON(http.StatusBadRequest, common.BatchErrorResponse{Error: errInvalidBatchItems.Error(), Items: itemErrors})
+
+		return
+	}
+
+	ids, err := h.vault.SetBatch(batch)
+	if err != nil {
+		h.logger.Error("Failed to set batch of users in vault", zap.Int("size", len(batch)), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, common.ErrorResponse{Error: err.Error()})
+
+		return
+	}
+
+	for i, user := range batch {
+		user.ID = ids[i]
+
+		if h.config.CacheEnabled {
+			// Drop a cached record of the ID missing from before the user was created
+			h.invalidateCache(user.ID)
+		}
+
+		if h.config.SearchEnabled {
+			h.index.Add(user)
+		}
+
+		h.publish(events.Created, user.ID, user)
+		h.record(c, audit.Create, user.ID, nil, user)
+	}
+
+	h.logger.Info("Batch of users successfully stored", zap.Int("size", len(users)))
+	c.JSON(http.StatusOK, users)
+}
+
+// @Summary List users
+// @Description Retrieve a page of users, use the returned cursor to fetch the next page
+// @ID list-users
+// @Produce json
+// @Param cursor query string false "Opaque cursor returned by the previous page"
+// @Param limit query int false "Maximum number of users in the page (1-1000)" default(100)
+// @Success 200 {object} common.UserList
+// @Failure 400 {object} common.ErrorResponse
+// @Failure 500 {object} common.ErrorResponse
+// @Router /user [get]
+func (h *UserHandler) ListHandler(c *gin.Context) {
+	cursor := c.Query("cursor")
+	limitStr := c.DefaultQuery("limit", strconv.Itoa(defaultListLimit))
+
+	limit, err := strconv.Atoi(limitStr)
+	if err != nil || limit < 1 || limit > maxListLimit {
+		h.logger.Warn("Invalid list limit received", zap.String("limit", limitStr))
+		c.JSON(http.StatusBadRequest, common.ErrorResponse{Error: errInvalidListLimit.Error()})
+
+		return
+	}
+
+	users, nextCursor, err := h.vault.List(cursor, limit)
+	if err != nil {
+		if errors.Is(err, common.ErrInvalidCursor) {
+			h.logger.Warn("Invalid list cursor received", zap.String("cursor", cursor))
+			c.JSON(http.StatusBadRequest, common.ErrorResponse{Error: err.Error()})
+
+			return
+		}
+
+		h.logger.Error("Failed to list users from vault", zap.String("cursor", cursor), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, common.ErrorResponse{Error: err.Error()})
+
+		return
+	}
+
+	h.logger.Info("Returning user list", zap.Int("count", len(users)))
+	c.JSON(http.StatusOK, common.UserList{Users: users, NextCursor: nextCursor})
+}
+
+// @Summary Update an existing user
+// @Description Replace the name, email and attributes of an existing user.
+// @Description When If-Match is provided, the update is only applied if the user is still at that version
+// @ID update-user
+// @Accept  json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param If-Match header string false "ETag of the expected user version"
+// @Param user body common.User true "User object"
+// @Success 200 {object} common.User
+// @Header 200 {string} ETag "New version of the user"
+// @Failure 400 {object} common.ErrorResponse
+// @Failure 404 {object} common.ErrorResponse
+// @Failure 410 {object} common.ErrorResponse
+// @Failure 412 {object} common.ErrorResponse
+// @Failure 500 {object} common.ErrorResponse
+// @Router /user/{id} [put]
+func (h *UserHandler) UpdateHandler(c *gin.Context) {
+	idStr := c.Param("id")
+
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		h.logger.Warn("Invalid user ID received", zap.String("id", idStr))
+		c.JSON(http.StatusBadRequest, common.ErrorResponse{Error: errInvalidUserID.Error()})
+
+		return
+	}
+
+	var user common.User
+	if err := c.BindJSON(&user); err != nil {
+		h.logger.Warn("Invalid JSON received", zap.Error(err))
+		c.JSON(http.StatusBadRequest, common.ErrorResponse{Error: errInvalidReqJSONParam.Error()})
+
+		return
+	}
+
+	if err := validateUser(&user); err != nil {
+		h.logger.Warn("Invalid user received", zap.String("name", user.Name), zap.Error(err))
+		c.JSON(http.StatusBadRequest, common.ErrorResponse{Error: err.Error()})
+
+		return
+	}
+
+	version, err := parseIfMatch(c.GetHeader("If-Match"))
+	if err != nil {
+		h.logger.Warn("Invalid If-Match received", zap.String("ifMatch", c.GetHeader("If-Match")))
+		c.JSON(http.StatusPreconditionFailed, common.ErrorResponse{Error: err.Error()})
+
+		return
+	}
+
+	user.ID = id
+
+	before, err := h.vault.Update(&user, version)
+	if err != nil {
+		if errors.Is(err, common.ErrUserNotFound) {
+			h.logger.Warn("User to update not found", zap.Int64("id", id))
+			c.JSON(http.StatusNotFound, common.ErrorResponse{Error: err.Error()})
+
+			return
+		}
+
+		if errors.Is(err, common.ErrVersionMismatch) {
+			h.logger.Warn("User to update changed", zap.Int64("id", id), zap.Int64("version", version))
+			c.JSON(http.StatusPreconditionFailed, common.ErrorResponse{Error: err.Error()})
+
+			return
+		}
+
+		if errors.Is(err, common.ErrUserDeleted) {
+			h.logger.Warn("User to update is deleted", zap.Int64("id", id))
+			c.JSON(http.StatusGone, common.ErrorResponse{Error: err.Error()})
+
+			return
+		}
+
+		h.logger.Error("Failed to update user in vault", zap.Int64("id", id), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, common.ErrorResponse{Error: err.Error()})
+
+		return
+	}
+
+	if h.config.CacheEnabled {
+		// Invalidate the cached user so the stale name is never served
+		h.invalidateCache(id)
+	}
+
+	if h.config.SearchEnabled {
+		h.index.Add(&user)
+	}
+
+	h.publish(events.Updated, id, &user)
+	h.record(c, audit.Update, id, before, &user)
+
+	h.logger.Info("User successfully updated", zap.Int64("id", id), zap.String("name", user.Name))
+	setETag(c, user.Version)
+	c.JSON(http.StatusOK, user)
+}
+
+// @Summary Delete a user
+// @Description Remove an existing user by user ID. When soft delete is enabled, the user is only marked as deleted
+// @Description and can be restored until it is purged after the retention period.
+// @Description When If-Match is provided, the user is only removed if it is still at that version
+// @ID delete-user
+// @Produce json
+// @Param id path int true "User ID"
+// @Param If-Match header string false "ETag of the expected user version"
+// @Success 204
+// @Failure 400 {object} common.ErrorResponse
+// @Failure 404 {object} common.ErrorResponse
+// @Failure 410 {object} common.ErrorResponse
+// @Failure 412 {object} common.ErrorResponse
+// @Failure 500 {object} common.ErrorResponse
+// @Router /user/{id} [delete]
+func (h *UserHandler) DeleteHandler(c *gin.Context) {
+	idStr := c.Param("id")
+
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		h.logger.Warn("Invalid user ID received", zap.String("id", idStr))
+		c.JSON(http.StatusBadRequest, common.ErrorResponse{Error: errInvalidUserID.Error()})
+
+		return
+	}
+
+	version, err := parseIfMatch(c.GetHeader("If-Match"))
+	if err != nil {
+		h.logger.Warn("Invalid If-Match received", zap.String("ifMatch", c.GetHeader("If-Match")))
+		c.JSON(http.StatusPreconditionFailed, common.ErrorResponse{Error: err.Error()})
+
+		return
+	}
+
+	deleteUser, operation := h.vault.Delete, audit.Delete
+	if h.config.SoftDeleteEnabled {
+		deleteUser, operation = h.vault.SoftDelete, audit.SoftDelete
+	}
+
+	before, err := deleteUser(id, version)
+	if err != nil {
+		if errors.Is(err, common.ErrUserNotFound) {
+			h.logger.Warn("User to delete not found", zap.Int64("id", id))
+			c.JSON(http.StatusNotFound, common.ErrorResponse{Error: err.Error()})
+
+			return
+		}
+
+		if errors.Is(err, common.ErrVersionMismatch) {
+			h.logger.Warn("User to delete changed", zap.Int64("id", id), zap.Int64("version", version))
+			c.JSON(http.StatusPreconditionFailed, common.ErrorResponse{Error: err.Error()})
+
+			return
+		}
+
+		if errors.Is(err, common.ErrUserDeleted) {
+			h.logger.Warn("User to delete is already deleted", zap.Int64("id", id))
+			c.JSON(http.StatusGone, common.ErrorResponse{Error: err.Error()})
+
+			return
+		}
+
+		h.logger.Error("Failed to delete user from vault", zap.Int64("id", id), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, common.ErrorResponse{Error: err.Error()})
+
+		return
+	}
+
+	if h.config.CacheEnabled {
+		// Evict the user so it is not served from cache anymore
+		h.invalidateCache(id)
+	}
+
+	if h.config.SearchEnabled {
+		h.index.Remove(id)
+	}
+
+	h.publish(events.Deleted, id, nil)
+	h.record(c, operation, id, before, nil)
+
+	h.logger.Info("User successfully deleted", zap.Int64("id", id))
+	c.Status(http.StatusNoContent)
+}
+
+// @Summary Restore a deleted user
+// @Description Bring back a soft deleted user which has not been purged yet
+// @ID restore-user
+// @Produce json
+// @Param id path int true "User ID"
+// @Success 200 {object} common.User
+// @Header 200 {string} ETag "New version of the user"
+// @Failure 400 {object} common.ErrorResponse
+// @Failure 404 {object} common.ErrorResponse
+// @Failure 409 {object} common.ErrorResponse
+// @Failure 500 {object} common.ErrorResponse
+// @Failure 501 {object} common.ErrorResponse
+// @Router /user/{id}/restore [post]
+func (h *UserHandler) RestoreHandler(c *gin.Context) {
+	idStr := c.Param("id")
+
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		h.logger.Warn("Invalid user ID received", zap.String("id", idStr))
+		c.JSON(http.StatusBadRequest, common.ErrorResponse{Error: errInvalidUserID.Error()})
+
+		return
+	}
+
+	if !h.config.SoftDeleteEnabled {
+		h.logger.Warn("Restore requested while soft delete is disabled", zap.Int64("id", id))
+		c.JSON(http.StatusNotImplemented, common.ErrorResponse{Error: errSoftDeleteDisabled.Error()})
+
+		return
+	}
+
+	user, before, err := h.vault.Restore(id)
+	if err != nil {
+		if errors.Is(err, common.ErrUserNotFound) {
+			h.logger.Warn("User to restore not found", zap.Int64("id", id))
+			c.JSON(http.StatusNotFound, common.ErrorResponse{Error: err.Error()})
+
+			return
+		}
+
+		if errors.Is(err, common.ErrUserNotDeleted) {
+			h.logger.Warn("User to restore is not deleted", zap.Int64("id", id))
+			c.JSON(http.StatusConflict, common.ErrorResponse{Error: err.Error()})
+
+			return
+		}
+
+		h.logger.Error("Failed to restore user in vault", zap.Int64("id", id), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, common.ErrorResponse{Error: err.Error()})
+
+		return
+	}
+
+	if h.config.CacheEnabled {
+		// Drop a cached record of the user from before it was restored
+		h.invalidateCache(id)
+	}
+
+	if h.config.SearchEnabled {
+		h.index.Add(user)
+	}
+
+	h.publish(events.Restored, id, user)
+	h.record(c, audit.Restore, id, before, user)
+
+	h.logger.Info("User successfully restored", zap.Int64("id", id))
+	setETag(c, user.Version)
+	c.JSON(http.StatusOK, user)
+}
+
+// invalidateCache removes the user with the given ID from cache.
+// Reads in flight are forgotten and do not store their possibly stale result in cache
+func (h *UserHandler) invalidateCache(id int64) {
+	h.writesLock.Lock()
+	h.writes++
+	h.writesLock.Unlock()
+
+	h.loads.Forget(strconv.FormatInt(id, 10))
+
+	if err := h.cache.Delete(id); err != nil {
+		h.logger.Error("Failed to remove user from cache", zap.Int64("id", id), zap.Error(err))
+
+		return
+	}
+
+	h.logger.Debug("User removed from cache", zap.Int64("id", id))
+}
+
+// validateUser checks the writable fields of a user received in a request and clears the fields owned by the server,
+// so a client can never set the timestamps, version or deletion mark of a user
+func validateUser(user *common.User) error {
+	user.CreatedAt = time.Time{}
+	user.UpdatedAt = time.Time{}
+	user.Version = 0
+	user.DeletedAt = nil
+
+	if user.Name == "" {
+		return errInvalidUserName
+	}
+
+	if user.Email != "" {
+		// Only bare addresses are accepted, without a display name
+		address, err := mail.ParseAddress(user.Email)
+		if err != nil || address.Address != user.Email {
+			return errInvalidEmail
+		}
+	}
+
+	if len(user.Attributes) > maxAttributes {
+		return errInvalidAttributes
+	}
+
+	for key := range user.Attributes {
+		if key == "" {
+			return errInvalidAttributes
+		}
+	}
+
+	return nil
+}
+
+// writeUser responds with the given user along with its validators,
+// or with 304 Not Modified when the request conditions show the client already has it
+func writeUser(c *gin.Context, user *common.User) {
+	setETag(c, user.Version)
+
+	if !user.UpdatedAt.IsZero() {
+		c.Header("Last-Modified", user.UpdatedAt.UTC().Format(http.TimeFormat))
+	}
+
+	if notModified(c.Request, user) {
+		c.Status(http.StatusNotModified)
+
+		return
+	}
+
 	c.JSON(http.StatusOK, user)
 }
+
+// notModified evaluates If-None-Match and If-Modified-Since against the user,
+// If-Modified-Since is ignored when If-None-Match is present
+func notModified(req *http.Request, user *common.User) bool {
+	if ifNoneMatch := req.Header.Get("If-None-Match"); ifNoneMatch != "" {
+		if user.Version == common.AnyVersion {
+			return false
+		}
+
+		etag := formatETag(user.Version)
+
+		for _, tag := range strings.Split(ifNoneMatch, ",") {
+			// If-None-Match uses weak comparison
+			tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+			if tag == "*" || tag == etag {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	ifModifiedSince := req.Header.Get("If-Modified-Since")
+	if ifModifiedSince == "" || user.UpdatedAt.IsZero() {
+		return false
+	}
+
+	since, err := http.ParseTime(ifModifiedSince)
+	if err != nil {
+		return false
+	}
+
+	// Last-Modified has a resolution of one second
+	return !user.UpdatedAt.Truncate(time.Second).After(since)
+}
+
+// setETag sets the ETag response header to the given user version,
+// users cached before versions were introduced are returned without one
+func setETag(c *gin.Context, version int64) {
+	if version == common.AnyVersion {
+		return
+	}
+
+	c.Header("ETag", formatETag(version))
+}
+
+// formatETag returns the strong entity tag of the given user version
+func formatETag(version int64) string {
+	return fmt.Sprintf("%q", strconv.FormatInt(version, 10))
+}
+
+// parseIfMatch returns the user version expected by the If-Match header,
+// a missing header or "*" matches any version of an existing user
+func parseIfMatch(header string) (int64, error) {
+	header = strings.TrimSpace(header)
+	if header == "" || header == "*" {
+		return common.AnyVersion, nil
+	}
+
+	// Weak tags never match, If-Match requires strong comparison
+	if len(header) < 2 || header[0] != '"' || header[len(header)-1] != '"' {
+		return 0, errInvalidIfMatch
+	}
+
+	version, err := strconv.ParseInt(header[1:len(header)-1], 10, 64)
+	if err != nil || version < 1 {
+		return 0, errInvalidIfMatch
+	}
+
+	return version, nil
+}
+
+// parseUserIDs parses a comma separated list of user IDs, removing duplicates while keeping the order
+func parseUserIDs(idsStr string) ([]int64, error) {
+	if idsStr == "" {
+		return nil, errInvalidUserIDs
+	}
+
+	parts := strings.Split(idsStr, ",")
+	if len(parts) > maxMultiGetSize {
+		return nil, errTooManyUserIDs
+	}
+
+	ids := make([]int64, 0, len(parts))
+	seen := make(map[int64]struct{}, len(parts))
+
+	for _, part := range parts {
+		id, err := strconv.ParseInt(strings.TrimSpace(part), 10, 64)
+		if err != nil {
+			return nil, errInvalidUserIDs
+		}
+
+		if _, ok := seen[id]; ok {
+			continue
+		}
+
+		seen[id] = struct{}{}
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
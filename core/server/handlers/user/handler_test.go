@@ -7,9 +7,15 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/Aleksao998/LightningUserVault/core/cache/expiry"
+	"github.com/Aleksao998/LightningUserVault/core/cache/lru"
 	cacheMock "github.com/Aleksao998/LightningUserVault/core/cache/mocks"
 	"github.com/Aleksao998/LightningUserVault/core/common"
+	searchMock "github.com/Aleksao998/LightningUserVault/core/search/mocks"
+	"github.com/Aleksao998/LightningUserVault/core/storage"
+	"github.com/Aleksao998/LightningUserVault/core/storage/memory"
 	storageMock "github.com/Aleksao998/LightningUserVault/core/storage/mocks"
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
@@ -22,6 +28,57 @@ var (
 	errUserNotInCache = errors.New("user not in cache")
 )
 
+// storageCase is a storage the handler tests run against
+type storageCase struct {
+	name       string
+	newStorage func(t *testing.T) storage.Storage
+}
+
+// storageCases returns the storages a handler test runs against: its mock and the in-memory storage holding
+// the given users. The users get their IDs from 1 in order and are soft deleted if they have a deletion time
+func storageCases(mockStorage *storageMock.MockStorage, users ...*common.User) []storageCase {
+	return []storageCase{
+		{
+			name: "Mock",
+			newStorage: func(t *testing.T) storage.Storage {
+				t.Helper()
+
+				return mockStorage
+			},
+		},
+		{
+			name: "Memory",
+			newStorage: func(t *testing.T) storage.Storage {
+				t.Helper()
+
+				store := memory.NewStorage(zap.NewNop(), memory.Options{KeepVersions: true})
+				t.Cleanup(func() {
+					_ = store.Close()
+				})
+
+				for _, user := range users {
+					stored := *user
+
+					id, err := store.Set(&stored)
+					if err != nil {
+						t.Fatalf("Failed to store user: %v", err)
+					}
+
+					if user.DeletedAt == nil {
+						continue
+					}
+
+					if _, err := store.SoftDelete(id, common.AnyVersion); err != nil {
+						t.Fatalf("Failed to soft delete user: %v", err)
+					}
+				}
+
+				return store
+			},
+		},
+	}
+}
+
 // TestUserHandler_GetWithInvalidParams tests the behavior of the GetHandler when provided with invalid parameters
 func TestUserHandler_GetWithInvalidParams(t *testing.T) {
 	t.Parallel()
@@ -34,7 +91,7 @@ func TestUserHandler_GetWithInvalidParams(t *testing.T) {
 	}
 
 	// Create test handler
-	handler := NewUserHandler(zap.NewNop(), mockStorage, mockCache, handlerConfig)
+	handler := NewUserHandler(zap.NewNop(), mockStorage, mockCache, nil, nil, nil, handlerConfig)
 
 	// Create a response recorder
 	w := httptest.NewRecorder()
@@ -79,13 +136,14 @@ func TestUserHandler_GetValidUserCache(t *testing.T) {
 	}
 
 	// Create test handler
-	handler := NewUserHandler(zap.NewNop(), mockStorage, mockCache, handlerConfig)
+	handler := NewUserHandler(zap.NewNop(), mockStorage, mockCache, nil, nil, nil, handlerConfig)
 
 	// Create a response recorder
 	w := httptest.NewRecorder()
 
 	// Create a new context from the request and response recorder
 	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/user/1", nil)
 
 	// Set the "id" parameter
 	c.Params = append(c.Params, gin.Param{Key: "id", Value: "1"})
@@ -118,50 +176,120 @@ func TestUserHandler_GetValidUserDB(t *testing.T) {
 			return &user, nil
 		},
 	}
-	mockCache := &cacheMock.MockCache{
+
+	for _, sc := range storageCases(mockStorage, &common.User{Name: "User-1"}) {
+		sc := sc
+
+		t.Run(sc.name, func(t *testing.T) {
+			t.Parallel()
+
+			mockCache := &cacheMock.MockCache{
+				GetFn: func(key int64) (*common.User, error) {
+					return nil, errUserNotInCache
+				},
+				SetFn: func(key int64, value *common.User) error {
+					return nil
+				},
+			}
+
+			handlerConfig := Config{
+				CacheEnabled: true,
+			}
+
+			// Create test handler
+			handler := NewUserHandler(zap.NewNop(), sc.newStorage(t), mockCache, nil, nil, nil, handlerConfig)
+
+			// Create a response recorder
+			w := httptest.NewRecorder()
+
+			// Create a new context from the request and response recorder
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodGet, "/user/1", nil)
+
+			// Set the "id" parameter
+			c.Params = append(c.Params, gin.Param{Key: "id", Value: "1"})
+
+			// Call the GetHandler function
+			handler.GetHandler(c)
+
+			// Check the response
+			assert.Equal(t, http.StatusOK, w.Code)
+
+			var user common.User
+
+			err := json.Unmarshal(w.Body.Bytes(), &user)
+			if err != nil {
+				t.Fatalf("Failed to unmarshal response: %v", err)
+			}
+
+			assert.Equal(t, int64(1), user.ID)
+			assert.Equal(t, "User-1", user.Name)
+		})
+	}
+}
+
+// TestUserHandler_GetMissingUser tests the behavior of the GetHandler when the user is missing both in the cache and the database
+func TestUserHandler_GetMissingUser(t *testing.T) {
+	t.Parallel()
+
+	mockStorage := &storageMock.MockStorage{
 		GetFn: func(key int64) (*common.User, error) {
-			return nil, errUserNotInCache
-		},
-		SetFn: func(key int64, value *common.User) error {
-			return nil
+			return nil, errUserNotFound
 		},
 	}
 
-	handlerConfig := Config{
-		CacheEnabled: true,
-	}
+	for _, sc := range storageCases(mockStorage) {
+		sc := sc
 
-	// Create test handler
-	handler := NewUserHandler(zap.NewNop(), mockStorage, mockCache, handlerConfig)
+		t.Run(sc.name, func(t *testing.T) {
+			t.Parallel()
 
-	// Create a response recorder
-	w := httptest.NewRecorder()
+			mockCache := &cacheMock.MockCache{
+				GetFn: func(key int64) (*common.User, error) {
+					return nil, errUserNotInCache
+				},
+				SetFn: func(key int64, value *common.User) error {
+					return nil
+				},
+			}
 
-	// Create a new context from the request and response recorder
-	c, _ := gin.CreateTestContext(w)
+			handlerConfig := Config{
+				CacheEnabled: true,
+			}
 
-	// Set the "id" parameter
-	c.Params = append(c.Params, gin.Param{Key: "id", Value: "1"})
+			// Create test handler
+			handler := NewUserHandler(zap.NewNop(), sc.newStorage(t), mockCache, nil, nil, nil, handlerConfig)
 
-	// Call the GetHandler function
-	handler.GetHandler(c)
+			// Create a response recorder
+			w := httptest.NewRecorder()
 
-	// Check the response
-	assert.Equal(t, http.StatusOK, w.Code)
+			// Create a new context from the request and response recorder
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodGet, "/user/1", nil)
 
-	var user common.User
+			// Set the "id" parameter
+			c.Params = append(c.Params, gin.Param{Key: "id", Value: "1"})
 
-	err := json.Unmarshal(w.Body.Bytes(), &user)
-	if err != nil {
-		t.Fatalf("Failed to unmarshal response: %v", err)
-	}
+			// Call the GetHandler function
+			handler.GetHandler(c)
 
-	assert.Equal(t, int64(1), user.ID)
-	assert.Equal(t, "User-1", user.Name)
+			// Check the response
+			assert.Equal(t, http.StatusNotFound, w.Code)
+
+			var jsonError common.ErrorResponse
+
+			err := json.Unmarshal(w.Body.Bytes(), &jsonError)
+			if err != nil {
+				t.Fatalf("Failed to unmarshal response: %v", err)
+			}
+
+			assert.Equal(t, errUserNotFound.Error(), jsonError.Error)
+		})
+	}
 }
 
-// TestUserHandler_GetMissingUser tests the behavior of the GetHandler when the user is missing both in the cache and the database
-func TestUserHandler_GetMissingUser(t *testing.T) {
+// TestUserHandler_GetErrSaveCache tests the behavior of the GetHandler when there's an error saving the user to the cache
+func TestUserHandler_GetErrSaveCache(t *testing.T) {
 	t.Parallel()
 
 	mockStorage := &storageMock.MockStorage{
@@ -174,7 +302,7 @@ func TestUserHandler_GetMissingUser(t *testing.T) {
 			return nil, errUserNotInCache
 		},
 		SetFn: func(key int64, value *common.User) error {
-			return nil
+			return errInternal
 		},
 	}
 
@@ -183,13 +311,14 @@ func TestUserHandler_GetMissingUser(t *testing.T) {
 	}
 
 	// Create test handler
-	handler := NewUserHandler(zap.NewNop(), mockStorage, mockCache, handlerConfig)
+	handler := NewUserHandler(zap.NewNop(), mockStorage, mockCache, nil, nil, nil, handlerConfig)
 
 	// Create a response recorder
 	w := httptest.NewRecorder()
 
 	// Create a new context from the request and response recorder
 	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/user/1", nil)
 
 	// Set the "id" parameter
 	c.Params = append(c.Params, gin.Param{Key: "id", Value: "1"})
@@ -210,21 +339,20 @@ func TestUserHandler_GetMissingUser(t *testing.T) {
 	assert.Equal(t, errUserNotFound.Error(), jsonError.Error)
 }
 
-// TestUserHandler_GetErrSaveCache tests the behavior of the GetHandler when there's an error saving the user to the cache
-func TestUserHandler_GetErrSaveCache(t *testing.T) {
+// TestUserHandler_GetMissingUserFromCache tests that a user recorded as missing in the cache is not looked up in the database
+func TestUserHandler_GetMissingUserFromCache(t *testing.T) {
 	t.Parallel()
 
 	mockStorage := &storageMock.MockStorage{
 		GetFn: func(key int64) (*common.User, error) {
-			return nil, errUserNotFound
+			t.Fatalf("Unexpected database lookup of user %d", key)
+
+			return nil, nil
 		},
 	}
 	mockCache := &cacheMock.MockCache{
 		GetFn: func(key int64) (*common.User, error) {
-			return nil, errUserNotInCache
-		},
-		SetFn: func(key int64, value *common.User) error {
-			return errInternal
+			return nil, common.ErrUserNotFound
 		},
 	}
 
@@ -233,13 +361,14 @@ func TestUserHandler_GetErrSaveCache(t *testing.T) {
 	}
 
 	// Create test handler
-	handler := NewUserHandler(zap.NewNop(), mockStorage, mockCache, handlerConfig)
+	handler := NewUserHandler(zap.NewNop(), mockStorage, mockCache, nil, nil, nil, handlerConfig)
 
 	// Create a response recorder
 	w := httptest.NewRecorder()
 
 	// Create a new context from the request and response recorder
 	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/user/1", nil)
 
 	// Set the "id" parameter
 	c.Params = append(c.Params, gin.Param{Key: "id", Value: "1"})
@@ -257,59 +386,127 @@ func TestUserHandler_GetErrSaveCache(t *testing.T) {
 		t.Fatalf("Failed to unmarshal response: %v", err)
 	}
 
-	assert.Equal(t, errUserNotFound.Error(), jsonError.Error)
+	assert.Equal(t, common.ErrUserNotFound.Error(), jsonError.Error)
 }
 
-// TestUserHandler_SetValidUser tests the successful setting of a valid user
-func TestUserHandler_SetValidUser(t *testing.T) {
+// TestUserHandler_GetCachesMissingUser tests that only users which do not exist in the database are recorded as missing in the cache
+func TestUserHandler_GetCachesMissingUser(t *testing.T) {
 	t.Parallel()
 
+	var missing []int64
+
 	mockStorage := &storageMock.MockStorage{
-		SetFn: func(value string) (int64, error) {
-			return 1, nil
+		GetFn: func(key int64) (*common.User, error) {
+			if key == 1 {
+				return nil, common.ErrUserNotFound
+			}
+
+			return nil, errInternal
+		},
+	}
+	mockCache := &cacheMock.MockCache{
+		GetFn: func(key int64) (*common.User, error) {
+			return nil, errUserNotInCache
+		},
+		SetMissingFn: func(key int64) error {
+			missing = append(missing, key)
+
+			return nil
 		},
 	}
-	mockCache := &cacheMock.MockCache{}
 
 	handlerConfig := Config{
 		CacheEnabled: true,
 	}
 
 	// Create test handler
-	handler := NewUserHandler(zap.NewNop(), mockStorage, mockCache, handlerConfig)
+	handler := NewUserHandler(zap.NewNop(), mockStorage, mockCache, nil, nil, nil, handlerConfig)
 
-	// Create a new HTTP request with a valid user JSON body
-	userJSON := `{"Name": "User-1"}`
+	for _, id := range []string{"1", "2"} {
+		w := httptest.NewRecorder()
 
-	req, err := http.NewRequest(http.MethodPost, "/user", strings.NewReader(userJSON))
-	if err != nil {
-		t.Fatalf("Failed to create request: %v", err)
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodGet, "/user/"+id, nil)
+		c.Params = append(c.Params, gin.Param{Key: "id", Value: id})
+
+		handler.GetHandler(c)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
 	}
 
-	req.Header.Set("Content-Type", "application/json")
+	assert.Equal(t, []int64{1}, missing)
+}
 
-	// Create a response recorder
-	w := httptest.NewRecorder()
+// TestUserHandler_SetValidUser tests the successful setting of a valid user
+func TestUserHandler_SetValidUser(t *testing.T) {
+	t.Parallel()
 
-	// Create a new context from the request and response recorder
-	c, _ := gin.CreateTestContext(w)
-	c.Request = req
+	mockStorage := &storageMock.MockStorage{
+		SetFn: func(user *common.User) (int64, error) {
+			return 1, nil
+		},
+	}
 
-	// Call the GetHandler function
-	handler.SetHandler(c)
+	for _, sc := range storageCases(mockStorage) {
+		sc := sc
 
-	// Check the response
-	assert.Equal(t, http.StatusOK, w.Code)
+		t.Run(sc.name, func(t *testing.T) {
+			t.Parallel()
 
-	var user common.User
+			var invalidated []int64
 
-	err = json.Unmarshal(w.Body.Bytes(), &user)
-	if err != nil {
-		t.Fatalf("Failed to unmarshal response: %v", err)
-	}
+			mockCache := &cacheMock.MockCache{
+				DeleteFn: func(key int64) error {
+					invalidated = append(invalidated, key)
 
-	assert.Equal(t, int64(1), user.ID)
-	assert.Equal(t, "User-1", user.Name)
+					return nil
+				},
+			}
+
+			handlerConfig := Config{
+				CacheEnabled: true,
+			}
+
+			// Create test handler
+			handler := NewUserHandler(zap.NewNop(), sc.newStorage(t), mockCache, nil, nil, nil, handlerConfig)
+
+			// Create a new HTTP request with a valid user JSON body
+			userJSON := `{"Name": "User-1"}`
+
+			req, err := http.NewRequest(http.MethodPost, "/user", strings.NewReader(userJSON))
+			if err != nil {
+				t.Fatalf("Failed to create request: %v", err)
+			}
+
+			req.Header.Set("Content-Type", "application/json")
+
+			// Create a response recorder
+			w := httptest.NewRecorder()
+
+			// Create a new context from the request and response recorder
+			c, _ := gin.CreateTestContext(w)
+			c.Request = req
+
+			// Call the GetHandler function
+			handler.SetHandler(c)
+
+			// Check the response
+			assert.Equal(t, http.StatusOK, w.Code)
+
+			var user common.User
+
+			err = json.Unmarshal(w.Body.Bytes(), &user)
+			if err != nil {
+				t.Fatalf("Failed to unmarshal response: %v", err)
+			}
+
+			assert.Equal(t, int64(1), user.ID)
+			assert.Equal(t, "User-1", user.Name)
+
+			// A cached record of the new ID missing is dropped
+			assert.Equal(t, []int64{1}, invalidated)
+		})
+	}
 }
 
 // TestUserHandler_SetInternalError tests the behavior of the SetHandler when there's an internal error
@@ -317,7 +514,7 @@ func TestUserHandler_SetInternalError(t *testing.T) {
 	t.Parallel()
 
 	mockStorage := &storageMock.MockStorage{
-		SetFn: func(value string) (int64, error) {
+		SetFn: func(user *common.User) (int64, error) {
 			return 0, errInternal
 		},
 	}
@@ -328,7 +525,7 @@ func TestUserHandler_SetInternalError(t *testing.T) {
 	}
 
 	// Create test handler
-	handler := NewUserHandler(zap.NewNop(), mockStorage, mockCache, handlerConfig)
+	handler := NewUserHandler(zap.NewNop(), mockStorage, mockCache, nil, nil, nil, handlerConfig)
 
 	// Create a new HTTP request with a user which does not exists
 	userJSON := `{"Name": "User-1"}`
@@ -375,7 +572,7 @@ func TestUserHandler_SetMissingParams(t *testing.T) {
 	}
 
 	// Create test handler
-	handler := NewUserHandler(zap.NewNop(), mockStorage, mockCache, handlerConfig)
+	handler := NewUserHandler(zap.NewNop(), mockStorage, mockCache, nil, nil, nil, handlerConfig)
 
 	// Create a new HTTP request with missing user name
 	userJSON := `{}`
@@ -410,6 +607,116 @@ func TestUserHandler_SetMissingParams(t *testing.T) {
 	assert.Equal(t, errInvalidUserName.Error(), jsonError.Error)
 }
 
+// TestUserHandler_SetUserFields tests that the optional user fields are passed to storage and returned
+func TestUserHandler_SetUserFields(t *testing.T) {
+	t.Parallel()
+
+	mockStorage := &storageMock.MockStorage{
+		SetFn: func(user *common.User) (int64, error) {
+			assert.Equal(t, "alice@example.com", user.Email)
+			assert.Equal(t, map[string]string{"team": "core"}, user.Attributes)
+
+			return 1, nil
+		},
+	}
+	mockCache := &cacheMock.MockCache{}
+
+	handlerConfig := Config{
+		CacheEnabled: true,
+	}
+
+	// Create test handler
+	handler := NewUserHandler(zap.NewNop(), mockStorage, mockCache, nil, nil, nil, handlerConfig)
+
+	userJSON := `{"name": "Alice", "email": "alice@example.com", "attributes": {"team": "core"}}`
+
+	req, err := http.NewRequest(http.MethodPost, "/user", strings.NewReader(userJSON))
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	// Create a response recorder
+	w := httptest.NewRecorder()
+
+	// Create a new context from the request and response recorder
+	c, _ := gin.CreateTestContext(w)
+	c.Request = req
+
+	// Call the SetHandler function
+	handler.SetHandler(c)
+
+	// Check the response
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var user common.User
+
+	err = json.Unmarshal(w.Body.Bytes(), &user)
+	if err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+
+	assert.Equal(t, int64(1), user.ID)
+	assert.Equal(t, "alice@example.com", user.Email)
+	assert.Equal(t, "core", user.Attributes["team"])
+}
+
+// TestUserHandler_SetInvalidUserFields tests the behavior of the SetHandler when provided with invalid optional fields
+func TestUserHandler_SetInvalidUserFields(t *testing.T) {
+	t.Parallel()
+
+	mockStorage := &storageMock.MockStorage{}
+	mockCache := &cacheMock.MockCache{}
+
+	handlerConfig := Config{
+		CacheEnabled: true,
+	}
+
+	// Create test handler
+	handler := NewUserHandler(zap.NewNop(), mockStorage, mockCache, nil, nil, nil, handlerConfig)
+
+	tests := []struct {
+		userJSON string
+		err      error
+	}{
+		{`{"name": "Alice", "email": "alice"}`, errInvalidEmail},
+		{`{"name": "Alice", "email": "Alice <alice@example.com>"}`, errInvalidEmail},
+		{`{"name": "Alice", "attributes": {"": "core"}}`, errInvalidAttributes},
+	}
+
+	for _, test := range tests {
+		req, err := http.NewRequest(http.MethodPost, "/user", strings.NewReader(test.userJSON))
+		if err != nil {
+			t.Fatalf("Failed to create request: %v", err)
+		}
+
+		req.Header.Set("Content-Type", "application/json")
+
+		// Create a response recorder
+		w := httptest.NewRecorder()
+
+		// Create a new context from the request and response recorder
+		c, _ := gin.CreateTestContext(w)
+		c.Request = req
+
+		// Call the SetHandler function
+		handler.SetHandler(c)
+
+		// Check the response
+		assert.Equal(t, http.StatusBadRequest, w.Code, "For body %s", test.userJSON)
+
+		var jsonError common.ErrorResponse
+
+		err = json.Unmarshal(w.Body.Bytes(), &jsonError)
+		if err != nil {
+			t.Fatalf("Failed to unmarshal response: %v", err)
+		}
+
+		assert.Equal(t, test.err.Error(), jsonError.Error)
+	}
+}
+
 // TestUserHandler_SetInvalidJsonParams tests the behavior of the SetHandler when provided with an invalid JSON body
 func TestUserHandler_SetInvalidJsonParams(t *testing.T) {
 	t.Parallel()
@@ -422,7 +729,7 @@ func TestUserHandler_SetInvalidJsonParams(t *testing.T) {
 	}
 
 	// Create test handler
-	handler := NewUserHandler(zap.NewNop(), mockStorage, mockCache, handlerConfig)
+	handler := NewUserHandler(zap.NewNop(), mockStorage, mockCache, nil, nil, nil, handlerConfig)
 
 	// Create a new HTTP request with invalid JSON body
 	userJSON := `{ "Name": "User-1`
@@ -456,3 +763,1918 @@ func TestUserHandler_SetInvalidJsonParams(t *testing.T) {
 
 	assert.Equal(t, errInvalidReqJSONParam.Error(), jsonError.Error)
 }
+
+// TestUserHandler_IgnoreServerOwnedFields tests that the timestamps, version and deletion mark sent by a client
+// are never passed to storage or returned, so a client can not hide a user by writing it as deleted
+func TestUserHandler_IgnoreServerOwnedFields(t *testing.T) {
+	t.Parallel()
+
+	serverOwned := `"created_at": "2020-01-01T00:00:00Z", "updated_at": "2020-01-01T00:00:00Z", "version": 7, "deleted_at": "2020-01-01T00:00:00Z"`
+
+	testCases := []struct {
+		name   string
+		method string
+		body   string
+		handle func(h *UserHandler) gin.HandlerFunc
+	}{
+		{
+			name:   "Create",
+			method: http.MethodPost,
+			body:   `{"name": "User-1", ` + serverOwned + `}`,
+			handle: func(h *UserHandler) gin.HandlerFunc { return h.SetHandler },
+		},
+		{
+			name:   "Create batch",
+			method: http.MethodPost,
+			body:   `[{"name": "User-1", ` + serverOwned + `}]`,
+			handle: func(h *UserHandler) gin.HandlerFunc { return h.SetBatchHandler },
+		},
+		{
+			name:   "Update",
+			method: http.MethodPut,
+			body:   `{"name": "User-1", ` + serverOwned + `}`,
+			handle: func(h *UserHandler) gin.HandlerFunc { return h.UpdateHandler },
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			written := make([]*common.User, 0)
+
+			mockStorage := &storageMock.MockStorage{
+				SetFn: func(user *common.User) (int64, error) {
+					written = append(written, user)
+
+					return 1, nil
+				},
+				SetBatchFn: func(users []*common.User) ([]int64, error) {
+					written = append(written, users...)
+
+					return []int64{1}, nil
+				},
+				UpdateFn: func(user *common.User, version int64) (*common.User, error) {
+					written = append(written, user)
+
+					return nil, nil
+				},
+			}
+
+			// Create test handler
+			handler := NewUserHandler(zap.NewNop(), mockStorage, nil, nil, nil, nil, Config{})
+
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(tc.method, "/user/1", strings.NewReader(tc.body))
+			c.Params = append(c.Params, gin.Param{Key: "id", Value: "1"})
+
+			tc.handle(handler)(c)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.NotContains(t, w.Body.String(), "deleted_at")
+			assert.NotContains(t, w.Body.String(), "2020-01-01")
+
+			if assert.Len(t, written, 1) {
+				assert.Nil(t, written[0].DeletedAt)
+				assert.True(t, written[0].CreatedAt.IsZero())
+				assert.True(t, written[0].UpdatedAt.IsZero())
+				assert.Equal(t, int64(0), written[0].Version)
+			}
+		})
+	}
+}
+
+// TestUserHandler_UpdateValidUser tests the successful update of a user and the invalidation of its cache entry
+func TestUserHandler_UpdateValidUser(t *testing.T) {
+	t.Parallel()
+
+	mockStorage := &storageMock.MockStorage{
+		UpdateFn: func(user *common.User, version int64) (*common.User, error) {
+			return nil, nil
+		},
+	}
+
+	for _, sc := range storageCases(mockStorage, &common.User{Name: "User-1"}) {
+		sc := sc
+
+		t.Run(sc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var invalidatedKey int64
+
+			mockCache := &cacheMock.MockCache{
+				DeleteFn: func(key int64) error {
+					invalidatedKey = key
+
+					return nil
+				},
+			}
+
+			handlerConfig := Config{
+				CacheEnabled: true,
+			}
+
+			// Create test handler
+			handler := NewUserHandler(zap.NewNop(), sc.newStorage(t), mockCache, nil, nil, nil, handlerConfig)
+
+			// Create a new HTTP request with a valid user JSON body
+			userJSON := `{"Name": "User-1-updated"}`
+
+			req, err := http.NewRequest(http.MethodPut, "/user/1", strings.NewReader(userJSON))
+			if err != nil {
+				t.Fatalf("Failed to create request: %v", err)
+			}
+
+			req.Header.Set("Content-Type", "application/json")
+
+			// Create a response recorder
+			w := httptest.NewRecorder()
+
+			// Create a new context from the request and response recorder
+			c, _ := gin.CreateTestContext(w)
+			c.Request = req
+			c.Params = append(c.Params, gin.Param{Key: "id", Value: "1"})
+
+			// Call the UpdateHandler function
+			handler.UpdateHandler(c)
+
+			// Check the response
+			assert.Equal(t, http.StatusOK, w.Code)
+
+			var user common.User
+
+			err = json.Unmarshal(w.Body.Bytes(), &user)
+			if err != nil {
+				t.Fatalf("Failed to unmarshal response: %v", err)
+			}
+
+			assert.Equal(t, int64(1), user.ID)
+			assert.Equal(t, "User-1-updated", user.Name)
+			assert.Equal(t, int64(1), invalidatedKey)
+		})
+	}
+}
+
+// TestUserHandler_UpdateMissingUser tests the behavior of the UpdateHandler when the user does not exist
+func TestUserHandler_UpdateMissingUser(t *testing.T) {
+	t.Parallel()
+
+	mockStorage := &storageMock.MockStorage{
+		UpdateFn: func(user *common.User, version int64) (*common.User, error) {
+			return nil, common.ErrUserNotFound
+		},
+	}
+
+	for _, sc := range storageCases(mockStorage) {
+		sc := sc
+
+		t.Run(sc.name, func(t *testing.T) {
+			t.Parallel()
+
+			mockCache := &cacheMock.MockCache{}
+
+			handlerConfig := Config{
+				CacheEnabled: true,
+			}
+
+			// Create test handler
+			handler := NewUserHandler(zap.NewNop(), sc.newStorage(t), mockCache, nil, nil, nil, handlerConfig)
+
+			// Create a new HTTP request for a user which does not exist
+			userJSON := `{"Name": "User-1"}`
+
+			req, err := http.NewRequest(http.MethodPut, "/user/1", strings.NewReader(userJSON))
+			if err != nil {
+				t.Fatalf("Failed to create request: %v", err)
+			}
+
+			req.Header.Set("Content-Type", "application/json")
+
+			// Create a response recorder
+			w := httptest.NewRecorder()
+
+			// Create a new context from the request and response recorder
+			c, _ := gin.CreateTestContext(w)
+			c.Request = req
+			c.Params = append(c.Params, gin.Param{Key: "id", Value: "1"})
+
+			// Call the UpdateHandler function
+			handler.UpdateHandler(c)
+
+			// Check the response
+			assert.Equal(t, http.StatusNotFound, w.Code)
+
+			var jsonError common.ErrorResponse
+
+			err = json.Unmarshal(w.Body.Bytes(), &jsonError)
+			if err != nil {
+				t.Fatalf("Failed to unmarshal response: %v", err)
+			}
+
+			assert.Equal(t, common.ErrUserNotFound.Error(), jsonError.Error)
+		})
+	}
+}
+
+// TestUserHandler_UpdateInternalError tests the behavior of the UpdateHandler when there's an internal error
+func TestUserHandler_UpdateInternalError(t *testing.T) {
+	t.Parallel()
+
+	mockStorage := &storageMock.MockStorage{
+		UpdateFn: func(user *common.User, version int64) (*common.User, error) {
+			return nil, errInternal
+		},
+	}
+	mockCache := &cacheMock.MockCache{}
+
+	handlerConfig := Config{
+		CacheEnabled: true,
+	}
+
+	// Create test handler
+	handler := NewUserHandler(zap.NewNop(), mockStorage, mockCache, nil, nil, nil, handlerConfig)
+
+	userJSON := `{"Name": "User-1"}`
+
+	req, err := http.NewRequest(http.MethodPut, "/user/1", strings.NewReader(userJSON))
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	// Create a response recorder
+	w := httptest.NewRecorder()
+
+	// Create a new context from the request and response recorder
+	c, _ := gin.CreateTestContext(w)
+	c.Request = req
+	c.Params = append(c.Params, gin.Param{Key: "id", Value: "1"})
+
+	// Call the UpdateHandler function
+	handler.UpdateHandler(c)
+
+	// Check the response
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+
+	var jsonError common.ErrorResponse
+
+	err = json.Unmarshal(w.Body.Bytes(), &jsonError)
+	if err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+
+	assert.Equal(t, errInternal.Error(), jsonError.Error)
+}
+
+// TestUserHandler_UpdateMissingParams tests the behavior of the UpdateHandler when provided with missing parameters
+func TestUserHandler_UpdateMissingParams(t *testing.T) {
+	t.Parallel()
+
+	mockStorage := &storageMock.MockStorage{}
+	mockCache := &cacheMock.MockCache{}
+
+	handlerConfig := Config{
+		CacheEnabled: true,
+	}
+
+	// Create test handler
+	handler := NewUserHandler(zap.NewNop(), mockStorage, mockCache, nil, nil, nil, handlerConfig)
+
+	// Create a new HTTP request with missing user name
+	userJSON := `{}`
+
+	req, err := http.NewRequest(http.MethodPut, "/user/1", strings.NewReader(userJSON))
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	// Create a response recorder
+	w := httptest.NewRecorder()
+
+	// Create a new context from the request and response recorder
+	c, _ := gin.CreateTestContext(w)
+	c.Request = req
+	c.Params = append(c.Params, gin.Param{Key: "id", Value: "1"})
+
+	// Call the UpdateHandler function
+	handler.UpdateHandler(c)
+
+	// Check the response
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	var jsonError common.ErrorResponse
+
+	err = json.Unmarshal(w.Body.Bytes(), &jsonError)
+	if err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+
+	assert.Equal(t, errInvalidUserName.Error(), jsonError.Error)
+}
+
+// TestUserHandler_DeleteValidUser tests the successful deletion of a user and its eviction from the cache
+func TestUserHandler_DeleteValidUser(t *testing.T) {
+	t.Parallel()
+
+	mockStorage := &storageMock.MockStorage{
+		DeleteFn: func(key int64, version int64) (*common.User, error) {
+			return nil, nil
+		},
+	}
+
+	for _, sc := range storageCases(mockStorage, &common.User{Name: "User-1"}) {
+		sc := sc
+
+		t.Run(sc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var evictedKey int64
+
+			mockCache := &cacheMock.MockCache{
+				DeleteFn: func(key int64) error {
+					evictedKey = key
+
+					return nil
+				},
+			}
+
+			handlerConfig := Config{
+				CacheEnabled: true,
+			}
+
+			// Create test handler
+			handler := NewUserHandler(zap.NewNop(), sc.newStorage(t), mockCache, nil, nil, nil, handlerConfig)
+
+			// Create a response recorder
+			w := httptest.NewRecorder()
+
+			// Create a new context from the request and response recorder
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodDelete, "/user/1", nil)
+
+			// Set the "id" parameter
+			c.Params = append(c.Params, gin.Param{Key: "id", Value: "1"})
+
+			// Call the DeleteHandler function
+			handler.DeleteHandler(c)
+
+			// Check the response
+			assert.Equal(t, http.StatusNoContent, c.Writer.Status())
+			assert.Equal(t, int64(1), evictedKey)
+		})
+	}
+}
+
+// TestUserHandler_DeleteMissingUser tests the behavior of the DeleteHandler when the user does not exist
+func TestUserHandler_DeleteMissingUser(t *testing.T) {
+	t.Parallel()
+
+	mockStorage := &storageMock.MockStorage{
+		DeleteFn: func(key int64, version int64) (*common.User, error) {
+			return nil, common.ErrUserNotFound
+		},
+	}
+
+	for _, sc := range storageCases(mockStorage) {
+		sc := sc
+
+		t.Run(sc.name, func(t *testing.T) {
+			t.Parallel()
+
+			mockCache := &cacheMock.MockCache{}
+
+			handlerConfig := Config{
+				CacheEnabled: true,
+			}
+
+			// Create test handler
+			handler := NewUserHandler(zap.NewNop(), sc.newStorage(t), mockCache, nil, nil, nil, handlerConfig)
+
+			// Create a response recorder
+			w := httptest.NewRecorder()
+
+			// Create a new context from the request and response recorder
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodDelete, "/user/1", nil)
+
+			// Set the "id" parameter
+			c.Params = append(c.Params, gin.Param{Key: "id", Value: "1"})
+
+			// Call the DeleteHandler function
+			handler.DeleteHandler(c)
+
+			// Check the response
+			assert.Equal(t, http.StatusNotFound, w.Code)
+
+			var jsonError common.ErrorResponse
+
+			err := json.Unmarshal(w.Body.Bytes(), &jsonError)
+			if err != nil {
+				t.Fatalf("Failed to unmarshal response: %v", err)
+			}
+
+			assert.Equal(t, common.ErrUserNotFound.Error(), jsonError.Error)
+		})
+	}
+}
+
+// TestUserHandler_DeleteWithInvalidParams tests the behavior of the DeleteHandler when provided with invalid parameters
+func TestUserHandler_DeleteWithInvalidParams(t *testing.T) {
+	t.Parallel()
+
+	mockStorage := &storageMock.MockStorage{}
+	mockCache := &cacheMock.MockCache{}
+
+	handlerConfig := Config{
+		CacheEnabled: true,
+	}
+
+	// Create test handler
+	handler := NewUserHandler(zap.NewNop(), mockStorage, mockCache, nil, nil, nil, handlerConfig)
+
+	// Create a response recorder
+	w := httptest.NewRecorder()
+
+	// Create a new context from the request and response recorder
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodDelete, "/user/1", nil)
+
+	// Set the "id" parameter
+	c.Params = append(c.Params, gin.Param{Key: "id", Value: "asd"})
+
+	// Call the DeleteHandler function
+	handler.DeleteHandler(c)
+
+	// Check the response
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	var jsonError common.ErrorResponse
+
+	err := json.Unmarshal(w.Body.Bytes(), &jsonError)
+	if err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+
+	assert.Equal(t, errInvalidUserID.Error(), jsonError.Error)
+}
+
+// TestUserHandler_ListValidUsers tests the successful retrieval of a page of users
+func TestUserHandler_ListValidUsers(t *testing.T) {
+	t.Parallel()
+
+	mockStorage := &storageMock.MockStorage{
+		ListFn: func(cursor string, limit int) ([]*common.User, string, error) {
+			assert.Equal(t, "cursor-1", cursor)
+			assert.Equal(t, 2, limit)
+
+			return []*common.User{{ID: 1, Name: "User-1"}, {ID: 2, Name: "User-2"}}, "cursor-2", nil
+		},
+	}
+	mockCache := &cacheMock.MockCache{}
+
+	handlerConfig := Config{
+		CacheEnabled: true,
+	}
+
+	// Create test handler
+	handler := NewUserHandler(zap.NewNop(), mockStorage, mockCache, nil, nil, nil, handlerConfig)
+
+	req, err := http.NewRequest(http.MethodGet, "/user?cursor=cursor-1&limit=2", nil)
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+
+	// Create a response recorder
+	w := httptest.NewRecorder()
+
+	// Create a new context from the request and response recorder
+	c, _ := gin.CreateTestContext(w)
+	c.Request = req
+
+	// Call the ListHandler function
+	handler.ListHandler(c)
+
+	// Check the response
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var userList common.UserList
+
+	err = json.Unmarshal(w.Body.Bytes(), &userList)
+	if err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+
+	assert.Len(t, userList.Users, 2)
+	assert.Equal(t, "User-2", userList.Users[1].Name)
+	assert.Equal(t, "cursor-2", userList.NextCursor)
+}
+
+// TestUserHandler_ListInvalidLimit tests the behavior of the ListHandler when provided with an invalid limit
+func TestUserHandler_ListInvalidLimit(t *testing.T) {
+	t.Parallel()
+
+	mockStorage := &storageMock.MockStorage{}
+	mockCache := &cacheMock.MockCache{}
+
+	handlerConfig := Config{
+		CacheEnabled: true,
+	}
+
+	// Create test handler
+	handler := NewUserHandler(zap.NewNop(), mockStorage, mockCache, nil, nil, nil, handlerConfig)
+
+	for _, limit := range []string{"0", "-1", "1001", "asd"} {
+		req, err := http.NewRequest(http.MethodGet, "/user?limit="+limit, nil)
+		if err != nil {
+			t.Fatalf("Failed to create request: %v", err)
+		}
+
+		// Create a response recorder
+		w := httptest.NewRecorder()
+
+		// Create a new context from the request and response recorder
+		c, _ := gin.CreateTestContext(w)
+		c.Request = req
+
+		// Call the ListHandler function
+		handler.ListHandler(c)
+
+		// Check the response
+		assert.Equal(t, http.StatusBadRequest, w.Code, "For limit %s", limit)
+
+		var jsonError common.ErrorResponse
+
+		err = json.Unmarshal(w.Body.Bytes(), &jsonError)
+		if err != nil {
+			t.Fatalf("Failed to unmarshal response: %v", err)
+		}
+
+		assert.Equal(t, errInvalidListLimit.Error(), jsonError.Error)
+	}
+}
+
+// TestUserHandler_ListInvalidCursor tests the behavior of the ListHandler when the storage rejects the cursor
+func TestUserHandler_ListInvalidCursor(t *testing.T) {
+	t.Parallel()
+
+	mockStorage := &storageMock.MockStorage{
+		ListFn: func(cursor string, limit int) ([]*common.User, string, error) {
+			return nil, "", common.ErrInvalidCursor
+		},
+	}
+
+	for _, sc := range storageCases(mockStorage) {
+		sc := sc
+
+		t.Run(sc.name, func(t *testing.T) {
+			t.Parallel()
+
+			mockCache := &cacheMock.MockCache{}
+
+			handlerConfig := Config{
+				CacheEnabled: true,
+			}
+
+			// Create test handler
+			handler := NewUserHandler(zap.NewNop(), sc.newStorage(t), mockCache, nil, nil, nil, handlerConfig)
+
+			req, err := http.NewRequest(http.MethodGet, "/user?cursor=invalid", nil)
+			if err != nil {
+				t.Fatalf("Failed to create request: %v", err)
+			}
+
+			// Create a response recorder
+			w := httptest.NewRecorder()
+
+			// Create a new context from the request and response recorder
+			c, _ := gin.CreateTestContext(w)
+			c.Request = req
+
+			// Call the ListHandler function
+			handler.ListHandler(c)
+
+			// Check the response
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+
+			var jsonError common.ErrorResponse
+
+			err = json.Unmarshal(w.Body.Bytes(), &jsonError)
+			if err != nil {
+				t.Fatalf("Failed to unmarshal response: %v", err)
+			}
+
+			assert.Equal(t, common.ErrInvalidCursor.Error(), jsonError.Error)
+		})
+	}
+}
+
+// TestUserHandler_SetBatchValidUsers tests the successful setting of a batch of valid users
+func TestUserHandler_SetBatchValidUsers(t *testing.T) {
+	t.Parallel()
+
+	mockStorage := &storageMock.MockStorage{
+		SetBatchFn: func(users []*common.User) ([]int64, error) {
+			assert.Len(t, users, 2)
+			assert.Equal(t, "User-1", users[0].Name)
+			assert.Equal(t, "User-2", users[1].Name)
+
+			return []int64{1, 2}, nil
+		},
+	}
+
+	for _, sc := range storageCases(mockStorage) {
+		sc := sc
+
+		t.Run(sc.name, func(t *testing.T) {
+			t.Parallel()
+
+			mockCache := &cacheMock.MockCache{}
+
+			handlerConfig := Config{
+				CacheEnabled: true,
+			}
+
+			// Create test handler
+			handler := NewUserHandler(zap.NewNop(), sc.newStorage(t), mockCache, nil, nil, nil, handlerConfig)
+
+			// Create a new HTTP request with a valid batch JSON body
+			usersJSON := `[{"name": "User-1"}, {"name": "User-2"}]`
+
+			req, err := http.NewRequest(http.MethodPost, "/user/batch", strings.NewReader(usersJSON))
+			if err != nil {
+				t.Fatalf("Failed to create request: %v", err)
+			}
+
+			req.Header.Set("Content-Type", "application/json")
+
+			// Create a response recorder
+			w := httptest.NewRecorder()
+
+			// Create a new context from the request and response recorder
+			c, _ := gin.CreateTestContext(w)
+			c.Request = req
+
+			// Call the SetBatchHandler function
+			handler.SetBatchHandler(c)
+
+			// Check the response
+			assert.Equal(t, http.StatusOK, w.Code)
+
+			var users []common.User
+
+			err = json.Unmarshal(w.Body.Bytes(), &users)
+			if err != nil {
+				t.Fatalf("Failed to unmarshal response: %v", err)
+			}
+
+			assert.Len(t, users, 2)
+			assert.Equal(t, int64(1), users[0].ID)
+			assert.Equal(t, "User-1", users[0].Name)
+			assert.Equal(t, int64(2), users[1].ID)
+			assert.Equal(t, "User-2", users[1].Name)
+		})
+	}
+}
+
+// TestUserHandler_SetBatchInvalidUsers tests that a batch containing invalid users is rejected with per-item errors
+func TestUserHandler_SetBatchInvalidUsers(t *testing.T) {
+	t.Parallel()
+
+	mockStorage := &storageMock.MockStorage{
+		SetBatchFn: func(users []*common.User) ([]int64, error) {
+			t.Fatalf("Batch with invalid users should not be stored")
+
+			return nil, nil
+		},
+	}
+	mockCache := &cacheMock.MockCache{}
+
+	handlerConfig := Config{
+		CacheEnabled: true,
+	}
+
+	// Create test handler
+	handler := NewUserHandler(zap.NewNop(), mockStorage, mockCache, nil, nil, nil, handlerConfig)
+
+	// Create a new HTTP request with a batch where the second and third users have no name
+	usersJSON := `[{"name": "User-1"}, {}, {"name": ""}]`
+
+	req, err := http.NewRequest(http.MethodPost, "/user/batch", strings.NewReader(usersJSON))
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	// Create a response recorder
+	w := httptest.NewRecorder()
+
+	// Create a new context from the request and response recorder
+	c, _ := gin.CreateTestContext(w)
+	c.Request = req
+
+	// Call the SetBatchHandler function
+	handler.SetBatchHandler(c)
+
+	// Check the response
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	var batchError common.BatchErrorResponse
+
+	err = json.Unmarshal(w.Body.Bytes(), &batchError)
+	if err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+
+	assert.Equal(t, errInvalidBatchItems.Error(), batchError.Error)
+	assert.Equal(t, []common.BatchItemError{
+		{Index: 1, Error: errInvalidUserName.Error()},
+		{Index: 2, Error: errInvalidUserName.Error()},
+	}, batchError.Items)
+}
+
+// TestUserHandler_SetBatchEmpty tests the behavior of the SetBatchHandler when provided with an empty batch
+func TestUserHandler_SetBatchEmpty(t *testing.T) {
+	t.Parallel()
+
+	mockStorage := &storageMock.MockStorage{}
+	mockCache := &cacheMock.MockCache{}
+
+	handlerConfig := Config{
+		CacheEnabled: true,
+	}
+
+	// Create test handler
+	handler := NewUserHandler(zap.NewNop(), mockStorage, mockCache, nil, nil, nil, handlerConfig)
+
+	req, err := http.NewRequest(http.MethodPost, "/user/batch", strings.NewReader(`[]`))
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	// Create a response recorder
+	w := httptest.NewRecorder()
+
+	// Create a new context from the request and response recorder
+	c, _ := gin.CreateTestContext(w)
+	c.Request = req
+
+	// Call the SetBatchHandler function
+	handler.SetBatchHandler(c)
+
+	// Check the response
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	var jsonError common.ErrorResponse
+
+	err = json.Unmarshal(w.Body.Bytes(), &jsonError)
+	if err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+
+	assert.Equal(t, errEmptyBatch.Error(), jsonError.Error)
+}
+
+// TestUserHandler_SetBatchInternalError tests the behavior of the SetBatchHandler when there's an internal error
+func TestUserHandler_SetBatchInternalError(t *testing.T) {
+	t.Parallel()
+
+	mockStorage := &storageMock.MockStorage{
+		SetBatchFn: func(users []*common.User) ([]int64, error) {
+			return nil, errInternal
+		},
+	}
+	mockCache := &cacheMock.MockCache{}
+
+	handlerConfig := Config{
+		CacheEnabled: true,
+	}
+
+	// Create test handler
+	handler := NewUserHandler(zap.NewNop(), mockStorage, mockCache, nil, nil, nil, handlerConfig)
+
+	req, err := http.NewRequest(http.MethodPost, "/user/batch", strings.NewReader(`[{"name": "User-1"}]`))
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	// Create a response recorder
+	w := httptest.NewRecorder()
+
+	// Create a new context from the request and response recorder
+	c, _ := gin.CreateTestContext(w)
+	c.Request = req
+
+	// Call the SetBatchHandler function
+	handler.SetBatchHandler(c)
+
+	// Check the response
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+
+	var jsonError common.ErrorResponse
+
+	err = json.Unmarshal(w.Body.Bytes(), &jsonError)
+	if err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+
+	assert.Equal(t, errInternal.Error(), jsonError.Error)
+}
+
+// TestUserHandler_GetMultiCacheAndDB tests retrieval of multiple users where some are cached, some stored and some missing
+func TestUserHandler_GetMultiCacheAndDB(t *testing.T) {
+	t.Parallel()
+
+	mockStorage := &storageMock.MockStorage{
+		GetMultiFn: func(keys []int64) (map[int64]*common.User, error) {
+			// Only cache misses should be fetched from storage
+			assert.Equal(t, []int64{2, 3}, keys)
+
+			return map[int64]*common.User{2: {ID: 2, Name: "User-2"}}, nil
+		},
+	}
+
+	for _, sc := range storageCases(mockStorage, &common.User{Name: "User-1"}, &common.User{Name: "User-2"}) {
+		sc := sc
+
+		t.Run(sc.name, func(t *testing.T) {
+			t.Parallel()
+
+			cachedKeys := make([]int64, 0)
+
+			mockCache := &cacheMock.MockCache{
+				GetMultiFn: func(keys []int64) (map[int64]*common.User, error) {
+					assert.Equal(t, []int64{1, 2, 3}, keys)
+
+					return map[int64]*common.User{1: {ID: 1, Name: "User-1"}}, nil
+				},
+				SetFn: func(key int64, value *common.User) error {
+					cachedKeys = append(cachedKeys, key)
+
+					return nil
+				},
+			}
+
+			handlerConfig := Config{
+				CacheEnabled: true,
+			}
+
+			// Create test handler
+			handler := NewUserHandler(zap.NewNop(), sc.newStorage(t), mockCache, nil, nil, nil, handlerConfig)
+
+			req, err := http.NewRequest(http.MethodGet, "/users?ids=1,2,3,1", nil)
+			if err != nil {
+				t.Fatalf("Failed to create request: %v", err)
+			}
+
+			// Create a response recorder
+			w := httptest.NewRecorder()
+
+			// Create a new context from the request and response recorder
+			c, _ := gin.CreateTestContext(w)
+			c.Request = req
+
+			// Call the GetMultiHandler function
+			handler.GetMultiHandler(c)
+
+			// Check the response
+			assert.Equal(t, http.StatusOK, w.Code)
+
+			var response common.UserMultiResponse
+
+			err = json.Unmarshal(w.Body.Bytes(), &response)
+			if err != nil {
+				t.Fatalf("Failed to unmarshal response: %v", err)
+			}
+
+			assert.Len(t, response.Users, 2)
+			assert.Equal(t, "User-1", response.Users[0].Name)
+			assert.Equal(t, "User-2", response.Users[1].Name)
+			assert.Equal(t, []int64{3}, response.Missing)
+
+			// The user fetched from storage should be back-filled into cache
+			assert.Equal(t, []int64{2}, cachedKeys)
+		})
+	}
+}
+
+// TestUserHandler_GetMultiInvalidParams tests the behavior of the GetMultiHandler when provided with invalid IDs
+func TestUserHandler_GetMultiInvalidParams(t *testing.T) {
+	t.Parallel()
+
+	mockStorage := &storageMock.MockStorage{}
+	mockCache := &cacheMock.MockCache{}
+
+	handlerConfig := Config{
+		CacheEnabled: true,
+	}
+
+	// Create test handler
+	handler := NewUserHandler(zap.NewNop(), mockStorage, mockCache, nil, nil, nil, handlerConfig)
+
+	for _, ids := range []string{"", "1,asd", "1,,2"} {
+		req, err := http.NewRequest(http.MethodGet, "/users?ids="+ids, nil)
+		if err != nil {
+			t.Fatalf("Failed to create request: %v", err)
+		}
+
+		// Create a response recorder
+		w := httptest.NewRecorder()
+
+		// Create a new context from the request and response recorder
+		c, _ := gin.CreateTestContext(w)
+		c.Request = req
+
+		// Call the GetMultiHandler function
+		handler.GetMultiHandler(c)
+
+		// Check the response
+		assert.Equal(t, http.StatusBadRequest, w.Code, "For ids %s", ids)
+
+		var jsonError common.ErrorResponse
+
+		err = json.Unmarshal(w.Body.Bytes(), &jsonError)
+		if err != nil {
+			t.Fatalf("Failed to unmarshal response: %v", err)
+		}
+
+		assert.Equal(t, errInvalidUserIDs.Error(), jsonError.Error)
+	}
+}
+
+// TestUserHandler_GetMultiInternalError tests the behavior of the GetMultiHandler when the storage fails
+func TestUserHandler_GetMultiInternalError(t *testing.T) {
+	t.Parallel()
+
+	mockStorage := &storageMock.MockStorage{
+		GetMultiFn: func(keys []int64) (map[int64]*common.User, error) {
+			return nil, errInternal
+		},
+	}
+	mockCache := &cacheMock.MockCache{
+		GetMultiFn: func(keys []int64) (map[int64]*common.User, error) {
+			return nil, errUserNotInCache
+		},
+	}
+
+	handlerConfig := Config{
+		CacheEnabled: true,
+	}
+
+	// Create test handler
+	handler := NewUserHandler(zap.NewNop(), mockStorage, mockCache, nil, nil, nil, handlerConfig)
+
+	req, err := http.NewRequest(http.MethodGet, "/users?ids=1,2", nil)
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+
+	// Create a response recorder
+	w := httptest.NewRecorder()
+
+	// Create a new context from the request and response recorder
+	c, _ := gin.CreateTestContext(w)
+	c.Request = req
+
+	// Call the GetMultiHandler function
+	handler.GetMultiHandler(c)
+
+	// Check the response
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+
+	var jsonError common.ErrorResponse
+
+	err = json.Unmarshal(w.Body.Bytes(), &jsonError)
+	if err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+
+	assert.Equal(t, errInternal.Error(), jsonError.Error)
+}
+
+// TestUserHandler_SearchValidUsers tests the successful search of users by name prefix
+func TestUserHandler_SearchValidUsers(t *testing.T) {
+	t.Parallel()
+
+	mockStorage := &storageMock.MockStorage{
+		FindByNameFn: func(name string, prefix bool) ([]*common.User, error) {
+			assert.Equal(t, "ali", name)
+			assert.True(t, prefix)
+
+			return []*common.User{{ID: 1, Name: "alice"}, {ID: 2, Name: "alicia"}}, nil
+		},
+	}
+
+	for _, sc := range storageCases(mockStorage, &common.User{Name: "alice"}, &common.User{Name: "alicia"}, &common.User{Name: "bob"}) {
+		sc := sc
+
+		t.Run(sc.name, func(t *testing.T) {
+			t.Parallel()
+
+			mockCache := &cacheMock.MockCache{}
+
+			handlerConfig := Config{
+				CacheEnabled: true,
+			}
+
+			// Create test handler
+			handler := NewUserHandler(zap.NewNop(), sc.newStorage(t), mockCache, nil, nil, nil, handlerConfig)
+
+			req, err := http.NewRequest(http.MethodGet, "/user/search?name=ali&match=prefix", nil)
+			if err != nil {
+				t.Fatalf("Failed to create request: %v", err)
+			}
+
+			// Create a response recorder
+			w := httptest.NewRecorder()
+
+			// Create a new context from the request and response recorder
+			c, _ := gin.CreateTestContext(w)
+			c.Request = req
+
+			// Call the SearchHandler function
+			handler.SearchHandler(c)
+
+			// Check the response
+			assert.Equal(t, http.StatusOK, w.Code)
+
+			var userList common.UserList
+
+			err = json.Unmarshal(w.Body.Bytes(), &userList)
+			if err != nil {
+				t.Fatalf("Failed to unmarshal response: %v", err)
+			}
+
+			assert.Len(t, userList.Users, 2)
+			assert.Equal(t, "alicia", userList.Users[1].Name)
+		})
+	}
+}
+
+// TestUserHandler_SearchInvalidParams tests the behavior of the SearchHandler when provided with invalid parameters
+func TestUserHandler_SearchInvalidParams(t *testing.T) {
+	t.Parallel()
+
+	mockStorage := &storageMock.MockStorage{}
+	mockCache := &cacheMock.MockCache{}
+
+	handlerConfig := Config{
+		CacheEnabled: true,
+	}
+
+	// Create test handler
+	handler := NewUserHandler(zap.NewNop(), mockStorage, mockCache, nil, nil, nil, handlerConfig)
+
+	tests := []struct {
+		query string
+		err   error
+	}{
+		{"/user/search", errInvalidUserName},
+		{"/user/search?name=", errInvalidUserName},
+		{"/user/search?name=alice&match=fuzzy", errInvalidMatchMode},
+	}
+
+	for _, test := range tests {
+		req, err := http.NewRequest(http.MethodGet, test.query, nil)
+		if err != nil {
+			t.Fatalf("Failed to create request: %v", err)
+		}
+
+		// Create a response recorder
+		w := httptest.NewRecorder()
+
+		// Create a new context from the request and response recorder
+		c, _ := gin.CreateTestContext(w)
+		c.Request = req
+
+		// Call the SearchHandler function
+		handler.SearchHandler(c)
+
+		// Check the response
+		assert.Equal(t, http.StatusBadRequest, w.Code, "For query %s", test.query)
+
+		var jsonError common.ErrorResponse
+
+		err = json.Unmarshal(w.Body.Bytes(), &jsonError)
+		if err != nil {
+			t.Fatalf("Failed to unmarshal response: %v", err)
+		}
+
+		assert.Equal(t, test.err.Error(), jsonError.Error)
+	}
+}
+
+// TestUserHandler_FullTextSearch tests that full-text search results are resolved from the vault in ranking order
+func TestUserHandler_FullTextSearch(t *testing.T) {
+	t.Parallel()
+
+	mockStorage := &storageMock.MockStorage{
+		GetMultiFn: func(keys []int64) (map[int64]*common.User, error) {
+			assert.Equal(t, []int64{3, 1, 2}, keys)
+
+			// User 2 was deleted after it was indexed
+			return map[int64]*common.User{
+				1: {ID: 1, Name: "Alexander"},
+				3: {ID: 3, Name: "Alex"},
+			}, nil
+		},
+	}
+	mockCache := &cacheMock.MockCache{}
+	mockIndex := &searchMock.MockIndex{
+		SearchFn: func(query string, limit int) []int64 {
+			assert.Equal(t, "alx", query)
+			assert.Equal(t, 5, limit)
+
+			return []int64{3, 1, 2}
+		},
+	}
+
+	handlerConfig := Config{
+		CacheEnabled:  true,
+		SearchEnabled: true,
+	}
+
+	// Create test handler
+	handler := NewUserHandler(zap.NewNop(), mockStorage, mockCache, mockIndex, nil, nil, handlerConfig)
+
+	req, err := http.NewRequest(http.MethodGet, "/user/search?q=alx&limit=5", nil)
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+
+	// Create a response recorder
+	w := httptest.NewRecorder()
+
+	// Create a new context from the request and response recorder
+	c, _ := gin.CreateTestContext(w)
+	c.Request = req
+
+	// Call the SearchHandler function
+	handler.SearchHandler(c)
+
+	// Check the response
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var userList common.UserList
+
+	err = json.Unmarshal(w.Body.Bytes(), &userList)
+	if err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+
+	assert.Equal(t, []*common.User{{ID: 3, Name: "Alex"}, {ID: 1, Name: "Alexander"}}, userList.Users)
+}
+
+// TestUserHandler_FullTextSearchInvalidParams tests the behavior of the full-text search when it can not be served
+func TestUserHandler_FullTextSearchInvalidParams(t *testing.T) {
+	t.Parallel()
+
+	mockStorage := &storageMock.MockStorage{}
+	mockCache := &cacheMock.MockCache{}
+	mockIndex := &searchMock.MockIndex{}
+
+	tests := []struct {
+		query         string
+		searchEnabled bool
+		status        int
+		err           error
+	}{
+		{"/user/search?q=alice&limit=0", true, http.StatusBadRequest, errInvalidSearchLimit},
+		{"/user/search?q=alice&limit=101", true, http.StatusBadRequest, errInvalidSearchLimit},
+		{"/user/search?q=alice&limit=abc", true, http.StatusBadRequest, errInvalidSearchLimit},
+		{"/user/search?q=alice", false, http.StatusNotImplemented, errSearchDisabled},
+	}
+
+	for _, test := range tests {
+		handlerConfig := Config{
+			CacheEnabled:  true,
+			SearchEnabled: test.searchEnabled,
+		}
+
+		// Create test handler
+		handler := NewUserHandler(zap.NewNop(), mockStorage, mockCache, mockIndex, nil, nil, handlerConfig)
+
+		req, err := http.NewRequest(http.MethodGet, test.query, nil)
+		if err != nil {
+			t.Fatalf("Failed to create request: %v", err)
+		}
+
+		// Create a response recorder
+		w := httptest.NewRecorder()
+
+		// Create a new context from the request and response recorder
+		c, _ := gin.CreateTestContext(w)
+		c.Request = req
+
+		// Call the SearchHandler function
+		handler.SearchHandler(c)
+
+		// Check the response
+		assert.Equal(t, test.status, w.Code, "For query %s", test.query)
+
+		var jsonError common.ErrorResponse
+
+		err = json.Unmarshal(w.Body.Bytes(), &jsonError)
+		if err != nil {
+			t.Fatalf("Failed to unmarshal response: %v", err)
+		}
+
+		assert.Equal(t, test.err.Error(), jsonError.Error)
+	}
+}
+
+// TestUserHandler_SearchIndexMaintained tests that writes going through the handler are reflected in the search index
+func TestUserHandler_SearchIndexMaintained(t *testing.T) {
+	t.Parallel()
+
+	var (
+		indexed []*common.User
+		removed []int64
+	)
+
+	mockStorage := &storageMock.MockStorage{
+		SetFn: func(user *common.User) (int64, error) {
+			return 1, nil
+		},
+	}
+	mockCache := &cacheMock.MockCache{}
+	mockIndex := &searchMock.MockIndex{
+		AddFn: func(user *common.User) {
+			indexed = append(indexed, user)
+		},
+		RemoveFn: func(id int64) {
+			removed = append(removed, id)
+		},
+	}
+
+	handlerConfig := Config{
+		SearchEnabled: true,
+	}
+
+	// Create test handler
+	handler := NewUserHandler(zap.NewNop(), mockStorage, mockCache, mockIndex, nil, nil, handlerConfig)
+
+	// Create the user
+	req, err := http.NewRequest(http.MethodPost, "/user", strings.NewReader(`{"Name": "Alice"}`))
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = req
+
+	handler.SetHandler(c)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Rename the user
+	req, err = http.NewRequest(http.MethodPut, "/user/1", strings.NewReader(`{"Name": "Alicia"}`))
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	w = httptest.NewRecorder()
+	c, _ = gin.CreateTestContext(w)
+	c.Request = req
+	c.Params = append(c.Params, gin.Param{Key: "id", Value: "1"})
+
+	handler.UpdateHandler(c)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Delete the user
+	w = httptest.NewRecorder()
+	c, _ = gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodDelete, "/user/1", nil)
+	c.Params = append(c.Params, gin.Param{Key: "id", Value: "1"})
+
+	handler.DeleteHandler(c)
+	assert.Equal(t, http.StatusNoContent, c.Writer.Status())
+
+	assert.Equal(t, []*common.User{{ID: 1, Name: "Alice"}, {ID: 1, Name: "Alicia"}}, indexed)
+	assert.Equal(t, []int64{1}, removed)
+}
+
+// TestUserHandler_GetReturnsETag tests that the GetHandler returns the user version as an ETag
+func TestUserHandler_GetReturnsETag(t *testing.T) {
+	t.Parallel()
+
+	mockStorage := &storageMock.MockStorage{
+		GetFn: func(key int64) (*common.User, error) {
+			return &common.User{ID: key, Name: "User-1", Version: 3}, nil
+		},
+	}
+
+	// Create test handler
+	handler := NewUserHandler(zap.NewNop(), mockStorage, nil, nil, nil, nil, Config{})
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/user/1", nil)
+	c.Params = append(c.Params, gin.Param{Key: "id", Value: "1"})
+
+	handler.GetHandler(c)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `"3"`, w.Header().Get("ETag"))
+}
+
+// TestUserHandler_UpdateIfMatch tests that the UpdateHandler passes the If-Match version to the storage
+func TestUserHandler_UpdateIfMatch(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name            string
+		ifMatch         string
+		storageErr      error
+		expectedVersion int64
+		expectedStatus  int
+		expectedETag    string
+		reachesStorage  bool
+	}{
+		{
+			name:            "No If-Match",
+			ifMatch:         "",
+			expectedVersion: common.AnyVersion,
+			expectedStatus:  http.StatusOK,
+			expectedETag:    `"3"`,
+			reachesStorage:  true,
+		},
+		{
+			name:            "Any version",
+			ifMatch:         "*",
+			expectedVersion: common.AnyVersion,
+			expectedStatus:  http.StatusOK,
+			expectedETag:    `"3"`,
+			reachesStorage:  true,
+		},
+		{
+			name:            "Matching version",
+			ifMatch:         `"2"`,
+			expectedVersion: 2,
+			expectedStatus:  http.StatusOK,
+			expectedETag:    `"3"`,
+			reachesStorage:  true,
+		},
+		{
+			name:            "Stale version",
+			ifMatch:         `"1"`,
+			storageErr:      common.ErrVersionMismatch,
+			expectedVersion: 1,
+			expectedStatus:  http.StatusPreconditionFailed,
+			reachesStorage:  true,
+		},
+		{
+			name:           "Weak tag",
+			ifMatch:        `W/"2"`,
+			expectedStatus: http.StatusPreconditionFailed,
+		},
+		{
+			name:           "Malformed tag",
+			ifMatch:        `"abc"`,
+			expectedStatus: http.StatusPreconditionFailed,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var (
+				called          bool
+				receivedVersion int64
+			)
+
+			mockStorage := &storageMock.MockStorage{
+				UpdateFn: func(user *common.User, version int64) (*common.User, error) {
+					called = true
+					receivedVersion = version
+
+					if tc.storageErr != nil {
+						return nil, tc.storageErr
+					}
+
+					user.Version = 3
+
+					return nil, nil
+				},
+			}
+
+			// Create test handler
+			handler := NewUserHandler(zap.NewNop(), mockStorage, nil, nil, nil, nil, Config{})
+
+			req, err := http.NewRequest(http.MethodPut, "/user/1", strings.NewReader(`{"name": "User-1"}`))
+			if err != nil {
+				t.Fatalf("Failed to create request: %v", err)
+			}
+
+			req.Header.Set("Content-Type", "application/json")
+
+			if tc.ifMatch != "" {
+				req.Header.Set("If-Match", tc.ifMatch)
+			}
+
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = req
+			c.Params = append(c.Params, gin.Param{Key: "id", Value: "1"})
+
+			handler.UpdateHandler(c)
+
+			assert.Equal(t, tc.expectedStatus, w.Code)
+			assert.Equal(t, tc.expectedETag, w.Header().Get("ETag"))
+
+			// Tags which can never match are rejected before reaching the storage
+			assert.Equal(t, tc.reachesStorage, called)
+			assert.Equal(t, tc.expectedVersion, receivedVersion)
+		})
+	}
+}
+
+// TestUserHandler_DeleteVersionMismatch tests that the DeleteHandler responds with 412 when the user changed
+func TestUserHandler_DeleteVersionMismatch(t *testing.T) {
+	t.Parallel()
+
+	var receivedVersion int64
+
+	mockStorage := &storageMock.MockStorage{
+		DeleteFn: func(key int64, version int64) (*common.User, error) {
+			receivedVersion = version
+
+			return nil, common.ErrVersionMismatch
+		},
+	}
+	mockCache := &cacheMock.MockCache{
+		DeleteFn: func(key int64) error {
+			t.Fatalf("cache should not be invalidated")
+
+			return nil
+		},
+	}
+
+	handlerConfig := Config{
+		CacheEnabled: true,
+	}
+
+	// Create test handler
+	handler := NewUserHandler(zap.NewNop(), mockStorage, mockCache, nil, nil, nil, handlerConfig)
+
+	req := httptest.NewRequest(http.MethodDelete, "/user/1", nil)
+	req.Header.Set("If-Match", `"4"`)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = req
+	c.Params = append(c.Params, gin.Param{Key: "id", Value: "1"})
+
+	handler.DeleteHandler(c)
+
+	assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+	assert.Equal(t, int64(4), receivedVersion)
+
+	var jsonError common.ErrorResponse
+
+	err := json.Unmarshal(w.Body.Bytes(), &jsonError)
+	if err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+
+	assert.Equal(t, common.ErrVersionMismatch.Error(), jsonError.Error)
+}
+
+// TestUserHandler_ConditionalGet tests that the GetHandler returns 304 when the client already has the current user
+func TestUserHandler_ConditionalGet(t *testing.T) {
+	t.Parallel()
+
+	updatedAt := time.Date(2023, 9, 1, 10, 30, 15, 500, time.UTC)
+	lastModified := updatedAt.Format(http.TimeFormat)
+
+	testCases := []struct {
+		name           string
+		cacheEnabled   bool
+		headers        map[string]string
+		expectedStatus int
+	}{
+		{
+			name:           "No conditions",
+			headers:        map[string]string{},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Matching ETag from storage",
+			headers:        map[string]string{"If-None-Match": `"2"`},
+			expectedStatus: http.StatusNotModified,
+		},
+		{
+			name:           "Matching ETag from cache",
+			cacheEnabled:   true,
+			headers:        map[string]string{"If-None-Match": `"2"`},
+			expectedStatus: http.StatusNotModified,
+		},
+		{
+			name:           "Matching weak ETag in list",
+			headers:        map[string]string{"If-None-Match": `"1", W/"2"`},
+			expectedStatus: http.StatusNotModified,
+		},
+		{
+			name:           "Any ETag",
+			headers:        map[string]string{"If-None-Match": "*"},
+			expectedStatus: http.StatusNotModified,
+		},
+		{
+			name:           "Stale ETag",
+			headers:        map[string]string{"If-None-Match": `"1"`},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Not modified since",
+			cacheEnabled:   true,
+			headers:        map[string]string{"If-Modified-Since": lastModified},
+			expectedStatus: http.StatusNotModified,
+		},
+		{
+			name:           "Modified since",
+			headers:        map[string]string{"If-Modified-Since": updatedAt.Add(-time.Minute).Format(http.TimeFormat)},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Invalid If-Modified-Since",
+			headers:        map[string]string{"If-Modified-Since": "yesterday"},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name: "If-None-Match takes precedence",
+			headers: map[string]string{
+				"If-None-Match":     `"1"`,
+				"If-Modified-Since": lastModified,
+			},
+			expectedStatus: http.StatusOK,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			user := &common.User{ID: 1, Name: "User-1", Version: 2, UpdatedAt: updatedAt}
+
+			mockStorage := &storageMock.MockStorage{
+				GetFn: func(key int64) (*common.User, error) {
+					if tc.cacheEnabled {
+						t.Fatalf("user should be served from cache")
+					}
+
+					return user, nil
+				},
+			}
+			mockCache := &cacheMock.MockCache{
+				GetFn: func(key int64) (*common.User, error) {
+					return user, nil
+				},
+			}
+
+			handlerConfig := Config{
+				CacheEnabled: tc.cacheEnabled,
+			}
+
+			// Create test handler
+			handler := NewUserHandler(zap.NewNop(), mockStorage, mockCache, nil, nil, nil, handlerConfig)
+
+			req := httptest.NewRequest(http.MethodGet, "/user/1", nil)
+			for key, value := range tc.headers {
+				req.Header.Set(key, value)
+			}
+
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = req
+			c.Params = append(c.Params, gin.Param{Key: "id", Value: "1"})
+
+			handler.GetHandler(c)
+
+			assert.Equal(t, tc.expectedStatus, c.Writer.Status())
+			assert.Equal(t, `"2"`, w.Header().Get("ETag"))
+			assert.Equal(t, lastModified, w.Header().Get("Last-Modified"))
+
+			if tc.expectedStatus == http.StatusNotModified {
+				assert.Empty(t, w.Body.Bytes())
+			}
+		})
+	}
+}
+
+// TestUserHandler_GetDeletedUser tests that the GetHandler responds with 410 for soft deleted users
+func TestUserHandler_GetDeletedUser(t *testing.T) {
+	t.Parallel()
+
+	mockStorage := &storageMock.MockStorage{
+		GetFn: func(key int64) (*common.User, error) {
+			return nil, common.ErrUserDeleted
+		},
+	}
+
+	deletedAt := time.Now().UTC()
+
+	for _, sc := range storageCases(mockStorage, &common.User{Name: "User-1", DeletedAt: &deletedAt}) {
+		sc := sc
+
+		t.Run(sc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// Create test handler
+			handler := NewUserHandler(zap.NewNop(), sc.newStorage(t), nil, nil, nil, nil, Config{})
+
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodGet, "/user/1", nil)
+			c.Params = append(c.Params, gin.Param{Key: "id", Value: "1"})
+
+			handler.GetHandler(c)
+
+			assert.Equal(t, http.StatusGone, w.Code)
+
+			var jsonError common.ErrorResponse
+
+			err := json.Unmarshal(w.Body.Bytes(), &jsonError)
+			if err != nil {
+				t.Fatalf("Failed to unmarshal response: %v", err)
+			}
+
+			assert.Equal(t, common.ErrUserDeleted.Error(), jsonError.Error)
+		})
+	}
+}
+
+// TestUserHandler_SoftDelete tests that the DeleteHandler only marks users as deleted when soft delete is enabled
+func TestUserHandler_SoftDelete(t *testing.T) {
+	t.Parallel()
+
+	deletedAt := time.Now().UTC()
+
+	testCases := []struct {
+		name           string
+		users          []*common.User
+		softDeleteErr  error
+		expectedStatus int
+	}{
+		{
+			name:           "Deleted",
+			users:          []*common.User{{Name: "User-1"}},
+			expectedStatus: http.StatusNoContent,
+		},
+		{
+			name:           "Already deleted",
+			users:          []*common.User{{Name: "User-1", DeletedAt: &deletedAt}},
+			softDeleteErr:  common.ErrUserDeleted,
+			expectedStatus: http.StatusGone,
+		},
+		{
+			name:           "Not found",
+			softDeleteErr:  common.ErrUserNotFound,
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			mockStorage := &storageMock.MockStorage{
+				DeleteFn: func(key int64, version int64) (*common.User, error) {
+					t.Fatalf("user should not be removed")
+
+					return nil, nil
+				},
+				SoftDeleteFn: func(key int64, version int64) (*common.User, error) {
+					assert.Equal(t, int64(1), key)
+
+					return nil, tc.softDeleteErr
+				},
+			}
+
+			for _, sc := range storageCases(mockStorage, tc.users...) {
+				sc := sc
+
+				t.Run(sc.name, func(t *testing.T) {
+					t.Parallel()
+
+					vault := sc.newStorage(t)
+
+					handlerConfig := Config{
+						SoftDeleteEnabled: true,
+					}
+
+					// Create test handler
+					handler := NewUserHandler(zap.NewNop(), vault, nil, nil, nil, nil, handlerConfig)
+
+					w := httptest.NewRecorder()
+					c, _ := gin.CreateTestContext(w)
+					c.Request = httptest.NewRequest(http.MethodDelete, "/user/1", nil)
+					c.Params = append(c.Params, gin.Param{Key: "id", Value: "1"})
+
+					handler.DeleteHandler(c)
+
+					assert.Equal(t, tc.expectedStatus, c.Writer.Status())
+
+					if tc.expectedStatus == http.StatusNoContent && vault != mockStorage {
+						// The user is kept with a deletion mark
+						_, err := vault.Get(1)
+						assert.ErrorIs(t, err, common.ErrUserDeleted)
+					}
+				})
+			}
+		})
+	}
+}
+
+// TestUserHandler_Restore tests the behavior of the RestoreHandler
+func TestUserHandler_Restore(t *testing.T) {
+	t.Parallel()
+
+	deletedAt := time.Now().UTC()
+
+	testCases := []struct {
+		name                string
+		users               []*common.User
+		softDeleteEnabled   bool
+		restoreErr          error
+		mockOnly            bool
+		expectedStatus      int
+		expectedIndexed     bool
+		expectedInvalidated bool
+	}{
+		{
+			name:                "Restored",
+			users:               []*common.User{{Name: "User-1", DeletedAt: &deletedAt}},
+			softDeleteEnabled:   true,
+			expectedStatus:      http.StatusOK,
+			expectedIndexed:     true,
+			expectedInvalidated: true,
+		},
+		{
+			name:              "Not deleted",
+			users:             []*common.User{{Name: "User-1"}},
+			softDeleteEnabled: true,
+			restoreErr:        common.ErrUserNotDeleted,
+			expectedStatus:    http.StatusConflict,
+		},
+		{
+			name:              "Not found",
+			softDeleteEnabled: true,
+			restoreErr:        common.ErrUserNotFound,
+			expectedStatus:    http.StatusNotFound,
+		},
+		{
+			name:              "Internal error",
+			softDeleteEnabled: true,
+			restoreErr:        errInternal,
+			mockOnly:          true,
+			expectedStatus:    http.StatusInternalServerError,
+		},
+		{
+			name:           "Soft delete disabled",
+			users:          []*common.User{{Name: "User-1", DeletedAt: &deletedAt}},
+			expectedStatus: http.StatusNotImplemented,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			mockStorage := &storageMock.MockStorage{
+				RestoreFn: func(key int64) (*common.User, *common.User, error) {
+					if tc.restoreErr != nil {
+						return nil, nil, tc.restoreErr
+					}
+
+					return &common.User{ID: key, Name: "User-1", Version: 3}, &common.User{ID: key, Name: "User-1", Version: 2}, nil
+				},
+			}
+
+			storages := storageCases(mockStorage, tc.users...)
+			if tc.mockOnly {
+				// Only the mock fails this way
+				storages = storages[:1]
+			}
+
+			for _, sc := range storages {
+				sc := sc
+
+				t.Run(sc.name, func(t *testing.T) {
+					t.Parallel()
+
+					var indexed, invalidated bool
+
+					mockCache := &cacheMock.MockCache{
+						DeleteFn: func(key int64) error {
+							invalidated = true
+
+							return nil
+						},
+					}
+					mockIndex := &searchMock.MockIndex{
+						AddFn: func(user *common.User) {
+							indexed = true
+						},
+					}
+
+					handlerConfig := Config{
+						CacheEnabled:      true,
+						SearchEnabled:     true,
+						SoftDeleteEnabled: tc.softDeleteEnabled,
+					}
+
+					// Create test handler
+					handler := NewUserHandler(zap.NewNop(), sc.newStorage(t), mockCache, mockIndex, nil, nil, handlerConfig)
+
+					w := httptest.NewRecorder()
+					c, _ := gin.CreateTestContext(w)
+					c.Request = httptest.NewRequest(http.MethodPost, "/user/1/restore", nil)
+					c.Params = append(c.Params, gin.Param{Key: "id", Value: "1"})
+
+					handler.RestoreHandler(c)
+
+					assert.Equal(t, tc.expectedStatus, w.Code)
+					assert.Equal(t, tc.expectedIndexed, indexed)
+					assert.Equal(t, tc.expectedInvalidated, invalidated)
+
+					if tc.expectedStatus == http.StatusOK {
+						var user common.User
+
+						err := json.Unmarshal(w.Body.Bytes(), &user)
+						if err != nil {
+							t.Fatalf("Failed to unmarshal response: %v", err)
+						}
+
+						assert.Equal(t, "User-1", user.Name)
+						assert.Equal(t, `"3"`, w.Header().Get("ETag"))
+					}
+				})
+			}
+		})
+	}
+}
+
+// TestUserHandler_RestoreCached tests that a restored user is served instead of a record cached before the restore
+func TestUserHandler_RestoreCached(t *testing.T) {
+	t.Parallel()
+
+	store := memory.NewStorage(zap.NewNop(), memory.Options{})
+	defer store.Close()
+
+	userCache := lru.NewLRUCache(zap.NewNop(), lru.Options{Expiry: expiry.Policy{NegativeTTL: time.Hour}})
+
+	handler := NewUserHandler(zap.NewNop(), store, userCache, nil, nil, nil, Config{
+		CacheEnabled:      true,
+		SoftDeleteEnabled: true,
+	})
+
+	id, err := store.Set(&common.User{Name: "User-1"})
+	assert.NoError(t, err)
+
+	_, err = store.SoftDelete(id, common.AnyVersion)
+	assert.NoError(t, err)
+
+	// A record of the user cached while it could not be read
+	assert.NoError(t, userCache.SetMissing(id))
+
+	request := func(method string, path string, handle gin.HandlerFunc) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(method, path, nil)
+		c.Params = append(c.Params, gin.Param{Key: "id", Value: "1"})
+
+		handle(c)
+
+		return w
+	}
+
+	assert.Equal(t, http.StatusNotFound, request(http.MethodGet, "/user/1", handler.GetHandler).Code)
+	assert.Equal(t, http.StatusOK, request(http.MethodPost, "/user/1/restore", handler.RestoreHandler).Code)
+
+	w := request(http.MethodGet, "/user/1", handler.GetHandler)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "User-1")
+}
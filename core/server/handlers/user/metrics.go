@@ -0,0 +1,58 @@
+package userhandler
+
+import (
+	"sync"
+
+	"github.com/penglongli/gin-metrics/ginmetrics"
+	"go.uber.org/zap"
+)
+
+const (
+	// collapsedReadsMetric counts user reads served by a concurrent read of the same user
+	collapsedReadsMetric = "user_get_collapsed_total"
+
+	// earlyRefreshesMetric counts cached users refreshed ahead of their expiry
+	earlyRefreshesMetric = "user_get_early_refresh_total"
+)
+
+var registerOnce sync.Once
+
+// registerMetrics adds the user read metrics to the global monitor exposed by the router, once per process
+func registerMetrics(logger *zap.Logger) {
+	registerOnce.Do(func() {
+		metrics := []*ginmetrics.Metric{
+			{
+				Type:        ginmetrics.Counter,
+				Name:        collapsedReadsMetric,
+				Description: "Number of user reads coalesced with a concurrent read of the same user",
+				Labels:      []string{},
+			},
+			{
+				Type:        ginmetrics.Counter,
+				Name:        earlyRefreshesMetric,
+				Description: "Number of cached users refreshed from the vault ahead of their expiry",
+				Labels:      []string{},
+			},
+		}
+
+		for _, metric := range metrics {
+			if err := ginmetrics.GetMonitor().AddMetric(metric); err != nil {
+				logger.Warn("Failed to register user metric", zap.String("metric", metric.Name), zap.Error(err))
+			}
+		}
+	})
+}
+
+// incCollapsedReads increments the collapsed reads metric
+func incCollapsedReads(logger *zap.Logger) {
+	if err := ginmetrics.GetMonitor().GetMetric(collapsedReadsMetric).Inc(nil); err != nil {
+		logger.Debug("Failed to increment collapsed user reads", zap.Error(err))
+	}
+}
+
+// incEarlyRefreshes increments the early refreshes metric
+func incEarlyRefreshes(logger *zap.Logger) {
+	if err := ginmetrics.GetMonitor().GetMetric(earlyRefreshesMetric).Inc(nil); err != nil {
+		logger.Debug("Failed to increment early user refreshes", zap.Error(err))
+	}
+}
@@ -0,0 +1,103 @@
+package userhandler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Aleksao998/LightningUserVault/core/common"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// getAsOf responds with the version of the user at the given time. Past versions never change,
+// so they are read from the vault directly instead of through the cache of current users
+func (h *UserHandler) getAsOf(c *gin.Context, id int64, asOf string) {
+	if !h.versionsEnabled(c) {
+		return
+	}
+
+	at, err := time.Parse(time.RFC3339Nano, asOf)
+	if err != nil {
+		h.logger.Warn("Invalid as_of received", zap.String("as_of", asOf))
+		c.JSON(http.StatusBadRequest, common.ErrorResponse{Error: errInvalidAsOf.Error()})
+
+		return
+	}
+
+	user, err := h.vault.GetAsOf(id, at)
+	if err != nil {
+		h.writeVersionError(c, id, err)
+
+		return
+	}
+
+	h.logger.Info("Returning user version", zap.Int64("id", id), zap.Time("as_of", at), zap.Int64("version", user.Version))
+	c.JSON(http.StatusOK, user)
+}
+
+// @Summary List user versions
+// @Description Retrieve every retained version of a user, newest first.
+// @Description Versions replaced longer than the version retention ago are compacted away
+// @ID list-user-versions
+// @Produce json
+// @Param id path int true "User ID"
+// @Success 200 {object} common.VersionList
+// @Failure 400 {object} common.ErrorResponse
+// @Failure 404 {object} common.ErrorResponse
+// @Failure 500 {object} common.ErrorResponse
+// @Failure 501 {object} common.ErrorResponse
+// @Router /user/{id}/versions [get]
+func (h *UserHandler) VersionsHandler(c *gin.Context) {
+	idStr := c.Param("id")
+
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		h.logger.Warn("Invalid user ID received", zap.String("id", idStr))
+		c.JSON(http.StatusBadRequest, common.ErrorResponse{Error: errInvalidUserID.Error()})
+
+		return
+	}
+
+	if !h.versionsEnabled(c) {
+		return
+	}
+
+	versions, err := h.vault.Versions(id)
+	if err != nil {
+		h.writeVersionError(c, id, err)
+
+		return
+	}
+
+	h.logger.Info("Returning user versions", zap.Int64("id", id), zap.Int("count", len(versions)))
+	c.JSON(http.StatusOK, common.VersionList{Versions: versions})
+}
+
+// versionsEnabled responds with 501 and returns false when user versions are disabled
+func (h *UserHandler) versionsEnabled(c *gin.Context) bool {
+	if h.config.VersionsEnabled {
+		return true
+	}
+
+	h.logger.Warn("User versions requested while disabled")
+	c.JSON(http.StatusNotImplemented, common.ErrorResponse{Error: errVersionsDisabled.Error()})
+
+	return false
+}
+
+// writeVersionError responds with the status matching an error of reading user versions
+func (h *UserHandler) writeVersionError(c *gin.Context, id int64, err error) {
+	switch {
+	case errors.Is(err, common.ErrUserNotFound):
+		h.logger.Warn("User version not found", zap.Int64("id", id))
+		c.JSON(http.StatusNotFound, common.ErrorResponse{Error: err.Error()})
+	case errors.Is(err, common.ErrUserDeleted):
+		h.logger.Info("Requested user version is deleted", zap.Int64("id", id))
+		c.JSON(http.StatusGone, common.ErrorResponse{Error: err.Error()})
+	default:
+		h.logger.Error("Failed to fetch user versions from vault", zap.Int64("id", id), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, common.ErrorResponse{Error: err.Error()})
+	}
+}
@@ -0,0 +1,211 @@
+package userhandler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	cacheMock "github.com/Aleksao998/LightningUserVault/core/cache/mocks"
+	"github.com/Aleksao998/LightningUserVault/core/common"
+	storageMock "github.com/Aleksao998/LightningUserVault/core/storage/mocks"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+// TestUserHandler_GetAsOf tests the behavior of the GetHandler when a point in time is requested
+func TestUserHandler_GetAsOf(t *testing.T) {
+	t.Parallel()
+
+	at := time.Date(2023, 9, 1, 12, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name            string
+		asOf            string
+		versionsEnabled bool
+		getAsOfErr      error
+		expectedStatus  int
+	}{
+		{
+			name:            "Version found",
+			asOf:            at.Format(time.RFC3339Nano),
+			versionsEnabled: true,
+			expectedStatus:  http.StatusOK,
+		},
+		{
+			name:            "Invalid time",
+			asOf:            "yesterday",
+			versionsEnabled: true,
+			expectedStatus:  http.StatusBadRequest,
+		},
+		{
+			name:            "Not found",
+			asOf:            at.Format(time.RFC3339Nano),
+			versionsEnabled: true,
+			getAsOfErr:      common.ErrUserNotFound,
+			expectedStatus:  http.StatusNotFound,
+		},
+		{
+			name:            "Deleted",
+			asOf:            at.Format(time.RFC3339Nano),
+			versionsEnabled: true,
+			getAsOfErr:      common.ErrUserDeleted,
+			expectedStatus:  http.StatusGone,
+		},
+		{
+			name:            "Internal error",
+			asOf:            at.Format(time.RFC3339Nano),
+			versionsEnabled: true,
+			getAsOfErr:      errInternal,
+			expectedStatus:  http.StatusInternalServerError,
+		},
+		{
+			name:           "Versions disabled",
+			asOf:           at.Format(time.RFC3339Nano),
+			expectedStatus: http.StatusNotImplemented,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var requestedAt time.Time
+
+			mockStorage := &storageMock.MockStorage{
+				GetAsOfFn: func(key int64, at time.Time) (*common.User, error) {
+					requestedAt = at
+
+					if tc.getAsOfErr != nil {
+						return nil, tc.getAsOfErr
+					}
+
+					return &common.User{ID: key, Name: "User-1", Version: 2}, nil
+				},
+			}
+
+			// The current user in cache is never returned for a point in time
+			mockCache := &cacheMock.MockCache{
+				GetFn: func(key int64) (*common.User, error) {
+					t.Fatalf("cache read for a point in time")
+
+					return nil, nil
+				},
+			}
+
+			handlerConfig := Config{
+				CacheEnabled:    true,
+				VersionsEnabled: tc.versionsEnabled,
+			}
+
+			// Create test handler
+			handler := NewUserHandler(zap.NewNop(), mockStorage, mockCache, nil, nil, nil, handlerConfig)
+
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodGet, "/user/1?as_of="+tc.asOf, nil)
+			c.Params = append(c.Params, gin.Param{Key: "id", Value: "1"})
+
+			handler.GetHandler(c)
+
+			assert.Equal(t, tc.expectedStatus, w.Code)
+
+			if tc.expectedStatus == http.StatusOK {
+				assert.Equal(t, at, requestedAt)
+
+				var user common.User
+				assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &user))
+				assert.Equal(t, int64(2), user.Version)
+			}
+		})
+	}
+}
+
+// TestUserHandler_Versions tests the behavior of the VersionsHandler
+func TestUserHandler_Versions(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name            string
+		id              string
+		versionsEnabled bool
+		versionsErr     error
+		expectedStatus  int
+	}{
+		{
+			name:            "Versions found",
+			id:              "1",
+			versionsEnabled: true,
+			expectedStatus:  http.StatusOK,
+		},
+		{
+			name:            "Invalid ID",
+			id:              "abc",
+			versionsEnabled: true,
+			expectedStatus:  http.StatusBadRequest,
+		},
+		{
+			name:            "Not found",
+			id:              "1",
+			versionsEnabled: true,
+			versionsErr:     common.ErrUserNotFound,
+			expectedStatus:  http.StatusNotFound,
+		},
+		{
+			name:            "Internal error",
+			id:              "1",
+			versionsEnabled: true,
+			versionsErr:     errInternal,
+			expectedStatus:  http.StatusInternalServerError,
+		},
+		{
+			name:           "Versions disabled",
+			id:             "1",
+			expectedStatus: http.StatusNotImplemented,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			mockStorage := &storageMock.MockStorage{
+				VersionsFn: func(key int64) ([]*common.User, error) {
+					if tc.versionsErr != nil {
+						return nil, tc.versionsErr
+					}
+
+					return []*common.User{
+						{ID: key, Name: "User-2", Version: 2},
+						{ID: key, Name: "User-1", Version: 1},
+					}, nil
+				},
+			}
+
+			// Create test handler
+			handler := NewUserHandler(zap.NewNop(), mockStorage, nil, nil, nil, nil, Config{VersionsEnabled: tc.versionsEnabled})
+
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodGet, "/user/"+tc.id+"/versions", nil)
+			c.Params = append(c.Params, gin.Param{Key: "id", Value: tc.id})
+
+			handler.VersionsHandler(c)
+
+			assert.Equal(t, tc.expectedStatus, w.Code)
+
+			if tc.expectedStatus == http.StatusOK {
+				var versions common.VersionList
+				assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &versions))
+				assert.Len(t, versions.Versions, 2)
+				assert.Equal(t, int64(2), versions.Versions[0].Version)
+			}
+		})
+	}
+}
@@ -0,0 +1,369 @@
+package webhookhandler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Aleksao998/LightningUserVault/core/common"
+	"github.com/Aleksao998/LightningUserVault/core/webhook"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const (
+	// defaultDeliveryLimit is the number of deliveries listed when the limit is not provided
+	defaultDeliveryLimit = 100
+
+	// maxDeliveryLimit is the largest number of deliveries listed at once
+	maxDeliveryLimit = 1000
+)
+
+var (
+	errInvalidWebhookID    = errors.New("invalid webhook ID")
+	errInvalidDeliveryID   = errors.New("invalid delivery ID")
+	errInvalidLimit        = errors.New("invalid limit")
+	errInvalidReqJSONParam = errors.New("request is invalid json")
+	errWebhooksDisabled    = errors.New("webhooks are disabled")
+)
+
+type Config struct {
+	WebhooksEnabled bool
+}
+
+type WebhookHandler struct {
+	webhooks webhook.Manager
+	logger   *zap.Logger
+	config   Config
+}
+
+// NewWebhookHandler creates a new WebhookHandler with the given webhook manager
+func NewWebhookHandler(logger *zap.Logger, webhooks webhook.Manager, config Config) *WebhookHandler {
+	return &WebhookHandler{
+		webhooks: webhooks,
+		logger:   logger,
+		config:   config,
+	}
+}
+
+// @Summary Register a webhook
+// @Description Register an endpoint which receives user events committed from now on.
+// @Description Every delivery is signed with HMAC-SHA256 over the X-Vault-Timestamp header, a dot and the body,
+// @Description sent as "sha256=<hex>" in X-Vault-Signature. The secret is only returned in this response
+// @ID register-webhook
+// @Accept  json
+// @Produce json
+// @Param webhook body webhook.Registration true "Webhook registration"
+// @Success 201 {object} webhook.Webhook
+// @Failure 400 {object} common.ErrorResponse
+// @Failure 500 {object} common.ErrorResponse
+// @Failure 501 {object} common.ErrorResponse
+// @Router /admin/webhooks [post]
+func (h *WebhookHandler) RegisterHandler(c *gin.Context) {
+	if !h.enabled(c) {
+		return
+	}
+
+	var registration webhook.Registration
+	if err := c.BindJSON(&registration); err != nil {
+		h.logger.Warn("Invalid JSON received", zap.Error(err))
+		c.JSON(http.StatusBadRequest, common.ErrorResponse{Error: errInvalidReqJSONParam.Error()})
+
+		return
+	}
+
+	created, err := h.webhooks.Register(&registration)
+	if err != nil {
+		if errors.Is(err, webhook.ErrInvalidURL) || errors.Is(err, webhook.ErrInvalidEventType) {
+			h.logger.Warn("Invalid webhook received", zap.String("url", registration.URL), zap.Error(err))
+			c.JSON(http.StatusBadRequest, common.ErrorResponse{Error: err.Error()})
+
+			return
+		}
+
+		h.logger.Error("Failed to register webhook", zap.String("url", registration.URL), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, common.ErrorResponse{Error: err.Error()})
+
+		return
+	}
+
+	h.logger.Info("Webhook successfully registered", zap.Int64("id", created.ID))
+	c.JSON(http.StatusCreated, created)
+}
+
+// @Summary List webhooks
+// @Description Retrieve all registered webhooks without their secrets
+// @ID list-webhooks
+// @Produce json
+// @Success 200 {object} webhook.WebhookList
+// @Failure 500 {object} common.ErrorResponse
+// @Failure 501 {object} common.ErrorResponse
+// @Router /admin/webhooks [get]
+func (h *WebhookHandler) ListHandler(c *gin.Context) {
+	if !h.enabled(c) {
+		return
+	}
+
+	webhooks, err := h.webhooks.List()
+	if err != nil {
+		h.logger.Error("Failed to list webhooks", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, common.ErrorResponse{Error: err.Error()})
+
+		return
+	}
+
+	c.JSON(http.StatusOK, webhook.WebhookList{Webhooks: webhooks})
+}
+
+// @Summary Get webhook by ID
+// @Description Retrieve a registered webhook without its secret
+// @ID get-webhook
+// @Produce json
+// @Param id path int true "Webhook ID"
+// @Success 200 {object} webhook.Webhook
+// @Failure 400 {object} common.ErrorResponse
+// @Failure 404 {object} common.ErrorResponse
+// @Failure 500 {object} common.ErrorResponse
+// @Failure 501 {object} common.ErrorResponse
+// @Router /admin/webhooks/{id} [get]
+func (h *WebhookHandler) GetHandler(c *gin.Context) {
+	if !h.enabled(c) {
+		return
+	}
+
+	id, ok := h.parseID(c, errInvalidWebhookID)
+	if !ok {
+		return
+	}
+
+	found, err := h.webhooks.Get(id)
+	if err != nil {
+		h.writeError(c, err, "Failed to get webhook", id)
+
+		return
+	}
+
+	c.JSON(http.StatusOK, found)
+}
+
+// @Summary Remove a webhook
+// @Description Stop delivering events to a webhook, its pending deliveries are moved to the dead-letter list
+// @ID remove-webhook
+// @Produce json
+// @Param id path int true "Webhook ID"
+// @Success 204
+// @Failure 400 {object} common.ErrorResponse
+// @Failure 404 {object} common.ErrorResponse
+// @Failure 500 {object} common.ErrorResponse
+// @Failure 501 {object} common.ErrorResponse
+// @Router /admin/webhooks/{id} [delete]
+func (h *WebhookHandler) RemoveHandler(c *gin.Context) {
+	if !h.enabled(c) {
+		return
+	}
+
+	id, ok := h.parseID(c, errInvalidWebhookID)
+	if !ok {
+		return
+	}
+
+	if err := h.webhooks.Remove(id); err != nil {
+		h.writeError(c, err, "Failed to remove webhook", id)
+
+		return
+	}
+
+	h.logger.Info("Webhook successfully removed", zap.Int64("id", id))
+	c.Status(http.StatusNoContent)
+}
+
+// @Summary List deliveries of a webhook
+// @Description Retrieve the newest deliveries of a webhook with their status
+// @ID list-webhook-deliveries
+// @Produce json
+// @Param id path int true "Webhook ID"
+// @Param status query string false "Delivery status" Enums(pending, delivered, dead)
+// @Param limit query int false "Maximum number of deliveries (1-1000)" default(100)
+// @Success 200 {object} webhook.DeliveryList
+// @Failure 400 {object} common.ErrorResponse
+// @Failure 404 {object} common.ErrorResponse
+// @Failure 500 {object} common.ErrorResponse
+// @Failure 501 {object} common.ErrorResponse
+// @Router /admin/webhooks/{id}/deliveries [get]
+func (h *WebhookHandler) ListWebhookDeliveriesHandler(c *gin.Context) {
+	if !h.enabled(c) {
+		return
+	}
+
+	id, ok := h.parseID(c, errInvalidWebhookID)
+	if !ok {
+		return
+	}
+
+	if _, err := h.webhooks.Get(id); err != nil {
+		h.writeError(c, err, "Failed to get webhook", id)
+
+		return
+	}
+
+	h.listDeliveries(c, id)
+}
+
+// @Summary List deliveries
+// @Description Retrieve the newest deliveries of all webhooks with their status, status dead lists the dead-letter list
+// @ID list-deliveries
+// @Produce json
+// @Param status query string false "Delivery status" Enums(pending, delivered, dead)
+// @Param limit query int false "Maximum number of deliveries (1-1000)" default(100)
+// @Success 200 {object} webhook.DeliveryList
+// @Failure 400 {object} common.ErrorResponse
+// @Failure 500 {object} common.ErrorResponse
+// @Failure 501 {object} common.ErrorResponse
+// @Router /admin/deliveries [get]
+func (h *WebhookHandler) ListDeliveriesHandler(c *gin.Context) {
+	if !h.enabled(c) {
+		return
+	}
+
+	h.listDeliveries(c, 0)
+}
+
+// @Summary Get delivery by ID
+// @Description Retrieve the status of a single delivery
+// @ID get-delivery
+// @Produce json
+// @Param id path int true "Delivery ID"
+// @Success 200 {object} webhook.Delivery
+// @Failure 400 {object} common.ErrorResponse
+// @Failure 404 {object} common.ErrorResponse
+// @Failure 500 {object} common.ErrorResponse
+// @Failure 501 {object} common.ErrorResponse
+// @Router /admin/deliveries/{id} [get]
+func (h *WebhookHandler) GetDeliveryHandler(c *gin.Context) {
+	if !h.enabled(c) {
+		return
+	}
+
+	id, ok := h.parseID(c, errInvalidDeliveryID)
+	if !ok {
+		return
+	}
+
+	delivery, err := h.webhooks.GetDelivery(id)
+	if err != nil {
+		h.writeError(c, err, "Failed to get delivery", id)
+
+		return
+	}
+
+	c.JSON(http.StatusOK, delivery)
+}
+
+// @Summary Retry a dead delivery
+// @Description Move a delivery from the dead-letter list back to the queue for immediate delivery
+// @ID retry-delivery
+// @Produce json
+// @Param id path int true "Delivery ID"
+// @Success 200 {object} webhook.Delivery
+// @Failure 400 {object} common.ErrorResponse
+// @Failure 404 {object} common.ErrorResponse
+// @Failure 409 {object} common.ErrorResponse
+// @Failure 500 {object} common.ErrorResponse
+// @Failure 501 {object} common.ErrorResponse
+// @Router /admin/deliveries/{id}/retry [post]
+func (h *WebhookHandler) RetryHandler(c *gin.Context) {
+	if !h.enabled(c) {
+		return
+	}
+
+	id, ok := h.parseID(c, errInvalidDeliveryID)
+	if !ok {
+		return
+	}
+
+	delivery, err := h.webhooks.Retry(id)
+	if err != nil {
+		h.writeError(c, err, "Failed to retry delivery", id)
+
+		return
+	}
+
+	h.logger.Info("Delivery successfully queued again", zap.Int64("id", id))
+	c.JSON(http.StatusOK, delivery)
+}
+
+// listDeliveries responds with deliveries of the given webhook, or of all webhooks if it is 0
+func (h *WebhookHandler) listDeliveries(c *gin.Context, webhookID int64) {
+	limitStr := c.DefaultQuery("limit", strconv.Itoa(defaultDeliveryLimit))
+
+	limit, err := strconv.Atoi(limitStr)
+	if err != nil || limit < 1 || limit > maxDeliveryLimit {
+		h.logger.Warn("Invalid delivery limit received", zap.String("limit", limitStr))
+		c.JSON(http.StatusBadRequest, common.ErrorResponse{Error: errInvalidLimit.Error()})
+
+		return
+	}
+
+	deliveries, err := h.webhooks.ListDeliveries(webhook.DeliveryFilter{
+		WebhookID: webhookID,
+		Status:    webhook.Status(c.Query("status")),
+		Limit:     limit,
+	})
+	if err != nil {
+		if errors.Is(err, webhook.ErrInvalidStatus) {
+			h.logger.Warn("Invalid delivery status received", zap.String("status", c.Query("status")))
+			c.JSON(http.StatusBadRequest, common.ErrorResponse{Error: err.Error()})
+
+			return
+		}
+
+		h.logger.Error("Failed to list deliveries", zap.Int64("webhook", webhookID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, common.ErrorResponse{Error: err.Error()})
+
+		return
+	}
+
+	c.JSON(http.StatusOK, webhook.DeliveryList{Deliveries: deliveries})
+}
+
+// enabled responds with 501 Not Implemented and returns false if webhooks are disabled
+func (h *WebhookHandler) enabled(c *gin.Context) bool {
+	if h.config.WebhooksEnabled {
+		return true
+	}
+
+	h.logger.Warn("Webhooks requested while disabled")
+	c.JSON(http.StatusNotImplemented, common.ErrorResponse{Error: errWebhooksDisabled.Error()})
+
+	return false
+}
+
+// parseID parses the "id" path parameter, responding with the given error if it is invalid
+func (h *WebhookHandler) parseID(c *gin.Context, invalid error) (int64, bool) {
+	idStr := c.Param("id")
+
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		h.logger.Warn("Invalid ID received", zap.String("id", idStr))
+		c.JSON(http.StatusBadRequest, common.ErrorResponse{Error: invalid.Error()})
+
+		return 0, false
+	}
+
+	return id, true
+}
+
+// writeError responds with the status matching the webhook manager error
+func (h *WebhookHandler) writeError(c *gin.Context, err error, message string, id int64) {
+	switch {
+	case errors.Is(err, webhook.ErrWebhookNotFound), errors.Is(err, webhook.ErrDeliveryNotFound):
+		h.logger.Warn(message, zap.Int64("id", id), zap.Error(err))
+		c.JSON(http.StatusNotFound, common.ErrorResponse{Error: err.Error()})
+	case errors.Is(err, webhook.ErrDeliveryNotDead):
+		h.logger.Warn(message, zap.Int64("id", id), zap.Error(err))
+		c.JSON(http.StatusConflict, common.ErrorResponse{Error: err.Error()})
+	default:
+		h.logger.Error(message, zap.Int64("id", id), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, common.ErrorResponse{Error: err.Error()})
+	}
+}
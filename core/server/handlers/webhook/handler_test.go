@@ -0,0 +1,428 @@
+package webhookhandler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Aleksao998/LightningUserVault/core/common"
+	"github.com/Aleksao998/LightningUserVault/core/events"
+	"github.com/Aleksao998/LightningUserVault/core/webhook"
+	webhookMock "github.com/Aleksao998/LightningUserVault/core/webhook/mocks"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+var errInternal = errors.New("internal error")
+
+// serve runs the handler for a request with the given "id" parameter and returns the response
+func serve(handle gin.HandlerFunc, method, target, id, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(method, target, strings.NewReader(body))
+
+	if id != "" {
+		c.Params = append(c.Params, gin.Param{Key: "id", Value: id})
+	}
+
+	handle(c)
+
+	// Responses without a body, like 204 No Content, only reach the recorder once the header is written
+	c.Writer.WriteHeaderNow()
+
+	return w
+}
+
+// decodeError returns the error message of an error response
+func decodeError(t *testing.T, w *httptest.ResponseRecorder) string {
+	t.Helper()
+
+	var jsonError common.ErrorResponse
+
+	err := json.Unmarshal(w.Body.Bytes(), &jsonError)
+	if err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+
+	return jsonError.Error
+}
+
+// TestWebhookHandler_Disabled tests that every endpoint responds with 501 when webhooks are disabled
+func TestWebhookHandler_Disabled(t *testing.T) {
+	t.Parallel()
+
+	handler := NewWebhookHandler(zap.NewNop(), nil, Config{})
+
+	handlers := []gin.HandlerFunc{
+		handler.RegisterHandler,
+		handler.ListHandler,
+		handler.GetHandler,
+		handler.RemoveHandler,
+		handler.ListWebhookDeliveriesHandler,
+		handler.ListDeliveriesHandler,
+		handler.GetDeliveryHandler,
+		handler.RetryHandler,
+	}
+
+	for _, handle := range handlers {
+		w := serve(handle, http.MethodGet, "/admin/webhooks", "1", "")
+
+		assert.Equal(t, http.StatusNotImplemented, w.Code)
+		assert.Equal(t, errWebhooksDisabled.Error(), decodeError(t, w))
+	}
+}
+
+// TestWebhookHandler_Register tests registering webhooks
+func TestWebhookHandler_Register(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name           string
+		body           string
+		registerErr    error
+		expectedStatus int
+	}{
+		{
+			name:           "Valid webhook",
+			body:           `{"url":"http://example.com/hook","events":["created"]}`,
+			expectedStatus: http.StatusCreated,
+		},
+		{
+			name:           "Invalid JSON",
+			body:           `{"url":`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Invalid URL",
+			body:           `{"url":"example.com"}`,
+			registerErr:    webhook.ErrInvalidURL,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Invalid event type",
+			body:           `{"url":"http://example.com/hook","events":["renamed"]}`,
+			registerErr:    webhook.ErrInvalidEventType,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Internal error",
+			body:           `{"url":"http://example.com/hook"}`,
+			registerErr:    errInternal,
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			mockManager := &webhookMock.MockManager{
+				RegisterFn: func(registration *webhook.Registration) (*webhook.Webhook, error) {
+					if tc.registerErr != nil {
+						return nil, tc.registerErr
+					}
+
+					return &webhook.Webhook{
+						ID:     1,
+						URL:    registration.URL,
+						Secret: "secret",
+						Events: registration.Events,
+					}, nil
+				},
+			}
+
+			// Create test handler
+			handler := NewWebhookHandler(zap.NewNop(), mockManager, Config{WebhooksEnabled: true})
+
+			w := serve(handler.RegisterHandler, http.MethodPost, "/admin/webhooks", "", tc.body)
+
+			assert.Equal(t, tc.expectedStatus, w.Code)
+
+			if tc.expectedStatus == http.StatusCreated {
+				var created webhook.Webhook
+
+				err := json.Unmarshal(w.Body.Bytes(), &created)
+				if err != nil {
+					t.Fatalf("Failed to unmarshal response: %v", err)
+				}
+
+				assert.Equal(t, int64(1), created.ID)
+				assert.Equal(t, "http://example.com/hook", created.URL)
+				assert.Equal(t, "secret", created.Secret)
+				assert.Equal(t, []events.Type{events.Created}, created.Events)
+			}
+		})
+	}
+}
+
+// TestWebhookHandler_GetAndRemove tests the status of webhook lookups and removals
+func TestWebhookHandler_GetAndRemove(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name           string
+		id             string
+		err            error
+		expectedGet    int
+		expectedRemove int
+	}{
+		{
+			name:           "Existing webhook",
+			id:             "1",
+			expectedGet:    http.StatusOK,
+			expectedRemove: http.StatusNoContent,
+		},
+		{
+			name:           "Invalid ID",
+			id:             "abc",
+			expectedGet:    http.StatusBadRequest,
+			expectedRemove: http.StatusBadRequest,
+		},
+		{
+			name:           "Not found",
+			id:             "2",
+			err:            webhook.ErrWebhookNotFound,
+			expectedGet:    http.StatusNotFound,
+			expectedRemove: http.StatusNotFound,
+		},
+		{
+			name:           "Internal error",
+			id:             "3",
+			err:            errInternal,
+			expectedGet:    http.StatusInternalServerError,
+			expectedRemove: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			mockManager := &webhookMock.MockManager{
+				GetFn: func(id int64) (*webhook.Webhook, error) {
+					if tc.err != nil {
+						return nil, tc.err
+					}
+
+					return &webhook.Webhook{ID: id, URL: "http://example.com/hook"}, nil
+				},
+				RemoveFn: func(id int64) error {
+					return tc.err
+				},
+			}
+
+			// Create test handler
+			handler := NewWebhookHandler(zap.NewNop(), mockManager, Config{WebhooksEnabled: true})
+
+			w := serve(handler.GetHandler, http.MethodGet, "/admin/webhooks/"+tc.id, tc.id, "")
+			assert.Equal(t, tc.expectedGet, w.Code)
+
+			w = serve(handler.RemoveHandler, http.MethodDelete, "/admin/webhooks/"+tc.id, tc.id, "")
+			assert.Equal(t, tc.expectedRemove, w.Code)
+		})
+	}
+}
+
+// TestWebhookHandler_ListDeliveries tests that delivery filters are passed to the manager
+func TestWebhookHandler_ListDeliveries(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name           string
+		target         string
+		id             string
+		listErr        error
+		expectedFilter webhook.DeliveryFilter
+		expectedStatus int
+	}{
+		{
+			name:           "Dead-letter list",
+			target:         "/admin/deliveries?status=dead",
+			expectedFilter: webhook.DeliveryFilter{Status: webhook.Dead, Limit: defaultDeliveryLimit},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Deliveries of a webhook",
+			target:         "/admin/webhooks/3/deliveries?limit=5",
+			id:             "3",
+			expectedFilter: webhook.DeliveryFilter{WebhookID: 3, Limit: 5},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Invalid limit",
+			target:         "/admin/deliveries?limit=0",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Invalid status",
+			target:         "/admin/deliveries?status=lost",
+			listErr:        webhook.ErrInvalidStatus,
+			expectedFilter: webhook.DeliveryFilter{Status: "lost", Limit: defaultDeliveryLimit},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Internal error",
+			target:         "/admin/deliveries",
+			listErr:        errInternal,
+			expectedFilter: webhook.DeliveryFilter{Limit: defaultDeliveryLimit},
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var filter webhook.DeliveryFilter
+
+			mockManager := &webhookMock.MockManager{
+				GetFn: func(id int64) (*webhook.Webhook, error) {
+					return &webhook.Webhook{ID: id}, nil
+				},
+				ListDeliveriesFn: func(f webhook.DeliveryFilter) ([]*webhook.Delivery, error) {
+					filter = f
+
+					if tc.listErr != nil {
+						return nil, tc.listErr
+					}
+
+					return []*webhook.Delivery{{ID: 7, WebhookID: 3, Status: webhook.Dead}}, nil
+				},
+			}
+
+			// Create test handler
+			handler := NewWebhookHandler(zap.NewNop(), mockManager, Config{WebhooksEnabled: true})
+
+			handle := handler.ListDeliveriesHandler
+			if tc.id != "" {
+				handle = handler.ListWebhookDeliveriesHandler
+			}
+
+			w := serve(handle, http.MethodGet, tc.target, tc.id, "")
+
+			assert.Equal(t, tc.expectedStatus, w.Code)
+			assert.Equal(t, tc.expectedFilter, filter)
+
+			if tc.expectedStatus == http.StatusOK {
+				var list webhook.DeliveryList
+
+				err := json.Unmarshal(w.Body.Bytes(), &list)
+				if err != nil {
+					t.Fatalf("Failed to unmarshal response: %v", err)
+				}
+
+				assert.Len(t, list.Deliveries, 1)
+			}
+		})
+	}
+}
+
+// TestWebhookHandler_ListUnknownWebhookDeliveries tests that listing deliveries of an unknown webhook responds with 404
+func TestWebhookHandler_ListUnknownWebhookDeliveries(t *testing.T) {
+	t.Parallel()
+
+	mockManager := &webhookMock.MockManager{
+		GetFn: func(id int64) (*webhook.Webhook, error) {
+			return nil, webhook.ErrWebhookNotFound
+		},
+	}
+
+	// Create test handler
+	handler := NewWebhookHandler(zap.NewNop(), mockManager, Config{WebhooksEnabled: true})
+
+	w := serve(handler.ListWebhookDeliveriesHandler, http.MethodGet, "/admin/webhooks/1/deliveries", "1", "")
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, webhook.ErrWebhookNotFound.Error(), decodeError(t, w))
+}
+
+// TestWebhookHandler_Retry tests the status of retrying deliveries
+func TestWebhookHandler_Retry(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name           string
+		id             string
+		retryErr       error
+		expectedStatus int
+	}{
+		{
+			name:           "Dead delivery",
+			id:             "1",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Invalid ID",
+			id:             "abc",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Not found",
+			id:             "1",
+			retryErr:       webhook.ErrDeliveryNotFound,
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "Not dead",
+			id:             "1",
+			retryErr:       webhook.ErrDeliveryNotDead,
+			expectedStatus: http.StatusConflict,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			mockManager := &webhookMock.MockManager{
+				RetryFn: func(id int64) (*webhook.Delivery, error) {
+					if tc.retryErr != nil {
+						return nil, tc.retryErr
+					}
+
+					return &webhook.Delivery{ID: id, Status: webhook.Pending}, nil
+				},
+				GetDeliveryFn: func(id int64) (*webhook.Delivery, error) {
+					if tc.retryErr != nil {
+						return nil, tc.retryErr
+					}
+
+					return &webhook.Delivery{ID: id, Status: webhook.Pending}, nil
+				},
+			}
+
+			// Create test handler
+			handler := NewWebhookHandler(zap.NewNop(), mockManager, Config{WebhooksEnabled: true})
+
+			w := serve(handler.RetryHandler, http.MethodPost, "/admin/deliveries/"+tc.id+"/retry", tc.id, "")
+
+			assert.Equal(t, tc.expectedStatus, w.Code)
+
+			if tc.expectedStatus == http.StatusOK {
+				var delivery webhook.Delivery
+
+				err := json.Unmarshal(w.Body.Bytes(), &delivery)
+				if err != nil {
+					t.Fatalf("Failed to unmarshal response: %v", err)
+				}
+
+				assert.Equal(t, webhook.Pending, delivery.Status)
+
+				w = serve(handler.GetDeliveryHandler, http.MethodGet, "/admin/deliveries/"+tc.id, tc.id, "")
+				assert.Equal(t, http.StatusOK, w.Code)
+			}
+		})
+	}
+}
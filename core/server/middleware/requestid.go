@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// RequestIDHeader is the header carrying the ID of a request
+	RequestIDHeader = "X-Request-ID"
+
+	// requestIDLength is the number of random bytes of a generated request ID
+	requestIDLength = 16
+)
+
+// RequestID makes sure every request has an ID, generating one when the client did not send it.
+// The ID is set on the request, so handlers read it from the header, and echoed in the response
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if id == "" {
+			id = newRequestID()
+			c.Request.Header.Set(RequestIDHeader, id)
+		}
+
+		c.Header(RequestIDHeader, id)
+		c.Next()
+	}
+}
+
+// newRequestID returns a random hex encoded request ID, or an empty ID if no randomness is available
+func newRequestID() string {
+	id := make([]byte, requestIDLength)
+	if _, err := rand.Read(id); err != nil {
+		return ""
+	}
+
+	return hex.EncodeToString(id)
+}
@@ -1,10 +1,17 @@
 package routers
 
 import (
+	"github.com/Aleksao998/LightningUserVault/core/audit"
 	"github.com/Aleksao998/LightningUserVault/core/cache"
 	docs "github.com/Aleksao998/LightningUserVault/core/docs"
+	"github.com/Aleksao998/LightningUserVault/core/events"
+	"github.com/Aleksao998/LightningUserVault/core/search"
+	auditHandler "github.com/Aleksao998/LightningUserVault/core/server/handlers/audit"
 	userHandler "github.com/Aleksao998/LightningUserVault/core/server/handlers/user"
+	webhookHandler "github.com/Aleksao998/LightningUserVault/core/server/handlers/webhook"
+	"github.com/Aleksao998/LightningUserVault/core/server/middleware"
 	"github.com/Aleksao998/LightningUserVault/core/storage"
+	"github.com/Aleksao998/LightningUserVault/core/webhook"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	"github.com/penglongli/gin-metrics/ginmetrics"
@@ -14,11 +21,30 @@ import (
 )
 
 type Config struct {
-	CacheEnabled bool
+	CacheEnabled      bool
+	SearchEnabled     bool
+	SoftDeleteEnabled bool
+	EventsEnabled     bool
+	WebhooksEnabled   bool
+	OutboxEnabled     bool
+	AuditEnabled      bool
+	VersionsEnabled   bool
+
+	// EarlyRefreshProbability is the probability of a user served from cache being refreshed in the background
+	EarlyRefreshProbability float64
 }
 
 // InitRouter initializes a new Gin router with predefined routes and middleware
-func InitRouter(logger *zap.Logger, vault storage.Storage, cache cache.Cache, config Config) *gin.Engine {
+func InitRouter(
+	logger *zap.Logger,
+	vault storage.Storage,
+	cache cache.Cache,
+	index search.Index,
+	stream events.Stream,
+	webhooks webhook.Manager,
+	auditLog audit.Log,
+	config Config,
+) *gin.Engine {
 	r := gin.New()
 
 	// Get global Monitor object
@@ -33,6 +59,7 @@ func InitRouter(logger *zap.Logger, vault storage.Storage, cache cache.Cache, co
 	// Middleware
 	r.Use(gin.Logger())
 	r.Use(gin.Recovery())
+	r.Use(middleware.RequestID())
 
 	// Swagger setup
 	docs.SwaggerInfo.BasePath = "/"
@@ -40,17 +67,61 @@ func InitRouter(logger *zap.Logger, vault storage.Storage, cache cache.Cache, co
 	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerfiles.Handler))
 
 	handlerConfig := userHandler.Config{
-		CacheEnabled: config.CacheEnabled,
+		CacheEnabled:      config.CacheEnabled,
+		SearchEnabled:     config.SearchEnabled,
+		SoftDeleteEnabled: config.SoftDeleteEnabled,
+		EventsEnabled:     config.EventsEnabled,
+		OutboxEnabled:     config.OutboxEnabled,
+		AuditEnabled:      config.AuditEnabled,
+		VersionsEnabled:   config.VersionsEnabled,
+
+		EarlyRefreshProbability: config.EarlyRefreshProbability,
 	}
 
 	// Init User Handler
-	handler := userHandler.NewUserHandler(logger, vault, cache, handlerConfig)
+	handler := userHandler.NewUserHandler(logger, vault, cache, index, stream, auditLog, handlerConfig)
+
+	// Init Audit Handler
+	auditsHandler := auditHandler.NewAuditHandler(logger, auditLog, auditHandler.Config{
+		AuditEnabled: config.AuditEnabled,
+	})
 
 	// User routes
 	userGroup := r.Group("/user")
 	{
+		userGroup.GET("/", handler.ListHandler)
+		userGroup.GET("/search", handler.SearchHandler)
+		userGroup.GET("/events", handler.EventsHandler)
 		userGroup.GET("/:id", handler.GetHandler)
+		userGroup.GET("/:id/history", auditsHandler.HistoryHandler)
+		userGroup.GET("/:id/versions", handler.VersionsHandler)
 		userGroup.POST("/", handler.SetHandler)
+		userGroup.POST("/batch", handler.SetBatchHandler)
+		userGroup.POST("/:id/restore", handler.RestoreHandler)
+		userGroup.PUT("/:id", handler.UpdateHandler)
+		userGroup.DELETE("/:id", handler.DeleteHandler)
+	}
+
+	// Multiple users routes
+	r.GET("/users", handler.GetMultiHandler)
+
+	// Init Webhook Handler
+	webhooksHandler := webhookHandler.NewWebhookHandler(logger, webhooks, webhookHandler.Config{
+		WebhooksEnabled: config.WebhooksEnabled,
+	})
+
+	// Admin routes
+	adminGroup := r.Group("/admin")
+	{
+		adminGroup.GET("/webhooks", webhooksHandler.ListHandler)
+		adminGroup.GET("/webhooks/:id", webhooksHandler.GetHandler)
+		adminGroup.GET("/webhooks/:id/deliveries", webhooksHandler.ListWebhookDeliveriesHandler)
+		adminGroup.POST("/webhooks", webhooksHandler.RegisterHandler)
+		adminGroup.DELETE("/webhooks/:id", webhooksHandler.RemoveHandler)
+		adminGroup.GET("/deliveries", webhooksHandler.ListDeliveriesHandler)
+		adminGroup.GET("/deliveries/:id", webhooksHandler.GetDeliveryHandler)
+		adminGroup.POST("/deliveries/:id/retry", webhooksHandler.RetryHandler)
+		adminGroup.GET("/audit", auditsHandler.ListHandler)
 	}
 
 	return r
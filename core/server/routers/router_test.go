@@ -6,10 +6,17 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
+	"github.com/Aleksao998/LightningUserVault/core/audit"
+	auditMock "github.com/Aleksao998/LightningUserVault/core/audit/mocks"
 	cacheMock "github.com/Aleksao998/LightningUserVault/core/cache/mocks"
 	"github.com/Aleksao998/LightningUserVault/core/common"
+	searchMock "github.com/Aleksao998/LightningUserVault/core/search/mocks"
+	"github.com/Aleksao998/LightningUserVault/core/server/middleware"
 	storageMock "github.com/Aleksao998/LightningUserVault/core/storage/mocks"
+	"github.com/Aleksao998/LightningUserVault/core/webhook"
+	webhookMock "github.com/Aleksao998/LightningUserVault/core/webhook/mocks"
 	"github.com/stretchr/testify/assert"
 	"go.uber.org/zap"
 )
@@ -30,7 +37,7 @@ func TestRouter_GetUser(t *testing.T) {
 	}
 
 	// Create test handler
-	router := InitRouter(zap.NewNop(), mockStorage, mockCache, routerConfig)
+	router := InitRouter(zap.NewNop(), mockStorage, mockCache, nil, nil, nil, nil, routerConfig)
 
 	w := httptest.NewRecorder()
 	req, _ := http.NewRequest("GET", "/user/1", nil)
@@ -52,7 +59,7 @@ func TestRouter_GetUser(t *testing.T) {
 // TestRouter_SetUser tests the successful setting of a user via the router's endpoint
 func TestRouter_SetUser(t *testing.T) {
 	mockStorage := &storageMock.MockStorage{
-		SetFn: func(value string) (int64, error) {
+		SetFn: func(user *common.User) (int64, error) {
 			return 1, nil
 		},
 	}
@@ -63,7 +70,7 @@ func TestRouter_SetUser(t *testing.T) {
 	}
 
 	// Create test handler
-	router := InitRouter(zap.NewNop(), mockStorage, mockCache, routerConfig)
+	router := InitRouter(zap.NewNop(), mockStorage, mockCache, nil, nil, nil, nil, routerConfig)
 
 	// Create a mock user data for the POST request
 	userData := map[string]interface{}{
@@ -89,3 +96,418 @@ func TestRouter_SetUser(t *testing.T) {
 	assert.Equal(t, int64(1), user.ID)
 	assert.Equal(t, "User-1", user.Name)
 }
+
+// TestRouter_UpdateUser tests the successful update of a user via the router's endpoint
+func TestRouter_UpdateUser(t *testing.T) {
+	mockStorage := &storageMock.MockStorage{
+		UpdateFn: func(user *common.User, version int64) (*common.User, error) {
+			return nil, nil
+		},
+	}
+	mockCache := &cacheMock.MockCache{}
+
+	routerConfig := Config{
+		CacheEnabled: true,
+	}
+
+	// Create test handler
+	router := InitRouter(zap.NewNop(), mockStorage, mockCache, nil, nil, nil, nil, routerConfig)
+
+	// Create a mock user data for the PUT request
+	userData := map[string]interface{}{
+		"name": "User-1-updated",
+	}
+	userDataBytes, _ := json.Marshal(userData)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("PUT", "/user/1", bytes.NewBuffer(userDataBytes))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, 200, w.Code)
+
+	var user common.User
+
+	err := json.Unmarshal(w.Body.Bytes(), &user)
+	if err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+
+	assert.Equal(t, int64(1), user.ID)
+	assert.Equal(t, "User-1-updated", user.Name)
+}
+
+// TestRouter_DeleteUser tests the successful deletion of a user via the router's endpoint
+func TestRouter_DeleteUser(t *testing.T) {
+	mockStorage := &storageMock.MockStorage{
+		DeleteFn: func(key int64, version int64) (*common.User, error) {
+			return nil, nil
+		},
+	}
+	mockCache := &cacheMock.MockCache{}
+
+	routerConfig := Config{
+		CacheEnabled: true,
+	}
+
+	// Create test handler
+	router := InitRouter(zap.NewNop(), mockStorage, mockCache, nil, nil, nil, nil, routerConfig)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("DELETE", "/user/1", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, 204, w.Code)
+}
+
+// TestRouter_RestoreUser tests the successful restoration of a soft deleted user via the router's endpoint
+func TestRouter_RestoreUser(t *testing.T) {
+	mockStorage := &storageMock.MockStorage{
+		RestoreFn: func(key int64) (*common.User, *common.User, error) {
+			return &common.User{ID: key, Name: "User-1", Version: 3}, &common.User{ID: key, Name: "User-1", Version: 2}, nil
+		},
+	}
+
+	routerConfig := Config{
+		SoftDeleteEnabled: true,
+	}
+
+	// Create test handler
+	router := InitRouter(zap.NewNop(), mockStorage, nil, nil, nil, nil, nil, routerConfig)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/user/1/restore", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, 200, w.Code)
+
+	var user common.User
+
+	err := json.Unmarshal(w.Body.Bytes(), &user)
+	if err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+
+	assert.Equal(t, int64(1), user.ID)
+	assert.Equal(t, "User-1", user.Name)
+}
+
+// TestRouter_UserVersions tests listing the versions of a user and reading one of them via the router's endpoints
+func TestRouter_UserVersions(t *testing.T) {
+	at := time.Date(2023, 9, 1, 12, 0, 0, 0, time.UTC)
+
+	mockStorage := &storageMock.MockStorage{
+		VersionsFn: func(key int64) ([]*common.User, error) {
+			return []*common.User{{ID: key, Name: "User-2", Version: 2}, {ID: key, Name: "User-1", Version: 1}}, nil
+		},
+		GetAsOfFn: func(key int64, asOf time.Time) (*common.User, error) {
+			assert.Equal(t, at, asOf)
+
+			return &common.User{ID: key, Name: "User-1", Version: 1}, nil
+		},
+	}
+
+	routerConfig := Config{
+		VersionsEnabled: true,
+	}
+
+	// Create test handler
+	router := InitRouter(zap.NewNop(), mockStorage, nil, nil, nil, nil, nil, routerConfig)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/user/1/versions", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, 200, w.Code)
+
+	var versions common.VersionList
+
+	err := json.Unmarshal(w.Body.Bytes(), &versions)
+	if err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+
+	assert.Len(t, versions.Versions, 2)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/user/1?as_of="+at.Format(time.RFC3339), nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, 200, w.Code)
+
+	var user common.User
+
+	err = json.Unmarshal(w.Body.Bytes(), &user)
+	if err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+
+	assert.Equal(t, "User-1", user.Name)
+}
+
+// TestRouter_ListUsers tests the successful listing of users via the router's endpoint
+func TestRouter_ListUsers(t *testing.T) {
+	mockStorage := &storageMock.MockStorage{
+		ListFn: func(cursor string, limit int) ([]*common.User, string, error) {
+			return []*common.User{{ID: 1, Name: "User-1"}}, "", nil
+		},
+	}
+	mockCache := &cacheMock.MockCache{}
+
+	routerConfig := Config{
+		CacheEnabled: true,
+	}
+
+	// Create test handler
+	router := InitRouter(zap.NewNop(), mockStorage, mockCache, nil, nil, nil, nil, routerConfig)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/user/?limit=10", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, 200, w.Code)
+
+	var userList common.UserList
+
+	err := json.Unmarshal(w.Body.Bytes(), &userList)
+	if err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+
+	assert.Len(t, userList.Users, 1)
+	assert.Equal(t, "User-1", userList.Users[0].Name)
+	assert.Empty(t, userList.NextCursor)
+}
+
+// TestRouter_SetUserBatch tests the successful setting of a batch of users via the router's endpoint
+func TestRouter_SetUserBatch(t *testing.T) {
+	mockStorage := &storageMock.MockStorage{
+		SetBatchFn: func(users []*common.User) ([]int64, error) {
+			return []int64{1, 2}, nil
+		},
+	}
+	mockCache := &cacheMock.MockCache{}
+
+	routerConfig := Config{
+		CacheEnabled: true,
+	}
+
+	// Create test handler
+	router := InitRouter(zap.NewNop(), mockStorage, mockCache, nil, nil, nil, nil, routerConfig)
+
+	// Create a mock batch of users for the POST request
+	usersData := []map[string]interface{}{
+		{"name": "User-1"},
+		{"name": "User-2"},
+	}
+	usersDataBytes, _ := json.Marshal(usersData)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/user/batch", bytes.NewBuffer(usersDataBytes))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, 200, w.Code)
+
+	var users []common.User
+
+	err := json.Unmarshal(w.Body.Bytes(), &users)
+	if err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+
+	assert.Len(t, users, 2)
+	assert.Equal(t, int64(2), users[1].ID)
+	assert.Equal(t, "User-2", users[1].Name)
+}
+
+// TestRouter_GetMultipleUsers tests the successful retrieval of multiple users via the router's endpoint
+func TestRouter_GetMultipleUsers(t *testing.T) {
+	mockStorage := &storageMock.MockStorage{
+		GetMultiFn: func(keys []int64) (map[int64]*common.User, error) {
+			return map[int64]*common.User{2: {ID: 2, Name: "User-2"}}, nil
+		},
+	}
+	mockCache := &cacheMock.MockCache{}
+
+	routerConfig := Config{
+		CacheEnabled: false,
+	}
+
+	// Create test handler
+	router := InitRouter(zap.NewNop(), mockStorage, mockCache, nil, nil, nil, nil, routerConfig)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/users?ids=1,2", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, 200, w.Code)
+
+	var response common.UserMultiResponse
+
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	if err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+
+	assert.Len(t, response.Users, 1)
+	assert.Equal(t, "User-2", response.Users[0].Name)
+	assert.Equal(t, []int64{1}, response.Missing)
+}
+
+// TestRouter_SearchUsers tests the successful search of users via the router's endpoint
+func TestRouter_SearchUsers(t *testing.T) {
+	mockStorage := &storageMock.MockStorage{
+		FindByNameFn: func(name string, prefix bool) ([]*common.User, error) {
+			return []*common.User{{ID: 1, Name: name}}, nil
+		},
+	}
+	mockCache := &cacheMock.MockCache{}
+
+	routerConfig := Config{
+		CacheEnabled: true,
+	}
+
+	// Create test handler
+	router := InitRouter(zap.NewNop(), mockStorage, mockCache, nil, nil, nil, nil, routerConfig)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/user/search?name=alice", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, 200, w.Code)
+
+	var userList common.UserList
+
+	err := json.Unmarshal(w.Body.Bytes(), &userList)
+	if err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+
+	assert.Len(t, userList.Users, 1)
+	assert.Equal(t, "alice", userList.Users[0].Name)
+}
+
+func TestRouter_FullTextSearchUsers(t *testing.T) {
+	mockStorage := &storageMock.MockStorage{
+		GetMultiFn: func(keys []int64) (map[int64]*common.User, error) {
+			return map[int64]*common.User{1: {ID: 1, Name: "alice"}}, nil
+		},
+	}
+	mockCache := &cacheMock.MockCache{}
+	mockIndex := &searchMock.MockIndex{
+		SearchFn: func(query string, limit int) []int64 {
+			return []int64{1}
+		},
+	}
+
+	routerConfig := Config{
+		CacheEnabled:  true,
+		SearchEnabled: true,
+	}
+
+	// Create test handler
+	router := InitRouter(zap.NewNop(), mockStorage, mockCache, mockIndex, nil, nil, nil, routerConfig)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/user/search?q=alise", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, 200, w.Code)
+
+	var userList common.UserList
+
+	err := json.Unmarshal(w.Body.Bytes(), &userList)
+	if err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+
+	assert.Len(t, userList.Users, 1)
+	assert.Equal(t, "alice", userList.Users[0].Name)
+}
+
+func TestRouter_EventsDisabled(t *testing.T) {
+	mockStorage := &storageMock.MockStorage{}
+
+	// Create test handler
+	router := InitRouter(zap.NewNop(), mockStorage, nil, nil, nil, nil, nil, Config{})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/user/events", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotImplemented, w.Code)
+}
+
+func TestRouter_ListWebhooks(t *testing.T) {
+	mockManager := &webhookMock.MockManager{
+		ListFn: func() ([]*webhook.Webhook, error) {
+			return []*webhook.Webhook{{ID: 1, URL: "http://example.com/hook"}}, nil
+		},
+	}
+
+	routerConfig := Config{
+		WebhooksEnabled: true,
+	}
+
+	// Create test handler
+	router := InitRouter(zap.NewNop(), &storageMock.MockStorage{}, nil, nil, nil, mockManager, nil, routerConfig)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/admin/webhooks", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, 200, w.Code)
+
+	var list webhook.WebhookList
+
+	err := json.Unmarshal(w.Body.Bytes(), &list)
+	if err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+
+	assert.Len(t, list.Webhooks, 1)
+	assert.Equal(t, "http://example.com/hook", list.Webhooks[0].URL)
+}
+
+// TestRouter_UserHistory tests reading the audit history of a user via the router's endpoint,
+// the request ID of the response is generated when the client does not send one
+func TestRouter_UserHistory(t *testing.T) {
+	mockLog := &auditMock.MockLog{
+		HistoryFn: func(userID int64, limit int) ([]*audit.Entry, error) {
+			return []*audit.Entry{{ID: 1, Operation: audit.Create, UserID: userID}}, nil
+		},
+	}
+
+	routerConfig := Config{
+		AuditEnabled: true,
+	}
+
+	// Create test handler
+	router := InitRouter(zap.NewNop(), &storageMock.MockStorage{}, nil, nil, nil, nil, mockLog, routerConfig)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/user/7/history", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, 200, w.Code)
+	assert.NotEmpty(t, w.Header().Get(middleware.RequestIDHeader))
+
+	var list audit.EntryList
+
+	err := json.Unmarshal(w.Body.Bytes(), &list)
+	if err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+
+	assert.Len(t, list.Entries, 1)
+	assert.Equal(t, int64(7), list.Entries[0].UserID)
+
+	// A request ID sent by the client is kept
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/user/7/history", nil)
+	req.Header.Set(middleware.RequestIDHeader, "req-1")
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, "req-1", w.Header().Get(middleware.RequestIDHeader))
+}
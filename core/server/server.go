@@ -7,9 +7,19 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/Aleksao998/LightningUserVault/core/audit"
 	"github.com/Aleksao998/LightningUserVault/core/cache"
+	"github.com/Aleksao998/LightningUserVault/core/cache/expiry"
+	"github.com/Aleksao998/LightningUserVault/core/cache/lru"
+	"github.com/Aleksao998/LightningUserVault/core/cache/redis"
+	"github.com/Aleksao998/LightningUserVault/core/events"
+	"github.com/Aleksao998/LightningUserVault/core/outbox"
+	"github.com/Aleksao998/LightningUserVault/core/search"
 	"github.com/Aleksao998/LightningUserVault/core/server/routers"
 	"github.com/Aleksao998/LightningUserVault/core/storage"
+	"github.com/Aleksao998/LightningUserVault/core/storage/compact"
+	"github.com/Aleksao998/LightningUserVault/core/storage/purge"
+	"github.com/Aleksao998/LightningUserVault/core/webhook"
 	"go.uber.org/zap"
 )
 
@@ -19,6 +29,12 @@ type Server struct {
 	httpServer *http.Server
 	logger     *zap.Logger
 	storage    storage.Storage
+	index      search.Index
+	stream     events.Stream
+	webhooks   webhook.Manager
+	relay      *outbox.Relay
+	purger     *purge.Purger
+	compactor  *compact.Compactor
 }
 
 // NewServer creates a new LightningUserVault server, using the passed in configuration
@@ -39,12 +55,19 @@ func NewServer(config *Config) (*Server, error) {
 
 	// Create storage config
 	storageConfig := storage.Config{
-		StorageType: config.StorageType,
-		DBHost:      config.DBHost.IP.String(),
-		DBPort:      strconv.Itoa(config.DBHost.Port),
-		DBPass:      config.DBPass,
-		DBName:      config.DBName,
-		DBUser:      config.DBUser,
+		StorageType:  config.StorageType,
+		DBHost:       config.DBHost.IP.String(),
+		DBPort:       strconv.Itoa(config.DBHost.Port),
+		DBPass:       config.DBPass,
+		DBName:       config.DBName,
+		DBPath:       config.DBPath,
+		DBCharset:    config.DBCharset,
+		DBCollation:  config.DBCollation,
+		DBUser:       config.DBUser,
+		Outbox:       config.EnableOutbox,
+		KeepVersions: config.EnableVersions,
+		Webhooks:     config.EnableWebhooks,
+		Audit:        config.EnableAudit,
 	}
 
 	// Initialize storage
@@ -59,7 +82,29 @@ func NewServer(config *Config) (*Server, error) {
 	cacheConfig := cache.Config{
 		CacheType:       config.CacheType,
 		MemcacheAddress: config.MemcacheAddress,
-		Enabled:         config.EnableCache,
+		Expiry: expiry.Policy{
+			TTL:         config.CacheTTL,
+			Jitter:      config.CacheTTLJitter,
+			NegativeTTL: config.CacheNegativeTTL,
+		},
+		Redis: redis.Options{
+			Address:           config.RedisAddress,
+			SentinelAddresses: config.RedisSentinels,
+			MasterName:        config.RedisMasterName,
+			Password:          config.RedisPassword,
+			TLS:               config.RedisTLS,
+			KeyPrefix:         config.RedisKeyPrefix,
+		},
+		InMemory: lru.Options{
+			MaxEntries: config.CacheMaxEntries,
+			MaxBytes:   config.CacheMaxBytes,
+		},
+		NearCache: config.EnableNearCache,
+		Near: lru.Options{
+			MaxEntries: config.NearCacheMaxEntries,
+			Expiry:     expiry.Policy{TTL: config.NearCacheTTL},
+		},
+		Enabled: config.EnableCache,
 	}
 
 	// Initialize cache
@@ -70,11 +115,95 @@ func NewServer(config *Config) (*Server, error) {
 		return nil, err
 	}
 
+	// Create search config
+	searchConfig := search.Config{
+		IndexPath: config.SearchIndexPath,
+		Enabled:   config.EnableSearch,
+	}
+
+	// Initialize search index
+	index, err := search.GetIndex(logger, searchConfig, vault)
+	if err != nil {
+		logger.Error("Failed to get search index", zap.Error(err))
+
+		return nil, err
+	}
+
+	// Create events config
+	eventsConfig := events.Config{
+		LogPath: config.EventLogPath,
+		Enabled: config.EnableEvents,
+	}
+
+	// Initialize event stream
+	stream, err := events.GetStream(logger, eventsConfig)
+	if err != nil {
+		logger.Error("Failed to get event stream", zap.Error(err))
+
+		return nil, err
+	}
+
+	// Create webhook config
+	webhookConfig := webhook.Config{
+		MaxAttempts: config.WebhookMaxAttempts,
+		Enabled:     config.EnableWebhooks,
+	}
+
+	// Initialize webhook delivery, it is fed from the event stream and queues deliveries in the storage
+	webhooks, err := webhook.GetManager(logger, webhookConfig, vault, stream)
+	if err != nil {
+		logger.Error("Failed to get webhooks", zap.Error(err))
+
+		return nil, err
+	}
+
+	// Relay the outbox to the event stream, or to the log when events are disabled
+	var sink outbox.Sink = outbox.NewLogSink(logger)
+	if config.EnableEvents {
+		sink = outbox.NewStreamSink(stream)
+	}
+
+	// Create outbox config
+	outboxConfig := outbox.Config{
+		Interval: config.OutboxInterval,
+		Enabled:  config.EnableOutbox,
+	}
+
+	// Initialize outbox relay
+	relay, err := outbox.GetRelay(logger, outboxConfig, vault, sink)
+	if err != nil {
+		logger.Error("Failed to get outbox relay", zap.Error(err))
+
+		return nil, err
+	}
+
+	// Create audit config
+	auditConfig := audit.Config{
+		Enabled: config.EnableAudit,
+	}
+
+	// Initialize audit log, its entries are kept in the storage
+	auditLog, err := audit.GetLog(logger, auditConfig, vault)
+	if err != nil {
+		logger.Error("Failed to get audit log", zap.Error(err))
+
+		return nil, err
+	}
+
 	routerConfig := routers.Config{
-		CacheEnabled: config.EnableCache,
+		CacheEnabled:      config.EnableCache,
+		SearchEnabled:     config.EnableSearch,
+		SoftDeleteEnabled: config.EnableSoftDelete,
+		EventsEnabled:     config.EnableEvents,
+		WebhooksEnabled:   config.EnableWebhooks,
+		OutboxEnabled:     config.EnableOutbox,
+		AuditEnabled:      config.EnableAudit,
+		VersionsEnabled:   config.EnableVersions,
+
+		EarlyRefreshProbability: config.CacheEarlyRefresh,
 	}
 
-	router := routers.InitRouter(logger, vault, cacheMechanism, routerConfig)
+	router := routers.InitRouter(logger, vault, cacheMechanism, index, stream, webhooks, auditLog, routerConfig)
 
 	// Create http server instance
 	httpServer := &http.Server{
@@ -89,6 +218,25 @@ func NewServer(config *Config) (*Server, error) {
 		httpServer: httpServer,
 		logger:     logger,
 		storage:    vault,
+		index:      index,
+		stream:     stream,
+		webhooks:   webhooks,
+		relay:      relay,
+	}
+
+	if config.EnableSoftDelete {
+		// Permanently remove soft deleted users once their retention has passed
+		server.purger = purge.NewPurger(logger, vault, purge.Config{Retention: config.SoftDeleteRetention})
+		server.purger.Start()
+	}
+
+	if config.EnableVersions {
+		// Remove replaced user versions once their retention has passed
+		server.compactor = compact.NewCompactor(logger, vault, compact.Config{
+			Retention: config.VersionRetention,
+			Interval:  config.VersionCompaction,
+		})
+		server.compactor.Start()
 	}
 
 	go func() {
@@ -107,6 +255,38 @@ func (s *Server) Close() error {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
+	// The purger and compactor use storage, so they are stopped first
+	if s.config.EnableSoftDelete {
+		s.purger.Close()
+	}
+
+	if s.config.EnableVersions {
+		s.compactor.Close()
+	}
+
+	// The relay reads from storage and publishes to the event stream, so it is stopped before both
+	if s.config.EnableOutbox {
+		s.relay.Close()
+	}
+
+	// Webhooks read from the event stream, so they are stopped before it
+	if s.config.EnableWebhooks {
+		if err := s.webhooks.Close(); err != nil {
+			s.logger.Error("Webhooks shutdown failed", zap.Error(err))
+
+			return err
+		}
+	}
+
+	// Closing the stream ends open event connections, which would otherwise block the http shutdown
+	if s.config.EnableEvents {
+		if err := s.stream.Close(); err != nil {
+			s.logger.Error("Event stream shutdown failed", zap.Error(err))
+
+			return err
+		}
+	}
+
 	if err := s.storage.Close(); err != nil {
 		s.logger.Error("Storage shutdown failed", zap.Error(err))
 
@@ -119,6 +299,14 @@ func (s *Server) Close() error {
 		return err
 	}
 
+	if s.config.EnableSearch {
+		if err := s.index.Close(); err != nil {
+			s.logger.Error("Search index shutdown failed", zap.Error(err))
+
+			return err
+		}
+	}
+
 	s.logger.Info("Server gracefully stopped")
 
 	return nil
@@ -0,0 +1,104 @@
+package compact
+
+import (
+	"sync"
+	"time"
+
+	"github.com/Aleksao998/LightningUserVault/core/storage"
+	"go.uber.org/zap"
+)
+
+// defaultInterval is the longest time between two compactions when the interval is not configured
+const defaultInterval = time.Hour
+
+type Config struct {
+	// Retention is how long replaced user versions are kept for point-in-time reads
+	Retention time.Duration
+
+	// Interval is the time between two compactions, it defaults to the retention capped at an hour
+	Interval time.Duration
+}
+
+// Compactor periodically removes user versions from storage once they were replaced longer than the retention ago
+type Compactor struct {
+	vault  storage.Storage
+	logger *zap.Logger
+	config Config
+
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewCompactor creates a new Compactor for the given storage, it does not run until started
+func NewCompactor(logger *zap.Logger, vault storage.Storage, config Config) *Compactor {
+	if config.Interval <= 0 {
+		config.Interval = defaultInterval
+		if config.Retention > 0 && config.Retention < config.Interval {
+			config.Interval = config.Retention
+		}
+	}
+
+	return &Compactor{
+		vault:  vault,
+		logger: logger,
+		config: config,
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+}
+
+// Start runs compactions in the background until the compactor is closed
+func (c *Compactor) Start() {
+	c.logger.Info("Starting version compactor",
+		zap.Duration("retention", c.config.Retention),
+		zap.Duration("interval", c.config.Interval),
+	)
+
+	go c.run()
+}
+
+// run compacts once right away and then on every tick of the interval
+func (c *Compactor) run() {
+	defer close(c.done)
+
+	ticker := time.NewTicker(c.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		// Errors are logged and the compaction is retried on the next tick
+		_, _ = c.Compact()
+
+		select {
+		case <-ticker.C:
+		case <-c.stop:
+			return
+		}
+	}
+}
+
+// Compact removes user versions replaced longer than the retention ago and returns how many were removed.
+// Reads at any time within the retention are still answered
+func (c *Compactor) Compact() (int, error) {
+	compacted, err := c.vault.CompactVersions(time.Now().Add(-c.config.Retention))
+	if err != nil {
+		c.logger.Error("Failed to compact user versions", zap.Error(err))
+
+		return compacted, err
+	}
+
+	if compacted > 0 {
+		c.logger.Info("Compacted user versions", zap.Int("count", compacted))
+	}
+
+	return compacted, nil
+}
+
+// Close stops the background compactions and waits for a running compaction to finish
+func (c *Compactor) Close() {
+	c.stopOnce.Do(func() {
+		close(c.stop)
+	})
+
+	<-c.done
+}
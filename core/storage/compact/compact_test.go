@@ -0,0 +1,93 @@
+package compact
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Aleksao998/LightningUserVault/core/storage/mocks"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+var errInternal = errors.New("internal error")
+
+// TestCompactor_CompactBeforeRetention tests that users deleted longer than the retention ago are compacted
+func TestCompactor_CompactBeforeRetention(t *testing.T) {
+	t.Parallel()
+
+	var before time.Time
+
+	vault := &mocks.MockStorage{
+		CompactFn: func(b time.Time) (int, error) {
+			before = b
+
+			return 3, nil
+		},
+	}
+
+	compactor := NewCompactor(zap.NewNop(), vault, Config{Retention: 24 * time.Hour})
+
+	start := time.Now()
+
+	compacted, err := compactor.Compact()
+	assert.NoError(t, err)
+	assert.Equal(t, 3, compacted)
+	assert.WithinDuration(t, start.Add(-24*time.Hour), before, time.Second)
+}
+
+// TestCompactor_CompactError tests that storage errors are returned
+func TestCompactor_CompactError(t *testing.T) {
+	t.Parallel()
+
+	vault := &mocks.MockStorage{
+		CompactFn: func(before time.Time) (int, error) {
+			return 0, errInternal
+		},
+	}
+
+	compactor := NewCompactor(zap.NewNop(), vault, Config{Retention: time.Hour})
+
+	_, err := compactor.Compact()
+	assert.ErrorIs(t, err, errInternal)
+}
+
+// TestCompactor_DefaultInterval tests that the interval is capped by the retention
+func TestCompactor_DefaultInterval(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, defaultInterval, NewCompactor(zap.NewNop(), nil, Config{Retention: 720 * time.Hour}).config.Interval)
+	assert.Equal(t, time.Minute, NewCompactor(zap.NewNop(), nil, Config{Retention: time.Minute}).config.Interval)
+	assert.Equal(t, time.Second, NewCompactor(zap.NewNop(), nil, Config{Retention: time.Hour, Interval: time.Second}).config.Interval)
+}
+
+// TestCompactor_StartAndClose tests that compactions run periodically until the compactor is closed
+func TestCompactor_StartAndClose(t *testing.T) {
+	t.Parallel()
+
+	var calls int32
+
+	vault := &mocks.MockStorage{
+		CompactFn: func(before time.Time) (int, error) {
+			atomic.AddInt32(&calls, 1)
+
+			return 0, nil
+		},
+	}
+
+	compactor := NewCompactor(zap.NewNop(), vault, Config{Retention: time.Hour, Interval: 10 * time.Millisecond})
+	compactor.Start()
+
+	assert.Eventually(t, func() bool {
+		return atomic.LoadInt32(&calls) >= 3
+	}, time.Second, 5*time.Millisecond)
+
+	compactor.Close()
+
+	// No compactions run after closing
+	stopped := atomic.LoadInt32(&calls)
+
+	time.Sleep(30 * time.Millisecond)
+	assert.Equal(t, stopped, atomic.LoadInt32(&calls))
+}
@@ -0,0 +1,137 @@
+package bolt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"time"
+
+	"github.com/Aleksao998/LightningUserVault/core/audit"
+	"go.etcd.io/bbolt"
+	"go.uber.org/zap"
+)
+
+var (
+	// auditEntriesBucket holds audit entries by their unique big endian time in nanoseconds,
+	// its sequence allocates their IDs
+	auditEntriesBucket = []byte("audit_entries")
+
+	// auditUsersBucket indexes audit entries by user, its values are empty.
+	// Keys are built as big endian user ID + big endian entry time in nanoseconds
+	auditUsersBucket = []byte("audit_users")
+)
+
+// auditEntryKey returns the key of the audit entry recorded at the given time
+func auditEntryKey(at time.Time) []byte {
+	return binary.BigEndian.AppendUint64(make([]byte, 0, idLength), uint64(at.UnixNano()))
+}
+
+// auditUserKey returns the index key of the user audit entry recorded at the given time
+func auditUserKey(userID int64, at time.Time) []byte {
+	return append(idKey(userID), auditEntryKey(at)...)
+}
+
+// RecordAudit allocates the next ID from the sequence of the entries bucket, assigns a time after the last entry
+// and stores the entry with its user index key
+func (b *Storage) RecordAudit(entry *audit.Entry) error {
+	err := b.db.Update(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket(auditEntriesBucket)
+
+		id, err := bucket.NextSequence()
+		if err != nil {
+			return err
+		}
+
+		var last time.Time
+		if key, _ := bucket.Cursor().Last(); key != nil {
+			last = time.Unix(0, int64(binary.BigEndian.Uint64(key))).UTC()
+		}
+
+		// Entries are keyed by time, so it never repeats or goes back even if the clock does
+		entry.ID = int64(id)
+		entry.Time = audit.NextTime(last, time.Nanosecond)
+
+		value, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+
+		if err := bucket.Put(auditEntryKey(entry.Time), value); err != nil {
+			return err
+		}
+
+		return tx.Bucket(auditUsersBucket).Put(auditUserKey(entry.UserID, entry.Time), nil)
+	})
+	if err != nil {
+		b.logger.Error("Failed to record audit entry in database", zap.Int64("userID", entry.UserID), zap.Error(err))
+
+		return err
+	}
+
+	return nil
+}
+
+// AuditHistory returns up to limit entries of the user with the given ID, newest first
+func (b *Storage) AuditHistory(userID int64, limit int) ([]*audit.Entry, error) {
+	entries := make([]*audit.Entry, 0)
+
+	err := b.db.View(func(tx *bbolt.Tx) error {
+		entriesBucket := tx.Bucket(auditEntriesBucket)
+		cursor := tx.Bucket(auditUsersBucket).Cursor()
+		prefix := idKey(userID)
+
+		// Seek to the first key after the prefix of the user and walk back through its entries
+		key, _ := cursor.Seek(idKey(userID + 1))
+		if key == nil {
+			key, _ = cursor.Last()
+		} else {
+			key, _ = cursor.Prev()
+		}
+
+		for ; key != nil && bytes.HasPrefix(key, prefix) && len(entries) < limit; key, _ = cursor.Prev() {
+			var entry audit.Entry
+			if err := json.Unmarshal(entriesBucket.Get(key[idLength:]), &entry); err != nil {
+				return err
+			}
+
+			entries = append(entries, &entry)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// AuditSince returns up to limit entries recorded after the given time, oldest first
+func (b *Storage) AuditSince(since time.Time, limit int) ([]*audit.Entry, error) {
+	entries := make([]*audit.Entry, 0)
+
+	err := b.db.View(func(tx *bbolt.Tx) error {
+		cursor := tx.Bucket(auditEntriesBucket).Cursor()
+
+		key, value := cursor.First()
+		if since.UnixNano() > 0 {
+			key, value = cursor.Seek(auditEntryKey(since.Add(time.Nanosecond)))
+		}
+
+		for ; key != nil && len(entries) < limit; key, value = cursor.Next() {
+			var entry audit.Entry
+			if err := json.Unmarshal(value, &entry); err != nil {
+				return err
+			}
+
+			entries = append(entries, &entry)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,469 @@
+package bolt
+
+import (
+	"bytes"
+	"sort"
+	"time"
+
+	"github.com/Aleksao998/LightningUserVault/core/common"
+	"go.etcd.io/bbolt"
+	"go.uber.org/zap"
+)
+
+// openTimeout is how long opening waits for the lock of a database file held by another process
+const openTimeout = time.Second
+
+var (
+	// usersBucket holds the users by big endian ID, its sequence allocates the IDs of new users
+	usersBucket = []byte("users")
+
+	// namesBucket is the name to ID secondary index, its values are empty
+	namesBucket = []byte("names")
+
+	// deletedBucket indexes soft deleted users by deletion time, its values are empty
+	deletedBucket = []byte("deleted")
+
+	// versionsBucket holds the immutable versions of users
+	versionsBucket = []byte("versions")
+)
+
+// Options configures optional behaviour of the storage
+type Options struct {
+	// KeepVersions stores an immutable copy of every written version of a user
+	KeepVersions bool
+}
+
+// Storage stores users in a single bbolt file. Every write runs in one bbolt transaction,
+// which are serialized by bbolt, so reading a user and writing it back is atomic without extra locking
+type Storage struct {
+	db      *bbolt.DB
+	logger  *zap.Logger
+	options Options
+}
+
+// NewStorage initializes a new Storage instance with a database at the given path
+func NewStorage(path string, logger *zap.Logger, options Options) (*Storage, error) {
+	db, err := bbolt.Open(path, 0o600, &bbolt.Options{Timeout: openTimeout})
+	if err != nil {
+		logger.Error("Failed to open bolt database", zap.String("path", path), zap.Error(err))
+
+		return nil, err
+	}
+
+	err = db.Update(func(tx *bbolt.Tx) error {
+		buckets := [][]byte{
+			usersBucket, namesBucket, deletedBucket, versionsBucket,
+			webhooksBucket, webhookDeliveriesBucket, webhookQueueBucket, webhookMetaBucket,
+			auditEntriesBucket, auditUsersBucket,
+		}
+
+		for _, name := range buckets {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		logger.Error("Failed to create bolt buckets", zap.Error(err))
+		db.Close()
+
+		return nil, err
+	}
+
+	return &Storage{
+		db:      db,
+		logger:  logger,
+		options: options,
+	}, nil
+}
+
+// Set stores a new user, filling its ID, timestamps and version, and returns the ID
+func (b *Storage) Set(user *common.User) (int64, error) {
+	err := b.db.Update(func(tx *bbolt.Tx) error {
+		return b.insert(tx, user, time.Now().UTC())
+	})
+	if err != nil {
+		b.logger.Error("Failed to set value in database", zap.String("name", user.Name), zap.Error(err))
+
+		return 0, err
+	}
+
+	b.logger.Debug("Stored user in database", zap.Int64("ID", user.ID))
+
+	return user.ID, nil
+}
+
+// SetBatch stores all users in a single transaction, filling their IDs, timestamps and versions, and returns the IDs
+func (b *Storage) SetBatch(users []*common.User) ([]int64, error) {
+	now := time.Now().UTC()
+	ids := make([]int64, 0, len(users))
+
+	err := b.db.Update(func(tx *bbolt.Tx) error {
+		for _, user := range users {
+			if err := b.insert(tx, user, now); err != nil {
+				return err
+			}
+
+			ids = append(ids, user.ID)
+		}
+
+		return nil
+	})
+	if err != nil {
+		b.logger.Error("Failed to store batch of users in database", zap.Int("size", len(users)), zap.Error(err))
+
+		return nil, err
+	}
+
+	b.logger.Debug("Stored batch of users in database", zap.Int("size", len(ids)))
+
+	return ids, nil
+}
+
+// insert allocates the next ID from the sequence of the users bucket and writes the new user.
+// The sequence is only advanced when the transaction commits, so IDs of failed writes are allocated again
+func (b *Storage) insert(tx *bbolt.Tx, user *common.User, createdAt time.Time) error {
+	id, err := tx.Bucket(usersBucket).NextSequence()
+	if err != nil {
+		return err
+	}
+
+	user.ID = int64(id)
+	user.CreatedAt = createdAt
+	user.UpdatedAt = createdAt
+	user.Version = firstVersion
+	user.DeletedAt = nil
+
+	if err := tx.Bucket(namesBucket).Put(nameIndexKey(user.Name, user.ID), nil); err != nil {
+		return err
+	}
+
+	return b.put(tx, user)
+}
+
+// put writes the user record and its version when versions are kept
+func (b *Storage) put(tx *bbolt.Tx, user *common.User) error {
+	value, err := encodeUser(user)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Bucket(usersBucket).Put(idKey(user.ID), value); err != nil {
+		return err
+	}
+
+	if !b.options.KeepVersions {
+		return nil
+	}
+
+	return tx.Bucket(versionsBucket).Put(versionKey(user.ID, user.Version), value)
+}
+
+// Get retrieves the user with the given ID, it returns common.ErrUserNotFound if there is none
+func (b *Storage) Get(key int64) (*common.User, error) {
+	var user *common.User
+
+	err := b.db.View(func(tx *bbolt.Tx) error {
+		var err error
+
+		user, err = b.getExisting(tx, key)
+
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if user.DeletedAt != nil {
+		b.logger.Debug("Retrieved user is deleted", zap.Int64("ID", key))
+
+		return nil, common.ErrUserDeleted
+	}
+
+	b.logger.Debug("Retrieved user from database", zap.Int64("ID", user.ID))
+
+	return user, nil
+}
+
+// GetMulti retrieves the users with the given IDs from a single read transaction, missing and soft deleted users are omitted
+func (b *Storage) GetMulti(keys []int64) (map[int64]*common.User, error) {
+	users := make(map[int64]*common.User, len(keys))
+
+	err := b.db.View(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket(usersBucket)
+
+		for _, key := range keys {
+			value := bucket.Get(idKey(key))
+			if value == nil {
+				continue
+			}
+
+			user, err := decodeUser(key, value)
+			if err != nil {
+				return err
+			}
+
+			if user.DeletedAt == nil {
+				users[key] = user
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		b.logger.Error("Failed to get values from database", zap.Int("keys", len(keys)), zap.Error(err))
+
+		return nil, err
+	}
+
+	b.logger.Debug("Retrieved multiple users from database", zap.Int("keys", len(keys)), zap.Int("found", len(users)))
+
+	return users, nil
+}
+
+// FindByName returns all users with the given name, or with names starting with it when prefix is set.
+// Users are looked up through the name index and returned ordered by ID
+func (b *Storage) FindByName(name string, prefix bool) ([]*common.User, error) {
+	scanPrefix := []byte(name)
+	if !prefix {
+		// Exact matches are terminated by the separator right after the name
+		scanPrefix = append(scanPrefix, 0)
+	}
+
+	users := make([]*common.User, 0)
+
+	err := b.db.View(func(tx *bbolt.Tx) error {
+		cursor := tx.Bucket(namesBucket).Cursor()
+		bucket := tx.Bucket(usersBucket)
+
+		for key, _ := cursor.Seek(scanPrefix); key != nil && bytes.HasPrefix(key, scanPrefix); key, _ = cursor.Next() {
+			_, id := parseNameIndexKey(key)
+
+			value := bucket.Get(idKey(id))
+			if value == nil {
+				// The index is written together with the user, so this should never happen
+				b.logger.Warn("Name index points to missing user", zap.Int64("ID", id))
+
+				continue
+			}
+
+			user, err := decodeUser(id, value)
+			if err != nil {
+				return err
+			}
+
+			// Soft deleted users keep their index entry so it does not have to be written again on restore
+			if user.DeletedAt == nil {
+				users = append(users, user)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		b.logger.Error("Failed to find users by name", zap.String("name", name), zap.Error(err))
+
+		return nil, err
+	}
+
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].ID < users[j].ID
+	})
+
+	b.logger.Debug("Found users by name", zap.String("name", name), zap.Bool("prefix", prefix), zap.Int("count", len(users)))
+
+	return users, nil
+}
+
+// Update overwrites the stored fields of an existing user, filling its timestamps and incremented version,
+// and returns the replaced user. It returns an error if the key does not exist, is not at the given version or is soft deleted
+func (b *Storage) Update(user *common.User, version int64) (*common.User, error) {
+	var oldUser *common.User
+
+	err := b.db.Update(func(tx *bbolt.Tx) error {
+		var err error
+
+		oldUser, err = b.getExistingVersion(tx, user.ID, version)
+		if err != nil {
+			return err
+		}
+
+		if oldUser.DeletedAt != nil {
+			b.logger.Warn("User to update is deleted", zap.Int64("key", user.ID))
+
+			return common.ErrUserDeleted
+		}
+
+		updated := *user
+		updated.CreatedAt = oldUser.CreatedAt
+		updated.UpdatedAt = time.Now().UTC()
+		updated.Version = oldUser.Version + 1
+		updated.DeletedAt = nil
+
+		// Move the index entry to the new name together with the user record
+		names := tx.Bucket(namesBucket)
+		if err := names.Delete(nameIndexKey(oldUser.Name, user.ID)); err != nil {
+			return err
+		}
+
+		if err := names.Put(nameIndexKey(updated.Name, user.ID), nil); err != nil {
+			return err
+		}
+
+		if err := b.put(tx, &updated); err != nil {
+			return err
+		}
+
+		*user = updated
+
+		return nil
+	})
+	if err != nil {
+		return nil, b.writeError("Failed to update value in database", user.ID, err)
+	}
+
+	b.logger.Debug("Updated user in database", zap.Int64("ID", user.ID), zap.Int64("version", user.Version))
+
+	return oldUser, nil
+}
+
+// Delete removes an existing user together with its index entries and versions, also when it is soft deleted,
+// and returns the removed user. It returns an error if the key does not exist or is not at the given version. IDs are never reused
+func (b *Storage) Delete(key int64, version int64) (*common.User, error) {
+	var user *common.User
+
+	err := b.db.Update(func(tx *bbolt.Tx) error {
+		var err error
+
+		user, err = b.getExistingVersion(tx, key, version)
+		if err != nil {
+			return err
+		}
+
+		return deleteUser(tx, user)
+	})
+	if err != nil {
+		return nil, b.writeError("Failed to delete value from database", key, err)
+	}
+
+	b.logger.Debug("Deleted user from database", zap.Int64("ID", key))
+
+	return user, nil
+}
+
+// List returns up to limit users stored after the given cursor, ordered by ID.
+// The cursor is the last returned ID, so iteration is stable even while new users are being added
+func (b *Storage) List(cursor string, limit int) ([]*common.User, string, error) {
+	// Cursors encode the last returned ID the same way for every storage
+	var start []byte
+
+	if cursor != "" {
+		lastID, err := common.DecodeCursor(cursor)
+		if err != nil || len(lastID) != idLength {
+			b.logger.Warn("Invalid list cursor received", zap.String("cursor", cursor))
+
+			return nil, "", common.ErrInvalidCursor
+		}
+
+		start = idKey(common.BytesToInt64(lastID) + 1)
+	}
+
+	users := make([]*common.User, 0, limit)
+	nextCursor := ""
+
+	err := b.db.View(func(tx *bbolt.Tx) error {
+		iter := tx.Bucket(usersBucket).Cursor()
+
+		key, value := iter.Seek(start)
+		if start == nil {
+			key, value = iter.First()
+		}
+
+		for ; key != nil; key, value = iter.Next() {
+			user, err := decodeUser(parseIDKey(key), value)
+			if err != nil {
+				return err
+			}
+
+			if user.DeletedAt != nil {
+				continue
+			}
+
+			if len(users) == limit {
+				// There is at least one more user, so the page can be continued
+				nextCursor = common.EncodeCursor(common.Int64ToBytes(users[len(users)-1].ID))
+
+				break
+			}
+
+			users = append(users, user)
+		}
+
+		return nil
+	})
+	if err != nil {
+		b.logger.Error("Failed to list users from database", zap.Error(err))
+
+		return nil, "", err
+	}
+
+	b.logger.Debug("Listed users from database", zap.Int("count", len(users)))
+
+	return users, nextCursor, nil
+}
+
+// getExisting returns the user stored under the given key, or common.ErrUserNotFound if there is none
+func (b *Storage) getExisting(tx *bbolt.Tx, key int64) (*common.User, error) {
+	value := tx.Bucket(usersBucket).Get(idKey(key))
+	if value == nil {
+		b.logger.Warn("User not found", zap.Int64("key", key))
+
+		return nil, common.ErrUserNotFound
+	}
+
+	user, err := decodeUser(key, value)
+	if err != nil {
+		b.logger.Error("Failed to decode value from database", zap.Int64("key", key), zap.Error(err))
+
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// getExistingVersion returns the user stored under the given key if it is at the given version,
+// common.AnyVersion matches every version
+func (b *Storage) getExistingVersion(tx *bbolt.Tx, key int64, version int64) (*common.User, error) {
+	user, err := b.getExisting(tx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	if version != common.AnyVersion && user.Version != version {
+		b.logger.Warn("User version mismatch", zap.Int64("key", key), zap.Int64("version", user.Version), zap.Int64("expected", version))
+
+		return nil, common.ErrVersionMismatch
+	}
+
+	return user, nil
+}
+
+// writeError logs an unexpected error of a write, errors which are part of the storage contract are returned as is
+func (b *Storage) writeError(msg string, key int64, err error) error {
+	switch err {
+	case common.ErrUserNotFound, common.ErrVersionMismatch, common.ErrUserDeleted, common.ErrUserNotDeleted:
+	default:
+		b.logger.Error(msg, zap.Int64("key", key), zap.Error(err))
+	}
+
+	return err
+}
+
+// Close closes the database file
+func (b *Storage) Close() error {
+	b.logger.Info("Closing database connection")
+
+	return b.db.Close()
+}
@@ -0,0 +1,325 @@
+package bolt
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Aleksao998/LightningUserVault/core/audit"
+	"github.com/Aleksao998/LightningUserVault/core/audit/audittest"
+	"github.com/Aleksao998/LightningUserVault/core/common"
+	"github.com/Aleksao998/LightningUserVault/core/webhook"
+	"github.com/Aleksao998/LightningUserVault/core/webhook/webhooktest"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+// createBoltStorage creates a storage with a database file in a temporary directory and returns the file path
+func createBoltStorage(t *testing.T, options Options) (string, *Storage) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "vault.db")
+
+	store, err := NewStorage(path, zap.NewNop(), options)
+	if err != nil {
+		t.Fatalf("error creating bolt storage, %v", err)
+	}
+
+	t.Cleanup(func() {
+		store.Close()
+	})
+
+	return path, store
+}
+
+// newUsers creates users with the given names
+func newUsers(names ...string) []*common.User {
+	users := make([]*common.User, 0, len(names))
+	for _, name := range names {
+		users = append(users, &common.User{Name: name})
+	}
+
+	return users
+}
+
+// TestBoltStorage_WriteRead tests that stored users are read back with their IDs, timestamps and versions
+func TestBoltStorage_WriteRead(t *testing.T) {
+	t.Parallel()
+
+	_, store := createBoltStorage(t, Options{})
+
+	user := &common.User{Name: "alice", Email: "alice@example.com", Attributes: map[string]string{"team": "a"}}
+
+	id, err := store.Set(user)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), id)
+	assert.Equal(t, int64(1), user.Version)
+
+	retrieved, err := store.Get(id)
+	assert.NoError(t, err)
+	assert.Equal(t, user, retrieved)
+
+	_, err = store.Get(int64(100))
+	assert.ErrorIs(t, err, common.ErrUserNotFound)
+
+	ids, err := store.SetBatch(newUsers("bob", "carol"))
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{2, 3}, ids)
+
+	users, err := store.GetMulti([]int64{1, 3, 100})
+	assert.NoError(t, err)
+	assert.Len(t, users, 2)
+	assert.Equal(t, "carol", users[3].Name)
+}
+
+// TestBoltStorage_WriteParallel tests that concurrent writes get unique IDs from the bucket sequence
+func TestBoltStorage_WriteParallel(t *testing.T) {
+	t.Parallel()
+
+	_, store := createBoltStorage(t, Options{})
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			_, err := store.Set(&common.User{Name: "user"})
+			assert.NoError(t, err)
+		}()
+	}
+
+	wg.Wait()
+
+	users, _, err := store.List("", 100)
+	assert.NoError(t, err)
+	assert.Len(t, users, 20)
+	assert.Equal(t, int64(20), users[19].ID)
+}
+
+// TestBoltStorage_SequencePersisted tests that IDs continue after reopening and are not reused after a delete
+func TestBoltStorage_SequencePersisted(t *testing.T) {
+	t.Parallel()
+
+	path, store := createBoltStorage(t, Options{})
+
+	_, err := store.SetBatch(newUsers("alice", "bob"))
+	assert.NoError(t, err)
+	_, err = store.Delete(2, common.AnyVersion)
+	assert.NoError(t, err)
+	assert.NoError(t, store.Close())
+
+	reopened, err := NewStorage(path, zap.NewNop(), Options{})
+	assert.NoError(t, err)
+
+	defer reopened.Close()
+
+	id, err := reopened.Set(&common.User{Name: "carol"})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), id)
+}
+
+// TestBoltStorage_UpdateAndDelete tests conditional updates and deletes
+func TestBoltStorage_UpdateAndDelete(t *testing.T) {
+	t.Parallel()
+
+	_, store := createBoltStorage(t, Options{})
+
+	id, err := store.Set(&common.User{Name: "alice"})
+	assert.NoError(t, err)
+
+	user := &common.User{ID: id, Name: "bob"}
+
+	_, err = store.Update(user, 2)
+	assert.ErrorIs(t, err, common.ErrVersionMismatch)
+	replaced, err := store.Update(user, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), user.Version)
+	assert.Equal(t, "alice", replaced.Name)
+	assert.Equal(t, int64(1), replaced.Version)
+	_, err = store.Update(&common.User{ID: 100}, common.AnyVersion)
+	assert.ErrorIs(t, err, common.ErrUserNotFound)
+
+	// The name index follows the update
+	found, err := store.FindByName("alice", false)
+	assert.NoError(t, err)
+	assert.Empty(t, found)
+
+	found, err = store.FindByName("bob", false)
+	assert.NoError(t, err)
+	assert.Len(t, found, 1)
+
+	_, err = store.Delete(id, 1)
+	assert.ErrorIs(t, err, common.ErrVersionMismatch)
+	removed, err := store.Delete(id, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, "bob", removed.Name)
+	_, err = store.Delete(id, common.AnyVersion)
+	assert.ErrorIs(t, err, common.ErrUserNotFound)
+
+	found, err = store.FindByName("bob", true)
+	assert.NoError(t, err)
+	assert.Empty(t, found)
+}
+
+// TestBoltStorage_FindByName tests exact and prefix lookups through the name index
+func TestBoltStorage_FindByName(t *testing.T) {
+	t.Parallel()
+
+	_, store := createBoltStorage(t, Options{})
+
+	_, err := store.SetBatch(newUsers("alice", "alicia", "al", "bob"))
+	assert.NoError(t, err)
+
+	users, err := store.FindByName("al", false)
+	assert.NoError(t, err)
+	assert.Len(t, users, 1)
+
+	users, err = store.FindByName("ali", true)
+	assert.NoError(t, err)
+	assert.Len(t, users, 2)
+	assert.Equal(t, "alice", users[0].Name)
+}
+
+// TestBoltStorage_List tests that users are listed in ID order across pages
+func TestBoltStorage_List(t *testing.T) {
+	t.Parallel()
+
+	_, store := createBoltStorage(t, Options{})
+
+	_, err := store.SetBatch(newUsers("alice", "bob", "carol"))
+	assert.NoError(t, err)
+
+	users, cursor, err := store.List("", 2)
+	assert.NoError(t, err)
+	assert.Len(t, users, 2)
+
+	users, cursor, err = store.List(cursor, 2)
+	assert.NoError(t, err)
+	assert.Len(t, users, 1)
+	assert.Equal(t, "carol", users[0].Name)
+	assert.Empty(t, cursor)
+
+	_, _, err = store.List("invalid", 2)
+	assert.ErrorIs(t, err, common.ErrInvalidCursor)
+}
+
+// TestBoltStorage_SoftDeleteAndPurge tests that soft deleted users are hidden, can be restored and are purged
+func TestBoltStorage_SoftDeleteAndPurge(t *testing.T) {
+	t.Parallel()
+
+	_, store := createBoltStorage(t, Options{KeepVersions: true})
+
+	ids, err := store.SetBatch(newUsers("alice", "bob"))
+	assert.NoError(t, err)
+
+	replaced, err := store.SoftDelete(ids[0], 1)
+	assert.NoError(t, err)
+	assert.Equal(t, "alice", replaced.Name)
+	assert.Equal(t, int64(1), replaced.Version)
+	assert.Nil(t, replaced.DeletedAt)
+	_, err = store.SoftDelete(ids[0], common.AnyVersion)
+	assert.ErrorIs(t, err, common.ErrUserDeleted)
+
+	_, err = store.Get(ids[0])
+	assert.ErrorIs(t, err, common.ErrUserDeleted)
+
+	listed, _, err := store.List("", 10)
+	assert.NoError(t, err)
+	assert.Len(t, listed, 1)
+
+	_, _, err = store.Restore(ids[1])
+	assert.ErrorIs(t, err, common.ErrUserNotDeleted)
+
+	restored, before, err := store.Restore(ids[0])
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), restored.Version)
+	assert.Equal(t, int64(2), before.Version)
+	assert.NotNil(t, before.DeletedAt)
+
+	_, err = store.SoftDelete(ids[1], common.AnyVersion)
+	assert.NoError(t, err)
+
+	purged, err := store.Purge(time.Now().Add(time.Second))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, purged)
+
+	_, _, err = store.Restore(ids[1])
+	assert.ErrorIs(t, err, common.ErrUserNotFound)
+
+	_, err = store.Versions(ids[1])
+	assert.ErrorIs(t, err, common.ErrUserNotFound)
+
+	found, err := store.FindByName("bob", false)
+	assert.NoError(t, err)
+	assert.Empty(t, found)
+}
+
+// TestBoltStorage_Versions tests point-in-time reads and compaction of versions
+func TestBoltStorage_Versions(t *testing.T) {
+	t.Parallel()
+
+	_, store := createBoltStorage(t, Options{KeepVersions: true})
+
+	// Versions of another user must not be mixed into the versions of the first one
+	ids, err := store.SetBatch(newUsers("alice", "other"))
+	assert.NoError(t, err)
+
+	created, err := store.Get(ids[0])
+	assert.NoError(t, err)
+
+	_, err = store.Update(&common.User{ID: ids[0], Name: "bob"}, common.AnyVersion)
+	assert.NoError(t, err)
+
+	before := time.Now().UTC()
+
+	_, err = store.Update(&common.User{ID: ids[0], Name: "carol"}, common.AnyVersion)
+	assert.NoError(t, err)
+
+	user, err := store.GetAsOf(ids[0], created.UpdatedAt)
+	assert.NoError(t, err)
+	assert.Equal(t, "alice", user.Name)
+
+	_, err = store.GetAsOf(ids[0], created.UpdatedAt.Add(-time.Second))
+	assert.ErrorIs(t, err, common.ErrUserNotFound)
+
+	versions, err := store.Versions(ids[0])
+	assert.NoError(t, err)
+	assert.Len(t, versions, 3)
+	assert.Equal(t, "carol", versions[0].Name)
+
+	versions, err = store.Versions(ids[1])
+	assert.NoError(t, err)
+	assert.Len(t, versions, 1)
+
+	compacted, err := store.CompactVersions(before)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, compacted)
+
+	user, err = store.GetAsOf(ids[0], before)
+	assert.NoError(t, err)
+	assert.Equal(t, "bob", user.Name)
+}
+
+func TestBoltStorage_WebhookConformance(t *testing.T) {
+	webhooktest.RunConformance(t, func(t *testing.T) webhook.Store {
+		t.Helper()
+
+		_, store := createBoltStorage(t, Options{})
+
+		return store
+	})
+}
+
+func TestBoltStorage_AuditConformance(t *testing.T) {
+	audittest.RunConformance(t, func(t *testing.T) audit.Store {
+		t.Helper()
+
+		_, store := createBoltStorage(t, Options{})
+
+		return store
+	})
+}
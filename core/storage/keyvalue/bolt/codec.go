@@ -0,0 +1,106 @@
+package bolt
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"time"
+
+	"github.com/Aleksao998/LightningUserVault/core/common"
+)
+
+const (
+	// idLength is the length of an encoded user ID
+	idLength = 8
+
+	// firstVersion is the version of newly stored users
+	firstVersion int64 = 1
+)
+
+// record is the stored representation of a user, the ID is the key and is not part of it
+type record struct {
+	Name       string            `json:"name"`
+	Email      string            `json:"email,omitempty"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+	CreatedAt  time.Time         `json:"created_at"`
+	UpdatedAt  time.Time         `json:"updated_at"`
+	Version    int64             `json:"version"`
+	DeletedAt  *time.Time        `json:"deleted_at,omitempty"`
+}
+
+// encodeUser returns the stored value of the given user
+func encodeUser(user *common.User) ([]byte, error) {
+	return json.Marshal(record{
+		Name:       user.Name,
+		Email:      user.Email,
+		Attributes: user.Attributes,
+		CreatedAt:  user.CreatedAt,
+		UpdatedAt:  user.UpdatedAt,
+		Version:    user.Version,
+		DeletedAt:  user.DeletedAt,
+	})
+}
+
+// decodeUser returns the user with the given ID from its stored value
+func decodeUser(id int64, value []byte) (*common.User, error) {
+	var r record
+	if err := json.Unmarshal(value, &r); err != nil {
+		return nil, err
+	}
+
+	return &common.User{
+		ID:         id,
+		Name:       r.Name,
+		Email:      r.Email,
+		Attributes: r.Attributes,
+		CreatedAt:  r.CreatedAt,
+		UpdatedAt:  r.UpdatedAt,
+		Version:    r.Version,
+		DeletedAt:  r.DeletedAt,
+	}, nil
+}
+
+// idKey returns the key of the user with the given ID.
+// Keys are big endian, so iterating over them follows the order of IDs
+func idKey(id int64) []byte {
+	return binary.BigEndian.AppendUint64(make([]byte, 0, idLength), uint64(id))
+}
+
+// parseIDKey extracts the user ID from a key built by idKey
+func parseIDKey(key []byte) int64 {
+	return int64(binary.BigEndian.Uint64(key))
+}
+
+// nameIndexKey returns the name index key pointing from the given name to the given ID.
+// Keys are built as name + 0x00 + big endian ID
+func nameIndexKey(name string, id int64) []byte {
+	key := make([]byte, 0, len(name)+1+idLength)
+	key = append(key, name...)
+	key = append(key, 0)
+
+	return binary.BigEndian.AppendUint64(key, uint64(id))
+}
+
+// parseNameIndexKey extracts the name and ID from a name index key
+func parseNameIndexKey(key []byte) (string, int64) {
+	nameEnd := len(key) - idLength - 1
+
+	return string(key[:nameEnd]), int64(binary.BigEndian.Uint64(key[nameEnd+1:]))
+}
+
+// deletedIndexKey returns the deletion index key of the given user deleted at the given time.
+// Keys are built as big endian deletion time in nanoseconds + big endian ID
+func deletedIndexKey(deletedAt time.Time, id int64) []byte {
+	key := make([]byte, 0, 2*idLength)
+	key = binary.BigEndian.AppendUint64(key, uint64(deletedAt.UnixNano()))
+
+	return binary.BigEndian.AppendUint64(key, uint64(id))
+}
+
+// versionKey returns the key of the given version of the user with the given ID.
+// Keys are built as big endian ID + big endian version, so the versions of a user are adjacent and ordered
+func versionKey(id int64, version int64) []byte {
+	key := make([]byte, 0, 2*idLength)
+	key = binary.BigEndian.AppendUint64(key, uint64(id))
+
+	return binary.BigEndian.AppendUint64(key, uint64(version))
+}
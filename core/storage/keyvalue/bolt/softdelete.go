@@ -0,0 +1,191 @@
+package bolt
+
+import (
+	"bytes"
+	"time"
+
+	"github.com/Aleksao998/LightningUserVault/core/common"
+	"go.etcd.io/bbolt"
+	"go.uber.org/zap"
+)
+
+// purgeBatchSize is the number of users removed in a single transaction while purging
+const purgeBatchSize = 1000
+
+// SoftDelete marks an existing user as deleted and increments its version, the record is kept until it is restored or purged.
+// It returns the user as it was before, or an error if the key does not exist, is not at the given version or is already deleted
+func (b *Storage) SoftDelete(key int64, version int64) (*common.User, error) {
+	var before common.User
+
+	err := b.db.Update(func(tx *bbolt.Tx) error {
+		user, err := b.getExistingVersion(tx, key, version)
+		if err != nil {
+			return err
+		}
+
+		if user.DeletedAt != nil {
+			b.logger.Warn("User to delete is already deleted", zap.Int64("key", key))
+
+			return common.ErrUserDeleted
+		}
+
+		before = *user
+
+		deletedAt := time.Now().UTC()
+		user.DeletedAt = &deletedAt
+		user.UpdatedAt = deletedAt
+		user.Version++
+
+		if err := tx.Bucket(deletedBucket).Put(deletedIndexKey(deletedAt, key), nil); err != nil {
+			return err
+		}
+
+		return b.put(tx, user)
+	})
+	if err != nil {
+		return nil, b.writeError("Failed to soft delete value in database", key, err)
+	}
+
+	b.logger.Debug("Soft deleted user in database", zap.Int64("ID", key))
+
+	return &before, nil
+}
+
+// Restore clears the deletion mark of a soft deleted user and returns it with its timestamps and incremented version,
+// together with the soft deleted user. It returns an error if the key does not exist or is not deleted
+func (b *Storage) Restore(key int64) (*common.User, *common.User, error) {
+	var user *common.User
+
+	var before common.User
+
+	err := b.db.Update(func(tx *bbolt.Tx) error {
+		var err error
+
+		user, err = b.getExisting(tx, key)
+		if err != nil {
+			return err
+		}
+
+		if user.DeletedAt == nil {
+			b.logger.Warn("User to restore is not deleted", zap.Int64("key", key))
+
+			return common.ErrUserNotDeleted
+		}
+
+		before = *user
+
+		if err := tx.Bucket(deletedBucket).Delete(deletedIndexKey(*user.DeletedAt, key)); err != nil {
+			return err
+		}
+
+		user.DeletedAt = nil
+		user.UpdatedAt = time.Now().UTC()
+		user.Version++
+
+		return b.put(tx, user)
+	})
+	if err != nil {
+		return nil, nil, b.writeError("Failed to restore value in database", key, err)
+	}
+
+	b.logger.Debug("Restored user in database", zap.Int64("ID", key))
+
+	return user, &before, nil
+}
+
+// Purge permanently removes users soft deleted before the given time, walking the deleted index in deletion order
+func (b *Storage) Purge(before time.Time) (int, error) {
+	purged := 0
+
+	for {
+		count, err := b.purgeBatch(before)
+		purged += count
+
+		if err != nil {
+			b.logger.Error("Failed to purge deleted users", zap.Int("purged", purged), zap.Error(err))
+
+			return purged, err
+		}
+
+		if count < purgeBatchSize {
+			break
+		}
+	}
+
+	b.logger.Debug("Purged deleted users from database", zap.Time("before", before), zap.Int("count", purged))
+
+	return purged, nil
+}
+
+// purgeBatch removes up to purgeBatchSize users deleted before the given time in a single transaction,
+// so writers are not blocked for the whole purge
+func (b *Storage) purgeBatch(before time.Time) (int, error) {
+	count := 0
+
+	err := b.db.Update(func(tx *bbolt.Tx) error {
+		upperBound := deletedIndexKey(before, 0)
+
+		// Keys are collected first, removing keys while a cursor walks over them skips entries
+		indexKeys := make([][]byte, 0)
+
+		cursor := tx.Bucket(deletedBucket).Cursor()
+		for key, _ := cursor.First(); key != nil && bytes.Compare(key, upperBound) < 0; key, _ = cursor.Next() {
+			if len(indexKeys) == purgeBatchSize {
+				break
+			}
+
+			indexKeys = append(indexKeys, append([]byte{}, key...))
+		}
+
+		for _, indexKey := range indexKeys {
+			id := parseIDKey(indexKey[idLength:])
+
+			// An entry whose user is missing should never exist, if it does only the entry is removed
+			value := tx.Bucket(usersBucket).Get(idKey(id))
+			if value != nil {
+				user, err := decodeUser(id, value)
+				if err != nil {
+					return err
+				}
+
+				if err := deleteUser(tx, user); err != nil {
+					return err
+				}
+			}
+
+			if err := tx.Bucket(deletedBucket).Delete(indexKey); err != nil {
+				return err
+			}
+		}
+
+		count = len(indexKeys)
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// deleteUser removes the user record, its versions and all of its index entries
+func deleteUser(tx *bbolt.Tx, user *common.User) error {
+	if err := tx.Bucket(usersBucket).Delete(idKey(user.ID)); err != nil {
+		return err
+	}
+
+	if err := deleteVersions(tx, user.ID); err != nil {
+		return err
+	}
+
+	if err := tx.Bucket(namesBucket).Delete(nameIndexKey(user.Name, user.ID)); err != nil {
+		return err
+	}
+
+	if user.DeletedAt != nil {
+		return tx.Bucket(deletedBucket).Delete(deletedIndexKey(*user.DeletedAt, user.ID))
+	}
+
+	return nil
+}
@@ -0,0 +1,183 @@
+package bolt
+
+import (
+	"bytes"
+	"time"
+
+	"github.com/Aleksao998/LightningUserVault/core/common"
+	"go.etcd.io/bbolt"
+	"go.uber.org/zap"
+)
+
+// deleteVersions removes all versions of the user
+func deleteVersions(tx *bbolt.Tx, id int64) error {
+	prefix := idKey(id)
+	cursor := tx.Bucket(versionsBucket).Cursor()
+
+	// Removing a key moves the cursor, so it seeks the first remaining version after every removal
+	for key, _ := cursor.Seek(prefix); key != nil && bytes.HasPrefix(key, prefix); key, _ = cursor.Seek(prefix) {
+		if err := cursor.Delete(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetAsOf returns the latest version of the user which was written at or before the given time.
+// The current record is the latest version, so it is used for users written before versions were kept
+func (b *Storage) GetAsOf(key int64, at time.Time) (*common.User, error) {
+	var user *common.User
+
+	err := b.db.View(func(tx *bbolt.Tx) error {
+		current, err := b.getExisting(tx, key)
+		if err != nil {
+			return err
+		}
+
+		user = current
+
+		if current.UpdatedAt.After(at) {
+			user, err = findVersion(tx, key, func(version *common.User) bool {
+				return !version.UpdatedAt.After(at)
+			})
+		}
+
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if user == nil {
+		b.logger.Debug("No user version retained at time", zap.Int64("key", key), zap.Time("at", at))
+
+		return nil, common.ErrUserNotFound
+	}
+
+	if user.DeletedAt != nil {
+		return nil, common.ErrUserDeleted
+	}
+
+	b.logger.Debug("Retrieved user version from database", zap.Int64("ID", key), zap.Int64("version", user.Version))
+
+	return user, nil
+}
+
+// Versions returns all retained versions of the user, newest first
+func (b *Storage) Versions(key int64) ([]*common.User, error) {
+	versions := make([]*common.User, 0)
+
+	err := b.db.View(func(tx *bbolt.Tx) error {
+		current, err := b.getExisting(tx, key)
+		if err != nil {
+			return err
+		}
+
+		_, err = findVersion(tx, key, func(version *common.User) bool {
+			versions = append(versions, version)
+
+			return false
+		})
+		if err != nil {
+			return err
+		}
+
+		// The current record was written before versions were kept
+		if len(versions) == 0 || versions[0].Version != current.Version {
+			versions = append([]*common.User{current}, versions...)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	b.logger.Debug("Retrieved user versions from database", zap.Int64("ID", key), zap.Int("count", len(versions)))
+
+	return versions, nil
+}
+
+// findVersion walks the versions of the user from the newest to the oldest and returns the first one matching,
+// or nil if none matches
+func findVersion(tx *bbolt.Tx, key int64, match func(version *common.User) bool) (*common.User, error) {
+	prefix := idKey(key)
+	cursor := tx.Bucket(versionsBucket).Cursor()
+
+	// Position the cursor at the newest version, which is the last key with the prefix
+	k, value := cursor.Seek(idKey(key + 1))
+	if k == nil {
+		k, value = cursor.Last()
+	} else {
+		k, value = cursor.Prev()
+	}
+
+	for ; k != nil && bytes.HasPrefix(k, prefix); k, value = cursor.Prev() {
+		version, err := decodeUser(key, value)
+		if err != nil {
+			return nil, err
+		}
+
+		if match(version) {
+			return version, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// CompactVersions removes every version which was replaced by a newer version written at or before the given time.
+// Versions of a user are ordered by version, which is also the order of their times
+func (b *Storage) CompactVersions(before time.Time) (int, error) {
+	compacted := 0
+
+	err := b.db.Update(func(tx *bbolt.Tx) error {
+		replaced := make([][]byte, 0)
+
+		// previous is the key of the last version of the current user which was in effect before the given time
+		var previous []byte
+
+		cursor := tx.Bucket(versionsBucket).Cursor()
+		for key, value := cursor.First(); key != nil; key, value = cursor.Next() {
+			if previous != nil && !bytes.Equal(previous[:idLength], key[:idLength]) {
+				previous = nil
+			}
+
+			version, err := decodeUser(parseIDKey(key[:idLength]), value)
+			if err != nil {
+				return err
+			}
+
+			if version.UpdatedAt.After(before) {
+				continue
+			}
+
+			// The previous version was replaced before the given time
+			if previous != nil {
+				replaced = append(replaced, previous)
+			}
+
+			previous = append([]byte{}, key...)
+		}
+
+		for _, key := range replaced {
+			if err := tx.Bucket(versionsBucket).Delete(key); err != nil {
+				return err
+			}
+		}
+
+		compacted = len(replaced)
+
+		return nil
+	})
+	if err != nil {
+		b.logger.Error("Failed to compact user versions", zap.Error(err))
+
+		return 0, err
+	}
+
+	b.logger.Debug("Compacted user versions in database", zap.Time("before", before), zap.Int("count", compacted))
+
+	return compacted, nil
+}
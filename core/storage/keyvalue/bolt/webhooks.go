@@ -0,0 +1,292 @@
+package bolt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"time"
+
+	"github.com/Aleksao998/LightningUserVault/core/webhook"
+	"go.etcd.io/bbolt"
+	"go.uber.org/zap"
+)
+
+var (
+	// webhooksBucket holds the registered webhooks by big endian ID, its sequence allocates their IDs
+	webhooksBucket = []byte("webhooks")
+
+	// webhookDeliveriesBucket holds webhook deliveries by big endian ID, its sequence allocates their IDs
+	webhookDeliveriesBucket = []byte("webhook_deliveries")
+
+	// webhookQueueBucket indexes pending deliveries by their next attempt, its values are empty
+	webhookQueueBucket = []byte("webhook_queue")
+
+	// webhookMetaBucket holds the state of the webhook dispatcher
+	webhookMetaBucket = []byte("webhook_meta")
+
+	// webhookCursorKey holds the ID of the last event which was queued for webhooks
+	webhookCursorKey = []byte("cursor")
+)
+
+// webhookQueueKey returns the queue key of the delivery with the given next attempt.
+// Keys are built as big endian next attempt in nanoseconds + big endian delivery ID
+func webhookQueueKey(nextAttempt time.Time, id int64) []byte {
+	key := make([]byte, 0, 2*idLength)
+	key = binary.BigEndian.AppendUint64(key, uint64(nextAttempt.UnixNano()))
+
+	return binary.BigEndian.AppendUint64(key, uint64(id))
+}
+
+// AddWebhook allocates the next ID from the sequence of the webhooks bucket and stores the webhook
+func (b *Storage) AddWebhook(hook *webhook.Webhook) error {
+	err := b.db.Update(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket(webhooksBucket)
+
+		id, err := bucket.NextSequence()
+		if err != nil {
+			return err
+		}
+
+		hook.ID = int64(id)
+
+		value, err := json.Marshal(hook)
+		if err != nil {
+			return err
+		}
+
+		return bucket.Put(idKey(hook.ID), value)
+	})
+	if err != nil {
+		b.logger.Error("Failed to store webhook in database", zap.String("url", hook.URL), zap.Error(err))
+
+		return err
+	}
+
+	return nil
+}
+
+// GetWebhook returns the webhook with the given ID
+func (b *Storage) GetWebhook(id int64) (*webhook.Webhook, error) {
+	var hook webhook.Webhook
+
+	err := b.db.View(func(tx *bbolt.Tx) error {
+		value := tx.Bucket(webhooksBucket).Get(idKey(id))
+		if value == nil {
+			return webhook.ErrWebhookNotFound
+		}
+
+		return json.Unmarshal(value, &hook)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &hook, nil
+}
+
+// ListWebhooks returns all webhooks ordered by ID
+func (b *Storage) ListWebhooks() ([]*webhook.Webhook, error) {
+	webhooks := make([]*webhook.Webhook, 0)
+
+	err := b.db.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket(webhooksBucket).ForEach(func(_, value []byte) error {
+			var hook webhook.Webhook
+			if err := json.Unmarshal(value, &hook); err != nil {
+				return err
+			}
+
+			webhooks = append(webhooks, &hook)
+
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return webhooks, nil
+}
+
+// RemoveWebhook removes the webhook with the given ID
+func (b *Storage) RemoveWebhook(id int64) error {
+	return b.db.Update(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket(webhooksBucket)
+
+		if bucket.Get(idKey(id)) == nil {
+			return webhook.ErrWebhookNotFound
+		}
+
+		return bucket.Delete(idKey(id))
+	})
+}
+
+// WebhookCursor returns the ID of the last queued event
+func (b *Storage) WebhookCursor() (int64, error) {
+	var cursor int64
+
+	err := b.db.View(func(tx *bbolt.Tx) error {
+		if value := tx.Bucket(webhookMetaBucket).Get(webhookCursorKey); value != nil {
+			cursor = parseIDKey(value)
+		}
+
+		return nil
+	})
+
+	return cursor, err
+}
+
+// EnqueueDeliveries allocates IDs to the deliveries and stores them with their queue entries and the cursor in one transaction
+func (b *Storage) EnqueueDeliveries(cursor int64, deliveries []*webhook.Delivery) error {
+	err := b.db.Update(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket(webhookDeliveriesBucket)
+
+		for _, delivery := range deliveries {
+			id, err := bucket.NextSequence()
+			if err != nil {
+				return err
+			}
+
+			delivery.ID = int64(id)
+
+			if err := putDelivery(tx, delivery); err != nil {
+				return err
+			}
+		}
+
+		return tx.Bucket(webhookMetaBucket).Put(webhookCursorKey, idKey(cursor))
+	})
+	if err != nil {
+		b.logger.Error("Failed to queue webhook deliveries in database", zap.Int64("cursor", cursor), zap.Error(err))
+
+		return err
+	}
+
+	return nil
+}
+
+// DueDeliveries returns up to limit pending deliveries whose next attempt is not after the given time, oldest first
+func (b *Storage) DueDeliveries(now time.Time, limit int) ([]*webhook.Delivery, error) {
+	deliveries := make([]*webhook.Delivery, 0)
+	end := webhookQueueKey(now.Add(time.Nanosecond), 0)
+
+	err := b.db.View(func(tx *bbolt.Tx) error {
+		queue := tx.Bucket(webhookQueueBucket).Cursor()
+
+		for key, _ := queue.First(); key != nil && bytes.Compare(key, end) < 0 && len(deliveries) < limit; key, _ = queue.Next() {
+			delivery, err := getDelivery(tx, parseIDKey(key[idLength:]))
+			if err != nil {
+				return err
+			}
+
+			deliveries = append(deliveries, delivery)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return deliveries, nil
+}
+
+// GetDelivery returns the delivery with the given ID
+func (b *Storage) GetDelivery(id int64) (*webhook.Delivery, error) {
+	var delivery *webhook.Delivery
+
+	err := b.db.View(func(tx *bbolt.Tx) error {
+		var err error
+		delivery, err = getDelivery(tx, id)
+
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return delivery, nil
+}
+
+// ListDeliveries returns deliveries selected by the filter, newest first
+func (b *Storage) ListDeliveries(filter webhook.DeliveryFilter) ([]*webhook.Delivery, error) {
+	deliveries := make([]*webhook.Delivery, 0)
+
+	err := b.db.View(func(tx *bbolt.Tx) error {
+		cursor := tx.Bucket(webhookDeliveriesBucket).Cursor()
+
+		for key, value := cursor.Last(); key != nil && len(deliveries) < filter.Limit; key, value = cursor.Prev() {
+			var delivery webhook.Delivery
+			if err := json.Unmarshal(value, &delivery); err != nil {
+				return err
+			}
+
+			if filter.Matches(&delivery) {
+				deliveries = append(deliveries, &delivery)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return deliveries, nil
+}
+
+// UpdateDelivery stores the delivery and moves its queue entry to the new next attempt, or removes it
+// when the delivery is no longer pending
+func (b *Storage) UpdateDelivery(delivery *webhook.Delivery) error {
+	err := b.db.Update(func(tx *bbolt.Tx) error {
+		previous, err := getDelivery(tx, delivery.ID)
+		if err != nil {
+			return err
+		}
+
+		if previous.Status == webhook.Pending {
+			if err := tx.Bucket(webhookQueueBucket).Delete(webhookQueueKey(previous.NextAttempt, delivery.ID)); err != nil {
+				return err
+			}
+		}
+
+		return putDelivery(tx, delivery)
+	})
+	if err != nil && err != webhook.ErrDeliveryNotFound {
+		b.logger.Error("Failed to update webhook delivery in database", zap.Int64("delivery", delivery.ID), zap.Error(err))
+	}
+
+	return err
+}
+
+// getDelivery returns the delivery with the given ID, or webhook.ErrDeliveryNotFound if there is none
+func getDelivery(tx *bbolt.Tx, id int64) (*webhook.Delivery, error) {
+	value := tx.Bucket(webhookDeliveriesBucket).Get(idKey(id))
+	if value == nil {
+		return nil, webhook.ErrDeliveryNotFound
+	}
+
+	var delivery webhook.Delivery
+	if err := json.Unmarshal(value, &delivery); err != nil {
+		return nil, err
+	}
+
+	return &delivery, nil
+}
+
+// putDelivery writes the delivery record and its queue entry when it is pending
+func putDelivery(tx *bbolt.Tx, delivery *webhook.Delivery) error {
+	value, err := json.Marshal(delivery)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Bucket(webhookDeliveriesBucket).Put(idKey(delivery.ID), value); err != nil {
+		return err
+	}
+
+	if delivery.Status != webhook.Pending {
+		return nil
+	}
+
+	return tx.Bucket(webhookQueueBucket).Put(webhookQueueKey(delivery.NextAttempt, delivery.ID), nil)
+}
@@ -0,0 +1,159 @@
+package pebble
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"math"
+	"time"
+
+	"github.com/Aleksao998/LightningUserVault/core/audit"
+	"github.com/cockroachdb/pebble"
+	"go.uber.org/zap"
+)
+
+var (
+	// auditEntryPrefix is the namespace of audit entries, keyed by their unique big endian time in nanoseconds
+	auditEntryPrefix = []byte("__auditentry__")
+
+	// auditUserPrefix is the namespace of the per-user audit history. Its keys are built as
+	// auditUserPrefix + big endian user ID + big endian entry time in nanoseconds and have an empty value
+	auditUserPrefix = []byte("__audituser__")
+)
+
+// auditEntryKey returns the key of the audit entry recorded at the given time
+func auditEntryKey(at time.Time) []byte {
+	key := make([]byte, 0, len(auditEntryPrefix)+userKeyLength)
+	key = append(key, auditEntryPrefix...)
+
+	return binary.BigEndian.AppendUint64(key, uint64(at.UnixNano()))
+}
+
+// auditUserKey returns the history key of the user audit entry recorded at the given time
+func auditUserKey(userID int64, at time.Time) []byte {
+	key := make([]byte, 0, len(auditUserPrefix)+2*userKeyLength)
+	key = append(key, auditUserPrefix...)
+	key = binary.BigEndian.AppendUint64(key, uint64(userID))
+
+	return binary.BigEndian.AppendUint64(key, uint64(at.UnixNano()))
+}
+
+// lastAuditEntry returns the newest audit entry, or an empty entry if none was recorded yet
+func (p *Storage) lastAuditEntry() (*audit.Entry, error) {
+	iter, err := p.db.NewIter(&pebble.IterOptions{
+		LowerBound: auditEntryPrefix,
+		UpperBound: prefixUpperBound(auditEntryPrefix),
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Close()
+
+	var entry audit.Entry
+
+	if !iter.Last() {
+		return &entry, iter.Error()
+	}
+
+	if err := json.Unmarshal(iter.Value(), &entry); err != nil {
+		return nil, err
+	}
+
+	return &entry, nil
+}
+
+// RecordAudit assigns the next ID and a time after the last entry to the entry and stores it with its history key
+func (p *Storage) RecordAudit(entry *audit.Entry) error {
+	p.auditLock.Lock()
+	defer p.auditLock.Unlock()
+
+	last, err := p.lastAuditEntry()
+	if err != nil {
+		return err
+	}
+
+	// Entries are keyed by time, so it never repeats or goes back even if the clock does
+	entry.ID = last.ID + 1
+	entry.Time = audit.NextTime(last.Time, time.Nanosecond)
+
+	encoded, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	batch := p.db.NewBatch()
+	defer batch.Close()
+
+	if err := batch.Set(auditEntryKey(entry.Time), encoded, nil); err != nil {
+		return err
+	}
+
+	if err := batch.Set(auditUserKey(entry.UserID, entry.Time), nil, nil); err != nil {
+		return err
+	}
+
+	if err := batch.Commit(pebble.Sync); err != nil {
+		p.logger.Error("Failed to record audit entry in database", zap.Int64("userID", entry.UserID), zap.Error(err))
+
+		return err
+	}
+
+	return nil
+}
+
+// AuditHistory returns up to limit entries of the user with the given ID, newest first
+func (p *Storage) AuditHistory(userID int64, limit int) ([]*audit.Entry, error) {
+	iter, err := p.db.NewIter(&pebble.IterOptions{
+		LowerBound: auditUserKey(userID, time.Unix(0, 0)),
+		UpperBound: auditUserKey(userID, time.Unix(0, math.MaxInt64)),
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Close()
+
+	entries := make([]*audit.Entry, 0)
+
+	for valid := iter.Last(); valid && len(entries) < limit; valid = iter.Prev() {
+		key := iter.Key()
+		at := time.Unix(0, int64(binary.BigEndian.Uint64(key[len(key)-userKeyLength:])))
+
+		var entry audit.Entry
+		if err := p.readJSON(auditEntryKey(at), &entry, pebble.ErrNotFound); err != nil {
+			return nil, err
+		}
+
+		entries = append(entries, &entry)
+	}
+
+	return entries, iter.Error()
+}
+
+// AuditSince returns up to limit entries recorded after the given time, oldest first
+func (p *Storage) AuditSince(since time.Time, limit int) ([]*audit.Entry, error) {
+	lower := auditEntryPrefix
+	if since.UnixNano() > 0 {
+		lower = auditEntryKey(since.Add(time.Nanosecond))
+	}
+
+	iter, err := p.db.NewIter(&pebble.IterOptions{
+		LowerBound: lower,
+		UpperBound: prefixUpperBound(auditEntryPrefix),
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Close()
+
+	entries := make([]*audit.Entry, 0)
+
+	for valid := iter.First(); valid && len(entries) < limit; valid = iter.Next() {
+		var entry audit.Entry
+		if err := json.Unmarshal(iter.Value(), &entry); err != nil {
+			return nil, err
+		}
+
+		entries = append(entries, &entry)
+	}
+
+	return entries, iter.Error()
+}
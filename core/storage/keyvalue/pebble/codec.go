@@ -0,0 +1,85 @@
+package pebble
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/Aleksao998/LightningUserVault/core/common"
+)
+
+const (
+	// encodingMarker starts every versioned value. Values written before versioning are raw user names,
+	// which are valid UTF-8 and can therefore never contain this byte
+	encodingMarker byte = 0xff
+
+	// encodingV1 is the version of values holding a JSON encoded record
+	encodingV1 byte = 1
+
+	// firstVersion is the version of newly stored users
+	firstVersion int64 = 1
+)
+
+var errUnknownEncoding = errors.New("unknown value encoding")
+
+// record is the stored representation of a user, the ID is the key and is not part of it
+type record struct {
+	Name       string            `json:"name"`
+	Email      string            `json:"email,omitempty"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+	CreatedAt  time.Time         `json:"created_at"`
+	UpdatedAt  time.Time         `json:"updated_at"`
+	Version    int64             `json:"version,omitempty"`
+	DeletedAt  *time.Time        `json:"deleted_at,omitempty"`
+}
+
+// encodeUser returns the stored value of the given user in the latest encoding
+func encodeUser(user *common.User) ([]byte, error) {
+	data, err := json.Marshal(record{
+		Name:       user.Name,
+		Email:      user.Email,
+		Attributes: user.Attributes,
+		CreatedAt:  user.CreatedAt,
+		UpdatedAt:  user.UpdatedAt,
+		Version:    user.Version,
+		DeletedAt:  user.DeletedAt,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]byte{encodingMarker, encodingV1}, data...), nil
+}
+
+// decodeUser returns the user with the given ID from its stored value, in any supported encoding
+func decodeUser(id int64, value []byte) (*common.User, error) {
+	if len(value) == 0 || value[0] != encodingMarker {
+		// Raw name written before values were versioned
+		return &common.User{ID: id, Name: string(value), Version: firstVersion}, nil
+	}
+
+	if len(value) < 2 || value[1] != encodingV1 {
+		return nil, errUnknownEncoding
+	}
+
+	var r record
+	if err := json.Unmarshal(value[2:], &r); err != nil {
+		return nil, err
+	}
+
+	if r.Version == 0 {
+		// Records written before user versions were introduced
+		r.Version = firstVersion
+	}
+
+	return &common.User{
+		ID:         id,
+		Name:       r.Name,
+		Email:      r.Email,
+		Attributes: r.Attributes,
+		CreatedAt:  r.CreatedAt,
+		UpdatedAt:  r.UpdatedAt,
+		Version:    r.Version,
+		DeletedAt:  r.DeletedAt,
+	}, nil
+}
@@ -0,0 +1,252 @@
+package pebble
+
+import (
+	"encoding/binary"
+	"errors"
+	"sort"
+
+	"github.com/Aleksao998/LightningUserVault/core/common"
+	"github.com/cockroachdb/pebble"
+	"go.uber.org/zap"
+)
+
+const (
+	// nameIndexReadyKey marks that the name index contains all users stored before the index was introduced
+	nameIndexReadyKey = "__nameIndexReady__"
+
+	// idIndexReadyKey marks that the ID index contains all users stored before the index was introduced
+	idIndexReadyKey = "__idIndexReady__"
+
+	// indexBuildBatchSize is the number of index entries committed at once while building the index
+	indexBuildBatchSize = 1000
+)
+
+var (
+	// nameIndexPrefix is the namespace of the name to ID secondary index.
+	// Index keys are built as nameIndexPrefix + name + 0x00 + big endian ID and have an empty value
+	nameIndexPrefix = []byte("__nameidx__")
+
+	// idIndexPrefix is the namespace of the index of users ordered by ID.
+	// User keys are little endian, so they do not sort by ID. Index keys are built as idIndexPrefix + big endian ID
+	// and have an empty value
+	idIndexPrefix = []byte("__ididx__")
+)
+
+// nameIndexKey returns the index key pointing from the given name to the given ID
+func nameIndexKey(name string, id int64) []byte {
+	key := make([]byte, 0, len(nameIndexPrefix)+len(name)+1+userKeyLength)
+	key = append(key, nameIndexPrefix...)
+	key = append(key, name...)
+	key = append(key, 0)
+
+	return binary.BigEndian.AppendUint64(key, uint64(id))
+}
+
+// parseNameIndexKey extracts the name and ID from an index key
+func parseNameIndexKey(key []byte) (string, int64) {
+	nameEnd := len(key) - userKeyLength - 1
+
+	return string(key[len(nameIndexPrefix):nameEnd]), int64(binary.BigEndian.Uint64(key[nameEnd+1:]))
+}
+
+// idIndexKey returns the ID index key of the given ID
+func idIndexKey(id int64) []byte {
+	key := make([]byte, 0, len(idIndexPrefix)+userKeyLength)
+	key = append(key, idIndexPrefix...)
+
+	return binary.BigEndian.AppendUint64(key, uint64(id))
+}
+
+// parseIDIndexKey extracts the ID from an ID index key
+func parseIDIndexKey(key []byte) int64 {
+	return int64(binary.BigEndian.Uint64(key[len(idIndexPrefix):]))
+}
+
+// prefixUpperBound returns the smallest key which is greater than every key starting with the given prefix
+func prefixUpperBound(prefix []byte) []byte {
+	upper := make([]byte, len(prefix))
+	copy(upper, prefix)
+
+	for i := len(upper) - 1; i >= 0; i-- {
+		upper[i]++
+		if upper[i] != 0 {
+			return upper[:i+1]
+		}
+	}
+
+	return nil
+}
+
+// FindByName returns all users with the given name, or with names starting with it when prefix is set.
+// Users are looked up through the name index and returned ordered by ID
+func (p *Storage) FindByName(name string, prefix bool) ([]*common.User, error) {
+	snapshot := p.db.NewSnapshot()
+	defer snapshot.Close()
+
+	scanPrefix := append([]byte{}, nameIndexPrefix...)
+	scanPrefix = append(scanPrefix, name...)
+
+	if !prefix {
+		// Exact matches are terminated by the separator right after the name
+		scanPrefix = append(scanPrefix, 0)
+	}
+
+	iter, err := snapshot.NewIter(&pebble.IterOptions{
+		LowerBound: scanPrefix,
+		UpperBound: prefixUpperBound(scanPrefix),
+	})
+	if err != nil {
+		p.logger.Error("Failed to create index iterator", zap.Error(err))
+
+		return nil, err
+	}
+	defer iter.Close()
+
+	users := make([]*common.User, 0)
+
+	for valid := iter.First(); valid; valid = iter.Next() {
+		indexedName, id := parseNameIndexKey(iter.Key())
+		if !prefix && indexedName != name {
+			continue
+		}
+
+		value, closer, err := snapshot.Get(common.Int64ToBytes(id))
+		if err != nil {
+			if errors.Is(err, pebble.ErrNotFound) {
+				// The index is written together with the user, so this should never happen
+				p.logger.Warn("Name index points to missing user", zap.Int64("ID", id))
+
+				continue
+			}
+
+			p.logger.Error("Failed to get value from database", zap.Int64("key", id), zap.Error(err))
+
+			return nil, err
+		}
+
+		user, err := decodeUser(id, value)
+		closer.Close()
+
+		if err != nil {
+			p.logger.Error("Failed to decode value from database", zap.Int64("key", id), zap.Error(err))
+
+			return nil, err
+		}
+
+		// Soft deleted users keep their index entry so it does not have to be written again on restore
+		if user.DeletedAt != nil {
+			continue
+		}
+
+		users = append(users, user)
+	}
+
+	if err := iter.Error(); err != nil {
+		p.logger.Error("Failed to iterate over name index", zap.Error(err))
+
+		return nil, err
+	}
+
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].ID < users[j].ID
+	})
+
+	p.logger.Debug("Found users by name", zap.String("name", name), zap.Bool("prefix", prefix), zap.Int("count", len(users)))
+
+	return users, nil
+}
+
+// buildNameIndex indexes users stored before the name index was introduced.
+// It runs only once, after that the index is maintained by every write
+func buildNameIndex(db *pebble.DB, logger *zap.Logger) error {
+	return buildIndex(db, logger, "name", nameIndexReadyKey, func(user *common.User) []byte {
+		return nameIndexKey(user.Name, user.ID)
+	})
+}
+
+// buildIDIndex indexes users stored before the ID index was introduced.
+// It runs only once, after that the index is maintained by every write
+func buildIDIndex(db *pebble.DB, logger *zap.Logger) error {
+	return buildIndex(db, logger, "ID", idIndexReadyKey, func(user *common.User) []byte {
+		return idIndexKey(user.ID)
+	})
+}
+
+// buildIndex writes the index entry returned by indexKey for every stored user and marks the index as ready
+// under readyKey. Nothing is written if the index is already marked as ready
+func buildIndex(
+	db *pebble.DB,
+	logger *zap.Logger,
+	index string,
+	readyKey string,
+	indexKey func(user *common.User) []byte,
+) error {
+	_, closer, err := db.Get([]byte(readyKey))
+	if err == nil {
+		return closer.Close()
+	}
+
+	if !errors.Is(err, pebble.ErrNotFound) {
+		return err
+	}
+
+	iter, err := db.NewIter(&pebble.IterOptions{})
+	if err != nil {
+		return err
+	}
+	defer iter.Close()
+
+	batch := db.NewBatch()
+	indexed := 0
+
+	for valid := iter.First(); valid; valid = iter.Next() {
+		key := iter.Key()
+		if len(key) != userKeyLength {
+			continue
+		}
+
+		user, err := decodeUser(common.BytesToInt64(key), iter.Value())
+		if err != nil {
+			batch.Close()
+
+			return err
+		}
+
+		if err := batch.Set(indexKey(user), nil, nil); err != nil {
+			batch.Close()
+
+			return err
+		}
+
+		indexed++
+
+		if batch.Count() >= indexBuildBatchSize {
+			if err := batch.Commit(pebble.Sync); err != nil {
+				batch.Close()
+
+				return err
+			}
+
+			batch.Close()
+			batch = db.NewBatch()
+		}
+	}
+
+	defer batch.Close()
+
+	if err := iter.Error(); err != nil {
+		return err
+	}
+
+	if err := batch.Set([]byte(readyKey), nil, nil); err != nil {
+		return err
+	}
+
+	if err := batch.Commit(pebble.Sync); err != nil {
+		return err
+	}
+
+	logger.Info("Built index for existing users", zap.String("index", index), zap.Int("count", indexed))
+
+	return nil
+}
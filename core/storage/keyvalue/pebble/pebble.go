@@ -3,24 +3,48 @@ package pebble
 import (
 	"errors"
 	"fmt"
+	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/Aleksao998/LightningUserVault/core/common"
 	"github.com/cockroachdb/pebble"
 	"go.uber.org/zap"
 )
 
-// nextIDKey represents key which will be used to save latest nextID on server stop
-const nextIDKey = "__nextID__"
+const (
+	// nextIDKey represents key which will be used to save latest nextID on server stop
+	nextIDKey = "__nextID__"
+
+	// userKeyLength is the length of keys holding user records, every internal key is longer
+	userKeyLength = 8
+)
+
+// Options configures optional behaviour of the storage
+type Options struct {
+	// KeepVersions stores an immutable copy of every written version of a user
+	KeepVersions bool
+}
 
 type Storage struct {
-	db     *pebble.DB
-	logger *zap.Logger
-	nextID int64
+	db      *pebble.DB
+	logger  *zap.Logger
+	nextID  int64
+	options Options
+
+	// writeLock serializes operations which modify existing users,
+	// so the existence check and the write happen atomically
+	writeLock sync.Mutex
+
+	// webhookLock serializes modifications of webhooks and their delivery queue
+	webhookLock sync.Mutex
+
+	// auditLock serializes audit records, so their IDs and times are strictly increasing
+	auditLock sync.Mutex
 }
 
 // NewStorage initializes a new Storage instance with a database at the given path
-func NewStorage(path string, logger *zap.Logger) (*Storage, error) {
+func NewStorage(path string, logger *zap.Logger, options Options) (*Storage, error) {
 	db, err := pebble.Open(path, &pebble.Options{})
 	if err != nil {
 		logger.Error("Failed to open pebble database", zap.String("path", path), zap.Error(err))
@@ -31,17 +55,142 @@ func NewStorage(path string, logger *zap.Logger) (*Storage, error) {
 	nextID := loadNextIDFromDB(db)
 	logger.Debug("Loaded nextID from database", zap.Int64("nextID", nextID))
 
+	if err := buildNameIndex(db, logger); err != nil {
+		logger.Error("Failed to build name index", zap.Error(err))
+		db.Close()
+
+		return nil, err
+	}
+
+	if err := buildIDIndex(db, logger); err != nil {
+		logger.Error("Failed to build ID index", zap.Error(err))
+		db.Close()
+
+		return nil, err
+	}
+
 	return &Storage{
-		db:     db,
-		logger: logger,
-		nextID: nextID,
+		db:      db,
+		logger:  logger,
+		nextID:  nextID,
+		options: options,
 	}, nil
 }
 
-// Set stores a value for a given key and returns an error if any issue occurs during the operation
-func (p *Storage) Set(value string) (int64, error) {
+// Set stores a new user, filling its ID, timestamps and version, and returns the ID
+func (p *Storage) Set(user *common.User) (int64, error) {
 	id := p.getNextID()
 
+	p.assertNotExists(id)
+
+	user.ID = common.BytesToInt64(id)
+	user.CreatedAt = time.Now().UTC()
+	user.UpdatedAt = user.CreatedAt
+	user.Version = firstVersion
+	user.DeletedAt = nil
+
+	batch := p.db.NewBatch()
+	defer batch.Close()
+
+	value, err := encodeUser(user)
+
+	// The user record and its index entries are committed together
+	if err == nil {
+		err = batch.Set(id, value, nil)
+	}
+
+	if err == nil {
+		err = batch.Set(nameIndexKey(user.Name, user.ID), nil, nil)
+	}
+
+	if err == nil {
+		err = batch.Set(idIndexKey(user.ID), nil, nil)
+	}
+
+	if err == nil {
+		err = p.setVersion(batch, user, value)
+	}
+
+	if err == nil {
+		err = batch.Commit(pebble.Sync)
+	}
+
+	if err != nil {
+		p.logger.Error("Failed to set value in database", zap.String("name", user.Name), zap.Error(err))
+	}
+
+	return user.ID, err
+}
+
+// SetBatch allocates IDs for all users, fills their IDs, timestamps and versions and writes them in a single atomic batch
+func (p *Storage) SetBatch(users []*common.User) ([]int64, error) {
+	lastID := atomic.AddInt64(&p.nextID, int64(len(users)))
+	firstID := lastID - int64(len(users)) + 1
+	now := time.Now().UTC()
+
+	batch := p.db.NewBatch()
+	defer batch.Close()
+
+	ids := make([]int64, 0, len(users))
+
+	for i, user := range users {
+		id := common.Int64ToBytes(firstID + int64(i))
+
+		p.assertNotExists(id)
+
+		user.ID = firstID + int64(i)
+		user.CreatedAt = now
+		user.UpdatedAt = now
+		user.Version = firstVersion
+		user.DeletedAt = nil
+
+		value, err := encodeUser(user)
+		if err != nil {
+			p.logger.Error("Failed to encode user", zap.String("name", user.Name), zap.Error(err))
+
+			return nil, err
+		}
+
+		if err := batch.Set(id, value, nil); err != nil {
+			p.logger.Error("Failed to add value to batch", zap.String("name", user.Name), zap.Error(err))
+
+			return nil, err
+		}
+
+		if err := batch.Set(nameIndexKey(user.Name, user.ID), nil, nil); err != nil {
+			p.logger.Error("Failed to add index entry to batch", zap.String("name", user.Name), zap.Error(err))
+
+			return nil, err
+		}
+
+		if err := batch.Set(idIndexKey(user.ID), nil, nil); err != nil {
+			p.logger.Error("Failed to add index entry to batch", zap.String("name", user.Name), zap.Error(err))
+
+			return nil, err
+		}
+
+		if err := p.setVersion(batch, user, value); err != nil {
+			p.logger.Error("Failed to add version to batch", zap.String("name", user.Name), zap.Error(err))
+
+			return nil, err
+		}
+
+		ids = append(ids, user.ID)
+	}
+
+	if err := batch.Commit(pebble.Sync); err != nil {
+		p.logger.Error("Failed to commit batch to database", zap.Int("size", len(users)), zap.Error(err))
+
+		return nil, err
+	}
+
+	p.logger.Debug("Stored batch of users in database", zap.Int64("firstID", firstID), zap.Int("size", len(users)))
+
+	return ids, nil
+}
+
+// assertNotExists panics if the newly allocated id is already present in the database
+func (p *Storage) assertNotExists(id []byte) {
 	_, _, err := p.db.Get(id)
 	if !errors.Is(err, pebble.ErrNotFound) {
 		msg := fmt.Sprintf("Id already exists %d:%v", common.BytesToInt64(id), err)
@@ -50,28 +199,35 @@ func (p *Storage) Set(value string) (int64, error) {
 		// This scenario should never occur. If it does, it indicates a bug in the pebble implementation
 		panic(fmt.Sprintf("Id already exists %d:%v", common.BytesToInt64(id), err))
 	}
-
-	err = p.db.Set(id, []byte(value), pebble.Sync)
-	if err != nil {
-		p.logger.Error("Failed to set value in database", zap.String("value", value), zap.Error(err))
-	}
-
-	return common.BytesToInt64(id), err
 }
 
 // Get retrieves the value for a given key and returns an error if any issue occurs during the operation
 func (p *Storage) Get(key int64) (*common.User, error) {
 	value, closer, err := p.db.Get(common.Int64ToBytes(key))
 	if err != nil {
+		if errors.Is(err, pebble.ErrNotFound) {
+			p.logger.Debug("User not found", zap.Int64("key", key))
+
+			return nil, common.ErrUserNotFound
+		}
+
 		p.logger.Error("Failed to get value from database", zap.Int64("key", key), zap.Error(err))
 
 		return nil, err
 	}
 	defer closer.Close()
 
-	user := &common.User{
-		ID:   key,
-		Name: string(value),
+	user, err := decodeUser(key, value)
+	if err != nil {
+		p.logger.Error("Failed to decode value from database", zap.Int64("key", key), zap.Error(err))
+
+		return nil, err
+	}
+
+	if user.DeletedAt != nil {
+		p.logger.Debug("Retrieved user is deleted", zap.Int64("ID", user.ID))
+
+		return nil, common.ErrUserDeleted
 	}
 
 	p.logger.Debug("Retrieved user from database", zap.Int64("ID", user.ID), zap.String("Name", user.Name))
@@ -79,6 +235,256 @@ func (p *Storage) Get(key int64) (*common.User, error) {
 	return user, nil
 }
 
+// Update overwrites the stored fields of an existing user, filling its timestamps and incremented version,
+// and returns the replaced user. It returns an error if the key does not exist or is not at the given version
+func (p *Storage) Update(user *common.User, version int64) (*common.User, error) {
+	p.writeLock.Lock()
+	defer p.writeLock.Unlock()
+
+	oldUser, err := p.getExistingVersion(user.ID, version)
+	if err != nil {
+		return nil, err
+	}
+
+	if oldUser.DeletedAt != nil {
+		p.logger.Warn("User to update is deleted", zap.Int64("key", user.ID))
+
+		return nil, common.ErrUserDeleted
+	}
+
+	user.CreatedAt = oldUser.CreatedAt
+	user.UpdatedAt = time.Now().UTC()
+	user.Version = oldUser.Version + 1
+	user.DeletedAt = nil
+
+	batch := p.db.NewBatch()
+	defer batch.Close()
+
+	value, err := encodeUser(user)
+
+	// Move the index entry to the new name together with the user record
+	if err == nil {
+		err = batch.Delete(nameIndexKey(oldUser.Name, user.ID), nil)
+	}
+
+	if err == nil {
+		err = batch.Set(nameIndexKey(user.Name, user.ID), nil, nil)
+	}
+
+	if err == nil {
+		err = batch.Set(idIndexKey(user.ID), nil, nil)
+	}
+
+	if err == nil {
+		err = batch.Set(common.Int64ToBytes(user.ID), value, nil)
+	}
+
+	if err == nil {
+		err = p.setVersion(batch, user, value)
+	}
+
+	if err == nil {
+		err = batch.Commit(pebble.Sync)
+	}
+
+	if err != nil {
+		p.logger.Error("Failed to update value in database", zap.Int64("key", user.ID), zap.Error(err))
+
+		return nil, err
+	}
+
+	p.logger.Debug("Updated user in database", zap.Int64("ID", user.ID), zap.String("Name", user.Name))
+
+	return oldUser, nil
+}
+
+// Delete removes the value for an existing key, also when it is soft deleted, and returns the removed user.
+// It returns an error if the key does not exist or is not at the given version.
+// Pebble writes a tombstone for the key, and since IDs are never reused the key is not written again
+func (p *Storage) Delete(key int64, version int64) (*common.User, error) {
+	p.writeLock.Lock()
+	defer p.writeLock.Unlock()
+
+	oldUser, err := p.getExistingVersion(key, version)
+	if err != nil {
+		return nil, err
+	}
+
+	batch := p.db.NewBatch()
+	defer batch.Close()
+
+	err = deleteUser(batch, oldUser)
+	if err == nil {
+		err = batch.Commit(pebble.Sync)
+	}
+
+	if err != nil {
+		p.logger.Error("Failed to delete value from database", zap.Int64("key", key), zap.Error(err))
+
+		return nil, err
+	}
+
+	p.logger.Debug("Deleted user from database", zap.Int64("ID", key))
+
+	return oldUser, nil
+}
+
+// List iterates over the ID index and returns up to limit users with an ID greater than the given cursor, ordered by ID.
+// The cursor is the last returned ID, so iteration is stable even while new users are being added
+func (p *Storage) List(cursor string, limit int) ([]*common.User, string, error) {
+	options := &pebble.IterOptions{
+		LowerBound: idIndexPrefix,
+		UpperBound: prefixUpperBound(idIndexPrefix),
+	}
+
+	if cursor != "" {
+		lastID, err := common.DecodeCursor(cursor)
+		if err != nil || len(lastID) != userKeyLength {
+			p.logger.Warn("Invalid list cursor received", zap.String("cursor", cursor))
+
+			return nil, "", common.ErrInvalidCursor
+		}
+
+		// The smallest index key which is greater than the one of the last returned ID
+		options.LowerBound = append(append(append([]byte{}, idIndexPrefix...), lastID...), 0)
+	}
+
+	snapshot := p.db.NewSnapshot()
+	defer snapshot.Close()
+
+	iter, err := snapshot.NewIter(options)
+	if err != nil {
+		p.logger.Error("Failed to create index iterator", zap.Error(err))
+
+		return nil, "", err
+	}
+	defer iter.Close()
+
+	users := make([]*common.User, 0, limit)
+	nextCursor := ""
+
+	for valid := iter.First(); valid; valid = iter.Next() {
+		id := parseIDIndexKey(iter.Key())
+
+		user, err := p.getExistingFrom(snapshot, id)
+		if err != nil {
+			if errors.Is(err, common.ErrUserNotFound) {
+				// The index is written together with the user, so this should never happen
+				p.logger.Warn("ID index points to missing user", zap.Int64("ID", id))
+
+				continue
+			}
+
+			return nil, "", err
+		}
+
+		if user.DeletedAt != nil {
+			continue
+		}
+
+		if len(users) == limit {
+			// There is at least one more user, so the page can be continued
+			nextCursor = common.EncodeCursor(idIndexKey(users[len(users)-1].ID)[len(idIndexPrefix):])
+
+			break
+		}
+
+		users = append(users, user)
+	}
+
+	if err := iter.Error(); err != nil {
+		p.logger.Error("Failed to iterate over ID index", zap.Error(err))
+
+		return nil, "", err
+	}
+
+	p.logger.Debug("Listed users from database", zap.Int("count", len(users)))
+
+	return users, nextCursor, nil
+}
+
+// getExisting returns the user stored under the given key, or common.ErrUserNotFound if there is none
+func (p *Storage) getExisting(key int64) (*common.User, error) {
+	return p.getExistingFrom(p.db, key)
+}
+
+// getExistingFrom returns the user stored under the given key in the given database or snapshot,
+// or common.ErrUserNotFound if there is none
+func (p *Storage) getExistingFrom(reader pebble.Reader, key int64) (*common.User, error) {
+	value, closer, err := reader.Get(common.Int64ToBytes(key))
+	if err != nil {
+		if errors.Is(err, pebble.ErrNotFound) {
+			p.logger.Warn("User not found", zap.Int64("key", key))
+
+			return nil, common.ErrUserNotFound
+		}
+
+		p.logger.Error("Failed to get value from database", zap.Int64("key", key), zap.Error(err))
+
+		return nil, err
+	}
+	defer closer.Close()
+
+	return decodeUser(key, value)
+}
+
+// getExistingVersion returns the user stored under the given key if it is at the given version,
+// common.AnyVersion matches every version. It must be called while holding the write lock
+func (p *Storage) getExistingVersion(key int64, version int64) (*common.User, error) {
+	user, err := p.getExisting(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if version != common.AnyVersion && user.Version != version {
+		p.logger.Warn("User version mismatch", zap.Int64("key", key), zap.Int64("version", user.Version), zap.Int64("expected", version))
+
+		return nil, common.ErrVersionMismatch
+	}
+
+	return user, nil
+}
+
+// GetMulti retrieves the values for the given keys from a single consistent snapshot, missing keys are omitted
+func (p *Storage) GetMulti(keys []int64) (map[int64]*common.User, error) {
+	snapshot := p.db.NewSnapshot()
+	defer snapshot.Close()
+
+	users := make(map[int64]*common.User, len(keys))
+
+	for _, key := range keys {
+		value, closer, err := snapshot.Get(common.Int64ToBytes(key))
+		if err != nil {
+			if errors.Is(err, pebble.ErrNotFound) {
+				continue
+			}
+
+			p.logger.Error("Failed to get value from database", zap.Int64("key", key), zap.Error(err))
+
+			return nil, err
+		}
+
+		user, err := decodeUser(key, value)
+		closer.Close()
+
+		if err != nil {
+			p.logger.Error("Failed to decode value from database", zap.Int64("key", key), zap.Error(err))
+
+			return nil, err
+		}
+
+		if user.DeletedAt != nil {
+			continue
+		}
+
+		users[key] = user
+	}
+
+	p.logger.Debug("Retrieved multiple users from database", zap.Int("keys", len(keys)), zap.Int("found", len(users)))
+
+	return users, nil
+}
+
 // getNextID retrieves next id for a new user
 func (p *Storage) getNextID() []byte {
 	id := atomic.AddInt64(&p.nextID, 1)
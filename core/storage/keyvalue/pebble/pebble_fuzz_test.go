@@ -4,6 +4,7 @@ import (
 	"os"
 	"testing"
 
+	"github.com/Aleksao998/LightningUserVault/core/common"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -20,7 +21,7 @@ func FuzzPebbleStorageWriteRead(f *testing.F) {
 		t.Parallel()
 
 		// Test Set method
-		id, err := store.Set(value)
+		id, err := store.Set(&common.User{Name: value})
 		if err != nil {
 			t.Fatalf("Error setting value: %s:%v", value, err)
 		}
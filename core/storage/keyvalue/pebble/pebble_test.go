@@ -4,14 +4,25 @@ import (
 	"fmt"
 	"os"
 	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/Aleksao998/LightningUserVault/core/audit"
+	"github.com/Aleksao998/LightningUserVault/core/audit/audittest"
+	"github.com/Aleksao998/LightningUserVault/core/common"
+	"github.com/Aleksao998/LightningUserVault/core/webhook"
+	"github.com/Aleksao998/LightningUserVault/core/webhook/webhooktest"
 	"github.com/cockroachdb/pebble"
 	"github.com/stretchr/testify/assert"
 	"go.uber.org/zap"
 )
 
 func createPebbleStorage() (string, *Storage, error) {
+	return createPebbleStorageWithOptions(Options{})
+}
+
+func createPebbleStorageWithOptions(options Options) (string, *Storage, error) {
 	// Create a temporary directory for Pebble storage
 	tempDir, err := os.MkdirTemp("", "pebble-test")
 	if err != nil {
@@ -19,7 +30,7 @@ func createPebbleStorage() (string, *Storage, error) {
 	}
 
 	// Initialize a new Pebble storage instance
-	store, err := NewStorage(tempDir, zap.NewNop())
+	store, err := NewStorage(tempDir, zap.NewNop(), options)
 	if err != nil {
 		os.RemoveAll(tempDir)
 
@@ -29,6 +40,16 @@ func createPebbleStorage() (string, *Storage, error) {
 	return tempDir, store, nil
 }
 
+// newUsers creates users with the given names
+func newUsers(names ...string) []*common.User {
+	users := make([]*common.User, 0, len(names))
+	for _, name := range names {
+		users = append(users, &common.User{Name: name})
+	}
+
+	return users
+}
+
 // TestPebbleStorageGet_NonExistentKey tests getting value with non-existent key
 func TestPebbleStorageGet_NonExistentKey(t *testing.T) {
 	tempDir, store, err := createPebbleStorage()
@@ -43,9 +64,11 @@ func TestPebbleStorageGet_NonExistentKey(t *testing.T) {
 	nonExistentKey := int64(1)
 
 	_, err = store.Get(nonExistentKey)
-	if !assert.ErrorIs(t, err, pebble.ErrNotFound) {
+	if !assert.ErrorIs(t, err, common.ErrUserNotFound) {
 		t.Errorf("Expected error not found when getting non-existent key")
 	}
+
+	assert.Equal(t, common.ErrUserNotFound.Error(), err.Error())
 }
 
 // TestPabbleStorage_WriteParallel tests writing in storage parallel
@@ -68,7 +91,7 @@ func TestPabbleStorage_WriteParallel(t *testing.T) {
 				wg.Done()
 			}()
 
-			_, err := store.Set(fmt.Sprintf("user-%d", i))
+			_, err := store.Set(&common.User{Name: fmt.Sprintf("user-%d", i)})
 			assert.Nil(t, err)
 		}(i)
 	}
@@ -102,7 +125,7 @@ func TestPebbleStorage_WriteRead(t *testing.T) {
 		key := int64(i)
 		value := fmt.Sprintf("user_%d", i)
 
-		id, err := store.Set(value)
+		id, err := store.Set(&common.User{Name: value})
 		if err != nil {
 			t.Fatalf("Error setting value for key '%d': %v", key, err)
 		}
@@ -116,3 +139,973 @@ func TestPebbleStorage_WriteRead(t *testing.T) {
 		assert.Equal(t, key, retrievedValue.ID)
 	}
 }
+
+// TestPebbleStorage_Update tests updating an existing value in storage
+func TestPebbleStorage_Update(t *testing.T) {
+	tempDir, store, err := createPebbleStorage()
+	if err != nil {
+		t.Fatalf("error creating pabble storage, %v", err)
+	}
+
+	defer os.RemoveAll(tempDir)
+	defer store.Close()
+
+	id, err := store.Set(&common.User{Name: "user_1"})
+	if err != nil {
+		t.Fatalf("Error setting value: %v", err)
+	}
+
+	_, err = store.Update(&common.User{ID: id, Name: "user_1_updated"}, common.AnyVersion)
+	assert.NoError(t, err)
+
+	retrievedValue, err := store.Get(id)
+	if err != nil {
+		t.Fatalf("Error getting value for key '%d': %v", id, err)
+	}
+
+	assert.Equal(t, id, retrievedValue.ID)
+	assert.Equal(t, "user_1_updated", retrievedValue.Name)
+}
+
+// TestPebbleStorageUpdate_NonExistentKey tests updating value with non-existent key
+func TestPebbleStorageUpdate_NonExistentKey(t *testing.T) {
+	tempDir, store, err := createPebbleStorage()
+	if err != nil {
+		t.Fatalf("error creating pabble storage, %v", err)
+	}
+
+	defer os.RemoveAll(tempDir)
+	defer store.Close()
+
+	_, err = store.Update(&common.User{ID: int64(1), Name: "user_1"}, common.AnyVersion)
+	assert.ErrorIs(t, err, common.ErrUserNotFound)
+
+	// Make sure the update did not create the user
+	_, err = store.Get(int64(1))
+	assert.ErrorIs(t, err, common.ErrUserNotFound)
+}
+
+// TestPebbleStorage_Delete tests deleting an existing value from storage
+func TestPebbleStorage_Delete(t *testing.T) {
+	tempDir, store, err := createPebbleStorage()
+	if err != nil {
+		t.Fatalf("error creating pabble storage, %v", err)
+	}
+
+	defer os.RemoveAll(tempDir)
+	defer store.Close()
+
+	id, err := store.Set(&common.User{Name: "user_1"})
+	if err != nil {
+		t.Fatalf("Error setting value: %v", err)
+	}
+
+	_, err = store.Delete(id, common.AnyVersion)
+	assert.NoError(t, err)
+
+	_, err = store.Get(id)
+	assert.ErrorIs(t, err, common.ErrUserNotFound)
+
+	// Deleting the same user twice should report it as missing
+	_, err = store.Delete(id, common.AnyVersion)
+	assert.ErrorIs(t, err, common.ErrUserNotFound)
+
+	// IDs of deleted users are never reused
+	newID, err := store.Set(&common.User{Name: "user_2"})
+	assert.NoError(t, err)
+	assert.Equal(t, id+1, newID)
+}
+
+// TestPebbleStorageDelete_NonExistentKey tests deleting value with non-existent key
+func TestPebbleStorageDelete_NonExistentKey(t *testing.T) {
+	tempDir, store, err := createPebbleStorage()
+	if err != nil {
+		t.Fatalf("error creating pabble storage, %v", err)
+	}
+
+	defer os.RemoveAll(tempDir)
+	defer store.Close()
+
+	_, err = store.Delete(int64(1), common.AnyVersion)
+	assert.ErrorIs(t, err, common.ErrUserNotFound)
+}
+
+// TestPebbleStorage_List tests iterating over all users page by page
+func TestPebbleStorage_List(t *testing.T) {
+	tempDir, store, err := createPebbleStorage()
+	if err != nil {
+		t.Fatalf("error creating pabble storage, %v", err)
+	}
+
+	defer os.RemoveAll(tempDir)
+	defer store.Close()
+
+	for i := 1; i <= 25; i++ {
+		_, err := store.Set(&common.User{Name: fmt.Sprintf("user_%d", i)})
+		if err != nil {
+			t.Fatalf("Error setting value: %v", err)
+		}
+	}
+
+	// Persist nextID so the internal key is present in the database
+	assert.NoError(t, store.saveNextID())
+
+	seen := make(map[int64]string)
+	cursor := ""
+	pages := 0
+
+	for {
+		users, nextCursor, err := store.List(cursor, 10)
+		assert.NoError(t, err)
+
+		pages++
+
+		for _, user := range users {
+			seen[user.ID] = user.Name
+		}
+
+		if nextCursor == "" {
+			break
+		}
+
+		cursor = nextCursor
+	}
+
+	assert.Equal(t, 3, pages)
+	assert.Len(t, seen, 25)
+
+	for i := 1; i <= 25; i++ {
+		assert.Equal(t, fmt.Sprintf("user_%d", i), seen[int64(i)])
+	}
+}
+
+// TestPebbleStorageList_InvalidCursor tests listing users with a cursor which was not issued by storage
+func TestPebbleStorageList_InvalidCursor(t *testing.T) {
+	tempDir, store, err := createPebbleStorage()
+	if err != nil {
+		t.Fatalf("error creating pabble storage, %v", err)
+	}
+
+	defer os.RemoveAll(tempDir)
+	defer store.Close()
+
+	_, _, err = store.List(common.EncodeCursor([]byte("invalid")), 10)
+	assert.ErrorIs(t, err, common.ErrInvalidCursor)
+}
+
+// TestPebbleStorageList_OrderedByID tests that users are listed in ID order also once IDs no longer fit in a single byte
+func TestPebbleStorageList_OrderedByID(t *testing.T) {
+	tempDir, store, err := createPebbleStorage()
+	if err != nil {
+		t.Fatalf("error creating pabble storage, %v", err)
+	}
+
+	defer os.RemoveAll(tempDir)
+	defer store.Close()
+
+	names := make([]string, 300)
+	for i := range names {
+		names[i] = fmt.Sprintf("user_%d", i+1)
+	}
+
+	_, err = store.SetBatch(newUsers(names...))
+	assert.NoError(t, err)
+
+	ids := make([]int64, 0, len(names))
+	cursor := ""
+
+	for {
+		users, nextCursor, err := store.List(cursor, 100)
+		assert.NoError(t, err)
+
+		for _, user := range users {
+			ids = append(ids, user.ID)
+		}
+
+		if nextCursor == "" {
+			break
+		}
+
+		cursor = nextCursor
+	}
+
+	assert.Len(t, ids, len(names))
+
+	for i, id := range ids {
+		assert.Equal(t, int64(i+1), id)
+	}
+}
+
+// TestPebbleStorage_SetBatch tests writing a batch of values and reading them from storage
+func TestPebbleStorage_SetBatch(t *testing.T) {
+	tempDir, store, err := createPebbleStorage()
+	if err != nil {
+		t.Fatalf("error creating pabble storage, %v", err)
+	}
+
+	defer os.RemoveAll(tempDir)
+	defer store.Close()
+
+	firstID, err := store.Set(&common.User{Name: "user_0"})
+	assert.NoError(t, err)
+
+	values := make([]string, 0, 100)
+	for i := 1; i <= 100; i++ {
+		values = append(values, fmt.Sprintf("user_%d", i))
+	}
+
+	ids, err := store.SetBatch(newUsers(values...))
+	assert.NoError(t, err)
+	assert.Len(t, ids, len(values))
+
+	for i, id := range ids {
+		assert.Equal(t, firstID+int64(i)+1, id)
+
+		retrievedValue, err := store.Get(id)
+		if err != nil {
+			t.Fatalf("Error getting value for key '%d': %v", id, err)
+		}
+
+		assert.Equal(t, values[i], retrievedValue.Name)
+	}
+
+	// IDs allocated after the batch continue the sequence
+	lastID, err := store.Set(&common.User{Name: "user_101"})
+	assert.NoError(t, err)
+	assert.Equal(t, ids[len(ids)-1]+1, lastID)
+}
+
+// TestPebbleStorage_GetMulti tests reading multiple values at once, including non-existent keys
+func TestPebbleStorage_GetMulti(t *testing.T) {
+	tempDir, store, err := createPebbleStorage()
+	if err != nil {
+		t.Fatalf("error creating pabble storage, %v", err)
+	}
+
+	defer os.RemoveAll(tempDir)
+	defer store.Close()
+
+	ids, err := store.SetBatch(newUsers("user_1", "user_2", "user_3"))
+	assert.NoError(t, err)
+
+	users, err := store.GetMulti([]int64{ids[0], ids[2], 100})
+	assert.NoError(t, err)
+	assert.Len(t, users, 2)
+	assert.Equal(t, "user_1", users[ids[0]].Name)
+	assert.Equal(t, "user_3", users[ids[2]].Name)
+	assert.NotContains(t, users, int64(100))
+}
+
+// TestPebbleStorage_FindByName tests looking up users through the name index while users are modified
+func TestPebbleStorage_FindByName(t *testing.T) {
+	tempDir, store, err := createPebbleStorage()
+	if err != nil {
+		t.Fatalf("error creating pabble storage, %v", err)
+	}
+
+	defer os.RemoveAll(tempDir)
+	defer store.Close()
+
+	ids, err := store.SetBatch(newUsers("alice", "alicia", "bob"))
+	assert.NoError(t, err)
+
+	aliceID, err := store.Set(&common.User{Name: "alice"})
+	assert.NoError(t, err)
+
+	// Exact match returns only users with exactly the same name
+	users, err := store.FindByName("alice", false)
+	assert.NoError(t, err)
+	assert.Len(t, users, 2)
+	assert.Equal(t, ids[0], users[0].ID)
+	assert.Equal(t, aliceID, users[1].ID)
+
+	// Prefix match returns all users with names starting with the query
+	users, err = store.FindByName("ali", true)
+	assert.NoError(t, err)
+	assert.Len(t, users, 3)
+
+	// Updated and deleted users are removed from the index
+	_, err = store.Update(&common.User{ID: ids[0], Name: "carol"}, common.AnyVersion)
+	assert.NoError(t, err)
+	_, err = store.Delete(aliceID, common.AnyVersion)
+	assert.NoError(t, err)
+
+	users, err = store.FindByName("alice", false)
+	assert.NoError(t, err)
+	assert.Empty(t, users)
+
+	users, err = store.FindByName("carol", false)
+	assert.NoError(t, err)
+	assert.Len(t, users, 1)
+	assert.Equal(t, ids[0], users[0].ID)
+
+	// Index entries are not returned as users by list
+	listed, _, err := store.List("", 10)
+	assert.NoError(t, err)
+	assert.Len(t, listed, 3)
+}
+
+// TestPebbleStorage_BuildNameIndex tests that users stored before the name index existed get indexed on startup
+func TestPebbleStorage_BuildNameIndex(t *testing.T) {
+	tempDir, store, err := createPebbleStorage()
+	if err != nil {
+		t.Fatalf("error creating pabble storage, %v", err)
+	}
+
+	defer os.RemoveAll(tempDir)
+
+	// Simulate a database written by a version without the name index
+	assert.NoError(t, store.db.Set(common.Int64ToBytes(1), []byte("alice"), pebble.Sync))
+	assert.NoError(t, store.db.Delete([]byte(nameIndexReadyKey), pebble.Sync))
+	assert.NoError(t, store.Close())
+
+	store, err = NewStorage(tempDir, zap.NewNop(), Options{})
+	if err != nil {
+		t.Fatalf("error reopening pabble storage, %v", err)
+	}
+
+	defer store.Close()
+
+	users, err := store.FindByName("alice", false)
+	assert.NoError(t, err)
+	assert.Len(t, users, 1)
+	assert.Equal(t, int64(1), users[0].ID)
+}
+
+// TestPebbleStorage_BuildIDIndex tests that users stored before the ID index existed get listed after startup
+func TestPebbleStorage_BuildIDIndex(t *testing.T) {
+	tempDir, store, err := createPebbleStorage()
+	if err != nil {
+		t.Fatalf("error creating pabble storage, %v", err)
+	}
+
+	defer os.RemoveAll(tempDir)
+
+	// Simulate a database written by a version without the ID index
+	assert.NoError(t, store.db.Set(common.Int64ToBytes(256), []byte("alice"), pebble.Sync))
+	assert.NoError(t, store.db.Set(common.Int64ToBytes(2), []byte("bob"), pebble.Sync))
+	assert.NoError(t, store.db.Delete([]byte(idIndexReadyKey), pebble.Sync))
+	assert.NoError(t, store.Close())
+
+	store, err = NewStorage(tempDir, zap.NewNop(), Options{})
+	if err != nil {
+		t.Fatalf("error reopening pabble storage, %v", err)
+	}
+
+	defer store.Close()
+
+	users, _, err := store.List("", 10)
+	assert.NoError(t, err)
+	assert.Len(t, users, 2)
+	assert.Equal(t, int64(2), users[0].ID)
+	assert.Equal(t, int64(256), users[1].ID)
+}
+
+// TestPebbleStorage_UserFields tests that every user field is persisted and timestamps are maintained
+func TestPebbleStorage_UserFields(t *testing.T) {
+	tempDir, store, err := createPebbleStorage()
+	if err != nil {
+		t.Fatalf("error creating pabble storage, %v", err)
+	}
+
+	defer os.RemoveAll(tempDir)
+	defer store.Close()
+
+	user := &common.User{
+		Name:       "alice",
+		Email:      "alice@example.com",
+		Attributes: map[string]string{"team": "core"},
+	}
+
+	id, err := store.Set(user)
+	assert.NoError(t, err)
+	assert.Equal(t, id, user.ID)
+	assert.False(t, user.CreatedAt.IsZero())
+	assert.Equal(t, user.CreatedAt, user.UpdatedAt)
+
+	retrieved, err := store.Get(id)
+	assert.NoError(t, err)
+	assert.Equal(t, "alice@example.com", retrieved.Email)
+	assert.Equal(t, map[string]string{"team": "core"}, retrieved.Attributes)
+	assert.True(t, user.CreatedAt.Equal(retrieved.CreatedAt))
+
+	// Update replaces the fields and keeps the creation time
+	updated := &common.User{ID: id, Name: "alice"}
+	_, err = store.Update(updated, common.AnyVersion)
+	assert.NoError(t, err)
+	assert.True(t, user.CreatedAt.Equal(updated.CreatedAt))
+	assert.True(t, updated.UpdatedAt.After(user.UpdatedAt))
+
+	retrieved, err = store.Get(id)
+	assert.NoError(t, err)
+	assert.Empty(t, retrieved.Email)
+	assert.Empty(t, retrieved.Attributes)
+	assert.True(t, updated.UpdatedAt.Equal(retrieved.UpdatedAt))
+}
+
+// TestPebbleStorage_LegacyValues tests that raw name values written before the versioned encoding are still readable
+func TestPebbleStorage_LegacyValues(t *testing.T) {
+	tempDir, store, err := createPebbleStorage()
+	if err != nil {
+		t.Fatalf("error creating pabble storage, %v", err)
+	}
+
+	defer os.RemoveAll(tempDir)
+	defer store.Close()
+
+	// Simulate users written by a version storing raw names
+	assert.NoError(t, store.db.Set(common.Int64ToBytes(1), []byte("alice"), pebble.Sync))
+	assert.NoError(t, store.db.Set(common.Int64ToBytes(2), []byte("bob"), pebble.Sync))
+	assert.NoError(t, store.db.Set(nameIndexKey("alice", 1), nil, pebble.Sync))
+	assert.NoError(t, store.db.Set(idIndexKey(1), nil, pebble.Sync))
+	assert.NoError(t, store.db.Set(idIndexKey(2), nil, pebble.Sync))
+	store.nextID = 2
+
+	retrieved, err := store.Get(1)
+	assert.NoError(t, err)
+	assert.Equal(t, &common.User{ID: 1, Name: "alice", Version: 1}, retrieved)
+
+	users, _, err := store.List("", 10)
+	assert.NoError(t, err)
+	assert.Len(t, users, 2)
+	assert.Equal(t, "bob", users[1].Name)
+
+	// Updating a legacy user rewrites it in the current encoding
+	_, err = store.Update(&common.User{ID: 1, Name: "alice", Email: "alice@example.com"}, common.AnyVersion)
+	assert.NoError(t, err)
+
+	value, closer, err := store.db.Get(common.Int64ToBytes(1))
+	assert.NoError(t, err)
+	assert.Equal(t, encodingMarker, value[0])
+	closer.Close()
+
+	found, err := store.FindByName("alice", false)
+	assert.NoError(t, err)
+	assert.Len(t, found, 1)
+	assert.Equal(t, "alice@example.com", found[0].Email)
+
+	// Values in an unknown encoding are reported instead of being misread
+	assert.NoError(t, store.db.Set(common.Int64ToBytes(2), []byte{encodingMarker, 99}, pebble.Sync))
+
+	_, err = store.Get(2)
+	assert.ErrorIs(t, err, errUnknownEncoding)
+}
+
+// TestPebbleStorage_Versions tests that versions are incremented on update and checked by conditional writes
+func TestPebbleStorage_Versions(t *testing.T) {
+	tempDir, store, err := createPebbleStorage()
+	if err != nil {
+		t.Fatalf("error creating pabble storage, %v", err)
+	}
+
+	defer os.RemoveAll(tempDir)
+	defer store.Close()
+
+	user := &common.User{Name: "alice"}
+
+	id, err := store.Set(user)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), user.Version)
+
+	// Conditional update at the current version
+	updated := &common.User{ID: id, Name: "alicia"}
+	_, err = store.Update(updated, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), updated.Version)
+
+	// Stale version is rejected and nothing is written
+	_, err = store.Update(&common.User{ID: id, Name: "bob"}, 1)
+	assert.ErrorIs(t, err, common.ErrVersionMismatch)
+	_, err = store.Delete(id, 1)
+	assert.ErrorIs(t, err, common.ErrVersionMismatch)
+
+	retrieved, err := store.Get(id)
+	assert.NoError(t, err)
+	assert.Equal(t, "alicia", retrieved.Name)
+	assert.Equal(t, int64(2), retrieved.Version)
+
+	// Unconditional update still increments the version
+	_, err = store.Update(&common.User{ID: id, Name: "carol"}, common.AnyVersion)
+	assert.NoError(t, err)
+
+	retrieved, err = store.Get(id)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), retrieved.Version)
+
+	_, err = store.Delete(id, 3)
+	assert.NoError(t, err)
+	_, err = store.Delete(id, 3)
+	assert.ErrorIs(t, err, common.ErrUserNotFound)
+}
+
+// TestPebbleStorage_ConcurrentConditionalUpdates tests that only one of concurrent writers at the same version succeeds
+func TestPebbleStorage_ConcurrentConditionalUpdates(t *testing.T) {
+	tempDir, store, err := createPebbleStorage()
+	if err != nil {
+		t.Fatalf("error creating pabble storage, %v", err)
+	}
+
+	defer os.RemoveAll(tempDir)
+	defer store.Close()
+
+	id, err := store.Set(&common.User{Name: "alice"})
+	assert.NoError(t, err)
+
+	var (
+		wg        sync.WaitGroup
+		succeeded int32
+	)
+
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			if _, err := store.Update(&common.User{ID: id, Name: fmt.Sprintf("user-%d", i)}, 1); err == nil {
+				atomic.AddInt32(&succeeded, 1)
+			} else {
+				assert.ErrorIs(t, err, common.ErrVersionMismatch)
+			}
+		}(i)
+	}
+
+	wg.Wait()
+
+	assert.Equal(t, int32(1), succeeded)
+}
+
+// TestPebbleStorage_IgnoreDeletionMark tests that users are only marked as deleted by SoftDelete,
+// so a user is never hidden without a deleted index entry the purger could find it by
+func TestPebbleStorage_IgnoreDeletionMark(t *testing.T) {
+	tempDir, store, err := createPebbleStorage()
+	if err != nil {
+		t.Fatalf("error creating pabble storage, %v", err)
+	}
+
+	defer os.RemoveAll(tempDir)
+	defer store.Close()
+
+	deletedAt := time.Now().UTC()
+
+	id, err := store.Set(&common.User{Name: "alice", DeletedAt: &deletedAt})
+	assert.NoError(t, err)
+
+	ids, err := store.SetBatch([]*common.User{{Name: "bob", DeletedAt: &deletedAt}})
+	assert.NoError(t, err)
+
+	_, err = store.Update(&common.User{ID: ids[0], Name: "carol", DeletedAt: &deletedAt}, common.AnyVersion)
+	assert.NoError(t, err)
+
+	users, err := store.GetMulti([]int64{id, ids[0]})
+	assert.NoError(t, err)
+	assert.Len(t, users, 2)
+
+	found, err := store.FindByName("carol", false)
+	assert.NoError(t, err)
+	assert.Len(t, found, 1)
+}
+
+// TestPebbleStorage_SoftDelete tests that soft deleted users are hidden until restored
+func TestPebbleStorage_SoftDelete(t *testing.T) {
+	tempDir, store, err := createPebbleStorage()
+	if err != nil {
+		t.Fatalf("error creating pabble storage, %v", err)
+	}
+
+	defer os.RemoveAll(tempDir)
+	defer store.Close()
+
+	ids, err := store.SetBatch(newUsers("alice", "bob"))
+	assert.NoError(t, err)
+
+	_, err = store.SoftDelete(ids[0], 2)
+	assert.ErrorIs(t, err, common.ErrVersionMismatch)
+	replaced, err := store.SoftDelete(ids[0], 1)
+	assert.NoError(t, err)
+	assert.Equal(t, "alice", replaced.Name)
+	assert.Equal(t, int64(1), replaced.Version)
+	assert.Nil(t, replaced.DeletedAt)
+	_, err = store.SoftDelete(ids[0], common.AnyVersion)
+	assert.ErrorIs(t, err, common.ErrUserDeleted)
+	_, err = store.SoftDelete(int64(100), common.AnyVersion)
+	assert.ErrorIs(t, err, common.ErrUserNotFound)
+
+	// The deleted user is hidden from every read
+	_, err = store.Get(ids[0])
+	assert.ErrorIs(t, err, common.ErrUserDeleted)
+
+	users, err := store.GetMulti(ids)
+	assert.NoError(t, err)
+	assert.Len(t, users, 1)
+
+	found, err := store.FindByName("alice", false)
+	assert.NoError(t, err)
+	assert.Empty(t, found)
+
+	listed, _, err := store.List("", 1)
+	assert.NoError(t, err)
+	assert.Len(t, listed, 1)
+	assert.Equal(t, "bob", listed[0].Name)
+
+	_, err = store.Update(&common.User{ID: ids[0], Name: "carol"}, common.AnyVersion)
+	assert.ErrorIs(t, err, common.ErrUserDeleted)
+
+	// Restoring brings the user back at a new version
+	_, _, err = store.Restore(ids[1])
+	assert.ErrorIs(t, err, common.ErrUserNotDeleted)
+
+	_, _, err = store.Restore(int64(100))
+	assert.ErrorIs(t, err, common.ErrUserNotFound)
+
+	restored, before, err := store.Restore(ids[0])
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), restored.Version)
+	assert.Equal(t, int64(2), before.Version)
+	assert.NotNil(t, before.DeletedAt)
+	assert.Nil(t, restored.DeletedAt)
+
+	retrieved, err := store.Get(ids[0])
+	assert.NoError(t, err)
+	assert.Equal(t, restored, retrieved)
+
+	found, err = store.FindByName("alice", false)
+	assert.NoError(t, err)
+	assert.Len(t, found, 1)
+
+	// A restored user is not purged
+	purged, err := store.Purge(time.Now().Add(time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, purged)
+}
+
+// TestPebbleStorage_DeleteSoftDeleted tests that permanently removing a soft deleted user returns it as it was stored
+func TestPebbleStorage_DeleteSoftDeleted(t *testing.T) {
+	tempDir, store, err := createPebbleStorage()
+	if err != nil {
+		t.Fatalf("error creating pabble storage, %v", err)
+	}
+
+	defer os.RemoveAll(tempDir)
+	defer store.Close()
+
+	id, err := store.Set(&common.User{Name: "alice"})
+	assert.NoError(t, err)
+
+	_, err = store.SoftDelete(id, common.AnyVersion)
+	assert.NoError(t, err)
+
+	removed, err := store.Delete(id, common.AnyVersion)
+	assert.NoError(t, err)
+	assert.Equal(t, "alice", removed.Name)
+	assert.Equal(t, int64(2), removed.Version)
+	assert.NotNil(t, removed.DeletedAt)
+}
+
+// TestPebbleStorage_Purge tests that only users deleted before the given time are permanently removed
+func TestPebbleStorage_Purge(t *testing.T) {
+	tempDir, store, err := createPebbleStorage()
+	if err != nil {
+		t.Fatalf("error creating pabble storage, %v", err)
+	}
+
+	defer os.RemoveAll(tempDir)
+	defer store.Close()
+
+	ids, err := store.SetBatch(newUsers("alice", "bob", "carol"))
+	assert.NoError(t, err)
+
+	_, err = store.SoftDelete(ids[0], common.AnyVersion)
+	assert.NoError(t, err)
+	_, err = store.SoftDelete(ids[1], common.AnyVersion)
+	assert.NoError(t, err)
+
+	before := time.Now()
+
+	_, err = store.SoftDelete(ids[2], common.AnyVersion)
+	assert.NoError(t, err)
+
+	purged, err := store.Purge(before)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, purged)
+
+	// Purged users are gone, including their index entries
+	_, _, err = store.Restore(ids[0])
+	assert.ErrorIs(t, err, common.ErrUserNotFound)
+
+	iter, err := store.db.NewIter(&pebble.IterOptions{LowerBound: nameIndexPrefix, UpperBound: prefixUpperBound(nameIndexPrefix)})
+	assert.NoError(t, err)
+
+	entries := 0
+	for valid := iter.First(); valid; valid = iter.Next() {
+		entries++
+	}
+
+	assert.NoError(t, iter.Close())
+	assert.Equal(t, 1, entries)
+
+	// The user deleted later is kept until its own retention passes
+	_, err = store.Get(ids[2])
+	assert.ErrorIs(t, err, common.ErrUserDeleted)
+
+	purged, err = store.Purge(time.Now().Add(time.Second))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, purged)
+
+	_, err = store.Delete(ids[2], common.AnyVersion)
+	assert.ErrorIs(t, err, common.ErrUserNotFound)
+}
+
+// TestPebbleStorage_GetAsOf tests that reads at a point in time return the version written at or before it
+func TestPebbleStorage_GetAsOf(t *testing.T) {
+	tempDir, store, err := createPebbleStorageWithOptions(Options{KeepVersions: true})
+	if err != nil {
+		t.Fatalf("error creating pabble storage, %v", err)
+	}
+
+	defer os.RemoveAll(tempDir)
+	defer store.Close()
+
+	beforeCreate := time.Now().UTC().Add(-time.Second)
+
+	id, err := store.Set(&common.User{Name: "alice"})
+	assert.NoError(t, err)
+
+	created, err := store.Get(id)
+	assert.NoError(t, err)
+
+	_, err = store.Update(&common.User{ID: id, Name: "bob"}, common.AnyVersion)
+	assert.NoError(t, err)
+	_, err = store.SoftDelete(id, common.AnyVersion)
+	assert.NoError(t, err)
+
+	deleted, err := store.Versions(id)
+	assert.NoError(t, err)
+	assert.Len(t, deleted, 3)
+
+	_, err = store.GetAsOf(id, beforeCreate)
+	assert.ErrorIs(t, err, common.ErrUserNotFound)
+
+	user, err := store.GetAsOf(id, created.UpdatedAt)
+	assert.NoError(t, err)
+	assert.Equal(t, created, user)
+
+	user, err = store.GetAsOf(id, deleted[1].UpdatedAt)
+	assert.NoError(t, err)
+	assert.Equal(t, "bob", user.Name)
+	assert.Equal(t, int64(2), user.Version)
+
+	_, err = store.GetAsOf(id, time.Now())
+	assert.ErrorIs(t, err, common.ErrUserDeleted)
+
+	_, err = store.GetAsOf(int64(100), time.Now())
+	assert.ErrorIs(t, err, common.ErrUserNotFound)
+
+	// Permanently deleting the user removes its versions
+	_, err = store.Delete(id, common.AnyVersion)
+	assert.NoError(t, err)
+
+	_, err = store.Versions(id)
+	assert.ErrorIs(t, err, common.ErrUserNotFound)
+
+	iter, err := store.db.NewIter(&pebble.IterOptions{LowerBound: versionPrefix, UpperBound: prefixUpperBound(versionPrefix)})
+	assert.NoError(t, err)
+	assert.False(t, iter.First())
+	assert.NoError(t, iter.Close())
+}
+
+// TestPebbleStorage_VersionsDisabled tests that only the current record is available when versions are not kept
+func TestPebbleStorage_VersionsDisabled(t *testing.T) {
+	tempDir, store, err := createPebbleStorage()
+	if err != nil {
+		t.Fatalf("error creating pabble storage, %v", err)
+	}
+
+	defer os.RemoveAll(tempDir)
+	defer store.Close()
+
+	id, err := store.Set(&common.User{Name: "alice"})
+	assert.NoError(t, err)
+
+	created, err := store.Get(id)
+	assert.NoError(t, err)
+
+	_, err = store.Update(&common.User{ID: id, Name: "bob"}, common.AnyVersion)
+	assert.NoError(t, err)
+
+	versions, err := store.Versions(id)
+	assert.NoError(t, err)
+	assert.Len(t, versions, 1)
+	assert.Equal(t, "bob", versions[0].Name)
+
+	_, err = store.GetAsOf(id, created.UpdatedAt)
+	assert.ErrorIs(t, err, common.ErrUserNotFound)
+
+	user, err := store.GetAsOf(id, time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, versions[0], user)
+}
+
+// TestPebbleStorage_CompactVersions tests that only versions replaced before the given time are removed
+func TestPebbleStorage_CompactVersions(t *testing.T) {
+	tempDir, store, err := createPebbleStorageWithOptions(Options{KeepVersions: true})
+	if err != nil {
+		t.Fatalf("error creating pabble storage, %v", err)
+	}
+
+	defer os.RemoveAll(tempDir)
+	defer store.Close()
+
+	ids, err := store.SetBatch(newUsers("alice", "bob"))
+	assert.NoError(t, err)
+
+	_, err = store.Update(&common.User{ID: ids[0], Name: "carol"}, common.AnyVersion)
+	assert.NoError(t, err)
+	_, err = store.Update(&common.User{ID: ids[1], Name: "dave"}, common.AnyVersion)
+	assert.NoError(t, err)
+
+	before := time.Now().UTC()
+
+	_, err = store.Update(&common.User{ID: ids[0], Name: "erin"}, common.AnyVersion)
+	assert.NoError(t, err)
+
+	// The first version of both users was replaced before the given time, the second version of alice afterwards
+	compacted, err := store.CompactVersions(before)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, compacted)
+
+	versions, err := store.Versions(ids[0])
+	assert.NoError(t, err)
+	assert.Len(t, versions, 2)
+	assert.Equal(t, "erin", versions[0].Name)
+	assert.Equal(t, "carol", versions[1].Name)
+
+	// Reads at or after the given time are still answered
+	user, err := store.GetAsOf(ids[0], before)
+	assert.NoError(t, err)
+	assert.Equal(t, "carol", user.Name)
+
+	versions, err = store.Versions(ids[1])
+	assert.NoError(t, err)
+	assert.Len(t, versions, 1)
+
+	compacted, err = store.CompactVersions(before)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, compacted)
+
+	// Version keys are never listed as users
+	users, _, err := store.List("", 10)
+	assert.NoError(t, err)
+	assert.Len(t, users, 2)
+}
+
+func TestPebbleStorage_WebhookConformance(t *testing.T) {
+	webhooktest.RunConformance(t, func(t *testing.T) webhook.Store {
+		t.Helper()
+
+		tempDir, store, err := createPebbleStorage()
+		if err != nil {
+			t.Fatalf("error creating pabble storage, %v", err)
+		}
+
+		t.Cleanup(func() {
+			store.Close()
+			os.RemoveAll(tempDir)
+		})
+
+		return store
+	})
+}
+
+func TestPebbleStorage_WebhookKeysNotListed(t *testing.T) {
+	tempDir, store, err := createPebbleStorage()
+	if err != nil {
+		t.Fatalf("error creating pabble storage, %v", err)
+	}
+
+	defer os.RemoveAll(tempDir)
+
+	_, err = store.Set(&common.User{Name: "alice"})
+	assert.NoError(t, err)
+
+	assert.NoError(t, store.AddWebhook(&webhook.Webhook{URL: "http://localhost"}))
+	assert.NoError(t, store.EnqueueDeliveries(1, []*webhook.Delivery{{WebhookID: 1, Status: webhook.Pending}}))
+
+	// Webhook keys are never listed as users, and do not survive as users after a restart
+	users, _, err := store.List("", 10)
+	assert.NoError(t, err)
+	assert.Len(t, users, 1)
+
+	assert.NoError(t, store.Close())
+
+	store, err = NewStorage(tempDir, zap.NewNop(), Options{})
+	if err != nil {
+		t.Fatalf("error reopening pabble storage, %v", err)
+	}
+
+	defer store.Close()
+
+	users, _, err = store.List("", 10)
+	assert.NoError(t, err)
+	assert.Len(t, users, 1)
+
+	webhooks, err := store.ListWebhooks()
+	assert.NoError(t, err)
+	assert.Len(t, webhooks, 1)
+}
+
+func TestPebbleStorage_AuditConformance(t *testing.T) {
+	audittest.RunConformance(t, func(t *testing.T) audit.Store {
+		t.Helper()
+
+		tempDir, store, err := createPebbleStorage()
+		if err != nil {
+			t.Fatalf("error creating pabble storage, %v", err)
+		}
+
+		t.Cleanup(func() {
+			store.Close()
+			os.RemoveAll(tempDir)
+		})
+
+		return store
+	})
+}
+
+func TestPebbleStorage_AuditReopen(t *testing.T) {
+	tempDir, store, err := createPebbleStorage()
+	if err != nil {
+		t.Fatalf("error creating pabble storage, %v", err)
+	}
+
+	defer os.RemoveAll(tempDir)
+
+	_, err = store.Set(&common.User{Name: "alice"})
+	assert.NoError(t, err)
+
+	first := &audit.Entry{Operation: audit.Create, UserID: 1}
+	assert.NoError(t, store.RecordAudit(first))
+	assert.NoError(t, store.Close())
+
+	store, err = NewStorage(tempDir, zap.NewNop(), Options{})
+	if err != nil {
+		t.Fatalf("error reopening pabble storage, %v", err)
+	}
+
+	defer store.Close()
+
+	// IDs and times continue after the last entry recorded before the restart
+	second := &audit.Entry{Operation: audit.Delete, UserID: 1}
+	assert.NoError(t, store.RecordAudit(second))
+	assert.Equal(t, first.ID+1, second.ID)
+	assert.True(t, second.Time.After(first.Time))
+
+	history, err := store.AuditHistory(1, 10)
+	assert.NoError(t, err)
+	assert.Len(t, history, 2)
+
+	// Audit keys are never listed as users
+	users, _, err := store.List("", 10)
+	assert.NoError(t, err)
+	assert.Len(t, users, 1)
+}
@@ -0,0 +1,248 @@
+package pebble
+
+import (
+	"encoding/binary"
+	"errors"
+	"time"
+
+	"github.com/Aleksao998/LightningUserVault/core/common"
+	"github.com/cockroachdb/pebble"
+	"go.uber.org/zap"
+)
+
+// purgeBatchSize is the number of users removed in a single batch while purging
+const purgeBatchSize = 1000
+
+// deletedIndexPrefix is the namespace of the index of soft deleted users ordered by deletion time.
+// Index keys are built as deletedIndexPrefix + big endian deletion time in nanoseconds + big endian ID and have an empty value
+var deletedIndexPrefix = []byte("__deletedidx__")
+
+// deletedIndexKey returns the index key of the given user deleted at the given time
+func deletedIndexKey(deletedAt time.Time, id int64) []byte {
+	key := make([]byte, 0, len(deletedIndexPrefix)+2*userKeyLength)
+	key = append(key, deletedIndexPrefix...)
+	key = binary.BigEndian.AppendUint64(key, uint64(deletedAt.UnixNano()))
+
+	return binary.BigEndian.AppendUint64(key, uint64(id))
+}
+
+// parseDeletedIndexKey extracts the user ID from a deleted index key
+func parseDeletedIndexKey(key []byte) int64 {
+	return int64(binary.BigEndian.Uint64(key[len(key)-userKeyLength:]))
+}
+
+// SoftDelete marks an existing user as deleted and increments its version, the record is kept until it is restored or purged.
+// It returns the user as it was before, or an error if the key does not exist, is not at the given version or is already deleted
+func (p *Storage) SoftDelete(key int64, version int64) (*common.User, error) {
+	p.writeLock.Lock()
+	defer p.writeLock.Unlock()
+
+	user, err := p.getExistingVersion(key, version)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.DeletedAt != nil {
+		p.logger.Warn("User to delete is already deleted", zap.Int64("key", key))
+
+		return nil, common.ErrUserDeleted
+	}
+
+	before := *user
+
+	deletedAt := time.Now().UTC()
+	user.DeletedAt = &deletedAt
+	user.UpdatedAt = deletedAt
+	user.Version++
+
+	batch := p.db.NewBatch()
+	defer batch.Close()
+
+	value, err := encodeUser(user)
+
+	// The deletion time is indexed together with the record, so the purger does not have to scan every user
+	if err == nil {
+		err = batch.Set(common.Int64ToBytes(key), value, nil)
+	}
+
+	if err == nil {
+		err = batch.Set(deletedIndexKey(deletedAt, key), nil, nil)
+	}
+
+	if err == nil {
+		err = p.setVersion(batch, user, value)
+	}
+
+	if err == nil {
+		err = batch.Commit(pebble.Sync)
+	}
+
+	if err != nil {
+		p.logger.Error("Failed to soft delete value in database", zap.Int64("key", key), zap.Error(err))
+
+		return nil, err
+	}
+
+	p.logger.Debug("Soft deleted user in database", zap.Int64("ID", key))
+
+	return &before, nil
+}
+
+// Restore clears the deletion mark of a soft deleted user, filling its timestamps and incremented version,
+// and returns it together with the soft deleted user. It returns an error if the key does not exist or is not deleted
+func (p *Storage) Restore(key int64) (*common.User, *common.User, error) {
+	p.writeLock.Lock()
+	defer p.writeLock.Unlock()
+
+	user, err := p.getExisting(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if user.DeletedAt == nil {
+		p.logger.Warn("User to restore is not deleted", zap.Int64("key", key))
+
+		return nil, nil, common.ErrUserNotDeleted
+	}
+
+	before := *user
+	indexKey := deletedIndexKey(*user.DeletedAt, key)
+
+	user.DeletedAt = nil
+	user.UpdatedAt = time.Now().UTC()
+	user.Version++
+
+	batch := p.db.NewBatch()
+	defer batch.Close()
+
+	value, err := encodeUser(user)
+	if err == nil {
+		err = batch.Set(common.Int64ToBytes(key), value, nil)
+	}
+
+	if err == nil {
+		err = batch.Delete(indexKey, nil)
+	}
+
+	if err == nil {
+		err = p.setVersion(batch, user, value)
+	}
+
+	if err == nil {
+		err = batch.Commit(pebble.Sync)
+	}
+
+	if err != nil {
+		p.logger.Error("Failed to restore value in database", zap.Int64("key", key), zap.Error(err))
+
+		return nil, nil, err
+	}
+
+	p.logger.Debug("Restored user in database", zap.Int64("ID", key))
+
+	return user, &before, nil
+}
+
+// Purge permanently removes users soft deleted before the given time, walking the deleted index in deletion order
+func (p *Storage) Purge(before time.Time) (int, error) {
+	purged := 0
+
+	for {
+		count, err := p.purgeBatch(before)
+		purged += count
+
+		if err != nil {
+			p.logger.Error("Failed to purge deleted users", zap.Int("purged", purged), zap.Error(err))
+
+			return purged, err
+		}
+
+		if count < purgeBatchSize {
+			break
+		}
+	}
+
+	p.logger.Debug("Purged deleted users from database", zap.Time("before", before), zap.Int("count", purged))
+
+	return purged, nil
+}
+
+// purgeBatch removes up to purgeBatchSize users deleted before the given time in a single batch.
+// The write lock is only held for one batch at a time, so writers are not blocked for the whole purge
+func (p *Storage) purgeBatch(before time.Time) (int, error) {
+	p.writeLock.Lock()
+	defer p.writeLock.Unlock()
+
+	iter, err := p.db.NewIter(&pebble.IterOptions{
+		LowerBound: deletedIndexPrefix,
+		UpperBound: deletedIndexKey(before, 0),
+	})
+	if err != nil {
+		return 0, err
+	}
+	defer iter.Close()
+
+	batch := p.db.NewBatch()
+	defer batch.Close()
+
+	count := 0
+
+	for valid := iter.First(); valid && count < purgeBatchSize; valid = iter.Next() {
+		// An entry whose user is missing should never exist, if it does only the entry is removed
+		user, err := p.getExisting(parseDeletedIndexKey(iter.Key()))
+		if err != nil && !errors.Is(err, common.ErrUserNotFound) {
+			return 0, err
+		}
+
+		if err == nil {
+			if err := deleteUser(batch, user); err != nil {
+				return 0, err
+			}
+		}
+
+		if err := batch.Delete(iter.Key(), nil); err != nil {
+			return 0, err
+		}
+
+		count++
+	}
+
+	if err := iter.Error(); err != nil {
+		return 0, err
+	}
+
+	if count == 0 {
+		return 0, nil
+	}
+
+	if err := batch.Commit(pebble.Sync); err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// deleteUser adds the removal of the user record, its versions and all of its index entries to the batch
+func deleteUser(batch *pebble.Batch, user *common.User) error {
+	if err := batch.Delete(common.Int64ToBytes(user.ID), nil); err != nil {
+		return err
+	}
+
+	if err := deleteVersions(batch, user.ID); err != nil {
+		return err
+	}
+
+	if err := batch.Delete(nameIndexKey(user.Name, user.ID), nil); err != nil {
+		return err
+	}
+
+	if err := batch.Delete(idIndexKey(user.ID), nil); err != nil {
+		return err
+	}
+
+	if user.DeletedAt != nil {
+		return batch.Delete(deletedIndexKey(*user.DeletedAt, user.ID), nil)
+	}
+
+	return nil
+}
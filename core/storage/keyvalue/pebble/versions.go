@@ -0,0 +1,231 @@
+package pebble
+
+import (
+	"encoding/binary"
+	"time"
+
+	"github.com/Aleksao998/LightningUserVault/core/common"
+	"github.com/cockroachdb/pebble"
+	"go.uber.org/zap"
+)
+
+// compactBatchSize is the number of versions removed in a single batch while compacting
+const compactBatchSize = 1000
+
+// versionPrefix is the namespace of immutable user versions.
+// Version keys are built as versionPrefix + big endian ID + big endian version and hold the encoded user of that version
+var versionPrefix = []byte("__versions__")
+
+// versionKey returns the key of the given version of the user with the given ID
+func versionKey(id int64, version int64) []byte {
+	key := make([]byte, 0, len(versionPrefix)+2*userKeyLength)
+	key = append(key, versionPrefix...)
+	key = binary.BigEndian.AppendUint64(key, uint64(id))
+
+	return binary.BigEndian.AppendUint64(key, uint64(version))
+}
+
+// userVersionsPrefix returns the prefix of all version keys of the user with the given ID
+func userVersionsPrefix(id int64) []byte {
+	key := make([]byte, 0, len(versionPrefix)+userKeyLength)
+	key = append(key, versionPrefix...)
+
+	return binary.BigEndian.AppendUint64(key, uint64(id))
+}
+
+// parseVersionKey extracts the user ID from a version key
+func parseVersionKey(key []byte) int64 {
+	return int64(binary.BigEndian.Uint64(key[len(versionPrefix) : len(versionPrefix)+userKeyLength]))
+}
+
+// setVersion adds the user as its current version to the batch when versions are kept
+func (p *Storage) setVersion(batch *pebble.Batch, user *common.User, value []byte) error {
+	if !p.options.KeepVersions {
+		return nil
+	}
+
+	return batch.Set(versionKey(user.ID, user.Version), value, nil)
+}
+
+// deleteVersions adds the removal of all versions of the user to the batch
+func deleteVersions(batch *pebble.Batch, id int64) error {
+	prefix := userVersionsPrefix(id)
+
+	return batch.DeleteRange(prefix, prefixUpperBound(prefix), nil)
+}
+
+// GetAsOf returns the latest version of the user which was written at or before the given time.
+// The current record is the latest version, so it is used for users written before versions were kept
+func (p *Storage) GetAsOf(key int64, at time.Time) (*common.User, error) {
+	snapshot := p.db.NewSnapshot()
+	defer snapshot.Close()
+
+	current, err := p.getExistingFrom(snapshot, key)
+	if err != nil {
+		return nil, err
+	}
+
+	user := current
+
+	if current.UpdatedAt.After(at) {
+		user, err = p.findVersion(snapshot, key, func(version *common.User) bool {
+			return !version.UpdatedAt.After(at)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if user == nil {
+		p.logger.Debug("No user version retained at time", zap.Int64("key", key), zap.Time("at", at))
+
+		return nil, common.ErrUserNotFound
+	}
+
+	if user.DeletedAt != nil {
+		return nil, common.ErrUserDeleted
+	}
+
+	p.logger.Debug("Retrieved user version from database", zap.Int64("ID", key), zap.Int64("version", user.Version))
+
+	return user, nil
+}
+
+// Versions returns all retained versions of the user, newest first
+func (p *Storage) Versions(key int64) ([]*common.User, error) {
+	snapshot := p.db.NewSnapshot()
+	defer snapshot.Close()
+
+	current, err := p.getExistingFrom(snapshot, key)
+	if err != nil {
+		return nil, err
+	}
+
+	versions := make([]*common.User, 0)
+
+	_, err = p.findVersion(snapshot, key, func(version *common.User) bool {
+		versions = append(versions, version)
+
+		return false
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// The current record was written before versions were kept
+	if len(versions) == 0 || versions[0].Version != current.Version {
+		versions = append([]*common.User{current}, versions...)
+	}
+
+	p.logger.Debug("Retrieved user versions from database", zap.Int64("ID", key), zap.Int("count", len(versions)))
+
+	return versions, nil
+}
+
+// findVersion walks the versions of the user from the newest to the oldest and returns the first one matching,
+// or nil if none matches
+func (p *Storage) findVersion(snapshot *pebble.Snapshot, key int64, match func(version *common.User) bool) (*common.User, error) {
+	prefix := userVersionsPrefix(key)
+
+	iter, err := snapshot.NewIter(&pebble.IterOptions{
+		LowerBound: prefix,
+		UpperBound: prefixUpperBound(prefix),
+	})
+	if err != nil {
+		p.logger.Error("Failed to create version iterator", zap.Error(err))
+
+		return nil, err
+	}
+	defer iter.Close()
+
+	for valid := iter.Last(); valid; valid = iter.Prev() {
+		version, err := decodeUser(key, iter.Value())
+		if err != nil {
+			p.logger.Error("Failed to decode version from database", zap.Int64("key", key), zap.Error(err))
+
+			return nil, err
+		}
+
+		if match(version) {
+			return version, nil
+		}
+	}
+
+	return nil, iter.Error()
+}
+
+// CompactVersions removes every version which was replaced by a newer version written at or before the given time.
+// Versions of a user are ordered by version, which is also the order of their times
+func (p *Storage) CompactVersions(before time.Time) (int, error) {
+	iter, err := p.db.NewIter(&pebble.IterOptions{
+		LowerBound: versionPrefix,
+		UpperBound: prefixUpperBound(versionPrefix),
+	})
+	if err != nil {
+		p.logger.Error("Failed to create version iterator", zap.Error(err))
+
+		return 0, err
+	}
+	defer iter.Close()
+
+	batch := p.db.NewBatch()
+	defer func() {
+		batch.Close()
+	}()
+
+	compacted := 0
+
+	// previous is the key of the last version of the current user which was in effect before the given time
+	var previous []byte
+
+	for valid := iter.First(); valid; valid = iter.Next() {
+		key := iter.Key()
+
+		if previous != nil && parseVersionKey(previous) != parseVersionKey(key) {
+			previous = nil
+		}
+
+		version, err := decodeUser(parseVersionKey(key), iter.Value())
+		if err != nil {
+			return compacted, err
+		}
+
+		if version.UpdatedAt.After(before) {
+			continue
+		}
+
+		// The previous version was replaced before the given time
+		if previous != nil {
+			if err := batch.Delete(previous, nil); err != nil {
+				return compacted, err
+			}
+
+			compacted++
+		}
+
+		previous = append(previous[:0], key...)
+
+		if int(batch.Count()) >= compactBatchSize {
+			if err := batch.Commit(pebble.Sync); err != nil {
+				return compacted, err
+			}
+
+			batch.Close()
+			batch = p.db.NewBatch()
+		}
+	}
+
+	if err := iter.Error(); err != nil {
+		return compacted, err
+	}
+
+	if err := batch.Commit(pebble.Sync); err != nil {
+		p.logger.Error("Failed to compact user versions", zap.Error(err))
+
+		return compacted, err
+	}
+
+	p.logger.Debug("Compacted user versions in database", zap.Time("before", before), zap.Int("count", compacted))
+
+	return compacted, nil
+}
@@ -0,0 +1,327 @@
+package pebble
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"math"
+	"time"
+
+	"github.com/Aleksao998/LightningUserVault/core/webhook"
+	"github.com/cockroachdb/pebble"
+	"go.uber.org/zap"
+)
+
+var (
+	// webhookPrefix is the namespace of registered webhooks, keyed by their big endian ID
+	webhookPrefix = []byte("__webhook__")
+
+	// webhookDeliveryPrefix is the namespace of webhook deliveries, keyed by their big endian ID
+	webhookDeliveryPrefix = []byte("__webhookdelivery__")
+
+	// webhookQueuePrefix is the namespace of pending deliveries ordered by their next attempt.
+	// Queue keys are built as webhookQueuePrefix + big endian next attempt in nanoseconds + big endian delivery ID
+	// and have an empty value
+	webhookQueuePrefix = []byte("__webhookqueue__")
+
+	// webhookCursorKey holds the ID of the last event which was queued for webhooks
+	webhookCursorKey = []byte("__webhookCursor__")
+
+	// webhookSequenceKey holds the last ID given to a webhook or delivery
+	webhookSequenceKey = []byte("__webhookSequence__")
+)
+
+// webhookKey returns the key of the given ID in the namespace
+func webhookKey(prefix []byte, id int64) []byte {
+	key := make([]byte, 0, len(prefix)+userKeyLength)
+	key = append(key, prefix...)
+
+	return binary.BigEndian.AppendUint64(key, uint64(id))
+}
+
+// webhookQueueKey returns the queue key of the delivery with the given next attempt
+func webhookQueueKey(nextAttempt time.Time, id int64) []byte {
+	key := make([]byte, 0, len(webhookQueuePrefix)+2*userKeyLength)
+	key = append(key, webhookQueuePrefix...)
+	key = binary.BigEndian.AppendUint64(key, uint64(nextAttempt.UnixNano()))
+
+	return binary.BigEndian.AppendUint64(key, uint64(id))
+}
+
+// readWebhookInt returns the integer stored under the key, or 0 if it does not exist
+func (p *Storage) readWebhookInt(key []byte) (int64, error) {
+	value, closer, err := p.db.Get(key)
+	if errors.Is(err, pebble.ErrNotFound) {
+		return 0, nil
+	}
+
+	if err != nil {
+		return 0, err
+	}
+	defer closer.Close()
+
+	return int64(binary.BigEndian.Uint64(value)), nil
+}
+
+// readJSON decodes the value stored under the key into target, returning notFound if the key does not exist
+func (p *Storage) readJSON(key []byte, target interface{}, notFound error) error {
+	value, closer, err := p.db.Get(key)
+	if errors.Is(err, pebble.ErrNotFound) {
+		return notFound
+	}
+
+	if err != nil {
+		return err
+	}
+	defer closer.Close()
+
+	return json.Unmarshal(value, target)
+}
+
+// setWebhookJSON adds the encoded value to the batch
+func setWebhookJSON(batch *pebble.Batch, key []byte, value interface{}) error {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	return batch.Set(key, encoded, nil)
+}
+
+// nextWebhookIDs reserves count IDs for webhooks or deliveries and adds the updated sequence to the batch.
+// It returns the first reserved ID and must be called while holding the webhook lock
+func (p *Storage) nextWebhookIDs(batch *pebble.Batch, count int) (int64, error) {
+	sequence, err := p.readWebhookInt(webhookSequenceKey)
+	if err != nil {
+		return 0, err
+	}
+
+	last := sequence + int64(count)
+
+	return sequence + 1, batch.Set(webhookSequenceKey, binary.BigEndian.AppendUint64(nil, uint64(last)), nil)
+}
+
+// AddWebhook assigns an ID to the webhook and stores it
+func (p *Storage) AddWebhook(hook *webhook.Webhook) error {
+	p.webhookLock.Lock()
+	defer p.webhookLock.Unlock()
+
+	batch := p.db.NewBatch()
+	defer batch.Close()
+
+	id, err := p.nextWebhookIDs(batch, 1)
+	if err != nil {
+		return err
+	}
+
+	hook.ID = id
+
+	if err := setWebhookJSON(batch, webhookKey(webhookPrefix, id), hook); err != nil {
+		return err
+	}
+
+	if err := batch.Commit(pebble.Sync); err != nil {
+		p.logger.Error("Failed to store webhook in database", zap.String("url", hook.URL), zap.Error(err))
+
+		return err
+	}
+
+	return nil
+}
+
+// GetWebhook returns the webhook with the given ID
+func (p *Storage) GetWebhook(id int64) (*webhook.Webhook, error) {
+	var hook webhook.Webhook
+
+	if err := p.readJSON(webhookKey(webhookPrefix, id), &hook, webhook.ErrWebhookNotFound); err != nil {
+		return nil, err
+	}
+
+	return &hook, nil
+}
+
+// ListWebhooks returns all webhooks ordered by ID
+func (p *Storage) ListWebhooks() ([]*webhook.Webhook, error) {
+	iter, err := p.db.NewIter(&pebble.IterOptions{
+		LowerBound: webhookPrefix,
+		UpperBound: prefixUpperBound(webhookPrefix),
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Close()
+
+	webhooks := make([]*webhook.Webhook, 0)
+
+	for valid := iter.First(); valid; valid = iter.Next() {
+		var hook webhook.Webhook
+		if err := json.Unmarshal(iter.Value(), &hook); err != nil {
+			return nil, err
+		}
+
+		webhooks = append(webhooks, &hook)
+	}
+
+	return webhooks, iter.Error()
+}
+
+// RemoveWebhook removes the webhook with the given ID
+func (p *Storage) RemoveWebhook(id int64) error {
+	p.webhookLock.Lock()
+	defer p.webhookLock.Unlock()
+
+	if _, err := p.GetWebhook(id); err != nil {
+		return err
+	}
+
+	return p.db.Delete(webhookKey(webhookPrefix, id), pebble.Sync)
+}
+
+// WebhookCursor returns the ID of the last queued event
+func (p *Storage) WebhookCursor() (int64, error) {
+	return p.readWebhookInt(webhookCursorKey)
+}
+
+// EnqueueDeliveries assigns IDs to the deliveries and stores them with their queue entries and the cursor in a single batch
+func (p *Storage) EnqueueDeliveries(cursor int64, deliveries []*webhook.Delivery) error {
+	p.webhookLock.Lock()
+	defer p.webhookLock.Unlock()
+
+	batch := p.db.NewBatch()
+	defer batch.Close()
+
+	if len(deliveries) > 0 {
+		firstID, err := p.nextWebhookIDs(batch, len(deliveries))
+		if err != nil {
+			return err
+		}
+
+		for i, delivery := range deliveries {
+			delivery.ID = firstID + int64(i)
+
+			if err := setWebhookJSON(batch, webhookKey(webhookDeliveryPrefix, delivery.ID), delivery); err != nil {
+				return err
+			}
+
+			if err := batch.Set(webhookQueueKey(delivery.NextAttempt, delivery.ID), nil, nil); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := batch.Set(webhookCursorKey, binary.BigEndian.AppendUint64(nil, uint64(cursor)), nil); err != nil {
+		return err
+	}
+
+	if err := batch.Commit(pebble.Sync); err != nil {
+		p.logger.Error("Failed to queue webhook deliveries in database", zap.Int64("cursor", cursor), zap.Error(err))
+
+		return err
+	}
+
+	return nil
+}
+
+// DueDeliveries returns up to limit pending deliveries whose next attempt is not after the given time, oldest first
+func (p *Storage) DueDeliveries(now time.Time, limit int) ([]*webhook.Delivery, error) {
+	iter, err := p.db.NewIter(&pebble.IterOptions{
+		LowerBound: webhookQueuePrefix,
+		UpperBound: webhookQueueKey(now, math.MaxInt64),
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Close()
+
+	deliveries := make([]*webhook.Delivery, 0)
+
+	for valid := iter.First(); valid && len(deliveries) < limit; valid = iter.Next() {
+		key := iter.Key()
+
+		delivery, err := p.GetDelivery(int64(binary.BigEndian.Uint64(key[len(key)-userKeyLength:])))
+		if err != nil {
+			return nil, err
+		}
+
+		deliveries = append(deliveries, delivery)
+	}
+
+	return deliveries, iter.Error()
+}
+
+// GetDelivery returns the delivery with the given ID
+func (p *Storage) GetDelivery(id int64) (*webhook.Delivery, error) {
+	var delivery webhook.Delivery
+
+	if err := p.readJSON(webhookKey(webhookDeliveryPrefix, id), &delivery, webhook.ErrDeliveryNotFound); err != nil {
+		return nil, err
+	}
+
+	return &delivery, nil
+}
+
+// ListDeliveries returns deliveries selected by the filter, newest first
+func (p *Storage) ListDeliveries(filter webhook.DeliveryFilter) ([]*webhook.Delivery, error) {
+	iter, err := p.db.NewIter(&pebble.IterOptions{
+		LowerBound: webhookDeliveryPrefix,
+		UpperBound: prefixUpperBound(webhookDeliveryPrefix),
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Close()
+
+	deliveries := make([]*webhook.Delivery, 0)
+
+	for valid := iter.Last(); valid && len(deliveries) < filter.Limit; valid = iter.Prev() {
+		var delivery webhook.Delivery
+		if err := json.Unmarshal(iter.Value(), &delivery); err != nil {
+			return nil, err
+		}
+
+		if filter.Matches(&delivery) {
+			deliveries = append(deliveries, &delivery)
+		}
+	}
+
+	return deliveries, iter.Error()
+}
+
+// UpdateDelivery stores the delivery and moves its queue entry to the new next attempt, or removes it
+// when the delivery is no longer pending
+func (p *Storage) UpdateDelivery(delivery *webhook.Delivery) error {
+	p.webhookLock.Lock()
+	defer p.webhookLock.Unlock()
+
+	previous, err := p.GetDelivery(delivery.ID)
+	if err != nil {
+		return err
+	}
+
+	batch := p.db.NewBatch()
+	defer batch.Close()
+
+	if previous.Status == webhook.Pending {
+		if err := batch.Delete(webhookQueueKey(previous.NextAttempt, delivery.ID), nil); err != nil {
+			return err
+		}
+	}
+
+	if err := setWebhookJSON(batch, webhookKey(webhookDeliveryPrefix, delivery.ID), delivery); err != nil {
+		return err
+	}
+
+	if delivery.Status == webhook.Pending {
+		if err := batch.Set(webhookQueueKey(delivery.NextAttempt, delivery.ID), nil, nil); err != nil {
+			return err
+		}
+	}
+
+	if err := batch.Commit(pebble.Sync); err != nil {
+		p.logger.Error("Failed to update webhook delivery in database", zap.Int64("delivery", delivery.ID), zap.Error(err))
+
+		return err
+	}
+
+	return nil
+}
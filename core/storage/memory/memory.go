@@ -0,0 +1,407 @@
+package memory
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Aleksao998/LightningUserVault/core/audit"
+	"github.com/Aleksao998/LightningUserVault/core/common"
+	"github.com/Aleksao998/LightningUserVault/core/webhook"
+	"go.uber.org/zap"
+)
+
+const (
+	// firstVersion is the version of newly stored users
+	firstVersion int64 = 1
+
+	// userKeyLength is the length of the encoded user ID used as a list cursor
+	userKeyLength = 8
+)
+
+// Options configures optional behaviour of the storage
+type Options struct {
+	// KeepVersions stores an immutable copy of every written version of a user
+	KeepVersions bool
+}
+
+// auditStore is the in-memory audit store, named so it can be embedded next to the webhook store
+type auditStore = audit.MemoryStore
+
+// Storage keeps users in memory, it is lost when the process stops.
+// Users are copied on every read and write, so callers never share state with the storage
+type Storage struct {
+	// MemoryStore keeps webhooks and their delivery queue next to the users
+	*webhook.MemoryStore
+
+	// auditStore keeps the audit log of user writes next to the users
+	*auditStore
+
+	logger  *zap.Logger
+	options Options
+
+	// lock guards all fields below, reads share it and writes hold it exclusively
+	lock     sync.RWMutex
+	users    map[int64]*common.User
+	versions map[int64][]*common.User
+	nextID   int64
+}
+
+// NewStorage initializes a new empty Storage instance
+func NewStorage(logger *zap.Logger, options Options) *Storage {
+	return &Storage{
+		MemoryStore: webhook.NewMemoryStore(),
+		auditStore:  audit.NewMemoryStore(),
+		logger:      logger,
+		options:     options,
+		users:       make(map[int64]*common.User),
+		versions:    make(map[int64][]*common.User),
+	}
+}
+
+// copyUser returns a deep copy of the user
+func copyUser(user *common.User) *common.User {
+	copied := *user
+
+	if user.Attributes != nil {
+		copied.Attributes = make(map[string]string, len(user.Attributes))
+		for key, value := range user.Attributes {
+			copied.Attributes[key] = value
+		}
+	}
+
+	if user.DeletedAt != nil {
+		deletedAt := *user.DeletedAt
+		copied.DeletedAt = &deletedAt
+	}
+
+	return &copied
+}
+
+// store saves a copy of the user as its current record and, when versions are kept, as its latest version.
+// It must be called while holding the write lock
+func (m *Storage) store(user *common.User) {
+	m.users[user.ID] = copyUser(user)
+
+	if m.options.KeepVersions {
+		m.versions[user.ID] = append(m.versions[user.ID], copyUser(user))
+	}
+}
+
+// Set stores a new user, filling its ID, timestamps and version, and returns the ID
+func (m *Storage) Set(user *common.User) (int64, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.nextID++
+
+	user.ID = m.nextID
+	user.CreatedAt = time.Now().UTC()
+	user.UpdatedAt = user.CreatedAt
+	user.Version = firstVersion
+	user.DeletedAt = nil
+
+	m.store(user)
+
+	m.logger.Debug("Stored user in memory", zap.Int64("ID", user.ID))
+
+	return user.ID, nil
+}
+
+// SetBatch allocates IDs for all users, fills their IDs, timestamps and versions and stores them at once
+func (m *Storage) SetBatch(users []*common.User) ([]int64, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	now := time.Now().UTC()
+	ids := make([]int64, 0, len(users))
+
+	for _, user := range users {
+		m.nextID++
+
+		user.ID = m.nextID
+		user.CreatedAt = now
+		user.UpdatedAt = now
+		user.Version = firstVersion
+		user.DeletedAt = nil
+
+		m.store(user)
+
+		ids = append(ids, user.ID)
+	}
+
+	m.logger.Debug("Stored batch of users in memory", zap.Int("size", len(users)))
+
+	return ids, nil
+}
+
+// Get retrieves the user with the given ID
+func (m *Storage) Get(key int64) (*common.User, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	user, ok := m.users[key]
+	if !ok {
+		m.logger.Debug("User not found", zap.Int64("key", key))
+
+		return nil, common.ErrUserNotFound
+	}
+
+	if user.DeletedAt != nil {
+		m.logger.Debug("Retrieved user is deleted", zap.Int64("ID", key))
+
+		return nil, common.ErrUserDeleted
+	}
+
+	return copyUser(user), nil
+}
+
+// GetMulti retrieves the users with the given IDs at once, missing and soft deleted users are omitted
+func (m *Storage) GetMulti(keys []int64) (map[int64]*common.User, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	users := make(map[int64]*common.User, len(keys))
+
+	for _, key := range keys {
+		user, ok := m.users[key]
+		if !ok || user.DeletedAt != nil {
+			continue
+		}
+
+		users[key] = copyUser(user)
+	}
+
+	return users, nil
+}
+
+// FindByName returns all users with the given name, or with names starting with it when prefix is set, ordered by ID
+func (m *Storage) FindByName(name string, prefix bool) ([]*common.User, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	users := make([]*common.User, 0)
+
+	for _, user := range m.users {
+		if user.DeletedAt != nil {
+			continue
+		}
+
+		if user.Name == name || (prefix && strings.HasPrefix(user.Name, name)) {
+			users = append(users, copyUser(user))
+		}
+	}
+
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].ID < users[j].ID
+	})
+
+	return users, nil
+}
+
+// Update overwrites the stored fields of an existing user, filling its timestamps and incremented version,
+// and returns the replaced user. It returns an error if the key does not exist or is not at the given version
+func (m *Storage) Update(user *common.User, version int64) (*common.User, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	existing, err := m.getExistingVersion(user.ID, version)
+	if err != nil {
+		return nil, err
+	}
+
+	if existing.DeletedAt != nil {
+		m.logger.Warn("User to update is deleted", zap.Int64("key", user.ID))
+
+		return nil, common.ErrUserDeleted
+	}
+
+	user.CreatedAt = existing.CreatedAt
+	user.UpdatedAt = time.Now().UTC()
+	user.Version = existing.Version + 1
+	user.DeletedAt = nil
+
+	m.store(user)
+
+	m.logger.Debug("Updated user in memory", zap.Int64("ID", user.ID))
+
+	return copyUser(existing), nil
+}
+
+// Delete permanently removes an existing user together with its versions, also when it is soft deleted,
+// and returns the removed user. It returns an error if the key does not exist or is not at the given version
+func (m *Storage) Delete(key int64, version int64) (*common.User, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	existing, err := m.getExistingVersion(key, version)
+	if err != nil {
+		return nil, err
+	}
+
+	delete(m.users, key)
+	delete(m.versions, key)
+
+	m.logger.Debug("Deleted user from memory", zap.Int64("ID", key))
+
+	return copyUser(existing), nil
+}
+
+// SoftDelete marks an existing user as deleted, increments its version and returns the user as it was before.
+// It returns an error if the key does not exist, is not at the given version or is already deleted
+func (m *Storage) SoftDelete(key int64, version int64) (*common.User, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	existing, err := m.getExistingVersion(key, version)
+	if err != nil {
+		return nil, err
+	}
+
+	if existing.DeletedAt != nil {
+		m.logger.Warn("User to delete is already deleted", zap.Int64("key", key))
+
+		return nil, common.ErrUserDeleted
+	}
+
+	deletedAt := time.Now().UTC()
+
+	user := copyUser(existing)
+	user.DeletedAt = &deletedAt
+	user.UpdatedAt = deletedAt
+	user.Version++
+
+	m.store(user)
+
+	m.logger.Debug("Soft deleted user in memory", zap.Int64("ID", key))
+
+	return copyUser(existing), nil
+}
+
+// Restore clears the deletion mark of a soft deleted user, filling its timestamps and incremented version,
+// and returns it together with the soft deleted user. It returns an error if the key does not exist or is not deleted
+func (m *Storage) Restore(key int64) (*common.User, *common.User, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	existing, err := m.getExistingVersion(key, common.AnyVersion)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if existing.DeletedAt == nil {
+		m.logger.Warn("User to restore is not deleted", zap.Int64("key", key))
+
+		return nil, nil, common.ErrUserNotDeleted
+	}
+
+	user := copyUser(existing)
+	user.DeletedAt = nil
+	user.UpdatedAt = time.Now().UTC()
+	user.Version++
+
+	m.store(user)
+
+	m.logger.Debug("Restored user in memory", zap.Int64("ID", key))
+
+	return copyUser(user), copyUser(existing), nil
+}
+
+// Purge permanently removes users soft deleted before the given time
+func (m *Storage) Purge(before time.Time) (int, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	purged := 0
+
+	for id, user := range m.users {
+		if user.DeletedAt != nil && user.DeletedAt.Before(before) {
+			delete(m.users, id)
+			delete(m.versions, id)
+
+			purged++
+		}
+	}
+
+	m.logger.Debug("Purged deleted users from memory", zap.Time("before", before), zap.Int("count", purged))
+
+	return purged, nil
+}
+
+// List returns up to limit users with an ID greater than the one encoded in the cursor, ordered by ID
+func (m *Storage) List(cursor string, limit int) ([]*common.User, string, error) {
+	var lastID int64
+
+	if cursor != "" {
+		data, err := common.DecodeCursor(cursor)
+		if err != nil || len(data) != userKeyLength {
+			m.logger.Warn("Invalid list cursor received", zap.String("cursor", cursor))
+
+			return nil, "", common.ErrInvalidCursor
+		}
+
+		lastID = common.BytesToInt64(data)
+	}
+
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	ids := make([]int64, 0, len(m.users))
+
+	for id, user := range m.users {
+		if id > lastID && user.DeletedAt == nil {
+			ids = append(ids, id)
+		}
+	}
+
+	sort.Slice(ids, func(i, j int) bool {
+		return ids[i] < ids[j]
+	})
+
+	nextCursor := ""
+
+	if len(ids) > limit {
+		ids = ids[:limit]
+		nextCursor = common.EncodeCursor(common.Int64ToBytes(ids[limit-1]))
+	}
+
+	users := make([]*common.User, 0, len(ids))
+	for _, id := range ids {
+		users = append(users, copyUser(m.users[id]))
+	}
+
+	return users, nextCursor, nil
+}
+
+// getExistingVersion returns the user stored under the given key if it is at the given version,
+// common.AnyVersion matches every version. It must be called while holding the lock
+func (m *Storage) getExistingVersion(key int64, version int64) (*common.User, error) {
+	user, ok := m.users[key]
+	if !ok {
+		m.logger.Warn("User not found", zap.Int64("key", key))
+
+		return nil, common.ErrUserNotFound
+	}
+
+	if version != common.AnyVersion && user.Version != version {
+		m.logger.Warn("User version mismatch", zap.Int64("key", key), zap.Int64("version", user.Version), zap.Int64("expected", version))
+
+		return nil, common.ErrVersionMismatch
+	}
+
+	return user, nil
+}
+
+// Close releases the stored users
+func (m *Storage) Close() error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.users = make(map[int64]*common.User)
+	m.versions = make(map[int64][]*common.User)
+
+	m.logger.Info("Closed in-memory storage")
+
+	return nil
+}
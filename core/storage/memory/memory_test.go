@@ -0,0 +1,288 @@
+package memory
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Aleksao998/LightningUserVault/core/audit"
+	"github.com/Aleksao998/LightningUserVault/core/audit/audittest"
+	"github.com/Aleksao998/LightningUserVault/core/common"
+	"github.com/Aleksao998/LightningUserVault/core/webhook"
+	"github.com/Aleksao998/LightningUserVault/core/webhook/webhooktest"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+// newUsers creates users with the given names
+func newUsers(names ...string) []*common.User {
+	users := make([]*common.User, 0, len(names))
+	for _, name := range names {
+		users = append(users, &common.User{Name: name})
+	}
+
+	return users
+}
+
+// TestMemoryStorage_WriteRead tests that stored users are read back as copies with increasing IDs
+func TestMemoryStorage_WriteRead(t *testing.T) {
+	t.Parallel()
+
+	store := NewStorage(zap.NewNop(), Options{})
+
+	user := &common.User{Name: "alice", Attributes: map[string]string{"team": "a"}}
+
+	id, err := store.Set(user)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), id)
+	assert.Equal(t, int64(1), user.Version)
+	assert.False(t, user.CreatedAt.IsZero())
+
+	// Changing the written user or a read copy does not change the stored user
+	user.Attributes["team"] = "b"
+
+	retrieved, err := store.Get(id)
+	assert.NoError(t, err)
+	assert.Equal(t, "a", retrieved.Attributes["team"])
+
+	retrieved.Name = "bob"
+
+	retrieved, err = store.Get(id)
+	assert.NoError(t, err)
+	assert.Equal(t, "alice", retrieved.Name)
+
+	_, err = store.Get(int64(100))
+	assert.ErrorIs(t, err, common.ErrUserNotFound)
+
+	ids, err := store.SetBatch(newUsers("bob", "carol"))
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{2, 3}, ids)
+
+	users, err := store.GetMulti([]int64{1, 3, 100})
+	assert.NoError(t, err)
+	assert.Len(t, users, 2)
+	assert.Equal(t, "carol", users[3].Name)
+}
+
+// TestMemoryStorage_WriteParallel tests that concurrent writes get unique IDs
+func TestMemoryStorage_WriteParallel(t *testing.T) {
+	t.Parallel()
+
+	store := NewStorage(zap.NewNop(), Options{})
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			_, err := store.Set(&common.User{Name: "user"})
+			assert.NoError(t, err)
+		}()
+	}
+
+	wg.Wait()
+
+	users, _, err := store.List("", 1000)
+	assert.NoError(t, err)
+	assert.Len(t, users, 100)
+	assert.Equal(t, int64(100), users[99].ID)
+}
+
+// TestMemoryStorage_UpdateAndDelete tests conditional updates and deletes
+func TestMemoryStorage_UpdateAndDelete(t *testing.T) {
+	t.Parallel()
+
+	store := NewStorage(zap.NewNop(), Options{})
+
+	id, err := store.Set(&common.User{Name: "alice"})
+	assert.NoError(t, err)
+
+	user := &common.User{ID: id, Name: "bob"}
+
+	_, err = store.Update(user, 2)
+	assert.ErrorIs(t, err, common.ErrVersionMismatch)
+	replaced, err := store.Update(user, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), user.Version)
+	assert.Equal(t, "alice", replaced.Name)
+	assert.Equal(t, int64(1), replaced.Version)
+	_, err = store.Update(&common.User{ID: 100, Name: "bob"}, common.AnyVersion)
+	assert.ErrorIs(t, err, common.ErrUserNotFound)
+
+	found, err := store.FindByName("bo", true)
+	assert.NoError(t, err)
+	assert.Len(t, found, 1)
+
+	found, err = store.FindByName("bo", false)
+	assert.NoError(t, err)
+	assert.Empty(t, found)
+
+	_, err = store.Delete(id, 1)
+	assert.ErrorIs(t, err, common.ErrVersionMismatch)
+	removed, err := store.Delete(id, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, "bob", removed.Name)
+	_, err = store.Delete(id, common.AnyVersion)
+	assert.ErrorIs(t, err, common.ErrUserNotFound)
+
+	// IDs are never reused
+	newID, err := store.Set(&common.User{Name: "carol"})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), newID)
+}
+
+// TestMemoryStorage_List tests that users are listed in ID order across pages
+func TestMemoryStorage_List(t *testing.T) {
+	t.Parallel()
+
+	store := NewStorage(zap.NewNop(), Options{})
+
+	_, err := store.SetBatch(newUsers("alice", "bob", "carol"))
+	assert.NoError(t, err)
+
+	users, cursor, err := store.List("", 2)
+	assert.NoError(t, err)
+	assert.Len(t, users, 2)
+	assert.NotEmpty(t, cursor)
+
+	users, cursor, err = store.List(cursor, 2)
+	assert.NoError(t, err)
+	assert.Len(t, users, 1)
+	assert.Equal(t, "carol", users[0].Name)
+	assert.Empty(t, cursor)
+
+	_, _, err = store.List("invalid", 2)
+	assert.ErrorIs(t, err, common.ErrInvalidCursor)
+}
+
+// TestMemoryStorage_SoftDeleteAndPurge tests that soft deleted users are hidden, can be restored and are purged
+func TestMemoryStorage_SoftDeleteAndPurge(t *testing.T) {
+	t.Parallel()
+
+	store := NewStorage(zap.NewNop(), Options{})
+
+	ids, err := store.SetBatch(newUsers("alice", "bob"))
+	assert.NoError(t, err)
+
+	replaced, err := store.SoftDelete(ids[0], 1)
+	assert.NoError(t, err)
+	assert.Equal(t, "alice", replaced.Name)
+	assert.Equal(t, int64(1), replaced.Version)
+	assert.Nil(t, replaced.DeletedAt)
+	_, err = store.SoftDelete(ids[0], common.AnyVersion)
+	assert.ErrorIs(t, err, common.ErrUserDeleted)
+
+	_, err = store.Get(ids[0])
+	assert.ErrorIs(t, err, common.ErrUserDeleted)
+
+	listed, _, err := store.List("", 10)
+	assert.NoError(t, err)
+	assert.Len(t, listed, 1)
+
+	_, _, err = store.Restore(ids[1])
+	assert.ErrorIs(t, err, common.ErrUserNotDeleted)
+
+	restored, before, err := store.Restore(ids[0])
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), restored.Version)
+	assert.Equal(t, int64(2), before.Version)
+	assert.NotNil(t, before.DeletedAt)
+
+	_, err = store.SoftDelete(ids[1], common.AnyVersion)
+	assert.NoError(t, err)
+
+	purged, err := store.Purge(time.Now().Add(time.Second))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, purged)
+
+	_, _, err = store.Restore(ids[1])
+	assert.ErrorIs(t, err, common.ErrUserNotFound)
+}
+
+// TestMemoryStorage_IgnoreDeletionMark tests that users are only marked as deleted by SoftDelete
+func TestMemoryStorage_IgnoreDeletionMark(t *testing.T) {
+	t.Parallel()
+
+	store := NewStorage(zap.NewNop(), Options{})
+
+	deletedAt := time.Now().UTC()
+
+	id, err := store.Set(&common.User{Name: "alice", DeletedAt: &deletedAt})
+	assert.NoError(t, err)
+
+	ids, err := store.SetBatch([]*common.User{{Name: "bob", DeletedAt: &deletedAt}})
+	assert.NoError(t, err)
+
+	_, err = store.Update(&common.User{ID: ids[0], Name: "carol", DeletedAt: &deletedAt}, common.AnyVersion)
+	assert.NoError(t, err)
+
+	users, err := store.GetMulti([]int64{id, ids[0]})
+	assert.NoError(t, err)
+	assert.Len(t, users, 2)
+}
+
+// TestMemoryStorage_Versions tests point-in-time reads and compaction of versions
+func TestMemoryStorage_Versions(t *testing.T) {
+	t.Parallel()
+
+	store := NewStorage(zap.NewNop(), Options{KeepVersions: true})
+
+	id, err := store.Set(&common.User{Name: "alice"})
+	assert.NoError(t, err)
+
+	created, err := store.Get(id)
+	assert.NoError(t, err)
+
+	_, err = store.Update(&common.User{ID: id, Name: "bob"}, common.AnyVersion)
+	assert.NoError(t, err)
+
+	before := time.Now().UTC()
+
+	_, err = store.Update(&common.User{ID: id, Name: "carol"}, common.AnyVersion)
+	assert.NoError(t, err)
+
+	user, err := store.GetAsOf(id, created.UpdatedAt)
+	assert.NoError(t, err)
+	assert.Equal(t, "alice", user.Name)
+
+	_, err = store.GetAsOf(id, created.UpdatedAt.Add(-time.Second))
+	assert.ErrorIs(t, err, common.ErrUserNotFound)
+
+	versions, err := store.Versions(id)
+	assert.NoError(t, err)
+	assert.Len(t, versions, 3)
+	assert.Equal(t, "carol", versions[0].Name)
+
+	compacted, err := store.CompactVersions(before)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, compacted)
+
+	user, err = store.GetAsOf(id, before)
+	assert.NoError(t, err)
+	assert.Equal(t, "bob", user.Name)
+
+	_, err = store.Delete(id, common.AnyVersion)
+	assert.NoError(t, err)
+
+	_, err = store.Versions(id)
+	assert.ErrorIs(t, err, common.ErrUserNotFound)
+}
+
+func TestMemoryStorage_WebhookConformance(t *testing.T) {
+	webhooktest.RunConformance(t, func(t *testing.T) webhook.Store {
+		t.Helper()
+
+		return NewStorage(zap.NewNop(), Options{})
+	})
+}
+
+func TestMemoryStorage_AuditConformance(t *testing.T) {
+	audittest.RunConformance(t, func(t *testing.T) audit.Store {
+		t.Helper()
+
+		return NewStorage(zap.NewNop(), Options{})
+	})
+}
@@ -0,0 +1,97 @@
+package memory
+
+import (
+	"time"
+
+	"github.com/Aleksao998/LightningUserVault/core/common"
+	"go.uber.org/zap"
+)
+
+// GetAsOf returns the latest version of the user which was written at or before the given time.
+// The current record is the latest version, so it is used for users written before versions were kept
+func (m *Storage) GetAsOf(key int64, at time.Time) (*common.User, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	user, err := m.getExistingVersion(key, common.AnyVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.UpdatedAt.After(at) {
+		user = nil
+
+		versions := m.versions[key]
+		for i := len(versions) - 1; i >= 0; i-- {
+			if !versions[i].UpdatedAt.After(at) {
+				user = versions[i]
+
+				break
+			}
+		}
+	}
+
+	if user == nil {
+		m.logger.Debug("No user version retained at time", zap.Int64("key", key), zap.Time("at", at))
+
+		return nil, common.ErrUserNotFound
+	}
+
+	if user.DeletedAt != nil {
+		return nil, common.ErrUserDeleted
+	}
+
+	return copyUser(user), nil
+}
+
+// Versions returns all retained versions of the user, newest first
+func (m *Storage) Versions(key int64) ([]*common.User, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	current, err := m.getExistingVersion(key, common.AnyVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	kept := m.versions[key]
+	versions := make([]*common.User, 0, len(kept)+1)
+
+	// The current record was written before versions were kept
+	if len(kept) == 0 || kept[len(kept)-1].Version != current.Version {
+		versions = append(versions, copyUser(current))
+	}
+
+	for i := len(kept) - 1; i >= 0; i-- {
+		versions = append(versions, copyUser(kept[i]))
+	}
+
+	return versions, nil
+}
+
+// CompactVersions removes every version which was replaced by a newer version written at or before the given time
+func (m *Storage) CompactVersions(before time.Time) (int, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	compacted := 0
+
+	for id, versions := range m.versions {
+		// The newest version written at or before the given time is the first one kept
+		keep := 0
+		for i, version := range versions {
+			if !version.UpdatedAt.After(before) {
+				keep = i
+			}
+		}
+
+		if keep > 0 {
+			m.versions[id] = append([]*common.User{}, versions[keep:]...)
+			compacted += keep
+		}
+	}
+
+	m.logger.Debug("Compacted user versions in memory", zap.Time("before", before), zap.Int("count", compacted))
+
+	return compacted, nil
+}
@@ -7,15 +7,31 @@ import (
 )
 
 type (
-	FirstDelegate  func(out interface{}, where ...interface{}) *gorm.DB
-	CreateDelegate func(value interface{}) *gorm.DB
-	DBDelegate     func() (*sql.DB, error)
+	FirstDelegate           func(out interface{}, where ...interface{}) *gorm.DB
+	FindDelegate            func(dest interface{}, conds ...interface{}) *gorm.DB
+	CreateDelegate          func(value interface{}) *gorm.DB
+	CreateInBatchesDelegate func(value interface{}, batchSize int) *gorm.DB
+	UpdatesDelegate         func(values interface{}) *gorm.DB
+	DeleteDelegate          func(value interface{}, conds ...interface{}) *gorm.DB
+	WhereDelegate           func(query interface{}, args ...interface{}) *gorm.DB
+	ModelDelegate           func(value interface{}) *gorm.DB
+	ExecDelegate            func(sql string, values ...interface{}) *gorm.DB
+	TransactionDelegate     func(fc func(tx *gorm.DB) error, opts ...*sql.TxOptions) error
+	DBDelegate              func() (*sql.DB, error)
 )
 
 type MockSQLdb struct {
-	FirstFn  FirstDelegate
-	CreateFn CreateDelegate
-	DBFn     DBDelegate
+	FirstFn           FirstDelegate
+	FindFn            FindDelegate
+	CreateFn          CreateDelegate
+	CreateInBatchesFn CreateInBatchesDelegate
+	UpdatesFn         UpdatesDelegate
+	DeleteFn          DeleteDelegate
+	WhereFn           WhereDelegate
+	ModelFn           ModelDelegate
+	ExecFn            ExecDelegate
+	TransactionFn     TransactionDelegate
+	DBFn              DBDelegate
 }
 
 func (m *MockSQLdb) First(out interface{}, where ...interface{}) *gorm.DB {
@@ -26,6 +42,14 @@ func (m *MockSQLdb) First(out interface{}, where ...interface{}) *gorm.DB {
 	return nil
 }
 
+func (m *MockSQLdb) Find(dest interface{}, conds ...interface{}) *gorm.DB {
+	if m.FindFn != nil {
+		return m.FindFn(dest, conds...)
+	}
+
+	return nil
+}
+
 func (m *MockSQLdb) Create(value interface{}) *gorm.DB {
 	if m.CreateFn != nil {
 		return m.CreateFn(value)
@@ -34,6 +58,62 @@ func (m *MockSQLdb) Create(value interface{}) *gorm.DB {
 	return nil
 }
 
+func (m *MockSQLdb) CreateInBatches(value interface{}, batchSize int) *gorm.DB {
+	if m.CreateInBatchesFn != nil {
+		return m.CreateInBatchesFn(value, batchSize)
+	}
+
+	return nil
+}
+
+func (m *MockSQLdb) Updates(values interface{}) *gorm.DB {
+	if m.UpdatesFn != nil {
+		return m.UpdatesFn(values)
+	}
+
+	return nil
+}
+
+func (m *MockSQLdb) Delete(value interface{}, conds ...interface{}) *gorm.DB {
+	if m.DeleteFn != nil {
+		return m.DeleteFn(value, conds...)
+	}
+
+	return nil
+}
+
+func (m *MockSQLdb) Where(query interface{}, args ...interface{}) *gorm.DB {
+	if m.WhereFn != nil {
+		return m.WhereFn(query, args...)
+	}
+
+	return nil
+}
+
+func (m *MockSQLdb) Model(value interface{}) *gorm.DB {
+	if m.ModelFn != nil {
+		return m.ModelFn(value)
+	}
+
+	return nil
+}
+
+func (m *MockSQLdb) Exec(sql string, values ...interface{}) *gorm.DB {
+	if m.ExecFn != nil {
+		return m.ExecFn(sql, values...)
+	}
+
+	return nil
+}
+
+func (m *MockSQLdb) Transaction(fc func(tx *gorm.DB) error, opts ...*sql.TxOptions) error {
+	if m.TransactionFn != nil {
+		return m.TransactionFn(fc, opts...)
+	}
+
+	return nil
+}
+
 func (m *MockSQLdb) DB() (*sql.DB, error) {
 	if m.DBFn != nil {
 		return m.DBFn()
@@ -1,17 +1,45 @@
 package mocks
 
-import "github.com/Aleksao998/LightningUserVault/core/common"
+import (
+	"time"
+
+	"github.com/Aleksao998/LightningUserVault/core/common"
+)
 
 type (
-	getDelegate   func(key int64) (*common.User, error)
-	setDelegate   func(value string) (int64, error)
-	closeDelegate func() error
+	getDelegate        func(key int64) (*common.User, error)
+	setDelegate        func(user *common.User) (int64, error)
+	setBatchDelegate   func(users []*common.User) ([]int64, error)
+	getMultiDelegate   func(keys []int64) (map[int64]*common.User, error)
+	findByNameDelegate func(name string, prefix bool) ([]*common.User, error)
+	updateDelegate     func(user *common.User, version int64) (*common.User, error)
+	deleteDelegate     func(key int64, version int64) (*common.User, error)
+	softDeleteDelegate func(key int64, version int64) (*common.User, error)
+	restoreDelegate    func(key int64) (*common.User, *common.User, error)
+	purgeDelegate      func(before time.Time) (int, error)
+	getAsOfDelegate    func(key int64, at time.Time) (*common.User, error)
+	versionsDelegate   func(key int64) ([]*common.User, error)
+	compactDelegate    func(before time.Time) (int, error)
+	listDelegate       func(cursor string, limit int) ([]*common.User, string, error)
+	closeDelegate      func() error
 )
 
 type MockStorage struct {
-	GetFn   getDelegate
-	SetFn   setDelegate
-	CloseFn closeDelegate
+	GetFn        getDelegate
+	SetFn        setDelegate
+	SetBatchFn   setBatchDelegate
+	GetMultiFn   getMultiDelegate
+	FindByNameFn findByNameDelegate
+	UpdateFn     updateDelegate
+	DeleteFn     deleteDelegate
+	SoftDeleteFn softDeleteDelegate
+	RestoreFn    restoreDelegate
+	PurgeFn      purgeDelegate
+	GetAsOfFn    getAsOfDelegate
+	VersionsFn   versionsDelegate
+	CompactFn    compactDelegate
+	ListFn       listDelegate
+	CloseFn      closeDelegate
 }
 
 func (m *MockStorage) Get(key int64) (*common.User, error) {
@@ -22,14 +50,110 @@ func (m *MockStorage) Get(key int64) (*common.User, error) {
 	return nil, nil
 }
 
-func (m *MockStorage) Set(value string) (int64, error) {
+func (m *MockStorage) Set(user *common.User) (int64, error) {
 	if m.SetFn != nil {
-		return m.SetFn(value)
+		return m.SetFn(user)
+	}
+
+	return 0, nil
+}
+
+func (m *MockStorage) SetBatch(users []*common.User) ([]int64, error) {
+	if m.SetBatchFn != nil {
+		return m.SetBatchFn(users)
+	}
+
+	return nil, nil
+}
+
+func (m *MockStorage) GetMulti(keys []int64) (map[int64]*common.User, error) {
+	if m.GetMultiFn != nil {
+		return m.GetMultiFn(keys)
+	}
+
+	return nil, nil
+}
+
+func (m *MockStorage) FindByName(name string, prefix bool) ([]*common.User, error) {
+	if m.FindByNameFn != nil {
+		return m.FindByNameFn(name, prefix)
+	}
+
+	return nil, nil
+}
+
+func (m *MockStorage) Update(user *common.User, version int64) (*common.User, error) {
+	if m.UpdateFn != nil {
+		return m.UpdateFn(user, version)
+	}
+
+	return nil, nil
+}
+
+func (m *MockStorage) Delete(key int64, version int64) (*common.User, error) {
+	if m.DeleteFn != nil {
+		return m.DeleteFn(key, version)
+	}
+
+	return nil, nil
+}
+
+func (m *MockStorage) SoftDelete(key int64, version int64) (*common.User, error) {
+	if m.SoftDeleteFn != nil {
+		return m.SoftDeleteFn(key, version)
+	}
+
+	return nil, nil
+}
+
+func (m *MockStorage) Restore(key int64) (*common.User, *common.User, error) {
+	if m.RestoreFn != nil {
+		return m.RestoreFn(key)
+	}
+
+	return nil, nil, nil
+}
+
+func (m *MockStorage) Purge(before time.Time) (int, error) {
+	if m.PurgeFn != nil {
+		return m.PurgeFn(before)
+	}
+
+	return 0, nil
+}
+
+func (m *MockStorage) GetAsOf(key int64, at time.Time) (*common.User, error) {
+	if m.GetAsOfFn != nil {
+		return m.GetAsOfFn(key, at)
+	}
+
+	return nil, nil
+}
+
+func (m *MockStorage) Versions(key int64) ([]*common.User, error) {
+	if m.VersionsFn != nil {
+		return m.VersionsFn(key)
+	}
+
+	return nil, nil
+}
+
+func (m *MockStorage) CompactVersions(before time.Time) (int, error) {
+	if m.CompactFn != nil {
+		return m.CompactFn(before)
 	}
 
 	return 0, nil
 }
 
+func (m *MockStorage) List(cursor string, limit int) ([]*common.User, string, error) {
+	if m.ListFn != nil {
+		return m.ListFn(cursor, limit)
+	}
+
+	return nil, "", nil
+}
+
 func (m *MockStorage) Close() error {
 	if m.CloseFn != nil {
 		return m.CloseFn()
@@ -0,0 +1,103 @@
+package purge
+
+import (
+	"sync"
+	"time"
+
+	"github.com/Aleksao998/LightningUserVault/core/storage"
+	"go.uber.org/zap"
+)
+
+// defaultInterval is the longest time between two purges when the interval is not configured
+const defaultInterval = time.Hour
+
+type Config struct {
+	// Retention is how long soft deleted users are kept before they are permanently removed
+	Retention time.Duration
+
+	// Interval is the time between two purges, it defaults to the retention capped at an hour
+	Interval time.Duration
+}
+
+// Purger periodically removes soft deleted users from storage once their retention has passed
+type Purger struct {
+	vault  storage.Storage
+	logger *zap.Logger
+	config Config
+
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewPurger creates a new Purger for the given storage, it does not run until started
+func NewPurger(logger *zap.Logger, vault storage.Storage, config Config) *Purger {
+	if config.Interval <= 0 {
+		config.Interval = defaultInterval
+		if config.Retention > 0 && config.Retention < config.Interval {
+			config.Interval = config.Retention
+		}
+	}
+
+	return &Purger{
+		vault:  vault,
+		logger: logger,
+		config: config,
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+}
+
+// Start runs purges in the background until the purger is closed
+func (p *Purger) Start() {
+	p.logger.Info("Starting purger",
+		zap.Duration("retention", p.config.Retention),
+		zap.Duration("interval", p.config.Interval),
+	)
+
+	go p.run()
+}
+
+// run purges once right away and then on every tick of the interval
+func (p *Purger) run() {
+	defer close(p.done)
+
+	ticker := time.NewTicker(p.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		// Errors are logged and the purge is retried on the next tick
+		_, _ = p.Purge()
+
+		select {
+		case <-ticker.C:
+		case <-p.stop:
+			return
+		}
+	}
+}
+
+// Purge permanently removes users soft deleted longer than the retention ago and returns how many were removed
+func (p *Purger) Purge() (int, error) {
+	purged, err := p.vault.Purge(time.Now().Add(-p.config.Retention))
+	if err != nil {
+		p.logger.Error("Failed to purge deleted users", zap.Error(err))
+
+		return purged, err
+	}
+
+	if purged > 0 {
+		p.logger.Info("Purged deleted users", zap.Int("count", purged))
+	}
+
+	return purged, nil
+}
+
+// Close stops the background purges and waits for a running purge to finish
+func (p *Purger) Close() {
+	p.stopOnce.Do(func() {
+		close(p.stop)
+	})
+
+	<-p.done
+}
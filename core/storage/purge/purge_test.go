@@ -0,0 +1,93 @@
+package purge
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Aleksao998/LightningUserVault/core/storage/mocks"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+var errInternal = errors.New("internal error")
+
+// TestPurger_PurgeBeforeRetention tests that users deleted longer than the retention ago are purged
+func TestPurger_PurgeBeforeRetention(t *testing.T) {
+	t.Parallel()
+
+	var before time.Time
+
+	vault := &mocks.MockStorage{
+		PurgeFn: func(b time.Time) (int, error) {
+			before = b
+
+			return 3, nil
+		},
+	}
+
+	purger := NewPurger(zap.NewNop(), vault, Config{Retention: 24 * time.Hour})
+
+	start := time.Now()
+
+	purged, err := purger.Purge()
+	assert.NoError(t, err)
+	assert.Equal(t, 3, purged)
+	assert.WithinDuration(t, start.Add(-24*time.Hour), before, time.Second)
+}
+
+// TestPurger_PurgeError tests that storage errors are returned
+func TestPurger_PurgeError(t *testing.T) {
+	t.Parallel()
+
+	vault := &mocks.MockStorage{
+		PurgeFn: func(before time.Time) (int, error) {
+			return 0, errInternal
+		},
+	}
+
+	purger := NewPurger(zap.NewNop(), vault, Config{Retention: time.Hour})
+
+	_, err := purger.Purge()
+	assert.ErrorIs(t, err, errInternal)
+}
+
+// TestPurger_DefaultInterval tests that the interval is capped by the retention
+func TestPurger_DefaultInterval(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, defaultInterval, NewPurger(zap.NewNop(), nil, Config{Retention: 720 * time.Hour}).config.Interval)
+	assert.Equal(t, time.Minute, NewPurger(zap.NewNop(), nil, Config{Retention: time.Minute}).config.Interval)
+	assert.Equal(t, time.Second, NewPurger(zap.NewNop(), nil, Config{Retention: time.Hour, Interval: time.Second}).config.Interval)
+}
+
+// TestPurger_StartAndClose tests that purges run periodically until the purger is closed
+func TestPurger_StartAndClose(t *testing.T) {
+	t.Parallel()
+
+	var calls int32
+
+	vault := &mocks.MockStorage{
+		PurgeFn: func(before time.Time) (int, error) {
+			atomic.AddInt32(&calls, 1)
+
+			return 0, nil
+		},
+	}
+
+	purger := NewPurger(zap.NewNop(), vault, Config{Retention: time.Hour, Interval: 10 * time.Millisecond})
+	purger.Start()
+
+	assert.Eventually(t, func() bool {
+		return atomic.LoadInt32(&calls) >= 3
+	}, time.Second, 5*time.Millisecond)
+
+	purger.Close()
+
+	// No purges run after closing
+	stopped := atomic.LoadInt32(&calls)
+
+	time.Sleep(30 * time.Millisecond)
+	assert.Equal(t, stopped, atomic.LoadInt32(&calls))
+}
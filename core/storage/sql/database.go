@@ -11,6 +11,14 @@ import (
 // facilitates easier testing by enabling the mocking of these operations
 type DBHandler interface {
 	First(out interface{}, where ...interface{}) *gorm.DB
+	Find(dest interface{}, conds ...interface{}) *gorm.DB
 	Create(value interface{}) *gorm.DB
+	CreateInBatches(value interface{}, batchSize int) *gorm.DB
+	Updates(values interface{}) *gorm.DB
+	Delete(value interface{}, conds ...interface{}) *gorm.DB
+	Where(query interface{}, args ...interface{}) *gorm.DB
+	Model(value interface{}) *gorm.DB
+	Exec(sql string, values ...interface{}) *gorm.DB
+	Transaction(fc func(tx *gorm.DB) error, opts ...*sql.TxOptions) error
 	DB() (*sql.DB, error)
 }
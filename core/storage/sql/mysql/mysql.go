@@ -0,0 +1,107 @@
+package mysql
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Aleksao998/LightningUserVault/core/storage/sql"
+	"github.com/Aleksao998/LightningUserVault/core/storage/sql/postgresql"
+	"go.uber.org/zap"
+	driver "gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+// datetimePrecision keeps times in microseconds, the precision of the times written by the storage
+var datetimePrecision = 6
+
+// compactVersionsQuery removes every version which was replaced by a newer version written at or before a time.
+// MySQL does not allow a subquery on the table a DELETE removes from, so the newer versions are joined instead
+const compactVersionsQuery = "DELETE replaced FROM user_versions AS replaced JOIN user_versions AS newer " +
+	"ON newer.user_id = replaced.user_id AND newer.version > replaced.version AND newer.updated_at <= ?"
+
+// Options configures optional behaviour of the storage
+type Options struct {
+	// Outbox records created users in the outbox table
+	Outbox bool
+
+	// KeepVersions stores an immutable copy of every written version of a user in the user_versions table
+	KeepVersions bool
+
+	// Webhooks creates the tables keeping webhooks and their delivery queue
+	Webhooks bool
+
+	// Audit creates the table keeping the audit log of user writes
+	Audit bool
+
+	// Charset is the character set of created tables
+	Charset string
+
+	// Collation is the collation of created tables, it decides how user names are compared and searched
+	Collation string
+}
+
+// Storage stores users in a MySQL or MariaDB database, using the same models and queries as the PostgreSQL storage.
+// Outbox messages are claimed with SKIP LOCKED, which requires MySQL 8.0 or MariaDB 10.6
+type Storage struct {
+	*postgresql.Storage
+
+	db     sql.DBHandler
+	logger *zap.Logger
+}
+
+// NewStorage initializes a new Storage instance with a database at the given DSN
+func NewStorage(logger *zap.Logger, dsn string, options Options) (*Storage, error) {
+	db, err := gorm.Open(driver.New(driver.Config{
+		DSN:                      dsn,
+		DefaultDatetimePrecision: &datetimePrecision,
+	}), &gorm.Config{})
+	if err != nil {
+		logger.Error("Failed to open MySQL database", zap.Error(err))
+
+		return nil, err
+	}
+
+	tableOptions := fmt.Sprintf("CHARSET=%s COLLATE=%s", options.Charset, options.Collation)
+
+	if err = postgresql.Migrate(logger, db.Set("gorm:table_options", tableOptions), storageOptions(options)); err != nil {
+		return nil, err
+	}
+
+	logger.Info("Successfully initialized MySQL storage")
+
+	return newStorage(logger, db, options), nil
+}
+
+// newStorage creates a Storage on top of an already opened and migrated database
+func newStorage(logger *zap.Logger, db sql.DBHandler, options Options) *Storage {
+	return &Storage{
+		Storage: postgresql.NewStorageWithDB(logger, db, storageOptions(options)),
+		db:      db,
+		logger:  logger,
+	}
+}
+
+// storageOptions returns the options of the shared SQL storage
+func storageOptions(options Options) postgresql.Options {
+	return postgresql.Options{
+		Outbox:       options.Outbox,
+		KeepVersions: options.KeepVersions,
+		Webhooks:     options.Webhooks,
+		Audit:        options.Audit,
+	}
+}
+
+// CompactVersions removes every version which was replaced by a newer version written at or before the given time,
+// using a single statement
+func (m *Storage) CompactVersions(before time.Time) (int, error) {
+	result := m.db.Exec(compactVersionsQuery, before)
+	if result.Error != nil {
+		m.logger.Error("Failed to compact user versions in database", zap.Time("before", before), zap.Error(result.Error))
+
+		return 0, result.Error
+	}
+
+	m.logger.Debug("Successfully compacted user versions in database", zap.Int64("count", result.RowsAffected))
+
+	return int(result.RowsAffected), nil
+}
@@ -0,0 +1,75 @@
+package mysql
+
+import (
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/Aleksao998/LightningUserVault/core/storage/mocks"
+	"github.com/Aleksao998/LightningUserVault/core/storage/sql"
+	"github.com/Aleksao998/LightningUserVault/core/storage/sql/sqltest"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	driver "gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+var errInternal = errors.New("internal db error")
+
+// TestMySQL_Conformance runs the shared SQL storage conformance suite against the storage
+func TestMySQL_Conformance(t *testing.T) {
+	t.Parallel()
+
+	sqltest.RunConformance(t, func(db sql.DBHandler) sqltest.Storage {
+		return newStorage(zap.NewNop(), db, Options{})
+	})
+}
+
+// TestMySQL_CompactVersions tests that replaced versions are removed with a self join supported by MySQL
+func TestMySQL_CompactVersions(t *testing.T) {
+	t.Parallel()
+
+	sqlDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error creating sql mock, %v", err)
+	}
+
+	defer sqlDB.Close()
+
+	db, err := gorm.Open(driver.New(driver.Config{Conn: sqlDB, SkipInitializeWithVersion: true}), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("error opening gorm, %v", err)
+	}
+
+	before := time.Date(2023, 9, 1, 12, 0, 0, 0, time.UTC)
+
+	mock.ExpectExec(regexp.QuoteMeta(compactVersionsQuery)).
+		WithArgs(before).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+
+	storage := newStorage(zap.NewNop(), db, Options{KeepVersions: true})
+
+	compacted, err := storage.CompactVersions(before)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, compacted)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestMySQL_CompactVersionsError tests the scenario where an error occurs while compacting versions
+func TestMySQL_CompactVersionsError(t *testing.T) {
+	t.Parallel()
+
+	mockDB := &mocks.MockSQLdb{
+		ExecFn: func(sql string, values ...interface{}) *gorm.DB {
+			return &gorm.DB{Error: errInternal}
+		},
+	}
+
+	storage := newStorage(zap.NewNop(), mockDB, Options{KeepVersions: true})
+
+	compacted, err := storage.CompactVersions(time.Now())
+	assert.Equal(t, errInternal, err)
+	assert.Equal(t, 0, compacted)
+}
@@ -0,0 +1,115 @@
+package postgresql
+
+import (
+	"errors"
+	"time"
+
+	"github.com/Aleksao998/LightningUserVault/core/audit"
+	"github.com/Aleksao998/LightningUserVault/core/common"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// AuditEntry is a recorded write to a user, the per-user history is selected by the user and entry IDs
+type AuditEntry struct {
+	ID        int64           `gorm:"primaryKey;index:idx_audit_entries_user,priority:2"`
+	Time      time.Time       `gorm:"column:recorded_at;not null;uniqueIndex"`
+	Actor     string          `gorm:"type:varchar(255)"`
+	Operation audit.Operation `gorm:"type:varchar(16);not null"`
+	RequestID string          `gorm:"type:varchar(255)"`
+	UserID    int64           `gorm:"not null;index:idx_audit_entries_user,priority:1"`
+	Before    *common.User    `gorm:"type:text;serializer:json"`
+	After     *common.User    `gorm:"type:text;serializer:json"`
+}
+
+// entry converts the model back into an audit entry
+func (a AuditEntry) entry() *audit.Entry {
+	return &audit.Entry{
+		ID:        a.ID,
+		Time:      a.Time,
+		Actor:     a.Actor,
+		Operation: a.Operation,
+		RequestID: a.RequestID,
+		UserID:    a.UserID,
+		Before:    a.Before,
+		After:     a.After,
+	}
+}
+
+// RecordAudit stores the entry with a time after the last entry, filling its ID and time
+func (p *Storage) RecordAudit(entry *audit.Entry) error {
+	p.auditLock.Lock()
+	defer p.auditLock.Unlock()
+
+	var last AuditEntry
+
+	if err := p.db.Model(&AuditEntry{}).Order("id DESC").First(&last).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		p.logger.Error("Failed to retrieve last audit entry from database", zap.Error(err))
+
+		return err
+	}
+
+	// Entries are paged by time, so it never repeats or goes back even if the clock does
+	row := AuditEntry{
+		Time:      audit.NextTime(last.Time, time.Microsecond),
+		Actor:     entry.Actor,
+		Operation: entry.Operation,
+		RequestID: entry.RequestID,
+		UserID:    entry.UserID,
+		Before:    entry.Before,
+		After:     entry.After,
+	}
+
+	if err := p.db.Create(&row).Error; err != nil {
+		p.logger.Error("Failed to record audit entry in database", zap.Int64("userID", entry.UserID), zap.Error(err))
+
+		return err
+	}
+
+	entry.ID = row.ID
+	entry.Time = row.Time
+
+	return nil
+}
+
+// AuditHistory returns up to limit entries of the user with the given ID, newest first
+func (p *Storage) AuditHistory(userID int64, limit int) ([]*audit.Entry, error) {
+	var rows []AuditEntry
+
+	if err := p.db.Where("user_id = ?", userID).Order("id DESC").Limit(limit).Find(&rows).Error; err != nil {
+		p.logger.Error("Failed to retrieve audit history from database", zap.Int64("userID", userID), zap.Error(err))
+
+		return nil, err
+	}
+
+	return auditEntries(rows), nil
+}
+
+// AuditSince returns up to limit entries recorded after the given time, oldest first
+func (p *Storage) AuditSince(since time.Time, limit int) ([]*audit.Entry, error) {
+	var rows []AuditEntry
+
+	query := p.db.Model(&AuditEntry{})
+
+	if !since.IsZero() {
+		query = query.Where("recorded_at > ?", since.UTC())
+	}
+
+	if err := query.Order("recorded_at").Limit(limit).Find(&rows).Error; err != nil {
+		p.logger.Error("Failed to retrieve audit entries from database", zap.Time("since", since), zap.Error(err))
+
+		return nil, err
+	}
+
+	return auditEntries(rows), nil
+}
+
+// auditEntries converts the models back into audit entries
+func auditEntries(rows []AuditEntry) []*audit.Entry {
+	entries := make([]*audit.Entry, 0, len(rows))
+	for _, row := range rows {
+		entries = append(entries, row.entry())
+	}
+
+	return entries
+}
@@ -0,0 +1,92 @@
+package postgresql
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/Aleksao998/LightningUserVault/core/common"
+	"github.com/Aleksao998/LightningUserVault/core/events"
+	"github.com/Aleksao998/LightningUserVault/core/outbox"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// OutboxMessage is an event of a user change, inserted in the same transaction as the change
+type OutboxMessage struct {
+	ID        int64       `gorm:"primaryKey"`
+	EventType events.Type `gorm:"type:varchar(32);not null"`
+	UserID    int64       `gorm:"not null"`
+	Payload   []byte
+	CreatedAt time.Time
+}
+
+// newOutboxMessage creates the outbox message of a change to the user
+func newOutboxMessage(eventType events.Type, user *common.User) (OutboxMessage, error) {
+	payload, err := json.Marshal(user)
+	if err != nil {
+		return OutboxMessage{}, err
+	}
+
+	return OutboxMessage{
+		EventType: eventType,
+		UserID:    user.ID,
+		Payload:   payload,
+		CreatedAt: user.UpdatedAt,
+	}, nil
+}
+
+// Drain claims up to limit outbox messages, oldest first, passes them to send and deletes them in the same transaction.
+// Claimed rows are locked and skipped by concurrent drains, so several relays never send the same message at once
+func (p *Storage) Drain(limit int, send func(messages []*outbox.Message) error) (int, error) {
+	drained := 0
+
+	err := p.db.Transaction(func(tx *gorm.DB) error {
+		var rows []OutboxMessage
+
+		result := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).Order("id").Limit(limit).Find(&rows)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		if len(rows) == 0 {
+			return nil
+		}
+
+		messages := make([]*outbox.Message, 0, len(rows))
+		ids := make([]int64, 0, len(rows))
+
+		for _, row := range rows {
+			messages = append(messages, &outbox.Message{
+				ID:        row.ID,
+				Type:      row.EventType,
+				UserID:    row.UserID,
+				Payload:   row.Payload,
+				CreatedAt: row.CreatedAt,
+			})
+
+			ids = append(ids, row.ID)
+		}
+
+		if err := send(messages); err != nil {
+			return err
+		}
+
+		if err := tx.Delete(&OutboxMessage{}, ids).Error; err != nil {
+			return err
+		}
+
+		drained = len(rows)
+
+		return nil
+	})
+	if err != nil {
+		p.logger.Error("Failed to drain outbox", zap.Error(err))
+
+		return 0, err
+	}
+
+	p.logger.Debug("Successfully drained outbox", zap.Int("count", drained))
+
+	return drained, nil
+}
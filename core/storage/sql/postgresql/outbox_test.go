@@ -0,0 +1,207 @@
+package postgresql
+
+import (
+	"encoding/json"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/Aleksao998/LightningUserVault/core/common"
+	"github.com/Aleksao998/LightningUserVault/core/events"
+	"github.com/Aleksao998/LightningUserVault/core/outbox"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+const (
+	// insertUserQuery is the start of the insert of users
+	insertUserQuery = `INSERT INTO "users"`
+
+	// insertOutboxQuery is the start of the insert of outbox messages
+	insertOutboxQuery = `INSERT INTO "outbox_messages"`
+
+	// claimOutboxQuery is the query locking the oldest outbox messages
+	claimOutboxQuery = `SELECT * FROM "outbox_messages" ORDER BY id LIMIT 10 FOR UPDATE SKIP LOCKED`
+
+	// deleteOutboxQuery is the delete of relayed outbox messages
+	deleteOutboxQuery = `DELETE FROM "outbox_messages" WHERE "outbox_messages"."id" IN ($1,$2)`
+)
+
+// outboxColumns are the columns of the outbox_messages table
+var outboxColumns = []string{"id", "event_type", "user_id", "payload", "created_at"}
+
+// TestPostgres_SetWithOutbox tests the scenario where a created user and its outbox message are inserted in one transaction
+func TestPostgres_SetWithOutbox(t *testing.T) {
+	t.Parallel()
+
+	db, mock := createMockedGorm(t)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(insertUserQuery)).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+	mock.ExpectQuery(regexp.QuoteMeta(insertOutboxQuery)).
+		WithArgs(events.Created, 7, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectCommit()
+
+	storage := &Storage{
+		db:     db,
+		logger: zap.NewNop(),
+		outbox: true,
+	}
+
+	user := &common.User{Name: mockUserName}
+
+	id, err := storage.Set(user)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(7), id)
+	assert.Equal(t, int64(7), user.ID)
+	assert.Equal(t, int64(1), user.Version)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestPostgres_SetWithOutboxError tests the scenario where the user is rolled back when its outbox message can not be inserted
+func TestPostgres_SetWithOutboxError(t *testing.T) {
+	t.Parallel()
+
+	db, mock := createMockedGorm(t)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(insertUserQuery)).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+	mock.ExpectQuery(regexp.QuoteMeta(insertOutboxQuery)).
+		WillReturnError(errInternal)
+	mock.ExpectRollback()
+
+	storage := &Storage{
+		db:     db,
+		logger: zap.NewNop(),
+		outbox: true,
+	}
+
+	id, err := storage.Set(&common.User{Name: mockUserName})
+	assert.Equal(t, errInternal, err)
+	assert.Equal(t, int64(0), id)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestPostgres_SetBatchWithOutbox tests the scenario where every user of a batch gets an outbox message in the same transaction
+func TestPostgres_SetBatchWithOutbox(t *testing.T) {
+	t.Parallel()
+
+	db, mock := createMockedGorm(t)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(insertUserQuery)).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1).AddRow(2))
+	mock.ExpectQuery(regexp.QuoteMeta(insertOutboxQuery)).
+		WithArgs(
+			events.Created, 1, sqlmock.AnyArg(), sqlmock.AnyArg(),
+			events.Created, 2, sqlmock.AnyArg(), sqlmock.AnyArg(),
+		).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1).AddRow(2))
+	mock.ExpectCommit()
+
+	storage := &Storage{
+		db:     db,
+		logger: zap.NewNop(),
+		outbox: true,
+	}
+
+	ids, err := storage.SetBatch([]*common.User{{Name: "User-1"}, {Name: "User-2"}})
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{1, 2}, ids)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestPostgres_DrainSuccessfully tests the scenario where claimed outbox messages are sent and deleted
+func TestPostgres_DrainSuccessfully(t *testing.T) {
+	t.Parallel()
+
+	db, mock := createMockedGorm(t)
+
+	createdAt := time.Date(2023, 9, 1, 12, 0, 0, 0, time.UTC)
+	payload, err := json.Marshal(&common.User{ID: 1, Name: mockUserName})
+	assert.NoError(t, err)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(claimOutboxQuery)).
+		WillReturnRows(sqlmock.NewRows(outboxColumns).
+			AddRow(3, events.Created, 1, payload, createdAt).
+			AddRow(4, events.Created, 2, payload, createdAt))
+	mock.ExpectExec(regexp.QuoteMeta(deleteOutboxQuery)).
+		WithArgs(3, 4).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	storage := &Storage{
+		db:     db,
+		logger: zap.NewNop(),
+	}
+
+	var sent []*outbox.Message
+
+	drained, err := storage.Drain(10, func(messages []*outbox.Message) error {
+		sent = messages
+
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, drained)
+	assert.Equal(t, []*outbox.Message{
+		{ID: 3, Type: events.Created, UserID: 1, Payload: payload, CreatedAt: createdAt},
+		{ID: 4, Type: events.Created, UserID: 2, Payload: payload, CreatedAt: createdAt},
+	}, sent)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestPostgres_DrainSendError tests the scenario where messages are kept in the outbox when sending them fails
+func TestPostgres_DrainSendError(t *testing.T) {
+	t.Parallel()
+
+	db, mock := createMockedGorm(t)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(claimOutboxQuery)).
+		WillReturnRows(sqlmock.NewRows(outboxColumns).AddRow(3, events.Created, 1, nil, time.Now()))
+	mock.ExpectRollback()
+
+	storage := &Storage{
+		db:     db,
+		logger: zap.NewNop(),
+	}
+
+	drained, err := storage.Drain(10, func(messages []*outbox.Message) error {
+		return errInternal
+	})
+	assert.Equal(t, errInternal, err)
+	assert.Equal(t, 0, drained)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestPostgres_DrainEmpty tests the scenario where nothing is sent when the outbox is empty
+func TestPostgres_DrainEmpty(t *testing.T) {
+	t.Parallel()
+
+	db, mock := createMockedGorm(t)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(claimOutboxQuery)).
+		WillReturnRows(sqlmock.NewRows(outboxColumns))
+	mock.ExpectCommit()
+
+	storage := &Storage{
+		db:     db,
+		logger: zap.NewNop(),
+	}
+
+	drained, err := storage.Drain(10, func(messages []*outbox.Message) error {
+		t.Fatalf("send called for an empty outbox")
+
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, drained)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
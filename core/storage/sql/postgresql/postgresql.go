@@ -2,28 +2,111 @@ package postgresql
 
 import (
 	"errors"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/Aleksao998/LightningUserVault/core/common"
+	"github.com/Aleksao998/LightningUserVault/core/events"
 	"github.com/Aleksao998/LightningUserVault/core/storage/sql"
 	"go.uber.org/zap"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
 
-var errUserNotFound = errors.New("user not found")
+const (
+	// idCursorLength is the length of the encoded user ID used as a list cursor
+	idCursorLength = 8
+
+	// firstVersion is the version of newly stored users
+	firstVersion int64 = 1
+
+	// createBatchSize is the largest number of rows inserted by a single statement. Every column of a row is a bind parameter,
+	// so it keeps the widest model well under the parameter limits of PostgreSQL and MySQL (65535) and SQLite (32766)
+	createBatchSize = 1000
+
+	// notDeleted is the condition selecting users which are not soft deleted
+	notDeleted = "deleted_at IS NULL"
+)
+
+// likeEscaper escapes LIKE wildcards using '!', which works the same way on every SQL database
+var likeEscaper = strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
 
 type User struct {
 	ID   int64  `gorm:"primaryKey"`
-	Name string `gorm:"type:varchar(255)"`
+	Name string `gorm:"type:varchar(255);index"`
+
+	// Email is a pointer so that updates also write an emptied email, gorm skips zero fields otherwise
+	Email      *string           `gorm:"type:varchar(255)"`
+	Attributes map[string]string `gorm:"type:text;serializer:json"`
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	Version    int64      `gorm:"not null;default:1"`
+	DeletedAt  *time.Time `gorm:"index"`
+}
+
+// newUser converts a user into its database model, all writable fields are set to non-zero values
+func newUser(user *common.User) User {
+	email := user.Email
+
+	attributes := user.Attributes
+	if attributes == nil {
+		attributes = map[string]string{}
+	}
+
+	return User{
+		ID:         user.ID,
+		Name:       user.Name,
+		Email:      &email,
+		Attributes: attributes,
+	}
+}
+
+// fillUser copies the fields assigned on insert from the database model to the user
+func fillUser(user *common.User, row User) {
+	user.ID = row.ID
+	user.CreatedAt = row.CreatedAt
+	user.UpdatedAt = row.UpdatedAt
+	user.Version = row.Version
+	user.DeletedAt = row.DeletedAt
+}
+
+// now returns the current time in the precision kept by the database
+func now() time.Time {
+	return time.Now().UTC().Truncate(time.Microsecond)
+}
+
+// Options configures optional behaviour of the storage
+type Options struct {
+	// Outbox records created users in the outbox table
+	Outbox bool
+
+	// KeepVersions stores an immutable copy of every written version of a user in the user_versions table
+	KeepVersions bool
+
+	// Webhooks creates the tables keeping webhooks and their delivery queue
+	Webhooks bool
+
+	// Audit creates the table keeping the audit log of user writes
+	Audit bool
 }
 
 type Storage struct {
 	db     sql.DBHandler
 	logger *zap.Logger
+
+	// outbox is a flag which represents if created users are recorded in the outbox table
+	outbox bool
+
+	// versions is a flag which represents if every written version of a user is kept in the user_versions table
+	versions bool
+
+	// auditLock serializes audit records, so their times are strictly increasing
+	auditLock sync.Mutex
 }
 
 // NewStorage initializes a new Storage instance with a database at the given path
-func NewStorage(logger *zap.Logger, connStr string) (*Storage, error) {
+func NewStorage(logger *zap.Logger, connStr string, options Options) (*Storage, error) {
 	db, err := gorm.Open(postgres.Open(connStr), &gorm.Config{})
 	if err != nil {
 		logger.Error("Failed to open PostgreSQL database", zap.String("connectionString", connStr), zap.Error(err))
@@ -31,20 +114,69 @@ func NewStorage(logger *zap.Logger, connStr string) (*Storage, error) {
 		return nil, err
 	}
 
+	if err = Migrate(logger, db, options); err != nil {
+		return nil, err
+	}
+
+	logger.Info("Successfully initialized PostgreSQL storage")
+
+	return NewStorageWithDB(logger, db, options), nil
+}
+
+// NewStorageWithDB creates a Storage on top of an already opened and migrated database,
+// so other SQL databases supported by gorm can share the same queries
+func NewStorageWithDB(logger *zap.Logger, db sql.DBHandler, options Options) *Storage {
+	return &Storage{
+		db:       db,
+		logger:   logger,
+		outbox:   options.Outbox,
+		versions: options.KeepVersions,
+	}
+}
+
+// Migrate creates the tables, missing columns and missing indexes used with the given options
+func Migrate(logger *zap.Logger, db *gorm.DB, options Options) error {
 	// AutoMigrate will ONLY create tables, missing columns and missing indexes
-	err = db.AutoMigrate(&User{})
+	err := db.AutoMigrate(&User{})
 	if err != nil {
 		logger.Error("Failed to auto-migrate User schema", zap.Error(err))
 
-		return nil, err
+		return err
 	}
 
-	logger.Info("Successfully initialized PostgreSQL storage")
+	if options.Outbox {
+		if err = db.AutoMigrate(&OutboxMessage{}); err != nil {
+			logger.Error("Failed to auto-migrate OutboxMessage schema", zap.Error(err))
 
-	return &Storage{
-		db:     db,
-		logger: logger,
-	}, nil
+			return err
+		}
+	}
+
+	if options.KeepVersions {
+		if err = db.AutoMigrate(&UserVersion{}); err != nil {
+			logger.Error("Failed to auto-migrate UserVersion schema", zap.Error(err))
+
+			return err
+		}
+	}
+
+	if options.Webhooks {
+		if err = db.AutoMigrate(&Webhook{}, &WebhookDelivery{}, &WebhookCursor{}); err != nil {
+			logger.Error("Failed to auto-migrate webhook schemas", zap.Error(err))
+
+			return err
+		}
+	}
+
+	if options.Audit {
+		if err = db.AutoMigrate(&AuditEntry{}); err != nil {
+			logger.Error("Failed to auto-migrate AuditEntry schema", zap.Error(err))
+
+			return err
+		}
+	}
+
+	return nil
 }
 
 // Get retrieves the user for a given ID
@@ -56,7 +188,7 @@ func (p *Storage) Get(id int64) (*common.User, error) {
 		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
 			p.logger.Warn("User not found", zap.Int64("ID", id))
 
-			return nil, errUserNotFound
+			return nil, common.ErrUserNotFound
 		}
 
 		p.logger.Error("Failed to retrieve user from database", zap.Int64("ID", id), zap.Error(result.Error))
@@ -64,27 +196,456 @@ func (p *Storage) Get(id int64) (*common.User, error) {
 		return nil, result.Error
 	}
 
+	if user.DeletedAt != nil {
+		p.logger.Debug("Retrieved user is deleted", zap.Int64("ID", id))
+
+		return nil, common.ErrUserDeleted
+	}
+
 	p.logger.Debug("Successfully retrieved user from database", zap.Int64("ID", user.ID))
 
 	return &user, nil
 }
 
-// Set stores a user with the given name and returns the ID
-func (p *Storage) Set(name string) (int64, error) {
-	user := User{Name: name}
+// GetMulti retrieves all users with the given IDs using a single query, soft deleted users are left out
+func (p *Storage) GetMulti(ids []int64) (map[int64]*common.User, error) {
+	var found []*common.User
+
+	result := p.db.Find(&found, ids)
+	if result.Error != nil {
+		p.logger.Error("Failed to retrieve users from database", zap.Int("ids", len(ids)), zap.Error(result.Error))
+
+		return nil, result.Error
+	}
+
+	users := make(map[int64]*common.User, len(found))
+
+	for _, user := range found {
+		if user.DeletedAt != nil {
+			continue
+		}
+
+		users[user.ID] = user
+	}
+
+	p.logger.Debug("Successfully retrieved users from database", zap.Int("ids", len(ids)), zap.Int("found", len(users)))
+
+	return users, nil
+}
+
+// FindByName returns all users with the given name, or with names starting with it when prefix is set.
+// Both lookups are served by the index on the name column
+func (p *Storage) FindByName(name string, prefix bool) ([]*common.User, error) {
+	query, arg := "name = ?", name
+	if prefix {
+		query, arg = "name LIKE ? ESCAPE '!'", likeEscaper.Replace(name)+"%"
+	}
+
+	users := make([]*common.User, 0)
 
-	result := p.db.Create(&user)
+	result := p.db.Where(query, arg).Where(notDeleted).Order("id").Find(&users)
 	if result.Error != nil {
-		p.logger.Error("Failed to store user in database", zap.String("Name", name), zap.Error(result.Error))
+		p.logger.Error("Failed to find users by name in database", zap.String("name", name), zap.Error(result.Error))
 
-		return 0, result.Error
+		return nil, result.Error
 	}
 
+	p.logger.Debug("Successfully found users by name in database", zap.String("name", name), zap.Int("count", len(users)))
+
+	return users, nil
+}
+
+// Set stores a new user, fills its ID, timestamps and version and returns the ID
+func (p *Storage) Set(user *common.User) (int64, error) {
+	row := newUser(user)
+	row.CreatedAt = now()
+	row.UpdatedAt = row.CreatedAt
+	row.Version = firstVersion
+
+	err := p.create(&row, func() []*common.User {
+		fillUser(user, row)
+
+		return []*common.User{user}
+	})
+	if err != nil {
+		p.logger.Error("Failed to store user in database", zap.String("Name", user.Name), zap.Error(err))
+
+		return 0, err
+	}
+
+	fillUser(user, row)
+
 	p.logger.Debug("Successfully stored user in database", zap.Int64("ID", user.ID))
 
 	return user.ID, nil
 }
 
+// SetBatch stores the given users, fills their IDs, timestamps and versions and returns the IDs.
+// All users are inserted with a single statement inside one transaction, so either all or none are stored
+func (p *Storage) SetBatch(users []*common.User) ([]int64, error) {
+	createdAt := now()
+
+	rows := make([]User, 0, len(users))
+	for _, user := range users {
+		row := newUser(user)
+		row.CreatedAt = createdAt
+		row.UpdatedAt = createdAt
+		row.Version = firstVersion
+
+		rows = append(rows, row)
+	}
+
+	err := p.create(&rows, func() []*common.User {
+		for i, row := range rows {
+			fillUser(users[i], row)
+		}
+
+		return users
+	})
+	if err != nil {
+		p.logger.Error("Failed to store batch of users in database", zap.Int("size", len(users)), zap.Error(err))
+
+		return nil, err
+	}
+
+	ids := make([]int64, 0, len(rows))
+	for i, row := range rows {
+		fillUser(users[i], row)
+
+		ids = append(ids, row.ID)
+	}
+
+	p.logger.Debug("Successfully stored batch of users in database", zap.Int("size", len(ids)))
+
+	return ids, nil
+}
+
+// create inserts the rows together with the outbox messages and versions of the inserted users in a single transaction,
+// so a message or version is recorded exactly when its user is committed. inserted fills the users from the inserted rows
+func (p *Storage) create(rows interface{}, inserted func() []*common.User) error {
+	if !p.outbox && !p.versions {
+		return p.db.CreateInBatches(rows, createBatchSize).Error
+	}
+
+	return p.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.CreateInBatches(rows, createBatchSize).Error; err != nil {
+			return err
+		}
+
+		users := inserted()
+
+		if p.outbox {
+			messages := make([]OutboxMessage, 0, len(users))
+
+			for _, user := range users {
+				message, err := newOutboxMessage(events.Created, user)
+				if err != nil {
+					return err
+				}
+
+				messages = append(messages, message)
+			}
+
+			if err := tx.CreateInBatches(&messages, createBatchSize).Error; err != nil {
+				return err
+			}
+		}
+
+		return p.insertVersions(tx, users...)
+	})
+}
+
+// Update overwrites the stored fields of an existing user, fills its timestamps and incremented version and returns the replaced user.
+// The write only applies to the version read before it, so concurrent writers never overwrite each other
+func (p *Storage) Update(user *common.User, version int64) (*common.User, error) {
+	for {
+		existing, err := p.getVersion(user.ID, version)
+		if err != nil {
+			return nil, err
+		}
+
+		if existing.DeletedAt != nil {
+			p.logger.Warn("User to update is deleted", zap.Int64("ID", user.ID))
+
+			return nil, common.ErrUserDeleted
+		}
+
+		row := newUser(user)
+		row.UpdatedAt = now()
+		row.Version = existing.Version + 1
+
+		updated := *user
+		updated.CreatedAt = existing.CreatedAt
+		updated.UpdatedAt = row.UpdatedAt
+		updated.Version = row.Version
+		updated.DeletedAt = nil
+
+		var rowsAffected int64
+
+		err = p.inTransaction(func(db sql.DBHandler) error {
+			result := db.Where("version = ?", existing.Version).Updates(&row)
+			if result.Error != nil || result.RowsAffected == 0 {
+				return result.Error
+			}
+
+			rowsAffected = result.RowsAffected
+
+			return p.insertVersions(db, &updated)
+		})
+		if err != nil {
+			p.logger.Error("Failed to update user in database", zap.Int64("ID", user.ID), zap.Error(err))
+
+			return nil, err
+		}
+
+		if rowsAffected > 0 {
+			*user = updated
+
+			p.logger.Debug("Successfully updated user in database", zap.Int64("ID", user.ID), zap.Int64("version", user.Version))
+
+			return existing, nil
+		}
+
+		// The user was changed or deleted after it was read, check it again
+		p.logger.Debug("User changed concurrently, retrying update", zap.Int64("ID", user.ID))
+	}
+}
+
+// Delete permanently removes an existing user from the database, also when it is soft deleted, if it is at the given version,
+// and returns the removed user. The delete only applies to the version read before it, so the returned user is the removed one
+func (p *Storage) Delete(id int64, version int64) (*common.User, error) {
+	for {
+		existing, err := p.getVersion(id, version)
+		if err != nil {
+			return nil, err
+		}
+
+		var rowsAffected int64
+
+		err = p.inTransaction(func(db sql.DBHandler) error {
+			result := db.Delete(&User{}, "id = ? AND version = ?", id, existing.Version)
+			if result.Error != nil || result.RowsAffected == 0 {
+				return result.Error
+			}
+
+			rowsAffected = result.RowsAffected
+
+			return p.deleteVersions(db, id)
+		})
+		if err != nil {
+			p.logger.Error("Failed to delete user from database", zap.Int64("ID", id), zap.Error(err))
+
+			return nil, err
+		}
+
+		if rowsAffected > 0 {
+			p.logger.Debug("Successfully deleted user from database", zap.Int64("ID", id))
+
+			return existing, nil
+		}
+
+		// The user was changed or deleted after it was read, check it again
+		p.logger.Debug("User changed concurrently, retrying delete", zap.Int64("ID", id))
+	}
+}
+
+// SoftDelete marks an existing user as deleted and increments its version, if it is at the given version,
+// and returns the user as it was before
+func (p *Storage) SoftDelete(id int64, version int64) (*common.User, error) {
+	for {
+		existing, err := p.getVersion(id, version)
+		if err != nil {
+			return nil, err
+		}
+
+		if existing.DeletedAt != nil {
+			p.logger.Warn("User to delete is already deleted", zap.Int64("ID", id))
+
+			return nil, common.ErrUserDeleted
+		}
+
+		deletedAt := now()
+
+		deleted := *existing
+		deleted.DeletedAt = &deletedAt
+		deleted.UpdatedAt = deletedAt
+		deleted.Version++
+
+		updated, err := p.updateVersion(&deleted, existing.Version, map[string]interface{}{
+			"deleted_at": deletedAt,
+			"updated_at": deletedAt,
+			"version":    existing.Version + 1,
+		})
+		if err != nil {
+			p.logger.Error("Failed to soft delete user in database", zap.Int64("ID", id), zap.Error(err))
+
+			return nil, err
+		}
+
+		if updated {
+			p.logger.Debug("Successfully soft deleted user in database", zap.Int64("ID", id))
+
+			return existing, nil
+		}
+
+		p.logger.Debug("User changed concurrently, retrying soft delete", zap.Int64("ID", id))
+	}
+}
+
+// Restore clears the deletion mark of a soft deleted user and returns it with its timestamps and incremented version,
+// together with the soft deleted user
+func (p *Storage) Restore(id int64) (*common.User, *common.User, error) {
+	for {
+		user, err := p.getVersion(id, common.AnyVersion)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if user.DeletedAt == nil {
+			p.logger.Warn("User to restore is not deleted", zap.Int64("ID", id))
+
+			return nil, nil, common.ErrUserNotDeleted
+		}
+
+		restored := *user
+		restored.DeletedAt = nil
+		restored.UpdatedAt = now()
+		restored.Version++
+
+		// A nil deletion time is only written through a map, gorm skips zero fields of structs
+		updated, err := p.updateVersion(&restored, user.Version, map[string]interface{}{
+			"deleted_at": nil,
+			"updated_at": restored.UpdatedAt,
+			"version":    restored.Version,
+		})
+		if err != nil {
+			p.logger.Error("Failed to restore user in database", zap.Int64("ID", id), zap.Error(err))
+
+			return nil, nil, err
+		}
+
+		if updated {
+			p.logger.Debug("Successfully restored user in database", zap.Int64("ID", id))
+
+			return &restored, user, nil
+		}
+
+		p.logger.Debug("User changed concurrently, retrying restore", zap.Int64("ID", id))
+	}
+}
+
+// Purge permanently removes users soft deleted before the given time using a single statement
+func (p *Storage) Purge(before time.Time) (int, error) {
+	var purged int64
+
+	err := p.inTransaction(func(db sql.DBHandler) error {
+		// Versions are removed first, while their users can still be selected
+		if p.versions {
+			purgedIDs := db.Model(&User{}).Select("id").Where("deleted_at < ?", before)
+
+			if err := db.Where("user_id IN (?)", purgedIDs).Delete(&UserVersion{}).Error; err != nil {
+				return err
+			}
+		}
+
+		result := db.Where("deleted_at < ?", before).Delete(&User{})
+		purged = result.RowsAffected
+
+		return result.Error
+	})
+	if err != nil {
+		p.logger.Error("Failed to purge deleted users from database", zap.Time("before", before), zap.Error(err))
+
+		return 0, err
+	}
+
+	p.logger.Debug("Successfully purged deleted users from database", zap.Int64("count", purged))
+
+	return int(purged), nil
+}
+
+// updateVersion writes the given columns of the user if it is still at the given version and reports whether it was.
+// user is the user after the write, which is kept as its new version
+func (p *Storage) updateVersion(user *common.User, version int64, values map[string]interface{}) (bool, error) {
+	updated := false
+
+	err := p.inTransaction(func(db sql.DBHandler) error {
+		result := db.Model(&User{ID: user.ID}).Where("version = ?", version).Updates(values)
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+
+		updated = true
+
+		return p.insertVersions(db, user)
+	})
+
+	return updated, err
+}
+
+// getVersion returns the user with the given ID if it is at the given version, common.AnyVersion matches every version
+func (p *Storage) getVersion(id int64, version int64) (*common.User, error) {
+	var user common.User
+
+	result := p.db.First(&user, id)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			p.logger.Warn("User not found", zap.Int64("ID", id))
+
+			return nil, common.ErrUserNotFound
+		}
+
+		p.logger.Error("Failed to retrieve user from database", zap.Int64("ID", id), zap.Error(result.Error))
+
+		return nil, result.Error
+	}
+
+	if version != common.AnyVersion && user.Version != version {
+		p.logger.Warn("User version mismatch", zap.Int64("ID", id), zap.Int64("version", user.Version), zap.Int64("expected", version))
+
+		return nil, common.ErrVersionMismatch
+	}
+
+	return &user, nil
+}
+
+// List returns up to limit users with an ID greater than the one encoded in the cursor, ordered by ID
+func (p *Storage) List(cursor string, limit int) ([]*common.User, string, error) {
+	var lastID int64
+
+	if cursor != "" {
+		data, err := common.DecodeCursor(cursor)
+		if err != nil || len(data) != idCursorLength {
+			p.logger.Warn("Invalid list cursor received", zap.String("cursor", cursor))
+
+			return nil, "", common.ErrInvalidCursor
+		}
+
+		lastID = common.BytesToInt64(data)
+	}
+
+	var users []*common.User
+
+	// Fetch one extra user to find out if there is a next page
+	result := p.db.Where("id > ?", lastID).Where(notDeleted).Order("id").Limit(limit + 1).Find(&users)
+	if result.Error != nil {
+		p.logger.Error("Failed to list users from database", zap.Int64("lastID", lastID), zap.Error(result.Error))
+
+		return nil, "", result.Error
+	}
+
+	nextCursor := ""
+
+	if len(users) > limit {
+		users = users[:limit]
+		nextCursor = common.EncodeCursor(common.Int64ToBytes(users[limit-1].ID))
+	}
+
+	p.logger.Debug("Successfully listed users from database", zap.Int("count", len(users)))
+
+	return users, nextCursor, nil
+}
+
 // Close closes the database connection
 func (p *Storage) Close() error {
 	sqlDB, err := p.db.DB()
@@ -96,12 +657,12 @@ func (p *Storage) Close() error {
 
 	err = sqlDB.Close()
 	if err != nil {
-		p.logger.Error("Failed to close PostgreSQL database connection", zap.Error(err))
+		p.logger.Error("Failed to close database connection", zap.Error(err))
 
 		return err
 	}
 
-	p.logger.Info("Successfully closed PostgreSQL database connection")
+	p.logger.Info("Successfully closed database connection")
 
 	return nil
 }
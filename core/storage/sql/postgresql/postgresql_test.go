@@ -2,12 +2,18 @@ package postgresql
 
 import (
 	"errors"
+	"regexp"
 	"testing"
+	"time"
 
 	"github.com/Aleksao998/LightningUserVault/core/common"
 	"github.com/Aleksao998/LightningUserVault/core/storage/mocks"
+	"github.com/Aleksao998/LightningUserVault/core/storage/sql"
+	"github.com/Aleksao998/LightningUserVault/core/storage/sql/sqltest"
+	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/stretchr/testify/assert"
 	"go.uber.org/zap"
+	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
 
@@ -15,6 +21,28 @@ var errInternal = errors.New("internal db error")
 
 const mockUserName = "Mocked User"
 
+// createMockedGorm creates a gorm instance on top of a mocked SQL connection,
+// used for testing queries which are built by chaining gorm methods
+func createMockedGorm(t *testing.T) (*gorm.DB, sqlmock.Sqlmock) {
+	t.Helper()
+
+	sqlDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error creating sql mock, %v", err)
+	}
+
+	t.Cleanup(func() {
+		sqlDB.Close()
+	})
+
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("error opening gorm, %v", err)
+	}
+
+	return db, mock
+}
+
 // TestPostgres_GetValid tests the scenario where a user is successfully retrieved from the database
 func TestPostgres_GetValid(t *testing.T) {
 	t.Parallel()
@@ -61,7 +89,7 @@ func TestPostgres_GetNotFound(t *testing.T) {
 
 	user, err := storage.Get(1)
 	assert.Error(t, err)
-	assert.Equal(t, errUserNotFound, err)
+	assert.Equal(t, common.ErrUserNotFound, err)
 	assert.Nil(t, user)
 }
 
@@ -91,7 +119,7 @@ func TestPostgres_SetSuccessfully(t *testing.T) {
 	t.Parallel()
 
 	mockDB := &mocks.MockSQLdb{
-		CreateFn: func(value interface{}) *gorm.DB {
+		CreateInBatchesFn: func(value interface{}, batchSize int) *gorm.DB {
 			u, ok := value.(*User)
 			if !ok {
 				t.Fatalf("value is not of type *User")
@@ -108,9 +136,15 @@ func TestPostgres_SetSuccessfully(t *testing.T) {
 		logger: zap.NewNop(),
 	}
 
-	id, err := storage.Set(mockUserName)
+	user := &common.User{Name: mockUserName}
+
+	id, err := storage.Set(user)
 	assert.Nil(t, err)
 	assert.Equal(t, int64(1), id)
+	assert.Equal(t, int64(1), user.ID)
+	assert.False(t, user.CreatedAt.IsZero())
+	assert.Equal(t, user.CreatedAt, user.UpdatedAt)
+	assert.Equal(t, int64(1), user.Version)
 }
 
 // TestPostgres_SetError tests the scenario where an error occurs while adding a user to the database
@@ -118,7 +152,7 @@ func TestPostgres_SetError(t *testing.T) {
 	t.Parallel()
 
 	mockDB := &mocks.MockSQLdb{
-		CreateFn: func(value interface{}) *gorm.DB {
+		CreateInBatchesFn: func(value interface{}, batchSize int) *gorm.DB {
 			return &gorm.DB{Error: errInternal}
 		},
 	}
@@ -128,8 +162,695 @@ func TestPostgres_SetError(t *testing.T) {
 		logger: zap.NewNop(),
 	}
 
-	id, err := storage.Set(mockUserName)
+	id, err := storage.Set(&common.User{Name: mockUserName})
 	assert.Error(t, err)
 	assert.Equal(t, errInternal, err)
 	assert.Equal(t, int64(0), id)
 }
+
+// userColumns are the columns of the users table
+var (
+	userColumns = []string{"id", "name", "email", "attributes", "created_at", "updated_at", "version"}
+
+	// deletedUserColumns additionally hold the deletion time of soft deleted users
+	deletedUserColumns = append(append([]string{}, userColumns...), "deleted_at")
+)
+
+const (
+	// selectUserQuery is the query issued when reading a user before a conditional write
+	selectUserQuery = `SELECT * FROM "users" WHERE "users"."id" = $1 ORDER BY "users"."id" LIMIT 1`
+
+	// deleteUserQuery is the query removing a user at the version read before
+	deleteUserQuery = `DELETE FROM "users" WHERE id = $1 AND version = $2`
+
+	// updateUserQuery is the conditional update of all writable user columns
+	updateUserQuery = `UPDATE "users" SET "name"=$1,"email"=$2,"attributes"=$3,"updated_at"=$4,"version"=$5 ` +
+		`WHERE version = $6 AND "id" = $7`
+
+	// updateDeletedQuery is the conditional update of the deletion mark of a user
+	updateDeletedQuery = `UPDATE "users" SET "deleted_at"=$1,"updated_at"=$2,"version"=$3 WHERE version = $4 AND "id" = $5`
+)
+
+// TestPostgres_UpdateSuccessfully tests the scenario where a user is successfully updated in the database
+func TestPostgres_UpdateSuccessfully(t *testing.T) {
+	t.Parallel()
+
+	db, mock := createMockedGorm(t)
+
+	createdAt := time.Date(2023, 9, 1, 12, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery(regexp.QuoteMeta(selectUserQuery)).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(userColumns).
+			AddRow(1, "Old Name", "old@example.com", `{}`, createdAt, createdAt, 2))
+	mock.ExpectBegin()
+
+	// Emptied fields are written as well
+	mock.ExpectExec(regexp.QuoteMeta(updateUserQuery)).
+		WithArgs(mockUserName, "", "{}", sqlmock.AnyArg(), 3, 2, 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	storage := &Storage{
+		db:     db,
+		logger: zap.NewNop(),
+	}
+
+	user := &common.User{ID: 1, Name: mockUserName}
+
+	_, err := storage.Update(user, 2)
+	assert.Nil(t, err)
+	assert.Equal(t, createdAt, user.CreatedAt)
+	assert.True(t, user.UpdatedAt.After(createdAt))
+	assert.Equal(t, int64(3), user.Version)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestPostgres_UpdateNotFound tests the scenario where the user to update is not found in the database
+func TestPostgres_UpdateNotFound(t *testing.T) {
+	t.Parallel()
+
+	db, mock := createMockedGorm(t)
+
+	mock.ExpectQuery(regexp.QuoteMeta(selectUserQuery)).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(userColumns))
+
+	storage := &Storage{
+		db:     db,
+		logger: zap.NewNop(),
+	}
+
+	_, err := storage.Update(&common.User{ID: 1, Name: mockUserName}, common.AnyVersion)
+	assert.Equal(t, common.ErrUserNotFound, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestPostgres_UpdateVersionMismatch tests the scenario where the user is not at the expected version
+func TestPostgres_UpdateVersionMismatch(t *testing.T) {
+	t.Parallel()
+
+	db, mock := createMockedGorm(t)
+
+	mock.ExpectQuery(regexp.QuoteMeta(selectUserQuery)).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(userColumns).AddRow(1, "Old Name", nil, nil, nil, nil, 2))
+
+	storage := &Storage{
+		db:     db,
+		logger: zap.NewNop(),
+	}
+
+	_, err := storage.Update(&common.User{ID: 1, Name: mockUserName}, 1)
+	assert.Equal(t, common.ErrVersionMismatch, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestPostgres_UpdateChangedConcurrently tests the scenario where the user is changed between reading and updating it
+func TestPostgres_UpdateChangedConcurrently(t *testing.T) {
+	t.Parallel()
+
+	db, mock := createMockedGorm(t)
+
+	mock.ExpectQuery(regexp.QuoteMeta(selectUserQuery)).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(userColumns).AddRow(1, "Old Name", nil, nil, nil, nil, 2))
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(updateUserQuery)).
+		WithArgs(mockUserName, "", "{}", sqlmock.AnyArg(), 3, 2, 1).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	// The update is retried on top of the concurrent change
+	mock.ExpectQuery(regexp.QuoteMeta(selectUserQuery)).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(userColumns).AddRow(1, "Other Name", nil, nil, nil, nil, 3))
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(updateUserQuery)).
+		WithArgs(mockUserName, "", "{}", sqlmock.AnyArg(), 4, 3, 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	storage := &Storage{
+		db:     db,
+		logger: zap.NewNop(),
+	}
+
+	user := &common.User{ID: 1, Name: mockUserName}
+
+	_, err := storage.Update(user, common.AnyVersion)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(4), user.Version)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestPostgres_UpdateError tests the scenario where an error occurs while updating a user in the database
+func TestPostgres_UpdateError(t *testing.T) {
+	t.Parallel()
+
+	db, mock := createMockedGorm(t)
+
+	mock.ExpectQuery(regexp.QuoteMeta(selectUserQuery)).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(userColumns).AddRow(1, "Old Name", nil, nil, nil, nil, 1))
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(updateUserQuery)).WillReturnError(errInternal)
+	mock.ExpectRollback()
+
+	storage := &Storage{
+		db:     db,
+		logger: zap.NewNop(),
+	}
+
+	_, err := storage.Update(&common.User{ID: 1, Name: mockUserName}, common.AnyVersion)
+	assert.Equal(t, errInternal, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestPostgres_DeleteSuccessfully tests the scenario where a user is successfully deleted from the database
+func TestPostgres_DeleteSuccessfully(t *testing.T) {
+	t.Parallel()
+
+	db, mock := createMockedGorm(t)
+
+	mock.ExpectQuery(regexp.QuoteMeta(selectUserQuery)).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(userColumns).AddRow(1, mockUserName, nil, nil, nil, nil, 2))
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(deleteUserQuery)).WithArgs(1, 2).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	storage := &Storage{
+		db:     db,
+		logger: zap.NewNop(),
+	}
+
+	removed, err := storage.Delete(1, common.AnyVersion)
+	assert.NoError(t, err)
+	assert.Equal(t, mockUserName, removed.Name)
+	assert.Equal(t, int64(2), removed.Version)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestPostgres_DeleteNotFound tests the scenario where the user to delete is not found in the database
+func TestPostgres_DeleteNotFound(t *testing.T) {
+	t.Parallel()
+
+	mockDB := &mocks.MockSQLdb{
+		FirstFn: func(out interface{}, where ...interface{}) *gorm.DB {
+			return &gorm.DB{Error: gorm.ErrRecordNotFound}
+		},
+	}
+
+	storage := &Storage{
+		db:     mockDB,
+		logger: zap.NewNop(),
+	}
+
+	_, err := storage.Delete(1, common.AnyVersion)
+	assert.Equal(t, common.ErrUserNotFound, err)
+}
+
+// TestPostgres_DeleteError tests the scenario where an error occurs while deleting a user from the database
+func TestPostgres_DeleteError(t *testing.T) {
+	t.Parallel()
+
+	db, mock := createMockedGorm(t)
+
+	mock.ExpectQuery(regexp.QuoteMeta(selectUserQuery)).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(userColumns).AddRow(1, mockUserName, nil, nil, nil, nil, 1))
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(deleteUserQuery)).WillReturnError(errInternal)
+	mock.ExpectRollback()
+
+	storage := &Storage{
+		db:     db,
+		logger: zap.NewNop(),
+	}
+
+	_, err := storage.Delete(1, common.AnyVersion)
+	assert.Equal(t, errInternal, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestPostgres_ListWithNextPage tests the scenario where there are more users than the requested limit
+func TestPostgres_ListWithNextPage(t *testing.T) {
+	t.Parallel()
+
+	db, mock := createMockedGorm(t)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "users" WHERE id > $1 AND deleted_at IS NULL ORDER BY id LIMIT 3`)).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).
+			AddRow(2, "User-2").
+			AddRow(3, "User-3").
+			AddRow(4, "User-4"))
+
+	storage := &Storage{
+		db:     db,
+		logger: zap.NewNop(),
+	}
+
+	users, nextCursor, err := storage.List(common.EncodeCursor(common.Int64ToBytes(1)), 2)
+	assert.NoError(t, err)
+	assert.Len(t, users, 2)
+	assert.Equal(t, int64(2), users[0].ID)
+	assert.Equal(t, "User-3", users[1].Name)
+	assert.Equal(t, common.EncodeCursor(common.Int64ToBytes(3)), nextCursor)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestPostgres_ListLastPage tests the scenario where the returned page is the last one
+func TestPostgres_ListLastPage(t *testing.T) {
+	t.Parallel()
+
+	db, mock := createMockedGorm(t)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "users" WHERE id > $1 AND deleted_at IS NULL ORDER BY id LIMIT 11`)).
+		WithArgs(0).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, mockUserName))
+
+	storage := &Storage{
+		db:     db,
+		logger: zap.NewNop(),
+	}
+
+	users, nextCursor, err := storage.List("", 10)
+	assert.NoError(t, err)
+	assert.Len(t, users, 1)
+	assert.Equal(t, mockUserName, users[0].Name)
+	assert.Empty(t, nextCursor)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestPostgres_ListInvalidCursor tests the scenario where the cursor was not issued by the storage
+func TestPostgres_ListInvalidCursor(t *testing.T) {
+	t.Parallel()
+
+	storage := &Storage{
+		db:     &mocks.MockSQLdb{},
+		logger: zap.NewNop(),
+	}
+
+	users, _, err := storage.List("invalid cursor", 10)
+	assert.ErrorIs(t, err, common.ErrInvalidCursor)
+	assert.Nil(t, users)
+}
+
+// TestPostgres_SetBatchSuccessfully tests the scenario where a batch of users is successfully added to the database
+func TestPostgres_SetBatchSuccessfully(t *testing.T) {
+	t.Parallel()
+
+	mockDB := &mocks.MockSQLdb{
+		CreateInBatchesFn: func(value interface{}, batchSize int) *gorm.DB {
+			users, ok := value.(*[]User)
+			if !ok {
+				t.Fatalf("value is not of type *[]User")
+			}
+
+			for i := range *users {
+				(*users)[i].ID = int64(i + 1)
+			}
+
+			return &gorm.DB{}
+		},
+	}
+
+	storage := &Storage{
+		db:     mockDB,
+		logger: zap.NewNop(),
+	}
+
+	users := []*common.User{{Name: "User-1"}, {Name: "User-2"}, {Name: "User-3"}}
+
+	ids, err := storage.SetBatch(users)
+	assert.Nil(t, err)
+	assert.Equal(t, []int64{1, 2, 3}, ids)
+	assert.Equal(t, int64(3), users[2].ID)
+	assert.False(t, users[2].CreatedAt.IsZero())
+}
+
+// TestPostgres_SetBatchError tests the scenario where an error occurs while adding a batch of users to the database
+func TestPostgres_SetBatchError(t *testing.T) {
+	t.Parallel()
+
+	mockDB := &mocks.MockSQLdb{
+		CreateInBatchesFn: func(value interface{}, batchSize int) *gorm.DB {
+			return &gorm.DB{Error: errInternal}
+		},
+	}
+
+	storage := &Storage{
+		db:     mockDB,
+		logger: zap.NewNop(),
+	}
+
+	ids, err := storage.SetBatch([]*common.User{{Name: "User-1"}, {Name: "User-2"}})
+	assert.Equal(t, errInternal, err)
+	assert.Nil(t, ids)
+}
+
+// TestPostgres_GetMultiValid tests the scenario where multiple users are retrieved from the database
+func TestPostgres_GetMultiValid(t *testing.T) {
+	t.Parallel()
+
+	mockDB := &mocks.MockSQLdb{
+		FindFn: func(dest interface{}, conds ...interface{}) *gorm.DB {
+			users, ok := dest.(*[]*common.User)
+			if !ok {
+				t.Fatalf("value is not of type *[]*common.User")
+			}
+
+			assert.Equal(t, []interface{}{[]int64{1, 2, 3}}, conds)
+
+			*users = append(*users, &common.User{ID: 1, Name: "User-1"}, &common.User{ID: 3, Name: "User-3"})
+
+			return &gorm.DB{}
+		},
+	}
+
+	storage := &Storage{
+		db:     mockDB,
+		logger: zap.NewNop(),
+	}
+
+	users, err := storage.GetMulti([]int64{1, 2, 3})
+	assert.Nil(t, err)
+	assert.Len(t, users, 2)
+	assert.Equal(t, "User-1", users[1].Name)
+	assert.Equal(t, "User-3", users[3].Name)
+}
+
+// TestPostgres_GetMultiInternalError tests the scenario where an internal error occurs while retrieving multiple users
+func TestPostgres_GetMultiInternalError(t *testing.T) {
+	t.Parallel()
+
+	mockDB := &mocks.MockSQLdb{
+		FindFn: func(dest interface{}, conds ...interface{}) *gorm.DB {
+			return &gorm.DB{Error: errInternal}
+		},
+	}
+
+	storage := &Storage{
+		db:     mockDB,
+		logger: zap.NewNop(),
+	}
+
+	users, err := storage.GetMulti([]int64{1, 2})
+	assert.Equal(t, errInternal, err)
+	assert.Nil(t, users)
+}
+
+// TestPostgres_FindByNameExact tests the scenario where users are looked up by their exact name
+func TestPostgres_FindByNameExact(t *testing.T) {
+	t.Parallel()
+
+	db, mock := createMockedGorm(t)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "users" WHERE name = $1 AND deleted_at IS NULL ORDER BY id`)).
+		WithArgs("alice").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "alice").AddRow(5, "alice"))
+
+	storage := &Storage{
+		db:     db,
+		logger: zap.NewNop(),
+	}
+
+	users, err := storage.FindByName("alice", false)
+	assert.NoError(t, err)
+	assert.Len(t, users, 2)
+	assert.Equal(t, int64(5), users[1].ID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestPostgres_FindByNamePrefix tests the scenario where users are looked up by name prefix, escaping wildcards
+func TestPostgres_FindByNamePrefix(t *testing.T) {
+	t.Parallel()
+
+	db, mock := createMockedGorm(t)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "users" WHERE name LIKE $1 ESCAPE '!' AND deleted_at IS NULL ORDER BY id`)).
+		WithArgs("a!%!_!!%").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "a%_!b"))
+
+	storage := &Storage{
+		db:     db,
+		logger: zap.NewNop(),
+	}
+
+	users, err := storage.FindByName("a%_!", true)
+	assert.NoError(t, err)
+	assert.Len(t, users, 1)
+	assert.Equal(t, "a%_!b", users[0].Name)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestPostgres_ListUserFields tests that every user column, including NULLs of rows written before they existed, is read
+func TestPostgres_ListUserFields(t *testing.T) {
+	t.Parallel()
+
+	db, mock := createMockedGorm(t)
+
+	createdAt := time.Date(2023, 9, 1, 12, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "users" WHERE id > $1 AND deleted_at IS NULL ORDER BY id LIMIT 11`)).
+		WithArgs(0).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "email", "attributes", "created_at", "updated_at"}).
+			AddRow(1, "User-1", "user1@example.com", `{"team":"core"}`, createdAt, createdAt).
+			AddRow(2, "User-2", nil, nil, nil, nil))
+
+	storage := &Storage{
+		db:     db,
+		logger: zap.NewNop(),
+	}
+
+	users, _, err := storage.List("", 10)
+	assert.NoError(t, err)
+	assert.Len(t, users, 2)
+	assert.Equal(t, &common.User{
+		ID:         1,
+		Name:       "User-1",
+		Email:      "user1@example.com",
+		Attributes: map[string]string{"team": "core"},
+		CreatedAt:  createdAt,
+		UpdatedAt:  createdAt,
+	}, users[0])
+	assert.Equal(t, &common.User{ID: 2, Name: "User-2"}, users[1])
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestPostgres_DeleteWithVersion tests deleting a user only if it is at the expected version
+func TestPostgres_DeleteWithVersion(t *testing.T) {
+	t.Parallel()
+
+	db, mock := createMockedGorm(t)
+
+	mock.ExpectQuery(regexp.QuoteMeta(selectUserQuery)).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(userColumns).AddRow(1, mockUserName, nil, nil, nil, nil, 2))
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(deleteUserQuery)).WithArgs(1, 2).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	// The second user is at another version, so it is not deleted
+	mock.ExpectQuery(regexp.QuoteMeta(selectUserQuery)).
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows(userColumns).AddRow(3, mockUserName, nil, nil, nil, nil, 5))
+
+	// The third user is removed after it was read, so the delete does not match and the user is read again
+	mock.ExpectQuery(regexp.QuoteMeta(selectUserQuery)).
+		WithArgs(4).
+		WillReturnRows(sqlmock.NewRows(userColumns).AddRow(4, mockUserName, nil, nil, nil, nil, 2))
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(deleteUserQuery)).WithArgs(4, 2).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+	mock.ExpectQuery(regexp.QuoteMeta(selectUserQuery)).
+		WithArgs(4).
+		WillReturnRows(sqlmock.NewRows(userColumns))
+
+	storage := &Storage{
+		db:     db,
+		logger: zap.NewNop(),
+	}
+
+	_, err := storage.Delete(1, 2)
+	assert.NoError(t, err)
+	_, err = storage.Delete(3, 2)
+	assert.Equal(t, common.ErrVersionMismatch, err)
+	_, err = storage.Delete(4, 2)
+	assert.Equal(t, common.ErrUserNotFound, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestPostgres_GetDeleted tests the scenario where the retrieved user is soft deleted
+func TestPostgres_GetDeleted(t *testing.T) {
+	t.Parallel()
+
+	mockDB := &mocks.MockSQLdb{
+		FirstFn: func(out interface{}, where ...interface{}) *gorm.DB {
+			u, ok := out.(*common.User)
+			if !ok {
+				t.Fatalf("value is not of type *User")
+			}
+
+			deletedAt := time.Now()
+			u.ID = 1
+			u.Name = mockUserName
+			u.DeletedAt = &deletedAt
+
+			return &gorm.DB{}
+		},
+	}
+
+	storage := &Storage{
+		db:     mockDB,
+		logger: zap.NewNop(),
+	}
+
+	user, err := storage.Get(1)
+	assert.Equal(t, common.ErrUserDeleted, err)
+	assert.Nil(t, user)
+}
+
+// TestPostgres_SoftDeleteSuccessfully tests the scenario where a user is marked as deleted
+func TestPostgres_SoftDeleteSuccessfully(t *testing.T) {
+	t.Parallel()
+
+	db, mock := createMockedGorm(t)
+
+	mock.ExpectQuery(regexp.QuoteMeta(selectUserQuery)).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(userColumns).AddRow(1, mockUserName, nil, nil, nil, nil, 2))
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(updateDeletedQuery)).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), 3, 2, 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	storage := &Storage{
+		db:     db,
+		logger: zap.NewNop(),
+	}
+
+	_, err := storage.SoftDelete(1, 2)
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestPostgres_SoftDeleteAlreadyDeleted tests the scenario where the user to delete is already soft deleted
+func TestPostgres_SoftDeleteAlreadyDeleted(t *testing.T) {
+	t.Parallel()
+
+	db, mock := createMockedGorm(t)
+
+	// Both the soft delete and the update read the user
+	for i := 0; i < 2; i++ {
+		mock.ExpectQuery(regexp.QuoteMeta(selectUserQuery)).
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows(deletedUserColumns).AddRow(1, mockUserName, nil, nil, nil, nil, 2, time.Now()))
+	}
+
+	storage := &Storage{
+		db:     db,
+		logger: zap.NewNop(),
+	}
+
+	_, err := storage.SoftDelete(1, common.AnyVersion)
+	assert.Equal(t, common.ErrUserDeleted, err)
+	_, err = storage.Update(&common.User{ID: 1, Name: mockUserName}, common.AnyVersion)
+	assert.Equal(t, common.ErrUserDeleted, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestPostgres_RestoreSuccessfully tests the scenario where a soft deleted user is restored
+func TestPostgres_RestoreSuccessfully(t *testing.T) {
+	t.Parallel()
+
+	db, mock := createMockedGorm(t)
+
+	mock.ExpectQuery(regexp.QuoteMeta(selectUserQuery)).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(deletedUserColumns).AddRow(1, mockUserName, nil, nil, nil, nil, 3, time.Now()))
+	mock.ExpectBegin()
+
+	// The deletion mark is cleared
+	mock.ExpectExec(regexp.QuoteMeta(updateDeletedQuery)).
+		WithArgs(nil, sqlmock.AnyArg(), 4, 3, 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	storage := &Storage{
+		db:     db,
+		logger: zap.NewNop(),
+	}
+
+	user, before, err := storage.Restore(1)
+	assert.NoError(t, err)
+	assert.Equal(t, mockUserName, user.Name)
+	assert.Equal(t, int64(4), user.Version)
+	assert.Nil(t, user.DeletedAt)
+	assert.Equal(t, int64(3), before.Version)
+	assert.NotNil(t, before.DeletedAt)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestPostgres_RestoreNotDeleted tests the scenario where the user to restore is not soft deleted
+func TestPostgres_RestoreNotDeleted(t *testing.T) {
+	t.Parallel()
+
+	db, mock := createMockedGorm(t)
+
+	mock.ExpectQuery(regexp.QuoteMeta(selectUserQuery)).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(userColumns).AddRow(1, mockUserName, nil, nil, nil, nil, 3))
+	mock.ExpectQuery(regexp.QuoteMeta(selectUserQuery)).
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows(userColumns))
+
+	storage := &Storage{
+		db:     db,
+		logger: zap.NewNop(),
+	}
+
+	_, _, err := storage.Restore(1)
+	assert.Equal(t, common.ErrUserNotDeleted, err)
+
+	_, _, err = storage.Restore(2)
+	assert.Equal(t, common.ErrUserNotFound, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestPostgres_Purge tests the scenario where users soft deleted before the given time are removed
+func TestPostgres_Purge(t *testing.T) {
+	t.Parallel()
+
+	db, mock := createMockedGorm(t)
+
+	before := time.Date(2023, 9, 1, 12, 0, 0, 0, time.UTC)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "users" WHERE deleted_at < $1`)).
+		WithArgs(before).
+		WillReturnResult(sqlmock.NewResult(0, 4))
+	mock.ExpectCommit()
+
+	storage := &Storage{
+		db:     db,
+		logger: zap.NewNop(),
+	}
+
+	purged, err := storage.Purge(before)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, purged)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestPostgres_Conformance runs the shared SQL storage conformance suite against the storage
+func TestPostgres_Conformance(t *testing.T) {
+	t.Parallel()
+
+	sqltest.RunConformance(t, func(db sql.DBHandler) sqltest.Storage {
+		return NewStorageWithDB(zap.NewNop(), db, Options{})
+	})
+}
@@ -0,0 +1,172 @@
+package postgresql
+
+import (
+	"time"
+
+	"github.com/Aleksao998/LightningUserVault/core/common"
+	"github.com/Aleksao998/LightningUserVault/core/storage/sql"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// replacedVersion is the condition selecting versions which were replaced by a newer version written at or before a time
+const replacedVersion = "EXISTS (SELECT 1 FROM user_versions AS newer WHERE newer.user_id = user_versions.user_id " +
+	"AND newer.version > user_versions.version AND newer.updated_at <= ?)"
+
+// UserVersion is an immutable copy of a user at one of its versions, inserted in the same transaction as the write
+type UserVersion struct {
+	UserID     int64             `gorm:"primaryKey;autoIncrement:false"`
+	Version    int64             `gorm:"primaryKey;autoIncrement:false"`
+	Name       string            `gorm:"type:varchar(255)"`
+	Email      string            `gorm:"type:varchar(255)"`
+	Attributes map[string]string `gorm:"type:text;serializer:json"`
+	CreatedAt  time.Time
+	UpdatedAt  time.Time `gorm:"index"`
+	DeletedAt  *time.Time
+}
+
+// newUserVersion converts a user into the model of its current version
+func newUserVersion(user *common.User) UserVersion {
+	return UserVersion{
+		UserID:     user.ID,
+		Version:    user.Version,
+		Name:       user.Name,
+		Email:      user.Email,
+		Attributes: user.Attributes,
+		CreatedAt:  user.CreatedAt,
+		UpdatedAt:  user.UpdatedAt,
+		DeletedAt:  user.DeletedAt,
+	}
+}
+
+// user converts the version back into the user it was written as
+func (v UserVersion) user() *common.User {
+	return &common.User{
+		ID:         v.UserID,
+		Name:       v.Name,
+		Email:      v.Email,
+		Attributes: v.Attributes,
+		CreatedAt:  v.CreatedAt,
+		UpdatedAt:  v.UpdatedAt,
+		Version:    v.Version,
+		DeletedAt:  v.DeletedAt,
+	}
+}
+
+// inTransaction runs the write in a transaction when versions are kept, so the user and its version are committed together.
+// Otherwise the write runs directly on the database
+func (p *Storage) inTransaction(write func(db sql.DBHandler) error) error {
+	if !p.versions {
+		return write(p.db)
+	}
+
+	return p.db.Transaction(func(tx *gorm.DB) error {
+		return write(tx)
+	})
+}
+
+// insertVersions inserts the current versions of the users when versions are kept
+func (p *Storage) insertVersions(db sql.DBHandler, users ...*common.User) error {
+	if !p.versions || len(users) == 0 {
+		return nil
+	}
+
+	versions := make([]UserVersion, 0, len(users))
+	for _, user := range users {
+		versions = append(versions, newUserVersion(user))
+	}
+
+	return db.CreateInBatches(&versions, createBatchSize).Error
+}
+
+// deleteVersions removes all versions of the user when versions are kept
+func (p *Storage) deleteVersions(db sql.DBHandler, id int64) error {
+	if !p.versions {
+		return nil
+	}
+
+	return db.Where("user_id = ?", id).Delete(&UserVersion{}).Error
+}
+
+// GetAsOf returns the latest version of the user which was written at or before the given time.
+// The current record is the latest version, so it is used for users written before versions were kept
+func (p *Storage) GetAsOf(id int64, at time.Time) (*common.User, error) {
+	user, err := p.getVersion(id, common.AnyVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.UpdatedAt.After(at) {
+		var versions []UserVersion
+
+		result := p.db.Where("user_id = ? AND updated_at <= ?", id, at).Order("version DESC").Limit(1).Find(&versions)
+		if result.Error != nil {
+			p.logger.Error("Failed to retrieve user version from database", zap.Int64("ID", id), zap.Error(result.Error))
+
+			return nil, result.Error
+		}
+
+		if len(versions) == 0 {
+			p.logger.Debug("No user version retained at time", zap.Int64("ID", id), zap.Time("at", at))
+
+			return nil, common.ErrUserNotFound
+		}
+
+		user = versions[0].user()
+	}
+
+	if user.DeletedAt != nil {
+		return nil, common.ErrUserDeleted
+	}
+
+	p.logger.Debug("Successfully retrieved user version from database", zap.Int64("ID", id), zap.Int64("version", user.Version))
+
+	return user, nil
+}
+
+// Versions returns all retained versions of the user, newest first
+func (p *Storage) Versions(id int64) ([]*common.User, error) {
+	current, err := p.getVersion(id, common.AnyVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []UserVersion
+
+	result := p.db.Where("user_id = ?", id).Order("version DESC").Find(&rows)
+	if result.Error != nil {
+		p.logger.Error("Failed to retrieve user versions from database", zap.Int64("ID", id), zap.Error(result.Error))
+
+		return nil, result.Error
+	}
+
+	versions := make([]*common.User, 0, len(rows)+1)
+
+	// The current record was written before versions were kept
+	if len(rows) == 0 || rows[0].Version != current.Version {
+		versions = append(versions, current)
+	}
+
+	for _, row := range rows {
+		versions = append(versions, row.user())
+	}
+
+	p.logger.Debug("Successfully retrieved user versions from database", zap.Int64("ID", id), zap.Int("count", len(versions)))
+
+	return versions, nil
+}
+
+// CompactVersions removes every version which was replaced by a newer version written at or before the given time,
+// using a single statement
+func (p *Storage) CompactVersions(before time.Time) (int, error) {
+	result := p.db.Where(replacedVersion, before).Delete(&UserVersion{})
+	if result.Error != nil {
+		p.logger.Error("Failed to compact user versions in database", zap.Time("before", before), zap.Error(result.Error))
+
+		return 0, result.Error
+	}
+
+	p.logger.Debug("Successfully compacted user versions in database", zap.Int64("count", result.RowsAffected))
+
+	return int(result.RowsAffected), nil
+}
@@ -0,0 +1,295 @@
+package postgresql
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Aleksao998/LightningUserVault/core/common"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+const (
+	// insertVersionQuery is the start of the insert of user versions
+	insertVersionQuery = `INSERT INTO "user_versions"`
+
+	// selectVersionAsOfQuery is the query of the latest version written at or before a time
+	selectVersionAsOfQuery = `SELECT * FROM "user_versions" WHERE user_id = $1 AND updated_at <= $2 ORDER BY version DESC LIMIT 1`
+
+	// selectVersionsQuery is the query of all versions of a user
+	selectVersionsQuery = `SELECT * FROM "user_versions" WHERE user_id = $1 ORDER BY version DESC`
+
+	// deleteVersionsQuery is the delete of all versions of a user
+	deleteVersionsQuery = `DELETE FROM "user_versions" WHERE user_id = $1`
+)
+
+// versionColumns are the columns of the user_versions table
+var versionColumns = []string{"user_id", "version", "name", "email", "attributes", "created_at", "updated_at", "deleted_at"}
+
+// TestPostgres_SetWithVersions tests the scenario where a created user and its first version are inserted in one transaction
+func TestPostgres_SetWithVersions(t *testing.T) {
+	t.Parallel()
+
+	db, mock := createMockedGorm(t)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(insertUserQuery)).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+	mock.ExpectExec(regexp.QuoteMeta(insertVersionQuery)).
+		WithArgs(7, 1, mockUserName, "", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), nil).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	storage := &Storage{
+		db:       db,
+		logger:   zap.NewNop(),
+		versions: true,
+	}
+
+	id, err := storage.Set(&common.User{Name: mockUserName})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(7), id)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestPostgres_UpdateWithVersions tests the scenario where the updated user is kept as a new version in the same transaction
+func TestPostgres_UpdateWithVersions(t *testing.T) {
+	t.Parallel()
+
+	db, mock := createMockedGorm(t)
+
+	createdAt := time.Date(2023, 9, 1, 12, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery(regexp.QuoteMeta(selectUserQuery)).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(userColumns).
+			AddRow(1, "Old Name", "old@example.com", `{}`, createdAt, createdAt, 2))
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(updateUserQuery)).
+		WithArgs(mockUserName, "", "{}", sqlmock.AnyArg(), 3, 2, 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(insertVersionQuery)).
+		WithArgs(1, 3, mockUserName, "", sqlmock.AnyArg(), createdAt, sqlmock.AnyArg(), nil).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	storage := &Storage{
+		db:       db,
+		logger:   zap.NewNop(),
+		versions: true,
+	}
+
+	user := &common.User{ID: 1, Name: mockUserName}
+
+	_, err := storage.Update(user, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), user.Version)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestPostgres_UpdateWithVersionsError tests the scenario where the update is rolled back when its version can not be inserted
+func TestPostgres_UpdateWithVersionsError(t *testing.T) {
+	t.Parallel()
+
+	db, mock := createMockedGorm(t)
+
+	mock.ExpectQuery(regexp.QuoteMeta(selectUserQuery)).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(userColumns).AddRow(1, "Old Name", nil, nil, nil, nil, 2))
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(updateUserQuery)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(insertVersionQuery)).
+		WillReturnError(errInternal)
+	mock.ExpectRollback()
+
+	storage := &Storage{
+		db:       db,
+		logger:   zap.NewNop(),
+		versions: true,
+	}
+
+	user := &common.User{ID: 1, Name: mockUserName}
+
+	_, err := storage.Update(user, 2)
+	assert.Equal(t, errInternal, err)
+	assert.Equal(t, int64(0), user.Version)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestPostgres_DeleteWithVersions tests the scenario where the versions of a deleted user are removed with it
+func TestPostgres_DeleteWithVersions(t *testing.T) {
+	t.Parallel()
+
+	db, mock := createMockedGorm(t)
+
+	mock.ExpectQuery(regexp.QuoteMeta(selectUserQuery)).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(userColumns).AddRow(1, mockUserName, nil, nil, nil, nil, 3))
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(deleteUserQuery)).
+		WithArgs(1, 3).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(deleteVersionsQuery)).
+		WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectCommit()
+
+	storage := &Storage{
+		db:       db,
+		logger:   zap.NewNop(),
+		versions: true,
+	}
+
+	_, err := storage.Delete(1, common.AnyVersion)
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestPostgres_PurgeWithVersions tests the scenario where the versions of purged users are removed with them
+func TestPostgres_PurgeWithVersions(t *testing.T) {
+	t.Parallel()
+
+	db, mock := createMockedGorm(t)
+
+	before := time.Date(2023, 9, 1, 12, 0, 0, 0, time.UTC)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "user_versions" WHERE user_id IN (SELECT "id" FROM "users" WHERE deleted_at < $1)`)).
+		WithArgs(before).
+		WillReturnResult(sqlmock.NewResult(0, 6))
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "users" WHERE deleted_at < $1`)).
+		WithArgs(before).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	storage := &Storage{
+		db:       db,
+		logger:   zap.NewNop(),
+		versions: true,
+	}
+
+	purged, err := storage.Purge(before)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, purged)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestPostgres_GetAsOf tests the scenario where the version written at or before the given time is returned
+func TestPostgres_GetAsOf(t *testing.T) {
+	t.Parallel()
+
+	db, mock := createMockedGorm(t)
+
+	createdAt := time.Date(2023, 9, 1, 12, 0, 0, 0, time.UTC)
+	updatedAt := createdAt.Add(time.Hour)
+	at := createdAt.Add(time.Minute)
+
+	mock.ExpectQuery(regexp.QuoteMeta(selectUserQuery)).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(userColumns).AddRow(1, "New Name", "", `{}`, createdAt, updatedAt, 2))
+	mock.ExpectQuery(regexp.QuoteMeta(selectVersionAsOfQuery)).
+		WithArgs(1, at).
+		WillReturnRows(sqlmock.NewRows(versionColumns).AddRow(1, 1, "Old Name", "", `{}`, createdAt, createdAt, nil))
+	mock.ExpectQuery(regexp.QuoteMeta(selectUserQuery)).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(userColumns).AddRow(1, "New Name", "", `{}`, createdAt, updatedAt, 2))
+
+	storage := &Storage{
+		db:     db,
+		logger: zap.NewNop(),
+	}
+
+	user, err := storage.GetAsOf(1, at)
+	assert.NoError(t, err)
+	assert.Equal(t, "Old Name", user.Name)
+	assert.Equal(t, int64(1), user.Version)
+
+	// The current record is returned without a query of the versions
+	user, err = storage.GetAsOf(1, updatedAt)
+	assert.NoError(t, err)
+	assert.Equal(t, "New Name", user.Name)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestPostgres_GetAsOfNotRetained tests the scenario where no version was written at or before the given time
+func TestPostgres_GetAsOfNotRetained(t *testing.T) {
+	t.Parallel()
+
+	db, mock := createMockedGorm(t)
+
+	createdAt := time.Date(2023, 9, 1, 12, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery(regexp.QuoteMeta(selectUserQuery)).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(userColumns).AddRow(1, mockUserName, "", `{}`, createdAt, createdAt, 1))
+	mock.ExpectQuery(regexp.QuoteMeta(selectVersionAsOfQuery)).
+		WillReturnRows(sqlmock.NewRows(versionColumns))
+
+	storage := &Storage{
+		db:     db,
+		logger: zap.NewNop(),
+	}
+
+	_, err := storage.GetAsOf(1, createdAt.Add(-time.Hour))
+	assert.Equal(t, common.ErrUserNotFound, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestPostgres_Versions tests the scenario where the current record is listed before the versions kept for it
+func TestPostgres_Versions(t *testing.T) {
+	t.Parallel()
+
+	db, mock := createMockedGorm(t)
+
+	createdAt := time.Date(2023, 9, 1, 12, 0, 0, 0, time.UTC)
+
+	// The current version was written before versions were kept
+	mock.ExpectQuery(regexp.QuoteMeta(selectUserQuery)).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(userColumns).AddRow(1, "Newest", "", `{}`, createdAt, createdAt, 3))
+	mock.ExpectQuery(regexp.QuoteMeta(selectVersionsQuery)).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(versionColumns).
+			AddRow(1, 2, "Newer", "", `{}`, createdAt, createdAt, nil).
+			AddRow(1, 1, "Oldest", "", `{}`, createdAt, createdAt, nil))
+
+	storage := &Storage{
+		db:     db,
+		logger: zap.NewNop(),
+	}
+
+	versions, err := storage.Versions(1)
+	assert.NoError(t, err)
+	assert.Len(t, versions, 3)
+	assert.Equal(t, []int64{3, 2, 1}, []int64{versions[0].Version, versions[1].Version, versions[2].Version})
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestPostgres_CompactVersions tests the scenario where versions replaced before the given time are removed
+func TestPostgres_CompactVersions(t *testing.T) {
+	t.Parallel()
+
+	db, mock := createMockedGorm(t)
+
+	before := time.Date(2023, 9, 1, 12, 0, 0, 0, time.UTC)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "user_versions" WHERE ` + strings.Replace(replacedVersion, "?", "$1", 1))).
+		WithArgs(before).
+		WillReturnResult(sqlmock.NewResult(0, 5))
+	mock.ExpectCommit()
+
+	storage := &Storage{
+		db:     db,
+		logger: zap.NewNop(),
+	}
+
+	compacted, err := storage.CompactVersions(before)
+	assert.NoError(t, err)
+	assert.Equal(t, 5, compacted)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
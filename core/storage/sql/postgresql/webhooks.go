@@ -0,0 +1,305 @@
+package postgresql
+
+import (
+	"errors"
+	"time"
+
+	"github.com/Aleksao998/LightningUserVault/core/events"
+	"github.com/Aleksao998/LightningUserVault/core/webhook"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// webhookCursorID is the ID of the single row of the webhook_cursors table
+const webhookCursorID = 1
+
+// Webhook is a registered webhook endpoint
+type Webhook struct {
+	ID        int64         `gorm:"primaryKey"`
+	URL       string        `gorm:"type:varchar(2048);not null"`
+	Secret    string        `gorm:"type:varchar(255)"`
+	Events    []events.Type `gorm:"type:text;serializer:json"`
+	CreatedAt time.Time     `gorm:"autoCreateTime:false"`
+}
+
+// WebhookDelivery is a queued delivery of an event to a webhook, pending deliveries are selected by their next attempt
+type WebhookDelivery struct {
+	ID             int64          `gorm:"primaryKey"`
+	WebhookID      int64          `gorm:"not null;index"`
+	Event          *events.Event  `gorm:"type:text;serializer:json"`
+	Status         webhook.Status `gorm:"type:varchar(16);not null;index:idx_webhook_deliveries_due,priority:1"`
+	Attempts       int            `gorm:"not null"`
+	NextAttempt    time.Time      `gorm:"index:idx_webhook_deliveries_due,priority:2"`
+	LastStatusCode int
+	LastError      string    `gorm:"type:text"`
+	CreatedAt      time.Time `gorm:"autoCreateTime:false"`
+	UpdatedAt      time.Time `gorm:"autoUpdateTime:false"`
+}
+
+// WebhookCursor holds the ID of the last event which was queued for webhooks, the table has a single row
+type WebhookCursor struct {
+	ID      int64 `gorm:"primaryKey;autoIncrement:false"`
+	EventID int64 `gorm:"not null"`
+}
+
+// dbTime returns the time in the precision and location kept by the database
+func dbTime(t time.Time) time.Time {
+	return t.UTC().Truncate(time.Microsecond)
+}
+
+// newWebhookDelivery converts a delivery into its database model
+func newWebhookDelivery(delivery *webhook.Delivery) WebhookDelivery {
+	return WebhookDelivery{
+		ID:             delivery.ID,
+		WebhookID:      delivery.WebhookID,
+		Event:          delivery.Event,
+		Status:         delivery.Status,
+		Attempts:       delivery.Attempts,
+		NextAttempt:    dbTime(delivery.NextAttempt),
+		LastStatusCode: delivery.LastStatusCode,
+		LastError:      delivery.LastError,
+		CreatedAt:      dbTime(delivery.CreatedAt),
+		UpdatedAt:      dbTime(delivery.UpdatedAt),
+	}
+}
+
+// delivery converts the model back into a delivery
+func (d WebhookDelivery) delivery() *webhook.Delivery {
+	return &webhook.Delivery{
+		ID:             d.ID,
+		WebhookID:      d.WebhookID,
+		Event:          d.Event,
+		Status:         d.Status,
+		Attempts:       d.Attempts,
+		NextAttempt:    d.NextAttempt,
+		LastStatusCode: d.LastStatusCode,
+		LastError:      d.LastError,
+		CreatedAt:      d.CreatedAt,
+		UpdatedAt:      d.UpdatedAt,
+	}
+}
+
+// webhook converts the model back into a webhook
+func (w Webhook) webhook() *webhook.Webhook {
+	return &webhook.Webhook{
+		ID:        w.ID,
+		URL:       w.URL,
+		Secret:    w.Secret,
+		Events:    w.Events,
+		CreatedAt: w.CreatedAt,
+	}
+}
+
+// AddWebhook stores the webhook, filling its ID
+func (p *Storage) AddWebhook(hook *webhook.Webhook) error {
+	row := Webhook{
+		URL:       hook.URL,
+		Secret:    hook.Secret,
+		Events:    hook.Events,
+		CreatedAt: dbTime(hook.CreatedAt),
+	}
+
+	if err := p.db.Create(&row).Error; err != nil {
+		p.logger.Error("Failed to store webhook in database", zap.String("url", hook.URL), zap.Error(err))
+
+		return err
+	}
+
+	hook.ID = row.ID
+
+	return nil
+}
+
+// GetWebhook returns the webhook with the given ID
+func (p *Storage) GetWebhook(id int64) (*webhook.Webhook, error) {
+	var row Webhook
+
+	if err := p.db.First(&row, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, webhook.ErrWebhookNotFound
+		}
+
+		p.logger.Error("Failed to retrieve webhook from database", zap.Int64("ID", id), zap.Error(err))
+
+		return nil, err
+	}
+
+	return row.webhook(), nil
+}
+
+// ListWebhooks returns all webhooks ordered by ID
+func (p *Storage) ListWebhooks() ([]*webhook.Webhook, error) {
+	var rows []Webhook
+
+	if err := p.db.Model(&Webhook{}).Order("id").Find(&rows).Error; err != nil {
+		p.logger.Error("Failed to list webhooks from database", zap.Error(err))
+
+		return nil, err
+	}
+
+	webhooks := make([]*webhook.Webhook, 0, len(rows))
+	for _, row := range rows {
+		webhooks = append(webhooks, row.webhook())
+	}
+
+	return webhooks, nil
+}
+
+// RemoveWebhook removes the webhook with the given ID
+func (p *Storage) RemoveWebhook(id int64) error {
+	result := p.db.Delete(&Webhook{}, id)
+	if result.Error != nil {
+		p.logger.Error("Failed to remove webhook from database", zap.Int64("ID", id), zap.Error(result.Error))
+
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return webhook.ErrWebhookNotFound
+	}
+
+	return nil
+}
+
+// WebhookCursor returns the ID of the last queued event
+func (p *Storage) WebhookCursor() (int64, error) {
+	var cursor WebhookCursor
+
+	if err := p.db.First(&cursor, webhookCursorID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, nil
+		}
+
+		p.logger.Error("Failed to retrieve webhook cursor from database", zap.Error(err))
+
+		return 0, err
+	}
+
+	return cursor.EventID, nil
+}
+
+// EnqueueDeliveries inserts the deliveries, filling their IDs, and moves the cursor in the same transaction
+func (p *Storage) EnqueueDeliveries(cursor int64, deliveries []*webhook.Delivery) error {
+	rows := make([]WebhookDelivery, 0, len(deliveries))
+	for _, delivery := range deliveries {
+		rows = append(rows, newWebhookDelivery(delivery))
+	}
+
+	err := p.db.Transaction(func(tx *gorm.DB) error {
+		if len(rows) > 0 {
+			if err := tx.Create(&rows).Error; err != nil {
+				return err
+			}
+		}
+
+		return tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"event_id"}),
+		}).Create(&WebhookCursor{ID: webhookCursorID, EventID: cursor}).Error
+	})
+	if err != nil {
+		p.logger.Error("Failed to queue webhook deliveries in database", zap.Int64("cursor", cursor), zap.Error(err))
+
+		return err
+	}
+
+	for i, delivery := range deliveries {
+		delivery.ID = rows[i].ID
+	}
+
+	return nil
+}
+
+// DueDeliveries returns up to limit pending deliveries whose next attempt is not after the given time, oldest first
+func (p *Storage) DueDeliveries(now time.Time, limit int) ([]*webhook.Delivery, error) {
+	var rows []WebhookDelivery
+
+	result := p.db.Where("status = ? AND next_attempt <= ?", webhook.Pending, now.UTC()).
+		Order("next_attempt, id").Limit(limit).Find(&rows)
+	if result.Error != nil {
+		p.logger.Error("Failed to retrieve due webhook deliveries from database", zap.Error(result.Error))
+
+		return nil, result.Error
+	}
+
+	return webhookDeliveries(rows), nil
+}
+
+// GetDelivery returns the delivery with the given ID
+func (p *Storage) GetDelivery(id int64) (*webhook.Delivery, error) {
+	var row WebhookDelivery
+
+	if err := p.db.First(&row, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, webhook.ErrDeliveryNotFound
+		}
+
+		p.logger.Error("Failed to retrieve webhook delivery from database", zap.Int64("ID", id), zap.Error(err))
+
+		return nil, err
+	}
+
+	return row.delivery(), nil
+}
+
+// ListDeliveries returns deliveries selected by the filter, newest first
+func (p *Storage) ListDeliveries(filter webhook.DeliveryFilter) ([]*webhook.Delivery, error) {
+	var rows []WebhookDelivery
+
+	query := p.db.Model(&WebhookDelivery{})
+
+	if filter.WebhookID != 0 {
+		query = query.Where("webhook_id = ?", filter.WebhookID)
+	}
+
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+
+	if err := query.Order("id DESC").Limit(filter.Limit).Find(&rows).Error; err != nil {
+		p.logger.Error("Failed to list webhook deliveries from database", zap.Error(err))
+
+		return nil, err
+	}
+
+	return webhookDeliveries(rows), nil
+}
+
+// UpdateDelivery stores the changed state of the delivery
+func (p *Storage) UpdateDelivery(delivery *webhook.Delivery) error {
+	row := newWebhookDelivery(delivery)
+
+	result := p.db.Model(&WebhookDelivery{}).Where("id = ?", delivery.ID).Updates(map[string]interface{}{
+		"status":           row.Status,
+		"attempts":         row.Attempts,
+		"next_attempt":     row.NextAttempt,
+		"last_status_code": row.LastStatusCode,
+		"last_error":       row.LastError,
+		"updated_at":       row.UpdatedAt,
+	})
+	if result.Error != nil {
+		p.logger.Error("Failed to update webhook delivery in database", zap.Int64("ID", delivery.ID), zap.Error(result.Error))
+
+		return result.Error
+	}
+
+	// MySQL does not count rows which were written with the same values, so only a missing row is reported
+	if result.RowsAffected == 0 {
+		if _, err := p.GetDelivery(delivery.ID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// webhookDeliveries converts the models back into deliveries
+func webhookDeliveries(rows []WebhookDelivery) []*webhook.Delivery {
+	deliveries := make([]*webhook.Delivery, 0, len(rows))
+	for _, row := range rows {
+		deliveries = append(deliveries, row.delivery())
+	}
+
+	return deliveries
+}
@@ -0,0 +1,82 @@
+package sqlite
+
+import (
+	"errors"
+	"time"
+
+	"github.com/Aleksao998/LightningUserVault/core/common"
+	"github.com/Aleksao998/LightningUserVault/core/storage/sql/postgresql"
+	driver "github.com/glebarez/sqlite"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// connectionParams configures every connection to the database file.
+// WAL lets readers run alongside the single writer, LIKE is made case sensitive as it is on PostgreSQL
+// and transactions take the write lock when they begin, so concurrent writers wait instead of failing
+const connectionParams = "?_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)&_pragma=case_sensitive_like(1)&_txlock=immediate"
+
+// errEmptyPath is returned when no database file is given, it would open a file named after the connection parameters
+var errEmptyPath = errors.New("empty SQLite database path")
+
+// Options configures optional behaviour of the storage
+type Options struct {
+	// KeepVersions stores an immutable copy of every written version of a user in the user_versions table
+	KeepVersions bool
+
+	// Webhooks creates the tables keeping webhooks and their delivery queue
+	Webhooks bool
+
+	// Audit creates the table keeping the audit log of user writes
+	Audit bool
+}
+
+// Storage stores users in a SQLite database file, using the same models and queries as the PostgreSQL storage.
+// SQLite does not support SKIP LOCKED, so the storage is never used with the outbox
+type Storage struct {
+	*postgresql.Storage
+}
+
+// NewStorage initializes a new Storage instance with a database at the given path
+func NewStorage(logger *zap.Logger, path string, options Options) (*Storage, error) {
+	if path == "" {
+		logger.Error("Failed to open SQLite database", zap.Error(errEmptyPath))
+
+		return nil, errEmptyPath
+	}
+
+	db, err := gorm.Open(driver.Open(path+connectionParams), &gorm.Config{})
+	if err != nil {
+		logger.Error("Failed to open SQLite database", zap.String("path", path), zap.Error(err))
+
+		return nil, err
+	}
+
+	storageOptions := postgresql.Options{KeepVersions: options.KeepVersions, Webhooks: options.Webhooks, Audit: options.Audit}
+
+	if err = postgresql.Migrate(logger, db, storageOptions); err != nil {
+		return nil, err
+	}
+
+	logger.Info("Successfully initialized SQLite storage", zap.String("path", path))
+
+	return &Storage{
+		Storage: postgresql.NewStorageWithDB(logger, db, storageOptions),
+	}, nil
+}
+
+// Purge permanently removes users soft deleted before the given time.
+// SQLite compares times as text, so the time is converted to UTC like all stored times
+func (s *Storage) Purge(before time.Time) (int, error) {
+	return s.Storage.Purge(before.UTC())
+}
+
+// GetAsOf returns the latest version of the user which was written at or before the given time
+func (s *Storage) GetAsOf(id int64, at time.Time) (*common.User, error) {
+	return s.Storage.GetAsOf(id, at.UTC())
+}
+
+// CompactVersions removes every version which was replaced by a newer version written at or before the given time
+func (s *Storage) CompactVersions(before time.Time) (int, error) {
+	return s.Storage.CompactVersions(before.UTC())
+}
@@ -0,0 +1,326 @@
+package sqlite
+
+import (
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Aleksao998/LightningUserVault/core/audit"
+	"github.com/Aleksao998/LightningUserVault/core/audit/audittest"
+	"github.com/Aleksao998/LightningUserVault/core/common"
+	"github.com/Aleksao998/LightningUserVault/core/webhook"
+	"github.com/Aleksao998/LightningUserVault/core/webhook/webhooktest"
+	driver "github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+// createStorage creates a storage with a database file in a temporary directory
+func createStorage(t *testing.T, options Options) *Storage {
+	t.Helper()
+
+	store, err := NewStorage(zap.NewNop(), filepath.Join(t.TempDir(), "vault.db"), options)
+	if err != nil {
+		t.Fatalf("error creating sqlite storage, %v", err)
+	}
+
+	t.Cleanup(func() {
+		store.Close()
+	})
+
+	return store
+}
+
+// TestSQLite_WriteRead tests that stored users are read back with their IDs, timestamps and versions
+func TestSQLite_WriteRead(t *testing.T) {
+	t.Parallel()
+
+	store := createStorage(t, Options{})
+
+	user := &common.User{Name: "alice", Email: "alice@example.com", Attributes: map[string]string{"team": "a"}}
+
+	id, err := store.Set(user)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), id)
+	assert.Equal(t, int64(1), user.Version)
+
+	retrieved, err := store.Get(id)
+	assert.NoError(t, err)
+	assert.Equal(t, "alice@example.com", retrieved.Email)
+	assert.Equal(t, "a", retrieved.Attributes["team"])
+	assert.True(t, user.CreatedAt.Equal(retrieved.CreatedAt))
+
+	_, err = store.Get(int64(100))
+	assert.ErrorIs(t, err, common.ErrUserNotFound)
+
+	ids, err := store.SetBatch([]*common.User{{Name: "bob"}, {Name: "carol"}})
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{2, 3}, ids)
+
+	users, err := store.GetMulti([]int64{1, 3, 100})
+	assert.NoError(t, err)
+	assert.Len(t, users, 2)
+	assert.Equal(t, "carol", users[3].Name)
+}
+
+// TestSQLite_EmptyPath tests that the storage is not opened without a database file
+func TestSQLite_EmptyPath(t *testing.T) {
+	t.Parallel()
+
+	_, err := NewStorage(zap.NewNop(), "", Options{})
+	assert.ErrorIs(t, err, errEmptyPath)
+}
+
+// TestSQLite_WALMode tests that the database file is switched to WAL mode, which is kept in the file
+func TestSQLite_WALMode(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "vault.db")
+
+	store, err := NewStorage(zap.NewNop(), path, Options{})
+	assert.NoError(t, err)
+	assert.NoError(t, store.Close())
+
+	sqlDB, err := sql.Open(driver.DriverName, path)
+	assert.NoError(t, err)
+
+	defer sqlDB.Close()
+
+	var mode string
+
+	assert.NoError(t, sqlDB.QueryRow("PRAGMA journal_mode").Scan(&mode))
+	assert.Equal(t, "wal", mode)
+}
+
+// TestSQLite_WriteParallel tests that concurrent writers all succeed with unique IDs
+func TestSQLite_WriteParallel(t *testing.T) {
+	t.Parallel()
+
+	store := createStorage(t, Options{KeepVersions: true})
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			_, err := store.Set(&common.User{Name: "user"})
+			assert.NoError(t, err)
+		}()
+	}
+
+	wg.Wait()
+
+	users, _, err := store.List("", 100)
+	assert.NoError(t, err)
+	assert.Len(t, users, 20)
+}
+
+// TestSQLite_UpdateAndDelete tests the errors of conditional updates and deletes
+func TestSQLite_UpdateAndDelete(t *testing.T) {
+	t.Parallel()
+
+	store := createStorage(t, Options{})
+
+	id, err := store.Set(&common.User{Name: "alice", Email: "alice@example.com"})
+	assert.NoError(t, err)
+
+	user := &common.User{ID: id, Name: "bob"}
+
+	_, err = store.Update(user, 2)
+	assert.ErrorIs(t, err, common.ErrVersionMismatch)
+	replaced, err := store.Update(user, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), user.Version)
+	assert.Equal(t, "alice", replaced.Name)
+	assert.Equal(t, int64(1), replaced.Version)
+	_, err = store.Update(&common.User{ID: 100}, common.AnyVersion)
+	assert.ErrorIs(t, err, common.ErrUserNotFound)
+
+	retrieved, err := store.Get(id)
+	assert.NoError(t, err)
+	assert.Equal(t, "bob", retrieved.Name)
+	assert.Empty(t, retrieved.Email)
+
+	_, err = store.Delete(id, 1)
+	assert.ErrorIs(t, err, common.ErrVersionMismatch)
+	removed, err := store.Delete(id, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, "bob", removed.Name)
+	_, err = store.Delete(id, common.AnyVersion)
+	assert.ErrorIs(t, err, common.ErrUserNotFound)
+}
+
+// TestSQLite_FindByName tests that prefix search is case sensitive and treats wildcards literally, as on PostgreSQL
+func TestSQLite_FindByName(t *testing.T) {
+	t.Parallel()
+
+	store := createStorage(t, Options{})
+
+	_, err := store.SetBatch([]*common.User{{Name: "Alice"}, {Name: "alice"}, {Name: "al_ce"}})
+	assert.NoError(t, err)
+
+	users, err := store.FindByName("al", true)
+	assert.NoError(t, err)
+	assert.Len(t, users, 2)
+
+	users, err = store.FindByName("al_", true)
+	assert.NoError(t, err)
+	assert.Len(t, users, 1)
+	assert.Equal(t, "al_ce", users[0].Name)
+
+	users, err = store.FindByName("Alice", false)
+	assert.NoError(t, err)
+	assert.Len(t, users, 1)
+}
+
+// TestSQLite_List tests that users are listed in ID order across pages
+func TestSQLite_List(t *testing.T) {
+	t.Parallel()
+
+	store := createStorage(t, Options{})
+
+	_, err := store.SetBatch([]*common.User{{Name: "alice"}, {Name: "bob"}, {Name: "carol"}})
+	assert.NoError(t, err)
+
+	users, cursor, err := store.List("", 2)
+	assert.NoError(t, err)
+	assert.Len(t, users, 2)
+
+	users, cursor, err = store.List(cursor, 2)
+	assert.NoError(t, err)
+	assert.Len(t, users, 1)
+	assert.Equal(t, "carol", users[0].Name)
+	assert.Empty(t, cursor)
+
+	_, _, err = store.List("invalid", 2)
+	assert.ErrorIs(t, err, common.ErrInvalidCursor)
+}
+
+// TestSQLite_SoftDeleteAndPurge tests that soft deleted users can be restored and are purged with a local time
+func TestSQLite_SoftDeleteAndPurge(t *testing.T) {
+	t.Parallel()
+
+	store := createStorage(t, Options{KeepVersions: true})
+
+	ids, err := store.SetBatch([]*common.User{{Name: "alice"}, {Name: "bob"}})
+	assert.NoError(t, err)
+
+	replaced, err := store.SoftDelete(ids[0], 1)
+	assert.NoError(t, err)
+	assert.Equal(t, "alice", replaced.Name)
+	assert.Equal(t, int64(1), replaced.Version)
+	assert.Nil(t, replaced.DeletedAt)
+	_, err = store.SoftDelete(ids[0], common.AnyVersion)
+	assert.ErrorIs(t, err, common.ErrUserDeleted)
+
+	_, err = store.Get(ids[0])
+	assert.ErrorIs(t, err, common.ErrUserDeleted)
+
+	_, _, err = store.Restore(ids[1])
+	assert.ErrorIs(t, err, common.ErrUserNotDeleted)
+
+	restored, before, err := store.Restore(ids[0])
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), restored.Version)
+	assert.Equal(t, int64(2), before.Version)
+	assert.NotNil(t, before.DeletedAt)
+
+	_, err = store.SoftDelete(ids[1], common.AnyVersion)
+	assert.NoError(t, err)
+
+	purged, err := store.Purge(time.Now().In(time.FixedZone("UTC+2", 2*60*60)).Add(time.Second))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, purged)
+
+	_, err = store.Versions(ids[1])
+	assert.ErrorIs(t, err, common.ErrUserNotFound)
+}
+
+// TestSQLite_Versions tests point-in-time reads and compaction of versions
+func TestSQLite_Versions(t *testing.T) {
+	t.Parallel()
+
+	store := createStorage(t, Options{KeepVersions: true})
+
+	user := &common.User{Name: "alice"}
+
+	id, err := store.Set(user)
+	assert.NoError(t, err)
+
+	_, err = store.Update(&common.User{ID: id, Name: "bob"}, common.AnyVersion)
+	assert.NoError(t, err)
+
+	before := time.Now()
+
+	_, err = store.Update(&common.User{ID: id, Name: "carol"}, common.AnyVersion)
+	assert.NoError(t, err)
+
+	retrieved, err := store.GetAsOf(id, user.UpdatedAt)
+	assert.NoError(t, err)
+	assert.Equal(t, "alice", retrieved.Name)
+
+	versions, err := store.Versions(id)
+	assert.NoError(t, err)
+	assert.Len(t, versions, 3)
+	assert.Equal(t, "carol", versions[0].Name)
+
+	compacted, err := store.CompactVersions(before)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, compacted)
+
+	retrieved, err = store.GetAsOf(id, before)
+	assert.NoError(t, err)
+	assert.Equal(t, "bob", retrieved.Name)
+}
+
+// TestSQLite_SetLargeBatch tests that the largest batch accepted by the user handler is stored together with its versions,
+// although it has more columns than SQLite accepts bind parameters in a single statement
+func TestSQLite_SetLargeBatch(t *testing.T) {
+	t.Parallel()
+
+	const batchSize = 10000
+
+	store := createStorage(t, Options{KeepVersions: true})
+
+	users := make([]*common.User, 0, batchSize)
+	for i := 0; i < batchSize; i++ {
+		users = append(users, &common.User{Name: fmt.Sprintf("User-%d", i)})
+	}
+
+	ids, err := store.SetBatch(users)
+	assert.NoError(t, err)
+	assert.Len(t, ids, batchSize)
+	assert.Equal(t, int64(batchSize), users[batchSize-1].ID)
+
+	retrieved, err := store.Get(int64(batchSize))
+	assert.NoError(t, err)
+	assert.Equal(t, fmt.Sprintf("User-%d", batchSize-1), retrieved.Name)
+
+	versions, err := store.Versions(int64(batchSize))
+	assert.NoError(t, err)
+	assert.Len(t, versions, 1)
+}
+
+// TestSQLite_WebhookConformance runs the shared webhook store suite against the tables of the shared SQL storage
+func TestSQLite_WebhookConformance(t *testing.T) {
+	webhooktest.RunConformance(t, func(t *testing.T) webhook.Store {
+		t.Helper()
+
+		return createStorage(t, Options{Webhooks: true})
+	})
+}
+
+// TestSQLite_AuditConformance runs the shared audit store suite against the table of the shared SQL storage
+func TestSQLite_AuditConformance(t *testing.T) {
+	audittest.RunConformance(t, func(t *testing.T) audit.Store {
+		t.Helper()
+
+		return createStorage(t, Options{Audit: true})
+	})
+}
@@ -0,0 +1,258 @@
+package sqltest
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Aleksao998/LightningUserVault/core/common"
+	"github.com/Aleksao998/LightningUserVault/core/storage/mocks"
+	"github.com/Aleksao998/LightningUserVault/core/storage/sql"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+var errInternal = errors.New("internal db error")
+
+// Storage is the part of the user storage covered by the conformance suite
+type Storage interface {
+	Get(key int64) (*common.User, error)
+	GetMulti(keys []int64) (map[int64]*common.User, error)
+	Set(user *common.User) (int64, error)
+	SetBatch(users []*common.User) ([]int64, error)
+	Update(user *common.User, version int64) (*common.User, error)
+	Delete(key int64, version int64) (*common.User, error)
+	SoftDelete(key int64, version int64) (*common.User, error)
+	Restore(key int64) (*common.User, *common.User, error)
+	List(cursor string, limit int) ([]*common.User, string, error)
+}
+
+// NewStorageFn creates the storage under test on top of the given database
+type NewStorageFn func(db sql.DBHandler) Storage
+
+// firstUser returns a First delegate which reads the given user, or fails with the given error
+func firstUser(user *common.User, err error) mocks.FirstDelegate {
+	return func(out interface{}, where ...interface{}) *gorm.DB {
+		if err != nil {
+			return &gorm.DB{Error: err}
+		}
+
+		if u, ok := out.(*common.User); ok {
+			*u = *user
+		}
+
+		return &gorm.DB{}
+	}
+}
+
+// assignIDs returns a Create delegate which assigns increasing IDs to the inserted rows, or fails with the given error.
+// Rows are models of the storage under test, so their ID field is set by name
+func assignIDs(err error) mocks.CreateInBatchesDelegate {
+	return func(value interface{}, batchSize int) *gorm.DB {
+		if err != nil {
+			return &gorm.DB{Error: err}
+		}
+
+		rows := reflect.Indirect(reflect.ValueOf(value))
+		if rows.Kind() != reflect.Slice {
+			rows.FieldByName("ID").SetInt(1)
+
+			return &gorm.DB{}
+		}
+
+		for i := 0; i < rows.Len(); i++ {
+			rows.Index(i).FieldByName("ID").SetInt(int64(i + 1))
+		}
+
+		return &gorm.DB{}
+	}
+}
+
+// RunConformance runs the suite checking that a SQL storage maps database results to the same users and errors
+// as the other storages. Every case uses a fresh mocked database, so storages must not query it on creation
+func RunConformance(t *testing.T, newStorage NewStorageFn) {
+	t.Helper()
+
+	deletedAt := time.Date(2023, 9, 1, 12, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name string
+		db   *mocks.MockSQLdb
+		run  func(t *testing.T, storage Storage)
+	}{
+		{
+			name: "Get existing user",
+			db:   &mocks.MockSQLdb{FirstFn: firstUser(&common.User{ID: 1, Name: "User-1", Version: 2}, nil)},
+			run: func(t *testing.T, storage Storage) {
+				user, err := storage.Get(1)
+				assert.NoError(t, err)
+				assert.Equal(t, "User-1", user.Name)
+				assert.Equal(t, int64(2), user.Version)
+			},
+		},
+		{
+			name: "Get missing user",
+			db:   &mocks.MockSQLdb{FirstFn: firstUser(nil, gorm.ErrRecordNotFound)},
+			run: func(t *testing.T, storage Storage) {
+				_, err := storage.Get(1)
+				assert.ErrorIs(t, err, common.ErrUserNotFound)
+			},
+		},
+		{
+			name: "Get with database error",
+			db:   &mocks.MockSQLdb{FirstFn: firstUser(nil, errInternal)},
+			run: func(t *testing.T, storage Storage) {
+				_, err := storage.Get(1)
+				assert.ErrorIs(t, err, errInternal)
+			},
+		},
+		{
+			name: "Get soft deleted user",
+			db:   &mocks.MockSQLdb{FirstFn: firstUser(&common.User{ID: 1, DeletedAt: &deletedAt}, nil)},
+			run: func(t *testing.T, storage Storage) {
+				_, err := storage.Get(1)
+				assert.ErrorIs(t, err, common.ErrUserDeleted)
+			},
+		},
+		{
+			name: "Get multiple users omits soft deleted users",
+			db: &mocks.MockSQLdb{
+				FindFn: func(dest interface{}, conds ...interface{}) *gorm.DB {
+					users, _ := dest.(*[]*common.User)
+					*users = []*common.User{{ID: 1}, {ID: 2, DeletedAt: &deletedAt}}
+
+					return &gorm.DB{}
+				},
+			},
+			run: func(t *testing.T, storage Storage) {
+				users, err := storage.GetMulti([]int64{1, 2, 3})
+				assert.NoError(t, err)
+				assert.Len(t, users, 1)
+				assert.Contains(t, users, int64(1))
+			},
+		},
+		{
+			name: "Set fills ID, timestamps and first version",
+			db:   &mocks.MockSQLdb{CreateInBatchesFn: assignIDs(nil)},
+			run: func(t *testing.T, storage Storage) {
+				user := &common.User{Name: "User-1"}
+
+				id, err := storage.Set(user)
+				assert.NoError(t, err)
+				assert.Equal(t, int64(1), id)
+				assert.Equal(t, int64(1), user.ID)
+				assert.Equal(t, int64(1), user.Version)
+				assert.False(t, user.CreatedAt.IsZero())
+				assert.Equal(t, user.CreatedAt, user.UpdatedAt)
+			},
+		},
+		{
+			name: "Set with database error",
+			db:   &mocks.MockSQLdb{CreateInBatchesFn: assignIDs(errInternal)},
+			run: func(t *testing.T, storage Storage) {
+				_, err := storage.Set(&common.User{Name: "User-1"})
+				assert.ErrorIs(t, err, errInternal)
+			},
+		},
+		{
+			name: "Set batch returns IDs in order",
+			db:   &mocks.MockSQLdb{CreateInBatchesFn: assignIDs(nil)},
+			run: func(t *testing.T, storage Storage) {
+				users := []*common.User{{Name: "User-1"}, {Name: "User-2"}}
+
+				ids, err := storage.SetBatch(users)
+				assert.NoError(t, err)
+				assert.Equal(t, []int64{1, 2}, ids)
+				assert.Equal(t, int64(2), users[1].ID)
+			},
+		},
+		{
+			name: "Update missing user",
+			db:   &mocks.MockSQLdb{FirstFn: firstUser(nil, gorm.ErrRecordNotFound)},
+			run: func(t *testing.T, storage Storage) {
+				_, err := storage.Update(&common.User{ID: 1}, common.AnyVersion)
+				assert.ErrorIs(t, err, common.ErrUserNotFound)
+			},
+		},
+		{
+			name: "Update at another version",
+			db:   &mocks.MockSQLdb{FirstFn: firstUser(&common.User{ID: 1, Version: 2}, nil)},
+			run: func(t *testing.T, storage Storage) {
+				_, err := storage.Update(&common.User{ID: 1}, 1)
+				assert.ErrorIs(t, err, common.ErrVersionMismatch)
+			},
+		},
+		{
+			name: "Update soft deleted user",
+			db:   &mocks.MockSQLdb{FirstFn: firstUser(&common.User{ID: 1, Version: 2, DeletedAt: &deletedAt}, nil)},
+			run: func(t *testing.T, storage Storage) {
+				_, err := storage.Update(&common.User{ID: 1}, common.AnyVersion)
+				assert.ErrorIs(t, err, common.ErrUserDeleted)
+			},
+		},
+		{
+			name: "Delete missing user",
+			db:   &mocks.MockSQLdb{FirstFn: firstUser(nil, gorm.ErrRecordNotFound)},
+			run: func(t *testing.T, storage Storage) {
+				_, err := storage.Delete(1, common.AnyVersion)
+				assert.ErrorIs(t, err, common.ErrUserNotFound)
+			},
+		},
+		{
+			name: "Delete with database error",
+			db: &mocks.MockSQLdb{
+				FirstFn: firstUser(&common.User{ID: 1, Version: 2}, nil),
+				DeleteFn: func(value interface{}, conds ...interface{}) *gorm.DB {
+					return &gorm.DB{Error: errInternal}
+				},
+			},
+			run: func(t *testing.T, storage Storage) {
+				_, err := storage.Delete(1, common.AnyVersion)
+				assert.ErrorIs(t, err, errInternal)
+			},
+		},
+		{
+			name: "Soft delete user which is already deleted",
+			db:   &mocks.MockSQLdb{FirstFn: firstUser(&common.User{ID: 1, Version: 2, DeletedAt: &deletedAt}, nil)},
+			run: func(t *testing.T, storage Storage) {
+				_, err := storage.SoftDelete(1, common.AnyVersion)
+				assert.ErrorIs(t, err, common.ErrUserDeleted)
+			},
+		},
+		{
+			name: "Restore user which is not deleted",
+			db:   &mocks.MockSQLdb{FirstFn: firstUser(&common.User{ID: 1, Version: 2}, nil)},
+			run: func(t *testing.T, storage Storage) {
+				_, _, err := storage.Restore(1)
+				assert.ErrorIs(t, err, common.ErrUserNotDeleted)
+			},
+		},
+		{
+			name: "Restore missing user",
+			db:   &mocks.MockSQLdb{FirstFn: firstUser(nil, gorm.ErrRecordNotFound)},
+			run: func(t *testing.T, storage Storage) {
+				_, _, err := storage.Restore(1)
+				assert.ErrorIs(t, err, common.ErrUserNotFound)
+			},
+		},
+		{
+			name: "List with invalid cursor",
+			db:   &mocks.MockSQLdb{},
+			run: func(t *testing.T, storage Storage) {
+				_, _, err := storage.List("invalid", 10)
+				assert.ErrorIs(t, err, common.ErrInvalidCursor)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			tc.run(t, newStorage(tc.db))
+		})
+	}
+}
@@ -3,11 +3,17 @@ package storage
 import (
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/Aleksao998/LightningUserVault/core/command/server/types"
 	"github.com/Aleksao998/LightningUserVault/core/common"
+	"github.com/Aleksao998/LightningUserVault/core/outbox"
+	"github.com/Aleksao998/LightningUserVault/core/storage/keyvalue/bolt"
 	"github.com/Aleksao998/LightningUserVault/core/storage/keyvalue/pebble"
+	"github.com/Aleksao998/LightningUserVault/core/storage/memory"
+	"github.com/Aleksao998/LightningUserVault/core/storage/sql/mysql"
 	"github.com/Aleksao998/LightningUserVault/core/storage/sql/postgresql"
+	"github.com/Aleksao998/LightningUserVault/core/storage/sql/sqlite"
 	"go.uber.org/zap"
 )
 
@@ -18,12 +24,64 @@ const (
 var errInvalidStorage = errors.New("invalid storage type")
 
 type Storage interface {
-	// Set stores a value and returns user ID and an error if any issue occurs during the operation
-	Set(value string) (int64, error)
+	// Set stores a new user and returns its ID, the ID, timestamps and version of the given user are filled in.
+	// A deletion mark of the given user is cleared, users are only marked as deleted by SoftDelete
+	Set(user *common.User) (int64, error)
 
-	// Get retrieves the value for a given user ID and returns an error if any issue occurs during the operation
+	// SetBatch atomically stores all users and returns their IDs in the same order, filling IDs, timestamps and versions of the users
+	SetBatch(users []*common.User) ([]int64, error)
+
+	// Get retrieves the value for a given user ID and returns an error if any issue occurs during the operation.
+	// It returns common.ErrUserDeleted if the user is soft deleted
 	Get(key int64) (*common.User, error)
 
+	// GetMulti retrieves all users found for the given user IDs, IDs which do not exist or are soft deleted are omitted from the result
+	GetMulti(keys []int64) (map[int64]*common.User, error)
+
+	// FindByName returns all users with the given name, or with names starting with it when prefix is set. Soft deleted users are omitted
+	FindByName(name string, prefix bool) ([]*common.User, error)
+
+	// Update overwrites the stored fields of the user with the same ID, filling its timestamps and incremented version,
+	// and returns the user it replaced. It returns common.ErrUserNotFound if the user does not exist and common.ErrVersionMismatch
+	// if the stored user is not at the given version, unless the version is common.AnyVersion.
+	// Soft deleted users can not be updated and common.ErrUserDeleted is returned for them, a deletion mark of the given user is cleared
+	Update(user *common.User, version int64) (*common.User, error)
+
+	// Delete permanently removes the user with the given ID, even if it is soft deleted, and returns the removed user.
+	// It returns common.ErrUserNotFound if the user does not exist and common.ErrVersionMismatch if the stored user
+	// is not at the given version, unless the version is common.AnyVersion
+	Delete(key int64, version int64) (*common.User, error)
+
+	// SoftDelete marks the user with the given ID as deleted and increments its version, the user is kept until it is restored or purged.
+	// It returns the user as it was before, or the same errors as Delete and common.ErrUserDeleted if the user is already soft deleted
+	SoftDelete(key int64, version int64) (*common.User, error)
+
+	// Restore brings back the soft deleted user with the given ID, filling its timestamps and incremented version.
+	// It returns the restored user and the soft deleted user it replaced, or common.ErrUserNotFound if the user does not exist
+	// and common.ErrUserNotDeleted if it is not soft deleted
+	Restore(key int64) (*common.User, *common.User, error)
+
+	// Purge permanently removes all users soft deleted before the given time and returns how many were removed
+	Purge(before time.Time) (int, error)
+
+	// GetAsOf retrieves the user with the given ID as it was at the given time, from its retained versions.
+	// It returns common.ErrUserNotFound if the user did not exist at that time or no version of that time is retained,
+	// and common.ErrUserDeleted if it was soft deleted at that time
+	GetAsOf(key int64, at time.Time) (*common.User, error)
+
+	// Versions returns all retained versions of the user with the given ID, newest first, including soft deleted versions.
+	// It returns common.ErrUserNotFound if the user does not exist. Permanently removed users keep no versions
+	Versions(key int64) ([]*common.User, error)
+
+	// CompactVersions removes versions which were replaced by a newer version before the given time and returns how many were removed.
+	// The version in effect at the given time is kept, so reads as of any later time are not affected
+	CompactVersions(before time.Time) (int, error)
+
+	// List returns up to limit users stored after the given opaque cursor, together with the cursor of the next page.
+	// An empty cursor starts from the beginning, an empty next cursor means there are no more users. Limit must be positive.
+	// Soft deleted users are omitted
+	List(cursor string, limit int) ([]*common.User, string, error)
+
 	// Close closes storage instance
 	Close() error
 }
@@ -35,20 +93,72 @@ type Config struct {
 	DBUser      string
 	DBPass      string
 	DBName      string
+
+	// DBPath is the path of the database file, used by SQLITE and BOLT
+	DBPath string
+
+	// DBCharset and DBCollation are the character set and collation of the connection and tables, used by MYSQL
+	DBCharset   string
+	DBCollation string
+
+	// Outbox records created users in an outbox relayed to an event sink, only POSTGRESQL and MYSQL support it
+	Outbox bool
+
+	// KeepVersions keeps an immutable copy of every version of a user for point-in-time reads
+	KeepVersions bool
+
+	// Webhooks keeps registered webhooks and their delivery queue next to the users
+	Webhooks bool
+
+	// Audit keeps the audit log of user writes next to the users
+	Audit bool
 }
 
 // GetStorage initializes and returns a storage instance based on the provided configuration
-// The method supports multiple storage types including PEBBLE and POSTGRESQL
+// The method supports multiple storage types including PEBBLE, POSTGRESQL, MEMORY, SQLITE, MYSQL and BOLT
 func GetStorage(logger *zap.Logger, config Config) (Storage, error) {
 	switch config.StorageType {
 	case types.PEBBLE:
-		return pebble.NewStorage(pebbleStorageRoute, logger)
+		return pebble.NewStorage(pebbleStorageRoute, logger, pebble.Options{KeepVersions: config.KeepVersions})
 	case types.POSTGRESQL:
 		psqlInfo := fmt.Sprintf("host=%s port=%s user=%s "+
 			"password=%s dbname=%s sslmode=disable",
 			config.DBHost, config.DBPort, config.DBName, config.DBPass, config.DBName)
 
-		return postgresql.NewStorage(logger, psqlInfo)
+		return postgresql.NewStorage(logger, psqlInfo, postgresql.Options{
+			Outbox:       config.Outbox,
+			KeepVersions: config.KeepVersions,
+			Webhooks:     config.Webhooks,
+			Audit:        config.Audit,
+		})
+	case types.MEMORY:
+		return memory.NewStorage(logger, memory.Options{KeepVersions: config.KeepVersions}), nil
+	case types.SQLITE:
+		// The embedded SQL storage can drain an outbox, but SQLite has no outbox table and no SKIP LOCKED
+		if config.Outbox {
+			return nil, outbox.ErrUnsupportedStorage
+		}
+
+		return sqlite.NewStorage(logger, config.DBPath, sqlite.Options{
+			KeepVersions: config.KeepVersions,
+			Webhooks:     config.Webhooks,
+			Audit:        config.Audit,
+		})
+	case types.MYSQL:
+		// Times are read back in UTC and clientFoundRows reports matched rows, as PostgreSQL does
+		dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?charset=%s&collation=%s&parseTime=true&loc=UTC&clientFoundRows=true",
+			config.DBUser, config.DBPass, config.DBHost, config.DBPort, config.DBName, config.DBCharset, config.DBCollation)
+
+		return mysql.NewStorage(logger, dsn, mysql.Options{
+			Outbox:       config.Outbox,
+			KeepVersions: config.KeepVersions,
+			Webhooks:     config.Webhooks,
+			Audit:        config.Audit,
+			Charset:      config.DBCharset,
+			Collation:    config.DBCollation,
+		})
+	case types.BOLT:
+		return bolt.NewStorage(config.DBPath, logger, bolt.Options{KeepVersions: config.KeepVersions})
 	default:
 		return nil, errInvalidStorage
 	}
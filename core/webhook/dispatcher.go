@@ -0,0 +1,533 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Aleksao998/LightningUserVault/core/events"
+	"go.uber.org/zap"
+)
+
+const (
+	// DefaultMaxAttempts is the number of failed attempts after which a delivery is dead when it is not configured
+	DefaultMaxAttempts = 8
+
+	// defaultBackoffBase is the delay before the second attempt, it doubles with every further attempt
+	defaultBackoffBase = time.Second
+
+	// defaultBackoffMax is the longest delay between two attempts
+	defaultBackoffMax = time.Hour
+
+	// defaultPollInterval is the longest time between two checks of the queue
+	defaultPollInterval = time.Second
+
+	// defaultTimeout is how long a single attempt may take
+	defaultTimeout = 10 * time.Second
+
+	// defaultConcurrency is the number of deliveries attempted at once
+	defaultConcurrency = 8
+
+	// secretLength is the number of random bytes of a generated secret
+	secretLength = 32
+
+	// maxErrorBodyLength is the largest part of a failed response body kept as the delivery error
+	maxErrorBodyLength = 256
+)
+
+// DispatcherConfig parametrizes the dispatcher, zero values are replaced by defaults
+type DispatcherConfig struct {
+	MaxAttempts  int
+	BackoffBase  time.Duration
+	BackoffMax   time.Duration
+	PollInterval time.Duration
+	Timeout      time.Duration
+	Concurrency  int
+}
+
+// Dispatcher queues events from the event stream for every subscribed webhook and delivers them.
+//
+// The queue position in the event stream is stored together with the queued deliveries,
+// so after a restart queuing continues with the first event which was not queued yet.
+// Failed attempts are retried with exponential backoff until the delivery is dead
+type Dispatcher struct {
+	logger *zap.Logger
+	stream events.Stream
+	store  Store
+	client *http.Client
+	config DispatcherConfig
+
+	// retryLock serializes retries, so a dead delivery is queued again only once
+	retryLock sync.Mutex
+
+	// wake is signaled when deliveries are queued, so they are attempted without waiting for the next poll
+	wake chan struct{}
+
+	ctx       context.Context
+	cancel    context.CancelFunc
+	done      sync.WaitGroup
+	closeOnce sync.Once
+}
+
+// NewDispatcher creates a new Dispatcher keeping webhooks in the given store, it does not deliver until started
+func NewDispatcher(logger *zap.Logger, store Store, stream events.Stream, config DispatcherConfig) *Dispatcher {
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = DefaultMaxAttempts
+	}
+
+	if config.BackoffBase <= 0 {
+		config.BackoffBase = defaultBackoffBase
+	}
+
+	if config.BackoffMax <= 0 {
+		config.BackoffMax = defaultBackoffMax
+	}
+
+	if config.PollInterval <= 0 {
+		config.PollInterval = defaultPollInterval
+	}
+
+	if config.Timeout <= 0 {
+		config.Timeout = defaultTimeout
+	}
+
+	if config.Concurrency <= 0 {
+		config.Concurrency = defaultConcurrency
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &Dispatcher{
+		logger: logger,
+		stream: stream,
+		store:  store,
+		client: &http.Client{Timeout: config.Timeout},
+		config: config,
+		wake:   make(chan struct{}, 1),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+// Start queues and delivers events in the background until the dispatcher is closed
+func (d *Dispatcher) Start() {
+	d.logger.Info("Starting webhook dispatcher",
+		zap.Int("maxAttempts", d.config.MaxAttempts),
+		zap.Int("concurrency", d.config.Concurrency),
+	)
+
+	d.done.Add(2)
+
+	go d.consume()
+	go d.deliverLoop()
+}
+
+// Register validates and stores a new webhook, generating its secret if none is given
+func (d *Dispatcher) Register(registration *Registration) (*Webhook, error) {
+	target, err := url.Parse(registration.URL)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		return nil, ErrInvalidURL
+	}
+
+	for _, eventType := range registration.Events {
+		switch eventType {
+		case events.Created, events.Updated, events.Deleted, events.Restored:
+		default:
+			return nil, ErrInvalidEventType
+		}
+	}
+
+	secret := registration.Secret
+	if secret == "" {
+		if secret, err = generateSecret(); err != nil {
+			return nil, err
+		}
+	}
+
+	webhook := &Webhook{
+		URL:       registration.URL,
+		Secret:    secret,
+		Events:    registration.Events,
+		CreatedAt: time.Now().UTC(),
+	}
+
+	if err := d.store.AddWebhook(webhook); err != nil {
+		d.logger.Error("Failed to store webhook", zap.String("url", webhook.URL), zap.Error(err))
+
+		return nil, err
+	}
+
+	d.logger.Info("Webhook registered", zap.Int64("id", webhook.ID), zap.String("url", webhook.URL))
+
+	return webhook, nil
+}
+
+// Get returns the webhook with the given ID without its secret
+func (d *Dispatcher) Get(id int64) (*Webhook, error) {
+	webhook, err := d.store.GetWebhook(id)
+	if err != nil {
+		return nil, err
+	}
+
+	webhook.Secret = ""
+
+	return webhook, nil
+}
+
+// List returns all webhooks without their secrets
+func (d *Dispatcher) List() ([]*Webhook, error) {
+	webhooks, err := d.store.ListWebhooks()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, webhook := range webhooks {
+		webhook.Secret = ""
+	}
+
+	return webhooks, nil
+}
+
+// Remove removes the webhook with the given ID
+func (d *Dispatcher) Remove(id int64) error {
+	if err := d.store.RemoveWebhook(id); err != nil {
+		return err
+	}
+
+	d.logger.Info("Webhook removed", zap.Int64("id", id))
+
+	return nil
+}
+
+// GetDelivery returns the delivery with the given ID
+func (d *Dispatcher) GetDelivery(id int64) (*Delivery, error) {
+	return d.store.GetDelivery(id)
+}
+
+// ListDeliveries returns deliveries selected by the filter, newest first
+func (d *Dispatcher) ListDeliveries(filter DeliveryFilter) ([]*Delivery, error) {
+	switch filter.Status {
+	case "", Pending, Delivered, Dead:
+	default:
+		return nil, ErrInvalidStatus
+	}
+
+	return d.store.ListDeliveries(filter)
+}
+
+// Retry moves a dead delivery back to the queue for immediate delivery
+func (d *Dispatcher) Retry(id int64) (*Delivery, error) {
+	d.retryLock.Lock()
+	defer d.retryLock.Unlock()
+
+	delivery, err := d.store.GetDelivery(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if delivery.Status != Dead {
+		return nil, ErrDeliveryNotDead
+	}
+
+	now := time.Now().UTC()
+	delivery.Status = Pending
+	delivery.Attempts = 0
+	delivery.NextAttempt = now
+	delivery.UpdatedAt = now
+
+	if err := d.store.UpdateDelivery(delivery); err != nil {
+		d.logger.Error("Failed to queue dead delivery again", zap.Int64("id", id), zap.Error(err))
+
+		return nil, err
+	}
+
+	d.logger.Info("Dead delivery queued again", zap.Int64("id", id))
+	d.signal()
+
+	return delivery, nil
+}
+
+// Close stops delivering and waits for running attempts, the store is closed together with the storage
+func (d *Dispatcher) Close() error {
+	d.closeOnce.Do(func() {
+		d.cancel()
+		d.done.Wait()
+	})
+
+	return nil
+}
+
+// signal wakes the delivery loop without blocking
+func (d *Dispatcher) signal() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// consume queues every event of the stream after the stored cursor for the webhooks subscribed to it
+func (d *Dispatcher) consume() {
+	defer d.done.Done()
+
+	for {
+		err := d.consumeOnce()
+		if err == nil || errors.Is(err, events.ErrStreamClosed) || d.ctx.Err() != nil {
+			return
+		}
+
+		// Reading the stream failed, it is subscribed again from the stored cursor after a pause
+		d.logger.Error("Failed to queue events for webhooks", zap.Error(err))
+
+		select {
+		case <-time.After(d.config.PollInterval):
+		case <-d.ctx.Done():
+			return
+		}
+	}
+}
+
+// consumeOnce subscribes to the stream from the stored cursor and queues events until an error occurs
+func (d *Dispatcher) consumeOnce() error {
+	cursor, err := d.store.WebhookCursor()
+	if err != nil {
+		return err
+	}
+
+	sub, err := d.stream.Subscribe(cursor)
+	if err != nil {
+		return err
+	}
+	defer sub.Close()
+
+	for {
+		event, err := sub.Next(d.ctx)
+		if err != nil {
+			return err
+		}
+
+		webhooks, err := d.store.ListWebhooks()
+		if err != nil {
+			return err
+		}
+
+		now := time.Now().UTC()
+		deliveries := make([]*Delivery, 0, len(webhooks))
+
+		for _, webhook := range webhooks {
+			if !webhook.subscribed(event.Type) {
+				continue
+			}
+
+			deliveries = append(deliveries, &Delivery{
+				WebhookID:   webhook.ID,
+				Event:       event,
+				Status:      Pending,
+				NextAttempt: now,
+				CreatedAt:   now,
+				UpdatedAt:   now,
+			})
+		}
+
+		if err := d.store.EnqueueDeliveries(event.ID, deliveries); err != nil {
+			return err
+		}
+
+		if len(deliveries) > 0 {
+			d.logger.Debug("Event queued for webhooks", zap.Int64("event", event.ID), zap.Int("webhooks", len(deliveries)))
+			d.signal()
+		}
+	}
+}
+
+// deliverLoop attempts due deliveries whenever deliveries are queued and on every poll
+func (d *Dispatcher) deliverLoop() {
+	defer d.done.Done()
+
+	ticker := time.NewTicker(d.config.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		d.deliverDue()
+
+		select {
+		case <-ticker.C:
+		case <-d.wake:
+		case <-d.ctx.Done():
+			return
+		}
+	}
+}
+
+// deliverDue attempts all due deliveries, at most config.Concurrency at once
+func (d *Dispatcher) deliverDue() {
+	for d.ctx.Err() == nil {
+		deliveries, err := d.store.DueDeliveries(time.Now().UTC(), d.config.Concurrency)
+		if err != nil {
+			d.logger.Error("Failed to read due deliveries", zap.Error(err))
+
+			return
+		}
+
+		var wg sync.WaitGroup
+
+		for _, delivery := range deliveries {
+			wg.Add(1)
+
+			go func(delivery *Delivery) {
+				defer wg.Done()
+
+				d.attempt(delivery)
+			}(delivery)
+		}
+
+		wg.Wait()
+
+		if len(deliveries) < d.config.Concurrency {
+			return
+		}
+	}
+}
+
+// attempt delivers the event once and stores the outcome
+func (d *Dispatcher) attempt(delivery *Delivery) {
+	webhook, err := d.store.GetWebhook(delivery.WebhookID)
+	if err == nil {
+		var statusCode int
+
+		statusCode, err = d.post(webhook, delivery)
+		delivery.LastStatusCode = statusCode
+	}
+
+	// Closing the dispatcher interrupts the attempt, it is repeated after the next start
+	if d.ctx.Err() != nil {
+		return
+	}
+
+	now := time.Now().UTC()
+	delivery.UpdatedAt = now
+
+	switch {
+	case err == nil:
+		delivery.Status = Delivered
+		delivery.LastError = ""
+
+		d.logger.Debug("Event delivered to webhook", zap.Int64("delivery", delivery.ID), zap.Int64("webhook", delivery.WebhookID))
+	case errors.Is(err, ErrWebhookNotFound):
+		// The webhook was removed, so the delivery can only succeed after it is retried for a new one
+		delivery.Status = Dead
+		delivery.LastError = err.Error()
+	default:
+		delivery.Attempts++
+		delivery.LastError = err.Error()
+
+		if delivery.Attempts >= d.config.MaxAttempts {
+			delivery.Status = Dead
+
+			d.logger.Warn("Webhook delivery is dead",
+				zap.Int64("delivery", delivery.ID),
+				zap.Int64("webhook", delivery.WebhookID),
+				zap.Int("attempts", delivery.Attempts),
+				zap.Error(err),
+			)
+		} else {
+			delivery.NextAttempt = now.Add(d.backoff(delivery.Attempts))
+
+			d.logger.Debug("Webhook delivery failed",
+				zap.Int64("delivery", delivery.ID),
+				zap.Int("attempts", delivery.Attempts),
+				zap.Time("nextAttempt", delivery.NextAttempt),
+				zap.Error(err),
+			)
+		}
+	}
+
+	if err := d.store.UpdateDelivery(delivery); err != nil {
+		d.logger.Error("Failed to store delivery attempt", zap.Int64("delivery", delivery.ID), zap.Error(err))
+	}
+}
+
+// post sends the signed event to the webhook and returns the response status,
+// any response other than 2xx is an error
+func (d *Dispatcher) post(webhook *Webhook, delivery *Delivery) (int, error) {
+	body, err := json.Marshal(delivery.Event)
+	if err != nil {
+		return 0, err
+	}
+
+	req, err := http.NewRequestWithContext(d.ctx, http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+
+	timestamp := time.Now().Unix()
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, string(delivery.Event.Type))
+	req.Header.Set(DeliveryHeader, strconv.FormatInt(delivery.ID, 10))
+	req.Header.Set(TimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(SignatureHeader, Sign(webhook.Secret, timestamp, body))
+
+	res, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < http.StatusOK || res.StatusCode >= http.StatusMultipleChoices {
+		message, _ := io.ReadAll(io.LimitReader(res.Body, maxErrorBodyLength))
+
+		return res.StatusCode, fmt.Errorf("unexpected response status %d: %s", res.StatusCode, message)
+	}
+
+	return res.StatusCode, nil
+}
+
+// backoff returns the delay after the given number of failed attempts, doubling from the base up to the maximum
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	delay := d.config.BackoffBase
+
+	for i := 1; i < attempts && delay < d.config.BackoffMax; i++ {
+		delay *= 2
+	}
+
+	if delay > d.config.BackoffMax {
+		return d.config.BackoffMax
+	}
+
+	return delay
+}
+
+// subscribed reports whether events of the given type are delivered to the webhook
+func (w *Webhook) subscribed(eventType events.Type) bool {
+	if len(w.Events) == 0 {
+		return true
+	}
+
+	for _, subscribed := range w.Events {
+		if subscribed == eventType {
+			return true
+		}
+	}
+
+	return false
+}
+
+// generateSecret returns a random hex encoded secret
+func generateSecret() (string, error) {
+	secret := make([]byte, secretLength)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(secret), nil
+}
@@ -0,0 +1,382 @@
+package webhook
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Aleksao998/LightningUserVault/core/common"
+	"github.com/Aleksao998/LightningUserVault/core/events"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// receivedRequest is a delivery attempt received by the test receiver
+type receivedRequest struct {
+	header http.Header
+	body   []byte
+}
+
+// receiver is a webhook endpoint answering attempts with the configured status codes, then 200
+type receiver struct {
+	lock     sync.Mutex
+	statuses []int
+	requests []receivedRequest
+	server   *httptest.Server
+}
+
+// newReceiver starts a webhook endpoint which is stopped when the test ends
+func newReceiver(t *testing.T, statuses ...int) *receiver {
+	t.Helper()
+
+	r := &receiver{statuses: statuses}
+	r.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+
+		r.lock.Lock()
+		defer r.lock.Unlock()
+
+		r.requests = append(r.requests, receivedRequest{header: req.Header.Clone(), body: body})
+
+		status := http.StatusOK
+		if len(r.statuses) > 0 {
+			status, r.statuses = r.statuses[0], r.statuses[1:]
+		}
+
+		w.WriteHeader(status)
+	}))
+
+	t.Cleanup(r.server.Close)
+
+	return r
+}
+
+// received returns a copy of all received attempts
+func (r *receiver) received() []receivedRequest {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return append([]receivedRequest{}, r.requests...)
+}
+
+// testDispatcher bundles a started dispatcher with the event stream it is fed from
+type testDispatcher struct {
+	*Dispatcher
+	stream events.Stream
+}
+
+// newTestDispatcher starts a dispatcher with fast retries keeping webhooks in a new memory store,
+// the change log is stored in the given directory
+func newTestDispatcher(t *testing.T, dir string, maxAttempts int) *testDispatcher {
+	t.Helper()
+
+	return newTestDispatcherWithStore(t, dir, NewMemoryStore(), maxAttempts)
+}
+
+// newTestDispatcherWithStore starts a dispatcher with fast retries keeping webhooks in the given store,
+// the change log is stored in the given directory
+func newTestDispatcherWithStore(t *testing.T, dir string, store Store, maxAttempts int) *testDispatcher {
+	t.Helper()
+
+	stream, err := events.GetStream(zap.NewNop(), events.Config{
+		LogPath: filepath.Join(dir, "event-log"),
+		Enabled: true,
+	})
+	require.NoError(t, err)
+
+	dispatcher := NewDispatcher(zap.NewNop(), store, stream, DispatcherConfig{
+		MaxAttempts:  maxAttempts,
+		BackoffBase:  10 * time.Millisecond,
+		BackoffMax:   20 * time.Millisecond,
+		PollInterval: 10 * time.Millisecond,
+	})
+	dispatcher.Start()
+
+	return &testDispatcher{Dispatcher: dispatcher, stream: stream}
+}
+
+// close stops the dispatcher before the stream it reads from
+func (d *testDispatcher) close(t *testing.T) {
+	t.Helper()
+
+	require.NoError(t, d.Close())
+	require.NoError(t, d.stream.Close())
+}
+
+// waitForStatus waits until the delivery with the given ID reaches the status
+func waitForStatus(t *testing.T, d *testDispatcher, id int64, status Status) *Delivery {
+	t.Helper()
+
+	var delivery *Delivery
+
+	require.Eventually(t, func() bool {
+		var err error
+
+		delivery, err = d.GetDelivery(id)
+
+		return err == nil && delivery.Status == status
+	}, 5*time.Second, 5*time.Millisecond)
+
+	return delivery
+}
+
+// waitForDeliveries waits until the given number of deliveries is queued and returns them, newest first
+func waitForDeliveries(t *testing.T, d *testDispatcher, count int) []*Delivery {
+	t.Helper()
+
+	var deliveries []*Delivery
+
+	require.Eventually(t, func() bool {
+		var err error
+
+		deliveries, err = d.ListDeliveries(DeliveryFilter{Limit: 100})
+
+		return err == nil && len(deliveries) == count
+	}, 5*time.Second, 5*time.Millisecond)
+
+	return deliveries
+}
+
+// TestDispatcher_DeliverSignedEvent tests that a committed event is posted to the webhook with a valid signature
+func TestDispatcher_DeliverSignedEvent(t *testing.T) {
+	t.Parallel()
+
+	r := newReceiver(t)
+	d := newTestDispatcher(t, t.TempDir(), 0)
+	defer d.close(t)
+
+	webhook, err := d.Register(&Registration{URL: r.server.URL, Secret: "secret"})
+	require.NoError(t, err)
+	assert.Equal(t, "secret", webhook.Secret)
+
+	event, err := d.stream.Publish(events.Created, 1, &common.User{ID: 1, Name: "John"})
+	require.NoError(t, err)
+
+	deliveries := waitForDeliveries(t, d, 1)
+	delivery := waitForStatus(t, d, deliveries[0].ID, Delivered)
+	assert.Equal(t, webhook.ID, delivery.WebhookID)
+	assert.Equal(t, event.ID, delivery.Event.ID)
+	assert.Equal(t, http.StatusOK, delivery.LastStatusCode)
+
+	requests := r.received()
+	require.Len(t, requests, 1)
+
+	header := requests[0].header
+	assert.Equal(t, "created", header.Get(EventHeader))
+	assert.Equal(t, strconv.FormatInt(delivery.ID, 10), header.Get(DeliveryHeader))
+
+	timestamp, err := strconv.ParseInt(header.Get(TimestampHeader), 10, 64)
+	require.NoError(t, err)
+	assert.True(t, Verify("secret", timestamp, requests[0].body, header.Get(SignatureHeader)))
+
+	var received events.Event
+	require.NoError(t, json.Unmarshal(requests[0].body, &received))
+	assert.Equal(t, event.ID, received.ID)
+	assert.Equal(t, "John", received.User.Name)
+}
+
+// TestDispatcher_EventTypeFilter tests that webhooks only receive the event types they subscribed to
+func TestDispatcher_EventTypeFilter(t *testing.T) {
+	t.Parallel()
+
+	r := newReceiver(t)
+	d := newTestDispatcher(t, t.TempDir(), 0)
+	defer d.close(t)
+
+	_, err := d.Register(&Registration{URL: r.server.URL, Events: []events.Type{events.Deleted}})
+	require.NoError(t, err)
+
+	_, err = d.stream.Publish(events.Created, 1, &common.User{ID: 1, Name: "John"})
+	require.NoError(t, err)
+	_, err = d.stream.Publish(events.Deleted, 1, nil)
+	require.NoError(t, err)
+
+	deliveries := waitForDeliveries(t, d, 1)
+	assert.Equal(t, events.Deleted, deliveries[0].Event.Type)
+}
+
+// TestDispatcher_RetryWithBackoff tests that failed attempts are retried until the endpoint accepts the event
+func TestDispatcher_RetryWithBackoff(t *testing.T) {
+	t.Parallel()
+
+	r := newReceiver(t, http.StatusInternalServerError, http.StatusServiceUnavailable)
+	d := newTestDispatcher(t, t.TempDir(), 5)
+	defer d.close(t)
+
+	_, err := d.Register(&Registration{URL: r.server.URL})
+	require.NoError(t, err)
+
+	_, err = d.stream.Publish(events.Created, 1, &common.User{ID: 1, Name: "John"})
+	require.NoError(t, err)
+
+	deliveries := waitForDeliveries(t, d, 1)
+	delivery := waitForStatus(t, d, deliveries[0].ID, Delivered)
+	assert.Equal(t, 2, delivery.Attempts)
+	assert.Empty(t, delivery.LastError)
+	assert.Len(t, r.received(), 3)
+}
+
+// TestDispatcher_DeadLetterAndRetry tests that a delivery failing every attempt is dead until it is retried
+func TestDispatcher_DeadLetterAndRetry(t *testing.T) {
+	t.Parallel()
+
+	r := newReceiver(t, http.StatusBadGateway, http.StatusBadGateway)
+	d := newTestDispatcher(t, t.TempDir(), 2)
+	defer d.close(t)
+
+	_, err := d.Register(&Registration{URL: r.server.URL})
+	require.NoError(t, err)
+
+	_, err = d.stream.Publish(events.Created, 1, &common.User{ID: 1, Name: "John"})
+	require.NoError(t, err)
+
+	deliveries := waitForDeliveries(t, d, 1)
+	delivery := waitForStatus(t, d, deliveries[0].ID, Dead)
+	assert.Equal(t, 2, delivery.Attempts)
+	assert.Equal(t, http.StatusBadGateway, delivery.LastStatusCode)
+	assert.Contains(t, delivery.LastError, "502")
+
+	dead, err := d.ListDeliveries(DeliveryFilter{Status: Dead, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, dead, 1)
+
+	_, err = d.Retry(delivery.ID)
+	require.NoError(t, err)
+
+	waitForStatus(t, d, delivery.ID, Delivered)
+
+	_, err = d.Retry(delivery.ID)
+	assert.ErrorIs(t, err, ErrDeliveryNotDead)
+}
+
+// TestDispatcher_RemovedWebhook tests that deliveries of a removed webhook are moved to the dead-letter list
+func TestDispatcher_RemovedWebhook(t *testing.T) {
+	t.Parallel()
+
+	// Attempts fail until the webhook is removed, enough are allowed for the delivery not to die before
+	d := newTestDispatcher(t, t.TempDir(), 100)
+	defer d.close(t)
+
+	webhook, err := d.Register(&Registration{URL: "http://localhost:1"})
+	require.NoError(t, err)
+
+	_, err = d.stream.Publish(events.Created, 1, &common.User{ID: 1, Name: "John"})
+	require.NoError(t, err)
+
+	deliveries := waitForDeliveries(t, d, 1)
+
+	require.NoError(t, d.Remove(webhook.ID))
+	assert.ErrorIs(t, d.Remove(webhook.ID), ErrWebhookNotFound)
+
+	delivery := waitForStatus(t, d, deliveries[0].ID, Dead)
+	assert.Equal(t, ErrWebhookNotFound.Error(), delivery.LastError)
+}
+
+// TestDispatcher_ResumeAfterRestart tests that events committed while the dispatcher was stopped are queued after it starts
+func TestDispatcher_ResumeAfterRestart(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	store := NewMemoryStore()
+	r := newReceiver(t)
+
+	d := newTestDispatcherWithStore(t, dir, store, 0)
+
+	_, err := d.Register(&Registration{URL: r.server.URL})
+	require.NoError(t, err)
+
+	_, err = d.stream.Publish(events.Created, 1, &common.User{ID: 1, Name: "John"})
+	require.NoError(t, err)
+
+	waitForStatus(t, d, waitForDeliveries(t, d, 1)[0].ID, Delivered)
+
+	// Events published without a running dispatcher stay in the change log
+	require.NoError(t, d.Close())
+
+	_, err = d.stream.Publish(events.Updated, 1, &common.User{ID: 1, Name: "Jane"})
+	require.NoError(t, err)
+	require.NoError(t, d.stream.Close())
+
+	d = newTestDispatcherWithStore(t, dir, store, 0)
+	defer d.close(t)
+
+	deliveries := waitForDeliveries(t, d, 2)
+	waitForStatus(t, d, deliveries[0].ID, Delivered)
+	assert.Equal(t, events.Updated, deliveries[0].Event.Type)
+	assert.Len(t, r.received(), 2)
+}
+
+// TestDispatcher_RegisterValidation tests that webhooks are only registered for valid URLs and event types
+func TestDispatcher_RegisterValidation(t *testing.T) {
+	t.Parallel()
+
+	d := newTestDispatcher(t, t.TempDir(), 0)
+	defer d.close(t)
+
+	_, err := d.Register(&Registration{URL: "ftp://example.com"})
+	assert.ErrorIs(t, err, ErrInvalidURL)
+
+	_, err = d.Register(&Registration{URL: "/relative"})
+	assert.ErrorIs(t, err, ErrInvalidURL)
+
+	_, err = d.Register(&Registration{URL: "http://example.com", Events: []events.Type{"renamed"}})
+	assert.ErrorIs(t, err, ErrInvalidEventType)
+
+	webhook, err := d.Register(&Registration{URL: "http://example.com"})
+	require.NoError(t, err)
+	assert.Len(t, webhook.Secret, 2*secretLength)
+
+	// Secrets are only returned on registration
+	stored, err := d.Get(webhook.ID)
+	require.NoError(t, err)
+	assert.Empty(t, stored.Secret)
+
+	webhooks, err := d.List()
+	require.NoError(t, err)
+	require.Len(t, webhooks, 1)
+	assert.Empty(t, webhooks[0].Secret)
+
+	_, err = d.ListDeliveries(DeliveryFilter{Status: "unknown", Limit: 10})
+	assert.ErrorIs(t, err, ErrInvalidStatus)
+}
+
+func TestDispatcher_Backoff(t *testing.T) {
+	t.Parallel()
+
+	d := &Dispatcher{config: DispatcherConfig{BackoffBase: time.Second, BackoffMax: 10 * time.Second}}
+
+	assert.Equal(t, time.Second, d.backoff(1))
+	assert.Equal(t, 2*time.Second, d.backoff(2))
+	assert.Equal(t, 8*time.Second, d.backoff(4))
+	assert.Equal(t, 10*time.Second, d.backoff(5))
+	assert.Equal(t, 10*time.Second, d.backoff(100))
+}
+
+func TestGetManager(t *testing.T) {
+	t.Parallel()
+
+	manager, err := GetManager(zap.NewNop(), Config{Enabled: false}, nil, nil)
+	require.NoError(t, err)
+	assert.Nil(t, manager)
+
+	_, err = GetManager(zap.NewNop(), Config{Enabled: true}, NewMemoryStore(), nil)
+	assert.ErrorIs(t, err, ErrEventsRequired)
+
+	stream, err := events.GetStream(zap.NewNop(), events.Config{
+		LogPath: filepath.Join(t.TempDir(), "event-log"),
+		Enabled: true,
+	})
+	require.NoError(t, err)
+
+	defer stream.Close()
+
+	_, err = GetManager(zap.NewNop(), Config{Enabled: true}, struct{}{}, stream)
+	assert.ErrorIs(t, err, ErrUnsupportedStorage)
+}
@@ -0,0 +1,91 @@
+package mocks
+
+import (
+	"github.com/Aleksao998/LightningUserVault/core/webhook"
+)
+
+type (
+	RegisterDelegate       func(registration *webhook.Registration) (*webhook.Webhook, error)
+	GetDelegate            func(id int64) (*webhook.Webhook, error)
+	ListDelegate           func() ([]*webhook.Webhook, error)
+	RemoveDelegate         func(id int64) error
+	GetDeliveryDelegate    func(id int64) (*webhook.Delivery, error)
+	ListDeliveriesDelegate func(filter webhook.DeliveryFilter) ([]*webhook.Delivery, error)
+	RetryDelegate          func(id int64) (*webhook.Delivery, error)
+	CloseDelegate          func() error
+)
+
+type MockManager struct {
+	RegisterFn       RegisterDelegate
+	GetFn            GetDelegate
+	ListFn           ListDelegate
+	RemoveFn         RemoveDelegate
+	GetDeliveryFn    GetDeliveryDelegate
+	ListDeliveriesFn ListDeliveriesDelegate
+	RetryFn          RetryDelegate
+	CloseFn          CloseDelegate
+}
+
+func (m *MockManager) Register(registration *webhook.Registration) (*webhook.Webhook, error) {
+	if m.RegisterFn != nil {
+		return m.RegisterFn(registration)
+	}
+
+	return nil, nil
+}
+
+func (m *MockManager) Get(id int64) (*webhook.Webhook, error) {
+	if m.GetFn != nil {
+		return m.GetFn(id)
+	}
+
+	return nil, nil
+}
+
+func (m *MockManager) List() ([]*webhook.Webhook, error) {
+	if m.ListFn != nil {
+		return m.ListFn()
+	}
+
+	return nil, nil
+}
+
+func (m *MockManager) Remove(id int64) error {
+	if m.RemoveFn != nil {
+		return m.RemoveFn(id)
+	}
+
+	return nil
+}
+
+func (m *MockManager) GetDelivery(id int64) (*webhook.Delivery, error) {
+	if m.GetDeliveryFn != nil {
+		return m.GetDeliveryFn(id)
+	}
+
+	return nil, nil
+}
+
+func (m *MockManager) ListDeliveries(filter webhook.DeliveryFilter) ([]*webhook.Delivery, error) {
+	if m.ListDeliveriesFn != nil {
+		return m.ListDeliveriesFn(filter)
+	}
+
+	return nil, nil
+}
+
+func (m *MockManager) Retry(id int64) (*webhook.Delivery, error) {
+	if m.RetryFn != nil {
+		return m.RetryFn(id)
+	}
+
+	return nil, nil
+}
+
+func (m *MockManager) Close() error {
+	if m.CloseFn != nil {
+		return m.CloseFn()
+	}
+
+	return nil
+}
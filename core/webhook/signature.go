@@ -0,0 +1,41 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+)
+
+const (
+	// SignatureHeader carries the HMAC signature of a delivery
+	SignatureHeader = "X-Vault-Signature"
+
+	// TimestampHeader carries the unix time a delivery attempt was signed at
+	TimestampHeader = "X-Vault-Timestamp"
+
+	// EventHeader carries the type of the delivered event
+	EventHeader = "X-Vault-Event"
+
+	// DeliveryHeader carries the ID of the delivery, it is the same for every attempt
+	DeliveryHeader = "X-Vault-Delivery"
+
+	// signaturePrefix names the algorithm of the signature
+	signaturePrefix = "sha256="
+)
+
+// Sign returns the signature of a delivery body sent at the given unix time.
+// The timestamp is signed together with the body, so receivers can reject replayed deliveries
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether the signature matches the delivery body sent at the given unix time
+func Verify(secret string, timestamp int64, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, timestamp, body)), []byte(signature))
+}
@@ -0,0 +1,20 @@
+package webhook
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSignAndVerify(t *testing.T) {
+	t.Parallel()
+
+	body := []byte(`{"id":1}`)
+	signature := Sign("secret", 1700000000, body)
+
+	assert.Regexp(t, "^sha256=[0-9a-f]{64}$", signature)
+	assert.True(t, Verify("secret", 1700000000, body, signature))
+	assert.False(t, Verify("other", 1700000000, body, signature))
+	assert.False(t, Verify("secret", 1700000001, body, signature))
+	assert.False(t, Verify("secret", 1700000000, []byte(`{"id":2}`), signature))
+}
@@ -0,0 +1,239 @@
+package webhook
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Aleksao998/LightningUserVault/core/events"
+)
+
+// Store persists webhooks and their delivery queue. It is implemented by the user storages,
+// so the queue is kept in the same database as the users it notifies about
+type Store interface {
+	// AddWebhook assigns an ID to the webhook and stores it
+	AddWebhook(webhook *Webhook) error
+
+	// GetWebhook returns the webhook with the given ID, or ErrWebhookNotFound
+	GetWebhook(id int64) (*Webhook, error)
+
+	// ListWebhooks returns all webhooks ordered by ID
+	ListWebhooks() ([]*Webhook, error)
+
+	// RemoveWebhook removes the webhook with the given ID, or returns ErrWebhookNotFound
+	RemoveWebhook(id int64) error
+
+	// WebhookCursor returns the ID of the last queued event, or 0 if no event was queued yet
+	WebhookCursor() (int64, error)
+
+	// EnqueueDeliveries assigns IDs to the deliveries and stores them together with the given event ID as the new cursor,
+	// so every event is queued exactly once even if the process stops in between
+	EnqueueDeliveries(cursor int64, deliveries []*Delivery) error
+
+	// DueDeliveries returns up to limit pending deliveries whose next attempt is not after the given time,
+	// ordered by their next attempt
+	DueDeliveries(now time.Time, limit int) ([]*Delivery, error)
+
+	// GetDelivery returns the delivery with the given ID, or ErrDeliveryNotFound
+	GetDelivery(id int64) (*Delivery, error)
+
+	// ListDeliveries returns deliveries selected by the filter, newest first
+	ListDeliveries(filter DeliveryFilter) ([]*Delivery, error)
+
+	// UpdateDelivery stores the changed delivery, it stays in the queue only while it is pending.
+	// It returns ErrDeliveryNotFound if the delivery was never queued
+	UpdateDelivery(delivery *Delivery) error
+}
+
+// MemoryStore keeps webhooks and their delivery queue in memory, it is used by the in-memory user storage.
+// Values are copied on every read and write, so callers never share state with the store
+type MemoryStore struct {
+	// lock guards all fields below
+	lock       sync.RWMutex
+	webhooks   map[int64]*Webhook
+	deliveries map[int64]*Delivery
+	cursor     int64
+	sequence   int64
+}
+
+// NewMemoryStore creates a new empty MemoryStore
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		webhooks:   make(map[int64]*Webhook),
+		deliveries: make(map[int64]*Delivery),
+	}
+}
+
+// copyWebhook returns a copy of the webhook
+func copyWebhook(webhook *Webhook) *Webhook {
+	copied := *webhook
+	copied.Events = append([]events.Type(nil), webhook.Events...)
+
+	return &copied
+}
+
+// copyDelivery returns a copy of the delivery, the event is shared since it is never changed
+func copyDelivery(delivery *Delivery) *Delivery {
+	copied := *delivery
+
+	return &copied
+}
+
+// AddWebhook assigns the next ID to the webhook and stores it
+func (m *MemoryStore) AddWebhook(webhook *Webhook) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.sequence++
+	webhook.ID = m.sequence
+	m.webhooks[webhook.ID] = copyWebhook(webhook)
+
+	return nil
+}
+
+// GetWebhook returns the webhook with the given ID
+func (m *MemoryStore) GetWebhook(id int64) (*Webhook, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	webhook, ok := m.webhooks[id]
+	if !ok {
+		return nil, ErrWebhookNotFound
+	}
+
+	return copyWebhook(webhook), nil
+}
+
+// ListWebhooks returns all webhooks ordered by ID
+func (m *MemoryStore) ListWebhooks() ([]*Webhook, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	webhooks := make([]*Webhook, 0, len(m.webhooks))
+	for _, webhook := range m.webhooks {
+		webhooks = append(webhooks, copyWebhook(webhook))
+	}
+
+	sort.Slice(webhooks, func(i, j int) bool {
+		return webhooks[i].ID < webhooks[j].ID
+	})
+
+	return webhooks, nil
+}
+
+// RemoveWebhook removes the webhook with the given ID
+func (m *MemoryStore) RemoveWebhook(id int64) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if _, ok := m.webhooks[id]; !ok {
+		return ErrWebhookNotFound
+	}
+
+	delete(m.webhooks, id)
+
+	return nil
+}
+
+// WebhookCursor returns the ID of the last queued event
+func (m *MemoryStore) WebhookCursor() (int64, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	return m.cursor, nil
+}
+
+// EnqueueDeliveries assigns the next IDs to the deliveries and stores them with the cursor
+func (m *MemoryStore) EnqueueDeliveries(cursor int64, deliveries []*Delivery) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	for _, delivery := range deliveries {
+		m.sequence++
+		delivery.ID = m.sequence
+		m.deliveries[delivery.ID] = copyDelivery(delivery)
+	}
+
+	m.cursor = cursor
+
+	return nil
+}
+
+// DueDeliveries returns up to limit pending deliveries whose next attempt is not after the given time
+func (m *MemoryStore) DueDeliveries(now time.Time, limit int) ([]*Delivery, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	due := make([]*Delivery, 0)
+
+	for _, delivery := range m.deliveries {
+		if delivery.Status == Pending && !delivery.NextAttempt.After(now) {
+			due = append(due, copyDelivery(delivery))
+		}
+	}
+
+	sort.Slice(due, func(i, j int) bool {
+		if !due[i].NextAttempt.Equal(due[j].NextAttempt) {
+			return due[i].NextAttempt.Before(due[j].NextAttempt)
+		}
+
+		return due[i].ID < due[j].ID
+	})
+
+	if len(due) > limit {
+		due = due[:limit]
+	}
+
+	return due, nil
+}
+
+// GetDelivery returns the delivery with the given ID
+func (m *MemoryStore) GetDelivery(id int64) (*Delivery, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	delivery, ok := m.deliveries[id]
+	if !ok {
+		return nil, ErrDeliveryNotFound
+	}
+
+	return copyDelivery(delivery), nil
+}
+
+// ListDeliveries returns deliveries selected by the filter, newest first
+func (m *MemoryStore) ListDeliveries(filter DeliveryFilter) ([]*Delivery, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	deliveries := make([]*Delivery, 0)
+
+	for _, delivery := range m.deliveries {
+		if filter.Matches(delivery) {
+			deliveries = append(deliveries, copyDelivery(delivery))
+		}
+	}
+
+	sort.Slice(deliveries, func(i, j int) bool {
+		return deliveries[i].ID > deliveries[j].ID
+	})
+
+	if len(deliveries) > filter.Limit {
+		deliveries = deliveries[:filter.Limit]
+	}
+
+	return deliveries, nil
+}
+
+// UpdateDelivery stores the changed delivery
+func (m *MemoryStore) UpdateDelivery(delivery *Delivery) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if _, ok := m.deliveries[delivery.ID]; !ok {
+		return ErrDeliveryNotFound
+	}
+
+	m.deliveries[delivery.ID] = copyDelivery(delivery)
+
+	return nil
+}
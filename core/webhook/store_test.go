@@ -0,0 +1,18 @@
+package webhook_test
+
+import (
+	"testing"
+
+	"github.com/Aleksao998/LightningUserVault/core/webhook"
+	"github.com/Aleksao998/LightningUserVault/core/webhook/webhooktest"
+)
+
+func TestMemoryStore_Conformance(t *testing.T) {
+	t.Parallel()
+
+	webhooktest.RunConformance(t, func(t *testing.T) webhook.Store {
+		t.Helper()
+
+		return webhook.NewMemoryStore()
+	})
+}
@@ -0,0 +1,185 @@
+package webhook
+
+import (
+	"errors"
+	"time"
+
+	"github.com/Aleksao998/LightningUserVault/core/events"
+	"go.uber.org/zap"
+)
+
+// Status is the delivery state of an event to a webhook
+type Status string
+
+const (
+	// Pending deliveries are waiting for their next attempt
+	Pending Status = "pending"
+
+	// Delivered deliveries were accepted by the endpoint
+	Delivered Status = "delivered"
+
+	// Dead deliveries failed every attempt and are kept in the dead-letter list until retried
+	Dead Status = "dead"
+)
+
+var (
+	// ErrWebhookNotFound is returned when the requested webhook does not exist
+	ErrWebhookNotFound = errors.New("webhook not found")
+
+	// ErrDeliveryNotFound is returned when the requested delivery does not exist
+	ErrDeliveryNotFound = errors.New("delivery not found")
+
+	// ErrDeliveryNotDead is returned when retrying a delivery which is not in the dead-letter list
+	ErrDeliveryNotDead = errors.New("delivery is not dead")
+
+	// ErrInvalidURL is returned when registering a webhook without an absolute http or https URL
+	ErrInvalidURL = errors.New("invalid webhook URL")
+
+	// ErrInvalidEventType is returned when registering a webhook for an unknown event type
+	ErrInvalidEventType = errors.New("invalid event type")
+
+	// ErrInvalidStatus is returned when filtering deliveries by an unknown status
+	ErrInvalidStatus = errors.New("invalid delivery status")
+
+	// ErrEventsRequired is returned when webhooks are enabled without the event stream they are fed from
+	ErrEventsRequired = errors.New("webhooks require events to be enabled")
+
+	// ErrUnsupportedStorage is returned when webhooks are enabled on a storage which can not keep the delivery queue
+	ErrUnsupportedStorage = errors.New("webhooks are not supported by the storage type")
+)
+
+// Webhook is an endpoint notified about user events
+type Webhook struct {
+	// ID is the unique identifier of the webhook
+	ID int64 `json:"id"`
+	// URL is the endpoint events are posted to
+	URL string `json:"url"`
+	// Secret is the key of the HMAC signature of every delivery, it is only returned on registration
+	Secret string `json:"secret,omitempty"`
+	// Events are the event types delivered to the webhook, all types are delivered when empty
+	Events []events.Type `json:"events,omitempty"`
+	// CreatedAt is the time the webhook was registered
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Registration is the request to register a new webhook
+type Registration struct {
+	// URL is the endpoint events are posted to
+	URL string `json:"url"`
+	// Secret is the key of the HMAC signature of every delivery, a random one is generated when empty
+	Secret string `json:"secret,omitempty"`
+	// Events are the event types delivered to the webhook, all types are delivered when empty
+	Events []events.Type `json:"events,omitempty"`
+}
+
+// Delivery is a single event queued for a webhook
+type Delivery struct {
+	// ID is the unique identifier of the delivery
+	ID int64 `json:"id"`
+	// WebhookID is the ID of the webhook the event is delivered to
+	WebhookID int64 `json:"webhook_id"`
+	// Event is the delivered event
+	Event *events.Event `json:"event"`
+	// Status is the delivery state
+	Status Status `json:"status"`
+	// Attempts is the number of failed attempts since the delivery was queued or retried
+	Attempts int `json:"attempts"`
+	// NextAttempt is the time of the next attempt of a pending delivery
+	NextAttempt time.Time `json:"next_attempt"`
+	// LastStatusCode is the response status of the last attempt, 0 if no response was received
+	LastStatusCode int `json:"last_status_code,omitempty"`
+	// LastError describes why the last attempt failed
+	LastError string `json:"last_error,omitempty"`
+	// CreatedAt is the time the delivery was queued
+	CreatedAt time.Time `json:"created_at"`
+	// UpdatedAt is the time of the last attempt or retry
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// WebhookList represents all registered webhooks
+type WebhookList struct {
+	Webhooks []*Webhook `json:"webhooks"`
+}
+
+// DeliveryList represents deliveries, newest first
+type DeliveryList struct {
+	Deliveries []*Delivery `json:"deliveries"`
+}
+
+// DeliveryFilter selects deliveries to list
+type DeliveryFilter struct {
+	// WebhookID only selects deliveries of the given webhook, 0 selects all webhooks
+	WebhookID int64
+	// Status only selects deliveries in the given state, empty selects all states
+	Status Status
+	// Limit is the largest number of returned deliveries
+	Limit int
+}
+
+// Matches reports whether the delivery is selected by the filter, the limit is not taken into account
+func (f DeliveryFilter) Matches(delivery *Delivery) bool {
+	if f.WebhookID != 0 && delivery.WebhookID != f.WebhookID {
+		return false
+	}
+
+	return f.Status == "" || delivery.Status == f.Status
+}
+
+type Manager interface {
+	// Register validates and stores a new webhook, which receives events committed from now on
+	Register(registration *Registration) (*Webhook, error)
+
+	// Get returns the webhook with the given ID without its secret
+	Get(id int64) (*Webhook, error)
+
+	// List returns all webhooks without their secrets
+	List() ([]*Webhook, error)
+
+	// Remove removes the webhook with the given ID, its pending deliveries are moved to the dead-letter list on their next attempt
+	Remove(id int64) error
+
+	// GetDelivery returns the delivery with the given ID
+	GetDelivery(id int64) (*Delivery, error)
+
+	// ListDeliveries returns deliveries selected by the filter, newest first
+	ListDeliveries(filter DeliveryFilter) ([]*Delivery, error)
+
+	// Retry moves a delivery from the dead-letter list back to the queue for immediate delivery
+	Retry(id int64) (*Delivery, error)
+
+	// Close stops delivering, the queue is kept by the storage
+	Close() error
+}
+
+type Config struct {
+	MaxAttempts int
+	Enabled     bool
+}
+
+// GetManager initializes and starts a webhook dispatcher fed from the given event stream based on the provided configuration.
+// Webhooks and their delivery queue are kept in the given storage
+func GetManager(logger *zap.Logger, config Config, vault interface{}, stream events.Stream) (Manager, error) {
+	if !config.Enabled {
+		logger.Debug("Webhooks disabled")
+
+		return nil, nil
+	}
+
+	logger.Debug("Webhooks enabled")
+
+	if stream == nil {
+		return nil, ErrEventsRequired
+	}
+
+	store, ok := vault.(Store)
+	if !ok {
+		return nil, ErrUnsupportedStorage
+	}
+
+	dispatcher := NewDispatcher(logger, store, stream, DispatcherConfig{
+		MaxAttempts: config.MaxAttempts,
+	})
+	dispatcher.Start()
+
+	return dispatcher, nil
+}
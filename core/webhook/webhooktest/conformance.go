@@ -0,0 +1,199 @@
+package webhooktest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Aleksao998/LightningUserVault/core/common"
+	"github.com/Aleksao998/LightningUserVault/core/events"
+	"github.com/Aleksao998/LightningUserVault/core/webhook"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// NewStoreFn creates an empty store under test, it is closed by the caller when the test ends
+type NewStoreFn func(t *testing.T) webhook.Store
+
+// newDelivery returns a pending delivery of the event to the webhook, due at the given time
+func newDelivery(webhookID int64, event *events.Event, nextAttempt time.Time) *webhook.Delivery {
+	return &webhook.Delivery{
+		WebhookID:   webhookID,
+		Event:       event,
+		Status:      webhook.Pending,
+		NextAttempt: nextAttempt,
+		CreatedAt:   nextAttempt,
+		UpdatedAt:   nextAttempt,
+	}
+}
+
+// deliveryIDs returns the IDs of the deliveries in order
+func deliveryIDs(deliveries []*webhook.Delivery) []int64 {
+	ids := make([]int64, 0, len(deliveries))
+	for _, delivery := range deliveries {
+		ids = append(ids, delivery.ID)
+	}
+
+	return ids
+}
+
+// RunConformance runs the suite checking that a storage keeps webhooks and their delivery queue
+// the same way as every other storage. Every case uses a fresh store
+func RunConformance(t *testing.T, newStore NewStoreFn) {
+	t.Helper()
+
+	t.Run("Webhooks", func(t *testing.T) {
+		store := newStore(t)
+
+		first := &webhook.Webhook{
+			URL:       "http://localhost/first",
+			Secret:    "secret",
+			Events:    []events.Type{events.Created},
+			CreatedAt: time.Now().UTC(),
+		}
+		second := &webhook.Webhook{URL: "http://localhost/second", CreatedAt: time.Now().UTC()}
+
+		require.NoError(t, store.AddWebhook(first))
+		require.NoError(t, store.AddWebhook(second))
+		assert.Greater(t, second.ID, first.ID)
+
+		stored, err := store.GetWebhook(first.ID)
+		require.NoError(t, err)
+		assert.Equal(t, first.URL, stored.URL)
+		assert.Equal(t, "secret", stored.Secret)
+		assert.Equal(t, []events.Type{events.Created}, stored.Events)
+
+		webhooks, err := store.ListWebhooks()
+		require.NoError(t, err)
+		require.Len(t, webhooks, 2)
+		assert.Equal(t, first.ID, webhooks[0].ID)
+		assert.Equal(t, second.ID, webhooks[1].ID)
+
+		require.NoError(t, store.RemoveWebhook(first.ID))
+
+		_, err = store.GetWebhook(first.ID)
+		assert.ErrorIs(t, err, webhook.ErrWebhookNotFound)
+		assert.ErrorIs(t, store.RemoveWebhook(first.ID), webhook.ErrWebhookNotFound)
+
+		webhooks, err = store.ListWebhooks()
+		require.NoError(t, err)
+		require.Len(t, webhooks, 1)
+		assert.Equal(t, second.ID, webhooks[0].ID)
+	})
+
+	t.Run("Queue", func(t *testing.T) {
+		store := newStore(t)
+
+		cursor, err := store.WebhookCursor()
+		require.NoError(t, err)
+		assert.Equal(t, int64(0), cursor)
+
+		now := time.Now().UTC().Truncate(time.Microsecond)
+		event := &events.Event{ID: 5, Type: events.Created, UserID: 1, User: &common.User{ID: 1, Name: "John"}, Time: now}
+
+		late := newDelivery(1, event, now.Add(time.Hour))
+		current := newDelivery(2, event, now)
+		early := newDelivery(3, event, now.Add(-time.Second))
+
+		require.NoError(t, store.EnqueueDeliveries(event.ID, []*webhook.Delivery{late, current, early}))
+		assert.NotEqual(t, late.ID, current.ID)
+		assert.NotEqual(t, current.ID, early.ID)
+
+		cursor, err = store.WebhookCursor()
+		require.NoError(t, err)
+		assert.Equal(t, int64(5), cursor)
+
+		// Only deliveries which are due are returned, ordered by their next attempt
+		due, err := store.DueDeliveries(now, 10)
+		require.NoError(t, err)
+		assert.Equal(t, []int64{early.ID, current.ID}, deliveryIDs(due))
+
+		due, err = store.DueDeliveries(now, 1)
+		require.NoError(t, err)
+		assert.Equal(t, []int64{early.ID}, deliveryIDs(due))
+
+		stored, err := store.GetDelivery(current.ID)
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), stored.WebhookID)
+		assert.Equal(t, webhook.Pending, stored.Status)
+		assert.True(t, now.Equal(stored.NextAttempt))
+		require.NotNil(t, stored.Event)
+		assert.Equal(t, int64(5), stored.Event.ID)
+		assert.Equal(t, "John", stored.Event.User.Name)
+
+		// A delivered delivery leaves the queue, a failed one is due again at its next attempt
+		early.Status = webhook.Delivered
+		early.LastStatusCode = 200
+		require.NoError(t, store.UpdateDelivery(early))
+
+		current.Attempts = 1
+		current.LastStatusCode = 500
+		current.LastError = "unexpected response status 500"
+		current.NextAttempt = now.Add(time.Minute)
+		require.NoError(t, store.UpdateDelivery(current))
+
+		due, err = store.DueDeliveries(now, 10)
+		require.NoError(t, err)
+		assert.Empty(t, due)
+
+		due, err = store.DueDeliveries(now.Add(time.Minute), 10)
+		require.NoError(t, err)
+		assert.Equal(t, []int64{current.ID}, deliveryIDs(due))
+		assert.Equal(t, 1, due[0].Attempts)
+		assert.Equal(t, 500, due[0].LastStatusCode)
+		assert.Equal(t, "unexpected response status 500", due[0].LastError)
+
+		// A dead delivery leaves the queue as well
+		current.Status = webhook.Dead
+		require.NoError(t, store.UpdateDelivery(current))
+
+		due, err = store.DueDeliveries(now.Add(2*time.Hour), 10)
+		require.NoError(t, err)
+		assert.Equal(t, []int64{late.ID}, deliveryIDs(due))
+
+		// Events without subscribed webhooks only move the cursor
+		require.NoError(t, store.EnqueueDeliveries(6, nil))
+
+		cursor, err = store.WebhookCursor()
+		require.NoError(t, err)
+		assert.Equal(t, int64(6), cursor)
+
+		_, err = store.GetDelivery(100)
+		assert.ErrorIs(t, err, webhook.ErrDeliveryNotFound)
+		assert.ErrorIs(t, store.UpdateDelivery(&webhook.Delivery{ID: 100, Status: webhook.Pending}), webhook.ErrDeliveryNotFound)
+	})
+
+	t.Run("ListDeliveries", func(t *testing.T) {
+		store := newStore(t)
+
+		now := time.Now().UTC().Truncate(time.Microsecond)
+		event := &events.Event{ID: 1, Type: events.Deleted, UserID: 1, Time: now}
+
+		deliveries := []*webhook.Delivery{
+			newDelivery(1, event, now),
+			newDelivery(2, event, now),
+			newDelivery(1, event, now),
+		}
+
+		require.NoError(t, store.EnqueueDeliveries(event.ID, deliveries))
+
+		deliveries[0].Status = webhook.Dead
+		require.NoError(t, store.UpdateDelivery(deliveries[0]))
+
+		listed, err := store.ListDeliveries(webhook.DeliveryFilter{Limit: 10})
+		require.NoError(t, err)
+		assert.Equal(t, []int64{deliveries[2].ID, deliveries[1].ID, deliveries[0].ID}, deliveryIDs(listed))
+
+		listed, err = store.ListDeliveries(webhook.DeliveryFilter{Limit: 2})
+		require.NoError(t, err)
+		assert.Equal(t, []int64{deliveries[2].ID, deliveries[1].ID}, deliveryIDs(listed))
+
+		listed, err = store.ListDeliveries(webhook.DeliveryFilter{WebhookID: 1, Limit: 10})
+		require.NoError(t, err)
+		assert.Equal(t, []int64{deliveries[2].ID, deliveries[0].ID}, deliveryIDs(listed))
+
+		listed, err = store.ListDeliveries(webhook.DeliveryFilter{WebhookID: 1, Status: webhook.Dead, Limit: 10})
+		require.NoError(t, err)
+		assert.Equal(t, []int64{deliveries[0].ID}, deliveryIDs(listed))
+		assert.Nil(t, listed[0].Event.User)
+	})
+}